@@ -0,0 +1,170 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// BuildResult is the result of the build of a test case.
+type BuildResult struct {
+	// Command is the packer build command that ran.
+	Command *exec.Cmd
+	// LogFile is the path of the logs of the build.
+	LogFile string
+	// Output is the machine-readable output of the build.
+	Output string
+}
+
+// ExitCode returns the exit code of the build, or -1 if it didn't run.
+func (r *BuildResult) ExitCode() int {
+	if r.Command == nil || r.Command.ProcessState == nil {
+		return -1
+	}
+	return r.Command.ProcessState.ExitCode()
+}
+
+// Log returns the content of the logs of the build.
+func (r *BuildResult) Log() (string, error) {
+	b, err := os.ReadFile(r.LogFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the logs of the build: %s", err)
+	}
+	return string(b), nil
+}
+
+// Artifact is an artifact of a build, as reported in the machine-readable
+// output.
+type Artifact struct {
+	// Build is the name of the build that produced the artifact.
+	Build string `json:"build"`
+	// BuilderID is the ID of the builder that produced the artifact.
+	BuilderID string `json:"builder_id"`
+	// ID is the ID of the artifact, like the ID of a cloud image.
+	ID string `json:"id"`
+}
+
+// Artifacts returns the artifacts listed in the machine-readable output of
+// the build, in order.
+func (r *BuildResult) Artifacts() []Artifact {
+	type key struct{ build, index string }
+	var (
+		order     []key
+		artifacts = map[key]*Artifact{}
+	)
+	for _, line := range strings.Split(r.Output, "\n") {
+		// timestamp,build,artifact,index,field,value...
+		parts := strings.SplitN(strings.TrimRight(line, "\r"), ",", 6)
+		if len(parts) < 6 || parts[2] != "artifact" {
+			continue
+		}
+		k := key{parts[1], parts[3]}
+		a, ok := artifacts[k]
+		if !ok {
+			a = &Artifact{Build: parts[1]}
+			artifacts[k] = a
+			order = append(order, k)
+		}
+		value := unescapeMachineValue(parts[5])
+		switch parts[4] {
+		case "id":
+			a.ID = value
+		case "builder-id":
+			a.BuilderID = value
+		}
+	}
+	res := make([]Artifact, 0, len(order))
+	for _, k := range order {
+		res = append(res, *artifacts[k])
+	}
+	return res
+}
+
+// unescapeMachineValue unescapes a value of the machine-readable output.
+func unescapeMachineValue(v string) string {
+	return strings.NewReplacer(`%!(PACKER_COMMA)`, ",", `\n`, "\n", `\r`, "\r").Replace(v)
+}
+
+// check returns the errors of the failing assertions.
+func (r *BuildResult) check(assertions []Assertion) error {
+	var errs []error
+	for _, assert := range assertions {
+		if err := assert(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Assertion checks the result of the build of a test case.
+type Assertion func(*BuildResult) error
+
+// AssertExitCode asserts that the build exited with code.
+func AssertExitCode(code int) Assertion {
+	return func(r *BuildResult) error {
+		if got := r.ExitCode(); got != code {
+			return fmt.Errorf("expected exit code %d, got %d", code, got)
+		}
+		return nil
+	}
+}
+
+// AssertLogContains asserts that the logs of the build match the regular
+// expression pattern.
+func AssertLogContains(pattern string) Assertion {
+	re := regexp.MustCompile(pattern)
+	return func(r *BuildResult) error {
+		logs, err := r.Log()
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(logs) {
+			return fmt.Errorf("logs do not match %q", pattern)
+		}
+		return nil
+	}
+}
+
+// AssertLogNotContains asserts that the logs of the build don't match the
+// regular expression pattern.
+func AssertLogNotContains(pattern string) Assertion {
+	re := regexp.MustCompile(pattern)
+	return func(r *BuildResult) error {
+		logs, err := r.Log()
+		if err != nil {
+			return err
+		}
+		if match := re.FindString(logs); match != "" {
+			return fmt.Errorf("logs match %q: %q", pattern, match)
+		}
+		return nil
+	}
+}
+
+// AssertArtifactExists asserts that the build produced at least one artifact
+// and that exists returns true for each of them, like when the image of the
+// artifact is found in the cloud it was built on.
+func AssertArtifactExists(exists func(Artifact) (bool, error)) Assertion {
+	return func(r *BuildResult) error {
+		artifacts := r.Artifacts()
+		if len(artifacts) == 0 {
+			return errors.New("the build produced no artifact")
+		}
+		for _, a := range artifacts {
+			ok, err := exists(a)
+			if err != nil {
+				return fmt.Errorf("failed to check that artifact %s of build %s exists: %s", a.ID, a.Build, err)
+			}
+			if !ok {
+				return fmt.Errorf("artifact %s of build %s does not exist", a.ID, a.Build)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMachineOutput = `1700000000,,ui,say,==> Builds finished.
+1700000000,basic,artifact-count,1
+1700000000,basic,artifact,0,builder-id,happycloud
+1700000000,basic,artifact,0,id,img-1234
+1700000000,basic,artifact,0,string,Image img-1234%!(PACKER_COMMA) in zone a
+1700000000,windows,artifact,0,builder-id,happycloud
+1700000000,windows,artifact,0,id,img-5678
+`
+
+func TestBuildResult_Artifacts(t *testing.T) {
+	r := &BuildResult{Output: testMachineOutput}
+	expected := []Artifact{
+		{Build: "basic", BuilderID: "happycloud", ID: "img-1234"},
+		{Build: "windows", BuilderID: "happycloud", ID: "img-5678"},
+	}
+	if got := r.Artifacts(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad artifacts: %#v", got)
+	}
+}
+
+func TestBuildResult_check(t *testing.T) {
+	logfile := filepath.Join(t.TempDir(), "packer_log.txt")
+	if err := os.WriteFile(logfile, []byte("ui: Build 'basic' finished after 2 minutes."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &BuildResult{LogFile: logfile, Output: testMachineOutput}
+
+	exists := func(a Artifact) (bool, error) { return a.ID == "img-1234", nil }
+	err := r.check([]Assertion{
+		AssertLogContains(`Build '\w+' finished`),
+		AssertLogNotContains(`(?i)error`),
+		AssertExitCode(0),
+		AssertLogNotContains(`finished after \d+ minutes`),
+		AssertArtifactExists(exists),
+	})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	expected := []string{
+		"expected exit code 0, got -1",
+		`logs match "finished after \\d+ minutes": "finished after 2 minutes"`,
+		"artifact img-5678 of build windows does not exist",
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad errors: %#v", got)
+	}
+
+	if err := r.check([]Assertion{AssertLogContains("basic")}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
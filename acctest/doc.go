@@ -5,6 +5,162 @@
 Package acctest provides an acceptance testing framework for testing builders
 and provisioners.
 
+# Running Plugin Acceptance Tests in Parallel
+
+`TestPlugin` runs a single `PluginTestCase`; `TestPlugins` runs several of
+them in parallel, as subtests, up to the `-parallel` flag of `go test`:
+
+```go
+
+	func TestAccBuilders(t *testing.T) {
+		acctest.TestPlugins(t, basicTestCase, windowsTestCase)
+	}
+
+```
+
+So that the test cases don't share resources, each of them gets a unique
+`Prefix`, like `acc-basic-x7k2p0`. It replaces the `{{acc_prefix}}`
+placeholder in the template of the test case, and is set in the
+`PACKER_ACC_PREFIX` environment variable of the build:
+
+```hcl
+
+	source "amazon-ebs" "basic" {
+	  ami_name = "{{acc_prefix}}-ubuntu"
+	}
+
+```
+
+A test case fails if its logs reference the prefix of another test case.
+
+# Checking the Result of a Build
+
+Rather than parsing the logs in `Check`, a `PluginTestCase` can list
+`Assertions` on the result of its build. They are all checked, and all the
+failing ones are reported:
+
+```go
+
+	testCase := &acctest.PluginTestCase{
+		Name:     "basic",
+		Template: basicTemplate,
+		PreCheck: func() error {
+			if os.Getenv("HAPPYCLOUD_TOKEN") == "" {
+				return fmt.Errorf("HAPPYCLOUD_TOKEN must be set")
+			}
+			return nil
+		},
+		Assertions: []acctest.Assertion{
+			acctest.AssertExitCode(0),
+			acctest.AssertLogContains(`Build 'happycloud\.basic' finished`),
+			acctest.AssertLogNotContains(`(?i)panic`),
+			acctest.AssertArtifactExists(func(a acctest.Artifact) (bool, error) {
+				return client.ImageExists(a.ID)
+			}),
+		},
+		Teardown: func() error {
+			return client.DeleteImages(prefix)
+		},
+	}
+
+```
+
+`PreCheck` skips the test case when it returns an error, `PostBuild` is
+called with the result of the build before the assertions, and `Teardown`
+is always called once `Setup` succeeded.
+
+# Testing Against Several Versions of Packer
+
+The test cases run with the packer found in the PATH, unless
+`PACKER_ACC_PACKER_VERSIONS` lists the versions of packer to run them with,
+like `1.9.4,1.10.0,local`, or the test case sets `PackerVersions`. The
+versions are downloaded from releases.hashicorp.com to the
+`PACKER_ACC_CACHE_DIR` directory. `PACKER_ACC_PROTOCOL_VERSIONS`, or
+`ProtocolVersions`, also runs them with each protocol version of the plugins:
+`v1` for gob only, and `v2` for protobuf. Each combination is a subtest, like
+`TestAccBasic/packer-1.10.0/protocol-v1`, and the results are logged:
+
+```sh
+
+	PACKER_ACC=1 PACKER_ACC_PACKER_VERSIONS=1.9.4,1.10.0 \
+		PACKER_ACC_PROTOCOL_VERSIONS=v1,v2 go test -run TestAcc ./...
+
+```
+
+# Reporting the Results
+
+The results of the test cases, with their duration, the logs of the failed
+ones, their artifacts and their failure messages, are written as JSON to the
+`PACKER_ACC_REPORT_JSON` file and as JUnit XML to the
+`PACKER_ACC_REPORT_JUNIT` file, if set, after each test case. `Results`
+returns them, like for a custom report written from `TestMain`.
+
+# Replaying Cloud API Interactions
+
+A test case with a `Cassette` replays the HTTP interactions of its build
+from the cassette, so that it runs without credentials. The plugin must
+wrap the transport of its HTTP clients with `recorder.Wrap`, which does
+nothing outside of the test cases with a cassette. Running the tests with
+`PACKER_ACC_RECORDER_MODE=record` sends the requests to the cloud, and
+records the cassettes again, with the credentials and sensitive values
+scrubbed. The prefix of a test case with a cassette has no random suffix,
+like `acc-basic`, so that the replayed requests match the recorded ones:
+
+```go
+
+	testCase := &acctest.PluginTestCase{
+		Name:     "basic",
+		Template: basicTemplate,
+		Cassette: "testdata/cassettes/basic.json",
+		PreCheck: func() error {
+			if recorder.Recording() && os.Getenv("HAPPYCLOUD_TOKEN") == "" {
+				return fmt.Errorf("HAPPYCLOUD_TOKEN must be set to record")
+			}
+			return nil
+		},
+	}
+
+```
+
+# Testing Without a Cloud
+
+The `simulator` package has a builder, a provisioner and a datasource that
+run on the host, to test the other components of a plugin without a
+hypervisor or a cloud. The simulator builder connects its communicator,
+`local` by default, runs the provisioners, publishes its `generated_data`
+along with `Simulator_ID`, `Simulator_Host` and `Simulator_Prefix`, and
+writes the `files` of its artifact to its `output_directory`. It fails with
+`error`, when set, once the provisioners ran. To use them, register them in
+the plugin set of the plugin under test:
+
+```go
+
+	pps.RegisterBuilder("simulator", new(simulator.Builder))
+	pps.RegisterProvisioner("simulator", new(simulator.Provisioner))
+	pps.RegisterDatasource("simulator", new(simulator.Datasource))
+
+```
+
+A provisioner is then tested against the simulator builder of its plugin:
+
+```hcl
+
+	source "happycloud-simulator" "basic" {
+	  files = {
+	    "image.txt" = "{{acc_prefix}}"
+	  }
+	}
+
+	build {
+	  sources = ["source.happycloud-simulator.basic"]
+
+	  provisioner "happycloud-shell" {
+	    inline = ["echo $PACKER_ACC_PREFIX"]
+	  }
+	}
+
+```
+
 # Writing Provisioner Acceptance Tests
 
 Packer has implemented a `ProvisionerTestCase` structure to help write
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/random"
+)
+
+const (
+	// PrefixPlaceholder is replaced by the Prefix of a test case in its
+	// template, like in `ami_name = "{{acc_prefix}}-ubuntu"`.
+	PrefixPlaceholder = "{{acc_prefix}}"
+	// PrefixEnvVar is the environment variable holding the Prefix of a test
+	// case, for the builds and scripts of the test case.
+	PrefixEnvVar = "PACKER_ACC_PREFIX"
+)
+
+var prefixNameRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// newPrefix returns a unique prefix for the resources of the test case named
+// name.
+func newPrefix(name string) string {
+	return fmt.Sprintf("%s-%s", cassettePrefix(name), random.AlphaNumLower(6))
+}
+
+// cassettePrefix returns the prefix for the resources of the test case named
+// name, when it has a cassette. It has no random part, so that the recorded
+// requests match the replayed ones.
+func cassettePrefix(name string) string {
+	name = strings.Trim(prefixNameRe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) > 20 {
+		name = strings.TrimRight(name[:20], "-")
+	}
+	return "acc-" + name
+}
+
+// setPrefix sets the Prefix of testCase, unless already set.
+func setPrefix(testCase *PluginTestCase) {
+	switch {
+	case testCase.Prefix != "":
+	case testCase.Cassette != "":
+		testCase.Prefix = cassettePrefix(testCase.Name)
+	default:
+		testCase.Prefix = newPrefix(testCase.Name)
+	}
+}
+
+// TestPlugins runs testCases in parallel, as parallel subtests of t named
+// after the test cases. The number of test cases running at the same time is
+// bounded by the -parallel flag of go test.
+//
+// Each test case gets its own Prefix, unless already set, and fails if its
+// logs reference the prefix of another test case, revealing that it used the
+// resources of another test case. The test cases must have unique names, as
+// their template and log files are named after them; their Setup and
+// Teardown functions must be safe to run concurrently.
+func TestPlugins(t *testing.T, testCases ...*PluginTestCase) {
+	if os.Getenv(TestEnvVar) == "" {
+		t.Skipf("Acceptance tests skipped unless env '%s' set", TestEnvVar)
+		return
+	}
+
+	names := map[string]bool{}
+	prefixes := map[string]string{}
+	for _, testCase := range testCases {
+		if names[testCase.Name] {
+			t.Fatalf("test case name %q is not unique", testCase.Name)
+		}
+		names[testCase.Name] = true
+		setPrefix(testCase)
+		if other, ok := prefixes[testCase.Prefix]; ok {
+			t.Fatalf("test cases %q and %q have the same prefix %q", other, testCase.Name, testCase.Prefix)
+		}
+		prefixes[testCase.Prefix] = testCase.Name
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		var others []*PluginTestCase
+		for _, other := range testCases {
+			if other != testCase {
+				others = append(others, other)
+			}
+		}
+		t.Run(testCase.Name, func(t *testing.T) {
+			t.Parallel()
+			runTargets(t, testCase, others)
+		})
+	}
+}
+
+// checkLeaks returns an error if the log file of testCase references the
+// prefix of one of others.
+func checkLeaks(logfile string, testCase *PluginTestCase, others []*PluginTestCase) error {
+	if len(others) == 0 {
+		return nil
+	}
+	b, err := os.ReadFile(logfile)
+	if err != nil {
+		return fmt.Errorf("failed to read the logs to check for leaks: %s", err)
+	}
+	for _, other := range others {
+		if other.Prefix != "" && strings.Contains(string(b), other.Prefix) {
+			return fmt.Errorf("test case %q references %q, the prefix of the resources of test case %q",
+				testCase.Name, other.Prefix, other.Name)
+		}
+	}
+	return nil
+}
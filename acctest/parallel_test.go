@@ -0,0 +1,43 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestNewPrefix(t *testing.T) {
+	prefix := newPrefix("Amazon EBS: basic build with a long name")
+	if ok, _ := regexp.MatchString(`^acc-amazon-ebs-basic-bui-[a-z0-9]{6}$`, prefix); !ok {
+		t.Fatalf("bad prefix: %s", prefix)
+	}
+	if newPrefix("basic") == newPrefix("basic") {
+		t.Fatal("prefixes should be unique")
+	}
+
+	testCase := &PluginTestCase{Name: "basic", Cassette: "testdata/basic.json"}
+	setPrefix(testCase)
+	if testCase.Prefix != "acc-basic" {
+		t.Fatalf("the prefix of a test case with a cassette should be stable, got %s", testCase.Prefix)
+	}
+}
+
+func TestCheckLeaks(t *testing.T) {
+	logfile := filepath.Join(t.TempDir(), "packer_log.txt")
+	if err := os.WriteFile(logfile, []byte("creating image acc-basic-abc123-ubuntu"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	basic := &PluginTestCase{Name: "basic", Prefix: "acc-basic-abc123"}
+	other := &PluginTestCase{Name: "other", Prefix: "acc-other-def456"}
+
+	if err := checkLeaks(logfile, basic, []*PluginTestCase{other}); err != nil {
+		t.Fatalf("unexpected leak: %s", err)
+	}
+	if err := checkLeaks(logfile, other, []*PluginTestCase{basic}); err == nil {
+		t.Fatal("the prefix of basic should be reported as leaked")
+	}
+}
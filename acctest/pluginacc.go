@@ -10,7 +10,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/acctest/recorder"
 )
 
 // TestEnvVar must be set to a non-empty value for acceptance tests to run.
@@ -38,6 +42,18 @@ type PluginTestCase struct {
 	// the step executed successfully. If this is not set, then the next
 	// step will be called
 	Check func(*exec.Cmd, string) error
+	// PreCheck, if non-nil, is called before anything else. The test case is
+	// skipped when it returns an error, like when the credentials of a cloud
+	// are not set.
+	PreCheck func() error
+	// PostBuild, if non-nil, is called once the build is done, before Check
+	// and the Assertions, for example to collect the resources to assert on.
+	// The test case fails when it returns an error.
+	PostBuild func(*BuildResult) error
+	// Assertions are checked after Check, like AssertExitCode(0) or
+	// AssertLogContains("Build finished"). All the failing assertions are
+	// reported.
+	Assertions []Assertion
 	// Name is the name of the test case. Be simple but unique and descriptive.
 	Name string
 	// Setup, if non-nil, will be called once before the test case
@@ -47,32 +63,88 @@ type PluginTestCase struct {
 	// binaries are installed, or text fixtures are in place.
 	Setup func() error
 	// Teardown will be called before the test case is over regardless
-	// of if the test succeeded or failed. This should return an error
+	// of if the test succeeded or failed, once Setup succeeded. This should return an error
 	// in the case that the test can't guarantee all resources were
 	// properly cleaned up.
 	Teardown TestTeardownFunc
-	// Template is the testing HCL2 template to use.
+	// Template is the testing HCL2 template to use. PrefixPlaceholder is
+	// replaced by the Prefix of the test case in the template.
 	Template string
 	// Type is the type of the plugin.
 	Type string
+	// Prefix is the unique prefix of the names of the resources the test case
+	// creates. If empty, it is generated from the Name of the test case and a
+	// random suffix, or only from the Name if the test case has a Cassette.
+	// It replaces PrefixPlaceholder in the Template and is passed to packer
+	// in the PrefixEnvVar environment variable, so that test cases running
+	// in parallel don't share resources.
+	Prefix string
+	// Cassette, if set, is the path of the cassette the HTTP interactions of
+	// the build are replayed from, or recorded in when the
+	// recorder.ModeEnvVar environment variable is set to "record". The
+	// plugin must wrap the transport of its HTTP clients with recorder.Wrap.
+	Cassette string
+	// PackerVersions are the versions of packer to run the test case with,
+	// like "1.10.0", or "local" for the packer found in the PATH. They
+	// default to the versions of the PackerVersionsEnvVar environment
+	// variable, or to the local packer. The versions are downloaded with
+	// PackerBinary.
+	PackerVersions []string
+	// ProtocolVersions are the protocol versions of the plugins to run the
+	// test case with, for each packer version: "v1" for gob only and "v2"
+	// for protobuf. They default to the versions of the
+	// ProtocolVersionsEnvVar environment variable, or to the protocol
+	// negotiated by packer.
+	ProtocolVersions []string
 }
 
 // TestTeardownFunc is the callback used for Teardown in TestCase.
 type TestTeardownFunc func() error
 
-//nolint:errcheck
+// TestPlugin runs testCase.
 func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 	if os.Getenv(TestEnvVar) == "" {
 		t.Skipf("Acceptance tests skipped unless env '%s' set", TestEnvVar)
 		return
 	}
+	setPrefix(testCase)
+	runTargets(t, testCase, nil)
+}
+
+//nolint:errcheck
+func testPlugin(t *testing.T, testCase *PluginTestCase, others []*PluginTestCase, tg target) {
+	res := &Result{Test: t.Name(), Name: testCase.Name}
+	if tg != (target{}) {
+		res.Target = tg.String()
+	}
+	defer res.record(t, time.Now())
+	fatalf := func(format string, args ...interface{}) {
+		res.Failure = fmt.Sprintf(format, args...)
+		t.Fatal(res.Failure)
+	}
+
+	if testCase.PreCheck != nil {
+		if err := testCase.PreCheck(); err != nil {
+			res.Failure = err.Error()
+			t.Skipf("test %s skipped: %s", testCase.Name, err)
+		}
+	}
 
 	if testCase.Setup != nil {
 		err := testCase.Setup()
 		if err != nil {
-			t.Fatalf("test %s setup failed: %s", testCase.Name, err)
+			fatalf("test %s setup failed: %s", testCase.Name, err)
 		}
 	}
+	// Clean up anything created in the plugin run
+	if testCase.Teardown != nil {
+		defer func() {
+			cleanErr := testCase.Teardown()
+			if cleanErr != nil {
+				t.Logf("bad: failed to clean up test-created resources: %s", cleanErr.Error())
+			}
+		}()
+	}
 
 	logfile := fmt.Sprintf("packer_log_%s.txt", testCase.Name)
 
@@ -84,38 +156,40 @@ func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 
 	// Write config hcl2 template
 	out := bytes.NewBuffer(nil)
-	fmt.Fprintf(out, testCase.Template)
+	fmt.Fprintf(out, strings.ReplaceAll(testCase.Template, PrefixPlaceholder, testCase.Prefix))
 	outputFile, err := os.Create(templatePath)
 	if err != nil {
-		t.Fatalf("bad: failed to create template file: %s", err.Error())
+		fatalf("bad: failed to create template file: %s", err.Error())
 	}
 	_, err = outputFile.Write(out.Bytes())
 	if err != nil {
-		t.Fatalf("bad: failed to write template file: %s", err.Error())
+		fatalf("bad: failed to write template file: %s", err.Error())
 	}
 	outputFile.Sync()
 
 	// Make sure packer is installed:
-	packerbin, err := exec.LookPath("packer")
+	packerbin, err := tg.packerBinary()
 	if err != nil {
-		t.Fatalf("Couldn't find packer binary installed on system: %s", err.Error())
+		fatalf("%s", err)
 	}
 
 	if testCase.Init {
 		initLogfile := fmt.Sprintf("packer_init_log_%s.txt", testCase.Name)
 		initCommand := exec.Command(packerbin, "init", templatePath)
 		initCommand.Env = append(initCommand.Env, os.Environ()...)
-		initCommand.Env = append(initCommand.Env, "PACKER_LOG=1", fmt.Sprintf("PACKER_LOG_PATH=%s", initLogfile))
+		initCommand.Env = append(initCommand.Env, "PACKER_LOG=1", fmt.Sprintf("PACKER_LOG_PATH=%s", initLogfile),
+			fmt.Sprintf("%s=%s", PrefixEnvVar, testCase.Prefix))
 		initCommand.Run()
 
 		if testCase.CheckInit != nil {
 			if err := testCase.CheckInit(initCommand, initLogfile); err != nil {
 				cwd, _ := os.Getwd()
-				t.Fatalf(fmt.Sprintf("Error running plugin acceptance"+
+				res.LogFile = filepath.Join(cwd, initLogfile)
+				fatalf("Error running plugin acceptance"+
 					" tests: %s\nLogs can be found at %s\nand the "+
 					"acceptance test template can be found at %s",
-					err.Error(), filepath.Join(cwd, initLogfile),
-					filepath.Join(cwd, templatePath)))
+					err.Error(), res.LogFile,
+					filepath.Join(cwd, templatePath))
 			} else {
 				os.Remove(initLogfile)
 			}
@@ -129,33 +203,56 @@ func TestPlugin(t *testing.T, testCase *PluginTestCase) {
 	buildArgs = append(buildArgs, "--machine-readable", templatePath)
 
 	// Run build
+	var stdout bytes.Buffer
 	buildCommand := exec.Command(packerbin, buildArgs...)
+	buildCommand.Stdout = &stdout
 	buildCommand.Env = append(buildCommand.Env, os.Environ()...)
 	buildCommand.Env = append(buildCommand.Env, "PACKER_LOG=1",
-		fmt.Sprintf("PACKER_LOG_PATH=%s", logfile))
+		fmt.Sprintf("PACKER_LOG_PATH=%s", logfile),
+		fmt.Sprintf("%s=%s", PrefixEnvVar, testCase.Prefix))
+	if tg.protocolVersion != "" {
+		buildCommand.Env = append(buildCommand.Env, fmt.Sprintf("%s=%s", protocolVersionEnvVar, tg.protocolVersion))
+	}
+	if testCase.Cassette != "" {
+		cassette, err := filepath.Abs(testCase.Cassette)
+		if err != nil {
+			fatalf("bad: cassette path: %s", err)
+		}
+		buildCommand.Env = append(buildCommand.Env, fmt.Sprintf("%s=%s", recorder.CassetteEnvVar, cassette))
+	}
 	buildCommand.Run()
 
+	result := &BuildResult{
+		Command: buildCommand,
+		LogFile: logfile,
+		Output:  stdout.String(),
+	}
+	res.Artifacts = result.Artifacts()
+
 	// Check for test custom pass/fail before we clean up
 	var checkErr error
-	if testCase.Check != nil {
+	if testCase.PostBuild != nil {
+		checkErr = testCase.PostBuild(result)
+	}
+	if checkErr == nil && testCase.Check != nil {
 		checkErr = testCase.Check(buildCommand, logfile)
 	}
-	// Clean up anything created in the plugin run
-	if testCase.Teardown != nil {
-		cleanErr := testCase.Teardown()
-		if cleanErr != nil {
-			t.Logf("bad: failed to clean up test-created resources: %s", cleanErr.Error())
-		}
+	if checkErr == nil {
+		checkErr = result.check(testCase.Assertions)
+	}
+	if checkErr == nil {
+		checkErr = checkLeaks(logfile, testCase, others)
 	}
 
 	// Fail test if check failed.
 	if checkErr != nil {
 		cwd, _ := os.Getwd()
-		t.Fatalf(fmt.Sprintf("Error running plugin acceptance"+
+		res.LogFile = filepath.Join(cwd, logfile)
+		fatalf("Error running plugin acceptance"+
 			" tests: %s\nLogs can be found at %s\nand the "+
 			"acceptance test template can be found at %s",
-			checkErr.Error(), filepath.Join(cwd, logfile),
-			filepath.Join(cwd, templatePath)))
+			checkErr.Error(), res.LogFile,
+			filepath.Join(cwd, templatePath))
 	} else {
 		os.Remove(templatePath)
 		os.Remove(logfile)
@@ -0,0 +1,318 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package recorder records the HTTP interactions of a plugin with the API of a
+cloud in cassettes, and replays them, so that acceptance tests can run
+deterministically without credentials.
+
+Plugins wrap the transport of their HTTP clients with Wrap:
+
+	client := &http.Client{
+		Transport: recorder.Wrap(http.DefaultTransport),
+	}
+
+Wrap returns the transport as is unless the CassetteEnvVar environment
+variable is set, which acctest does for the test cases with a Cassette. In
+ModeReplay, the default, the responses are served from the cassette, and a
+request that was not recorded fails. In ModeRecord, set with the ModeEnvVar
+environment variable, the requests are sent to the cloud and the cassette is
+rewritten with the interactions.
+
+Before being written, the interactions are scrubbed: the values of the
+headers holding credentials, like Authorization, and the values registered
+with packersdk.LogSecretFilter, like the sensitive options of the
+configurations, are replaced by "<sensitive>".
+*/
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// Mode is the mode of a Recorder.
+type Mode string
+
+const (
+	// ModeReplay serves the responses from the cassette.
+	ModeReplay Mode = "replay"
+	// ModeRecord sends the requests and records the interactions in the
+	// cassette, replacing its previous content.
+	ModeRecord Mode = "record"
+)
+
+const (
+	// CassetteEnvVar is the environment variable holding the path of the
+	// cassette of the recorders returned by Wrap.
+	CassetteEnvVar = "PACKER_ACC_CASSETTE"
+	// ModeEnvVar is the environment variable holding the Mode of the
+	// recorders returned by Wrap, ModeReplay if not set.
+	ModeEnvVar = "PACKER_ACC_RECORDER_MODE"
+)
+
+// scrubbedHeaders are the headers whose values are replaced by
+// "<sensitive>" in cassettes.
+var scrubbedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Amz-Security-Token",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// Request is a recorded HTTP request.
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the list of the interactions of a test case.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper recording or replaying interactions.
+type Recorder struct {
+	// Mode is the mode of the recorder.
+	Mode Mode
+	// Path is the path of the cassette.
+	Path string
+	// Transport sends the requests in ModeRecord. http.DefaultTransport is
+	// used if nil.
+	Transport http.RoundTripper
+	// Scrub, if set, is called on the interactions, after the default
+	// scrubbing, before they are recorded, like to remove account IDs.
+	Scrub func(*Interaction)
+
+	tape *tape
+}
+
+// tape is a cassette being recorded or replayed, shared by the recorders
+// of a process with the same path.
+type tape struct {
+	l        sync.Mutex
+	mode     Mode
+	cassette Cassette
+	used     []bool
+}
+
+var (
+	tapesLock sync.Mutex
+	tapes     = map[string]*tape{}
+)
+
+// New returns a recorder of the cassette at path. In ModeReplay, the
+// cassette is loaded. The recorders of a same cassette share its
+// interactions; the cassette must only be recorded by one process at a time.
+func New(path string, mode Mode) (*Recorder, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("unknown recorder mode %q", mode)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tapesLock.Lock()
+	defer tapesLock.Unlock()
+	t, ok := tapes[abs]
+	if ok && t.mode != mode {
+		return nil, fmt.Errorf("cassette %s is already used in %s mode", path, t.mode)
+	}
+	if !ok {
+		t = &tape{mode: mode}
+		if mode == ModeReplay {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load cassette: %s", err)
+			}
+			if err := json.Unmarshal(b, &t.cassette); err != nil {
+				return nil, fmt.Errorf("failed to parse cassette %s: %s", path, err)
+			}
+			t.used = make([]bool, len(t.cassette.Interactions))
+		}
+		tapes[abs] = t
+	}
+	return &Recorder{Mode: mode, Path: path, tape: t}, nil
+}
+
+// Wrap returns a recorder wrapping transport when the CassetteEnvVar
+// environment variable is set, or transport otherwise. It panics if the
+// cassette can't be loaded, as it is to be called by plugins under test.
+func Wrap(transport http.RoundTripper) http.RoundTripper {
+	path := os.Getenv(CassetteEnvVar)
+	if path == "" {
+		return transport
+	}
+	r, err := New(path, EnvMode())
+	if err != nil {
+		panic(err)
+	}
+	r.Transport = transport
+	return r
+}
+
+// EnvMode returns the mode set with the ModeEnvVar environment variable,
+// ModeReplay if not set.
+func EnvMode() Mode {
+	if mode := os.Getenv(ModeEnvVar); mode != "" {
+		return Mode(mode)
+	}
+	return ModeReplay
+}
+
+// Recording returns true if the recorders run in ModeRecord, that is when
+// the acceptance tests need credentials.
+func Recording() bool {
+	return EnvMode() == ModeRecord
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	recorded := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: req.Header.Clone(),
+			Body:    body,
+		},
+	}
+	if r.Mode == ModeReplay {
+		r.scrub(&recorded)
+		return r.replay(req, recorded.Request)
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	recorded.Response = Response{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header.Clone(),
+		Body:       respBody,
+	}
+	r.scrub(&recorded)
+	if err := r.record(recorded); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replay returns the response of the first unused interaction matching req.
+func (r *Recorder) replay(req *http.Request, scrubbed Request) (*http.Response, error) {
+	t := r.tape
+	t.l.Lock()
+	defer t.l.Unlock()
+	for i, in := range t.cassette.Interactions {
+		if t.used[i] || in.Request.Method != scrubbed.Method ||
+			in.Request.URL != scrubbed.URL || in.Request.Body != scrubbed.Body {
+			continue
+		}
+		t.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no interaction recorded in %s for %s %s", r.Path, scrubbed.Method, scrubbed.URL)
+}
+
+// record appends in to the cassette, and writes it.
+func (r *Recorder) record(in Interaction) error {
+	t := r.tape
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, in)
+	b, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.Path, b, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %s", err)
+	}
+	return nil
+}
+
+func (r *Recorder) scrub(in *Interaction) {
+	for _, h := range []http.Header{in.Request.Headers, in.Response.Headers} {
+		for _, name := range scrubbedHeaders {
+			if values := h.Values(name); len(values) > 0 {
+				h.Set(name, "<sensitive>")
+			}
+		}
+		for name, values := range h {
+			for i, v := range values {
+				values[i] = packersdk.LogSecretFilter.FilterString(v)
+			}
+			h[name] = values
+		}
+	}
+	in.Request.URL = packersdk.LogSecretFilter.FilterString(in.Request.URL)
+	in.Request.Body = packersdk.LogSecretFilter.FilterString(in.Request.Body)
+	in.Response.Body = packersdk.LogSecretFilter.FilterString(in.Response.Body)
+	if r.Scrub != nil {
+		r.Scrub(in)
+	}
+}
+
+// readBody reads *body, and replaces it with a reader of its content.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package recorder
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestRecorder(t *testing.T) {
+	packersdk.LogSecretFilter.Set("recorder-s3cr3t")
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=1234")
+		w.Write([]byte("created " + string(body)))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "fixtures", "cassette.json")
+	rec, err := New(path, ModeRecord)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client := &http.Client{Transport: rec}
+	if got := post(t, client, server.URL+"/images", "password=recorder-s3cr3t"); got != "created password=recorder-s3cr3t" {
+		t.Fatalf("bad response: %s", got)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.Contains(string(b), "recorder-s3cr3t") || strings.Contains(string(b), "Bearer") ||
+		strings.Contains(string(b), "session=1234") {
+		t.Fatalf("the cassette should be scrubbed:\n%s", b)
+	}
+
+	// Replay from the recorded cassette, without the server.
+	delete(tapes, mustAbs(t, path))
+	server.Close()
+	rec, err = New(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client = &http.Client{Transport: rec}
+	if got := post(t, client, server.URL+"/images", "password=recorder-s3cr3t"); got != "created password=<sensitive>" {
+		t.Fatalf("bad replayed response: %s", got)
+	}
+	if calls != 1 {
+		t.Fatalf("the server should be called once, got %d", calls)
+	}
+
+	// Each interaction is only replayed once.
+	req, _ := http.NewRequest("POST", server.URL+"/images", strings.NewReader("password=recorder-s3cr3t"))
+	if _, err := client.Do(req); err == nil || !strings.Contains(err.Error(), "no interaction recorded") {
+		t.Fatalf("bad error: %v", err)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	os.Unsetenv(CassetteEnvVar)
+	if got := Wrap(http.DefaultTransport); got != http.DefaultTransport {
+		t.Fatalf("the transport should not be wrapped, got %T", got)
+	}
+
+	t.Setenv(CassetteEnvVar, filepath.Join(t.TempDir(), "cassette.json"))
+	t.Setenv(ModeEnvVar, string(ModeRecord))
+	rec, ok := Wrap(http.DefaultTransport).(*Recorder)
+	if !ok || rec.Mode != ModeRecord || rec.Transport != http.DefaultTransport {
+		t.Fatalf("bad recorder: %#v", rec)
+	}
+	if !Recording() {
+		t.Fatal("should be recording")
+	}
+}
+
+func post(t *testing.T, client *http.Client, url, body string) string {
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer 1234")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func mustAbs(t *testing.T, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return abs
+}
@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// JSONReportEnvVar is the environment variable holding the path of the
+	// JSON report of the test cases, written after each test case.
+	JSONReportEnvVar = "PACKER_ACC_REPORT_JSON"
+	// JUnitReportEnvVar is the environment variable holding the path of the
+	// JUnit XML report of the test cases, written after each test case.
+	JUnitReportEnvVar = "PACKER_ACC_REPORT_JUNIT"
+)
+
+// Status is the status of a test case.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Result is the result of a test case, for a version of packer.
+type Result struct {
+	// Test is the name of the go test that ran the test case, like
+	// "TestAccBasic/packer-1.10.0".
+	Test string `json:"test"`
+	// Name is the name of the test case.
+	Name string `json:"name"`
+	// Target is the version of packer and of the protocol the test case ran
+	// with, like "packer-1.10.0/protocol-v1", if set.
+	Target   string        `json:"target,omitempty"`
+	Status   Status        `json:"status"`
+	Duration time.Duration `json:"duration_ns"`
+	// LogFile is the path of the logs of a failed test case. The logs of the
+	// test cases that pass are removed.
+	LogFile string `json:"log_file,omitempty"`
+	// Artifacts are the artifacts of the build of the test case.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Failure is the failure message of the test case, or the reason it was
+	// skipped.
+	Failure string `json:"failure,omitempty"`
+}
+
+var (
+	resultsLock sync.Mutex
+	results     []Result
+)
+
+// Results returns the results of the test cases ran so far.
+func Results() []Result {
+	resultsLock.Lock()
+	defer resultsLock.Unlock()
+	return append([]Result(nil), results...)
+}
+
+// record records the result of the test case ran by t since start, and
+// writes the reports set by JSONReportEnvVar and JUnitReportEnvVar.
+func (r *Result) record(t *testing.T, start time.Time) {
+	r.Duration = time.Since(start)
+	switch {
+	case t.Skipped():
+		r.Status = StatusSkip
+	case t.Failed():
+		r.Status = StatusFail
+	default:
+		r.Status = StatusPass
+	}
+
+	resultsLock.Lock()
+	defer resultsLock.Unlock()
+	results = append(results, *r)
+
+	reports := []struct {
+		path  string
+		write func(io.Writer, []Result) error
+	}{
+		{os.Getenv(JSONReportEnvVar), WriteJSONReport},
+		{os.Getenv(JUnitReportEnvVar), WriteJUnitReport},
+	}
+	for _, report := range reports {
+		if report.path == "" {
+			continue
+		}
+		if err := writeReport(report.path, report.write); err != nil {
+			t.Logf("bad: failed to write report %s: %s", report.path, err)
+		}
+	}
+}
+
+func writeReport(path string, write func(io.Writer, []Result) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteJSONReport writes results to w as a JSON array.
+func WriteJSONReport(w io.Writer, results []Result) error {
+	if results == nil {
+		results = []Result{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes results to w as a JUnit XML report, with a test
+// suite per go test.
+func WriteJUnitReport(w io.Writer, results []Result) error {
+	var suites junitTestSuites
+	index := map[string]int{}
+	durations := map[string]time.Duration{}
+	for _, r := range results {
+		suiteName, _, _ := strings.Cut(r.Test, "/")
+		i, ok := index[suiteName]
+		if !ok {
+			i = len(suites.Suites)
+			index[suiteName] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: suiteName})
+		}
+		suite := &suites.Suites[i]
+		durations[suiteName] += r.Duration
+
+		tc := junitTestCase{
+			ClassName: suiteName,
+			Name:      r.Test,
+			Time:      junitTime(r.Duration),
+		}
+		firstLine, _, _ := strings.Cut(r.Failure, "\n")
+		switch r.Status {
+		case StatusFail:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: firstLine, Text: r.Failure}
+		case StatusSkip:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: firstLine}
+		}
+		var out []string
+		if r.LogFile != "" {
+			out = append(out, "Logs: "+r.LogFile)
+		}
+		for _, a := range r.Artifacts {
+			out = append(out, fmt.Sprintf("Artifact of %s: %s", a.Build, a.ID))
+		}
+		tc.SystemOut = strings.Join(out, "\n")
+
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = junitTime(durations[suites.Suites[i].Name])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testResults = []Result{
+	{
+		Test:      "TestAccBasic/packer-1.10.0",
+		Name:      "basic",
+		Target:    "packer-1.10.0",
+		Status:    StatusPass,
+		Duration:  90 * time.Second,
+		Artifacts: []Artifact{{Build: "happycloud.basic", BuilderID: "happycloud", ID: "img-1234"}},
+	},
+	{
+		Test:     "TestAccBasic/packer-1.9.4",
+		Name:     "basic",
+		Target:   "packer-1.9.4",
+		Status:   StatusFail,
+		Duration: 30 * time.Second,
+		LogFile:  "/src/packer_log_basic.txt",
+		Failure:  "Error running plugin acceptance tests: expected exit code 0, got 1\nLogs can be found at /src/packer_log_basic.txt",
+	},
+	{
+		Test:    "TestAccWindows",
+		Name:    "windows",
+		Status:  StatusSkip,
+		Failure: "HAPPYCLOUD_TOKEN must be set",
+	},
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, testResults); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="TestAccBasic" tests="2" failures="1" skipped="0" time="120.000">
+    <testcase classname="TestAccBasic" name="TestAccBasic/packer-1.10.0" time="90.000">
+      <system-out>Artifact of happycloud.basic: img-1234</system-out>
+    </testcase>
+    <testcase classname="TestAccBasic" name="TestAccBasic/packer-1.9.4" time="30.000">
+      <failure message="Error running plugin acceptance tests: expected exit code 0, got 1">Error running plugin acceptance tests: expected exit code 0, got 1&#xA;Logs can be found at /src/packer_log_basic.txt</failure>
+      <system-out>Logs: /src/packer_log_basic.txt</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="TestAccWindows" tests="1" failures="0" skipped="1" time="0.000">
+    <testcase classname="TestAccWindows" name="TestAccWindows" time="0.000">
+      <skipped message="HAPPYCLOUD_TOKEN must be set"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if buf.String() != expected {
+		t.Fatalf("bad report:\n%s", buf.String())
+	}
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSONReport(&buf, testResults); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var decoded []Result
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, testResults) {
+		t.Fatalf("bad report: %s", buf.String())
+	}
+}
+
+func TestResult_record(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(JSONReportEnvVar, filepath.Join(dir, "report.json"))
+	t.Setenv(JUnitReportEnvVar, filepath.Join(dir, "junit", "report.xml"))
+
+	t.Run("pass", func(t *testing.T) {
+		defer (&Result{Test: t.Name(), Name: "pass"}).record(t, time.Now())
+	})
+	t.Run("skip", func(t *testing.T) {
+		defer (&Result{Test: t.Name(), Name: "skip"}).record(t, time.Now())
+		t.Skip("skipped")
+	})
+
+	var statuses []Status
+	for _, r := range Results() {
+		if r.Name == "pass" || r.Name == "skip" {
+			statuses = append(statuses, r.Status)
+		}
+	}
+	if !reflect.DeepEqual(statuses, []Status{StatusPass, StatusSkip}) {
+		t.Fatalf("bad statuses: %#v", statuses)
+	}
+	for _, path := range []string{os.Getenv(JSONReportEnvVar), os.Getenv(JUnitReportEnvVar)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("the report should be written: %s", err)
+		}
+	}
+}
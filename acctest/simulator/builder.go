@@ -0,0 +1,362 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// BuilderId is the ID of the artifacts of the simulator builder.
+const BuilderId = "packer.simulator"
+
+const (
+	// prefixEnvVar is acctest.PrefixEnvVar, holding the prefix of the test
+	// case running the build.
+	prefixEnvVar = "PACKER_ACC_PREFIX"
+
+	// localCommunicator is the communicator type running the commands of
+	// the provisioners on the host.
+	localCommunicator = "local"
+)
+
+// Namespace is the namespace of the generated data of the simulator builder.
+const Namespace = "Simulator"
+
+var (
+	// KeyID is the ID of the simulated machine.
+	KeyID = packerbuilderdata.Key{Namespace: Namespace, Name: "ID", Type: packerbuilderdata.TypeString,
+		Description: "The ID of the simulated machine."}
+	// KeyHost is the host the communicator connects to.
+	KeyHost = packerbuilderdata.Key{Namespace: Namespace, Name: "Host", Type: packerbuilderdata.TypeString,
+		Description: "The host the communicator connects to."}
+	// KeyPrefix is the prefix of the test case running the build, empty
+	// outside of acceptance tests.
+	KeyPrefix = packerbuilderdata.Key{Namespace: Namespace, Name: "Prefix", Type: packerbuilderdata.TypeString,
+		Description: "The prefix of the acceptance test case running the build."}
+)
+
+// Config is the configuration of the simulator builder.
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+	// The communicator connecting to the simulated machine. The `local`
+	// communicator, the default, runs the commands of the provisioners on
+	// the host; `ssh` and `winrm` connect to `host`; `none` disables the
+	// provisioners.
+	Comm communicator.Config `mapstructure:",squash"`
+
+	// The host the `ssh` and `winrm` communicators connect to, when
+	// `ssh_host` or `winrm_host` are not set. Defaults to `127.0.0.1`.
+	Host string `mapstructure:"host"`
+	// The directory the files of the artifact are written to. Defaults to
+	// `output-<build name>`.
+	OutputDir string `mapstructure:"output_directory"`
+	// The files of the artifact, by name relative to `output_directory`,
+	// with their content.
+	Files map[string]string `mapstructure:"files"`
+	// Generated data published, in the Simulator namespace, along with
+	// `Simulator_ID`, `Simulator_Host` and `Simulator_Prefix`.
+	GeneratedData map[string]string `mapstructure:"generated_data"`
+	// The names of the `generated_data` entries holding secrets, redacted
+	// from the output and the logs.
+	SensitiveGeneratedData []string `mapstructure:"sensitive_generated_data"`
+	// If set, the build fails with this error once the provisioners ran,
+	// without producing an artifact.
+	Error string `mapstructure:"error"`
+
+	ctx interpolate.Context
+}
+
+// Builder is a builder simulating a machine on the host, to run acceptance
+// tests of provisioners and post-processors without a hypervisor or a cloud.
+type Builder struct {
+	config   Config
+	manifest *packerbuilderdata.Manifest
+}
+
+var _ packersdk.Builder = new(Builder)
+
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	err := config.Decode(&b.config, &config.DecodeOpts{
+		PluginType:         BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &b.config.ctx,
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs *packersdk.MultiError
+	if b.config.Comm.Type == "" {
+		b.config.Comm.Type = localCommunicator
+	}
+	if b.config.Comm.Type != localCommunicator {
+		errs = packersdk.MultiErrorAppend(errs, b.config.Comm.Prepare(&b.config.ctx)...)
+	}
+	if b.config.Host == "" {
+		b.config.Host = "127.0.0.1"
+	}
+	if b.config.OutputDir == "" {
+		b.config.OutputDir = fmt.Sprintf("output-%s", b.config.PackerBuildName)
+	}
+	for name := range b.config.Files {
+		if filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("files: %q must be relative to output_directory", name))
+		}
+	}
+
+	sensitive := map[string]bool{}
+	for _, name := range b.config.SensitiveGeneratedData {
+		if _, ok := b.config.GeneratedData[name]; !ok {
+			errs = packersdk.MultiErrorAppend(errs,
+				fmt.Errorf("sensitive_generated_data: %q is not set in generated_data", name))
+		}
+		sensitive[name] = true
+	}
+	b.manifest = &packerbuilderdata.Manifest{}
+	if err := b.manifest.Add(KeyID, KeyHost, KeyPrefix); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+	for name := range b.config.GeneratedData {
+		key := generatedDataKey(name, sensitive[name])
+		if err := b.manifest.Add(key); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("generated_data: %s", err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, nil, errs
+	}
+	return b.manifest.Names(), nil, nil
+}
+
+func generatedDataKey(name string, sensitive bool) packerbuilderdata.Key {
+	return packerbuilderdata.Key{
+		Namespace: Namespace,
+		Name:      name,
+		Type:      packerbuilderdata.TypeString,
+		Sensitive: sensitive,
+	}
+}
+
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", &b.config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	steps := []multistep.Step{
+		&commonsteps.StepOutputDir{
+			Force: b.config.PackerForce,
+			Path:  b.config.OutputDir,
+		},
+		&stepCreateMachine{manifest: b.manifest},
+		&communicator.StepConnect{
+			Config:    &b.config.Comm,
+			Host:      communicator.CommHost(b.config.Comm.Host(), "instance_ip"),
+			SSHConfig: b.config.Comm.SSHConfigFunc(),
+			CustomConnect: map[string]multistep.Step{
+				localCommunicator: &stepConnectLocal{},
+			},
+		},
+		&commonsteps.StepProvision{},
+		&stepWriteFiles{},
+	}
+
+	runner := commonsteps.NewRunner(steps, b.config.PackerConfig, ui)
+	runner.Run(ctx, state)
+
+	if err, ok := state.GetOk("error"); ok {
+		return nil, err.(error)
+	}
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	generatedData, _ := state.Get("generated_data").(map[string]interface{})
+	return &Artifact{
+		id:            state.Get("instance_id").(string),
+		files:         state.Get("artifact_files").([]string),
+		generatedData: generatedData,
+	}, nil
+}
+
+// stepCreateMachine simulates the creation of the machine, and publishes its
+// generated data.
+type stepCreateMachine struct {
+	manifest *packerbuilderdata.Manifest
+}
+
+func (s *stepCreateMachine) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	prefix := os.Getenv(prefixEnvVar)
+	id := "simulator-" + uuid.TimeOrderedUUID()
+	if prefix != "" {
+		id = prefix + "-simulator"
+	}
+	ui.Say(fmt.Sprintf("Creating simulated machine %s...", id))
+	state.Put("instance_id", id)
+	state.Put("instance_ip", config.Host)
+
+	gd := &packerbuilderdata.GeneratedData{State: state, Manifest: s.manifest}
+	values := []struct {
+		key   packerbuilderdata.Key
+		value string
+	}{
+		{KeyID, id},
+		{KeyHost, config.Host},
+		{KeyPrefix, prefix},
+	}
+	sensitive := map[string]bool{}
+	for _, name := range config.SensitiveGeneratedData {
+		sensitive[name] = true
+	}
+	for _, name := range sortedKeys(config.GeneratedData) {
+		values = append(values, struct {
+			key   packerbuilderdata.Key
+			value string
+		}{generatedDataKey(name, sensitive[name]), config.GeneratedData[name]})
+	}
+	for _, v := range values {
+		if err := gd.Set(v.key, v.value); err != nil {
+			err := fmt.Errorf("Error publishing generated data: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateMachine) Cleanup(state multistep.StateBag) {
+	if id, ok := state.GetOk("instance_id"); ok {
+		ui := state.Get("ui").(packersdk.Ui)
+		ui.Say(fmt.Sprintf("Destroying simulated machine %s...", id))
+	}
+}
+
+// stepConnectLocal connects the local communicator.
+type stepConnectLocal struct{}
+
+func (s *stepConnectLocal) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	state.Put("communicator", local.New(&local.Config{}))
+	return multistep.ActionContinue
+}
+
+func (s *stepConnectLocal) Cleanup(state multistep.StateBag) {}
+
+// stepWriteFiles writes the files of the artifact, or fails the build with
+// the configured error.
+type stepWriteFiles struct{}
+
+func (s *stepWriteFiles) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if config.Error != "" {
+		err := errors.New(config.Error)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	files := make([]string, 0, len(config.Files))
+	for _, name := range sortedKeys(config.Files) {
+		path := filepath.Join(config.OutputDir, name)
+		ui.Message(fmt.Sprintf("Writing %s...", path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			err := fmt.Errorf("Error creating the directory of %s: %s", path, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if err := os.WriteFile(path, []byte(config.Files[name]), 0644); err != nil {
+			err := fmt.Errorf("Error writing %s: %s", path, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		files = append(files, path)
+	}
+	state.Put("artifact_files", files)
+	return multistep.ActionContinue
+}
+
+func (s *stepWriteFiles) Cleanup(state multistep.StateBag) {}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Artifact is the artifact of the simulator builder: the files written to
+// its output directory.
+type Artifact struct {
+	id            string
+	files         []string
+	generatedData map[string]interface{}
+}
+
+var _ packersdk.Artifact = new(Artifact)
+
+func (a *Artifact) BuilderId() string { return BuilderId }
+
+func (a *Artifact) Files() []string { return a.files }
+
+func (a *Artifact) Id() string { return a.id }
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Simulated machine %s with %d file(s)", a.id, len(a.files))
+}
+
+func (a *Artifact) State(name string) interface{} {
+	if name == "generated_data" {
+		return a.generatedData
+	}
+	return nil
+}
+
+func (a *Artifact) Destroy() error {
+	var errs *packersdk.MultiError
+	for _, f := range a.files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
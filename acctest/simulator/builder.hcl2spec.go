@@ -0,0 +1,202 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package simulator
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	PackerBuildName           *string                         `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType         *string                         `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion         *string                         `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug               *bool                           `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce               *bool                           `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError             *string                         `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars            map[string]string               `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars       []string                        `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Type                      *string                         `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect        *string                         `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	TransferBandwidthLimit    *string                         `mapstructure:"transfer_bandwidth_limit" cty:"transfer_bandwidth_limit" hcl:"transfer_bandwidth_limit"`
+	TransferProgress          *bool                           `mapstructure:"transfer_progress" cty:"transfer_progress" hcl:"transfer_progress"`
+	ConnectPolicy             *communicator.FlatConnectPolicy `mapstructure:"connect_policy" cty:"connect_policy" hcl:"connect_policy"`
+	SSHHost                   *string                         `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                   *int                            `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername               *string                         `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword               *string                         `mapstructure:"ssh_password" packer:"sensitive" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName            *string                         `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName   *string                         `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType   *string                         `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits   *int                            `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                []string                        `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys    *bool                           `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos               []string                        `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile         *string                         `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile        *string                         `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPrivateKeyPassphrase   *string                         `mapstructure:"ssh_private_key_passphrase" packer:"sensitive" cty:"ssh_private_key_passphrase" hcl:"ssh_private_key_passphrase"`
+	SSHPty                    *bool                           `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                *string                         `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout            *string                         `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth              *bool                           `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding *bool                           `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts      *int                            `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost            *string                         `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort            *int                            `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth       *bool                           `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername        *string                         `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword        *string                         `mapstructure:"ssh_bastion_password" packer:"sensitive" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive     *bool                           `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile  *string                         `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile *string                         `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHBastionHosts           []communicator.FlatSSHBastion   `mapstructure:"ssh_bastion_hosts" cty:"ssh_bastion_hosts" hcl:"ssh_bastion_hosts"`
+	SSHFileTransferMethod     *string                         `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHUploadDirTar           *bool                           `mapstructure:"ssh_upload_dir_tar" cty:"ssh_upload_dir_tar" hcl:"ssh_upload_dir_tar"`
+	SSHUploadDirInclude       []string                        `mapstructure:"ssh_upload_dir_include" cty:"ssh_upload_dir_include" hcl:"ssh_upload_dir_include"`
+	SSHUploadDirExclude       []string                        `mapstructure:"ssh_upload_dir_exclude" cty:"ssh_upload_dir_exclude" hcl:"ssh_upload_dir_exclude"`
+	SSHUploadDirSymlinks      *string                         `mapstructure:"ssh_upload_dir_symlinks" cty:"ssh_upload_dir_symlinks" hcl:"ssh_upload_dir_symlinks"`
+	SSHRemoteShell            *string                         `mapstructure:"ssh_remote_shell" cty:"ssh_remote_shell" hcl:"ssh_remote_shell"`
+	SSHProxyHost              *string                         `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort              *int                            `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername          *string                         `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword          *string                         `mapstructure:"ssh_proxy_password" packer:"sensitive" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval      *string                         `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHKeepAliveCountMax      *int                            `mapstructure:"ssh_keep_alive_count_max" cty:"ssh_keep_alive_count_max" hcl:"ssh_keep_alive_count_max"`
+	SSHHostKeyChecking        *string                         `mapstructure:"ssh_host_key_checking" cty:"ssh_host_key_checking" hcl:"ssh_host_key_checking"`
+	SSHKnownHostsFile         *string                         `mapstructure:"ssh_known_hosts_file" cty:"ssh_known_hosts_file" hcl:"ssh_known_hosts_file"`
+	SSHHostKeyFingerprints    []string                        `mapstructure:"ssh_host_key_fingerprints" cty:"ssh_host_key_fingerprints" hcl:"ssh_host_key_fingerprints"`
+	SSHReadWriteTimeout       *string                         `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels          []string                        `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels           []string                        `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHRemoteSOCKSProxy       *string                         `mapstructure:"ssh_remote_socks_proxy" cty:"ssh_remote_socks_proxy" hcl:"ssh_remote_socks_proxy"`
+	SSHPublicKey              []byte                          `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey             []byte                          `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                 *string                         `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword             *string                         `mapstructure:"winrm_password" packer:"sensitive" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                 *string                         `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy              *bool                           `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                 *int                            `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout              *string                         `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL               *bool                           `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure             *bool                           `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM              *bool                           `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	WinRMUseKerberos          *bool                           `mapstructure:"winrm_use_kerberos" cty:"winrm_use_kerberos" hcl:"winrm_use_kerberos"`
+	WinRMKerberosRealm        *string                         `mapstructure:"winrm_kerberos_realm" cty:"winrm_kerberos_realm" hcl:"winrm_kerberos_realm"`
+	WinRMKerberosKeytab       *string                         `mapstructure:"winrm_kerberos_keytab" cty:"winrm_kerberos_keytab" hcl:"winrm_kerberos_keytab"`
+	WinRMKerberosCCache       *string                         `mapstructure:"winrm_kerberos_ccache" cty:"winrm_kerberos_ccache" hcl:"winrm_kerberos_ccache"`
+	WinRMKerberosConfig       *string                         `mapstructure:"winrm_kerberos_config" cty:"winrm_kerberos_config" hcl:"winrm_kerberos_config"`
+	WinRMKerberosSPN          *string                         `mapstructure:"winrm_kerberos_spn" cty:"winrm_kerberos_spn" hcl:"winrm_kerberos_spn"`
+	WinRMUploadChunkSize      *int                            `mapstructure:"winrm_upload_chunk_size" cty:"winrm_upload_chunk_size" hcl:"winrm_upload_chunk_size"`
+	WinRMUploadParallelism    *int                            `mapstructure:"winrm_upload_parallelism" cty:"winrm_upload_parallelism" hcl:"winrm_upload_parallelism"`
+	Host                      *string                         `mapstructure:"host" cty:"host" hcl:"host"`
+	OutputDir                 *string                         `mapstructure:"output_directory" cty:"output_directory" hcl:"output_directory"`
+	Files                     map[string]string               `mapstructure:"files" cty:"files" hcl:"files"`
+	GeneratedData             map[string]string               `mapstructure:"generated_data" cty:"generated_data" hcl:"generated_data"`
+	SensitiveGeneratedData    []string                        `mapstructure:"sensitive_generated_data" cty:"sensitive_generated_data" hcl:"sensitive_generated_data"`
+	Error                     *string                         `mapstructure:"error" cty:"error" hcl:"error"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"packer_build_name":            &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":          &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":          &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":                 &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":                 &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":              &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":        &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables":   &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"transfer_bandwidth_limit":     &hcldec.AttrSpec{Name: "transfer_bandwidth_limit", Type: cty.String, Required: false},
+		"transfer_progress":            &hcldec.AttrSpec{Name: "transfer_progress", Type: cty.Bool, Required: false},
+		"connect_policy":               &hcldec.BlockSpec{TypeName: "connect_policy", Nested: hcldec.ObjectSpec((*communicator.FlatConnectPolicy)(nil).HCL2Spec())},
+		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                     &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                 &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                 &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keypair_name":             &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":      &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":      &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":      &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                  &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":    &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":  &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_private_key_file":         &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":         &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_private_key_passphrase":   &hcldec.AttrSpec{Name: "ssh_private_key_passphrase", Type: cty.String, Required: false},
+		"ssh_pty":                      &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_timeout":                  &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":             &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":               &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding": &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":       &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":             &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":             &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":         &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":         &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":      &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file": &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_bastion_hosts":            &hcldec.BlockListSpec{TypeName: "ssh_bastion_hosts", Nested: hcldec.ObjectSpec((*communicator.FlatSSHBastion)(nil).HCL2Spec())},
+		"ssh_file_transfer_method":     &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_upload_dir_tar":           &hcldec.AttrSpec{Name: "ssh_upload_dir_tar", Type: cty.Bool, Required: false},
+		"ssh_upload_dir_include":       &hcldec.AttrSpec{Name: "ssh_upload_dir_include", Type: cty.List(cty.String), Required: false},
+		"ssh_upload_dir_exclude":       &hcldec.AttrSpec{Name: "ssh_upload_dir_exclude", Type: cty.List(cty.String), Required: false},
+		"ssh_upload_dir_symlinks":      &hcldec.AttrSpec{Name: "ssh_upload_dir_symlinks", Type: cty.String, Required: false},
+		"ssh_remote_shell":             &hcldec.AttrSpec{Name: "ssh_remote_shell", Type: cty.String, Required: false},
+		"ssh_proxy_host":               &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_port":               &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":           &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":           &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":      &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_keep_alive_count_max":     &hcldec.AttrSpec{Name: "ssh_keep_alive_count_max", Type: cty.Number, Required: false},
+		"ssh_host_key_checking":        &hcldec.AttrSpec{Name: "ssh_host_key_checking", Type: cty.String, Required: false},
+		"ssh_known_hosts_file":         &hcldec.AttrSpec{Name: "ssh_known_hosts_file", Type: cty.String, Required: false},
+		"ssh_host_key_fingerprints":    &hcldec.AttrSpec{Name: "ssh_host_key_fingerprints", Type: cty.List(cty.String), Required: false},
+		"ssh_read_write_timeout":       &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":           &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":            &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_remote_socks_proxy":       &hcldec.AttrSpec{Name: "ssh_remote_socks_proxy", Type: cty.String, Required: false},
+		"ssh_public_key":               &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":              &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":               &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":               &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                   &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":               &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_port":                   &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"winrm_use_kerberos":           &hcldec.AttrSpec{Name: "winrm_use_kerberos", Type: cty.Bool, Required: false},
+		"winrm_kerberos_realm":         &hcldec.AttrSpec{Name: "winrm_kerberos_realm", Type: cty.String, Required: false},
+		"winrm_kerberos_keytab":        &hcldec.AttrSpec{Name: "winrm_kerberos_keytab", Type: cty.String, Required: false},
+		"winrm_kerberos_ccache":        &hcldec.AttrSpec{Name: "winrm_kerberos_ccache", Type: cty.String, Required: false},
+		"winrm_kerberos_config":        &hcldec.AttrSpec{Name: "winrm_kerberos_config", Type: cty.String, Required: false},
+		"winrm_kerberos_spn":           &hcldec.AttrSpec{Name: "winrm_kerberos_spn", Type: cty.String, Required: false},
+		"winrm_upload_chunk_size":      &hcldec.AttrSpec{Name: "winrm_upload_chunk_size", Type: cty.Number, Required: false},
+		"winrm_upload_parallelism":     &hcldec.AttrSpec{Name: "winrm_upload_parallelism", Type: cty.Number, Required: false},
+		"host":                         &hcldec.AttrSpec{Name: "host", Type: cty.String, Required: false},
+		"output_directory":             &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
+		"files":                        &hcldec.AttrSpec{Name: "files", Type: cty.Map(cty.String), Required: false},
+		"generated_data":               &hcldec.AttrSpec{Name: "generated_data", Type: cty.Map(cty.String), Required: false},
+		"sensitive_generated_data":     &hcldec.AttrSpec{Name: "sensitive_generated_data", Type: cty.List(cty.String), Required: false},
+		"error":                        &hcldec.AttrSpec{Name: "error", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,177 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestBuilderPrepare(t *testing.T) {
+	var b Builder
+	vars, warns, err := b.Prepare(map[string]interface{}{
+		"packer_build_name":        "basic",
+		"generated_data":           map[string]string{"Token": "s3cr3t"},
+		"sensitive_generated_data": []string{"Token"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	expected := []string{"Simulator_Host", "Simulator_ID", "Simulator_Prefix", "Simulator_Token"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("expected %v, got %v", expected, vars)
+	}
+	if b.config.Comm.Type != "local" {
+		t.Fatalf("bad communicator: %s", b.config.Comm.Type)
+	}
+	if b.config.OutputDir != "output-basic" {
+		t.Fatalf("bad output directory: %s", b.config.OutputDir)
+	}
+	if k, _ := b.manifest.Lookup("Simulator_Token"); !k.Sensitive {
+		t.Fatalf("Simulator_Token should be sensitive")
+	}
+}
+
+func TestBuilderPrepare_errors(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"absolute file":          {"files": map[string]string{"/etc/passwd": ""}},
+		"file outside output":    {"files": map[string]string{"../a": ""}},
+		"unset sensitive data":   {"sensitive_generated_data": []string{"Token"}},
+		"invalid communicator":   {"communicator": "carrier-pigeon"},
+		"ssh without a username": {"communicator": "ssh"},
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			var b Builder
+			if _, _, err := b.Prepare(raw); err == nil {
+				t.Fatalf("should error")
+			}
+		})
+	}
+}
+
+func TestBuilderRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the local communicator runs commands with /bin/sh")
+	}
+	t.Setenv(prefixEnvVar, "acc-basic")
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+
+	var b Builder
+	_, _, err := b.Prepare(map[string]interface{}{
+		"output_directory": output,
+		"files":            map[string]string{"disk.img": "disk", "meta/info.txt": "info"},
+		"generated_data":   map[string]string{"Region": "local-1"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var p Provisioner
+	err = p.Prepare(map[string]interface{}{
+		"inline": []string{
+			`echo "{{ .Simulator_ID }} $PACKER_BUILD_SIMULATOR_REGION" > ` + filepath.Join(dir, "inline.txt"),
+		},
+		"files":               map[string]string{filepath.Join(dir, "uploaded.txt"): "uploaded"},
+		"generated_data_file": filepath.Join(dir, "generated.json"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	hook := &packersdk.DispatchHook{Mapping: map[string][]packersdk.Hook{
+		packersdk.HookProvision: {&packersdk.ProvisionHook{
+			Provisioners: []*packersdk.HookedProvisioner{{Provisioner: &p, TypeName: "simulator"}},
+		}},
+	}}
+
+	artifact, err := b.Run(context.Background(), packersdk.TestUi(t), hook)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if artifact.Id() != "acc-basic-simulator" {
+		t.Fatalf("bad id: %s", artifact.Id())
+	}
+	expectedFiles := []string{filepath.Join(output, "disk.img"), filepath.Join(output, "meta", "info.txt")}
+	if !reflect.DeepEqual(artifact.Files(), expectedFiles) {
+		t.Fatalf("expected files %v, got %v", expectedFiles, artifact.Files())
+	}
+
+	assertFile(t, filepath.Join(dir, "inline.txt"), "acc-basic-simulator local-1\n")
+	assertFile(t, filepath.Join(dir, "uploaded.txt"), "uploaded")
+	assertFile(t, filepath.Join(output, "meta", "info.txt"), "info")
+
+	b2, err := os.ReadFile(filepath.Join(dir, "generated.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var generated map[string]interface{}
+	if err := json.Unmarshal(b2, &generated); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for k, v := range map[string]string{
+		"Simulator_ID":     "acc-basic-simulator",
+		"Simulator_Host":   "127.0.0.1",
+		"Simulator_Prefix": "acc-basic",
+		"Simulator_Region": "local-1",
+	} {
+		if generated[k] != v {
+			t.Fatalf("expected %s to be %q, got %v", k, v, generated[k])
+		}
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "disk.img")); !os.IsNotExist(err) {
+		t.Fatalf("the files of the artifact should be removed: %v", err)
+	}
+}
+
+func TestBuilderRun_error(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+	var b Builder
+	_, _, err := b.Prepare(map[string]interface{}{
+		"communicator":     "none",
+		"output_directory": output,
+		"files":            map[string]string{"disk.img": "disk"},
+		"error":            "simulated failure",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact, err := b.Run(context.Background(), packersdk.TestUi(t), &packersdk.MockHook{})
+	if err == nil || !strings.Contains(err.Error(), "simulated failure") {
+		t.Fatalf("expected the simulated failure, got %v", err)
+	}
+	if artifact != nil {
+		t.Fatalf("should not return an artifact")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("the output directory should be removed: %v", err)
+	}
+}
+
+func assertFile(t *testing.T, path, expected string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(b) != expected {
+		t.Fatalf("expected %s to contain %q, got %q", path, expected, b)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceConfig,DatasourceOutput
+
+package simulator
+
+import (
+	"errors"
+	"os"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DatasourceConfig is the configuration of the simulator datasource.
+type DatasourceConfig struct {
+	// The values returned in the `outputs` attribute.
+	Outputs map[string]string `mapstructure:"outputs"`
+	// If set, the datasource fails with this error.
+	Error string `mapstructure:"error"`
+}
+
+// DatasourceOutput is the value returned by the simulator datasource.
+type DatasourceOutput struct {
+	// The prefix of the test case running the build, empty outside of
+	// acceptance tests.
+	Prefix string `mapstructure:"prefix"`
+	// The configured outputs.
+	Outputs map[string]string `mapstructure:"outputs"`
+}
+
+// Datasource is a datasource returning its configured outputs, to run
+// acceptance tests of the components using the values of datasources.
+type Datasource struct {
+	config DatasourceConfig
+}
+
+var _ packersdk.Datasource = new(Datasource)
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec { return d.config.FlatMapstructure().HCL2Spec() }
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	return config.Decode(&d.config, nil, raws...)
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	if d.config.Error != "" {
+		return cty.NullVal(hcldec.ImpliedType(d.OutputSpec())), errors.New(d.config.Error)
+	}
+	output := DatasourceOutput{
+		Prefix:  os.Getenv(prefixEnvVar),
+		Outputs: d.config.Outputs,
+	}
+	if output.Outputs == nil {
+		output.Outputs = map[string]string{}
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
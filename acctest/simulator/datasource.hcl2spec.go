@@ -0,0 +1,58 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package simulator
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatDatasourceConfig is an auto-generated flat version of DatasourceConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceConfig struct {
+	Outputs map[string]string `mapstructure:"outputs" cty:"outputs" hcl:"outputs"`
+	Error   *string           `mapstructure:"error" cty:"error" hcl:"error"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceConfig.
+// FlatDatasourceConfig is an auto-generated flat version of DatasourceConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceConfig)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceConfig.
+// This spec is used by HCL to read the fields of DatasourceConfig.
+// The decoded values from this spec will then be applied to a FlatDatasourceConfig.
+func (*FlatDatasourceConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"outputs": &hcldec.AttrSpec{Name: "outputs", Type: cty.Map(cty.String), Required: false},
+		"error":   &hcldec.AttrSpec{Name: "error", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	Prefix  *string           `mapstructure:"prefix" cty:"prefix" hcl:"prefix"`
+	Outputs map[string]string `mapstructure:"outputs" cty:"outputs" hcl:"outputs"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"prefix":  &hcldec.AttrSpec{Name: "prefix", Type: cty.String, Required: false},
+		"outputs": &hcldec.AttrSpec{Name: "outputs", Type: cty.Map(cty.String), Required: false},
+	}
+	return s
+}
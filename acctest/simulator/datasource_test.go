@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package simulator
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDatasource(t *testing.T) {
+	t.Setenv(prefixEnvVar, "acc-basic")
+	var d Datasource
+	err := d.Configure(map[string]interface{}{
+		"outputs": map[string]string{"image": "ubuntu"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	value := hcl2helper.TestDatasourceOutput(t, &d)
+	if prefix := value.GetAttr("prefix").AsString(); prefix != "acc-basic" {
+		t.Fatalf("bad prefix: %s", prefix)
+	}
+	if image := value.GetAttr("outputs").Index(cty.StringVal("image")).AsString(); image != "ubuntu" {
+		t.Fatalf("bad image: %s", image)
+	}
+}
+
+func TestDatasource_error(t *testing.T) {
+	var d Datasource
+	if err := d.Configure(map[string]interface{}{"error": "simulated failure"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := d.Execute(); err == nil || err.Error() != "simulated failure" {
+		t.Fatalf("expected the simulated failure, got %v", err)
+	}
+}
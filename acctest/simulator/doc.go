@@ -0,0 +1,16 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package simulator provides a builder, a provisioner and a datasource that run
+on the host running Packer, so that the acceptance tests of the other
+components of a plugin run without a hypervisor or a cloud.
+
+The Builder connects its communicator, the local one by default, runs the
+provisioners, and writes the files of its artifact. The Provisioner runs
+commands and uploads files through the communicator, and can upload the
+generated data of the build for the checks of a test case. The Datasource
+returns its configured outputs. All of them can be configured to fail, to
+test the handling of errors.
+*/
+package simulator
@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type ProvisionerConfig
+
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// ProvisionerConfig is the configuration of the simulator provisioner.
+type ProvisionerConfig struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// Commands run in order through the communicator. The generated data of
+	// the build is available in `{{ .Name }}` templates, and in environment
+	// variables, like `PACKER_BUILD_SIMULATOR_ID` for `Simulator_ID`.
+	Inline []string `mapstructure:"inline"`
+	// Files uploaded through the communicator, by destination path, with
+	// their content.
+	Files map[string]string `mapstructure:"files"`
+	// If set, the generated data of the build is uploaded to this path as a
+	// JSON object, for the checks of the test case.
+	GeneratedDataFile string `mapstructure:"generated_data_file"`
+	// If set, the provisioner fails with this error once the commands ran
+	// and the files were uploaded.
+	Error string `mapstructure:"error"`
+
+	ctx interpolate.Context
+}
+
+// Provisioner is a provisioner exercising the communicator and the generated
+// data of a build, to run acceptance tests of builders without writing
+// scripts.
+type Provisioner struct {
+	config ProvisionerConfig
+}
+
+var _ packersdk.Provisioner = new(Provisioner)
+
+func (p *Provisioner) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	return config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "simulator",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"inline",
+			},
+		},
+	}, raws...)
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, generatedData map[string]interface{}) error {
+	p.config.ctx.Data = generatedData
+	env := generatedDataEnv(generatedData)
+
+	for _, command := range p.config.Inline {
+		command, err := interpolate.Render(command, &p.config.ctx)
+		if err != nil {
+			return fmt.Errorf("Error rendering command %q: %s", command, err)
+		}
+		ui.Say(fmt.Sprintf("Running: %s", command))
+		cmd := &packersdk.RemoteCmd{Command: command, Env: env}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+			return fmt.Errorf("Error running %q: %s", command, err)
+		}
+		if status := cmd.ExitStatus(); status != 0 {
+			return fmt.Errorf("Command %q exited with a non-zero exit status: %d", command, status)
+		}
+	}
+
+	dsts := make([]string, 0, len(p.config.Files))
+	for dst := range p.config.Files {
+		dsts = append(dsts, dst)
+	}
+	sort.Strings(dsts)
+	for _, dst := range dsts {
+		ui.Say(fmt.Sprintf("Uploading %s...", dst))
+		if err := comm.Upload(dst, strings.NewReader(p.config.Files[dst]), nil); err != nil {
+			return fmt.Errorf("Error uploading %s: %s", dst, err)
+		}
+	}
+
+	if p.config.GeneratedDataFile != "" {
+		b, err := json.MarshalIndent(generatedData, "", "  ")
+		if err != nil {
+			return err
+		}
+		ui.Say(fmt.Sprintf("Uploading the generated data to %s...", p.config.GeneratedDataFile))
+		if err := comm.Upload(p.config.GeneratedDataFile, bytes.NewReader(b), nil); err != nil {
+			return fmt.Errorf("Error uploading %s: %s", p.config.GeneratedDataFile, err)
+		}
+	}
+
+	if p.config.Error != "" {
+		return errors.New(p.config.Error)
+	}
+	return nil
+}
+
+// generatedDataEnv returns the environment variables of the string entries
+// of generatedData, sorted.
+func generatedDataEnv(generatedData map[string]interface{}) []string {
+	var env []string
+	for name, v := range generatedData {
+		if s, ok := v.(string); ok {
+			env = append(env, fmt.Sprintf("PACKER_BUILD_%s=%s", strings.ToUpper(name), s))
+		}
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,53 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package simulator
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatProvisionerConfig is an auto-generated flat version of ProvisionerConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatProvisionerConfig struct {
+	PackerBuildName     *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType   *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion   *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug         *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce         *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError       *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars      map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Inline              []string          `mapstructure:"inline" cty:"inline" hcl:"inline"`
+	Files               map[string]string `mapstructure:"files" cty:"files" hcl:"files"`
+	GeneratedDataFile   *string           `mapstructure:"generated_data_file" cty:"generated_data_file" hcl:"generated_data_file"`
+	Error               *string           `mapstructure:"error" cty:"error" hcl:"error"`
+}
+
+// FlatMapstructure returns a new FlatProvisionerConfig.
+// FlatProvisionerConfig is an auto-generated flat version of ProvisionerConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*ProvisionerConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatProvisionerConfig)
+}
+
+// HCL2Spec returns the hcl spec of a ProvisionerConfig.
+// This spec is used by HCL to read the fields of ProvisionerConfig.
+// The decoded values from this spec will then be applied to a FlatProvisionerConfig.
+func (*FlatProvisionerConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":        &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":               &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":               &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":            &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":      &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables": &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"inline":                     &hcldec.AttrSpec{Name: "inline", Type: cty.List(cty.String), Required: false},
+		"files":                      &hcldec.AttrSpec{Name: "files", Type: cty.Map(cty.String), Required: false},
+		"generated_data_file":        &hcldec.AttrSpec{Name: "generated_data_file", Type: cty.String, Required: false},
+		"error":                      &hcldec.AttrSpec{Name: "error", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,247 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	// PackerVersionsEnvVar is the environment variable listing, separated by
+	// commas, the versions of packer to run the test cases with, like
+	// "1.9.4,1.10.0,local". "local" is the packer found in the PATH.
+	PackerVersionsEnvVar = "PACKER_ACC_PACKER_VERSIONS"
+	// ProtocolVersionsEnvVar is the environment variable listing, separated
+	// by commas, the protocol versions to run the test cases with: "v1" for
+	// gob only and "v2" for protobuf.
+	ProtocolVersionsEnvVar = "PACKER_ACC_PROTOCOL_VERSIONS"
+	// CacheDirEnvVar is the environment variable holding the directory the
+	// versions of packer are downloaded to. It defaults to a packer-acc
+	// directory in the cache directory of the user.
+	CacheDirEnvVar = "PACKER_ACC_CACHE_DIR"
+
+	// LocalPackerVersion is the version of the packer found in the PATH.
+	LocalPackerVersion = "local"
+
+	// protocolVersionEnvVar is plugin.ProtocolVersionEnvVar, forcing the
+	// protocol of the plugins.
+	protocolVersionEnvVar = "PACKER_PLUGIN_PROTOCOL_VERSION"
+)
+
+// ReleasesURL is the URL packer is downloaded from.
+var ReleasesURL = "https://releases.hashicorp.com/packer"
+
+// target is a version of packer and a protocol version to run a test case
+// with. The zero target is the local packer with the default protocol.
+type target struct {
+	packerVersion   string
+	protocolVersion string
+}
+
+func (t target) String() string {
+	v := t.packerVersion
+	if v == "" {
+		v = LocalPackerVersion
+	}
+	if t.protocolVersion == "" {
+		return "packer-" + v
+	}
+	return fmt.Sprintf("packer-%s/protocol-%s", v, t.protocolVersion)
+}
+
+// targets returns the targets to run testCase with.
+func (testCase *PluginTestCase) targets() []target {
+	versions := testCase.PackerVersions
+	if len(versions) == 0 {
+		versions = splitList(os.Getenv(PackerVersionsEnvVar))
+	}
+	protocols := testCase.ProtocolVersions
+	if len(protocols) == 0 {
+		protocols = splitList(os.Getenv(ProtocolVersionsEnvVar))
+	}
+	if len(versions) == 0 {
+		versions = []string{""}
+	}
+	if len(protocols) == 0 {
+		protocols = []string{""}
+	}
+
+	var targets []target
+	for _, v := range versions {
+		if v == LocalPackerVersion {
+			v = ""
+		}
+		for _, p := range protocols {
+			targets = append(targets, target{packerVersion: strings.TrimPrefix(v, "v"), protocolVersion: p})
+		}
+	}
+	return targets
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// runTargets runs testCase with each of its targets, as subtests of t named
+// after the targets, and logs the result for each target. A test case
+// without targets runs with the local packer.
+func runTargets(t *testing.T, testCase *PluginTestCase, others []*PluginTestCase) {
+	targets := testCase.targets()
+	if len(targets) == 1 && targets[0] == (target{}) {
+		testPlugin(t, testCase, others, target{})
+		return
+	}
+
+	results := make([]string, 0, len(targets))
+	for _, tg := range targets {
+		tg := tg
+		result := "FAIL"
+		if t.Run(tg.String(), func(t *testing.T) {
+			testPlugin(t, testCase, others, tg)
+		}) {
+			result = "PASS"
+		}
+		results = append(results, fmt.Sprintf("%s: %s", tg, result))
+	}
+	t.Logf("results of %s:\n\t%s", testCase.Name, strings.Join(results, "\n\t"))
+}
+
+// packerBinary returns the path of the packer binary of tg.
+func (tg target) packerBinary() (string, error) {
+	if tg.packerVersion == "" {
+		packerbin, err := exec.LookPath("packer")
+		if err != nil {
+			return "", fmt.Errorf("Couldn't find packer binary installed on system: %s", err.Error())
+		}
+		return packerbin, nil
+	}
+	return PackerBinary(tg.packerVersion)
+}
+
+var downloadLocks sync.Map
+
+// PackerBinary returns the path of the packer binary of version, downloading
+// it from ReleasesURL, and checking its checksum, if not already in the
+// cache directory.
+func PackerBinary(version string) (string, error) {
+	dir := os.Getenv(CacheDirEnvVar)
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cache, "packer-acc")
+	}
+	dir = filepath.Join(dir, version, runtime.GOOS+"_"+runtime.GOARCH)
+	name := "packer"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	bin := filepath.Join(dir, name)
+
+	l, _ := downloadLocks.LoadOrStore(bin, new(sync.Mutex))
+	l.(*sync.Mutex).Lock()
+	defer l.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(bin); err == nil {
+		return bin, nil
+	}
+	if err := downloadPacker(version, dir, name); err != nil {
+		return "", fmt.Errorf("failed to download packer %s: %s", version, err)
+	}
+	return bin, nil
+}
+
+func downloadPacker(version, dir, name string) error {
+	zipName := fmt.Sprintf("packer_%s_%s_%s.zip", version, runtime.GOOS, runtime.GOARCH)
+	base := fmt.Sprintf("%s/%s/", strings.TrimSuffix(ReleasesURL, "/"), version)
+
+	sums, err := httpGet(base + fmt.Sprintf("packer_%s_SHA256SUMS", version))
+	if err != nil {
+		return err
+	}
+	var want string
+	s := bufio.NewScanner(strings.NewReader(string(sums)))
+	for s.Scan() {
+		if fields := strings.Fields(s.Text()); len(fields) == 2 && fields[1] == zipName {
+			want = fields[0]
+		}
+	}
+	if want == "" {
+		return fmt.Errorf("no checksum for %s", zipName)
+	}
+
+	archive, err := httpGet(base + zipName)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("bad checksum for %s: expected %s, got %s", zipName, want, got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		tmp := filepath.Join(dir, name+".tmp")
+		out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, rc); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp, filepath.Join(dir, name))
+	}
+	return fmt.Errorf("no %s in %s", name, zipName)
+}
+
+func httpGet(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package acctest
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPluginTestCase_targets(t *testing.T) {
+	t.Setenv(PackerVersionsEnvVar, "")
+	t.Setenv(ProtocolVersionsEnvVar, "")
+	if got := new(PluginTestCase).targets(); !reflect.DeepEqual(got, []target{{}}) {
+		t.Fatalf("bad default targets: %#v", got)
+	}
+
+	t.Setenv(PackerVersionsEnvVar, "1.9.4, v1.10.0,local")
+	t.Setenv(ProtocolVersionsEnvVar, "v1,v2")
+	testCase := &PluginTestCase{ProtocolVersions: []string{"v2"}}
+	expected := []target{
+		{packerVersion: "1.9.4", protocolVersion: "v2"},
+		{packerVersion: "1.10.0", protocolVersion: "v2"},
+		{packerVersion: "", protocolVersion: "v2"},
+	}
+	got := testCase.targets()
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("bad targets: %#v", got)
+	}
+	if got[2].String() != "packer-local/protocol-v2" {
+		t.Fatalf("bad target name: %s", got[2])
+	}
+}
+
+func TestPackerBinary(t *testing.T) {
+	name := "packer"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create(name)
+	w.Write([]byte("#!/bin/sh\necho 1.10.0\n"))
+	zw.Close()
+	sum := sha256.Sum256(archive.Bytes())
+	zipName := fmt.Sprintf("packer_1.10.0_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/packer/1.10.0/packer_1.10.0_SHA256SUMS":
+			fmt.Fprintf(w, "%s  %s\n", hex.EncodeToString(sum[:]), zipName)
+		case "/packer/1.10.0/" + zipName:
+			downloads++
+			w.Write(archive.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	defer func(url string) { ReleasesURL = url }(ReleasesURL)
+	ReleasesURL = server.URL + "/packer"
+	t.Setenv(CacheDirEnvVar, t.TempDir())
+
+	for i := 0; i < 2; i++ {
+		bin, err := PackerBinary("1.10.0")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		b, err := os.ReadFile(bin)
+		if err != nil || !bytes.Contains(b, []byte("echo 1.10.0")) {
+			t.Fatalf("bad binary: %s, %v", b, err)
+		}
+	}
+	if downloads != 1 {
+		t.Fatalf("packer should be downloaded once, got %d", downloads)
+	}
+
+	if _, err := PackerBinary("1.11.0"); err == nil {
+		t.Fatal("expected an error for a missing version")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"log"
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/google/shlex"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -68,6 +69,7 @@ func (c *Adapter) Serve() {
 
 func (c *Adapter) Handle(conn net.Conn, ui packersdk.Ui) error {
 	log.Print("SSH proxy: accepted connection")
+	defer conn.Close()
 	_, chans, reqs, err := ssh.NewServerConn(conn, c.config)
 	if err != nil {
 		return errors.New("failed to handshake")
@@ -76,15 +78,22 @@ func (c *Adapter) Handle(conn net.Conn, ui packersdk.Ui) error {
 	// discard all global requests
 	go ssh.DiscardRequests(reqs)
 
-	// Service the incoming NewChannels
+	// Service the incoming NewChannels, each session in its own goroutine so
+	// that clients can multiplex them over a connection.
 	for newChannel := range chans {
-		if newChannel.ChannelType() != "session" {
+		var handle func(ssh.NewChannel) error
+		switch newChannel.ChannelType() {
+		case "session":
+			handle = c.handleSession
+		case "direct-tcpip":
+			handle = c.handleForward
+		default:
 			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
 			continue
 		}
 
 		go func(ch ssh.NewChannel) {
-			if err := c.handleSession(ch); err != nil {
+			if err := handle(ch); err != nil {
 				c.ui.Error(err.Error())
 			}
 		}(newChannel)
@@ -101,6 +110,8 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 	defer channel.Close()
 
 	done := make(chan struct{})
+	var once sync.Once
+	finish := func() { once.Do(func() { close(done) }) }
 
 	// Sessions have requests such as "pty-req", "shell", "env", and "exec".
 	// see RFC 4254, section 6
@@ -126,7 +137,7 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 				if err != nil {
 					c.ui.Error(err.Error())
 					req.Reply(false, nil)
-					close(done)
+					finish()
 					continue
 				}
 
@@ -134,7 +145,7 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 
 				if len(req.Payload) == 0 {
 					req.Reply(false, nil)
-					close(done)
+					finish()
 					return
 				}
 
@@ -144,7 +155,7 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 					exitStatus := make([]byte, 4)
 					binary.BigEndian.PutUint32(exitStatus, uint32(exit))
 					channel.SendRequest("exit-status", false, exitStatus)
-					close(done)
+					finish()
 				}(channel)
 				req.Reply(true, nil)
 			case "subsystem":
@@ -165,8 +176,14 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 
 					log.Print("starting sftp subsystem")
 					go func() {
-						_ = c.remoteExec(sftpCmd, channel, channel, channel.Stderr())
-						close(done)
+						if sftpCmd == EmulatedSFTP {
+							if err := serveSFTP(channel, c.comm); err != nil {
+								c.ui.Error(fmt.Sprintf("sftp subsystem failed: %s", err))
+							}
+						} else {
+							_ = c.remoteExec(sftpCmd, channel, channel, channel.Stderr())
+						}
+						finish()
 					}()
 					req.Reply(true, nil)
 				default:
@@ -184,6 +201,38 @@ func (c *Adapter) handleSession(newChannel ssh.NewChannel) error {
 	return nil
 }
 
+// forwardPayload is the payload of direct-tcpip channels, see RFC 4254,
+// section 7.2.
+type forwardPayload struct {
+	Host       string
+	Port       uint32
+	OriginHost string
+	OriginPort uint32
+}
+
+// handleForward forwards the data of a direct-tcpip channel to the address
+// it requests, connected to from the remote with nc.
+func (c *Adapter) handleForward(newChannel ssh.NewChannel) error {
+	var p forwardPayload
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &p); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid port forward request")
+		return err
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+
+	log.Printf("SSH proxy: forwarding to %s:%d", p.Host, p.Port)
+	command := fmt.Sprintf("nc %s %d", shellQuote(p.Host), p.Port)
+	if exit := c.remoteExec(command, channel, channel, channel.Stderr()); exit != 0 {
+		log.Printf("SSH proxy: forwarding to %s:%d exited with %d", p.Host, p.Port, exit)
+	}
+	return nil
+}
+
 func (c *Adapter) Shutdown() {
 	c.l.Close()
 }
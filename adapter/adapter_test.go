@@ -4,16 +4,22 @@
 package adapter
 
 import (
+	"bufio"
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"io"
 	"log"
 	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -118,3 +124,99 @@ func (c communicator) Download(string, io.Writer) error {
 func (c communicator) DownloadDir(src string, dst string, exclude []string) error {
 	return errors.New("communicator not supported")
 }
+
+// testClient serves an adapter running the commands on the host, and returns
+// an SSH client connected to it.
+func testClient(t *testing.T, sftpCmd string) *ssh.Client {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	done := make(chan struct{})
+	a := NewAdapter(done, l, config, sftpCmd, packersdk.TestUi(t), local.New(&local.Config{}))
+	go a.Serve()
+	t.Cleanup(func() {
+		close(done)
+		a.Shutdown()
+	})
+
+	client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "packer",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestAdapter_concurrentSessions(t *testing.T) {
+	client := testClient(t, "")
+
+	// The sessions wait for each other, so they only end when run at the
+	// same time.
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	if err := exec.Command("mkfifo", fifo).Run(); err != nil {
+		t.Skipf("mkfifo is not available: %s", err)
+	}
+	var wg sync.WaitGroup
+	outputs := make([]string, 2)
+	for i, command := range []string{"echo ping > " + fifo + "; echo sent", "cat " + fifo} {
+		wg.Add(1)
+		go func(i int, command string) {
+			defer wg.Done()
+			session, err := client.NewSession()
+			if err != nil {
+				t.Errorf("err: %s", err)
+				return
+			}
+			defer session.Close()
+			out, err := session.Output(command)
+			if err != nil {
+				t.Errorf("%s: %s", command, err)
+			}
+			outputs[i] = string(out)
+		}(i, command)
+	}
+	wg.Wait()
+	if outputs[0] != "sent\n" || outputs[1] != "ping\n" {
+		t.Fatalf("unexpected outputs %q", outputs)
+	}
+}
+
+func TestAdapter_portForward(t *testing.T) {
+	// nc is replaced by an echo server saying where it connects to.
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "nc"), []byte("#!/bin/sh\necho \"$1:$2\"\nexec cat\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	client := testClient(t, "")
+	conn, err := client.Dial("tcp", "10.0.0.1:8080")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r := bufio.NewReader(conn)
+	for _, expected := range []string{"10.0.0.1:8080\n", "hello\n"} {
+		if line, err := r.ReadString('\n'); err != nil || line != expected {
+			t.Fatalf("expected %q, got %q: %v", expected, line, err)
+		}
+	}
+}
@@ -11,6 +11,21 @@ use this package to proxy communicator calls.
 
 You may want to use this adapter if you are writing a provisioner that wraps a
 tool which under normal usage would be run locally and form a connection to the
-remote instance itself.
+remote instance itself. NewSSHServer exposes the communicator as a local SSH
+endpoint, with its host key, a client key and an idle timeout:
+
+	server, err := adapter.NewSSHServer(comm, adapter.SSHServerOptions{
+		IdleTimeout: 5 * time.Minute,
+	})
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	// Write server.ClientKey() and server.KnownHosts() to files, then run
+	// the tool against server.Addr().
+
+The adapter serves concurrent sessions, the SFTP subsystem, either with an SFTP
+server run on the remote or emulated with the communicator (see EmulatedSFTP),
+and port forwarding, connected to from the remote with nc.
 */
 package adapter
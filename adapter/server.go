@@ -0,0 +1,252 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHServerOptions configures an SSH server created with NewSSHServer.
+type SSHServerOptions struct {
+	// Address is the local address the server listens on. Defaults to
+	// "127.0.0.1:0", a port chosen by the system.
+	Address string
+	// HostKey is the PEM encoded private key the server authenticates with.
+	// A temporary ed25519 key is generated when it is not set.
+	HostKey []byte
+	// AuthorizedKeys are the public keys of the clients allowed to connect,
+	// in the authorized_keys format. When none are set, a temporary client
+	// key is generated, see SSHServer.ClientKey.
+	AuthorizedKeys [][]byte
+	// SFTPCommand is the command serving the SFTP subsystem on the remote,
+	// or EmulatedSFTP. Defaults to EmulatedSFTP, which works whatever the
+	// remote is.
+	SFTPCommand string
+	// IdleTimeout shuts the server down once no client has been connected to
+	// it for that long. By default, the server runs until it is closed.
+	IdleTimeout time.Duration
+	// Ui receives the errors of the server. By default, they are only
+	// logged.
+	Ui packersdk.Ui
+}
+
+// SSHServer is a local SSH server running the commands and transferring the
+// files of its clients with a communicator, for provisioners wrapping tools
+// that connect to the machine with SSH themselves, like salt-ssh or fabric.
+type SSHServer struct {
+	adapter   *Adapter
+	listener  *idleListener
+	hostKey   ssh.Signer
+	clientKey []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSSHServer starts an SSH server for comm. Close it once done.
+func NewSSHServer(comm packersdk.Communicator, opts SSHServerOptions) (*SSHServer, error) {
+	s := &SSHServer{done: make(chan struct{})}
+
+	hostKey := opts.HostKey
+	if hostKey == nil {
+		pair, err := sshkey.GeneratePair(sshkey.ED25519, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating the host key: %s", err)
+		}
+		hostKey = pair.Private
+	}
+	var err error
+	if s.hostKey, err = ssh.ParsePrivateKey(hostKey); err != nil {
+		return nil, fmt.Errorf("Error parsing the host key: %s", err)
+	}
+
+	authorized := opts.AuthorizedKeys
+	if len(authorized) == 0 {
+		pair, err := sshkey.GeneratePair(sshkey.ED25519, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating the client key: %s", err)
+		}
+		s.clientKey = pair.Private
+		authorized = [][]byte{pair.Public}
+	}
+	var keys [][]byte
+	for _, k := range authorized {
+		pk, _, _, _, err := ssh.ParseAuthorizedKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing the authorized key %q: %s", k, err)
+		}
+		keys = append(keys, pk.Marshal())
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range keys {
+				if bytes.Equal(k, key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown public key for %q", conn.User())
+		},
+	}
+	config.AddHostKey(s.hostKey)
+
+	address := opts.Address
+	if address == "" {
+		address = "127.0.0.1:0"
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s.listener = newIdleListener(l, opts.IdleTimeout, s.Close)
+
+	sftpCmd := opts.SFTPCommand
+	if sftpCmd == "" {
+		sftpCmd = EmulatedSFTP
+	}
+	ui := opts.Ui
+	if ui == nil {
+		ui = &packersdk.BasicUi{
+			Reader:      strings.NewReader(""),
+			Writer:      io.Discard,
+			ErrorWriter: io.Discard,
+		}
+	}
+	s.adapter = NewAdapter(s.done, s.listener, config, sftpCmd, ui, comm)
+	go s.adapter.Serve()
+	return s, nil
+}
+
+// Addr returns the address clients connect to.
+func (s *SSHServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// HostKey returns the public key the server authenticates with.
+func (s *SSHServer) HostKey() ssh.PublicKey {
+	return s.hostKey.PublicKey()
+}
+
+// KnownHosts returns the known_hosts line of the server, for clients
+// checking host keys.
+func (s *SSHServer) KnownHosts() string {
+	return knownhosts.Line([]string{knownhosts.Normalize(s.Addr().String())}, s.HostKey())
+}
+
+// ClientKey returns the PEM encoded private key clients authenticate with,
+// when it was generated.
+func (s *SSHServer) ClientKey() []byte {
+	return s.clientKey
+}
+
+// Done is closed once the server is closed, including after IdleTimeout.
+func (s *SSHServer) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the server. The connected clients are disconnected.
+func (s *SSHServer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.listener.Close()
+		s.listener.closeConns()
+		if s.listener.timer != nil {
+			s.listener.timer.Stop()
+		}
+	})
+	return err
+}
+
+// idleListener is a listener calling onIdle when no connection it accepted
+// has been open for timeout.
+type idleListener struct {
+	net.Listener
+	timeout time.Duration
+	onIdle  func() error
+
+	l     sync.Mutex
+	conns map[*idleConn]struct{}
+	timer *time.Timer
+}
+
+func newIdleListener(l net.Listener, timeout time.Duration, onIdle func() error) *idleListener {
+	il := &idleListener{
+		Listener: l,
+		timeout:  timeout,
+		onIdle:   onIdle,
+		conns:    map[*idleConn]struct{}{},
+	}
+	if timeout > 0 {
+		il.timer = time.AfterFunc(timeout, il.idle)
+	}
+	return il
+}
+
+func (il *idleListener) idle() {
+	il.l.Lock()
+	idle := len(il.conns) == 0
+	il.l.Unlock()
+	if idle {
+		il.onIdle()
+	}
+}
+
+func (il *idleListener) Accept() (net.Conn, error) {
+	conn, err := il.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := &idleConn{Conn: conn, l: il}
+	il.l.Lock()
+	il.conns[c] = struct{}{}
+	if il.timer != nil {
+		il.timer.Stop()
+	}
+	il.l.Unlock()
+	return c, nil
+}
+
+func (il *idleListener) release(c *idleConn) {
+	il.l.Lock()
+	defer il.l.Unlock()
+	if _, ok := il.conns[c]; !ok {
+		return
+	}
+	delete(il.conns, c)
+	if len(il.conns) == 0 && il.timer != nil {
+		il.timer.Reset(il.timeout)
+	}
+}
+
+func (il *idleListener) closeConns() {
+	il.l.Lock()
+	conns := make([]*idleConn, 0, len(il.conns))
+	for c := range il.conns {
+		conns = append(conns, c)
+	}
+	il.l.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+type idleConn struct {
+	net.Conn
+	l *idleListener
+}
+
+func (c *idleConn) Close() error {
+	c.l.release(c)
+	return c.Conn.Close()
+}
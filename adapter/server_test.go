@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestNewSSHServer(t *testing.T) {
+	s, err := NewSSHServer(local.New(&local.Config{}), SSHServerOptions{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer s.Close()
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte(s.KnownHosts()+"\n"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHosts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := ssh.ParsePrivateKey(s.ClientKey())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client, err := ssh.Dial("tcp", s.Addr().String(), &ssh.ClientConfig{
+		User:            "packer",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer session.Close()
+	if out, err := session.Output("echo hi"); err != nil || string(out) != "hi\n" {
+		t.Fatalf("unexpected output %q: %v", out, err)
+	}
+
+	// Other keys are rejected.
+	pair, err := sshkey.GeneratePair(sshkey.ED25519, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	other, err := ssh.ParsePrivateKey(pair.Private)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := ssh.Dial("tcp", s.Addr().String(), &ssh.ClientConfig{
+		User:            "packer",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(other)},
+		HostKeyCallback: hostKeyCallback,
+	}); err == nil {
+		t.Fatal("an unknown client key should be rejected")
+	}
+}
+
+func TestSSHServer_idleTimeout(t *testing.T) {
+	pair, err := sshkey.GeneratePair(sshkey.ED25519, nil, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s, err := NewSSHServer(local.New(&local.Config{}), SSHServerOptions{
+		AuthorizedKeys: [][]byte{pair.Public},
+		IdleTimeout:    200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer s.Close()
+	if s.ClientKey() != nil {
+		t.Fatal("no client key should be generated with authorized keys")
+	}
+
+	signer, _ := ssh.ParsePrivateKey(pair.Private)
+	client, err := ssh.Dial("tcp", s.Addr().String(), &ssh.ClientConfig{
+		User:            "packer",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.FixedHostKey(s.HostKey()),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// The server keeps running while a client is connected.
+	select {
+	case <-s.Done():
+		t.Fatal("the server stopped with a client connected")
+	case <-time.After(400 * time.Millisecond):
+	}
+
+	client.Close()
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle server didn't stop")
+	}
+}
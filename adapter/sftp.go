@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+	"github.com/pkg/sftp"
+)
+
+// EmulatedSFTP is the sftp command serving the SFTP subsystem with the
+// communicator, like the internal-sftp of OpenSSH, rather than with an SFTP
+// server run on the remote. Files are transferred with Upload and Download,
+// and the other operations run POSIX commands like stat, mkdir or mv.
+const EmulatedSFTP = "internal-sftp"
+
+// serveSFTP serves the SFTP subsystem on channel until the client closes it.
+func serveSFTP(channel io.ReadWriteCloser, comm packersdk.Communicator) error {
+	h := &sftpHandler{comm: comm}
+	server := sftp.NewRequestServer(channel, sftp.Handlers{
+		FileGet:  h,
+		FilePut:  h,
+		FileCmd:  h,
+		FileList: h,
+	})
+	err := server.Serve()
+	server.Close()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// sftpHandler handles the SFTP requests with a communicator.
+type sftpHandler struct {
+	comm packersdk.Communicator
+}
+
+// run runs command on the remote, and returns its output.
+func (h *sftpHandler) run(command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := h.comm.Start(context.TODO(), cmd); err != nil {
+		return "", err
+	}
+	if code := cmd.Wait(); code != 0 {
+		return "", fmt.Errorf("%s exited with %d: %s", command, code, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (h *sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	f, err := tmp.File("sftp-download")
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SFTP: downloading %s", r.Filepath)
+	if err := h.comm.Download(r.Filepath, f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &tmpFile{File: f}, nil
+}
+
+func (h *sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	f, err := tmp.File("sftp-upload")
+	if err != nil {
+		return nil, err
+	}
+	return &tmpFile{File: f, upload: func(f *os.File) error {
+		log.Printf("SFTP: uploading %s", r.Filepath)
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return h.comm.Upload(r.Filepath, f, nil)
+	}}, nil
+}
+
+func (h *sftpHandler) Filecmd(r *sftp.Request) error {
+	p := shellQuote(r.Filepath)
+	var err error
+	switch r.Method {
+	case "Setstat":
+		if r.AttrFlags().Permissions {
+			_, err = h.run(fmt.Sprintf("chmod %o %s", r.Attributes().FileMode().Perm(), p))
+		}
+	case "Rename":
+		_, err = h.run(fmt.Sprintf("mv %s %s", p, shellQuote(r.Target)))
+	case "Rmdir":
+		_, err = h.run("rmdir " + p)
+	case "Mkdir":
+		_, err = h.run("mkdir " + p)
+	case "Remove":
+		_, err = h.run("rm -f " + p)
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+	return err
+}
+
+func (h *sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	p := shellQuote(r.Filepath)
+	var command string
+	switch r.Method {
+	case "Stat":
+		command = "stat -L -c '%f %s %Y %n' " + p
+	case "List":
+		command = "find " + p + " -mindepth 1 -maxdepth 1 -exec stat -c '%f %s %Y %n' {} +"
+	case "Readlink":
+		out, err := h.run("readlink " + p)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{fileInfo{name: strings.TrimSuffix(out, "\n")}}, nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+
+	out, err := h.run(command)
+	if err != nil {
+		if r.Method == "Stat" {
+			// The client probes paths to know whether they exist.
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	var l listerAt
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		fi, err := parseStat(line)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, fi)
+	}
+	return l, nil
+}
+
+// parseStat parses a line of `stat -c '%f %s %Y %n'`.
+func parseStat(line string) (fileInfo, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return fileInfo{}, fmt.Errorf("unexpected stat output %q", line)
+	}
+	mode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("unexpected stat output %q: %s", line, err)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("unexpected stat output %q: %s", line, err)
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fileInfo{}, fmt.Errorf("unexpected stat output %q: %s", line, err)
+	}
+
+	fm := os.FileMode(mode & 0777)
+	switch mode & 0170000 {
+	case 0040000:
+		fm |= os.ModeDir
+	case 0120000:
+		fm |= os.ModeSymlink
+	}
+	return fileInfo{
+		name:  path.Base(fields[3]),
+		size:  size,
+		mode:  fm,
+		mtime: time.Unix(mtime, 0),
+	}, nil
+}
+
+// tmpFile is a temporary file removed once closed, and uploaded first when
+// upload is set.
+type tmpFile struct {
+	*os.File
+	upload func(*os.File) error
+}
+
+func (f *tmpFile) Close() error {
+	defer os.Remove(f.Name())
+	var err error
+	if f.upload != nil {
+		err = f.upload(f.File)
+	}
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(f []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(f, l[offset:])
+	if n < len(f) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,82 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package adapter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestAdapter_emulatedSFTP(t *testing.T) {
+	client, err := sftp.NewClient(testClient(t, EmulatedSFTP))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer client.Close()
+
+	dir := t.TempDir()
+	if err := client.Mkdir(filepath.Join(dir, "sub")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := filepath.Join(dir, "sub", "file")
+	f, err := client.Create(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := f.Write([]byte("content")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := client.Chmod(dst, 0640); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	fi, err := client.Stat(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Name() != "file" || fi.Size() != 7 || fi.Mode().Perm() != 0640 {
+		t.Fatalf("unexpected file info %s %d %s", fi.Name(), fi.Size(), fi.Mode())
+	}
+	if _, err := client.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("expected a missing file, got %v", err)
+	}
+	entries, err := client.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "sub" || !entries[0].IsDir() {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+
+	renamed := filepath.Join(dir, "renamed")
+	if err := client.Rename(dst, renamed); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f, err = client.Open(renamed)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "content" {
+		t.Fatalf("unexpected content %q: %v", b, err)
+	}
+
+	if err := client.Remove(renamed); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := client.RemoveDirectory(filepath.Join(dir, "sub")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("unexpected entries %v: %v", entries, err)
+	}
+}
@@ -62,18 +62,26 @@ var g = &grammar{
 							alternatives: []interface{}{
 								&ruleRefExpr{
 									pos:  position{line: 10, col: 13, offset: 87},
+									name: "WaitForText",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 27, offset: 101},
 									name: "Wait",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 20, offset: 94},
+									pos:  position{line: 10, col: 34, offset: 108},
+									name: "Speed",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 10, col: 42, offset: 116},
 									name: "CharToggle",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 33, offset: 107},
+									pos:  position{line: 10, col: 55, offset: 129},
 									name: "Special",
 								},
 								&ruleRefExpr{
-									pos:  position{line: 10, col: 43, offset: 117},
+									pos:  position{line: 10, col: 65, offset: 139},
 									name: "Literal",
 								},
 							},
@@ -84,37 +92,37 @@ var g = &grammar{
 		},
 		{
 			name: "Wait",
-			pos:  position{line: 14, col: 1, offset: 150},
+			pos:  position{line: 14, col: 1, offset: 172},
 			expr: &actionExpr{
-				pos: position{line: 14, col: 8, offset: 157},
+				pos: position{line: 14, col: 8, offset: 179},
 				run: (*parser).callonWait1,
 				expr: &seqExpr{
-					pos: position{line: 14, col: 8, offset: 157},
+					pos: position{line: 14, col: 8, offset: 179},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 14, col: 8, offset: 157},
+							pos:  position{line: 14, col: 8, offset: 179},
 							name: "ExprStart",
 						},
 						&litMatcher{
-							pos:        position{line: 14, col: 18, offset: 167},
+							pos:        position{line: 14, col: 18, offset: 189},
 							val:        "wait",
 							ignoreCase: false,
 							want:       "\"wait\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 14, col: 25, offset: 174},
+							pos:   position{line: 14, col: 25, offset: 196},
 							label: "duration",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 14, col: 34, offset: 183},
+								pos: position{line: 14, col: 34, offset: 205},
 								expr: &choiceExpr{
-									pos: position{line: 14, col: 36, offset: 185},
+									pos: position{line: 14, col: 36, offset: 207},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 14, col: 36, offset: 185},
+											pos:  position{line: 14, col: 36, offset: 207},
 											name: "Duration",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 14, col: 47, offset: 196},
+											pos:  position{line: 14, col: 47, offset: 218},
 											name: "Integer",
 										},
 									},
@@ -122,53 +130,285 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 14, col: 58, offset: 207},
+							pos:  position{line: 14, col: 58, offset: 229},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "WaitForText",
+			pos:  position{line: 27, col: 1, offset: 475},
+			expr: &actionExpr{
+				pos: position{line: 27, col: 15, offset: 489},
+				run: (*parser).callonWaitForText1,
+				expr: &seqExpr{
+					pos: position{line: 27, col: 15, offset: 489},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 27, col: 15, offset: 489},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 27, col: 25, offset: 499},
+							val:        "wait_for_text:",
+							ignoreCase: true,
+							want:       "\"wait_for_text:\"i",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 27, col: 43, offset: 517},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 27, col: 45, offset: 519},
+							label: "text",
+							expr: &ruleRefExpr{
+								pos:  position{line: 27, col: 50, offset: 524},
+								name: "QuotedText",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 27, col: 61, offset: 535},
+							label: "timeout",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 27, col: 69, offset: 543},
+								expr: &ruleRefExpr{
+									pos:  position{line: 27, col: 69, offset: 543},
+									name: "TextTimeout",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 27, col: 82, offset: 556},
+							name: "_",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 27, col: 84, offset: 558},
+							name: "ExprEnd",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "TextTimeout",
+			pos:  position{line: 34, col: 1, offset: 758},
+			expr: &actionExpr{
+				pos: position{line: 34, col: 15, offset: 772},
+				run: (*parser).callonTextTimeout1,
+				expr: &seqExpr{
+					pos: position{line: 34, col: 15, offset: 772},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 34, col: 15, offset: 772},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 34, col: 17, offset: 774},
+							val:        ",",
+							ignoreCase: false,
+							want:       "\",\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 34, col: 21, offset: 778},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 34, col: 23, offset: 780},
+							label: "d",
+							expr: &ruleRefExpr{
+								pos:  position{line: 34, col: 25, offset: 782},
+								name: "Duration",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "QuotedText",
+			pos:  position{line: 38, col: 1, offset: 814},
+			expr: &actionExpr{
+				pos: position{line: 38, col: 14, offset: 827},
+				run: (*parser).callonQuotedText1,
+				expr: &seqExpr{
+					pos: position{line: 38, col: 14, offset: 827},
+					exprs: []interface{}{
+						&litMatcher{
+							pos:        position{line: 38, col: 14, offset: 827},
+							val:        "'",
+							ignoreCase: false,
+							want:       "\"'\"",
+						},
+						&zeroOrMoreExpr{
+							pos: position{line: 38, col: 18, offset: 831},
+							expr: &choiceExpr{
+								pos: position{line: 38, col: 20, offset: 833},
+								alternatives: []interface{}{
+									&seqExpr{
+										pos: position{line: 38, col: 20, offset: 833},
+										exprs: []interface{}{
+											&litMatcher{
+												pos:        position{line: 38, col: 20, offset: 833},
+												val:        "\\",
+												ignoreCase: false,
+												want:       "\"\\\\\"",
+											},
+											&anyMatcher{
+												line: 38, col: 25, offset: 838,
+											},
+										},
+									},
+									&charClassMatcher{
+										pos:        position{line: 38, col: 29, offset: 842},
+										val:        "[^'\\\\]",
+										chars:      []rune{'\'', '\\'},
+										ignoreCase: false,
+										inverted:   true,
+									},
+								},
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 38, col: 39, offset: 852},
+							val:        "'",
+							ignoreCase: false,
+							want:       "\"'\"",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Speed",
+			pos:  position{line: 43, col: 1, offset: 973},
+			expr: &actionExpr{
+				pos: position{line: 43, col: 9, offset: 981},
+				run: (*parser).callonSpeed1,
+				expr: &seqExpr{
+					pos: position{line: 43, col: 9, offset: 981},
+					exprs: []interface{}{
+						&ruleRefExpr{
+							pos:  position{line: 43, col: 9, offset: 981},
+							name: "ExprStart",
+						},
+						&litMatcher{
+							pos:        position{line: 43, col: 19, offset: 991},
+							val:        "speed",
+							ignoreCase: true,
+							want:       "\"speed\"i",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 43, col: 28, offset: 1000},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 43, col: 30, offset: 1002},
+							label: "s",
+							expr: &choiceExpr{
+								pos: position{line: 43, col: 34, offset: 1006},
+								alternatives: []interface{}{
+									&ruleRefExpr{
+										pos:  position{line: 43, col: 34, offset: 1006},
+										name: "Duration",
+									},
+									&ruleRefExpr{
+										pos:  position{line: 43, col: 45, offset: 1017},
+										name: "SpeedProfile",
+									},
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 43, col: 60, offset: 1032},
+							name: "_",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 43, col: 62, offset: 1034},
 							name: "ExprEnd",
 						},
 					},
 				},
 			},
 		},
+		{
+			name: "SpeedProfile",
+			pos:  position{line: 50, col: 1, offset: 1203},
+			expr: &actionExpr{
+				pos: position{line: 50, col: 16, offset: 1218},
+				run: (*parser).callonSpeedProfile1,
+				expr: &choiceExpr{
+					pos: position{line: 50, col: 18, offset: 1220},
+					alternatives: []interface{}{
+						&litMatcher{
+							pos:        position{line: 50, col: 18, offset: 1220},
+							val:        "slow",
+							ignoreCase: true,
+							want:       "\"slow\"i",
+						},
+						&litMatcher{
+							pos:        position{line: 50, col: 28, offset: 1230},
+							val:        "normal",
+							ignoreCase: true,
+							want:       "\"normal\"i",
+						},
+						&litMatcher{
+							pos:        position{line: 50, col: 40, offset: 1242},
+							val:        "fast",
+							ignoreCase: true,
+							want:       "\"fast\"i",
+						},
+						&litMatcher{
+							pos:        position{line: 50, col: 50, offset: 1252},
+							val:        "default",
+							ignoreCase: true,
+							want:       "\"default\"i",
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "CharToggle",
-			pos:  position{line: 27, col: 1, offset: 453},
+			pos:  position{line: 54, col: 1, offset: 1318},
 			expr: &actionExpr{
-				pos: position{line: 27, col: 14, offset: 466},
+				pos: position{line: 54, col: 14, offset: 1331},
 				run: (*parser).callonCharToggle1,
 				expr: &seqExpr{
-					pos: position{line: 27, col: 14, offset: 466},
+					pos: position{line: 54, col: 14, offset: 1331},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 27, col: 14, offset: 466},
+							pos:  position{line: 54, col: 14, offset: 1331},
 							name: "ExprStart",
 						},
 						&labeledExpr{
-							pos:   position{line: 27, col: 24, offset: 476},
+							pos:   position{line: 54, col: 24, offset: 1341},
 							label: "lit",
 							expr: &ruleRefExpr{
-								pos:  position{line: 27, col: 29, offset: 481},
+								pos:  position{line: 54, col: 29, offset: 1346},
 								name: "Literal",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 27, col: 38, offset: 490},
+							pos:   position{line: 54, col: 38, offset: 1355},
 							label: "t",
 							expr: &choiceExpr{
-								pos: position{line: 27, col: 41, offset: 493},
+								pos: position{line: 54, col: 41, offset: 1358},
 								alternatives: []interface{}{
 									&ruleRefExpr{
-										pos:  position{line: 27, col: 41, offset: 493},
+										pos:  position{line: 54, col: 41, offset: 1358},
 										name: "On",
 									},
 									&ruleRefExpr{
-										pos:  position{line: 27, col: 46, offset: 498},
+										pos:  position{line: 54, col: 46, offset: 1363},
 										name: "Off",
 									},
 								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 27, col: 51, offset: 503},
+							pos:  position{line: 54, col: 51, offset: 1368},
 							name: "ExprEnd",
 						},
 					},
@@ -177,39 +417,39 @@ var g = &grammar{
 		},
 		{
 			name: "Special",
-			pos:  position{line: 31, col: 1, offset: 574},
+			pos:  position{line: 58, col: 1, offset: 1439},
 			expr: &actionExpr{
-				pos: position{line: 31, col: 11, offset: 584},
+				pos: position{line: 58, col: 11, offset: 1449},
 				run: (*parser).callonSpecial1,
 				expr: &seqExpr{
-					pos: position{line: 31, col: 11, offset: 584},
+					pos: position{line: 58, col: 11, offset: 1449},
 					exprs: []interface{}{
 						&ruleRefExpr{
-							pos:  position{line: 31, col: 11, offset: 584},
+							pos:  position{line: 58, col: 11, offset: 1449},
 							name: "ExprStart",
 						},
 						&labeledExpr{
-							pos:   position{line: 31, col: 21, offset: 594},
+							pos:   position{line: 58, col: 21, offset: 1459},
 							label: "s",
 							expr: &ruleRefExpr{
-								pos:  position{line: 31, col: 24, offset: 597},
+								pos:  position{line: 58, col: 24, offset: 1462},
 								name: "SpecialKey",
 							},
 						},
 						&labeledExpr{
-							pos:   position{line: 31, col: 36, offset: 609},
+							pos:   position{line: 58, col: 36, offset: 1474},
 							label: "t",
 							expr: &zeroOrOneExpr{
-								pos: position{line: 31, col: 38, offset: 611},
+								pos: position{line: 58, col: 38, offset: 1476},
 								expr: &choiceExpr{
-									pos: position{line: 31, col: 39, offset: 612},
+									pos: position{line: 58, col: 39, offset: 1477},
 									alternatives: []interface{}{
 										&ruleRefExpr{
-											pos:  position{line: 31, col: 39, offset: 612},
+											pos:  position{line: 58, col: 39, offset: 1477},
 											name: "On",
 										},
 										&ruleRefExpr{
-											pos:  position{line: 31, col: 44, offset: 617},
+											pos:  position{line: 58, col: 44, offset: 1482},
 											name: "Off",
 										},
 									},
@@ -217,7 +457,7 @@ var g = &grammar{
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 31, col: 50, offset: 623},
+							pos:  position{line: 58, col: 50, offset: 1488},
 							name: "ExprEnd",
 						},
 					},
@@ -226,41 +466,41 @@ var g = &grammar{
 		},
 		{
 			name: "Number",
-			pos:  position{line: 39, col: 1, offset: 810},
+			pos:  position{line: 66, col: 1, offset: 1675},
 			expr: &actionExpr{
-				pos: position{line: 39, col: 10, offset: 819},
+				pos: position{line: 66, col: 10, offset: 1684},
 				run: (*parser).callonNumber1,
 				expr: &seqExpr{
-					pos: position{line: 39, col: 10, offset: 819},
+					pos: position{line: 66, col: 10, offset: 1684},
 					exprs: []interface{}{
 						&zeroOrOneExpr{
-							pos: position{line: 39, col: 10, offset: 819},
+							pos: position{line: 66, col: 10, offset: 1684},
 							expr: &litMatcher{
-								pos:        position{line: 39, col: 10, offset: 819},
+								pos:        position{line: 66, col: 10, offset: 1684},
 								val:        "-",
 								ignoreCase: false,
 								want:       "\"-\"",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 39, col: 15, offset: 824},
+							pos:  position{line: 66, col: 15, offset: 1689},
 							name: "Integer",
 						},
 						&zeroOrOneExpr{
-							pos: position{line: 39, col: 23, offset: 832},
+							pos: position{line: 66, col: 23, offset: 1697},
 							expr: &seqExpr{
-								pos: position{line: 39, col: 25, offset: 834},
+								pos: position{line: 66, col: 25, offset: 1699},
 								exprs: []interface{}{
 									&litMatcher{
-										pos:        position{line: 39, col: 25, offset: 834},
+										pos:        position{line: 66, col: 25, offset: 1699},
 										val:        ".",
 										ignoreCase: false,
 										want:       "\".\"",
 									},
 									&oneOrMoreExpr{
-										pos: position{line: 39, col: 29, offset: 838},
+										pos: position{line: 66, col: 29, offset: 1703},
 										expr: &ruleRefExpr{
-											pos:  position{line: 39, col: 29, offset: 838},
+											pos:  position{line: 66, col: 29, offset: 1703},
 											name: "Digit",
 										},
 									},
@@ -273,30 +513,30 @@ var g = &grammar{
 		},
 		{
 			name: "Integer",
-			pos:  position{line: 43, col: 1, offset: 884},
+			pos:  position{line: 70, col: 1, offset: 1749},
 			expr: &choiceExpr{
-				pos: position{line: 43, col: 11, offset: 894},
+				pos: position{line: 70, col: 11, offset: 1759},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 43, col: 11, offset: 894},
+						pos:        position{line: 70, col: 11, offset: 1759},
 						val:        "0",
 						ignoreCase: false,
 						want:       "\"0\"",
 					},
 					&actionExpr{
-						pos: position{line: 43, col: 17, offset: 900},
+						pos: position{line: 70, col: 17, offset: 1765},
 						run: (*parser).callonInteger3,
 						expr: &seqExpr{
-							pos: position{line: 43, col: 17, offset: 900},
+							pos: position{line: 70, col: 17, offset: 1765},
 							exprs: []interface{}{
 								&ruleRefExpr{
-									pos:  position{line: 43, col: 17, offset: 900},
+									pos:  position{line: 70, col: 17, offset: 1765},
 									name: "NonZeroDigit",
 								},
 								&zeroOrMoreExpr{
-									pos: position{line: 43, col: 30, offset: 913},
+									pos: position{line: 70, col: 30, offset: 1778},
 									expr: &ruleRefExpr{
-										pos:  position{line: 43, col: 30, offset: 913},
+										pos:  position{line: 70, col: 30, offset: 1778},
 										name: "Digit",
 									},
 								},
@@ -308,21 +548,21 @@ var g = &grammar{
 		},
 		{
 			name: "Duration",
-			pos:  position{line: 47, col: 1, offset: 977},
+			pos:  position{line: 74, col: 1, offset: 1842},
 			expr: &actionExpr{
-				pos: position{line: 47, col: 12, offset: 988},
+				pos: position{line: 74, col: 12, offset: 1853},
 				run: (*parser).callonDuration1,
 				expr: &oneOrMoreExpr{
-					pos: position{line: 47, col: 12, offset: 988},
+					pos: position{line: 74, col: 12, offset: 1853},
 					expr: &seqExpr{
-						pos: position{line: 47, col: 14, offset: 990},
+						pos: position{line: 74, col: 14, offset: 1855},
 						exprs: []interface{}{
 							&ruleRefExpr{
-								pos:  position{line: 47, col: 14, offset: 990},
+								pos:  position{line: 74, col: 14, offset: 1855},
 								name: "Number",
 							},
 							&ruleRefExpr{
-								pos:  position{line: 47, col: 21, offset: 997},
+								pos:  position{line: 74, col: 21, offset: 1862},
 								name: "TimeUnit",
 							},
 						},
@@ -332,12 +572,12 @@ var g = &grammar{
 		},
 		{
 			name: "On",
-			pos:  position{line: 51, col: 1, offset: 1060},
+			pos:  position{line: 78, col: 1, offset: 1925},
 			expr: &actionExpr{
-				pos: position{line: 51, col: 6, offset: 1065},
+				pos: position{line: 78, col: 6, offset: 1930},
 				run: (*parser).callonOn1,
 				expr: &litMatcher{
-					pos:        position{line: 51, col: 6, offset: 1065},
+					pos:        position{line: 78, col: 6, offset: 1930},
 					val:        "on",
 					ignoreCase: true,
 					want:       "\"on\"i",
@@ -346,12 +586,12 @@ var g = &grammar{
 		},
 		{
 			name: "Off",
-			pos:  position{line: 55, col: 1, offset: 1098},
+			pos:  position{line: 82, col: 1, offset: 1963},
 			expr: &actionExpr{
-				pos: position{line: 55, col: 7, offset: 1104},
+				pos: position{line: 82, col: 7, offset: 1969},
 				run: (*parser).callonOff1,
 				expr: &litMatcher{
-					pos:        position{line: 55, col: 7, offset: 1104},
+					pos:        position{line: 82, col: 7, offset: 1969},
 					val:        "off",
 					ignoreCase: true,
 					want:       "\"off\"i",
@@ -360,20 +600,20 @@ var g = &grammar{
 		},
 		{
 			name: "Literal",
-			pos:  position{line: 59, col: 1, offset: 1139},
+			pos:  position{line: 86, col: 1, offset: 2004},
 			expr: &actionExpr{
-				pos: position{line: 59, col: 11, offset: 1149},
+				pos: position{line: 86, col: 11, offset: 2014},
 				run: (*parser).callonLiteral1,
 				expr: &anyMatcher{
-					line: 59, col: 11, offset: 1149,
+					line: 86, col: 11, offset: 2014,
 				},
 			},
 		},
 		{
 			name: "ExprEnd",
-			pos:  position{line: 64, col: 1, offset: 1230},
+			pos:  position{line: 91, col: 1, offset: 2095},
 			expr: &litMatcher{
-				pos:        position{line: 64, col: 11, offset: 1240},
+				pos:        position{line: 91, col: 11, offset: 2105},
 				val:        ">",
 				ignoreCase: false,
 				want:       "\">\"",
@@ -381,9 +621,9 @@ var g = &grammar{
 		},
 		{
 			name: "ExprStart",
-			pos:  position{line: 65, col: 1, offset: 1244},
+			pos:  position{line: 92, col: 1, offset: 2109},
 			expr: &litMatcher{
-				pos:        position{line: 65, col: 13, offset: 1256},
+				pos:        position{line: 92, col: 13, offset: 2121},
 				val:        "<",
 				ignoreCase: false,
 				want:       "\"<\"",
@@ -391,228 +631,228 @@ var g = &grammar{
 		},
 		{
 			name: "SpecialKey",
-			pos:  position{line: 66, col: 1, offset: 1260},
+			pos:  position{line: 93, col: 1, offset: 2125},
 			expr: &choiceExpr{
-				pos: position{line: 66, col: 14, offset: 1273},
+				pos: position{line: 93, col: 14, offset: 2138},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 66, col: 14, offset: 1273},
+						pos:        position{line: 93, col: 14, offset: 2138},
 						val:        "bs",
 						ignoreCase: true,
 						want:       "\"bs\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 22, offset: 1281},
+						pos:        position{line: 93, col: 22, offset: 2146},
 						val:        "del",
 						ignoreCase: true,
 						want:       "\"del\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 31, offset: 1290},
+						pos:        position{line: 93, col: 31, offset: 2155},
 						val:        "enter",
 						ignoreCase: true,
 						want:       "\"enter\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 42, offset: 1301},
+						pos:        position{line: 93, col: 42, offset: 2166},
 						val:        "esc",
 						ignoreCase: true,
 						want:       "\"esc\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 51, offset: 1310},
+						pos:        position{line: 93, col: 51, offset: 2175},
 						val:        "f10",
 						ignoreCase: true,
 						want:       "\"f10\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 60, offset: 1319},
+						pos:        position{line: 93, col: 60, offset: 2184},
 						val:        "f11",
 						ignoreCase: true,
 						want:       "\"f11\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 66, col: 69, offset: 1328},
+						pos:        position{line: 93, col: 69, offset: 2193},
 						val:        "f12",
 						ignoreCase: true,
 						want:       "\"f12\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 11, offset: 1345},
+						pos:        position{line: 94, col: 11, offset: 2210},
 						val:        "f1",
 						ignoreCase: true,
 						want:       "\"f1\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 19, offset: 1353},
+						pos:        position{line: 94, col: 19, offset: 2218},
 						val:        "f2",
 						ignoreCase: true,
 						want:       "\"f2\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 27, offset: 1361},
+						pos:        position{line: 94, col: 27, offset: 2226},
 						val:        "f3",
 						ignoreCase: true,
 						want:       "\"f3\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 35, offset: 1369},
+						pos:        position{line: 94, col: 35, offset: 2234},
 						val:        "f4",
 						ignoreCase: true,
 						want:       "\"f4\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 43, offset: 1377},
+						pos:        position{line: 94, col: 43, offset: 2242},
 						val:        "f5",
 						ignoreCase: true,
 						want:       "\"f5\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 51, offset: 1385},
+						pos:        position{line: 94, col: 51, offset: 2250},
 						val:        "f6",
 						ignoreCase: true,
 						want:       "\"f6\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 59, offset: 1393},
+						pos:        position{line: 94, col: 59, offset: 2258},
 						val:        "f7",
 						ignoreCase: true,
 						want:       "\"f7\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 67, offset: 1401},
+						pos:        position{line: 94, col: 67, offset: 2266},
 						val:        "f8",
 						ignoreCase: true,
 						want:       "\"f8\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 67, col: 75, offset: 1409},
+						pos:        position{line: 94, col: 75, offset: 2274},
 						val:        "f9",
 						ignoreCase: true,
 						want:       "\"f9\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 12, offset: 1426},
+						pos:        position{line: 95, col: 12, offset: 2291},
 						val:        "return",
 						ignoreCase: true,
 						want:       "\"return\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 24, offset: 1438},
+						pos:        position{line: 95, col: 24, offset: 2303},
 						val:        "tab",
 						ignoreCase: true,
 						want:       "\"tab\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 33, offset: 1447},
+						pos:        position{line: 95, col: 33, offset: 2312},
 						val:        "up",
 						ignoreCase: true,
 						want:       "\"up\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 41, offset: 1455},
+						pos:        position{line: 95, col: 41, offset: 2320},
 						val:        "down",
 						ignoreCase: true,
 						want:       "\"down\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 51, offset: 1465},
+						pos:        position{line: 95, col: 51, offset: 2330},
 						val:        "spacebar",
 						ignoreCase: true,
 						want:       "\"spacebar\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 65, offset: 1479},
+						pos:        position{line: 95, col: 65, offset: 2344},
 						val:        "insert",
 						ignoreCase: true,
 						want:       "\"insert\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 68, col: 77, offset: 1491},
+						pos:        position{line: 95, col: 77, offset: 2356},
 						val:        "home",
 						ignoreCase: true,
 						want:       "\"home\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 11, offset: 1509},
+						pos:        position{line: 96, col: 11, offset: 2374},
 						val:        "end",
 						ignoreCase: true,
 						want:       "\"end\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 20, offset: 1518},
+						pos:        position{line: 96, col: 20, offset: 2383},
 						val:        "pageup",
 						ignoreCase: true,
 						want:       "\"pageUp\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 32, offset: 1530},
+						pos:        position{line: 96, col: 32, offset: 2395},
 						val:        "pagedown",
 						ignoreCase: true,
 						want:       "\"pageDown\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 46, offset: 1544},
+						pos:        position{line: 96, col: 46, offset: 2409},
 						val:        "leftalt",
 						ignoreCase: true,
 						want:       "\"leftAlt\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 59, offset: 1557},
+						pos:        position{line: 96, col: 59, offset: 2422},
 						val:        "leftctrl",
 						ignoreCase: true,
 						want:       "\"leftCtrl\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 69, col: 73, offset: 1571},
+						pos:        position{line: 96, col: 73, offset: 2436},
 						val:        "leftshift",
 						ignoreCase: true,
 						want:       "\"leftShift\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 11, offset: 1594},
+						pos:        position{line: 97, col: 11, offset: 2459},
 						val:        "rightalt",
 						ignoreCase: true,
 						want:       "\"rightAlt\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 25, offset: 1608},
+						pos:        position{line: 97, col: 25, offset: 2473},
 						val:        "rightctrl",
 						ignoreCase: true,
 						want:       "\"rightCtrl\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 40, offset: 1623},
+						pos:        position{line: 97, col: 40, offset: 2488},
 						val:        "rightshift",
 						ignoreCase: true,
 						want:       "\"rightShift\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 56, offset: 1639},
+						pos:        position{line: 97, col: 56, offset: 2504},
 						val:        "leftsuper",
 						ignoreCase: true,
 						want:       "\"leftSuper\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 70, col: 71, offset: 1654},
+						pos:        position{line: 97, col: 71, offset: 2519},
 						val:        "rightsuper",
 						ignoreCase: true,
 						want:       "\"rightSuper\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 11, offset: 1678},
+						pos:        position{line: 98, col: 11, offset: 2543},
 						val:        "left",
 						ignoreCase: true,
 						want:       "\"left\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 21, offset: 1688},
+						pos:        position{line: 98, col: 21, offset: 2553},
 						val:        "right",
 						ignoreCase: true,
 						want:       "\"right\"i",
 					},
 					&litMatcher{
-						pos:        position{line: 71, col: 32, offset: 1699},
+						pos:        position{line: 98, col: 32, offset: 2564},
 						val:        "menu",
 						ignoreCase: true,
 						want:       "\"menu\"i",
@@ -622,9 +862,9 @@ var g = &grammar{
 		},
 		{
 			name: "NonZeroDigit",
-			pos:  position{line: 73, col: 1, offset: 1708},
+			pos:  position{line: 100, col: 1, offset: 2573},
 			expr: &charClassMatcher{
-				pos:        position{line: 73, col: 16, offset: 1723},
+				pos:        position{line: 100, col: 16, offset: 2588},
 				val:        "[1-9]",
 				ranges:     []rune{'1', '9'},
 				ignoreCase: false,
@@ -633,9 +873,9 @@ var g = &grammar{
 		},
 		{
 			name: "Digit",
-			pos:  position{line: 74, col: 1, offset: 1729},
+			pos:  position{line: 101, col: 1, offset: 2594},
 			expr: &charClassMatcher{
-				pos:        position{line: 74, col: 9, offset: 1737},
+				pos:        position{line: 101, col: 9, offset: 2602},
 				val:        "[0-9]",
 				ranges:     []rune{'0', '9'},
 				ignoreCase: false,
@@ -644,48 +884,48 @@ var g = &grammar{
 		},
 		{
 			name: "TimeUnit",
-			pos:  position{line: 75, col: 1, offset: 1743},
+			pos:  position{line: 102, col: 1, offset: 2608},
 			expr: &choiceExpr{
-				pos: position{line: 75, col: 13, offset: 1755},
+				pos: position{line: 102, col: 13, offset: 2620},
 				alternatives: []interface{}{
 					&litMatcher{
-						pos:        position{line: 75, col: 13, offset: 1755},
+						pos:        position{line: 102, col: 13, offset: 2620},
 						val:        "ns",
 						ignoreCase: false,
 						want:       "\"ns\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 20, offset: 1762},
+						pos:        position{line: 102, col: 20, offset: 2627},
 						val:        "us",
 						ignoreCase: false,
 						want:       "\"us\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 27, offset: 1769},
+						pos:        position{line: 102, col: 27, offset: 2634},
 						val:        "µs",
 						ignoreCase: false,
 						want:       "\"µs\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 34, offset: 1777},
+						pos:        position{line: 102, col: 34, offset: 2642},
 						val:        "ms",
 						ignoreCase: false,
 						want:       "\"ms\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 41, offset: 1784},
+						pos:        position{line: 102, col: 41, offset: 2649},
 						val:        "s",
 						ignoreCase: false,
 						want:       "\"s\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 47, offset: 1790},
+						pos:        position{line: 102, col: 47, offset: 2655},
 						val:        "m",
 						ignoreCase: false,
 						want:       "\"m\"",
 					},
 					&litMatcher{
-						pos:        position{line: 75, col: 53, offset: 1796},
+						pos:        position{line: 102, col: 53, offset: 2661},
 						val:        "h",
 						ignoreCase: false,
 						want:       "\"h\"",
@@ -696,11 +936,11 @@ var g = &grammar{
 		{
 			name:        "_",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 77, col: 1, offset: 1802},
+			pos:         position{line: 104, col: 1, offset: 2667},
 			expr: &zeroOrMoreExpr{
-				pos: position{line: 77, col: 19, offset: 1820},
+				pos: position{line: 104, col: 19, offset: 2685},
 				expr: &charClassMatcher{
-					pos:        position{line: 77, col: 19, offset: 1820},
+					pos:        position{line: 104, col: 19, offset: 2685},
 					val:        "[ \\n\\t\\r]",
 					chars:      []rune{' ', '\n', '\t', '\r'},
 					ignoreCase: false,
@@ -710,11 +950,11 @@ var g = &grammar{
 		},
 		{
 			name: "EOF",
-			pos:  position{line: 79, col: 1, offset: 1832},
+			pos:  position{line: 106, col: 1, offset: 2697},
 			expr: &notExpr{
-				pos: position{line: 79, col: 8, offset: 1839},
+				pos: position{line: 106, col: 8, offset: 2704},
 				expr: &anyMatcher{
-					line: 79, col: 9, offset: 1840,
+					line: 106, col: 9, offset: 2705,
 				},
 			},
 		},
@@ -760,6 +1000,63 @@ func (p *parser) callonWait1() (interface{}, error) {
 	return p.cur.onWait1(stack["duration"])
 }
 
+func (c *current) onWaitForText1(text, timeout interface{}) (interface{}, error) {
+	if timeout == nil {
+		return &waitForTextExpression{text.(string), WaitForTextTimeout}, nil
+	}
+	return &waitForTextExpression{text.(string), timeout.(time.Duration)}, nil
+}
+
+func (p *parser) callonWaitForText1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWaitForText1(stack["text"], stack["timeout"])
+}
+
+func (c *current) onTextTimeout1(d interface{}) (interface{}, error) {
+	return d, nil
+}
+
+func (p *parser) callonTextTimeout1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onTextTimeout1(stack["d"])
+}
+
+func (c *current) onQuotedText1() (interface{}, error) {
+	s := string(c.text[1 : len(c.text)-1])
+	return strings.NewReplacer(`\'`, "'", `\\`, `\`).Replace(s), nil
+}
+
+func (p *parser) callonQuotedText1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onQuotedText1()
+}
+
+func (c *current) onSpeed1(s interface{}) (interface{}, error) {
+	if p, ok := s.(string); ok {
+		return &speedExpression{KeySpeedProfiles[p], p}, nil
+	}
+	return &speedExpression{s.(time.Duration), ""}, nil
+}
+
+func (p *parser) callonSpeed1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onSpeed1(stack["s"])
+}
+
+func (c *current) onSpeedProfile1() (interface{}, error) {
+	return strings.ToLower(string(c.text)), nil
+}
+
+func (p *parser) callonSpeedProfile1() (interface{}, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onSpeedProfile1()
+}
+
 func (c *current) onCharToggle1(lit, t interface{}) (interface{}, error) {
 	return &literal{lit.(*literal).s, t.(KeyAction)}, nil
 }
@@ -54,6 +54,10 @@ func (s expressionSequence) Do(ctx context.Context, b BCDriver) error {
 		return fmt.Errorf("Found an invalid boot command. This is likely an error in Packer, so please open a ticket.")
 	}
 
+	if setter, ok := findDriver[contextSetter](b); ok {
+		setter.SetContext(ctx)
+	}
+
 	for _, exp := range s {
 		if err := ctx.Err(); err != nil {
 			return err
@@ -121,6 +125,120 @@ func (w *waitExpression) String() string {
 	return fmt.Sprintf("Wait<%s>", w.d)
 }
 
+// WaitForTextTimeout is how long `<wait_for_text>` waits for the text to show
+// up when no timeout is given.
+var WaitForTextTimeout = 5 * time.Minute
+
+// WaitForTextInterval is the delay between two reads of the screen while
+// waiting for some text.
+var WaitForTextInterval = time.Second
+
+type waitForTextExpression struct {
+	text    string
+	timeout time.Duration
+}
+
+// normalizeScreenText collapses runs of white space, which screen readers
+// like OCR don't reproduce reliably.
+func normalizeScreenText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Do reads the screen until the text shows up. It fails when the driver
+// cannot read the screen or after the timeout, and is cancellable through
+// the context.
+func (w *waitForTextExpression) Do(ctx context.Context, driver BCDriver) error {
+	driver.Flush()
+	grabber, ok := findDriver[ScreenGrabber](driver)
+	if !ok {
+		return fmt.Errorf("Cannot wait for text %q: this builder cannot read the screen of the VM", w.text)
+	}
+
+	log.Printf("[INFO] Waiting up to %s for %q to show up on the screen", w.timeout, w.text)
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	text := normalizeScreenText(w.text)
+	for {
+		screen, err := grabber.ScreenText(ctx)
+		if err != nil {
+			log.Printf("[WARN] Error reading the screen: %s", err)
+		} else if strings.Contains(normalizeScreenText(screen), text) {
+			return nil
+		}
+
+		select {
+		case <-time.After(WaitForTextInterval):
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("Timeout waiting for %q on the screen after %s", w.text, w.timeout)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// Validate returns an error if the text is empty or the timeout is <= 0
+func (w *waitForTextExpression) Validate() error {
+	if normalizeScreenText(w.text) == "" {
+		return fmt.Errorf("Expecting some text to wait for")
+	}
+	if w.timeout <= 0 {
+		return fmt.Errorf("Expecting a positive timeout to wait for text. Got %s", w.timeout)
+	}
+	return nil
+}
+
+func (w *waitForTextExpression) String() string {
+	return fmt.Sprintf("WaitForText<%q, %s>", w.text, w.timeout)
+}
+
+// KeySpeedProfiles are the delays between key events of the speed profiles,
+// for `boot_key_speed` and `<speed>`. The "default" profile restores the
+// delay the driver was created with.
+var KeySpeedProfiles = map[string]time.Duration{
+	"slow":    300 * time.Millisecond,
+	"normal":  PackerKeyDefault,
+	"fast":    10 * time.Millisecond,
+	"default": 0,
+}
+
+type speedExpression struct {
+	interval time.Duration
+	profile  string
+}
+
+// Do changes the delay between the next key events, for the drivers
+// supporting it. The keys typed so far are flushed first, so they are sent
+// at the previous speed.
+func (s *speedExpression) Do(ctx context.Context, driver BCDriver) error {
+	if err := driver.Flush(); err != nil {
+		return err
+	}
+	setter, ok := findDriver[KeyIntervalSetter](driver)
+	if !ok {
+		log.Printf("[WARN] Ignoring %s, the typing speed of this builder cannot be changed", s)
+		return nil
+	}
+	log.Printf("[INFO] Changing the typing speed: %s", s)
+	setter.SetKeyInterval(s.interval)
+	return nil
+}
+
+// Validate returns an error if the time is < 0
+func (s *speedExpression) Validate() error {
+	if s.interval < 0 || (s.interval == 0 && s.profile == "") {
+		return fmt.Errorf("Expecting a positive key interval. Got %s", s.interval)
+	}
+	return nil
+}
+
+func (s *speedExpression) String() string {
+	if s.profile != "" {
+		return fmt.Sprintf("Speed<%s>", s.profile)
+	}
+	return fmt.Sprintf("Speed<%s>", s.interval)
+}
+
 type specialExpression struct {
 	s      string
 	action KeyAction
@@ -4,9 +4,11 @@
 package bootcommand
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -114,6 +116,30 @@ func Test_validation(t *testing.T) {
 			"<",
 			true,
 		},
+		{
+			"<wait_for_text: 'login:', 10m>",
+			true,
+		},
+		{
+			"<wait_for_text: ' '>",
+			false,
+		},
+		{
+			"<speed 50ms>",
+			true,
+		},
+		{
+			"<speed default>",
+			true,
+		},
+		{
+			"<speed -50ms>",
+			false,
+		},
+		{
+			"<wait_for_text: 'login:', -1s>",
+			false,
+		},
 	}
 	for _, tt := range expressions {
 		exp, err := GenerateExpressionSequence(tt.in)
@@ -131,6 +157,113 @@ func Test_validation(t *testing.T) {
 	}
 }
 
+func Test_waitForTextParse(t *testing.T) {
+	var expressions = []struct {
+		in  string
+		out string
+	}{
+		{
+			"<wait_for_text: 'Press any key'>",
+			`WaitForText<"Press any key", 5m0s>`,
+		},
+		{
+			"<WAIT_FOR_TEXT:'boot: ' , 30s>",
+			`WaitForText<"boot: ", 30s>`,
+		},
+		{
+			`<wait_for_text: 'it\'s <ok> \\o/'>`,
+			`WaitForText<"it's <ok> \\o/", 5m0s>`,
+		},
+	}
+	for _, tt := range expressions {
+		exp, err := GenerateExpressionSequence(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.in, err)
+		}
+		assert.Len(t, exp, 1)
+		assert.Equal(t, tt.out, fmt.Sprintf("%s", exp[0]))
+	}
+}
+
+type fakeScreen struct {
+	recordingDriver
+	screens []string
+	reads   int
+}
+
+func (s *fakeScreen) ScreenText(context.Context) (string, error) {
+	screen := s.screens[s.reads]
+	if s.reads < len(s.screens)-1 {
+		s.reads++
+	}
+	return screen, nil
+}
+
+func Test_waitForTextDo(t *testing.T) {
+	defer func(i time.Duration) { WaitForTextInterval = i }(WaitForTextInterval)
+	WaitForTextInterval = time.Millisecond
+
+	seq, err := GenerateExpressionSequence("<wait_for_text: 'Press  any key', 1s>a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	screen := &fakeScreen{screens: []string{"Booting...", "Booting...\nPress any\n key to continue"}}
+	if err := seq.Do(context.Background(), screen); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, 1, screen.reads)
+	assert.Equal(t, []string{"a Press"}, screen.events)
+
+	// The screen grabber can be found behind wrapping drivers.
+	screen = &fakeScreen{screens: []string{"Press any key"}}
+	layout, err := LoadKeyboardLayout("de")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.NoError(t, seq.Do(context.Background(), NewLayoutDriver(screen, layout)))
+	assert.NoError(t, seq.Do(context.Background(), WithScreenGrabber(new(recordingDriver), screen)))
+
+	screen = &fakeScreen{screens: []string{"Booting..."}}
+	seq, err = GenerateExpressionSequence("<wait_for_text: 'Press any key', 10ms>")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Error(t, seq.Do(context.Background(), screen), "should time out")
+	assert.Error(t, seq.Do(context.Background(), new(recordingDriver)), "should not be able to read the screen")
+}
+
+func Test_speed(t *testing.T) {
+	seq, err := GenerateExpressionSequence("<speed 1ms>a<SPEED fast>b<speed50ms><speed default>")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"Speed<1ms>", "LIT-Press(a)", "Speed<fast>", "LIT-Press(b)", "Speed<50ms>", "Speed<default>"}
+	var got []string
+	for _, exp := range seq {
+		got = append(got, fmt.Sprintf("%s", exp))
+	}
+	assert.Equal(t, expected, got)
+
+	d := NewVNCDriver(&sender{}, 5*time.Millisecond)
+	for i, interval := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, 5 * time.Millisecond} {
+		if err := seq[[]int{0, 2, 4, 5}[i]].Do(context.Background(), d); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assert.Equal(t, interval, d.interval)
+	}
+
+	// Speed changes go through wrapping drivers, and are ignored by the
+	// drivers not supporting them.
+	layout, err := LoadKeyboardLayout("de")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.NoError(t, seq[0].Do(context.Background(), NewLayoutDriver(d, layout)))
+	assert.Equal(t, time.Millisecond, d.interval)
+	assert.NoError(t, seq[0].Do(context.Background(), new(recordingDriver)))
+}
+
 func Test_empty(t *testing.T) {
 	exp, err := GenerateExpressionSequence("")
 	assert.NoError(t, err, "should have parsed an empty input okay.")
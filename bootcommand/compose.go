@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Command composes a boot command programmatically, for builders and tools
+// generating the boot commands of well known installers. For example:
+//
+//	cmd := NewCommand().
+//		Press("esc").Wait(time.Second).
+//		Type("linux ks=http://{{ .HTTPIP }}:{{ .HTTPPort }}/ks.cfg").
+//		Press("enter")
+//
+// Its String can be used as, or appended to, a boot command.
+type Command struct {
+	b strings.Builder
+}
+
+// NewCommand returns an empty command.
+func NewCommand() *Command {
+	return &Command{}
+}
+
+// Type types text. It is added as is: special keys like `<enter>` in text
+// are pressed as usual.
+func (c *Command) Type(text string) *Command {
+	c.b.WriteString(text)
+	return c
+}
+
+// Press presses then releases each of the keys, in order. Keys are the names
+// of special keys, like "enter" or "f2".
+func (c *Command) Press(keys ...string) *Command {
+	for _, key := range keys {
+		fmt.Fprintf(&c.b, "<%s>", key)
+	}
+	return c
+}
+
+// keyToggle returns the expression holding or releasing key, a special key
+// or a single character.
+func keyToggle(key string, action KeyAction) string {
+	return fmt.Sprintf("<%s%s>", key, action)
+}
+
+// Hold holds key down while f composes the rest of the command, then
+// releases it. key is the name of a special key or a single character. For
+// example, ctrl+alt+del is composed with:
+//
+//	NewCommand().Hold("leftCtrl", func(c *Command) {
+//		c.Hold("leftAlt", func(c *Command) {
+//			c.Press("del")
+//		})
+//	})
+func (c *Command) Hold(key string, f func(*Command)) *Command {
+	c.b.WriteString(keyToggle(key, KeyOn))
+	f(c)
+	c.b.WriteString(keyToggle(key, KeyOff))
+	return c
+}
+
+// Wait pauses for d.
+func (c *Command) Wait(d time.Duration) *Command {
+	fmt.Fprintf(&c.b, "<wait%s>", d)
+	return c
+}
+
+// WaitForText waits for text to be displayed on the screen, for at most
+// timeout, or the default WaitForTextTimeout when timeout is 0.
+func (c *Command) WaitForText(text string, timeout time.Duration) *Command {
+	text = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text)
+	if timeout == 0 {
+		fmt.Fprintf(&c.b, "<wait_for_text: '%s'>", text)
+	} else {
+		fmt.Fprintf(&c.b, "<wait_for_text: '%s', %s>", text, timeout)
+	}
+	return c
+}
+
+// Macro includes the boot command macro called name, see
+// BootConfig.BootCommandMacros.
+func (c *Command) Macro(name string) *Command {
+	fmt.Fprintf(&c.b, "{{template %q .}}", name)
+	return c
+}
+
+// Append appends other commands to c.
+func (c *Command) Append(others ...*Command) *Command {
+	for _, o := range others {
+		c.b.WriteString(o.String())
+	}
+	return c
+}
+
+// Validate returns the errors of the command, as BootConfig.Prepare would.
+// Commands including macros can only be validated once interpolated.
+func (c *Command) Validate() []error {
+	seq, err := GenerateExpressionSequence(c.String())
+	if err != nil {
+		return []error{err}
+	}
+	return seq.Validate()
+}
+
+func (c *Command) String() string {
+	return c.b.String()
+}
@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestCommand(t *testing.T) {
+	login := NewCommand().Type("root").Press("enter").WaitForText("Password:", 0)
+	cmd := NewCommand().
+		Press("esc", "esc").
+		Wait(1500*time.Millisecond).
+		Hold("leftCtrl", func(c *Command) {
+			c.Hold("leftAlt", func(c *Command) {
+				c.Press("del")
+			})
+		}).
+		Hold("c", func(*Command) {}).
+		WaitForText(`it's \o/`, time.Minute).
+		Append(login)
+
+	expected := "<esc><esc><wait1.5s>" +
+		"<leftCtrlOn><leftAltOn><del><leftAltOff><leftCtrlOff><cOn><cOff>" +
+		`<wait_for_text: 'it\'s \\o/', 1m0s>` +
+		"root<enter><wait_for_text: 'Password:'>"
+	if cmd.String() != expected {
+		t.Fatalf("bad command:\n%s\nexpected:\n%s", cmd, expected)
+	}
+	if errs := cmd.Validate(); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	seq, err := GenerateExpressionSequence(cmd.String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s := seq[len(seq)-1].(*waitForTextExpression); s.text != "Password:" || s.timeout != WaitForTextTimeout {
+		t.Fatalf("bad expression: %s", s)
+	}
+}
+
+func TestBootCommandMacros(t *testing.T) {
+	c := &BootConfig{
+		BootCommandMacros: map[string]string{
+			"login":  "root<enter>{{ .Password }}<enter>",
+			"wait":   "<wait5>",
+			"random": "{{template \"wait\" .}}",
+		},
+		BootCommand: []string{
+			NewCommand().Macro("login").String(),
+			"ls{{template \"random\" .}}<enter>",
+		},
+	}
+	if errs := c.Prepare(&interpolate.Context{}); len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	ctx := &interpolate.Context{Data: map[string]string{"Password": "toor"}}
+	command, err := interpolate.Render(c.FlatBootCommand(), ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := "root<enter>toor<enter>ls<wait5><enter>"
+	if command != expected {
+		t.Fatalf("bad command %q, expected %q", command, expected)
+	}
+
+	c.BootCommand = append(c.BootCommand, NewCommand().Macro("logout").String())
+	errs := c.Prepare(&interpolate.Context{})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"logout"`) {
+		t.Fatalf("bad: %#v", errs)
+	}
+	c.BootCommandMacros["logout"] = "exit<enter>"
+
+	c.BootCommandMacros["bad"] = "<wait-1s>"
+	c.BootCommandMacros[`"`] = "a"
+	if errs := c.Prepare(&interpolate.Context{}); len(errs) != 2 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
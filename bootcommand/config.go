@@ -7,7 +7,10 @@ package bootcommand
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"text/template"
+	"text/template/parse"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -22,6 +25,14 @@ const PackerKeyEnv = "PACKER_KEY_INTERVAL"
 // shorter delay (e.g. 10ms) can be used on a workstation. See PackerKeyEnv.
 const PackerKeyDefault = 100 * time.Millisecond
 
+// InterpolateExclude returns the options of BootConfig that builders must
+// exclude from the interpolation done when decoding their configuration,
+// these being interpolated when the boot command is typed, once the IP of the
+// HTTP server and such are known.
+func InterpolateExclude() []string {
+	return []string{"boot_command", "boot_command_macros"}
+}
+
 // The boot configuration is very important: `boot_command` specifies the keys
 // to type when the virtual machine is first booted in order to start the OS
 // installer. This command is typed after boot_wait, which gives the virtual
@@ -76,6 +87,21 @@ const PackerKeyDefault = 100 * time.Millisecond
 //     Valid time units are `ns`, `us` (or `µs`), `ms`, `s`, `m`, `h`. For
 //     example `<wait10m>` or `<wait1m20s>`.
 //
+//   - `<wait_for_text: 'XX'>` - Waits for the text `XX` to be displayed on
+//     the screen of the machine before sending any additional keys, failing
+//     the build if it doesn't show up within 5 minutes. A different timeout
+//     can be given after the text, for example
+//     `<wait_for_text: 'Press any key', 10m>`. Quotes and backslashes in the
+//     text are escaped with a backslash. This is only available with the
+//     builders able to read the screen of the machine.
+//
+//   - `<speed XX>` - Changes the delay between the keys typed next, `XX`
+//     being a duration, like `<speed 500ms>`, or one of the `slow`, `normal`
+//     and `fast` speed profiles. `<speed default>` goes back to the speed
+//     the builder was configured with. This allows typing fragile sections,
+//     like the GRUB editor, slowly while typing the rest fast. This is
+//     ignored by the builders that cannot change their typing speed.
+//
 //   - `<XXXOn> <XXXOff>` - Any printable keyboard character, and of these
 //     "special" expressions, with the exception of the `<wait>` types, can
 //     also be toggled on or off. For example, to simulate ctrl+c, use
@@ -176,6 +202,39 @@ type BootConfig struct {
 	// well, and are covered in the section below on the boot command. If this
 	// is not specified, it is assumed the installer will start itself.
 	BootCommand []string `mapstructure:"boot_command"`
+	// The keyboard layout of the guest, for the characters of the
+	// `boot_command` to be typed with the keys that produce them on that
+	// layout. It is either one of the built-in layouts, `us` (the default),
+	// `uk`, `de`, `fr` and `jp`, or the path to a keymap file. Every line of a
+	// keymap file has a character of the layout followed by what the key
+	// typing it types on a US keyboard, or `nonusbackslash`,
+	// `international1` or `international3` for the keys that don't exist on
+	// US keyboards, then by the optional `shift`, `altgr` and `dead` flags.
+	// For example `z y`, `@ q altgr` or `< nonusbackslash`. This has no effect
+	// over VNC, where the VNC server does that translation.
+	BootKeyboardLayout string `mapstructure:"boot_keyboard_layout"`
+	// Named snippets of boot command, that the `boot_command` can include with
+	// `{{template "name" .}}`, `name` being the name of the macro. Macros can
+	// use the same special keys and variables as the `boot_command`, and
+	// include other macros. This avoids repeating, for example, the keys
+	// typed to log in:
+	//
+	// ```hcl
+	// boot_command_macros = {
+	//   login = "root<enter><wait>packer<enter><wait>"
+	// }
+	// boot_command = [
+	//   "{{template \"login\" .}}",
+	//   "curl -o /tmp/install.sh http://{{ .HTTPIP }}:{{ .HTTPPort }}/install.sh<enter>",
+	// ]
+	// ```
+	BootCommandMacros map[string]string `mapstructure:"boot_command_macros"`
+	// The typing speed of the `boot_command`: `slow` (300ms between keys),
+	// `normal` (100ms) or `fast` (10ms). This is overridden by the key
+	// interval option of the builder, if any, and can be changed in the boot
+	// command itself with `<speed XX>`. Defaults to the speed the builder
+	// picks.
+	BootKeySpeed string `mapstructure:"boot_key_speed"`
 }
 
 // The boot command "typed" character for character over a VNC connection to
@@ -192,6 +251,14 @@ type VNCConfig struct {
 	DisableVNC bool `mapstructure:"disable_vnc"`
 	// Time in ms to wait between each key press
 	BootKeyInterval time.Duration `mapstructure:"boot_key_interval"`
+	// How many times to try reconnecting when the VNC connection drops while
+	// typing the boot command, for the builders supporting it. Typing then
+	// resumes from the last key sent. Defaults to 5, set to a negative number
+	// to fail the build right away.
+	VNCReconnectRetries int `mapstructure:"vnc_reconnect_retries"`
+	// The time to wait before the first reconnection attempt, doubled after
+	// every failed attempt up to 30 seconds. Defaults to `1s`.
+	VNCReconnectBackoff time.Duration `mapstructure:"vnc_reconnect_backoff"`
 }
 
 func (c *BootConfig) Prepare(ctx *interpolate.Context) (errs []error) {
@@ -200,7 +267,8 @@ func (c *BootConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 	}
 
 	if c.BootCommand != nil {
-		expSeq, err := GenerateExpressionSequence(c.FlatBootCommand())
+		// Macros are validated on their own below.
+		expSeq, err := GenerateExpressionSequence(strings.Join(c.BootCommand, ""))
 		if err != nil {
 			errs = append(errs, err)
 		} else if vErrs := expSeq.Validate(); vErrs != nil {
@@ -208,11 +276,138 @@ func (c *BootConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 		}
 	}
 
+	for name, macro := range c.BootCommandMacros {
+		if name == "" || strings.ContainsAny(name, "\"`{}") {
+			errs = append(errs, fmt.Errorf("Invalid boot command macro name %q", name))
+			continue
+		}
+		expSeq, err := GenerateExpressionSequence(macro)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error parsing boot command macro %q: %s", name, err))
+		} else if vErrs := expSeq.Validate(); vErrs != nil {
+			for _, err := range vErrs {
+				errs = append(errs, fmt.Errorf("Error in boot command macro %q: %s", name, err))
+			}
+		}
+	}
+
+	for _, name := range undefinedMacros(c.FlatBootCommand()) {
+		errs = append(errs, fmt.Errorf("Undefined boot command macro %q", name))
+	}
+
+	if c.BootKeySpeed != "" {
+		if _, ok := KeySpeedProfiles[c.BootKeySpeed]; !ok || c.BootKeySpeed == "default" {
+			errs = append(errs, fmt.Errorf("Invalid boot_key_speed %q, expected slow, normal or fast", c.BootKeySpeed))
+		}
+	}
+
+	if c.BootKeyboardLayout != "" {
+		if _, err := LoadKeyboardLayout(c.BootKeyboardLayout); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid boot_keyboard_layout: %s", err))
+		}
+	}
+
 	return
 }
 
+// undefinedMacros returns the names of the templates included by command
+// that it doesn't define. Commands that fail to parse are reported when
+// interpolated.
+func undefinedMacros(command string) []string {
+	tpl, err := template.New("boot_command").Funcs(interpolate.Funcs(nil)).Parse(command)
+	if err != nil {
+		return nil
+	}
+
+	included := map[string]bool{}
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			includedTemplates(t.Tree.Root, included)
+		}
+	}
+	var names []string
+	for name := range included {
+		if tpl.Lookup(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func includedTemplates(node parse.Node, names map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, node := range n.Nodes {
+			includedTemplates(node, names)
+		}
+	case *parse.TemplateNode:
+		names[n.Name] = true
+	case *parse.IfNode:
+		includedTemplates(n.List, names)
+		includedTemplates(n.ElseList, names)
+	case *parse.RangeNode:
+		includedTemplates(n.List, names)
+		includedTemplates(n.ElseList, names)
+	case *parse.WithNode:
+		includedTemplates(n.List, names)
+		includedTemplates(n.ElseList, names)
+	}
+}
+
+// KeyInterval returns the delay between key events to create the drivers
+// with: the one of boot_key_speed, or 0 for the driver to pick its default.
+func (c *BootConfig) KeyInterval() time.Duration {
+	return KeySpeedProfiles[c.BootKeySpeed]
+}
+
+// DryRun interpolates the boot command with ctx and returns its timeline,
+// see DryRun. The boot_wait is not included.
+func (c *BootConfig) DryRun(ctx *interpolate.Context) (*Timeline, error) {
+	command, err := interpolate.Render(c.FlatBootCommand(), ctx)
+	if err != nil {
+		return nil, err
+	}
+	return DryRun(command, c.KeyInterval())
+}
+
+// KeyboardLayoutDriver wraps driver so that it types the boot command with
+// the keyboard layout of the guest. It is a no-op for the VNC driver.
+func (c *BootConfig) KeyboardLayoutDriver(driver BCDriver) (BCDriver, error) {
+	if c.BootKeyboardLayout == "" {
+		return driver, nil
+	}
+	if _, ok := findDriver[*vncDriver](driver); ok {
+		return driver, nil
+	}
+	layout, err := LoadKeyboardLayout(c.BootKeyboardLayout)
+	if err != nil {
+		return nil, err
+	}
+	return NewLayoutDriver(driver, layout), nil
+}
+
+// FlatBootCommand returns the boot command as a single string, to be
+// interpolated then parsed. The macros are prepended to it as template
+// definitions, which render to nothing.
 func (c *BootConfig) FlatBootCommand() string {
-	return strings.Join(c.BootCommand, "")
+	names := make([]string, 0, len(c.BootCommandMacros))
+	for name := range c.BootCommandMacros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "{{define %q}}%s{{end}}", name, c.BootCommandMacros[name])
+	}
+	for _, command := range c.BootCommand {
+		b.WriteString(command)
+	}
+	return b.String()
 }
 
 func (c *VNCConfig) Prepare(ctx *interpolate.Context) (errs []error) {
@@ -221,6 +416,24 @@ func (c *VNCConfig) Prepare(ctx *interpolate.Context) (errs []error) {
 			fmt.Errorf("A boot command cannot be used when vnc is disabled."))
 	}
 
+	if c.VNCReconnectRetries == 0 {
+		c.VNCReconnectRetries = DefaultVNCReconnectRetries
+	}
+	if c.VNCReconnectBackoff == 0 {
+		c.VNCReconnectBackoff = DefaultVNCReconnectBackoff
+	}
+
 	errs = append(errs, c.BootConfig.Prepare(ctx)...)
 	return
 }
+
+// VNCDriver creates a VNC driver connected with dial, reconnecting as
+// configured by vnc_reconnect_retries and vnc_reconnect_backoff when the
+// connection drops.
+func (c *VNCConfig) VNCDriver(dial VNCDialFunc) (BCDriver, error) {
+	interval := c.BootKeyInterval
+	if interval == 0 {
+		interval = c.KeyInterval()
+	}
+	return NewReconnectingVNCDriver(dial, interval, c.VNCReconnectRetries, c.VNCReconnectBackoff)
+}
@@ -31,6 +31,62 @@ func TestConfigPrepare(t *testing.T) {
 	if len(errs) > 0 {
 		t.Fatalf("bad: %#v", errs)
 	}
+
+	// Test with a keyboard layout
+	c = new(BootConfig)
+	c.BootKeyboardLayout = "de"
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	// Test with a speed profile
+	c = new(BootConfig)
+	c.BootKeySpeed = "fast"
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if c.KeyInterval() != 10*time.Millisecond {
+		t.Fatalf("bad value: %s", c.KeyInterval())
+	}
+
+	// Test with an unknown speed profile
+	c = new(BootConfig)
+	c.BootKeySpeed = "ludicrous"
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+
+	// Test with an unknown keyboard layout
+	c = new(BootConfig)
+	c.BootKeyboardLayout = "klingon"
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestBootConfigKeyboardLayoutDriver(t *testing.T) {
+	c := &BootConfig{BootKeyboardLayout: "de"}
+
+	vnc := WithScreenGrabber(NewVNCDriver(new(sender), time.Nanosecond), nil)
+	d, err := c.KeyboardLayoutDriver(vnc)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if d != vnc {
+		t.Fatalf("a wrapped VNC driver should not be wrapped with the layout: %#v", d)
+	}
+
+	d, err = c.KeyboardLayoutDriver(new(recordingDriver))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := d.(*layoutDriver); !ok {
+		t.Fatalf("scancode drivers should be wrapped with the layout: %#v", d)
+	}
 }
 
 func TestVNCConfigPrepare(t *testing.T) {
@@ -58,4 +114,14 @@ func TestVNCConfigPrepare(t *testing.T) {
 	if len(errs) > 0 {
 		t.Fatalf("bad: %#v", errs)
 	}
+
+	// Test the reconnection defaults
+	c = new(VNCConfig)
+	errs = c.Prepare(&interpolate.Context{})
+	if len(errs) > 0 {
+		t.Fatalf("bad: %#v", errs)
+	}
+	if c.VNCReconnectRetries != DefaultVNCReconnectRetries || c.VNCReconnectBackoff != DefaultVNCReconnectBackoff {
+		t.Fatalf("bad reconnection defaults: %d %s", c.VNCReconnectRetries, c.VNCReconnectBackoff)
+	}
 }
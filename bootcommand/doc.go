@@ -6,8 +6,10 @@
 // This package is relevant to people who want to create new builders, particularly
 // builders with the capacity to build a VM from an iso.
 //
-// You can choose between three different drivers to send the command: a vnc
-// driver, a usb driver, and a PX-XT keyboard driver. The driver you choose will
-// depend on what kind of keyboard codes your hypervisor expects, and how you want
-// to implement the connection.
+// You can choose between five different drivers to send the command: a vnc
+// driver, a usb driver, a USB HID report driver, a PX-XT keyboard driver, and
+// a serial console driver. The driver you choose will depend on what kind of
+// keyboard codes your hypervisor expects, and how you want to implement the
+// connection. The HID driver can also click on the screen when the VM has a
+// USB tablet.
 package bootcommand
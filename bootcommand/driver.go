@@ -3,6 +3,11 @@
 
 package bootcommand
 
+import (
+	"context"
+	"time"
+)
+
 const shiftedChars = "~!@#$%^&*()_+{}|:\"<>?"
 
 // BCDriver is our access to the VM we want to type boot commands to
@@ -12,3 +17,60 @@ type BCDriver interface {
 	// Flush will be called when we want to send scancodes to the VM.
 	Flush() error
 }
+
+// unwrapper is implemented by the drivers wrapping another driver, like the
+// layout driver, for expressions to find the capabilities of the driver being
+// wrapped.
+type unwrapper interface {
+	Unwrap() BCDriver
+}
+
+// findDriver returns driver, or the first of the drivers it wraps, that is a
+// T.
+func findDriver[T any](driver BCDriver) (T, bool) {
+	for driver != nil {
+		if t, ok := driver.(T); ok {
+			return t, true
+		}
+		u, ok := driver.(unwrapper)
+		if !ok {
+			break
+		}
+		driver = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// contextSetter is implemented by the drivers that can block outside of the
+// expressions of a boot command, like the VNC driver when reconnecting. The
+// context of the boot command is set before it is typed so that they stop
+// waiting when it is cancelled.
+type contextSetter interface {
+	SetContext(ctx context.Context)
+}
+
+// KeyIntervalSetter is implemented by the drivers which typing speed can be
+// changed with `<speed>`.
+type KeyIntervalSetter interface {
+	// SetKeyInterval sets the delay between key events, 0 restoring the
+	// delay the driver was created with.
+	SetKeyInterval(interval time.Duration)
+}
+
+// keySpeed holds the delay between the key events of a driver.
+type keySpeed struct {
+	interval        time.Duration
+	defaultInterval time.Duration
+}
+
+func newKeySpeed(interval time.Duration) keySpeed {
+	return keySpeed{interval: interval, defaultInterval: interval}
+}
+
+func (s *keySpeed) SetKeyInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = s.defaultInterval
+	}
+	s.interval = interval
+}
@@ -0,0 +1,153 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// EventKind is the kind of a TimelineEvent.
+type EventKind string
+
+const (
+	EventKey         EventKind = "key"
+	EventSpecial     EventKind = "special"
+	EventWait        EventKind = "wait"
+	EventWaitForText EventKind = "wait_for_text"
+	EventSpeed       EventKind = "speed"
+)
+
+// TimelineEvent is a step of a boot command.
+type TimelineEvent struct {
+	// Offset is the estimated time since the start of the boot command.
+	Offset time.Duration
+	Kind   EventKind
+	// Key is the character typed by EventKey events.
+	Key rune
+	// Special is the name of the special key of EventSpecial events.
+	Special string
+	// Action is what EventKey and EventSpecial events do with the key.
+	Action KeyAction
+	// Duration is the estimated time taken by the event: typing the key, or
+	// waiting. It is the timeout of EventWaitForText events.
+	Duration time.Duration
+	// Text is the text waited for by EventWaitForText events.
+	Text string
+	// Interval is the new delay between key events of EventSpeed events.
+	Interval time.Duration
+}
+
+func (e TimelineEvent) String() string {
+	var what string
+	switch e.Kind {
+	case EventKey:
+		what = fmt.Sprintf("%q %s", e.Key, e.Action)
+	case EventSpecial:
+		what = fmt.Sprintf("<%s> %s", e.Special, e.Action)
+	case EventWait:
+		what = e.Duration.String()
+	case EventWaitForText:
+		what = fmt.Sprintf("%q for at most %s", e.Text, e.Duration)
+	case EventSpeed:
+		what = fmt.Sprintf("%s between keys", e.Interval)
+	}
+	return fmt.Sprintf("%10s %s %s", e.Offset, e.Kind, what)
+}
+
+// Timeline is the expanded list of what a boot command does.
+type Timeline struct {
+	Events []TimelineEvent
+	// Duration is the estimated time typing the boot command takes, when
+	// every `<wait_for_text>` succeeds right away.
+	Duration time.Duration
+	// MaxDuration is the time it takes when every `<wait_for_text>` times out.
+	MaxDuration time.Duration
+}
+
+func (t *Timeline) String() string {
+	var b strings.Builder
+	for _, e := range t.Events {
+		fmt.Fprintln(&b, e)
+	}
+	fmt.Fprintf(&b, "Total: %s, at most %s\n", t.Duration, t.MaxDuration)
+	return b.String()
+}
+
+// keyEvents returns the number of key events a driver sends to type a key.
+func keyEvents(action KeyAction, shift bool) int {
+	n := 1
+	if action == KeyPress {
+		n = 2
+	}
+	if shift {
+		n += 2
+	}
+	return n
+}
+
+// DryRun parses command, validates it and returns the keys it types and the
+// waits it does, without typing it. interval is the delay between key events
+// the driver would be created with, 0 picking the default like the drivers
+// do. Variables and macros must have been interpolated.
+//
+// The durations are estimates based on the delay between key events: they
+// don't account for the latency of the hypervisor.
+func DryRun(command string, interval time.Duration) (*Timeline, error) {
+	seq, err := GenerateExpressionSequence(command)
+	if err != nil {
+		return nil, err
+	}
+	if errs := seq.Validate(); len(errs) > 0 {
+		return nil, &multierror.Error{Errors: errs}
+	}
+
+	defaultInterval := PackerKeyDefault
+	if delay, err := time.ParseDuration(os.Getenv(PackerKeyEnv)); err == nil {
+		defaultInterval = delay
+	}
+	if interval > 0 {
+		defaultInterval = interval
+	}
+	interval = defaultInterval
+
+	t := &Timeline{}
+	var maxOffset time.Duration
+	for _, exp := range seq {
+		e := TimelineEvent{Offset: t.Duration}
+		switch exp := exp.(type) {
+		case *literal:
+			shift := unicode.IsUpper(exp.s) || strings.ContainsRune(shiftedChars, exp.s)
+			e.Kind, e.Key, e.Action = EventKey, exp.s, exp.action
+			e.Duration = time.Duration(keyEvents(exp.action, shift)) * interval
+		case *specialExpression:
+			e.Kind, e.Special, e.Action = EventSpecial, exp.s, exp.action
+			e.Duration = time.Duration(keyEvents(exp.action, false)) * interval
+		case *waitExpression:
+			e.Kind, e.Duration = EventWait, exp.d
+		case *waitForTextExpression:
+			e.Kind, e.Text, e.Duration = EventWaitForText, exp.text, exp.timeout
+			maxOffset += exp.timeout
+		case *speedExpression:
+			interval = exp.interval
+			if interval == 0 {
+				interval = defaultInterval
+			}
+			e.Kind, e.Interval = EventSpeed, interval
+		default:
+			return nil, fmt.Errorf("unknown boot command expression %s", exp)
+		}
+		t.Events = append(t.Events, e)
+		if e.Kind != EventWaitForText {
+			t.Duration += e.Duration
+		}
+	}
+	t.MaxDuration = t.Duration + maxOffset
+	return t, nil
+}
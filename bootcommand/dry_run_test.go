@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestDryRun(t *testing.T) {
+	timeline, err := DryRun("aB<enter><wait5><wait_for_text: 'login:', 1m><speed 1s><leftShiftOn><speed default>", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ms := time.Millisecond
+	expected := []TimelineEvent{
+		{Offset: 0, Kind: EventKey, Key: 'a', Action: KeyPress, Duration: 20 * ms},
+		{Offset: 20 * ms, Kind: EventKey, Key: 'B', Action: KeyPress, Duration: 40 * ms},
+		{Offset: 60 * ms, Kind: EventSpecial, Special: "enter", Action: KeyPress, Duration: 20 * ms},
+		{Offset: 80 * ms, Kind: EventWait, Duration: 5 * time.Second},
+		{Offset: 5080 * ms, Kind: EventWaitForText, Text: "login:", Duration: time.Minute},
+		{Offset: 5080 * ms, Kind: EventSpeed, Interval: time.Second},
+		{Offset: 5080 * ms, Kind: EventSpecial, Special: "leftshift", Action: KeyOn, Duration: time.Second},
+		{Offset: 6080 * ms, Kind: EventSpeed, Interval: 10 * ms},
+	}
+	if diff := cmp.Diff(expected, timeline.Events); diff != "" {
+		t.Fatalf("unexpected timeline: %s", diff)
+	}
+	if timeline.Duration != 6080*ms || timeline.MaxDuration != 66080*ms {
+		t.Fatalf("bad durations: %s, %s", timeline.Duration, timeline.MaxDuration)
+	}
+	if timeline.String() == "" {
+		t.Fatal("should render the timeline")
+	}
+}
+
+func TestDryRun_invalid(t *testing.T) {
+	if _, err := DryRun("<wait-1s><speed -1s>", 0); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBootConfig_DryRun(t *testing.T) {
+	c := &BootConfig{
+		BootCommandMacros: map[string]string{"login": "root<enter>"},
+		BootCommand:       []string{`{{template "login" .}}`, "<wait>{{ .Name }}"},
+		BootKeySpeed:      "fast",
+	}
+	ctx := &interpolate.Context{Data: map[string]string{"Name": "vm"}}
+	timeline, err := c.DryRun(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(timeline.Events) != 8 || timeline.Duration != time.Second+140*time.Millisecond {
+		t.Fatalf("bad timeline: %s", timeline)
+	}
+}
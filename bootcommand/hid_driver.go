@@ -0,0 +1,234 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// HIDKeyboardReport is a USB HID boot protocol keyboard input report.
+type HIDKeyboardReport struct {
+	// Modifiers is the bitmap of the pressed modifier keys, bit 0 being left
+	// control and bit 7 right GUI.
+	Modifiers byte
+	// Keys are the usages of the other pressed keys, 0 meaning none.
+	Keys [6]byte
+}
+
+// Bytes returns the 8 bytes of the report, as sent on the wire.
+func (r HIDKeyboardReport) Bytes() []byte {
+	return append([]byte{r.Modifiers, 0}, r.Keys[:]...)
+}
+
+// HIDButton is a bitmap of the buttons of a pointing device.
+type HIDButton byte
+
+const (
+	HIDButtonLeft HIDButton = 1 << iota
+	HIDButtonRight
+	HIDButtonMiddle
+)
+
+// HIDTabletMax is the maximal value of the coordinates of a HIDTabletReport.
+const HIDTabletMax = 0x7FFF
+
+// HIDTabletReport is the input report of an absolute pointing device, like
+// the QEMU usb-tablet or the VMware absolute mouse.
+type HIDTabletReport struct {
+	Buttons HIDButton
+	// X and Y go from 0, the top left corner of the screen, to HIDTabletMax.
+	X, Y  uint16
+	Wheel int8
+}
+
+// Bytes returns the 6 bytes of the report, as sent on the wire: buttons,
+// little endian X then Y, and wheel.
+func (r HIDTabletReport) Bytes() []byte {
+	return []byte{byte(r.Buttons), byte(r.X), byte(r.X >> 8), byte(r.Y), byte(r.Y >> 8), byte(r.Wheel)}
+}
+
+// HIDKeyboard sends keyboard reports to the VM.
+type HIDKeyboard interface {
+	SendKeyboardReport(HIDKeyboardReport) error
+}
+
+// HIDTablet sends absolute pointer reports to the VM. A HIDKeyboard can
+// implement it to enable the pointer methods of the HID driver.
+type HIDTablet interface {
+	SendTabletReport(HIDTabletReport) error
+}
+
+type hidDriver struct {
+	keyboard HIDKeyboard
+	keySpeed
+	specialMap  map[string]key.Code
+	scancodeMap map[rune]key.Code
+
+	// The current state of the keyboard.
+	modifiers byte
+	pressed   []key.Code
+}
+
+// NewHIDDriver creates a boot command driver that types through USB HID
+// keyboard reports. Unlike the driver of NewUSBDriver, which leaves the
+// (de)composition of key events to the builder, it tracks the state of the
+// keyboard and sends a full report for every change, which is what
+// hypervisors emulating USB devices expect.
+//
+// When keyboard also implements HIDTablet, the driver can click on the
+// screen as well, see Click.
+func NewHIDDriver(keyboard HIDKeyboard, interval time.Duration) *hidDriver {
+	// We delay (default 100ms) between each report to allow for CPU or
+	// network latency. See PackerKeyEnv for tuning.
+	keyInterval := PackerKeyDefault
+	if delay, err := time.ParseDuration(os.Getenv(PackerKeyEnv)); err == nil {
+		keyInterval = delay
+	}
+	// override interval based on builder-specific override.
+	if interval > time.Duration(0) {
+		keyInterval = interval
+	}
+
+	return &hidDriver{
+		keyboard:    keyboard,
+		keySpeed:    newKeySpeed(keyInterval),
+		specialMap:  usbSpecialMap(),
+		scancodeMap: usbScancodeMap(),
+	}
+}
+
+// modifierBit returns the bit of the modifier bitmap of a modifier key, or 0
+// for the other keys.
+func modifierBit(k key.Code) byte {
+	if k < key.CodeLeftControl || k > key.CodeRightGUI {
+		return 0
+	}
+	return 1 << (k - key.CodeLeftControl)
+}
+
+func (d *hidDriver) report() error {
+	r := HIDKeyboardReport{Modifiers: d.modifiers}
+	for i, k := range d.pressed {
+		r.Keys[i] = byte(k)
+	}
+	if err := d.keyboard.SendKeyboardReport(r); err != nil {
+		return err
+	}
+	time.Sleep(d.interval)
+	return nil
+}
+
+func (d *hidDriver) press(k key.Code) error {
+	if bit := modifierBit(k); bit != 0 {
+		d.modifiers |= bit
+		return d.report()
+	}
+	for _, p := range d.pressed {
+		if p == k {
+			return nil
+		}
+	}
+	if len(d.pressed) == len(HIDKeyboardReport{}.Keys) {
+		return fmt.Errorf("cannot press %s: too many keys are held down", k)
+	}
+	d.pressed = append(d.pressed, k)
+	return d.report()
+}
+
+func (d *hidDriver) release(k key.Code) error {
+	if bit := modifierBit(k); bit != 0 {
+		d.modifiers &^= bit
+		return d.report()
+	}
+	for i, p := range d.pressed {
+		if p == k {
+			d.pressed = append(d.pressed[:i], d.pressed[i+1:]...)
+			return d.report()
+		}
+	}
+	return nil
+}
+
+func (d *hidDriver) sendKeyCode(k key.Code, shift bool, action KeyAction) error {
+	if action&(KeyOn|KeyPress) != 0 {
+		if shift {
+			if err := d.press(key.CodeLeftShift); err != nil {
+				return err
+			}
+		}
+		if err := d.press(k); err != nil {
+			return err
+		}
+	}
+	if action&(KeyOff|KeyPress) != 0 {
+		if err := d.release(k); err != nil {
+			return err
+		}
+		if shift {
+			if err := d.release(key.CodeLeftShift); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush does nothing here, reports are sent as the keys are typed.
+func (d *hidDriver) Flush() error {
+	return nil
+}
+
+func (d *hidDriver) SendKey(k rune, action KeyAction) error {
+	keyShift := unicode.IsUpper(k) || strings.ContainsRune(shiftedChars, k)
+	keyCode, ok := d.scancodeMap[k]
+	if !ok {
+		return fmt.Errorf("no key types the character %q", k)
+	}
+	log.Printf("Sending char '%c', code %s, shift %v", k, keyCode, keyShift)
+	return d.sendKeyCode(keyCode, keyShift, action)
+}
+
+func (d *hidDriver) SendSpecial(special string, action KeyAction) error {
+	keyCode, ok := d.specialMap[special]
+	if !ok {
+		return fmt.Errorf("special %s not found.", special)
+	}
+	log.Printf("Special code '<%s>' found, replacing with: %s", special, keyCode)
+	return d.sendKeyCode(keyCode, false, action)
+}
+
+// Click moves the pointer to x, y and clicks button. x and y are fractions
+// of the width and height of the screen, from 0 to 1, the origin being the
+// top left corner. It fails if the keyboard of the driver is not a
+// HIDTablet.
+func (d *hidDriver) Click(x, y float64, button HIDButton) error {
+	tablet, ok := d.keyboard.(HIDTablet)
+	if !ok {
+		return fmt.Errorf("the VM has no absolute pointer device to click with")
+	}
+	if x < 0 || x > 1 || y < 0 || y > 1 {
+		return fmt.Errorf("cannot click at %g,%g: the coordinates must be between 0 and 1", x, y)
+	}
+
+	r := HIDTabletReport{
+		X: uint16(x * HIDTabletMax),
+		Y: uint16(y * HIDTabletMax),
+	}
+	log.Printf("Clicking at %d,%d with buttons %b", r.X, r.Y, button)
+	for _, buttons := range []HIDButton{0, button, 0} {
+		r.Buttons = buttons
+		if err := tablet.SendTabletReport(r); err != nil {
+			return err
+		}
+		time.Sleep(d.interval)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type recordingHID struct {
+	keyboard [][]byte
+	tablet   [][]byte
+}
+
+func (r *recordingHID) SendKeyboardReport(report HIDKeyboardReport) error {
+	r.keyboard = append(r.keyboard, report.Bytes())
+	return nil
+}
+
+type recordingTablet struct {
+	recordingHID
+}
+
+func (r *recordingTablet) SendTabletReport(report HIDTabletReport) error {
+	r.tablet = append(r.tablet, report.Bytes())
+	return nil
+}
+
+func TestHIDDriver(t *testing.T) {
+	tc := []struct {
+		command  string
+		expected [][]byte
+	}{
+		{
+			"a",
+			[][]byte{
+				{0, 0, 0x04, 0, 0, 0, 0, 0},
+				{0, 0, 0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			"A",
+			[][]byte{
+				{0x02, 0, 0, 0, 0, 0, 0, 0},
+				{0x02, 0, 0x04, 0, 0, 0, 0, 0},
+				{0x02, 0, 0, 0, 0, 0, 0, 0},
+				{0, 0, 0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			"<leftCtrlOn><leftAltOn><del><leftAltOff><leftCtrlOff>",
+			[][]byte{
+				{0x01, 0, 0, 0, 0, 0, 0, 0},
+				{0x05, 0, 0, 0, 0, 0, 0, 0},
+				{0x05, 0, 0x4c, 0, 0, 0, 0, 0},
+				{0x05, 0, 0, 0, 0, 0, 0, 0},
+				{0x01, 0, 0, 0, 0, 0, 0, 0},
+				{0, 0, 0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			"<aOn><bOn><aOff><bOff>",
+			[][]byte{
+				{0, 0, 0x04, 0, 0, 0, 0, 0},
+				{0, 0, 0x04, 0x05, 0, 0, 0, 0},
+				{0, 0, 0x05, 0, 0, 0, 0, 0},
+				{0, 0, 0, 0, 0, 0, 0, 0},
+			},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.command, func(t *testing.T) {
+			hid := new(recordingHID)
+			d := NewHIDDriver(hid, time.Nanosecond)
+			seq, err := GenerateExpressionSequence(tt.command)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if err := seq.Do(context.Background(), d); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if diff := cmp.Diff(tt.expected, hid.keyboard); diff != "" {
+				t.Fatalf("unexpected reports: %s", diff)
+			}
+		})
+	}
+}
+
+func TestHIDDriver_unknownChar(t *testing.T) {
+	d := NewHIDDriver(new(recordingHID), time.Nanosecond)
+	if err := d.SendKey('é', KeyPress); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestHIDDriver_Click(t *testing.T) {
+	if err := NewHIDDriver(new(recordingHID), time.Nanosecond).Click(0.5, 0.5, HIDButtonLeft); err == nil {
+		t.Fatal("should fail without a tablet")
+	}
+
+	tablet := new(recordingTablet)
+	d := NewHIDDriver(tablet, time.Nanosecond)
+	if err := d.Click(1.5, 0, HIDButtonLeft); err == nil {
+		t.Fatal("should fail out of the screen")
+	}
+	if err := d.Click(0.5, 1, HIDButtonLeft); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := [][]byte{
+		{0, 0xff, 0x3f, 0xff, 0x7f, 0},
+		{1, 0xff, 0x3f, 0xff, 0x7f, 0},
+		{0, 0xff, 0x3f, 0xff, 0x7f, 0},
+	}
+	if diff := cmp.Diff(expected, tablet.tablet); diff != "" {
+		t.Fatalf("unexpected reports: %s", diff)
+	}
+}
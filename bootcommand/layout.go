@@ -0,0 +1,404 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Special keys found on ISO and JIS keyboards but not on US ones. They can't
+// be typed from a boot command, keyboard layouts use them to type the
+// characters they carry.
+const (
+	// SpecialNonUSBackslash is the key between left shift and Z on ISO
+	// keyboards.
+	SpecialNonUSBackslash = "nonusbackslash"
+	// SpecialInternational1 is the "ro" key of JIS keyboards, between slash
+	// and right shift.
+	SpecialInternational1 = "international1"
+	// SpecialInternational3 is the yen key of JIS keyboards, between equal
+	// and backspace.
+	SpecialInternational3 = "international3"
+)
+
+// LayoutKey describes the keys to press to type a character.
+type LayoutKey struct {
+	// Char is the character typed by the key on a US keyboard, without
+	// shift.
+	Char rune
+	// Special is the name of the special key to press, for the keys that
+	// don't exist on US keyboards. Only one of Char and Special is set.
+	Special string
+	// Shift and AltGr tell whether the shift and AltGr (right alt) keys must
+	// be held.
+	Shift bool
+	AltGr bool
+	// Dead is set for the dead keys, that only type their character when
+	// followed by a space.
+	Dead bool
+}
+
+// KeyboardLayout translates the characters of a boot command into the keys
+// to press for the guest to type them with its keyboard layout. Only the
+// characters that are typed differently than on a US keyboard are listed.
+type KeyboardLayout struct {
+	Name string
+	Keys map[rune]LayoutKey
+}
+
+// usShifted is what the keys of a US keyboard type with shift held.
+var usShifted = func() map[rune]rune {
+	m := map[rune]rune{}
+	for i, r := range "`1234567890-=[]\\;',./" {
+		m[r] = []rune("~!@#$%^&*()_+{}|:\"<>?")[i]
+	}
+	for r := 'a'; r <= 'z'; r++ {
+		m[r] = r - 'a' + 'A'
+	}
+	return m
+}()
+
+// keyboardLayouts are the built-in layouts, written in the keymap file
+// format, see LoadKeyboardLayout.
+var keyboardLayouts = map[string]string{
+	"us": ``,
+	"de": `
+^ ` + "`" + ` dead
+° ` + "`" + ` shift
+" 2 shift
+² 2 altgr
+§ 3 shift
+³ 3 altgr
+& 6 shift
+/ 7 shift
+{ 7 altgr
+( 8 shift
+[ 8 altgr
+) 9 shift
+] 9 altgr
+= 0 shift
+} 0 altgr
+ß -
+? - shift
+\ - altgr
+´ = dead
+` + "`" + ` = shift dead
+@ q altgr
+€ e altgr
+z y
+Z y shift
+ü [
+Ü [ shift
++ ]
+* ] shift
+~ ] altgr dead
+ö ;
+Ö ; shift
+ä '
+Ä ' shift
+# \
+' \ shift
+< nonusbackslash
+> nonusbackslash shift
+| nonusbackslash altgr
+y z
+Y z shift
+µ m altgr
+; , shift
+: . shift
+- /
+_ / shift
+`,
+	"fr": `
+² ` + "`" + `
+& 1
+1 1 shift
+é 2
+2 2 shift
+~ 2 altgr dead
+" 3
+3 3 shift
+# 3 altgr
+' 4
+4 4 shift
+{ 4 altgr
+( 5
+5 5 shift
+[ 5 altgr
+- 6
+6 6 shift
+| 6 altgr
+è 7
+7 7 shift
+` + "`" + ` 7 altgr dead
+_ 8
+8 8 shift
+\ 8 altgr
+ç 9
+9 9 shift
+^ 9 altgr
+à 0
+0 0 shift
+@ 0 altgr
+) -
+° - shift
+] - altgr
++ = shift
+} = altgr
+a q
+A q shift
+z w
+Z w shift
+€ e altgr
+¨ [ shift dead
+$ ]
+£ ] shift
+¤ ] altgr
+q a
+Q a shift
+m ;
+M ; shift
+ù '
+% ' shift
+* \
+µ \ shift
+< nonusbackslash
+> nonusbackslash shift
+w z
+W z shift
+, m
+? m shift
+; ,
+. , shift
+: .
+/ . shift
+! /
+§ / shift
+`,
+	"uk": `
+¬ ` + "`" + ` shift
+" 2 shift
+£ 3 shift
+€ 4 altgr
+@ ' shift
+# \
+~ \ shift
+\ nonusbackslash
+| nonusbackslash shift
+`,
+	"jp": `
+" 2 shift
+& 6 shift
+' 7 shift
+( 8 shift
+) 9 shift
+= - shift
+^ =
+~ = shift
+¥ international3
+| international3 shift
+@ [
+` + "`" + ` [ shift
+[ ]
+{ ] shift
++ ; shift
+: '
+* ' shift
+] \
+} \ shift
+\ international1
+_ international1 shift
+`,
+}
+
+// KeyboardLayouts returns the names of the built-in keyboard layouts.
+func KeyboardLayouts() []string {
+	names := make([]string, 0, len(keyboardLayouts))
+	for name := range keyboardLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadKeyboardLayout returns the built-in layout called name, or reads the
+// keymap file at that path.
+//
+// Keymap files list, one per line, a character of the layout followed by the
+// key typing it: either what the key types on a US keyboard or the name of a
+// special key. Characters can also be written as U+XXXX. Then the optional
+// `shift`, `altgr` and `dead` flags tell which modifiers are needed and if the
+// key is a dead key. Empty lines and lines starting with "//" are ignored.
+// For example, a German keyboard would have:
+//
+//	z y
+//	Z y shift
+//	@ q altgr
+//	< nonusbackslash
+func LoadKeyboardLayout(name string) (*KeyboardLayout, error) {
+	if keymap, ok := keyboardLayouts[strings.ToLower(name)]; ok {
+		return parseKeymap(strings.ToLower(name), keymap)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown keyboard layout %q, expected one of %s or a keymap file: %s",
+			name, strings.Join(KeyboardLayouts(), ", "), err)
+	}
+	return parseKeymap(name, string(b))
+}
+
+func parseKeymap(name, keymap string) (*KeyboardLayout, error) {
+	layout := &KeyboardLayout{Name: name, Keys: map[rune]LayoutKey{}}
+
+	scanner := bufio.NewScanner(strings.NewReader(keymap))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "//") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected a character and a key, got %q", name, line, text)
+		}
+
+		char, err := parseKeymapChar(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", name, line, err)
+		}
+
+		var key LayoutKey
+		if utf8.RuneCountInString(fields[1]) == 1 {
+			key.Char, _ = utf8.DecodeRuneInString(fields[1])
+			if _, ok := usShifted[key.Char]; !ok {
+				return nil, fmt.Errorf("%s:%d: %q is not typed by a key of a US keyboard without shift", name, line, fields[1])
+			}
+		} else {
+			key.Special = strings.ToLower(fields[1])
+			if _, ok := usbSpecialMap()[key.Special]; !ok {
+				return nil, fmt.Errorf("%s:%d: unknown key %q", name, line, fields[1])
+			}
+		}
+
+		for _, flag := range fields[2:] {
+			switch strings.ToLower(flag) {
+			case "shift":
+				key.Shift = true
+			case "altgr":
+				key.AltGr = true
+			case "dead":
+				key.Dead = true
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown flag %q, expected shift, altgr or dead", name, line, flag)
+			}
+		}
+
+		if _, ok := layout.Keys[char]; ok {
+			return nil, fmt.Errorf("%s:%d: %q is defined twice", name, line, char)
+		}
+		layout.Keys[char] = key
+	}
+	return layout, scanner.Err()
+}
+
+func parseKeymapChar(s string) (rune, error) {
+	if utf8.RuneCountInString(s) == 1 {
+		r, _ := utf8.DecodeRuneInString(s)
+		return r, nil
+	}
+	if hex, ok := strings.CutPrefix(strings.ToUpper(s), "U+"); ok {
+		r, err := strconv.ParseUint(hex, 16, 32)
+		if err == nil && utf8.ValidRune(rune(r)) {
+			return rune(r), nil
+		}
+	}
+	return 0, fmt.Errorf("expected a single character or U+XXXX, got %q", s)
+}
+
+type layoutDriver struct {
+	BCDriver
+	layout *KeyboardLayout
+}
+
+// NewLayoutDriver wraps a scancode driver, like the PC-XT, USB or HID ones,
+// so that the characters of boot commands are typed correctly on a guest
+// using layout. The VNC driver must not be wrapped since VNC sends
+// characters rather than keys, the layout being handled by the VNC server.
+func NewLayoutDriver(driver BCDriver, layout *KeyboardLayout) BCDriver {
+	if layout == nil || len(layout.Keys) == 0 {
+		return driver
+	}
+	return &layoutDriver{BCDriver: driver, layout: layout}
+}
+
+func (d *layoutDriver) Unwrap() BCDriver {
+	return d.BCDriver
+}
+
+func (d *layoutDriver) SendKey(char rune, action KeyAction) error {
+	key, ok := d.layout.Keys[char]
+	if !ok {
+		return d.BCDriver.SendKey(char, action)
+	}
+	log.Printf("Typing '%c' with the %s keyboard layout: %+v", char, d.layout.Name, key)
+
+	if key.Special == "" && !key.AltGr && !key.Dead {
+		return d.sendKey(key, action)
+	}
+
+	if action&(KeyOn|KeyPress) != 0 {
+		if key.AltGr {
+			if err := d.BCDriver.SendSpecial("rightalt", KeyOn); err != nil {
+				return err
+			}
+		}
+		if err := d.sendKey(key, KeyOn); err != nil {
+			return err
+		}
+	}
+	if action&(KeyOff|KeyPress) != 0 {
+		if err := d.sendKey(key, KeyOff); err != nil {
+			return err
+		}
+		if key.AltGr {
+			if err := d.BCDriver.SendSpecial("rightalt", KeyOff); err != nil {
+				return err
+			}
+		}
+		if key.Dead {
+			return d.BCDriver.SendSpecial("spacebar", KeyPress)
+		}
+	}
+	return nil
+}
+
+func (d *layoutDriver) sendKey(key LayoutKey, action KeyAction) error {
+	if key.Special == "" {
+		char := key.Char
+		if key.Shift {
+			char = usShifted[char]
+		}
+		return d.BCDriver.SendKey(char, action)
+	}
+
+	if key.Shift && action == KeyOn {
+		if err := d.BCDriver.SendSpecial("leftshift", KeyOn); err != nil {
+			return err
+		}
+	}
+	if err := d.BCDriver.SendSpecial(key.Special, action); err != nil {
+		return err
+	}
+	if key.Shift && action == KeyOff {
+		return d.BCDriver.SendSpecial("leftshift", KeyOff)
+	}
+	return nil
+}
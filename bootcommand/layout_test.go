@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type recordingDriver struct {
+	events []string
+}
+
+func (d *recordingDriver) SendKey(k rune, action KeyAction) error {
+	d.events = append(d.events, fmt.Sprintf("%c %s", k, action))
+	return nil
+}
+
+func (d *recordingDriver) SendSpecial(special string, action KeyAction) error {
+	d.events = append(d.events, fmt.Sprintf("<%s> %s", special, action))
+	return nil
+}
+
+func (d *recordingDriver) Flush() error { return nil }
+
+func TestLayoutDriver(t *testing.T) {
+	tc := []struct {
+		layout   string
+		command  string
+		expected []string
+	}{
+		{"de", "az", []string{"a Press", "y Press"}},
+		{"de", "Z", []string{"Y Press"}},
+		{"de", "@", []string{"<rightalt> On", "q On", "q Off", "<rightalt> Off"}},
+		{"de", "|", []string{
+			"<rightalt> On", "<nonusbackslash> On", "<nonusbackslash> Off", "<rightalt> Off",
+		}},
+		{"de", ">", []string{
+			"<leftshift> On", "<nonusbackslash> On", "<nonusbackslash> Off", "<leftshift> Off",
+		}},
+		{"de", "^", []string{"` On", "` Off", "<spacebar> Press"}},
+		{"de", "<zOn><zOff>", []string{"y On", "y Off"}},
+		{"fr", "1&", []string{"! Press", "1 Press"}},
+		{"fr", "/", []string{"> Press"}},
+		{"uk", "\"#", []string{"@ Press", "\\ Press"}},
+		{"jp", "_", []string{
+			"<leftshift> On", "<international1> On", "<international1> Off", "<leftshift> Off",
+		}},
+		{"us", "z@", []string{"z Press", "@ Press"}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.layout+" "+tt.command, func(t *testing.T) {
+			layout, err := LoadKeyboardLayout(tt.layout)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			rec := new(recordingDriver)
+			seq, err := GenerateExpressionSequence(tt.command)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if err := seq.Do(context.Background(), NewLayoutDriver(rec, layout)); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if diff := cmp.Diff(tt.expected, rec.events); diff != "" {
+				t.Fatalf("unexpected keys: %s", diff)
+			}
+		})
+	}
+}
+
+func TestLayoutDriver_pcxt(t *testing.T) {
+	layout, err := LoadKeyboardLayout("de")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var codes []string
+	sendCodes := func(c []string) error {
+		codes = c
+		return nil
+	}
+	d := NewLayoutDriver(NewPCXTDriver(sendCodes, -1, time.Nanosecond), layout)
+	seq, err := GenerateExpressionSequence("z<")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []string{"15", "95", "56", "d6"}
+	if diff := cmp.Diff(expected, codes); diff != "" {
+		t.Fatalf("unexpected scancodes: %s", diff)
+	}
+}
+
+func TestLoadKeyboardLayout_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keymap")
+	err := os.WriteFile(path, []byte("// A test keymap\n\nz y\nU+00E9 2 altgr dead\n\n< nonusbackslash shift\n"), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	layout, err := LoadKeyboardLayout(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[rune]LayoutKey{
+		'z': {Char: 'y'},
+		'é': {Char: '2', AltGr: true, Dead: true},
+		'<': {Special: SpecialNonUSBackslash, Shift: true},
+	}
+	if diff := cmp.Diff(expected, layout.Keys); diff != "" {
+		t.Fatalf("unexpected keys: %s", diff)
+	}
+}
+
+func TestLoadKeyboardLayout_invalid(t *testing.T) {
+	for _, keymap := range []string{
+		"z",
+		"z Y",
+		"z y ctrl",
+		"z nokey",
+		"zz y",
+		"z y\nz x",
+	} {
+		if _, err := parseKeymap("test", keymap); err == nil {
+			t.Errorf("%q should have error", keymap)
+		}
+	}
+
+	if _, err := LoadKeyboardLayout("klingon"); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -18,7 +18,7 @@ type SendCodeFunc func([]string) error
 type scMap map[string]*scancode
 
 type pcXTDriver struct {
-	interval    time.Duration
+	keySpeed
 	sendImpl    SendCodeFunc
 	specialMap  scMap
 	scancodeMap map[rune]byte
@@ -94,6 +94,9 @@ func NewPCXTDriver(send SendCodeFunc, chunkSize int, interval time.Duration) *pc
 	sMap["spacebar"] = &scancode{[]string{"39"}, []string{"b9"}}
 	sMap["tab"] = &scancode{[]string{"0f"}, []string{"8f"}}
 	sMap["up"] = &scancode{[]string{"e0", "48"}, []string{"e0", "c8"}}
+	sMap[SpecialNonUSBackslash] = &scancode{[]string{"56"}, []string{"d6"}}
+	sMap[SpecialInternational1] = &scancode{[]string{"73"}, []string{"f3"}}
+	sMap[SpecialInternational3] = &scancode{[]string{"7d"}, []string{"fd"}}
 
 	scancodeIndex := make(map[string]byte)
 	scancodeIndex["1234567890-="] = 0x02
@@ -118,7 +121,7 @@ func NewPCXTDriver(send SendCodeFunc, chunkSize int, interval time.Duration) *pc
 	}
 
 	return &pcXTDriver{
-		interval:          keyInterval,
+		keySpeed:          newKeySpeed(keyInterval),
 		sendImpl:          send,
 		specialMap:        sMap,
 		scancodeMap:       scancodeMap,
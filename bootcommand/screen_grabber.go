@@ -0,0 +1,31 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import "context"
+
+// ScreenGrabber reads the screen of the VM, allowing boot commands to wait
+// for some text to be displayed with `<wait_for_text: '...'>`. Builders can
+// implement it from a VNC framebuffer, a hypervisor screenshot API followed
+// by OCR, a text mode console buffer, etc.
+type ScreenGrabber interface {
+	// ScreenText returns the text currently displayed on the screen of the
+	// VM.
+	ScreenText(ctx context.Context) (string, error)
+}
+
+type screenGrabberDriver struct {
+	BCDriver
+	ScreenGrabber
+}
+
+// WithScreenGrabber adds grabber to driver, for builders using one of the
+// drivers of this package to support `<wait_for_text>`.
+func WithScreenGrabber(driver BCDriver, grabber ScreenGrabber) BCDriver {
+	return &screenGrabberDriver{BCDriver: driver, ScreenGrabber: grabber}
+}
+
+func (d *screenGrabberDriver) Unwrap() BCDriver {
+	return d.BCDriver
+}
@@ -0,0 +1,337 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// serialOutputSize is how much of the output of the console is kept for
+// `<wait_for_text>`.
+const serialOutputSize = 64 * 1024
+
+type serialDriver struct {
+	w io.Writer
+	keySpeed
+	specialMap map[string]string
+
+	// The modifiers currently held down.
+	ctrl, alt, shift bool
+
+	mu     sync.Mutex
+	output []byte
+	// readErr is set when reading the output of the console stopped.
+	readErr error
+}
+
+// NewSerialDriver creates a boot command driver typing on a serial console,
+// like a hypervisor serial port or a BMC serial over LAN session. Special keys
+// are sent as the sequences of a VT100 terminal, held control keys make
+// control characters and held alt keys prefix the keys with escape, the way
+// a terminal emulator would. Keys with no equivalent on a terminal, like
+// super, are ignored.
+//
+// When conn is also an io.Reader, the output of the console is read in the
+// background and the driver implements ScreenGrabber, which makes
+// `<wait_for_text>` available. See DialSerial to connect to a console.
+func NewSerialDriver(conn io.Writer, interval time.Duration) *serialDriver {
+	// We delay (default 100ms) between each key to allow for CPU or network
+	// latency. See PackerKeyEnv for tuning.
+	keyInterval := PackerKeyDefault
+	if delay, err := time.ParseDuration(os.Getenv(PackerKeyEnv)); err == nil {
+		keyInterval = delay
+	}
+	// override interval based on builder-specific override.
+	if interval > time.Duration(0) {
+		keyInterval = interval
+	}
+
+	sMap := map[string]string{
+		"bs":       "\x7f",
+		"del":      "\x1b[3~",
+		"down":     "\x1b[B",
+		"end":      "\x1b[F",
+		"enter":    "\r",
+		"esc":      "\x1b",
+		"f1":       "\x1bOP",
+		"f2":       "\x1bOQ",
+		"f3":       "\x1bOR",
+		"f4":       "\x1bOS",
+		"f5":       "\x1b[15~",
+		"f6":       "\x1b[17~",
+		"f7":       "\x1b[18~",
+		"f8":       "\x1b[19~",
+		"f9":       "\x1b[20~",
+		"f10":      "\x1b[21~",
+		"f11":      "\x1b[23~",
+		"f12":      "\x1b[24~",
+		"home":     "\x1b[H",
+		"insert":   "\x1b[2~",
+		"left":     "\x1b[D",
+		"pagedown": "\x1b[6~",
+		"pageup":   "\x1b[5~",
+		"return":   "\r",
+		"right":    "\x1b[C",
+		"spacebar": " ",
+		"tab":      "\t",
+		"up":       "\x1b[A",
+	}
+
+	d := &serialDriver{
+		w:          conn,
+		keySpeed:   newKeySpeed(keyInterval),
+		specialMap: sMap,
+	}
+	if r, ok := conn.(io.Reader); ok {
+		go d.read(r)
+	}
+	return d
+}
+
+func (d *serialDriver) read(r io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		d.mu.Lock()
+		d.output = append(d.output, buf[:n]...)
+		if len(d.output) > serialOutputSize {
+			d.output = d.output[len(d.output)-serialOutputSize:]
+		}
+		if err != nil {
+			d.readErr = err
+		}
+		d.mu.Unlock()
+		if err != nil {
+			log.Printf("[DEBUG] Stopped reading the serial console: %s", err)
+			return
+		}
+	}
+}
+
+// ScreenText returns the last output of the console, without the escape
+// sequences.
+func (d *serialDriver) ScreenText(context.Context) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	text := stripEscapeSequences(string(d.output))
+	if d.readErr != nil && d.readErr != io.EOF {
+		return text, fmt.Errorf("error reading the serial console: %s", d.readErr)
+	}
+	return text, nil
+}
+
+// stripEscapeSequences removes the CSI and other escape sequences, used by
+// consoles to move the cursor and set colors, from s.
+func stripEscapeSequences(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\x1b' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i < len(s) && s[i] == '[' {
+			// Parameters and intermediate bytes, up to a final byte.
+			for i++; i < len(s) && (s[i] < 0x40 || s[i] > 0x7e); i++ {
+			}
+		}
+	}
+	return b.String()
+}
+
+func (d *serialDriver) send(s string) error {
+	if d.alt {
+		s = "\x1b" + s
+	}
+	log.Printf("Sending %q to the serial console", s)
+	if _, err := io.WriteString(d.w, s); err != nil {
+		return err
+	}
+	time.Sleep(d.interval)
+	return nil
+}
+
+// Flush does nothing here, keys are sent as they are typed.
+func (d *serialDriver) Flush() error {
+	return nil
+}
+
+func (d *serialDriver) SendKey(key rune, action KeyAction) error {
+	// A serial line has no key releases.
+	if action == KeyOff {
+		return nil
+	}
+	if d.shift {
+		key = unicode.ToUpper(key)
+	}
+	if d.ctrl {
+		k := unicode.ToUpper(key)
+		if k < '@' || k > '_' {
+			return fmt.Errorf("ctrl+%c cannot be typed on a serial console", key)
+		}
+		key = k & 0x1f
+	}
+	return d.send(string(key))
+}
+
+func (d *serialDriver) SendSpecial(special string, action KeyAction) error {
+	var modifier *bool
+	switch special {
+	case "leftctrl", "rightctrl":
+		modifier = &d.ctrl
+	case "leftalt", "rightalt":
+		modifier = &d.alt
+	case "leftshift", "rightshift":
+		modifier = &d.shift
+	case "leftsuper", "rightsuper", "menu":
+		log.Printf("Ignoring <%s>, it cannot be typed on a serial console", special)
+		return nil
+	}
+	if modifier != nil {
+		switch action {
+		case KeyOn:
+			*modifier = true
+		case KeyOff:
+			*modifier = false
+		}
+		return nil
+	}
+
+	seq, ok := d.specialMap[special]
+	if !ok {
+		return fmt.Errorf("special %s not found.", special)
+	}
+	if action == KeyOff {
+		return nil
+	}
+	return d.send(seq)
+}
+
+// DialSerial connects to a serial console, for NewSerialDriver. address is
+// either:
+//
+//   - `tcp://host:port` for a raw TCP serial port, like the QEMU
+//     `-serial tcp:...,server` option.
+//   - `telnet://host:port` for a serial port served over telnet.
+//   - `unix:///path/to/socket` for a unix socket, like the QEMU
+//     `-serial unix:...,server` option.
+//   - the path to a character device, like a pty or a USB serial adapter.
+func DialSerial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme == "" {
+		return os.OpenFile(address, os.O_RDWR, 0)
+	}
+
+	var dialer net.Dialer
+	switch u.Scheme {
+	case "tcp":
+		return dialer.DialContext(ctx, "tcp", u.Host)
+	case "telnet":
+		conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &telnetConn{Conn: conn}, nil
+	case "unix":
+		return dialer.DialContext(ctx, "unix", u.Path)
+	}
+	return nil, fmt.Errorf("unsupported serial console address %q, expected tcp://, telnet://, unix:// or a device path", address)
+}
+
+// Telnet commands.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+)
+
+// telnetConn is a telnet connection carrying a console. It escapes the data
+// sent and strips the telnet commands from the data received, declining the
+// options proposed by the server.
+type telnetConn struct {
+	net.Conn
+
+	// The command being read, when it spans several reads.
+	cmd []byte
+}
+
+func (c *telnetConn) Write(p []byte) (int, error) {
+	if _, err := c.Conn.Write(bytes.ReplaceAll(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *telnetConn) Read(p []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(p)
+		data := c.filter(p[:n])
+		copy(p, data)
+		if len(data) > 0 || err != nil {
+			return len(data), err
+		}
+	}
+}
+
+// filter returns the data of p, answering the commands it contains.
+func (c *telnetConn) filter(p []byte) []byte {
+	data := p[:0]
+	for _, b := range p {
+		if len(c.cmd) == 0 {
+			if b == telnetIAC {
+				c.cmd = append(c.cmd, b)
+			} else {
+				data = append(data, b)
+			}
+			continue
+		}
+
+		c.cmd = append(c.cmd, b)
+		switch cmd := c.cmd[1]; {
+		case cmd == telnetIAC:
+			// An escaped 255 data byte.
+			data = append(data, telnetIAC)
+			c.cmd = c.cmd[:0]
+		case cmd == telnetSB:
+			// Sub-negotiations go on until IAC SE.
+			if n := len(c.cmd); n >= 4 && c.cmd[n-2] == telnetIAC && b == telnetSE {
+				c.cmd = c.cmd[:0]
+			}
+		case cmd >= telnetWILL && cmd <= telnetDONT:
+			if len(c.cmd) < 3 {
+				continue
+			}
+			var reply byte
+			switch cmd {
+			case telnetWILL:
+				reply = telnetDONT
+			case telnetDO:
+				reply = telnetWONT
+			}
+			if reply != 0 {
+				// Write errors will show up when typing.
+				c.Conn.Write([]byte{telnetIAC, reply, c.cmd[2]})
+			}
+			c.cmd = c.cmd[:0]
+		default:
+			c.cmd = c.cmd[:0]
+		}
+	}
+	return data
+}
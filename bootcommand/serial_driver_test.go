@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package bootcommand
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialDriver(t *testing.T) {
+	tc := []struct {
+		command  string
+		expected string
+	}{
+		{"ls -l<enter>", "ls -l\r"},
+		{"<leftCtrlOn>c<leftCtrlOff>", "\x03"},
+		{"<leftAltOn>x<leftAltOff><up>", "\x1bx\x1b[A"},
+		{"<leftShiftOn>ab<leftShiftOff>c", "ABc"},
+		{"<f2><leftSuper><aOn><aOff>", "\x1bOQa"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.command, func(t *testing.T) {
+			var out bytes.Buffer
+			// Hide the Read method of the buffer, for the driver not to read
+			// its output.
+			d := NewSerialDriver(struct{ io.Writer }{&out}, time.Nanosecond)
+			seq, err := GenerateExpressionSequence(tt.command)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if err := seq.Do(context.Background(), d); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestSerialDriver_waitForText(t *testing.T) {
+	defer func(i time.Duration) { WaitForTextInterval = i }(WaitForTextInterval)
+	WaitForTextInterval = time.Millisecond
+
+	console, vm := net.Pipe()
+	defer console.Close()
+	go func() {
+		defer vm.Close()
+		vm.Write([]byte("\x1b[2J\x1b[1;1HBoot\x1b[0m: "))
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(vm, buf); err != nil {
+			return
+		}
+		vm.Write([]byte("\r\nlogin: "))
+		io.Copy(io.Discard, vm)
+	}()
+
+	d := NewSerialDriver(console, time.Nanosecond)
+	seq, err := GenerateExpressionSequence("<wait_for_text: 'Boot:', 1s>text<enter><wait_for_text: 'login:', 1s>")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestDialSerial_telnet(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+
+	// The server proposes an option and sends a sub-negotiation among the
+	// data, and reports what it received from the client.
+	received := make(chan []byte, 2)
+	go func() {
+		server, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer server.Close()
+		server.Write([]byte{'a', telnetIAC, telnetWILL, 1, 'b', telnetIAC, telnetIAC})
+		reply := make([]byte, 3)
+		io.ReadFull(server, reply)
+		received <- reply
+		server.Write([]byte{telnetIAC, telnetSB, 24, 1, telnetIAC, telnetSE, 'c'})
+		written := make([]byte, 3)
+		io.ReadFull(server, written)
+		received <- written
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := DialSerial(ctx, "telnet://"+l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+	conn.(net.Conn).SetDeadline(time.Now().Add(10 * time.Second))
+
+	readN := func(n int) []byte {
+		var data []byte
+		buf := make([]byte, 16)
+		for len(data) < n {
+			m, err := conn.Read(buf)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			data = append(data, buf[:m]...)
+		}
+		return data
+	}
+
+	assert.Equal(t, []byte{'a', 'b', telnetIAC}, readN(3))
+	assert.Equal(t, []byte{telnetIAC, telnetDONT, 1}, <-received)
+	assert.Equal(t, []byte{'c'}, readN(1))
+
+	_, err = conn.Write([]byte{telnetIAC, 'd'})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{telnetIAC, telnetIAC, 'd'}, <-received)
+}
@@ -18,8 +18,8 @@ import (
 type SendUsbScanCodes func(k key.Code, down bool) error
 
 type usbDriver struct {
-	sendImpl    SendUsbScanCodes
-	interval    time.Duration
+	sendImpl SendUsbScanCodes
+	keySpeed
 	specialMap  map[string]key.Code
 	scancodeMap map[rune]key.Code
 }
@@ -36,7 +36,17 @@ func NewUSBDriver(send SendUsbScanCodes, interval time.Duration) *usbDriver {
 		keyInterval = interval
 	}
 
-	special := map[string]key.Code{
+	return &usbDriver{
+		sendImpl:    send,
+		specialMap:  usbSpecialMap(),
+		keySpeed:    newKeySpeed(keyInterval),
+		scancodeMap: usbScancodeMap(),
+	}
+}
+
+// usbSpecialMap returns the USB HID usage of the special keys.
+func usbSpecialMap() map[string]key.Code {
+	return map[string]key.Code{
 		"enter":      key.CodeReturnEnter,
 		"return":     key.CodeReturnEnter,
 		"esc":        key.CodeEscape,
@@ -73,8 +83,18 @@ func NewUSBDriver(send SendUsbScanCodes, interval time.Duration) *usbDriver {
 		"leftsuper":  key.CodeLeftGUI,
 		"rightsuper": key.CodeRightGUI,
 		"spacebar":   key.CodeSpacebar,
+
+		// Keys of ISO and JIS keyboards, see layout.go.
+		SpecialNonUSBackslash: key.Code(0x64),
+		SpecialInternational1: key.Code(0x87),
+		SpecialInternational3: key.Code(0x89),
 	}
+}
 
+// usbScancodeMap returns the USB HID usage of the key typing each character
+// on a US keyboard, shift being needed for the upper case letters and for
+// shiftedChars.
+func usbScancodeMap() map[rune]key.Code {
 	scancodeIndex := make(map[string]key.Code)
 	scancodeIndex["abcdefghijklmnopqrstuvwxyz"] = key.CodeA
 	scancodeIndex["ABCDEFGHIJKLMNOPQRSTUVWXYZ"] = key.CodeA
@@ -92,13 +112,7 @@ func NewUSBDriver(send SendUsbScanCodes, interval time.Duration) *usbDriver {
 			scancodeMap[r] = start + key.Code(i)
 		}
 	}
-
-	return &usbDriver{
-		sendImpl:    send,
-		specialMap:  special,
-		interval:    keyInterval,
-		scancodeMap: scancodeMap,
-	}
+	return scancodeMap
 }
 
 func (d *usbDriver) keyEvent(k key.Code, down bool) error {
@@ -4,12 +4,16 @@
 package bootcommand
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
 	"time"
 	"unicode"
+
+	"github.com/hashicorp/packer-plugin-sdk/retry"
 )
 
 const KeyLeftShift uint32 = 0xFFE1
@@ -18,12 +22,33 @@ type VNCKeyEvent interface {
 	KeyEvent(uint32, bool) error
 }
 
+// VNCDialFunc opens a new connection to the VNC server of the VM.
+type VNCDialFunc func() (VNCKeyEvent, error)
+
+// DefaultVNCReconnectRetries and DefaultVNCReconnectBackoff are the defaults
+// of the vnc_reconnect_retries and vnc_reconnect_backoff options.
+const (
+	DefaultVNCReconnectRetries = 5
+	DefaultVNCReconnectBackoff = time.Second
+)
+
 type vncDriver struct {
-	c          VNCKeyEvent
-	interval   time.Duration
+	c VNCKeyEvent
+	keySpeed
 	specialMap map[string]uint32
 	// keyEvent can set this error which will prevent it from continuing
 	err error
+
+	// dial, when set, is used to reconnect when sending a key event fails.
+	dial    VNCDialFunc
+	retries int
+	backoff time.Duration
+	// held are the keys currently held down, pressed again after
+	// reconnecting.
+	held map[uint32]bool
+	// ctx is the context of the boot command being typed, cancelling the
+	// reconnections.
+	ctx context.Context
 }
 
 func NewVNCDriver(c VNCKeyEvent, interval time.Duration) *vncDriver {
@@ -80,19 +105,101 @@ func NewVNCDriver(c VNCKeyEvent, interval time.Duration) *vncDriver {
 
 	return &vncDriver{
 		c:          c,
-		interval:   keyInterval,
+		keySpeed:   newKeySpeed(keyInterval),
 		specialMap: sMap,
 	}
 }
 
+// NewReconnectingVNCDriver creates a VNC driver connected with dial, that
+// calls dial again when the connection drops in the middle of a boot
+// command and resumes typing from the key event that failed. held keys are
+// pressed again on the new connection. It gives up after retries failed
+// reconnections, waiting backoff before the first one and twice as long
+// before each of the next ones. Connections implementing io.Closer are closed
+// when replaced.
+func NewReconnectingVNCDriver(dial VNCDialFunc, interval time.Duration, retries int, backoff time.Duration) (*vncDriver, error) {
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	d := NewVNCDriver(c, interval)
+	d.dial = dial
+	d.retries = retries
+	d.backoff = backoff
+	d.held = map[uint32]bool{}
+	return d, nil
+}
+
+// SetContext sets the context of the boot command being typed. Reconnecting
+// stops when it is cancelled.
+func (d *vncDriver) SetContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+// reconnect replaces the connection of the driver after it failed with err.
+func (d *vncDriver) reconnect(err error) error {
+	log.Printf("[WARN] VNC connection failed, reconnecting: %s", err)
+	if closer, ok := d.c.(io.Closer); ok {
+		closer.Close()
+	}
+
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	backoff := &retry.Backoff{InitialBackoff: d.backoff, MaxBackoff: 30 * time.Second, Multiplier: 2}
+	// Give the server some time before the first attempt too, the connection
+	// having just dropped.
+	select {
+	case <-time.After(backoff.Linear()):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return retry.Config{
+		Tries:      d.retries,
+		RetryDelay: backoff.Linear,
+	}.Run(ctx, func(context.Context) error {
+		c, err := d.dial()
+		if err != nil {
+			return err
+		}
+		for k := range d.held {
+			if err := c.KeyEvent(k, true); err != nil {
+				if closer, ok := c.(io.Closer); ok {
+					closer.Close()
+				}
+				return err
+			}
+		}
+		d.c = c
+		log.Printf("[INFO] Reconnected to VNC, resuming the boot command")
+		return nil
+	})
+}
+
 func (d *vncDriver) keyEvent(k uint32, down bool) error {
 	if d.err != nil {
 		return nil
 	}
-	if err := d.c.KeyEvent(k, down); err != nil {
+	err := d.c.KeyEvent(k, down)
+	if err != nil && d.dial != nil && d.retries > 0 {
+		if rerr := d.reconnect(err); rerr != nil {
+			err = fmt.Errorf("%s, reconnecting failed: %s", err, rerr)
+		} else {
+			err = d.c.KeyEvent(k, down)
+		}
+	}
+	if err != nil {
 		d.err = err
 		return err
 	}
+	if d.held != nil {
+		if down {
+			d.held[k] = true
+		} else {
+			delete(d.held, k)
+		}
+	}
 	time.Sleep(d.interval)
 	return nil
 }
@@ -5,6 +5,7 @@ package bootcommand
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -95,3 +96,99 @@ func Test_vncIntervalGiven(t *testing.T) {
 	d := NewVNCDriver(s, time.Duration(5000)*time.Millisecond)
 	assert.Equal(t, d.interval, time.Duration(5000)*time.Millisecond)
 }
+
+// flakySender fails once it has sent failAfter events.
+type flakySender struct {
+	sender
+	failAfter int
+	closed    bool
+}
+
+func (s *flakySender) KeyEvent(u uint32, down bool) error {
+	if len(s.e) == s.failAfter {
+		return fmt.Errorf("connection reset by peer")
+	}
+	return s.sender.KeyEvent(u, down)
+}
+
+func (s *flakySender) Close() error {
+	s.closed = true
+	return nil
+}
+
+func Test_vncReconnect(t *testing.T) {
+	conns := []*flakySender{{failAfter: 3}, {failAfter: -1}}
+	dials := 0
+	dial := func() (VNCKeyEvent, error) {
+		if dials == 1 {
+			dials++
+			return nil, fmt.Errorf("connection refused")
+		}
+		c := conns[dials/2]
+		dials++
+		return c, nil
+	}
+
+	d, err := NewReconnectingVNCDriver(dial, time.Nanosecond, 3, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	seq, err := GenerateExpressionSequence("<leftShiftOn>ab<leftShiftOff>")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := seq.Do(context.Background(), d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assert.Equal(t, 3, dials)
+	assert.True(t, conns[0].closed)
+	assert.Equal(t, []event{{0xFFE1, true}, {0x61, true}, {0x61, false}}, conns[0].e)
+	// The held shift key is pressed again on the new connection.
+	assert.Equal(t, []event{{0xFFE1, true}, {0x62, true}, {0x62, false}, {0xFFE1, false}}, conns[1].e)
+}
+
+func Test_vncReconnectExhausted(t *testing.T) {
+	dials := 0
+	dial := func() (VNCKeyEvent, error) {
+		dials++
+		if dials > 1 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return &flakySender{failAfter: 1}, nil
+	}
+
+	d, err := NewReconnectingVNCDriver(dial, time.Nanosecond, 2, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	seq, err := GenerateExpressionSequence("ab")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Error(t, seq.Do(context.Background(), d))
+	assert.Equal(t, 3, dials)
+}
+
+func Test_vncReconnectCancelled(t *testing.T) {
+	dial := func() (VNCKeyEvent, error) {
+		return &flakySender{failAfter: 1}, nil
+	}
+
+	d, err := NewReconnectingVNCDriver(dial, time.Nanosecond, 5, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	seq, err := GenerateExpressionSequence("ab")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.Error(t, seq.Do(ctx, d))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("reconnecting should stop when the context is cancelled, took %s", elapsed)
+	}
+}
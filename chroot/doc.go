@@ -20,6 +20,20 @@ more expensive because the instance used to perform the build must be kept
 running persistently in order to build images, whereas the other non-chroot
 cloud image builders start instances on-demand to build images as needed.
 
+StepMountDevice mounts the root filesystem of the image, from a partition of
+the device, an LVM logical volume or a btrfs subvolume, with the mount options
+the filesystem needs. StepMountExtra mounts the chroot mounts within it, whose
+entries can also have mount options; see ChrootMount. StepChrootNetwork sets
+up the DNS resolution within the chroot, and restores its original files once
+done. StepQemuUserStatic lets the chroot of an image of another architecture
+than the host, like arm64 on an amd64 host, run its binaries with
+qemu-user-static.
+
+The steps unmount their mounts on cleanup as configured by their
+UnmountConfig, which can retry the unmounts and escalate to forced and lazy
+unmounts. StepVerifyCleanup reports the mounts left behind within the chroot
+once it is cleaned up.
+
 The HashiCorp-maintained Amazon and Azure builder plugins have chroot builders
 which use this option and can serve as an example for how the chroot steps and
 communicator are used.
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+)
+
+// ChrootMount is an entry of the chroot mounts: a filesystem mounted within
+// the chroot. The entries of the chroot mounts are lists of the form
+// [type, device, path] or [type, device, path, options], like
+//
+//	["proc", "proc", "/proc"]
+//	["bind", "/dev", "/dev"]
+//	["btrfs", "/dev/xvdf2", "/home", "subvol=@home,compress=zstd"]
+//
+// where options are the comma separated options of the mount, like the
+// subvolume of a btrfs filesystem, or "nouuid" for a XFS filesystem with the
+// UUID of a filesystem of the host.
+type ChrootMount struct {
+	// Type is the type of the filesystem, or "bind" for a bind mount.
+	Type string
+	// Device is the device, or the directory of the host for a bind mount.
+	Device string
+	// Path is the path of the mount within the chroot.
+	Path string
+	// Options are the options of the mount.
+	Options []string
+}
+
+// ParseChrootMount parses an entry of the chroot mounts.
+func ParseChrootMount(entry []string) (ChrootMount, error) {
+	if len(entry) != 3 && len(entry) != 4 {
+		return ChrootMount{}, fmt.Errorf(
+			"chroot mount %q must be [type, device, path] or [type, device, path, options]", entry)
+	}
+	m := ChrootMount{Type: entry[0], Device: entry[1], Path: entry[2]}
+	if m.Type == "" || m.Device == "" || m.Path == "" {
+		return ChrootMount{}, fmt.Errorf("chroot mount %q: type, device and path must be set", entry)
+	}
+	if len(entry) == 4 && entry[3] != "" {
+		m.Options = strings.Split(entry[3], ",")
+	}
+	return m, nil
+}
+
+// ValidateChrootMounts returns the errors of the entries of mounts, for the
+// Prepare method of builders.
+func ValidateChrootMounts(mounts [][]string) []error {
+	var errs []error
+	for _, entry := range mounts {
+		if _, err := ParseChrootMount(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// MountCommand returns the command mounting device at path. fstype is the
+// type of the filesystem, detected by mount when empty, or "bind" for a bind
+// mount.
+func MountCommand(fstype, device, path string, options []string) string {
+	args := []string{"mount"}
+	switch fstype {
+	case "":
+	case "bind":
+		args = append(args, "--bind")
+	default:
+		args = append(args, "-t", fstype)
+	}
+	if len(options) > 0 {
+		args = append(args, "-o", strings.Join(options, ","))
+	}
+	return strings.Join(append(args, device, path), " ")
+}
+
+// runCommand runs command, wrapped with wrappedCommand, on the host. The
+// returned error holds the standard error of the command.
+func runCommand(wrappedCommand common.CommandWrapper, command string) error {
+	wrapped, err := wrappedCommand(command)
+	if err != nil {
+		return fmt.Errorf("Error creating command %q: %s", command, err)
+	}
+	stderr := new(bytes.Buffer)
+	cmd := common.ShellCommand(wrapped)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error running %q: %s\nStderr: %s", command, err, stderr.String())
+	}
+	return nil
+}
+
+// isMounted returns true if path is a mount point of the host, as listed by
+// /proc/mounts.
+func isMounted(wrappedCommand common.CommandWrapper, path string) (bool, error) {
+	grepCommand, err := wrappedCommand(fmt.Sprintf("grep %s /proc/mounts", path))
+	if err != nil {
+		return false, fmt.Errorf("Error creating grep command: %s", err)
+	}
+	cmd := common.ShellCommand(grepCommand)
+	if err := cmd.Run(); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 1 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
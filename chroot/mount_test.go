@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChrootMount(t *testing.T) {
+	cases := []struct {
+		entry    []string
+		expected ChrootMount
+		err      bool
+	}{
+		{
+			entry:    []string{"proc", "proc", "/proc"},
+			expected: ChrootMount{Type: "proc", Device: "proc", Path: "/proc"},
+		},
+		{
+			entry: []string{"btrfs", "/dev/xvdf2", "/home", "subvol=@home,compress=zstd"},
+			expected: ChrootMount{Type: "btrfs", Device: "/dev/xvdf2", Path: "/home",
+				Options: []string{"subvol=@home", "compress=zstd"}},
+		},
+		{
+			entry:    []string{"bind", "/dev", "/dev", ""},
+			expected: ChrootMount{Type: "bind", Device: "/dev", Path: "/dev"},
+		},
+		{entry: []string{"proc", "/proc"}, err: true},
+		{entry: []string{"", "proc", "/proc"}, err: true},
+		{entry: []string{"a", "b", "c", "d", "e"}, err: true},
+	}
+	for _, tc := range cases {
+		got, err := ParseChrootMount(tc.entry)
+		if (err != nil) != tc.err {
+			t.Fatalf("%q: unexpected error %v", tc.entry, err)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("%q: expected %#v, got %#v", tc.entry, tc.expected, got)
+		}
+	}
+
+	if errs := ValidateChrootMounts([][]string{{"proc", "proc", "/proc"}, {"proc"}}); len(errs) != 1 {
+		t.Fatalf("expected an error, got %v", errs)
+	}
+}
+
+func TestMountCommand(t *testing.T) {
+	cases := []struct {
+		fstype   string
+		options  []string
+		expected string
+	}{
+		{"", nil, "mount /dev/xvdf /mnt"},
+		{"bind", nil, "mount --bind /dev/xvdf /mnt"},
+		{"xfs", []string{"nouuid"}, "mount -t xfs -o nouuid /dev/xvdf /mnt"},
+		{"btrfs", []string{"subvol=@", "compress=zstd"}, "mount -t btrfs -o subvol=@,compress=zstd /dev/xvdf /mnt"},
+	}
+	for _, tc := range cases {
+		if got := MountCommand(tc.fstype, "/dev/xvdf", "/mnt", tc.options); got != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestPartitionDevice(t *testing.T) {
+	cases := map[string]string{
+		"/dev/xvdf":    "/dev/xvdf1",
+		"/dev/nvme1n1": "/dev/nvme1n1p1",
+	}
+	for device, expected := range cases {
+		if got := PartitionDevice(device, "1"); got != expected {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
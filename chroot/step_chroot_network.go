@@ -0,0 +1,189 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The ways StepChrootNetwork sets the resolv.conf of the chroot up.
+const (
+	// ResolvConfCopy copies the resolv.conf of the host into the chroot.
+	ResolvConfCopy = "copy"
+	// ResolvConfBind bind mounts the resolv.conf of the host in the chroot,
+	// so that the changes of the host, like with DHCP, are seen in the
+	// chroot.
+	ResolvConfBind = "bind"
+	// ResolvConfNone leaves the resolv.conf of the chroot as is.
+	ResolvConfNone = "none"
+)
+
+// backupSuffix is the suffix of the original files of the chroot while
+// StepChrootNetwork replaces them.
+const backupSuffix = ".packer-backup"
+
+// StepChrootNetwork sets up the DNS resolution within the chroot, so that
+// the provisioners can reach the network, and restores the original files
+// of the chroot on cleanup. The original resolv.conf of an image is often a
+// symbolic link to a file of a service that doesn't run in the chroot, like
+// systemd-resolved.
+//
+// Uses:
+//
+//	mount_path string - The path the root filesystem is mounted at
+//
+// Produces:
+//
+//	chroot_network_cleanup CleanupFunc - To perform early cleanup
+type StepChrootNetwork struct {
+	// ResolvConf is how the resolv.conf of the chroot is set up:
+	// ResolvConfCopy, the default, ResolvConfBind or ResolvConfNone.
+	ResolvConf string
+	// ResolvConfSource is the resolv.conf of the host, /etc/resolv.conf by
+	// default. Symbolic links are followed.
+	ResolvConfSource string
+	// NSSwitchHosts, if set, replaces the sources of the hosts database in
+	// the nsswitch.conf of the chroot during the build, like "files dns",
+	// for the images looking hosts up with services that don't run in the
+	// chroot.
+	NSSwitchHosts string
+	// Unmount configures how the bind mounted resolv.conf is unmounted on
+	// cleanup.
+	Unmount UnmountConfig
+
+	// restores are the commands restoring the files of the chroot, run in
+	// reverse order.
+	restores []string
+	mounted  string
+}
+
+func (s *StepChrootNetwork) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	mountPath := state.Get("mount_path").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	state.Put("chroot_network_cleanup", s)
+	if err := s.setup(mountPath, ui, wrappedCommand); err != nil {
+		err := fmt.Errorf("Error setting up the network of the chroot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepChrootNetwork) setup(mountPath string, ui packersdk.Ui, wrappedCommand common.CommandWrapper) error {
+	mode := s.ResolvConf
+	if mode == "" {
+		mode = ResolvConfCopy
+	}
+	switch mode {
+	case ResolvConfCopy, ResolvConfBind, ResolvConfNone:
+	default:
+		return fmt.Errorf("unknown resolv.conf mode %q, expected %q, %q or %q",
+			mode, ResolvConfCopy, ResolvConfBind, ResolvConfNone)
+	}
+
+	if mode != ResolvConfNone {
+		src := s.ResolvConfSource
+		if src == "" {
+			src = "/etc/resolv.conf"
+		}
+		resolved, err := filepath.EvalSymlinks(src)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %s", src, err)
+		}
+		src = resolved
+		dst := filepath.Join(mountPath, "etc", "resolv.conf")
+		ui.Say(fmt.Sprintf("Setting up the resolv.conf of the chroot from %s...", src))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := s.backup(wrappedCommand, dst, "mv"); err != nil {
+			return err
+		}
+		if mode == ResolvConfCopy {
+			if err := runCommand(wrappedCommand, fmt.Sprintf("cp -L %s %s", src, dst)); err != nil {
+				return err
+			}
+		} else {
+			if err := runCommand(wrappedCommand, fmt.Sprintf("touch %s", dst)); err != nil {
+				return err
+			}
+			if err := runCommand(wrappedCommand, MountCommand("bind", src, dst, nil)); err != nil {
+				return err
+			}
+			s.mounted = dst
+		}
+	}
+
+	if s.NSSwitchHosts != "" {
+		nsswitch := filepath.Join(mountPath, "etc", "nsswitch.conf")
+		if _, err := os.Lstat(nsswitch); err != nil {
+			log.Printf("No nsswitch.conf in the chroot, not setting its hosts: %s", err)
+			return nil
+		}
+		ui.Say(fmt.Sprintf("Setting the hosts of the nsswitch.conf of the chroot to %q...", s.NSSwitchHosts))
+		if err := s.backup(wrappedCommand, nsswitch, "cp -p"); err != nil {
+			return err
+		}
+		sed := fmt.Sprintf("sed -i 's/^hosts:.*/hosts: %s/' %s", s.NSSwitchHosts, nsswitch)
+		if err := runCommand(wrappedCommand, sed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backup backs path up, if it exists, with copy, "mv" or "cp -p", and
+// schedules its restoration; a path that didn't exist is removed instead.
+func (s *StepChrootNetwork) backup(wrappedCommand common.CommandWrapper, path, copy string) error {
+	backup := path + backupSuffix
+	if _, err := os.Lstat(path); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		s.restores = append(s.restores, fmt.Sprintf("rm -f %s", path))
+		return nil
+	}
+	if err := runCommand(wrappedCommand, fmt.Sprintf("%s %s %s", copy, path, backup)); err != nil {
+		return err
+	}
+	s.restores = append(s.restores, fmt.Sprintf("mv %s %s", backup, path), fmt.Sprintf("rm -f %s", path))
+	return nil
+}
+
+func (s *StepChrootNetwork) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepChrootNetwork) CleanupFunc(state multistep.StateBag) error {
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+	if s.mounted != "" {
+		if err := s.Unmount.Unmount(wrappedCommand, s.mounted); err != nil {
+			return fmt.Errorf("Error unmounting the resolv.conf of the chroot: %s", err)
+		}
+		s.mounted = ""
+	}
+	for len(s.restores) > 0 {
+		last := len(s.restores) - 1
+		log.Printf("Restoring the network files of the chroot: %s", s.restores[last])
+		if err := runCommand(wrappedCommand, s.restores[last]); err != nil {
+			return fmt.Errorf("Error restoring the network files of the chroot: %s", err)
+		}
+		s.restores = s.restores[:last]
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestChrootNetworkCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{} = new(StepChrootNetwork)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+// testChrootNetwork runs step in a chroot with an etc directory holding
+// files, and returns the commands it ran and cleaned up with.
+func testChrootNetwork(t *testing.T, step *StepChrootNetwork, files ...string) (string, string, []string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "host-resolv.conf")
+	if err := os.WriteFile(source, []byte("nameserver 10.0.0.2\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	step.ResolvConfSource = source
+	mountPath := filepath.Join(dir, "chroot")
+	if err := os.MkdirAll(filepath.Join(mountPath, "etc"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(mountPath, "etc", f), nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	var commands []string
+	var wrapper common.CommandWrapper = func(ran string) (string, error) {
+		commands = append(commands, ran)
+		return "", nil
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", mountPath)
+	state.Put("wrappedCommand", wrapper)
+	ui, getErrs := testUI()
+	state.Put("ui", ui)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v': %s", action, getErrs())
+	}
+	run := commands
+	commands = nil
+	step.Cleanup(state)
+	if errs := getErrs(); errs != "" {
+		t.Fatalf("unexpected errors: %s", errs)
+	}
+	return source, filepath.Join(mountPath, "etc"), run, commands
+}
+
+func TestChrootNetwork_copy(t *testing.T) {
+	source, etc, run, cleanup := testChrootNetwork(t, &StepChrootNetwork{
+		NSSwitchHosts: "files dns",
+	}, "resolv.conf", "nsswitch.conf")
+
+	resolv := filepath.Join(etc, "resolv.conf")
+	nsswitch := filepath.Join(etc, "nsswitch.conf")
+	expected := []string{
+		"mv " + resolv + " " + resolv + ".packer-backup",
+		"cp -L " + source + " " + resolv,
+		"cp -p " + nsswitch + " " + nsswitch + ".packer-backup",
+		"sed -i 's/^hosts:.*/hosts: files dns/' " + nsswitch,
+	}
+	if !reflect.DeepEqual(run, expected) {
+		t.Fatalf("expected commands\n%q\ngot\n%q", expected, run)
+	}
+	expected = []string{
+		"rm -f " + nsswitch,
+		"mv " + nsswitch + ".packer-backup " + nsswitch,
+		"rm -f " + resolv,
+		"mv " + resolv + ".packer-backup " + resolv,
+	}
+	if !reflect.DeepEqual(cleanup, expected) {
+		t.Fatalf("expected cleanup commands\n%q\ngot\n%q", expected, cleanup)
+	}
+}
+
+func TestChrootNetwork_bind(t *testing.T) {
+	source, etc, run, cleanup := testChrootNetwork(t, &StepChrootNetwork{
+		ResolvConf:    ResolvConfBind,
+		NSSwitchHosts: "files dns",
+	})
+
+	resolv := filepath.Join(etc, "resolv.conf")
+	expected := []string{
+		"touch " + resolv,
+		"mount --bind " + source + " " + resolv,
+	}
+	if !reflect.DeepEqual(run, expected) {
+		t.Fatalf("expected commands\n%q\ngot\n%q", expected, run)
+	}
+	expected = []string{
+		"umount " + resolv,
+		"rm -f " + resolv,
+	}
+	if !reflect.DeepEqual(cleanup, expected) {
+		t.Fatalf("expected cleanup commands\n%q\ngot\n%q", expected, cleanup)
+	}
+}
+
+func TestChrootNetwork_unknownMode(t *testing.T) {
+	step := &StepChrootNetwork{ResolvConf: "symlink"}
+	var wrapper common.CommandWrapper = func(ran string) (string, error) { return "", nil }
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", t.TempDir())
+	state.Put("wrappedCommand", wrapper)
+	ui, _ := testUI()
+	state.Put("ui", ui)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("expected 'halt', got '%v'", action)
+	}
+}
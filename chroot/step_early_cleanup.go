@@ -19,6 +19,8 @@ type StepEarlyCleanup struct{}
 func (s *StepEarlyCleanup) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	cleanupKeys := []string{
+		"chroot_network_cleanup",
+		"qemu_user_static_cleanup",
 		"copy_files_cleanup",
 		"mount_extra_cleanup",
 		"mount_device_cleanup",
@@ -26,7 +28,11 @@ func (s *StepEarlyCleanup) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 
 	for _, key := range cleanupKeys {
-		c := state.Get(key).(Cleanup)
+		c, ok := state.Get(key).(Cleanup)
+		if !ok {
+			// The step producing the cleanup isn't used by the builder.
+			continue
+		}
 		log.Printf("Running cleanup func: %s", key)
 		if err := c.CleanupFunc(state); err != nil {
 			err := fmt.Errorf("Error cleaning up: %s", err)
@@ -0,0 +1,26 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestEarlyCleanup_skipsUnusedSteps(t *testing.T) {
+	var wrapper common.CommandWrapper = func(ran string) (string, error) { return "", nil }
+	state := new(multistep.BasicStateBag)
+	state.Put("wrappedCommand", wrapper)
+	state.Put("copy_files_cleanup", new(StepCopyFiles))
+	ui, getErrs := testUI()
+	state.Put("ui", ui)
+
+	step := new(StepEarlyCleanup)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v': %s", action, getErrs())
+	}
+}
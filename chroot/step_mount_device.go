@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepMountDevice mounts the root filesystem of the image, on the attached
+// device, at MountPath.
+//
+// Uses:
+//
+//	device string - The attached device, like /dev/xvdf
+//
+// Produces:
+//
+//	mount_path string - The path the root filesystem is mounted at
+//	mount_device_cleanup CleanupFunc - To perform early cleanup
+type StepMountDevice struct {
+	// MountPath is the directory the root filesystem is mounted at. It is
+	// created if it doesn't exist.
+	MountPath string
+	// FilesystemType is the type of the root filesystem, detected by mount
+	// if empty.
+	FilesystemType string
+	// MountOptions are the options of the mount, like "nouuid" for a XFS
+	// filesystem with the UUID of a filesystem of the host.
+	MountOptions []string
+	// MountPartition is the number of the partition of the device holding
+	// the root filesystem, or its physical volume with LogicalVolume. The
+	// device itself is mounted if empty or "0".
+	MountPartition string
+	// LogicalVolume is the LVM logical volume holding the root filesystem,
+	// of the form "volume-group/logical-volume". Its volume group is
+	// activated before the mount, and deactivated once unmounted. The
+	// volume group must not have the name of a volume group of the host.
+	LogicalVolume string
+	// Subvolume is the btrfs subvolume mounted as the root filesystem, like
+	// "@".
+	Subvolume string
+	// Unmount configures how the root filesystem is unmounted on cleanup.
+	Unmount UnmountConfig
+
+	mountPath   string
+	volumeGroup string
+}
+
+func (s *StepMountDevice) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	device := state.Get("device").(string)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	if s.MountPartition != "" && s.MountPartition != "0" {
+		device = PartitionDevice(device, s.MountPartition)
+	}
+
+	if s.LogicalVolume != "" {
+		vg, lv, ok := strings.Cut(s.LogicalVolume, "/")
+		if !ok || vg == "" || lv == "" {
+			err := fmt.Errorf("Invalid logical volume %q, expected volume-group/logical-volume", s.LogicalVolume)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		ui.Say(fmt.Sprintf("Activating volume group %s on %s...", vg, device))
+		for _, command := range []string{
+			fmt.Sprintf("pvscan --cache %s", device),
+			fmt.Sprintf("vgchange -ay %s", vg),
+		} {
+			if err := runCommand(wrappedCommand, command); err != nil {
+				err := fmt.Errorf("Error activating volume group: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+		s.volumeGroup = vg
+		device = fmt.Sprintf("/dev/%s/%s", vg, lv)
+	}
+
+	log.Printf("Mount path: %s", s.MountPath)
+	if err := os.MkdirAll(s.MountPath, 0755); err != nil {
+		err := fmt.Errorf("Error creating mount directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	options := append([]string(nil), s.MountOptions...)
+	if s.Subvolume != "" {
+		options = append(options, "subvol="+s.Subvolume)
+	}
+	ui.Say(fmt.Sprintf("Mounting the root device %s...", device))
+	if err := runCommand(wrappedCommand, MountCommand(s.FilesystemType, device, s.MountPath, options)); err != nil {
+		err := fmt.Errorf("Error mounting root volume: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.mountPath = s.MountPath
+	state.Put("mount_path", s.MountPath)
+	state.Put("mount_device_cleanup", s)
+	return multistep.ActionContinue
+}
+
+func (s *StepMountDevice) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepMountDevice) CleanupFunc(state multistep.StateBag) error {
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+	if s.mountPath != "" {
+		log.Printf("Unmounting the root device: %s", s.mountPath)
+		if err := s.Unmount.Unmount(wrappedCommand, s.mountPath); err != nil {
+			return fmt.Errorf("Error unmounting root device: %s", err)
+		}
+		s.mountPath = ""
+	}
+	if s.volumeGroup != "" {
+		log.Printf("Deactivating the volume group: %s", s.volumeGroup)
+		if err := runCommand(wrappedCommand, fmt.Sprintf("vgchange -an %s", s.volumeGroup)); err != nil {
+			return fmt.Errorf("Error deactivating volume group: %s", err)
+		}
+		s.volumeGroup = ""
+	}
+	return nil
+}
+
+// PartitionDevice returns the device of the partition of device numbered
+// partition, like /dev/xvdf1 for /dev/xvdf, or /dev/nvme1n1p1 for
+// /dev/nvme1n1.
+func PartitionDevice(device, partition string) string {
+	if device != "" && unicode.IsDigit(rune(device[len(device)-1])) {
+		return device + "p" + partition
+	}
+	return device + partition
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestMountDeviceCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{} = new(StepMountDevice)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestMountDevice_Run(t *testing.T) {
+	mountPath := filepath.Join(t.TempDir(), "root")
+	step := &StepMountDevice{
+		MountPath:      mountPath,
+		FilesystemType: "btrfs",
+		MountOptions:   []string{"compress=zstd"},
+		MountPartition: "2",
+		LogicalVolume:  "image-vg/root",
+		Subvolume:      "@",
+	}
+
+	var commands []string
+	var wrapper common.CommandWrapper = func(ran string) (string, error) {
+		commands = append(commands, ran)
+		return "", nil
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("device", "/dev/nvme1n1")
+	state.Put("wrappedCommand", wrapper)
+	ui, getErrs := testUI()
+	state.Put("ui", ui)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v': %s", action, getErrs())
+	}
+	if got := state.Get("mount_path"); got != mountPath {
+		t.Fatalf("bad mount path: %v", got)
+	}
+	if err := step.CleanupFunc(state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Cleaning up again is a no-op.
+	step.Cleanup(state)
+
+	expected := []string{
+		"pvscan --cache /dev/nvme1n1p2",
+		"vgchange -ay image-vg",
+		"mount -t btrfs -o compress=zstd,subvol=@ /dev/image-vg/root " + mountPath,
+		"umount " + mountPath,
+		"vgchange -an image-vg",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("expected commands\n%q\ngot\n%q", expected, commands)
+	}
+}
+
+func TestMountDevice_invalidLogicalVolume(t *testing.T) {
+	step := &StepMountDevice{MountPath: t.TempDir(), LogicalVolume: "root"}
+	var wrapper common.CommandWrapper = func(ran string) (string, error) { return "", nil }
+	state := new(multistep.BasicStateBag)
+	state.Put("device", "/dev/xvdf")
+	state.Put("wrappedCommand", wrapper)
+	ui, _ := testUI()
+	state.Put("ui", ui)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("expected 'halt', got '%v'", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatalf("should set an error")
+	}
+}
@@ -8,22 +8,23 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"syscall"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// StepMountExtra mounts the attached device.
+// StepMountExtra mounts the ChrootMounts within the chroot. See ChrootMount
+// for the format of their entries.
 //
 // Produces:
 //
 //	mount_extra_cleanup CleanupFunc - To perform early cleanup
 type StepMountExtra struct {
 	ChrootMounts [][]string
-	mounts       []string
+	// Unmount configures how the mounts are unmounted on cleanup.
+	Unmount UnmountConfig
+	mounts  []string
 }
 
 func (s *StepMountExtra) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -34,8 +35,14 @@ func (s *StepMountExtra) Run(ctx context.Context, state multistep.StateBag) mult
 	s.mounts = make([]string, 0, len(s.ChrootMounts))
 
 	ui.Say("Mounting additional paths within the chroot...")
-	for _, mountInfo := range s.ChrootMounts {
-		innerPath := mountPath + mountInfo[2]
+	for _, entry := range s.ChrootMounts {
+		mount, err := ParseChrootMount(entry)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		innerPath := mountPath + mount.Path
 
 		if err := os.MkdirAll(innerPath, 0755); err != nil {
 			err := fmt.Errorf("Error creating mount directory: %s", err)
@@ -44,18 +51,9 @@ func (s *StepMountExtra) Run(ctx context.Context, state multistep.StateBag) mult
 			return multistep.ActionHalt
 		}
 
-		flags := "-t " + mountInfo[0]
-		if mountInfo[0] == "bind" {
-			flags = "--bind"
-		}
-
-		ui.Message(fmt.Sprintf("Mounting: %s", mountInfo[2]))
+		ui.Message(fmt.Sprintf("Mounting: %s", mount.Path))
 		stderr := new(bytes.Buffer)
-		mountCommand, err := wrappedCommand(fmt.Sprintf(
-			"mount %s %s %s",
-			flags,
-			mountInfo[1],
-			innerPath))
+		mountCommand, err := wrappedCommand(MountCommand(mount.Type, mount.Device, innerPath, mount.Options))
 		if err != nil {
 			err := fmt.Errorf("Error creating mount command: %s", err)
 			state.Put("error", err)
@@ -100,40 +98,18 @@ func (s *StepMountExtra) CleanupFunc(state multistep.StateBag) error {
 		lastIndex := len(s.mounts) - 1
 		path, s.mounts = s.mounts[lastIndex], s.mounts[:lastIndex]
 
-		grepCommand, err := wrappedCommand(fmt.Sprintf("grep %s /proc/mounts", path))
-		if err != nil {
-			return fmt.Errorf("Error creating grep command: %s", err)
-		}
-
 		// Before attempting to unmount,
 		// check to see if path is already unmounted
-		stderr := new(bytes.Buffer)
-		cmd := common.ShellCommand(grepCommand)
-		cmd.Stderr = stderr
-		if err := cmd.Run(); err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
-					exitStatus := status.ExitStatus()
-					if exitStatus == 1 {
-						// path has already been unmounted
-						// just skip this path
-						continue
-					}
-				}
-			}
-		}
-
-		unmountCommand, err := wrappedCommand(fmt.Sprintf("umount %s", path))
+		mounted, err := isMounted(wrappedCommand, path)
 		if err != nil {
-			return fmt.Errorf("Error creating unmount command: %s", err)
+			return err
+		}
+		if !mounted {
+			continue
 		}
 
-		stderr = new(bytes.Buffer)
-		cmd = common.ShellCommand(unmountCommand)
-		cmd.Stderr = stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf(
-				"Error unmounting device: %s\nStderr: %s", err, stderr.String())
+		if err := s.Unmount.Unmount(wrappedCommand, path); err != nil {
+			return fmt.Errorf("Error unmounting device: %s", err)
 		}
 	}
 
@@ -3,7 +3,14 @@
 
 package chroot
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
 
 func TestMountExtraCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
 	var raw interface{} = new(StepMountExtra)
@@ -11,3 +18,49 @@ func TestMountExtraCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
 		t.Fatalf("cleanup func should be a CleanupFunc")
 	}
 }
+
+func TestMountExtra_Run(t *testing.T) {
+	mountPath := t.TempDir()
+	step := &StepMountExtra{
+		ChrootMounts: [][]string{
+			{"proc", "proc", "/proc"},
+			{"btrfs", "/dev/xvdf2", "/home", "subvol=@home"},
+		},
+	}
+
+	var commands []string
+	var wrapper common.CommandWrapper = func(ran string) (string, error) {
+		commands = append(commands, ran)
+		return "", nil
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", mountPath)
+	state.Put("wrappedCommand", wrapper)
+	ui, getErrs := testUI()
+	state.Put("ui", ui)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v': %s", action, getErrs())
+	}
+	expected := []string{
+		"mount -t proc proc " + mountPath + "/proc",
+		"mount -t btrfs -o subvol=@home /dev/xvdf2 " + mountPath + "/home",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("expected commands\n%q\ngot\n%q", expected, commands)
+	}
+}
+
+func TestMountExtra_invalidMount(t *testing.T) {
+	step := &StepMountExtra{ChrootMounts: [][]string{{"proc", "/proc"}}}
+	var wrapper common.CommandWrapper = func(ran string) (string, error) { return "", nil }
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", t.TempDir())
+	state.Put("wrappedCommand", wrapper)
+	ui, _ := testUI()
+	state.Put("ui", ui)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("expected 'halt', got '%v'", action)
+	}
+}
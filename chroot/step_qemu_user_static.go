@@ -0,0 +1,223 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// binfmtFormat is the ELF header of the binaries of an architecture, as
+// registered with binfmt_misc by qemu-binfmt-conf.sh.
+type binfmtFormat struct {
+	magic string
+	mask  string
+}
+
+// binfmtFormats are the formats of the architectures qemu-user-static can
+// emulate, by qemu name.
+var binfmtFormats = map[string]binfmtFormat{
+	"aarch64": {
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\xb7\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"arm": {
+		magic: `\x7fELF\x01\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x28\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"ppc64le": {
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x15\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\xfc\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\x00`,
+	},
+	"riscv64": {
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\xf3\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+	"s390x": {
+		magic: `\x7fELF\x02\x02\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x16`,
+		mask:  `\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff`,
+	},
+	"x86_64": {
+		magic: `\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00`,
+		mask:  `\xff\xff\xff\xff\xff\xfe\xfe\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff`,
+	},
+}
+
+// goArchitectures are the qemu names of the go architectures.
+var goArchitectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+}
+
+var (
+	// binfmtMiscDir is the directory of the binfmt_misc filesystem.
+	binfmtMiscDir = "/proc/sys/fs/binfmt_misc"
+	// hostArchitecture is the architecture of the host.
+	hostArchitecture = runtime.GOARCH
+)
+
+// QemuArchitecture returns the qemu name of arch, a go or qemu name of an
+// architecture, like "aarch64" for "arm64", or an error if qemu-user-static
+// can't emulate it.
+func QemuArchitecture(arch string) (string, error) {
+	if name, ok := goArchitectures[arch]; ok {
+		arch = name
+	}
+	if _, ok := binfmtFormats[arch]; !ok {
+		names := make([]string, 0, len(binfmtFormats))
+		for name := range binfmtFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unsupported architecture %q, expected one of %s", arch, strings.Join(names, ", "))
+	}
+	return arch, nil
+}
+
+// StepQemuUserStatic lets the chroot run the binaries of an image of another
+// architecture than the host, like an arm64 image on an amd64 host. It
+// registers qemu-user-static with binfmt_misc, unless it already is, like by
+// systemd-binfmt, and copies it into the chroot. Only the entry it
+// registered is unregistered on cleanup. The chroot Communicator and the
+// chroot steps then run commands in the chroot as usual. The host must have
+// qemu-user-static installed.
+//
+// Uses:
+//
+//	mount_path string - The path the root filesystem is mounted at
+//
+// Produces:
+//
+//	qemu_user_static_cleanup CleanupFunc - To perform early cleanup
+type StepQemuUserStatic struct {
+	// Architecture is the architecture of the image, a go or qemu name like
+	// "arm64" or "aarch64". The step does nothing if it is empty or the
+	// architecture of the host.
+	Architecture string
+	// QemuBinary is the qemu-user-static emulator of Architecture on the
+	// host, /usr/bin/qemu-<architecture>-static by default.
+	QemuBinary string
+
+	copied     string
+	registered string
+}
+
+func (s *StepQemuUserStatic) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	mountPath := state.Get("mount_path").(string)
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	state.Put("qemu_user_static_cleanup", s)
+	if s.Architecture == "" {
+		return multistep.ActionContinue
+	}
+	if err := s.setup(mountPath, ui, wrappedCommand); err != nil {
+		err := fmt.Errorf("Error setting up qemu-user-static: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepQemuUserStatic) setup(mountPath string, ui packersdk.Ui, wrappedCommand common.CommandWrapper) error {
+	arch, err := QemuArchitecture(s.Architecture)
+	if err != nil {
+		return err
+	}
+	if host, err := QemuArchitecture(hostArchitecture); err == nil && host == arch {
+		log.Printf("The image has the architecture of the host, %s, not using qemu-user-static", arch)
+		return nil
+	}
+
+	binary := s.QemuBinary
+	if binary == "" {
+		binary = fmt.Sprintf("/usr/bin/qemu-%s-static", arch)
+	}
+	if _, err := os.Stat(binary); err != nil {
+		return fmt.Errorf("%s not found, install qemu-user-static on the host: %s", binary, err)
+	}
+
+	ui.Say(fmt.Sprintf("Setting up qemu-user-static to run %s binaries in the chroot...", arch))
+	if _, err := os.Stat(filepath.Join(binfmtMiscDir, "register")); err != nil {
+		mount := MountCommand("binfmt_misc", "binfmt_misc", binfmtMiscDir, nil)
+		if err := runCommand(wrappedCommand, mount); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(filepath.Join(binfmtMiscDir, "qemu-"+arch)); err == nil {
+		log.Printf("qemu-%s is already registered with binfmt_misc", arch)
+	} else {
+		// The entry is named after this step, so that the cleanup doesn't
+		// unregister the ones of the host or of other builds, which may
+		// still be using it.
+		name := fmt.Sprintf("packer-qemu-%s-%s", arch, uuid.TimeOrderedUUID())
+		// The F flag makes the kernel open the emulator when registering it,
+		// so that it runs even if the chroot doesn't have it.
+		format := binfmtFormats[arch]
+		registration := fmt.Sprintf(":%s:M::%s:%s:%s:F", name, format.magic, format.mask, binary)
+		if err := writeProcFile(wrappedCommand, filepath.Join(binfmtMiscDir, "register"), registration); err != nil {
+			return fmt.Errorf("failed to register qemu-%s with binfmt_misc: %s", arch, err)
+		}
+		s.registered = filepath.Join(binfmtMiscDir, name)
+	}
+
+	chrootBinary := filepath.Join(mountPath, binary)
+	if _, err := os.Stat(chrootBinary); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(chrootBinary), 0755); err != nil {
+		return err
+	}
+	if err := runCommand(wrappedCommand, fmt.Sprintf("cp %s %s", binary, chrootBinary)); err != nil {
+		return err
+	}
+	s.copied = chrootBinary
+	return nil
+}
+
+// writeProcFile writes content to path, a file of /proc, as the wrapped
+// command.
+func writeProcFile(wrappedCommand common.CommandWrapper, path, content string) error {
+	write := fmt.Sprintf("printf '%%s\\n' %s > %s", local.ShellQuote(content), path)
+	return runCommand(wrappedCommand, "sh -c "+local.ShellQuote(write))
+}
+
+func (s *StepQemuUserStatic) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepQemuUserStatic) CleanupFunc(state multistep.StateBag) error {
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+	if s.copied != "" {
+		log.Printf("Removing: %s", s.copied)
+		if err := runCommand(wrappedCommand, fmt.Sprintf("rm -f %s", s.copied)); err != nil {
+			return fmt.Errorf("Error removing qemu-user-static from the chroot: %s", err)
+		}
+		s.copied = ""
+	}
+	if s.registered != "" {
+		log.Printf("Unregistering: %s", s.registered)
+		if err := writeProcFile(wrappedCommand, s.registered, "-1"); err != nil {
+			return fmt.Errorf("Error unregistering qemu-user-static: %s", err)
+		}
+		s.registered = ""
+	}
+	return nil
+}
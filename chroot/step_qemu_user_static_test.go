@@ -0,0 +1,157 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestQemuUserStaticCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{} = new(StepQemuUserStatic)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestQemuArchitecture(t *testing.T) {
+	for arch, expected := range map[string]string{
+		"arm64":   "aarch64",
+		"aarch64": "aarch64",
+		"amd64":   "x86_64",
+		"riscv64": "riscv64",
+	} {
+		got, err := QemuArchitecture(arch)
+		if err != nil {
+			t.Fatalf("%s: %s", arch, err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %s, got %s", arch, expected, got)
+		}
+	}
+	if _, err := QemuArchitecture("vax"); err == nil {
+		t.Fatalf("should error")
+	}
+}
+
+// testQemuUserStatic runs step and cleans it up on a host of the
+// architecture host, whose binfmt_misc has the files entries.
+func testQemuUserStatic(t *testing.T, step *StepQemuUserStatic, host string, entries ...string) (string, []string, multistep.StepAction) {
+	t.Helper()
+	dir := t.TempDir()
+	oldDir, oldHost := binfmtMiscDir, hostArchitecture
+	binfmtMiscDir, hostArchitecture = filepath.Join(dir, "binfmt_misc"), host
+	t.Cleanup(func() { binfmtMiscDir, hostArchitecture = oldDir, oldHost })
+	if len(entries) > 0 {
+		if err := os.MkdirAll(binfmtMiscDir, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	for _, entry := range entries {
+		if err := os.WriteFile(filepath.Join(binfmtMiscDir, entry), nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	var commands []string
+	var wrapper common.CommandWrapper = func(ran string) (string, error) {
+		commands = append(commands, ran)
+		return "", nil
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", filepath.Join(dir, "chroot"))
+	state.Put("wrappedCommand", wrapper)
+	ui, _ := testUI()
+	state.Put("ui", ui)
+
+	action := step.Run(context.Background(), state)
+	step.Cleanup(state)
+	return dir, commands, action
+}
+
+func TestQemuUserStatic_Run(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "qemu-aarch64-static")
+	if err := os.WriteFile(binary, nil, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	step := &StepQemuUserStatic{Architecture: "arm64", QemuBinary: binary}
+	dir, commands, action := testQemuUserStatic(t, step, "amd64")
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v'", action)
+	}
+
+	binfmt := filepath.Join(dir, "binfmt_misc")
+	chrootBinary := filepath.Join(dir, "chroot", binary)
+	if len(commands) != 5 {
+		t.Fatalf("expected 5 commands, got %q", commands)
+	}
+	if expected := "mount -t binfmt_misc binfmt_misc " + binfmt; commands[0] != expected {
+		t.Fatalf("expected %q, got %q", expected, commands[0])
+	}
+	// The entry is named after the step, not the qemu-aarch64 of the host.
+	_, name, _ := strings.Cut(commands[1], ":packer-qemu-aarch64-")
+	name, _, _ = strings.Cut(name, ":")
+	name = "packer-qemu-aarch64-" + name
+	register := ":" + name + ":M::" + binfmtFormats["aarch64"].magic + ":" +
+		binfmtFormats["aarch64"].mask + ":" + binary + ":F"
+	if !strings.Contains(commands[1], register) || !strings.Contains(commands[1], filepath.Join(binfmt, "register")) {
+		t.Fatalf("bad registration command: %q", commands[1])
+	}
+	expected := []string{
+		"cp " + binary + " " + chrootBinary,
+		"rm -f " + chrootBinary,
+	}
+	if !reflect.DeepEqual(commands[2:4], expected) {
+		t.Fatalf("expected %q, got %q", expected, commands[2:4])
+	}
+	if !strings.Contains(commands[4], "-1") || !strings.Contains(commands[4], filepath.Join(binfmt, name)) {
+		t.Fatalf("bad unregistration command: %q", commands[4])
+	}
+}
+
+func TestQemuUserStatic_hostArchitecture(t *testing.T) {
+	step := &StepQemuUserStatic{Architecture: "aarch64"}
+	_, commands, action := testQemuUserStatic(t, step, "arm64")
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v'", action)
+	}
+	if len(commands) != 0 {
+		t.Fatalf("should not run commands, ran %q", commands)
+	}
+}
+
+func TestQemuUserStatic_missingBinary(t *testing.T) {
+	step := &StepQemuUserStatic{Architecture: "arm64", QemuBinary: "/nonexistent/qemu-aarch64-static"}
+	_, _, action := testQemuUserStatic(t, step, "amd64")
+	if action != multistep.ActionHalt {
+		t.Fatalf("expected 'halt', got '%v'", action)
+	}
+}
+
+func TestQemuUserStatic_registered(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "qemu-aarch64-static")
+	if err := os.WriteFile(binary, nil, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	step := &StepQemuUserStatic{Architecture: "arm64", QemuBinary: binary}
+	_, commands, action := testQemuUserStatic(t, step, "amd64", "register", "qemu-aarch64")
+	if action != multistep.ActionContinue {
+		t.Fatalf("expected 'continue', got '%v'", action)
+	}
+	for _, command := range commands {
+		if strings.Contains(command, "binfmt_misc") {
+			t.Fatalf("the entry of the host should be left alone, ran %q", command)
+		}
+	}
+	if len(commands) != 2 {
+		t.Fatalf("expected the copy and the removal of the emulator, got %q", commands)
+	}
+}
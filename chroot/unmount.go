@@ -0,0 +1,220 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// mountsFile lists the mounts of the host.
+var mountsFile = "/proc/mounts"
+
+// UnmountConfig configures how the chroot steps unmount their mounts on
+// cleanup. The zero value unmounts once, without retrying.
+type UnmountConfig struct {
+	// Attempts is the number of times a regular unmount is attempted. It
+	// defaults to 1.
+	Attempts int
+	// RetryDelay is the time waited between two attempts, 1 second by
+	// default.
+	RetryDelay time.Duration
+	// Escalate, once the attempts failed, logs the processes keeping the
+	// mount busy and unmounts it with a forced unmount, then with a lazy
+	// unmount, which detaches it even if it is busy.
+	Escalate bool
+}
+
+// Unmount unmounts path, retrying and escalating as configured by c.
+func (c UnmountConfig) Unmount(wrappedCommand common.CommandWrapper, path string) error {
+	attempts := c.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := c.RetryDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		if err = runCommand(wrappedCommand, fmt.Sprintf("umount %s", path)); err == nil {
+			return nil
+		}
+		log.Printf("Attempt %d/%d to unmount %s failed: %s", i+1, attempts, path, err)
+	}
+	if !c.Escalate {
+		return err
+	}
+
+	if busy := busyProcesses(wrappedCommand, path); busy != "" {
+		log.Printf("[WARN] %s is busy:\n%s", path, busy)
+	}
+	for _, flag := range []string{"-f", "-l"} {
+		if ferr := runCommand(wrappedCommand, fmt.Sprintf("umount %s %s", flag, path)); ferr == nil {
+			log.Printf("[WARN] %s unmounted with umount %s", path, flag)
+			return nil
+		}
+	}
+	return err
+}
+
+// busyProcesses returns the processes using the filesystem mounted at path,
+// as listed by fuser, or an empty string if they can't be listed.
+func busyProcesses(wrappedCommand common.CommandWrapper, path string) string {
+	command, err := wrappedCommand(fmt.Sprintf("fuser -vm %s", path))
+	if err != nil {
+		return ""
+	}
+	out, _ := common.ShellCommand(command).CombinedOutput()
+	return strings.TrimSpace(string(out))
+}
+
+// LeakedMount is a mount within the chroot left behind by the cleanup of the
+// build.
+type LeakedMount struct {
+	// Device is the device, or the source, of the mount.
+	Device string
+	// Path is the path of the mount on the host.
+	Path string
+	// Type is the type of the filesystem.
+	Type string
+	// Processes are the processes keeping the mount busy, as listed by
+	// fuser, if any.
+	Processes string
+}
+
+func (m LeakedMount) String() string {
+	s := fmt.Sprintf("%s (%s on %s) is still mounted", m.Path, m.Type, m.Device)
+	if m.Processes != "" {
+		s += ", used by:\n" + m.Processes
+	}
+	return s
+}
+
+// mountsUnder returns the mounts of the host at or under path, the deepest
+// first.
+func mountsUnder(path string) ([]LeakedMount, error) {
+	f, err := os.Open(mountsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	path = strings.TrimSuffix(path, "/")
+	var mounts []LeakedMount
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		mountPoint := unescapeMountField(fields[1])
+		if mountPoint != path && !strings.HasPrefix(mountPoint, path+"/") {
+			continue
+		}
+		mounts = append(mounts, LeakedMount{
+			Device: unescapeMountField(fields[0]),
+			Path:   mountPoint,
+			Type:   fields[2],
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return strings.Count(mounts[i].Path, "/") > strings.Count(mounts[j].Path, "/")
+	})
+	return mounts, nil
+}
+
+// unescapeMountField decodes the octal escapes, like \040 for a space, of a
+// field of /proc/mounts.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// StepVerifyCleanup checks, once the other chroot steps are cleaned up, that
+// nothing is left mounted within the chroot. It must run before the step
+// mounting the root filesystem, so that it is cleaned up last. The leaked
+// mounts are unmounted when Unmount escalates, and the remaining ones are
+// reported as warnings.
+//
+// Uses:
+//
+//	mount_path string - The path the root filesystem is mounted at
+//
+// Produces, on cleanup:
+//
+//	chroot_leaked_mounts []LeakedMount - The mounts left behind
+type StepVerifyCleanup struct {
+	// Unmount configures how the leaked mounts are unmounted. They are
+	// only unmounted when it escalates.
+	Unmount UnmountConfig
+}
+
+func (s *StepVerifyCleanup) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	return multistep.ActionContinue
+}
+
+func (s *StepVerifyCleanup) Cleanup(state multistep.StateBag) {
+	mountPath, ok := state.Get("mount_path").(string)
+	if !ok || mountPath == "" {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(common.CommandWrapper)
+
+	mounts, err := mountsUnder(mountPath)
+	if err != nil {
+		log.Printf("[WARN] Unable to check the mounts of the chroot: %s", err)
+		return
+	}
+	var leaked []LeakedMount
+	for _, m := range mounts {
+		if s.Unmount.Escalate {
+			log.Printf("[WARN] %s is still mounted, unmounting it", m.Path)
+			if err := s.Unmount.Unmount(wrappedCommand, m.Path); err == nil {
+				continue
+			}
+		}
+		m.Processes = busyProcesses(wrappedCommand, m.Path)
+		leaked = append(leaked, m)
+	}
+	if len(leaked) == 0 {
+		return
+	}
+	state.Put("chroot_leaked_mounts", leaked)
+	for _, m := range leaked {
+		ui.Error(fmt.Sprintf("Warning: %s", m))
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package chroot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// failingWrapper records the commands, and makes the ones starting with one
+// of failing fail.
+func failingWrapper(commands *[]string, failing ...string) common.CommandWrapper {
+	return func(ran string) (string, error) {
+		*commands = append(*commands, ran)
+		for _, f := range failing {
+			if strings.HasPrefix(ran, f) {
+				return "false", nil
+			}
+		}
+		return "", nil
+	}
+}
+
+func TestUnmount(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   UnmountConfig
+		failing  []string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "success",
+			expected: []string{"umount /mnt"},
+		},
+		{
+			name:     "no retry by default",
+			failing:  []string{"umount /mnt"},
+			expected: []string{"umount /mnt"},
+			err:      true,
+		},
+		{
+			name:     "retries",
+			config:   UnmountConfig{Attempts: 3, RetryDelay: time.Millisecond},
+			failing:  []string{"umount /mnt"},
+			expected: []string{"umount /mnt", "umount /mnt", "umount /mnt"},
+			err:      true,
+		},
+		{
+			name:     "forced unmount",
+			config:   UnmountConfig{Attempts: 2, RetryDelay: time.Millisecond, Escalate: true},
+			failing:  []string{"umount /mnt"},
+			expected: []string{"umount /mnt", "umount /mnt", "fuser -vm /mnt", "umount -f /mnt"},
+		},
+		{
+			name:     "lazy unmount",
+			config:   UnmountConfig{Escalate: true},
+			failing:  []string{"umount /mnt", "umount -f"},
+			expected: []string{"umount /mnt", "fuser -vm /mnt", "umount -f /mnt", "umount -l /mnt"},
+		},
+		{
+			name:     "escalation failure",
+			config:   UnmountConfig{Escalate: true},
+			failing:  []string{"umount"},
+			expected: []string{"umount /mnt", "fuser -vm /mnt", "umount -f /mnt", "umount -l /mnt"},
+			err:      true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var commands []string
+			err := tc.config.Unmount(failingWrapper(&commands, tc.failing...), "/mnt")
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(commands, tc.expected) {
+				t.Fatalf("expected commands %q, got %q", tc.expected, commands)
+			}
+		})
+	}
+}
+
+func testMountsFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mounts")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	old := mountsFile
+	mountsFile = path
+	t.Cleanup(func() { mountsFile = old })
+}
+
+const testMounts = `/dev/nvme0n1p1 / ext4 rw,relatime 0 0
+/dev/xvdf1 /mnt/chroot ext4 rw,relatime 0 0
+proc /mnt/chroot/proc proc rw 0 0
+/dev/xvdf2 /mnt/chroot/home\040dir btrfs rw,subvol=/@home 0 0
+/dev/xvdg1 /mnt/chroot-other ext4 rw 0 0
+`
+
+func TestMountsUnder(t *testing.T) {
+	testMountsFile(t, testMounts)
+	mounts, err := mountsUnder("/mnt/chroot/")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []LeakedMount{
+		{Device: "proc", Path: "/mnt/chroot/proc", Type: "proc"},
+		{Device: "/dev/xvdf2", Path: "/mnt/chroot/home dir", Type: "btrfs"},
+		{Device: "/dev/xvdf1", Path: "/mnt/chroot", Type: "ext4"},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, mounts)
+	}
+}
+
+func TestVerifyCleanup(t *testing.T) {
+	testMountsFile(t, testMounts)
+
+	var commands []string
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", "/mnt/chroot")
+	state.Put("wrappedCommand", failingWrapper(&commands, "umount /mnt/chroot/proc", "umount -f /mnt/chroot/proc", "umount -l /mnt/chroot/proc"))
+	ui, getErrs := testUI()
+	state.Put("ui", ui)
+
+	step := &StepVerifyCleanup{Unmount: UnmountConfig{Escalate: true}}
+	step.Cleanup(state)
+
+	leaked, ok := state.Get("chroot_leaked_mounts").([]LeakedMount)
+	if !ok || len(leaked) != 1 || leaked[0].Path != "/mnt/chroot/proc" {
+		t.Fatalf("expected /mnt/chroot/proc to be leaked, got %#v", leaked)
+	}
+	if errs := getErrs(); !strings.Contains(errs, "/mnt/chroot/proc (proc on proc) is still mounted") {
+		t.Fatalf("the leaked mount should be reported, got %q", errs)
+	}
+}
+
+func TestVerifyCleanup_noEscalation(t *testing.T) {
+	testMountsFile(t, testMounts)
+
+	var commands []string
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", "/mnt/chroot")
+	state.Put("wrappedCommand", failingWrapper(&commands))
+	ui, _ := testUI()
+	state.Put("ui", ui)
+
+	step := new(StepVerifyCleanup)
+	step.Cleanup(state)
+
+	leaked := state.Get("chroot_leaked_mounts").([]LeakedMount)
+	if len(leaked) != 3 {
+		t.Fatalf("expected 3 leaked mounts, got %#v", leaked)
+	}
+	for _, c := range commands {
+		if strings.HasPrefix(c, "umount") {
+			t.Fatalf("should not unmount, ran %q", c)
+		}
+	}
+}
@@ -302,11 +302,32 @@ func goFieldToCtyType(accessor string, fieldType types.Type) (interface{}, cty.T
 			Required: false,
 		}, ctyType
 	case *types.Map:
+		elem := f.Elem()
+		if ptr, isPtr := elem.(*types.Pointer); isPtr {
+			elem = ptr.Elem()
+		}
+		if elem, isNamed := elem.(*types.Named); isNamed {
+			if _, isStruct := elem.Underlying().(*types.Struct); isStruct {
+				// A map of structs is the relative type of a field with labelled blocks.
+				// E.g. Disks map[string]FlatDisk
+				// Disks will validate a block per key, labelled with the key.
+				return fmt.Sprintf(`&hcldec.BlockMapSpec{TypeName: "%s", LabelNames: []string{"name"},`+
+					` Nested: hcldec.ObjectSpec((*%s)(nil).HCL2Spec())}`, accessor, elem.String()), cty.NilType
+			}
+		}
 		return &hcldec.AttrSpec{
 			Name: accessor,
 			Type: cty.Map(cty.String), // for now everything can be simplified to a map[string]string
 		}, cty.Map(cty.String)
 	case *types.Named:
+		if f.String() == ctyValueType.String() {
+			// An interface field can be set to any value.
+			return &hcldec.AttrSpec{
+				Name:     accessor,
+				Type:     cty.DynamicPseudoType,
+				Required: false,
+			}, cty.DynamicPseudoType
+		}
 		// Named is the relative type when of a field with a struct.
 		// E.g. SourceAmiFilter    *common.FlatAmiFilterOptions
 		// SourceAmiFilter will become a block with nested elements from the struct itself.
@@ -418,13 +439,7 @@ func outputImports(w io.Writer, imports map[NamePath]*types.Package) {
 func getUsedImports(s *types.Struct) map[NamePath]*types.Package {
 	res := map[NamePath]*types.Package{}
 	for i := 0; i < s.NumFields(); i++ {
-		fieldType := s.Field(i).Type()
-		if p, ok := fieldType.(*types.Pointer); ok {
-			fieldType = p.Elem()
-		}
-		if p, ok := fieldType.(*types.Slice); ok {
-			fieldType = p.Elem()
-		}
+		fieldType := elemType(s.Field(i).Type())
 		namedType, ok := fieldType.(*types.Named)
 		if !ok {
 			continue
@@ -438,6 +453,23 @@ func getUsedImports(s *types.Struct) map[NamePath]*types.Package {
 	return res
 }
 
+// elemType returns the type of the elements of t, following pointers, slices
+// and maps.
+func elemType(t types.Type) types.Type {
+	for {
+		switch e := t.(type) {
+		case *types.Pointer:
+			t = e.Elem()
+		case *types.Slice:
+			t = e.Elem()
+		case *types.Map:
+			t = e.Elem()
+		default:
+			return t
+		}
+	}
+}
+
 func addCtyTagToStruct(s *types.Struct) (*types.Struct, error) {
 	vars, tags := structFields(s)
 	for i := range tags {
@@ -551,33 +583,31 @@ func getMapstructureSquashedStruct(topPkg *types.Package, utStruct *types.Struct
 			case "github.com/hashicorp/packer/provisioner/powershell.ExecutionPolicy": // TODO(azr): unhack this situation
 				field = types.NewField(field.Pos(), field.Pkg(), field.Name(), types.NewPointer(types.Typ[types.String]), field.Embedded())
 			default:
-				if str, isStruct := f.Underlying().(*types.Struct); isStruct {
-					obj := flattenNamed(f, str)
+				switch u := f.Underlying().(type) {
+				case *types.Struct:
+					obj := flattenNamed(f, u)
 					field = types.NewField(field.Pos(), field.Pkg(), field.Name(), obj, field.Embedded())
 					field = makePointer(field)
-				}
-				if slice, isSlice := f.Underlying().(*types.Slice); isSlice {
-					if f, fNamed := slice.Elem().(*types.Named); fNamed {
-						if str, isStruct := f.Underlying().(*types.Struct); isStruct {
-							// this is a slice of named structs; we want to change
-							// the struct ref to a 'FlatStruct'.
-							obj := flattenNamed(f, str)
-							slice := types.NewSlice(obj)
-							field = types.NewField(field.Pos(), field.Pkg(), field.Name(), slice, field.Embedded())
-						}
+				case *types.Slice, *types.Map:
+					// this is a slice or a map of named structs; we want to
+					// change the struct ref to a 'FlatStruct'.
+					if flat, ok := flattenElem(u); ok {
+						field = types.NewField(field.Pos(), field.Pkg(), field.Name(), flat, field.Embedded())
 					}
-				}
-				if _, isBasic := f.Underlying().(*types.Basic); isBasic {
+				case *types.Interface:
+					field = types.NewField(field.Pos(), field.Pkg(), field.Name(), ctyValueType, false)
+				case *types.Basic:
 					field = makePointer(field)
 				}
 			}
-		case *types.Slice:
-			if f, fNamed := f.Elem().(*types.Named); fNamed {
-				if str, isStruct := f.Underlying().(*types.Struct); isStruct {
-					obj := flattenNamed(f, str)
-					field = types.NewField(field.Pos(), field.Pkg(), field.Name(), types.NewSlice(obj), field.Embedded())
-				}
+		case *types.Slice, *types.Map:
+			if flat, ok := flattenElem(f); ok {
+				field = types.NewField(field.Pos(), field.Pkg(), field.Name(), flat, field.Embedded())
 			}
+		case *types.Interface:
+			// the value of an interface field is only known once decoded,
+			// it is kept as a cty.Value until then.
+			field = types.NewField(field.Pos(), field.Pkg(), field.Name(), ctyValueType, false)
 		case *types.Basic:
 			// since everything is optional, everything must be a pointer
 			// non optional fields should be non pointers.
@@ -591,6 +621,46 @@ func getMapstructureSquashedStruct(topPkg *types.Package, utStruct *types.Struct
 	return res, nil
 }
 
+// ctyValueType is the type of the fields of flat structs that can be set to
+// any value.
+var ctyValueType = types.NewNamed(
+	types.NewTypeName(0, types.NewPackage("github.com/zclconf/go-cty/cty", "cty"), "Value", nil),
+	types.NewStruct(nil, nil), nil)
+
+// flattenElem returns t, a slice or a map, with its elements changed to a
+// 'FlatStruct' when they are named structs or pointers to named structs.
+func flattenElem(t types.Type) (types.Type, bool) {
+	var elem types.Type
+	switch t := t.(type) {
+	case *types.Slice:
+		elem = t.Elem()
+	case *types.Map:
+		elem = t.Elem()
+	default:
+		return t, false
+	}
+	ptr, isPtr := elem.(*types.Pointer)
+	if isPtr {
+		elem = ptr.Elem()
+	}
+	named, isNamed := elem.(*types.Named)
+	if !isNamed {
+		return t, false
+	}
+	str, isStruct := named.Underlying().(*types.Struct)
+	if !isStruct {
+		return t, false
+	}
+	var flat types.Type = flattenNamed(named, str)
+	if isPtr {
+		flat = types.NewPointer(flat)
+	}
+	if m, isMap := t.(*types.Map); isMap {
+		return types.NewMap(m.Key(), flat), true
+	}
+	return types.NewSlice(flat), true
+}
+
 func flattenNamed(f *types.Named, underlying types.Type) *types.Named {
 	obj := f.Obj()
 	obj = types.NewTypeName(obj.Pos(), obj.Pkg(), "Flat"+obj.Name(), obj.Type())
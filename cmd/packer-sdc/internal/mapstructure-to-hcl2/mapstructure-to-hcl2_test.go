@@ -45,6 +45,13 @@ func TestCommand_Run(t *testing.T) {
 				Expected: []string{"../test-data/packer-plugin-happycloud/builder/happycloud/config.hcl2spec.go"},
 			},
 		},
+		{
+			[]string{"-type", "Config,Disk", "../test-data/nested-types/config.go"},
+			0,
+			FileCheck{
+				Expected: []string{"../test-data/nested-types/config.hcl2spec.go"},
+			},
+		},
 		{
 			[]string{"-type", "Config", "../test-data/field-conflict/test_mapstructure_field_conflict.go"},
 			1,
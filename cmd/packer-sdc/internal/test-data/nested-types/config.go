@@ -0,0 +1,22 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,Disk
+
+package nestedtypes
+
+// Config uses maps of structs, slices of pointers to structs and interface
+// fields.
+type Config struct {
+	Disks     map[string]Disk   `mapstructure:"disk"`
+	ExtraDisk map[string]*Disk  `mapstructure:"extra_disk"`
+	Mounts    []*Disk           `mapstructure:"mount"`
+	Metadata  interface{}       `mapstructure:"metadata"`
+	Labels    map[string]string `mapstructure:"labels"`
+}
+
+// Disk is a disk of the instance.
+type Disk struct {
+	Size int    `mapstructure:"size"`
+	Type string `mapstructure:"type"`
+}
@@ -0,0 +1,64 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package nestedtypes
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	Disks     map[string]FlatDisk  `mapstructure:"disk" cty:"disk" hcl:"disk"`
+	ExtraDisk map[string]*FlatDisk `mapstructure:"extra_disk" cty:"extra_disk" hcl:"extra_disk"`
+	Mounts    []*FlatDisk          `mapstructure:"mount" cty:"mount" hcl:"mount"`
+	Metadata  cty.Value            `mapstructure:"metadata" cty:"metadata" hcl:"metadata"`
+	Labels    map[string]string    `mapstructure:"labels" cty:"labels" hcl:"labels"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"disk":       &hcldec.BlockMapSpec{TypeName: "disk", LabelNames: []string{"name"}, Nested: hcldec.ObjectSpec((*FlatDisk)(nil).HCL2Spec())},
+		"extra_disk": &hcldec.BlockMapSpec{TypeName: "extra_disk", LabelNames: []string{"name"}, Nested: hcldec.ObjectSpec((*FlatDisk)(nil).HCL2Spec())},
+		"mount":      &hcldec.BlockListSpec{TypeName: "mount", Nested: hcldec.ObjectSpec((*FlatDisk)(nil).HCL2Spec())},
+		"metadata":   &hcldec.AttrSpec{Name: "metadata", Type: cty.DynamicPseudoType, Required: false},
+		"labels":     &hcldec.AttrSpec{Name: "labels", Type: cty.Map(cty.String), Required: false},
+	}
+	return s
+}
+
+// FlatDisk is an auto-generated flat version of Disk.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDisk struct {
+	Size *int    `mapstructure:"size" cty:"size" hcl:"size"`
+	Type *string `mapstructure:"type" cty:"type" hcl:"type"`
+}
+
+// FlatMapstructure returns a new FlatDisk.
+// FlatDisk is an auto-generated flat version of Disk.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Disk) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDisk)
+}
+
+// HCL2Spec returns the hcl spec of a Disk.
+// This spec is used by HCL to read the fields of Disk.
+// The decoded values from this spec will then be applied to a FlatDisk.
+func (*FlatDisk) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number, Required: false},
+		"type": &hcldec.AttrSpec{Name: "type", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -3,6 +3,12 @@
 
 package common
 
+import (
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/version"
+)
+
 const (
 	// This is the key in configurations that is set to the name of the
 	// build.
@@ -54,3 +60,16 @@ type PackerConfig struct {
 	PackerUserVars      map[string]string `mapstructure:"packer_user_variables"`
 	PackerSensitiveVars []string          `mapstructure:"packer_sensitive_variables"`
 }
+
+// RequireCoreVersion returns a *version.ConstraintError if the version of
+// the Packer core running the plugin doesn't satisfy constraints, like
+// ">= 1.10.0". Plugins call it in Configure, once the configuration is
+// decoded, to fail early with a friendly error. A core that doesn't send its
+// version, like in unit tests, satisfies all the constraints.
+func (c *PackerConfig) RequireCoreVersion(constraints string) error {
+	if c.PackerCoreVersion == "" {
+		log.Printf("[DEBUG] Packer core version unknown, skipping the constraints %q", constraints)
+		return nil
+	}
+	return version.Require("Packer", c.PackerCoreVersion, constraints)
+}
@@ -2,7 +2,7 @@
 // SPDX-License-Identifier: MPL-2.0
 
 //go:generate packer-sdc struct-markdown
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,SSH,WinRM,SSHTemporaryKeyPair
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,SSH,WinRM,SSHTemporaryKeyPair,SSHBastion,ConnectPolicy
 
 package communicator
 
@@ -11,14 +11,17 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
 	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/pathing"
 	packerssh "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	packerwinrm "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/winrm"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/masterzen/winrm"
@@ -26,6 +29,12 @@ import (
 	"golang.org/x/crypto/ssh/agent"
 )
 
+// Types are the valid communicator types, registered with didyoumean to
+// suggest one for an invalid type. A builder with a communicator of its own,
+// set up with StepConnect.CustomConnect, adds its type.
+var Types = didyoumean.Register(didyoumean.NewSet("communicator type",
+	"ssh", "winrm", "docker", "dockerWindowsContainer", "none"))
+
 // Config is the common configuration a builder uses to define and configure a Packer
 // communicator. Embed this struct in your builder config to implement
 // communicator support.
@@ -57,11 +66,60 @@ type Config struct {
 	// will disconnect and then wait 10 minutes before connecting to the guest
 	// and beginning provisioning.
 	PauseBeforeConnect time.Duration `mapstructure:"pause_before_connecting"`
+	// Limits the bandwidth of file uploads and downloads, in bytes per second
+	// or with a KB, MB or GB suffix, where 1KB is 1024 bytes: `5MB` uploads
+	// at most 5 MiB per second. Helpful when several builds share a
+	// constrained uplink. This applies to the files transferred one by one,
+	// not to whole directories. By default, transfers are not limited.
+	TransferBandwidthLimit string `mapstructure:"transfer_bandwidth_limit"`
+	// Reports the progress of file uploads and downloads with a progress
+	// bar. Defaults to `false`.
+	TransferProgress bool `mapstructure:"transfer_progress"`
+	// How long and how often Packer tries to connect to the communicator. It
+	// replaces `ssh_timeout`, `ssh_handshake_attempts` and `winrm_timeout`,
+	// which are still supported.
+	//
+	// ```hcl
+	// connect_policy {
+	//   max_wait           = "20m"
+	//   initial_backoff    = "2s"
+	//   max_backoff        = "30s"
+	//   backoff_multiplier = 2
+	// }
+	// ```
+	ConnectPolicy ConnectPolicy `mapstructure:"connect_policy"`
+
+	// SecretsSource, when set by the builder before Prepare, fills the
+	// credentials the configuration leaves empty, like the usernames and
+	// passwords, when StepConnect connects. Prepare doesn't require them
+	// then.
+	SecretsSource packersdk.SecretsSource `mapstructure:"-" mapstructure-to-hcl2:",skip" undocumented:"true"`
 
 	SSH   `mapstructure:",squash"`
 	WinRM `mapstructure:",squash"`
 }
 
+// ConnectPolicy defines how long and how often Packer tries to connect to
+// the machine. Unset options default to the values of the legacy options.
+type ConnectPolicy struct {
+	// The time to wait for the communicator to become available. This
+	// defaults to `ssh_timeout` or `winrm_timeout`.
+	MaxWait time.Duration `mapstructure:"max_wait"`
+	// The time to wait before the first retry. This defaults to `5s`.
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// The longest time to wait between two attempts. This defaults to `1m`.
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// The factor the time to wait is multiplied by after each attempt. This
+	// defaults to `1`, retrying at a constant pace.
+	BackoffMultiplier float64 `mapstructure:"backoff_multiplier"`
+	// The time to wait for the SSH server to send its banner and complete the
+	// handshake, once it accepted the connection. This defaults to `1m`.
+	BannerTimeout time.Duration `mapstructure:"banner_timeout"`
+	// The number of SSH handshakes to attempt once the machine accepts
+	// connections. This defaults to `ssh_handshake_attempts`.
+	MaxHandshakeAttempts int `mapstructure:"max_handshake_attempts"`
+}
+
 // The SSH config defines configuration for the SSH communicator.
 type SSH struct {
 	// The address to SSH to. This usually is automatically configured by the
@@ -72,7 +130,7 @@ type SSH struct {
 	// The username to connect to SSH with. Required if using SSH.
 	SSHUsername string `mapstructure:"ssh_username"`
 	// A plaintext password to use to authenticate with SSH.
-	SSHPassword string `mapstructure:"ssh_password"`
+	SSHPassword string `mapstructure:"ssh_password" packer:"sensitive"`
 	// If specified, this is the key that will be used for SSH with the
 	// machine. The key must match a key pair name loaded up into the remote.
 	// By default, this is blank, and Packer will generate a temporary keypair
@@ -115,10 +173,19 @@ type SSH struct {
 	// The `~` can be used in path and will be expanded to the home directory
 	// of current user.
 	SSHPrivateKeyFile string `mapstructure:"ssh_private_key_file" undocumented:"true"`
-	// Path to user certificate used to authenticate with SSH.
-	// The `~` can be used in path and will be expanded to the
-	// home directory of current user.
+	// Path to user certificate used to authenticate with SSH, signed with
+	// the key of `ssh_private_key_file`, or with the matching key of the SSH
+	// agent when only `ssh_agent_auth` is set. The certificate must be valid
+	// at the time of the build. The `~` can be used in path and will be
+	// expanded to the home directory of current user.
 	SSHCertificateFile string `mapstructure:"ssh_certificate_file"`
+	// The passphrase decrypting the private keys protected by one, like
+	// `ssh_private_key_file` or the keys of the bastion hosts. When it is not
+	// set, Packer asks for the passphrase when connecting; set it for
+	// unattended builds. Security keys, like `sk-ssh-ed25519@openssh.com`
+	// ones, can't be read from a file: add them to an SSH agent and use
+	// `ssh_agent_auth` instead.
+	SSHPrivateKeyPassphrase string `mapstructure:"ssh_private_key_passphrase" packer:"sensitive"`
 	// If `true`, a PTY will be requested for the SSH connection. This defaults
 	// to `false`.
 	SSHPty bool `mapstructure:"ssh_pty"`
@@ -134,7 +201,10 @@ type SSH struct {
 	// values of [`ssh_password`](#ssh_password) and
 	// [`ssh_private_key_file`](#ssh_private_key_file) will be ignored. The
 	// environment variable `SSH_AUTH_SOCK` must be set for this option to work
-	// properly.
+	// properly. The certificates held by the agent, like the ones issued by
+	// Vault, are offered first, skipping the ones that expired. This is also
+	// how security keys, like FIDO2 `sk-ssh-ed25519@openssh.com` ones, are
+	// used.
 	SSHAgentAuth bool `mapstructure:"ssh_agent_auth" undocumented:"true"`
 	// If true, SSH agent forwarding will be disabled. Defaults to `false`.
 	SSHDisableAgentForwarding bool `mapstructure:"ssh_disable_agent_forwarding"`
@@ -151,25 +221,81 @@ type SSH struct {
 	// The username to connect to the bastion host.
 	SSHBastionUsername string `mapstructure:"ssh_bastion_username"`
 	// The password to use to authenticate with the bastion host.
-	SSHBastionPassword string `mapstructure:"ssh_bastion_password"`
+	SSHBastionPassword string `mapstructure:"ssh_bastion_password" packer:"sensitive"`
 	// If `true`, the keyboard-interactive used to authenticate with bastion host.
 	SSHBastionInteractive bool `mapstructure:"ssh_bastion_interactive"`
 	// Path to a PEM encoded private key file to use to authenticate with the
 	// bastion host. The `~` can be used in path and will be expanded to the
 	// home directory of current user.
 	SSHBastionPrivateKeyFile string `mapstructure:"ssh_bastion_private_key_file"`
-	// Path to user certificate used to authenticate with bastion host.
-	// The `~` can be used in path and will be expanded to the
-	//home directory of current user.
+	// Path to user certificate used to authenticate with bastion host, signed
+	// with the key of `ssh_bastion_private_key_file`, or with the matching key
+	// of the SSH agent when only `ssh_bastion_agent_auth` is set. The `~` can
+	// be used in path and will be expanded to the home directory of current
+	// user.
 	SSHBastionCertificateFile string `mapstructure:"ssh_bastion_certificate_file"`
-	// `scp` or `sftp` - How to transfer files, Secure copy (default) or SSH
-	// File Transfer Protocol.
+	// A chain of bastion hosts to go through to reach the machine, for the
+	// networks that can only be reached through several bastions. The first
+	// bastion is connected to from the machine running Packer, each of the
+	// next ones through the previous one. Each bastion accepts the `host`,
+	// `port`, `username`, `password`, `agent_auth`, `interactive`,
+	// `private_key_file` and `certificate_file` options, that work like their
+	// `ssh_bastion_*` counterparts, and `keep_alive_interval`, which defaults
+	// to `ssh_keep_alive_interval`. Cannot be used with `ssh_bastion_host` or
+	// `ssh_proxy_host`.
+	//
+	// ```hcl
+	// ssh_bastion_hosts {
+	//   host       = "bastion.example.com"
+	//   username   = "jump"
+	//   agent_auth = true
+	// }
+	// ssh_bastion_hosts {
+	//   host             = "10.0.0.2"
+	//   username         = "jump"
+	//   private_key_file = "~/.ssh/build_network"
+	// }
+	// ```
+	SSHBastionHosts []SSHBastion `mapstructure:"ssh_bastion_hosts"`
+	// `scp`, `sftp` or `auto` - How to transfer files, Secure copy (default)
+	// or SSH File Transfer Protocol. With `auto`, the method is detected on
+	// the first transfer: SFTP when the server supports it, else SCP when it
+	// is installed, else shell commands (`cat`) for minimal appliance images
+	// that have neither. The method used is logged.
 	//
 	// **NOTE**: Guests using Windows with Win32-OpenSSH v9.1.0.0p1-Beta, scp
 	// (the default protocol for copying data) returns a a non-zero error code since the MOTW
 	// cannot be set, which cause any file transfer to fail. As a workaround you can override the transfer protocol
 	// with SFTP instead `ssh_file_transfer_method = "sftp"`.
 	SSHFileTransferMethod string `mapstructure:"ssh_file_transfer_method"`
+	// If `true`, directories are uploaded in a tar archive streamed to the
+	// machine and extracted there, which is much faster for directories
+	// holding many small files. This needs `tar` on the machine, files are
+	// uploaded one by one otherwise. Defaults to `false`.
+	SSHUploadDirTar bool `mapstructure:"ssh_upload_dir_tar"`
+	// Glob patterns of the files to upload from directories, all files are
+	// uploaded by default. Patterns without a `/` are matched against the
+	// names of files, like `*.js`, others against their path relative to the
+	// uploaded directory, like `lib/*.js`.
+	SSHUploadDirInclude []string `mapstructure:"ssh_upload_dir_include"`
+	// Glob patterns of the files and directories not to upload from
+	// directories, matched like `ssh_upload_dir_include`. For example
+	// `["node_modules", "*.log"]`.
+	SSHUploadDirExclude []string `mapstructure:"ssh_upload_dir_exclude"`
+	// How links are uploaded from directories: `follow` (the default)
+	// uploads the files and directories they point to, `preserve` recreates
+	// the links on the machine and `skip` ignores them. Links can't be
+	// preserved with `scp` without `ssh_upload_dir_tar`, they are followed.
+	SSHUploadDirSymlinks string `mapstructure:"ssh_upload_dir_symlinks"`
+	// The shell commands are run with on the machine. Set it to
+	// `powershell` to provision Windows machines through OpenSSH, for
+	// example hardened images where WinRM is disabled: commands are then
+	// run as PowerShell scripts with `powershell.exe`, like with WinRM, and
+	// files are transferred with SFTP or SCP. This is not PowerShell
+	// Remoting (PSRP) over SSH: the `powershell` subsystem of the server
+	// isn't used. By default, commands are run with the default shell of
+	// the SSH server.
+	SSHRemoteShell string `mapstructure:"ssh_remote_shell"`
 	// A SOCKS proxy host to use for SSH connection
 	SSHProxyHost string `mapstructure:"ssh_proxy_host"`
 	// A port of the SOCKS proxy. Defaults to `1080`.
@@ -177,10 +303,39 @@ type SSH struct {
 	// The optional username to authenticate with the proxy server.
 	SSHProxyUsername string `mapstructure:"ssh_proxy_username"`
 	// The optional password to use to authenticate with the proxy server.
-	SSHProxyPassword string `mapstructure:"ssh_proxy_password"`
+	SSHProxyPassword string `mapstructure:"ssh_proxy_password" packer:"sensitive"`
 	// How often to send "keep alive" messages to the server. Set to a negative
 	// value (`-1s`) to disable. Example value: `10s`. Defaults to `5s`.
 	SSHKeepAliveInterval time.Duration `mapstructure:"ssh_keep_alive_interval"`
+	// The number of keep alive messages in a row the server can leave
+	// without reply before Packer considers the connection dead, like
+	// OpenSSH's `ServerAliveCountMax`. The running command then fails right
+	// away with a clear error, rather than hanging when a firewall silently
+	// dropped an idle connection. Example value: `3`. Disabled by default.
+	SSHKeepAliveCountMax int `mapstructure:"ssh_keep_alive_count_max"`
+	// How the host key of the machine is verified, like OpenSSH's
+	// `StrictHostKeyChecking`:
+	//
+	// -   `no` - The host key is not verified.
+	//
+	// -   `yes` - The host key must be one of `ssh_host_key_fingerprints`,
+	//     or of the fingerprints provided by the builder, or be in
+	//     `ssh_known_hosts_file`.
+	//
+	// -   `accept-new` - Like `yes`, but the key of hosts that are not in
+	//     `ssh_known_hosts_file` yet is trusted on first use and added to it.
+	//
+	// Defaults to verifying the host key when there is something to verify
+	// it against, and to `no` otherwise.
+	SSHHostKeyChecking string `mapstructure:"ssh_host_key_checking"`
+	// Path to an OpenSSH `known_hosts` file to verify the host key against.
+	// The `~` can be used in path and will be expanded to the home directory
+	// of current user.
+	SSHKnownHostsFile string `mapstructure:"ssh_known_hosts_file"`
+	// The SHA256 fingerprints of the expected host keys, as printed by
+	// `ssh-keygen -l`. For example
+	// `["SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"]`.
+	SSHHostKeyFingerprints []string `mapstructure:"ssh_host_key_fingerprints"`
 	// The amount of time to wait for a remote command to end. This might be
 	// useful if, for example, packer hangs on a connection after a reboot.
 	// Example: `5m`. Disabled by default.
@@ -192,10 +347,54 @@ type SSH struct {
 	SSHRemoteTunnels []string `mapstructure:"ssh_remote_tunnels"`
 	//
 	SSHLocalTunnels []string `mapstructure:"ssh_local_tunnels"`
+	// Serve a SOCKS5 proxy on this `[bind_address:]port` of the machine,
+	// like `1080`, connecting through the SSH connection to the addresses
+	// its clients ask for, from the host running Packer. Like the `-R 1080`
+	// option of OpenSSH, it lets the machines of isolated networks reach the
+	// HTTP server of Packer or a package cache during provisioning, with
+	// `http_proxy=socks5h://localhost:1080` for example. Without
+	// bind_address, the proxy only listens on the localhost of the machine;
+	// binding other addresses requires the `GatewayPorts` option of sshd. The
+	// proxy doesn't authenticate its clients.
+	SSHRemoteSOCKSProxy string `mapstructure:"ssh_remote_socks_proxy"`
 
 	// SSH Internals
 	SSHPublicKey  []byte `mapstructure:"ssh_public_key" undocumented:"true"`
 	SSHPrivateKey []byte `mapstructure:"ssh_private_key" undocumented:"true"`
+	// SSHPrivateKeyPassphraseFunc, when set by the builder, provides the
+	// passphrase of the encrypted private keys called name instead of asking
+	// the user for it. ssh_private_key_passphrase takes precedence.
+	SSHPrivateKeyPassphraseFunc func(name string) ([]byte, error)
+}
+
+// SSHBastion is a bastion host of the `ssh_bastion_hosts` chain.
+type SSHBastion struct {
+	// The address of the bastion host, as reached from the previous one.
+	Host string `mapstructure:"host" required:"true"`
+	// The port of the bastion host. Defaults to `22`.
+	Port int `mapstructure:"port"`
+	// The username to connect to the bastion host.
+	Username string `mapstructure:"username"`
+	// The password to use to authenticate with the bastion host.
+	Password string `mapstructure:"password" packer:"sensitive"`
+	// If `true`, the local SSH agent will be used to authenticate with the
+	// bastion host. Defaults to `false`.
+	AgentAuth bool `mapstructure:"agent_auth"`
+	// If `true`, the keyboard-interactive used to authenticate with the
+	// bastion host.
+	Interactive bool `mapstructure:"interactive"`
+	// Path to a PEM encoded private key file to use to authenticate with the
+	// bastion host. The `~` can be used in path and will be expanded to the
+	// home directory of current user.
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	// Path to user certificate used to authenticate with the bastion host.
+	// The `~` can be used in path and will be expanded to the home directory
+	// of current user.
+	CertificateFile string `mapstructure:"certificate_file"`
+	// How often to send "keep alive" messages to the bastion host. Set to a
+	// negative value (`-1s`) to disable. Defaults to
+	// `ssh_keep_alive_interval`.
+	KeepAliveInterval time.Duration `mapstructure:"keep_alive_interval"`
 }
 
 // When no ssh credentials are specified, Packer will generate a temporary SSH
@@ -229,7 +428,7 @@ type WinRM struct {
 	// The username to use to connect to WinRM.
 	WinRMUser string `mapstructure:"winrm_username"`
 	// The password to use to connect to WinRM.
-	WinRMPassword string `mapstructure:"winrm_password"`
+	WinRMPassword string `mapstructure:"winrm_password" packer:"sensitive"`
 	// The address for WinRM to connect to.
 	//
 	// NOTE: If using an Amazon EBS builder, you can specify the interface
@@ -257,7 +456,38 @@ type WinRM struct {
 	// requirement for basic authentication to be enabled within the target
 	// guest. Further reading for remote connection authentication can be found
 	// [here](https://msdn.microsoft.com/en-us/library/aa384295(v=vs.85).aspx).
-	WinRMUseNTLM            bool `mapstructure:"winrm_use_ntlm"`
+	WinRMUseNTLM bool `mapstructure:"winrm_use_ntlm"`
+	// If `true`, Kerberos authentication will be used for WinRM, for domain
+	// joined machines where NTLM and basic authentication are forbidden.
+	// The credentials come from `winrm_kerberos_keytab`, from
+	// `winrm_password`, or else from the credentials cache filled by
+	// `kinit`. Over HTTPS, the authentication is bound to the TLS channel.
+	// Over HTTP, WinRM must allow unencrypted messages.
+	WinRMUseKerberos bool `mapstructure:"winrm_use_kerberos"`
+	// The Kerberos realm of `winrm_username`, defaults to the
+	// `default_realm` of the Kerberos configuration. The realm can also be
+	// part of the username, as in `packer@EXAMPLE.COM`.
+	WinRMKerberosRealm string `mapstructure:"winrm_kerberos_realm"`
+	// The path of a keytab holding the keys of `winrm_username`.
+	WinRMKerberosKeytab string `mapstructure:"winrm_kerberos_keytab"`
+	// The path of the credentials cache to use, defaults to `$KRB5CCNAME`
+	// or `/tmp/krb5cc_<uid>` when neither `winrm_kerberos_keytab` nor
+	// `winrm_password` are set.
+	WinRMKerberosCCache string `mapstructure:"winrm_kerberos_ccache"`
+	// The path of the Kerberos configuration, defaults to `$KRB5_CONFIG` or
+	// `/etc/krb5.conf`.
+	WinRMKerberosConfig string `mapstructure:"winrm_kerberos_config"`
+	// The service principal name of WinRM on the machine, defaults to
+	// `HTTP/<winrm_host>`.
+	WinRMKerberosSPN string `mapstructure:"winrm_kerberos_spn"`
+	// The size in bytes of the chunks files are uploaded in, defaults to
+	// 65536. Uploaded files are verified against their SHA256 hash before
+	// being moved to their destination.
+	WinRMUploadChunkSize int `mapstructure:"winrm_upload_chunk_size"`
+	// The number of parallel streams files are uploaded with, defaults to 1.
+	// Only files read from disk are uploaded in parallel; a few streams can
+	// speed up the upload of large files over high latency links.
+	WinRMUploadParallelism  int `mapstructure:"winrm_upload_parallelism"`
 	WinRMTransportDecorator func() winrm.Transporter
 }
 
@@ -324,14 +554,48 @@ func (c *Config) ReadSSHPrivateKeyFile() ([]byte, error) {
 	return privateKey, nil
 }
 
+// keyPassphrase returns the function providing the passphrase of the
+// encrypted private keys: ssh_private_key_passphrase, SSHPrivateKeyPassphraseFunc
+// or else the passphrase the user is asked for through ui, without echoing
+// it. It returns nil when none is available.
+func (c *Config) keyPassphrase(ui packersdk.Ui) helperssh.PassphraseFunc {
+	switch {
+	case c.SSHPrivateKeyPassphrase != "":
+		return func(string) ([]byte, error) {
+			return []byte(c.SSHPrivateKeyPassphrase), nil
+		}
+	case c.SSHPrivateKeyPassphraseFunc != nil:
+		return c.SSHPrivateKeyPassphraseFunc
+	case ui != nil:
+		return func(name string) ([]byte, error) {
+			passphrase, err := ui.AskSecret(fmt.Sprintf("Enter passphrase for SSH private key %s:", name))
+			if err != nil {
+				// Versions of Packer without secret prompts fail rather
+				// than echo the passphrase.
+				return nil, fmt.Errorf("Error asking for the passphrase of SSH private key %s, "+
+					"set ssh_private_key_passphrase instead: %s", name, err)
+			}
+			return []byte(passphrase), nil
+		}
+	}
+	return nil
+}
+
 // SSHConfigFunc returns a function that can be used for the SSH communicator
 // config for connecting to the instance created over SSH using the private key
 // or password.
 func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, error) {
+	// The decrypted private keys are remembered so that the user is only
+	// asked for their passphrase once, and not on every retry.
+	signers := map[string]ssh.Signer{}
 	return func(state multistep.StateBag) (*ssh.ClientConfig, error) {
+		hostKeyCallback, err := c.hostKeyCallback(state)
+		if err != nil {
+			return nil, err
+		}
 		sshConfig := &ssh.ClientConfig{
 			User:            c.SSHUsername,
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			HostKeyCallback: hostKeyCallback,
 		}
 		if len(c.SSHCiphers) != 0 {
 			sshConfig.Config.Ciphers = c.SSHCiphers
@@ -341,6 +605,15 @@ func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, er
 			sshConfig.Config.KeyExchanges = c.SSHKEXAlgos
 		}
 
+		certPath := ""
+		if c.SSHCertificateFile != "" {
+			var err error
+			certPath, err = pathing.ExpandUser(c.SSHCertificateFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		if c.SSHAgentAuth {
 			authSock := os.Getenv("SSH_AUTH_SOCK")
 			if authSock == "" {
@@ -352,41 +625,47 @@ func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, er
 				return nil, fmt.Errorf("Cannot connect to SSH Agent socket %q: %s", authSock, err)
 			}
 
-			sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers))
+			agentCertPath := ""
+			if c.SSHPrivateKeyFile == "" {
+				// The key of the certificate is held by the agent.
+				agentCertPath = certPath
+			}
+			sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeysCallback(agentSigners(agent.NewClient(sshAgent), agentCertPath)))
 		}
 
-		var privateKeys [][]byte
+		type privateKey struct {
+			name  string
+			bytes []byte
+		}
+		var privateKeys []privateKey
 		if c.SSHPrivateKeyFile != "" {
-			privateKey, err := c.ReadSSHPrivateKeyFile()
+			key, err := c.ReadSSHPrivateKeyFile()
 			if err != nil {
 				return nil, err
 			}
-			privateKeys = append(privateKeys, privateKey)
+			privateKeys = append(privateKeys, privateKey{c.SSHPrivateKeyFile, key})
 		}
 
 		// aws,alicloud,cloudstack,digitalOcean,oneAndOne,openstack,oracle & profitbricks key
 		if iKey, hasKey := state.GetOk("privateKey"); hasKey {
-			privateKeys = append(privateKeys, []byte(iKey.(string)))
+			privateKeys = append(privateKeys, privateKey{"privateKey", []byte(iKey.(string))})
 		}
 
 		if len(c.SSHPrivateKey) != 0 {
-			privateKeys = append(privateKeys, c.SSHPrivateKey)
-		}
-
-		certPath := ""
-		if c.SSHCertificateFile != "" {
-			var err error
-			certPath, err = pathing.ExpandUser(c.SSHCertificateFile)
-			if err != nil {
-				return nil, err
-			}
+			privateKeys = append(privateKeys, privateKey{"ssh_private_key", c.SSHPrivateKey})
 		}
 
+		ui, _ := state.Get("ui").(packersdk.Ui)
 		for _, key := range privateKeys {
 
-			signer, err := ssh.ParsePrivateKey(key)
-			if err != nil {
-				return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
+			signer, ok := signers[string(key.bytes)]
+			var err error
+			if !ok {
+				signer, err = helperssh.ParsePrivateKey(key.name, key.bytes, c.keyPassphrase(ui))
+				if err != nil {
+					return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
+				}
+				signers[string(key.bytes)] = signer
 			}
 
 			if certPath != "" {
@@ -409,6 +688,26 @@ func (c *Config) SSHConfigFunc() func(multistep.StateBag) (*ssh.ClientConfig, er
 	}
 }
 
+// agentSigners returns the signers to authenticate with using the SSH agent:
+// its valid keys and certificates, or when certPath is set, the certificate
+// at certPath signed with the matching key of the agent.
+func agentSigners(a agent.Agent, certPath string) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		signers, err := a.Signers()
+		if err != nil {
+			return nil, err
+		}
+		if certPath == "" {
+			return helperssh.ValidSigners(signers), nil
+		}
+		signer, err := helperssh.SignerWithCert(signers, certPath)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	}
+}
+
 // Port returns the port that will be used for access based on config.
 func (c *Config) Port() int {
 	switch c.Type {
@@ -463,6 +762,16 @@ func (c *Config) Prepare(ctx *interpolate.Context) []error {
 	}
 
 	var errs []error
+	if c.TransferBandwidthLimit != "" {
+		if _, err := parseBandwidth(c.TransferBandwidthLimit); err != nil {
+			errs = append(errs, fmt.Errorf("transfer_bandwidth_limit is invalid: %s", err))
+		}
+	}
+
+	if es := c.prepareConnectPolicy(); len(es) > 0 {
+		errs = append(errs, es...)
+	}
+
 	switch c.Type {
 	case "ssh":
 		if es := c.prepareSSH(ctx); len(es) > 0 {
@@ -472,15 +781,111 @@ func (c *Config) Prepare(ctx *interpolate.Context) []error {
 		if es := c.prepareWinRM(ctx); len(es) > 0 {
 			errs = append(errs, es...)
 		}
-	case "docker", "dockerWindowsContainer", "none":
-		break
 	default:
-		return []error{fmt.Errorf("Communicator type %s is invalid", c.Type)}
+		if !Types.Contains(c.Type) {
+			err := fmt.Errorf("Communicator type %s is invalid", c.Type)
+			if suggestion := Types.Suggest(c.Type); suggestion != "" {
+				err = fmt.Errorf("%s, did you mean %q?", err, suggestion)
+			}
+			return []error{err}
+		}
 	}
 
 	return errs
 }
 
+// prepareConnectPolicy validates the connect policy, and sets the legacy
+// options from it so that they are kept in sync.
+func (c *Config) prepareConnectPolicy() (errs []error) {
+	p := c.ConnectPolicy
+	for _, o := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"max_wait", p.MaxWait},
+		{"initial_backoff", p.InitialBackoff},
+		{"max_backoff", p.MaxBackoff},
+		{"banner_timeout", p.BannerTimeout},
+	} {
+		if o.d < 0 {
+			errs = append(errs, fmt.Errorf("connect_policy.%s must be positive", o.name))
+		}
+	}
+	if p.MaxHandshakeAttempts < 0 {
+		errs = append(errs, errors.New("connect_policy.max_handshake_attempts must be positive"))
+	}
+	if p.BackoffMultiplier != 0 && p.BackoffMultiplier < 1 {
+		errs = append(errs, errors.New("connect_policy.backoff_multiplier must be at least 1"))
+	}
+	if p.InitialBackoff > 0 && p.MaxBackoff > 0 && p.InitialBackoff > p.MaxBackoff {
+		errs = append(errs, errors.New("connect_policy.initial_backoff must not be greater than max_backoff"))
+	}
+
+	if p.MaxWait > 0 {
+		legacy := &c.SSHTimeout
+		name := "ssh_timeout"
+		if c.Type == "winrm" {
+			legacy, name = &c.WinRMTimeout, "winrm_timeout"
+		} else if c.SSHWaitTimeout != 0 {
+			legacy, name = &c.SSHWaitTimeout, "ssh_wait_timeout"
+		}
+		if *legacy != 0 && *legacy != p.MaxWait {
+			errs = append(errs, fmt.Errorf("connect_policy.max_wait and %s are both set, only set one", name))
+		}
+		*legacy = p.MaxWait
+	}
+	if p.MaxHandshakeAttempts > 0 {
+		if c.SSHHandshakeAttempts != 0 && c.SSHHandshakeAttempts != p.MaxHandshakeAttempts {
+			errs = append(errs, errors.New("connect_policy.max_handshake_attempts and ssh_handshake_attempts are both set, only set one"))
+		}
+		c.SSHHandshakeAttempts = p.MaxHandshakeAttempts
+	}
+	return errs
+}
+
+// EffectiveConnectPolicy returns the connect policy with the defaults and the
+// legacy options applied. Call it once the config is prepared.
+func (c *Config) EffectiveConnectPolicy() ConnectPolicy {
+	p := c.ConnectPolicy
+	if p.MaxWait == 0 {
+		p.MaxWait = c.SSHTimeout
+		if c.Type == "winrm" {
+			p.MaxWait = c.WinRMTimeout
+		}
+	}
+	if p.MaxHandshakeAttempts == 0 {
+		p.MaxHandshakeAttempts = c.SSHHandshakeAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = 5 * time.Second
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = time.Minute
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	if p.BackoffMultiplier == 0 {
+		p.BackoffMultiplier = 1
+	}
+	if p.BannerTimeout == 0 {
+		p.BannerTimeout = time.Minute
+	}
+	return p
+}
+
+// Backoff returns the time to wait before the given retry, starting at 1.
+func (p ConnectPolicy) Backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < retry && d < float64(p.MaxBackoff); i++ {
+		d *= p.BackoffMultiplier
+	}
+	if d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
 func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 	if c.SSHPort == 0 {
 		c.SSHPort = 22
@@ -499,11 +904,19 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 	// Validation
 	var errs []error
 	if c.SSHPrivateKeyFile == "" && c.SSHCertificateFile != "" {
-		errs = append(errs, fmt.Errorf("ssh_private_key_file must be specified if ssh_certificate_file is specified"))
+		if !c.SSHAgentAuth {
+			errs = append(errs, fmt.Errorf("ssh_private_key_file or ssh_agent_auth must be specified if ssh_certificate_file is specified"))
+		} else if err := validateCertificateFile(c.SSHCertificateFile); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_certificate_file is invalid: %s", err))
+		}
 	}
 
 	if c.SSHBastionPrivateKeyFile == "" && c.SSHBastionCertificateFile != "" {
-		errs = append(errs, fmt.Errorf("ssh_bastion_private_key_file must be specified if ssh_bastion_certificate_file is specified"))
+		if !c.SSHBastionAgentAuth {
+			errs = append(errs, fmt.Errorf("ssh_bastion_private_key_file or ssh_bastion_agent_auth must be specified if ssh_bastion_certificate_file is specified"))
+		} else if err := validateCertificateFile(c.SSHBastionCertificateFile); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_bastion_certificate_file is invalid: %s", err))
+		}
 	}
 
 	if c.SSHBastionHost != "" {
@@ -523,6 +936,16 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		}
 	}
 
+	for i := range c.SSHBastionHosts {
+		b := &c.SSHBastionHosts[i]
+		if b.Port == 0 {
+			b.Port = 22
+		}
+		if b.KeepAliveInterval == 0 {
+			b.KeepAliveInterval = c.SSHKeepAliveInterval
+		}
+	}
+
 	if c.SSHFileTransferMethod == "" {
 		c.SSHFileTransferMethod = "scp"
 	}
@@ -532,7 +955,7 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		c.SSHTimeout = c.SSHWaitTimeout
 	}
 
-	if c.SSHUsername == "" {
+	if c.SSHUsername == "" && c.SecretsSource == nil {
 		errs = append(errs, errors.New("An ssh_username must be specified\n  Note: some builders used to default ssh_username to \"root\"."))
 	}
 
@@ -551,12 +974,12 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 					errs = append(errs, fmt.Errorf("invalid identity certificate: #{err}"))
 				}
 
-				if _, err := helperssh.FileSignerWithCert(path, certPath); err != nil {
+				if err := c.validateKeyFile(path, certPath); err != nil {
 					errs = append(errs, fmt.Errorf(
 						"ssh_private_key_file is invalid: %s", err))
 				}
 			} else {
-				if _, err := helperssh.FileSigner(path); err != nil {
+				if err := c.validateKeyFile(path, ""); err != nil {
 					errs = append(errs, fmt.Errorf(
 						"ssh_private_key_file is invalid: %s", err))
 				}
@@ -566,8 +989,10 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 
 	if c.SSHBastionHost != "" {
 		if c.SSHBastionPassword == "" && c.SSHBastionPrivateKeyFile == "" && !c.SSHBastionAgentAuth {
-			errs = append(errs, errors.New(
-				"ssh_bastion_password, ssh_bastion_private_key_file or ssh_bastion_agent_auth must be specified"))
+			if c.SecretsSource == nil {
+				errs = append(errs, errors.New(
+					"ssh_bastion_password, ssh_bastion_private_key_file or ssh_bastion_agent_auth must be specified"))
+			}
 		} else if c.SSHBastionPrivateKeyFile != "" {
 			path, err := pathing.ExpandUser(c.SSHBastionPrivateKeyFile)
 			if err != nil {
@@ -582,12 +1007,12 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 					if err != nil {
 						errs = append(errs, fmt.Errorf("invalid identity certificate: #{err}"))
 					}
-					if _, err := helperssh.FileSignerWithCert(path, certPath); err != nil {
+					if err := c.validateKeyFile(path, certPath); err != nil {
 						errs = append(errs, fmt.Errorf(
 							"ssh_bastion_private_key_file is invalid: %s", err))
 					}
 				} else {
-					if _, err := helperssh.FileSigner(path); err != nil {
+					if err := c.validateKeyFile(path, ""); err != nil {
 						errs = append(errs, fmt.Errorf(
 							"ssh_bastion_private_key_file is invalid: %s", err))
 					}
@@ -596,16 +1021,63 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		}
 	}
 
-	if c.SSHFileTransferMethod != "scp" && c.SSHFileTransferMethod != "sftp" {
+	for i, b := range c.SSHBastionHosts {
+		if b.Host == "" {
+			errs = append(errs, fmt.Errorf("ssh_bastion_hosts[%d]: host must be specified", i))
+		}
+		if b.Password == "" && b.PrivateKeyFile == "" && !b.AgentAuth && !b.Interactive && c.SecretsSource == nil {
+			errs = append(errs, fmt.Errorf(
+				"ssh_bastion_hosts[%d]: password, private_key_file, agent_auth or interactive must be specified", i))
+		}
+		if b.PrivateKeyFile == "" && b.CertificateFile != "" {
+			if !b.AgentAuth {
+				errs = append(errs, fmt.Errorf(
+					"ssh_bastion_hosts[%d]: private_key_file or agent_auth must be specified if certificate_file is specified", i))
+			} else if err := validateCertificateFile(b.CertificateFile); err != nil {
+				errs = append(errs, fmt.Errorf("ssh_bastion_hosts[%d]: certificate_file is invalid: %s", i, err))
+			}
+		} else if b.PrivateKeyFile != "" {
+			if _, err := b.signer(c.keyPassphrase(nil)); err != nil && !errors.As(err, new(*ssh.PassphraseMissingError)) {
+				errs = append(errs, fmt.Errorf("ssh_bastion_hosts[%d]: private_key_file is invalid: %s", i, err))
+			}
+		}
+	}
+
+	if c.SSHFileTransferMethod != "scp" && c.SSHFileTransferMethod != "sftp" && c.SSHFileTransferMethod != "auto" {
 		errs = append(errs, fmt.Errorf(
-			"ssh_file_transfer_method ('%s') is invalid, valid methods: sftp, scp",
+			"ssh_file_transfer_method ('%s') is invalid, valid methods: sftp, scp, auto",
 			c.SSHFileTransferMethod))
 	}
 
+	if c.SSHRemoteShell != "" && c.SSHRemoteShell != "powershell" {
+		errs = append(errs, fmt.Errorf(
+			"ssh_remote_shell ('%s') is invalid, valid shells: powershell", c.SSHRemoteShell))
+	}
+
+	errs = append(errs, c.prepareHostKeyChecking()...)
+
+	switch packerssh.SymlinkPolicy(c.SSHUploadDirSymlinks) {
+	case "", packerssh.SymlinksFollow, packerssh.SymlinksPreserve, packerssh.SymlinksSkip:
+	default:
+		errs = append(errs, fmt.Errorf(
+			"ssh_upload_dir_symlinks ('%s') is invalid, valid policies: follow, preserve, skip",
+			c.SSHUploadDirSymlinks))
+	}
+
+	for _, p := range append(append([]string{}, c.SSHUploadDirInclude...), c.SSHUploadDirExclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			errs = append(errs, fmt.Errorf("upload pattern %q is invalid: %s", p, err))
+		}
+	}
+
 	if c.SSHBastionHost != "" && c.SSHProxyHost != "" {
 		errs = append(errs, errors.New("please specify either ssh_bastion_host or ssh_proxy_host, not both"))
 	}
 
+	if len(c.SSHBastionHosts) > 0 && (c.SSHBastionHost != "" || c.SSHProxyHost != "") {
+		errs = append(errs, errors.New("ssh_bastion_hosts cannot be used with ssh_bastion_host or ssh_proxy_host"))
+	}
+
 	for _, v := range c.SSHLocalTunnels {
 		_, err := helperssh.ParseTunnelArgument(v, packerssh.UnsetTunnel)
 		if err != nil {
@@ -622,9 +1094,82 @@ func (c *Config) prepareSSH(ctx *interpolate.Context) []error {
 		}
 	}
 
+	if c.SSHRemoteSOCKSProxy != "" {
+		if _, err := helperssh.ParseSOCKSArgument(c.SSHRemoteSOCKSProxy, packerssh.UnsetTunnel); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"ssh_remote_socks_proxy ('%s') is invalid: %s", c.SSHRemoteSOCKSProxy, err))
+		}
+	}
+
 	return errs
 }
 
+// validateKeyFile checks that the private key at path can be used, along with
+// the user certificate at certPath when set. Keys protected by a passphrase
+// that isn't configured are valid: it is asked for when connecting.
+func (c *Config) validateKeyFile(path, certPath string) error {
+	signer, err := helperssh.FileSignerWithPassphrase(path, c.keyPassphrase(nil))
+	if errors.As(err, new(*ssh.PassphraseMissingError)) {
+		if certPath == "" {
+			return nil
+		}
+		_, err = helperssh.ParseCertificateFile(certPath)
+		return err
+	}
+	if err != nil || certPath == "" {
+		return err
+	}
+	_, err = helperssh.ReadCertificate(certPath, signer)
+	return err
+}
+
+// validateCertificateFile checks that the user certificate at path, used
+// with a key of the SSH agent, is currently valid.
+func validateCertificateFile(path string) error {
+	path, err := pathing.ExpandUser(path)
+	if err != nil {
+		return err
+	}
+	_, err = helperssh.ParseCertificateFile(path)
+	return err
+}
+
+// signer reads the private key of the bastion, decrypted with passphrase when
+// it is protected by one, along with its certificate if any.
+func (b *SSHBastion) signer(passphrase helperssh.PassphraseFunc) (ssh.Signer, error) {
+	path, err := pathing.ExpandUser(b.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error expanding path for SSH bastion private key: %s", err)
+	}
+	signer, err := helperssh.FileSignerWithPassphrase(path, passphrase)
+	if err != nil || b.CertificateFile == "" {
+		return signer, err
+	}
+	certPath, err := pathing.ExpandUser(b.CertificateFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error expanding path for SSH bastion identity certificate: %s", err)
+	}
+	return helperssh.ReadCertificate(certPath, signer)
+}
+
+// bastionHosts returns the bastion hosts to go through to reach the machine:
+// either ssh_bastion_host or the ssh_bastion_hosts chain.
+func (c *Config) bastionHosts() []SSHBastion {
+	if c.SSHBastionHost == "" {
+		return c.SSHBastionHosts
+	}
+	return []SSHBastion{{
+		Host:            c.SSHBastionHost,
+		Port:            c.SSHBastionPort,
+		Username:        c.SSHBastionUsername,
+		Password:        c.SSHBastionPassword,
+		AgentAuth:       c.SSHBastionAgentAuth,
+		Interactive:     c.SSHBastionInteractive,
+		PrivateKeyFile:  c.SSHBastionPrivateKeyFile,
+		CertificateFile: c.SSHBastionCertificateFile,
+	}}
+}
+
 func (c *Config) prepareWinRM(ctx *interpolate.Context) (errs []error) {
 	if c.WinRMPort == 0 && c.WinRMUseSSL {
 		c.WinRMPort = 5986
@@ -636,11 +1181,36 @@ func (c *Config) prepareWinRM(ctx *interpolate.Context) (errs []error) {
 		c.WinRMTimeout = 30 * time.Minute
 	}
 
+	if c.WinRMUploadChunkSize < 0 {
+		errs = append(errs, errors.New("winrm_upload_chunk_size must be positive"))
+	} else if c.WinRMUploadChunkSize == 0 {
+		c.WinRMUploadChunkSize = packerwinrm.DefaultUploadChunkSize
+	}
+
+	if c.WinRMUploadParallelism < 0 {
+		errs = append(errs, errors.New("winrm_upload_parallelism must be positive"))
+	} else if c.WinRMUploadParallelism == 0 {
+		c.WinRMUploadParallelism = 1
+	}
+
 	if c.WinRMUseNTLM {
 		c.WinRMTransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
 	}
 
-	if c.WinRMUser == "" {
+	if c.WinRMUseKerberos {
+		if c.WinRMUseNTLM {
+			errs = append(errs, errors.New("winrm_use_kerberos and winrm_use_ntlm cannot both be set"))
+		}
+		if c.WinRMKerberosKeytab != "" && c.WinRMKerberosCCache != "" {
+			errs = append(errs, errors.New("please specify either winrm_kerberos_keytab or winrm_kerberos_ccache, not both"))
+		}
+		c.WinRMTransportDecorator = c.kerberosTransportDecorator
+	} else if c.WinRMKerberosRealm != "" || c.WinRMKerberosKeytab != "" || c.WinRMKerberosCCache != "" ||
+		c.WinRMKerberosConfig != "" || c.WinRMKerberosSPN != "" {
+		errs = append(errs, errors.New("winrm_kerberos_* options need winrm_use_kerberos"))
+	}
+
+	if c.WinRMUser == "" && c.SecretsSource == nil {
 		errs = append(errs, errors.New("winrm_username must be specified."))
 	}
 
@@ -10,15 +10,20 @@ import (
 	"encoding/pem"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/masterzen/winrm"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 func testConfig() *Config {
@@ -151,6 +156,120 @@ func TestConfig_winrm_use_ntlm(t *testing.T) {
 
 }
 
+func TestConfig_winrm_use_kerberos(t *testing.T) {
+	c := &Config{
+		Type: "winrm",
+		WinRM: WinRM{
+			WinRMUser:           "admin@EXAMPLE.COM",
+			WinRMUseKerberos:    true,
+			WinRMKerberosKeytab: "admin.keytab",
+		},
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.WinRMTransportDecorator == nil {
+		t.Fatalf("WinRMTransportDecorator not set.")
+	}
+
+	c.WinRMKerberosCCache = "/tmp/krb5cc_0"
+	c.WinRMUseNTLM = true
+	if err := c.Prepare(testContext(t)); len(err) != 2 {
+		t.Fatalf("expected NTLM and the credentials to conflict, got %v", err)
+	}
+
+	c = &Config{
+		Type: "winrm",
+		WinRM: WinRM{
+			WinRMUser:          "admin",
+			WinRMKerberosRealm: "EXAMPLE.COM",
+		},
+	}
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("expected winrm_use_kerberos to be missing, got %v", err)
+	}
+}
+
+func TestConfig_winrm_upload(t *testing.T) {
+	c := &Config{
+		Type:  "winrm",
+		WinRM: WinRM{WinRMUser: "admin"},
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.WinRMUploadChunkSize != 64*1024 || c.WinRMUploadParallelism != 1 {
+		t.Fatalf("unexpected upload defaults %d, %d", c.WinRMUploadChunkSize, c.WinRMUploadParallelism)
+	}
+
+	c.WinRMUploadChunkSize = -1
+	c.WinRMUploadParallelism = -1
+	if err := c.Prepare(testContext(t)); len(err) != 2 {
+		t.Fatalf("expected negative upload options to be invalid, got %v", err)
+	}
+}
+
+func TestConfig_connectPolicy(t *testing.T) {
+	// The legacy options are used when there is no policy.
+	c := &Config{SSH: SSH{SSHUsername: "root"}}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	expected := ConnectPolicy{
+		MaxWait:              5 * time.Minute,
+		InitialBackoff:       5 * time.Second,
+		MaxBackoff:           time.Minute,
+		BackoffMultiplier:    1,
+		BannerTimeout:        time.Minute,
+		MaxHandshakeAttempts: 10,
+	}
+	if diff := cmp.Diff(expected, c.EffectiveConnectPolicy()); diff != "" {
+		t.Fatalf("unexpected policy: %s", diff)
+	}
+
+	// The policy sets the legacy options.
+	c = &Config{
+		Type:          "winrm",
+		ConnectPolicy: ConnectPolicy{MaxWait: time.Hour},
+		WinRM:         WinRM{WinRMUser: "admin"},
+	}
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+	if c.WinRMTimeout != time.Hour || c.EffectiveConnectPolicy().MaxWait != time.Hour {
+		t.Fatalf("unexpected timeout %s", c.WinRMTimeout)
+	}
+
+	for _, c := range []*Config{
+		{ConnectPolicy: ConnectPolicy{MaxWait: time.Hour}, SSH: SSH{SSHTimeout: time.Minute}},
+		{ConnectPolicy: ConnectPolicy{MaxHandshakeAttempts: 3}, SSH: SSH{SSHHandshakeAttempts: 4}},
+		{ConnectPolicy: ConnectPolicy{BannerTimeout: -time.Second}},
+		{ConnectPolicy: ConnectPolicy{BackoffMultiplier: 0.5}},
+		{ConnectPolicy: ConnectPolicy{InitialBackoff: time.Minute, MaxBackoff: time.Second}},
+	} {
+		c.SSHUsername = "root"
+		if err := c.Prepare(testContext(t)); len(err) != 1 {
+			t.Fatalf("expected %#v to be invalid, got %v", c.ConnectPolicy, err)
+		}
+	}
+}
+
+func TestConnectPolicy_Backoff(t *testing.T) {
+	p := ConnectPolicy{
+		InitialBackoff:    time.Second,
+		MaxBackoff:        10 * time.Second,
+		BackoffMultiplier: 2,
+	}
+	for retry, expected := range []time.Duration{1: time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if retry == 0 {
+			continue
+		}
+		if d := p.Backoff(retry); d != expected {
+			t.Fatalf("retry %d: expected %s, got %s", retry, expected, d)
+		}
+	}
+}
+
 // generateSSHPrivateKey generates a new RSA SSH private key for use in tests
 //
 // It returns the path in which the key was created.
@@ -502,6 +621,206 @@ func TestSSHBastion(t *testing.T) {
 	}
 }
 
+func TestSSHBastionHosts(t *testing.T) {
+	privKeyPath, certKeyPath, certPath, err := generateSSHKeys()
+	if err != nil {
+		t.Fatalf("failed to generate SSH keys and certificates: %s", err)
+	}
+
+	defer func() {
+		os.Remove(privKeyPath)
+		os.Remove(certKeyPath)
+		os.Remove(certPath)
+	}()
+
+	c := &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHUsername: "root",
+			SSHBastionHosts: []SSHBastion{
+				{Host: "first.bastion", Username: "jump", AgentAuth: true},
+				{Host: "10.0.0.2", Port: 2222, Username: "jump", PrivateKeyFile: privKeyPath, CertificateFile: certPath, KeepAliveInterval: -1},
+			},
+		},
+	}
+	if errs := c.Prepare(testContext(t)); len(errs) != 0 {
+		t.Fatalf("bad: %v", errs)
+	}
+	expected := []SSHBastion{
+		{Host: "first.bastion", Port: 22, Username: "jump", AgentAuth: true, KeepAliveInterval: 5 * time.Second},
+		{Host: "10.0.0.2", Port: 2222, Username: "jump", PrivateKeyFile: privKeyPath, CertificateFile: certPath, KeepAliveInterval: -1},
+	}
+	if diff := cmp.Diff(expected, c.bastionHosts()); diff != "" {
+		t.Fatalf("unexpected bastion hosts: %s", diff)
+	}
+
+	for name, bastions := range map[string][]SSHBastion{
+		"no host":             {{Username: "jump", AgentAuth: true}},
+		"no auth":             {{Host: "first.bastion", Username: "jump"}},
+		"no private key":      {{Host: "first.bastion", Username: "jump", Password: "test", CertificateFile: certPath}},
+		"invalid private key": {{Host: "first.bastion", Username: "jump", PrivateKeyFile: certPath}},
+	} {
+		c := &Config{Type: "ssh", SSH: SSH{SSHUsername: "root", SSHBastionHosts: bastions}}
+		if errs := c.Prepare(testContext(t)); len(errs) != 1 {
+			t.Errorf("%s: expected an error, got %v", name, errs)
+		}
+	}
+
+	c = &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHUsername:        "root",
+			SSHBastionHost:     "my.bastion",
+			SSHBastionPassword: "test",
+			SSHBastionHosts:    []SSHBastion{{Host: "first.bastion", AgentAuth: true}},
+		},
+	}
+	if errs := c.Prepare(testContext(t)); len(errs) != 1 {
+		t.Fatalf("ssh_bastion_host and ssh_bastion_hosts should conflict, got %v", errs)
+	}
+}
+
+func TestSSHPrivateKeyPassphrase(t *testing.T) {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(pk, "", []byte("foo"))
+	if err != nil {
+		t.Fatalf("failed to encrypt key: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHUsername:       "packer",
+			SSHPrivateKeyFile: path,
+		},
+	}
+	if errs := c.Prepare(testContext(t)); len(errs) > 0 {
+		t.Fatalf("the passphrase should be asked for when connecting, got %v", errs)
+	}
+
+	ui := new(packersdk.MockUi)
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+	f := c.SSHConfigFunc()
+	for i := 0; i < 2; i++ {
+		ui.AskSecretCalled = false
+		sshConfig, err := f(state)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if len(sshConfig.Auth) != 1 {
+			t.Fatalf("expected the decrypted key to be used, got %d auth methods", len(sshConfig.Auth))
+		}
+		if ui.AskSecretCalled != (i == 0) || ui.AskCalled {
+			t.Fatalf("the passphrase should be asked for once, without echo, call %d asked: %t", i, ui.AskSecretCalled)
+		}
+	}
+
+	c.SSHPrivateKeyPassphrase = "bar"
+	if errs := c.Prepare(testContext(t)); len(errs) != 1 {
+		t.Fatalf("should error with a wrong ssh_private_key_passphrase, got %v", errs)
+	}
+}
+
+func TestSSHUploadDir(t *testing.T) {
+	c := &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHUsername:         "packer",
+			SSHPassword:         "packer",
+			SSHUploadDirExclude: []string{"node_modules", "*.log"},
+		},
+	}
+	if errs := c.Prepare(testContext(t)); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+
+	c.SSHUploadDirSymlinks = "copy"
+	c.SSHUploadDirInclude = []string{"[a-"}
+	if errs := c.Prepare(testContext(t)); len(errs) != 2 {
+		t.Fatalf("expected the policy and the pattern to be invalid, got %v", errs)
+	}
+}
+
+func TestSSHRemoteShell(t *testing.T) {
+	c := testConfig()
+	c.SSHRemoteShell = "powershell"
+	if errs := c.Prepare(testContext(t)); len(errs) > 0 {
+		t.Fatalf("err: %v", errs)
+	}
+
+	c.SSHRemoteShell = "bash"
+	if errs := c.Prepare(testContext(t)); len(errs) != 1 {
+		t.Fatalf("expected ssh_remote_shell to be invalid, got %v", errs)
+	}
+}
+
+func TestAgentSigners(t *testing.T) {
+	privKeyPath, certKeyPath, certPath, err := generateSSHKeys()
+	if err != nil {
+		t.Fatalf("failed to generate SSH keys and certificates: %s", err)
+	}
+
+	defer func() {
+		os.Remove(privKeyPath)
+		os.Remove(certKeyPath)
+		os.Remove(certPath)
+	}()
+
+	c := &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHUsername:        "root",
+			SSHAgentAuth:       true,
+			SSHCertificateFile: certPath,
+		},
+	}
+	if errs := c.Prepare(testContext(t)); len(errs) != 0 {
+		t.Fatalf("a certificate should be usable with the agent: %v", errs)
+	}
+
+	keyring := agent.NewKeyring()
+	for _, path := range []string{certKeyPath, privKeyPath} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		key, err := ssh.ParseRawPrivateKey(b)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	signers, err := agentSigners(keyring, "")()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(signers) != 2 {
+		t.Fatalf("expected the keys of the agent, got %d signers", len(signers))
+	}
+
+	signers, err = agentSigners(keyring, certPath)()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(signers) != 1 {
+		t.Fatalf("expected the certificate signer only, got %d signers", len(signers))
+	}
+	if cert, ok := signers[0].PublicKey().(*ssh.Certificate); !ok || cert.KeyId != "TestSSHCert" {
+		t.Fatalf("expected a signer for the certificate, got %#v", signers[0].PublicKey())
+	}
+}
+
 func TestSSHConfigFunc_ciphers(t *testing.T) {
 	state := new(multistep.BasicStateBag)
 
@@ -574,6 +893,43 @@ func TestSSHConfigFunc_kexAlgos(t *testing.T) {
 	}
 }
 
+func TestConfig_sshRemoteSOCKSProxy(t *testing.T) {
+	c := testConfig()
+	c.SSHRemoteSOCKSProxy = "1080"
+	if err := c.Prepare(testContext(t)); len(err) > 0 {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	c = testConfig()
+	c.SSHRemoteSOCKSProxy = "localhost:socks"
+	if err := c.Prepare(testContext(t)); len(err) != 1 {
+		t.Fatalf("an invalid port should fail: %#v", err)
+	}
+}
+
+func TestConfig_sensitiveValues(t *testing.T) {
+	c := &Config{
+		SSH: SSH{
+			SSHUsername:             "root",
+			SSHPassword:             "ssh-password",
+			SSHPrivateKeyPassphrase: "passphrase",
+			SSHBastionPassword:      "bastion-password",
+			SSHProxyPassword:        "proxy-password",
+			SSHBastionHosts:         []SSHBastion{{Host: "bastion", Password: "hop-password"}},
+		},
+		WinRM: WinRM{
+			WinRMPassword: "winrm-password",
+		},
+	}
+	got := config.SensitiveValues(c)
+	want := []string{"ssh-password", "passphrase", "bastion-password", "proxy-password", "hop-password", "winrm-password"}
+	sort.Strings(got)
+	sort.Strings(want)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("bad sensitive values: %s", diff)
+	}
+}
+
 func TestConfig_winrm(t *testing.T) {
 	c := &Config{
 		Type: "winrm",
@@ -589,3 +945,17 @@ func TestConfig_winrm(t *testing.T) {
 func testContext(t *testing.T) *interpolate.Context {
 	return nil
 }
+
+func TestConfig_badtypeSuggestion(t *testing.T) {
+	c := &Config{Type: "shh"}
+	errs := c.Prepare(testContext(t))
+	if len(errs) != 1 || errs[0].Error() != `Communicator type shh is invalid, did you mean "ssh"?` {
+		t.Fatalf("bad errors: %v", errs)
+	}
+
+	Types.Add("serial")
+	c = &Config{Type: "serial"}
+	if errs := c.Prepare(testContext(t)); len(errs) != 0 {
+		t.Fatalf("an added type should be valid: %v", errs)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// LinuxBootIDCommand prints the ID of the current boot of Linux.
+	LinuxBootIDCommand = "cat /proc/sys/kernel/random/boot_id"
+	// WindowsBootIDCommand prints the time Windows booted at.
+	WindowsBootIDCommand = `powershell.exe -NoProfile -NonInteractive -Command "(Get-CimInstance -ClassName Win32_OperatingSystem).LastBootUpTime.ToUniversalTime().ToString('o')"`
+)
+
+// ExpectedDisconnect waits for the machine to reboot, for the provisioners
+// rebooting it:
+//
+//	d := &communicator.ExpectedDisconnect{}
+//	if err := d.Prepare(ctx, comm); err != nil {
+//		return err
+//	}
+//	// Reboot the machine.
+//	if err := d.Wait(ctx, comm); err != nil {
+//		return err
+//	}
+//
+// or d.Run(ctx, comm, "sudo reboot"). Prepare notes the boot ID of the
+// machine, and Wait waits for the communicator to lose its connection, to
+// reconnect with the same configuration and for the boot ID to change, so
+// that the provisioning doesn't go on before the reboot or on a machine that
+// didn't reboot.
+type ExpectedDisconnect struct {
+	// BootIDCommand prints an ID changing with each boot, like its time.
+	// Defaults to LinuxBootIDCommand.
+	BootIDCommand string
+	// DisconnectTimeout is the time the machine has to reboot. Defaults to
+	// 5 minutes.
+	DisconnectTimeout time.Duration
+	// ReconnectTimeout is the time the machine has to reboot and be
+	// reachable again. Defaults to 30 minutes.
+	ReconnectTimeout time.Duration
+	// RetryDelay is the time between the attempts to reconnect. Defaults to
+	// 5 seconds.
+	RetryDelay time.Duration
+	// ReadinessProbes are run once reconnected, in order, like by
+	// StepConnect.
+	ReadinessProbes []ReadinessProbe
+
+	bootID string
+}
+
+// Prepare notes the boot ID of the machine, before rebooting it.
+func (d *ExpectedDisconnect) Prepare(ctx context.Context, comm packersdk.Communicator) error {
+	bootID, err := runCommand(ctx, comm, d.bootIDCommand(), 0)
+	if err != nil {
+		return fmt.Errorf("Error getting the boot ID of the machine: %s", err)
+	}
+	d.bootID = strings.TrimSpace(bootID)
+	if d.bootID == "" {
+		return fmt.Errorf("Error getting the boot ID of the machine: %q printed nothing", d.bootIDCommand())
+	}
+	log.Printf("[DEBUG] Boot ID before the reboot: %s", d.bootID)
+	return nil
+}
+
+// Run starts command, expected to reboot the machine, and waits for the
+// reboot. Its exit status is ignored, as the disconnection may interrupt
+// it. Prepare is called first if it wasn't.
+func (d *ExpectedDisconnect) Run(ctx context.Context, comm packersdk.Communicator, command string) error {
+	if d.bootID == "" {
+		if err := d.Prepare(ctx, comm); err != nil {
+			return err
+		}
+	}
+	cmd := &packersdk.RemoteCmd{Command: command}
+	if err := comm.Start(ctx, cmd); err != nil {
+		// The connection may be lost before the command is confirmed.
+		log.Printf("[DEBUG] Error starting %q, waiting for the reboot anyway: %s", command, err)
+	}
+	return d.Wait(ctx, comm)
+}
+
+// Wait waits for the machine to reboot and for the communicator to
+// reconnect, and runs the ReadinessProbes.
+func (d *ExpectedDisconnect) Wait(ctx context.Context, comm packersdk.Communicator) error {
+	if d.bootID == "" {
+		return fmt.Errorf("ExpectedDisconnect.Prepare must be called before the reboot")
+	}
+	disconnectTimeout := d.DisconnectTimeout
+	if disconnectTimeout == 0 {
+		disconnectTimeout = 5 * time.Minute
+	}
+	reconnectTimeout := d.ReconnectTimeout
+	if reconnectTimeout == 0 {
+		reconnectTimeout = 30 * time.Minute
+	}
+	retryDelay := d.RetryDelay
+	if retryDelay == 0 {
+		retryDelay = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reconnectTimeout)
+	defer cancel()
+	disconnectDeadline := time.Now().Add(disconnectTimeout)
+	disconnected := false
+
+	var lastErr error
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				if !disconnected {
+					return fmt.Errorf("the machine didn't reboot within %s", reconnectTimeout)
+				}
+				return fmt.Errorf("the machine wasn't reachable again within %s: %s", reconnectTimeout, lastErr)
+			case <-time.After(retryDelay):
+			}
+		}
+
+		bootID, err := runCommand(ctx, comm, d.bootIDCommand(), 0)
+		bootID = strings.TrimSpace(bootID)
+		switch {
+		case err != nil:
+			if !disconnected {
+				log.Printf("[INFO] Disconnected from the machine: %s", err)
+			}
+			disconnected = true
+			if ctx.Err() == nil || lastErr == nil {
+				lastErr = err
+			}
+			continue
+		case bootID == d.bootID:
+			// Either not rebooted yet, or the connection dropped for
+			// another reason.
+			if time.Now().After(disconnectDeadline) {
+				return fmt.Errorf("the machine didn't reboot within %s: its boot ID is still %s", disconnectTimeout, bootID)
+			}
+			continue
+		}
+
+		log.Printf("[INFO] Reconnected to the machine, booted with the ID %s", bootID)
+		d.bootID = bootID
+		break
+	}
+
+	for _, probe := range d.ReadinessProbes {
+		if err := probe.wait(ctx, comm, func(int) time.Duration { return retryDelay }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *ExpectedDisconnect) bootIDCommand() string {
+	if d.BootIDCommand != "" {
+		return d.BootIDCommand
+	}
+	return LinuxBootIDCommand
+}
@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
+)
+
+func TestExpectedDisconnect(t *testing.T) {
+	// The boot ID is the content of a file, missing while "rebooting".
+	bootID := filepath.Join(t.TempDir(), "boot_id")
+	if err := os.WriteFile(bootID, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	comm := local.New(&local.Config{})
+
+	d := &ExpectedDisconnect{
+		BootIDCommand: "cat " + bootID,
+		RetryDelay:    10 * time.Millisecond,
+		ReadinessProbes: []ReadinessProbe{
+			{Name: "true", Command: "true"},
+		},
+	}
+	if err := d.Prepare(context.Background(), comm); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.Remove(bootID)
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(bootID, []byte("second\n"), 0644)
+	}()
+	if err := d.Wait(context.Background(), comm); err != nil {
+		t.Fatal(err)
+	}
+	if d.bootID != "second" {
+		t.Fatalf("bad boot ID %q", d.bootID)
+	}
+}
+
+func TestExpectedDisconnect_noReboot(t *testing.T) {
+	bootID := filepath.Join(t.TempDir(), "boot_id")
+	if err := os.WriteFile(bootID, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	comm := local.New(&local.Config{})
+
+	d := &ExpectedDisconnect{
+		BootIDCommand:     "cat " + bootID,
+		DisconnectTimeout: 100 * time.Millisecond,
+		RetryDelay:        10 * time.Millisecond,
+	}
+	err := d.Run(context.Background(), comm, "true")
+	if err == nil || !strings.Contains(err.Error(), "the machine didn't reboot within 100ms") {
+		t.Fatalf("expected the machine not to reboot, got %v", err)
+	}
+}
+
+func TestExpectedDisconnect_notPrepared(t *testing.T) {
+	d := &ExpectedDisconnect{}
+	if err := d.Wait(context.Background(), local.New(&local.Config{})); err == nil {
+		t.Fatal("Wait should fail without Prepare")
+	}
+}
+
+func TestExpectedDisconnect_stderr(t *testing.T) {
+	// Warnings printed on the error output aren't part of the boot ID.
+	d := &ExpectedDisconnect{BootIDCommand: "echo warning >&2; echo first"}
+	if err := d.Prepare(context.Background(), local.New(&local.Config{})); err != nil {
+		t.Fatal(err)
+	}
+	if d.bootID != "first" {
+		t.Fatalf("bad boot ID %q", d.bootID)
+	}
+}
@@ -8,6 +8,9 @@ Normally, a builder will want to implement StepConnect, which is smart enough
 to then determine which kind of communicator, and therefore which kind of
 substep, it should implement.
 
+Provisioners rebooting the machine use ExpectedDisconnect to wait for the
+communicator to reconnect after the reboot.
+
 Various helper functions are also supplied.
 */
 package communicator
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Values of ssh_host_key_checking.
+const (
+	hostKeyCheckingNo        = "no"
+	hostKeyCheckingYes       = "yes"
+	hostKeyCheckingAcceptNew = "accept-new"
+)
+
+// HostKeyFingerprintsStateKey is the state key builders put the SHA256
+// fingerprints of the host keys of the machine at, as a []string, when they
+// know them, for example from the console output returned by a cloud API.
+// The host key is then verified against them, unless ssh_host_key_checking
+// is "no".
+const HostKeyFingerprintsStateKey = "ssh_host_key_fingerprints"
+
+func (c *Config) prepareHostKeyChecking() []error {
+	var errs []error
+	switch c.SSHHostKeyChecking {
+	case "", hostKeyCheckingNo, hostKeyCheckingYes:
+	case hostKeyCheckingAcceptNew:
+		if c.SSHKnownHostsFile == "" {
+			errs = append(errs, errors.New(
+				"ssh_known_hosts_file must be specified when ssh_host_key_checking is accept-new"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(
+			"ssh_host_key_checking ('%s') is invalid, valid values: no, yes, accept-new",
+			c.SSHHostKeyChecking))
+	}
+
+	for _, fp := range c.SSHHostKeyFingerprints {
+		if !strings.HasPrefix(fp, "SHA256:") {
+			errs = append(errs, fmt.Errorf(
+				"ssh_host_key_fingerprints: %q is not a SHA256 fingerprint, like the ones printed by ssh-keygen -l", fp))
+		}
+	}
+
+	if c.SSHKnownHostsFile != "" {
+		path, err := pathing.ExpandUser(c.SSHKnownHostsFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ssh_known_hosts_file is invalid: %s", err))
+		} else if _, err := os.Stat(path); err != nil && !(os.IsNotExist(err) && c.SSHHostKeyChecking == hostKeyCheckingAcceptNew) {
+			errs = append(errs, fmt.Errorf("ssh_known_hosts_file is invalid: %s", err))
+		}
+	}
+	return errs
+}
+
+// hostKeyCallback returns the callback verifying the host key of the machine
+// against the expected fingerprints and the known hosts file. Host keys are
+// not verified when ssh_host_key_checking is "no", or when it isn't set and
+// there is nothing to verify them against.
+func (c *Config) hostKeyCallback(state multistep.StateBag) (ssh.HostKeyCallback, error) {
+	if c.SSHHostKeyChecking == hostKeyCheckingNo {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	fingerprints := append([]string{}, c.SSHHostKeyFingerprints...)
+	if fps, ok := state.GetOk(HostKeyFingerprintsStateKey); ok {
+		fingerprints = append(fingerprints, fps.([]string)...)
+	}
+
+	var knownHostsPath string
+	var knownHosts ssh.HostKeyCallback
+	if c.SSHKnownHostsFile != "" {
+		var err error
+		knownHostsPath, err = pathing.ExpandUser(c.SSHKnownHostsFile)
+		if err != nil {
+			return nil, err
+		}
+		if c.SSHHostKeyChecking == hostKeyCheckingAcceptNew {
+			// Trusted keys will be added to the file.
+			if err := os.MkdirAll(filepath.Dir(knownHostsPath), 0700); err != nil {
+				return nil, err
+			}
+			f, err := os.OpenFile(knownHostsPath, os.O_CREATE|os.O_RDONLY, 0600)
+			if err != nil {
+				return nil, err
+			}
+			f.Close()
+		}
+		knownHosts, err = knownhosts.New(knownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading ssh_known_hosts_file: %s", err)
+		}
+	}
+
+	if len(fingerprints) == 0 && knownHosts == nil {
+		if c.SSHHostKeyChecking == hostKeyCheckingYes {
+			return nil, errors.New("ssh_host_key_checking is yes, but there is no ssh_known_hosts_file " +
+				"nor host key fingerprint to verify the host key against")
+		}
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		for _, fp := range fingerprints {
+			if fp == fingerprint {
+				log.Printf("[DEBUG] The host key %s of %s is an expected one", fingerprint, hostname)
+				return nil
+			}
+		}
+		if knownHosts == nil {
+			return fmt.Errorf("the host key %s of %s is not one of the expected ones %v: "+
+				"it may have been intercepted", fingerprint, hostname, fingerprints)
+		}
+
+		err := knownHosts(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("the host key %s of %s doesn't match the one in %s: "+
+				"it changed or it may have been intercepted", fingerprint, hostname, knownHostsPath)
+		}
+		if c.SSHHostKeyChecking != hostKeyCheckingAcceptNew {
+			return fmt.Errorf("%s is not in %s, its host key is %s", hostname, knownHostsPath, fingerprint)
+		}
+
+		log.Printf("[INFO] Adding the host key %s of %s to %s", fingerprint, hostname, knownHostsPath)
+		f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"golang.org/x/crypto/ssh"
+)
+
+func testHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return key
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	key, other := testHostKey(t), testHostKey(t)
+
+	check := func(c *Config, state multistep.StateBag, key ssh.PublicKey) error {
+		callback, err := c.hostKeyCallback(state)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return callback("192.0.2.1:22", remote, key)
+	}
+
+	// Nothing to verify the key against
+	if err := check(&Config{}, new(multistep.BasicStateBag), key); err != nil {
+		t.Fatalf("host keys should not be verified, got %s", err)
+	}
+	if _, err := (&Config{SSH: SSH{SSHHostKeyChecking: "yes"}}).hostKeyCallback(new(multistep.BasicStateBag)); err == nil {
+		t.Fatal("should error without anything to verify the host key against")
+	}
+
+	// Fingerprints from the builder
+	state := new(multistep.BasicStateBag)
+	state.Put(HostKeyFingerprintsStateKey, []string{ssh.FingerprintSHA256(key)})
+	if err := check(&Config{}, state, key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := check(&Config{}, state, other); err == nil {
+		t.Fatal("should error with an unexpected host key")
+	}
+	if err := check(&Config{SSH: SSH{SSHHostKeyChecking: "no"}}, state, other); err != nil {
+		t.Fatalf("host keys should not be verified, got %s", err)
+	}
+
+	// Trust on first use
+	knownHosts := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	c := &Config{SSH: SSH{SSHHostKeyChecking: "accept-new", SSHKnownHostsFile: knownHosts}}
+	if err := check(c, new(multistep.BasicStateBag), key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, err := os.ReadFile(knownHosts); err != nil || len(b) == 0 {
+		t.Fatalf("the host key should have been added to the known hosts: %q, %v", b, err)
+	}
+	if err := check(c, new(multistep.BasicStateBag), key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := check(c, new(multistep.BasicStateBag), other); err == nil {
+		t.Fatal("should error when the host key changed")
+	}
+
+	// Strict checking against the known hosts
+	c = &Config{SSH: SSH{SSHHostKeyChecking: "yes", SSHKnownHostsFile: knownHosts}}
+	if err := check(c, new(multistep.BasicStateBag), key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	callback, err := c.hostKeyCallback(new(multistep.BasicStateBag))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := callback("192.0.2.2:22", &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 22}, key); err == nil {
+		t.Fatal("should error with an unknown host")
+	}
+}
+
+func TestConfig_hostKeyChecking(t *testing.T) {
+	c := testConfig()
+	c.SSHHostKeyChecking = "accept-new"
+	c.SSHHostKeyFingerprints = []string{"aa:bb:cc"}
+	if errs := c.Prepare(testContext(t)); len(errs) != 2 {
+		t.Fatalf("expected the known hosts file to be missing and the fingerprint to be invalid, got %v", errs)
+	}
+
+	c = testConfig()
+	c.SSHHostKeyChecking = "maybe"
+	if errs := c.Prepare(testContext(t)); len(errs) != 1 {
+		t.Fatalf("expected an invalid ssh_host_key_checking, got %v", errs)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// ReadinessProbe is a check run through the communicator once it is
+// connected. StepConnect only declares the machine connected once all its
+// probes pass, so that provisioning doesn't start on a half-booted machine.
+type ReadinessProbe struct {
+	// Name describes the probe in the messages.
+	Name string
+	// Command is the command run on the machine. The probe passes when it
+	// exits with 0, it is retried otherwise.
+	Command string
+	// Timeout is the time to wait for the probe to pass. Defaults to 10
+	// minutes.
+	Timeout time.Duration
+	// AttemptTimeout limits the time a run of Command can take. Defaults to
+	// a minute.
+	AttemptTimeout time.Duration
+}
+
+// CloudInitProbe passes once cloud-init finished booting the machine.
+func CloudInitProbe() ReadinessProbe {
+	return ReadinessProbe{
+		Name:    "cloud-init",
+		Command: "test -f /var/lib/cloud/instance/boot-finished",
+	}
+}
+
+// SystemdProbe passes once systemd finished starting the units of the
+// system, even when some failed.
+func SystemdProbe() ReadinessProbe {
+	return ReadinessProbe{
+		Name:    "systemd",
+		Command: `state=$(systemctl is-system-running); [ "$state" = running ] || [ "$state" = degraded ]`,
+	}
+}
+
+// WindowsServiceProbe passes once the Windows service of the given name is
+// running.
+func WindowsServiceProbe(service string) ReadinessProbe {
+	name := "'" + strings.ReplaceAll(service, "'", "''") + "'"
+	return ReadinessProbe{
+		Name: fmt.Sprintf("service %s", service),
+		Command: fmt.Sprintf(`powershell.exe -NoProfile -NonInteractive -Command "if ((Get-Service -Name %s).Status -ne 'Running') { exit 1 }"`,
+			name),
+	}
+}
+
+// wait runs the probe until it passes, waiting retryDelay between attempts.
+func (p ReadinessProbe) wait(ctx context.Context, comm packersdk.Communicator, retryDelay func(int) time.Duration) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("readiness probe %s didn't pass within %s: %s", p.Name, timeout, lastErr)
+			case <-time.After(retryDelay(retry)):
+			}
+		}
+
+		err := p.run(ctx, comm)
+		if err == nil {
+			return nil
+		}
+		// Keep the error of the last complete attempt when the time is up.
+		if ctx.Err() == nil || lastErr == nil {
+			lastErr = err
+		}
+		log.Printf("[DEBUG] Readiness probe %s failed: %s", p.Name, err)
+	}
+}
+
+// run runs the command of the probe once.
+func (p ReadinessProbe) run(ctx context.Context, comm packersdk.Communicator) error {
+	_, err := runCommand(ctx, comm, p.Command, p.AttemptTimeout)
+	return err
+}
+
+// runCommand runs command once, for at most attemptTimeout, a minute by
+// default, and returns its standard output. Its error output is only logged,
+// or part of the error if command doesn't exit with 0.
+func runCommand(ctx context.Context, comm packersdk.Communicator, command string, attemptTimeout time.Duration) (string, error) {
+	if attemptTimeout == 0 {
+		attemptTimeout = time.Minute
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(attemptCtx, cmd); err != nil {
+		return "", err
+	}
+
+	code, err := cmd.WaitContext(attemptCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("no result after %s", attemptTimeout)
+	}
+	if code != 0 {
+		output := stderr.String()
+		if strings.TrimSpace(output) == "" {
+			output = stdout.String()
+		}
+		return "", fmt.Errorf("exited with %d: %s", code, strings.TrimSpace(output))
+	}
+	if stderr.Len() > 0 {
+		log.Printf("[DEBUG] Error output of %q: %s", command, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
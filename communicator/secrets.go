@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// credential is a credential of a communicator that can be fetched from a
+// packersdk.SecretsSource.
+type credential struct {
+	name      string
+	value     *string
+	sensitive bool
+}
+
+// fetchSecrets fills the credentials of c that are not set with the secrets
+// of source. The passwords and keys are redacted from the output of Packer.
+// The hosts of ssh_bastion_hosts share the bastion credentials of source. It
+// fails if the username is still missing, as Prepare doesn't require it with
+// a SecretsSource.
+func (c *Config) fetchSecrets(ctx context.Context, source packersdk.SecretsSource) error {
+	var credentials []credential
+	switch c.Type {
+	case "ssh":
+		credentials = append(credentials,
+			credential{packersdk.SecretSSHUsername, &c.SSHUsername, false},
+			credential{packersdk.SecretSSHPassword, &c.SSHPassword, true},
+		)
+		if c.SSHBastionHost != "" {
+			credentials = append(credentials,
+				credential{packersdk.SecretSSHBastionUsername, &c.SSHBastionUsername, false},
+				credential{packersdk.SecretSSHBastionPassword, &c.SSHBastionPassword, true},
+			)
+		}
+		for i := range c.SSHBastionHosts {
+			b := &c.SSHBastionHosts[i]
+			credentials = append(credentials,
+				credential{packersdk.SecretSSHBastionUsername, &b.Username, false},
+			)
+			// The hosts authenticating otherwise don't need a password.
+			if b.PrivateKeyFile == "" && !b.AgentAuth && !b.Interactive {
+				credentials = append(credentials,
+					credential{packersdk.SecretSSHBastionPassword, &b.Password, true},
+				)
+			}
+		}
+		if len(c.SSHPrivateKey) == 0 && c.SSHPrivateKeyFile == "" {
+			var key string
+			if err := fetchSecret(ctx, source, credential{packersdk.SecretSSHPrivateKey, &key, true}); err != nil {
+				return err
+			}
+			if key != "" {
+				c.SSHPrivateKey = []byte(key)
+			}
+		}
+	case "winrm":
+		credentials = append(credentials,
+			credential{packersdk.SecretWinRMUsername, &c.WinRMUser, false},
+			credential{packersdk.SecretWinRMPassword, &c.WinRMPassword, true},
+		)
+	}
+
+	for _, cred := range credentials {
+		if *cred.value != "" {
+			continue
+		}
+		if err := fetchSecret(ctx, source, cred); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case c.Type == "ssh" && c.SSHUsername == "":
+		return errors.New("An ssh_username must be specified, or provided by the secrets source")
+	case c.Type == "winrm" && c.WinRMUser == "":
+		return errors.New("winrm_username must be specified, or provided by the secrets source")
+	}
+	return nil
+}
+
+// fetchSecret sets the value of cred to its secret, if source has it.
+func fetchSecret(ctx context.Context, source packersdk.SecretsSource, cred credential) error {
+	secret, err := source.Secret(ctx, cred.name)
+	if errors.Is(err, packersdk.ErrSecretNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", cred.name, err)
+	}
+	if cred.sensitive {
+		packersdk.LogSecretFilter.Set(secret)
+	}
+	*cred.value = secret
+	return nil
+}
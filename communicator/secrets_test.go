@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestConfig_fetchSecrets(t *testing.T) {
+	source := packersdk.MapSecretsSource{
+		packersdk.SecretSSHUsername:        "vault-user",
+		packersdk.SecretSSHPassword:        "vault-password",
+		packersdk.SecretSSHPrivateKey:      "vault-key",
+		packersdk.SecretSSHBastionPassword: "vault-bastion-password",
+		packersdk.SecretWinRMUsername:      "vault-winrm-user",
+		packersdk.SecretWinRMPassword:      "vault-winrm-password",
+	}
+
+	c := &Config{
+		Type: "ssh",
+		SSH: SSH{
+			SSHUsername:    "packer",
+			SSHBastionHost: "bastion",
+			SSHBastionHosts: []SSHBastion{
+				{Host: "hop1"},
+				{Host: "hop2", Username: "hop", AgentAuth: true},
+			},
+		},
+	}
+	if err := c.fetchSecrets(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+	if c.SSHUsername != "packer" {
+		t.Fatalf("the username of the configuration should be kept, got %q", c.SSHUsername)
+	}
+	if c.SSHPassword != "vault-password" || string(c.SSHPrivateKey) != "vault-key" ||
+		c.SSHBastionPassword != "vault-bastion-password" || c.SSHBastionUsername != "" {
+		t.Fatalf("bad credentials: %#v", c.SSH)
+	}
+	if hop := c.SSHBastionHosts[0]; hop.Username != "" || hop.Password != "vault-bastion-password" {
+		t.Fatalf("bad credentials of the first bastion host: %#v", hop)
+	}
+	if hop := c.SSHBastionHosts[1]; hop.Username != "hop" || hop.Password != "" {
+		t.Fatalf("the second bastion host doesn't need a password: %#v", hop)
+	}
+	if s := packersdk.LogSecretFilter.FilterString("vault-password"); s != "<sensitive>" {
+		t.Fatalf("the password should be redacted, got %q", s)
+	}
+
+	c = &Config{Type: "winrm"}
+	if err := c.fetchSecrets(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+	if c.WinRMPassword != "vault-winrm-password" || c.WinRMUser != "vault-winrm-user" {
+		t.Fatalf("bad credentials: %#v", c.WinRM)
+	}
+
+	c = &Config{Type: "ssh"}
+	err := c.fetchSecrets(context.Background(), packersdk.MapSecretsSource{})
+	if err == nil || !strings.Contains(err.Error(), "ssh_username must be specified") {
+		t.Fatalf("a missing username should fail, got %v", err)
+	}
+}
+
+func TestConfig_Prepare_secretsSource(t *testing.T) {
+	source := packersdk.MapSecretsSource{}
+	for _, c := range []*Config{
+		{Type: "ssh", SecretsSource: source, SSH: SSH{SSHBastionHost: "bastion"}},
+		{Type: "ssh", SecretsSource: source, SSH: SSH{SSHBastionHosts: []SSHBastion{{Host: "hop"}}}},
+		{Type: "winrm", SecretsSource: source},
+	} {
+		if errs := c.Prepare(testContext(t)); len(errs) > 0 {
+			t.Fatalf("the credentials of the secrets source shouldn't be required by Prepare: %v", errs)
+		}
+	}
+
+	c := &Config{Type: "winrm"}
+	if errs := c.Prepare(testContext(t)); len(errs) != 1 {
+		t.Fatalf("winrm_username should be required without secrets source, got %v", errs)
+	}
+}
+
+func TestStepConnect_secretsSourceError(t *testing.T) {
+	state := testState(t)
+	state.Put("secrets_source", packersdk.SecretsSourceFunc(func(context.Context, string) (string, error) {
+		return "", errors.New("permission denied")
+	}))
+	step := &StepConnect{
+		Config: &Config{Type: "winrm"},
+		Host:   func(multistep.StateBag) (string, error) { return "localhost", nil },
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, _ := state.Get("error").(error)
+	if err == nil || err.Error() != "Failed to fetch the communicator credentials: winrm_username: permission denied" {
+		t.Fatalf("bad error: %v", err)
+	}
+}
@@ -6,9 +6,12 @@
 package ssh
 
 import (
+	"bytes"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"time"
 
@@ -28,42 +31,109 @@ func parseKeyFile(path string) ([]byte, error) {
 	}
 
 	// We parse the private key on our own first so that we can
-	// show a nicer error if the private key has a password.
+	// show a nicer error if the file holds no key.
 	block, _ := pem.Decode(keyBytes)
 	if block == nil {
 		return nil, fmt.Errorf(
 			"Failed to read key '%s': no key found", path)
 	}
-	if block.Headers["Proc-Type"] == "4,ENCRYPTED" {
+	return keyBytes, nil
+}
+
+// PassphraseFunc returns the passphrase decrypting the private key called
+// name, usually the path of the key file.
+type PassphraseFunc func(name string) ([]byte, error)
+
+// ParsePrivateKey returns an ssh.Signer for the private key keyBytes, called
+// name in errors. Keys protected by a passphrase are decrypted with the one
+// returned by passphrase; when passphrase is nil, the returned error wraps an
+// *ssh.PassphraseMissingError.
+//
+// The private part of security keys, like sk-ssh-ed25519@openssh.com ones,
+// never leaves the hardware token: they can only be used through an SSH
+// agent holding them.
+func ParsePrivateKey(name string, keyBytes []byte, passphrase PassphraseFunc) (ssh.Signer, error) {
+	if keyType := securityKeyType(keyBytes); keyType != "" {
 		return nil, fmt.Errorf(
-			"Failed to read key '%s': password protected keys are\n"+
-				"not supported. Please decrypt the key prior to use.", path)
+			"%s is a %s security key, which can only be used through an SSH agent: "+
+				"add it to the agent with ssh-add and use agent authentication instead", name, keyType)
 	}
-	return keyBytes, nil
+
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	if passphrase == nil {
+		return nil, fmt.Errorf("%s is protected by a passphrase: %w", name, err)
+	}
+
+	pass, err := passphrase(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the passphrase of %s: %w", name, err)
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, pass)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", name, err)
+	}
+	return signer, nil
+}
+
+// securityKeyType returns the type of the OpenSSH private key keyBytes when
+// it is a security key.
+func securityKeyType(keyBytes []byte) string {
+	const magic = "openssh-key-v1\x00"
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" || !bytes.HasPrefix(block.Bytes, []byte(magic)) {
+		return ""
+	}
+	var key struct {
+		CipherName string
+		KdfName    string
+		KdfOpts    string
+		NumKeys    uint32
+		PubKey     []byte
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(magic):], &key); err != nil {
+		return ""
+	}
+	pub, err := ssh.ParsePublicKey(key.PubKey)
+	if err != nil {
+		return ""
+	}
+	switch pub.Type() {
+	case ssh.KeyAlgoSKECDSA256, ssh.KeyAlgoSKED25519:
+		return pub.Type()
+	}
+	return ""
 }
 
 // FileSigner returns an ssh.Signer for a key file.
 func FileSigner(path string) (ssh.Signer, error) {
+	return FileSignerWithPassphrase(path, nil)
+}
 
+// FileSignerWithPassphrase returns an ssh.Signer for a key file, decrypted
+// with the passphrase returned by passphrase when it is protected by one.
+func FileSignerWithPassphrase(path string, passphrase PassphraseFunc) (ssh.Signer, error) {
 	keyBytes, err := parseKeyFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("Error setting up SSH config: %s", err)
 	}
 
-	signer, err := ssh.ParsePrivateKey(keyBytes)
+	signer, err := ParsePrivateKey(path, keyBytes, passphrase)
 	if err != nil {
-		return nil, fmt.Errorf("Error setting up SSH config: %s", err)
+		return nil, fmt.Errorf("Error setting up SSH config: %w", err)
 	}
 
 	return signer, nil
 }
 
-func ReadCertificate(certificatePath string, keySigner ssh.Signer) (ssh.Signer, error) {
-
-	if certificatePath == "" {
-		return keySigner, fmt.Errorf("no certificate file provided")
-	}
-
+// ParseCertificateFile reads the user certificate at certificatePath and
+// checks that it is currently valid.
+func ParseCertificateFile(certificatePath string) (*ssh.Certificate, error) {
 	// Load the certificate
 	cert, err := os.ReadFile(certificatePath)
 	if err != nil {
@@ -86,6 +156,19 @@ func ReadCertificate(certificatePath string, keySigner ssh.Signer) (ssh.Signer,
 	if err != nil {
 		return nil, fmt.Errorf("%s not a valid cert: %v", certificatePath, err)
 	}
+	return certificate, nil
+}
+
+func ReadCertificate(certificatePath string, keySigner ssh.Signer) (ssh.Signer, error) {
+
+	if certificatePath == "" {
+		return keySigner, fmt.Errorf("no certificate file provided")
+	}
+
+	certificate, err := ParseCertificateFile(certificatePath)
+	if err != nil {
+		return nil, err
+	}
 
 	certSigner, err := ssh.NewCertSigner(certificate, keySigner)
 	if err != nil {
@@ -95,6 +178,45 @@ func ReadCertificate(certificatePath string, keySigner ssh.Signer) (ssh.Signer,
 	return certSigner, nil
 }
 
+// SignerWithCert returns a signer for the user certificate at
+// certificatePath, signing with the one of signers that holds its key. It is
+// used to authenticate with a certificate whose key is held by an SSH agent.
+func SignerWithCert(signers []ssh.Signer, certificatePath string) (ssh.Signer, error) {
+	certificate, err := ParseCertificateFile(certificatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	key := certificate.Key.Marshal()
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), key) {
+			return ssh.NewCertSigner(certificate, signer)
+		}
+	}
+	return nil, fmt.Errorf("no key of the SSH agent matches the certificate %s", certificatePath)
+}
+
+// ValidSigners returns signers with the certificates first, so that
+// certificates issued for the build are offered before plain keys, and
+// without the certificates that are expired or not valid yet, which servers
+// would reject.
+func ValidSigners(signers []ssh.Signer) []ssh.Signer {
+	var certs, keys []ssh.Signer
+	for _, signer := range signers {
+		cert, ok := signer.PublicKey().(*ssh.Certificate)
+		if !ok {
+			keys = append(keys, signer)
+			continue
+		}
+		if err := checkValidCert(cert); err != nil {
+			log.Printf("[DEBUG] Skipping SSH certificate %q: %s", cert.KeyId, err)
+			continue
+		}
+		certs = append(certs, signer)
+	}
+	return append(certs, keys...)
+}
+
 // FileSigner returns an ssh.Signer for a key file.
 func FileSignerWithCert(path string, certificatePath string) (ssh.Signer, error) {
 
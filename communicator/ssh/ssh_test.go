@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func testSigner(t *testing.T) gossh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return signer
+}
+
+// testCertSigner returns a signer for a certificate of key, signed by ca and
+// valid between after and before.
+func testCertSigner(t *testing.T, ca, key gossh.Signer, id string, after, before time.Time) gossh.Signer {
+	cert := &gossh.Certificate{
+		Key:             key.PublicKey(),
+		KeyId:           id,
+		CertType:        gossh.UserCert,
+		ValidPrincipals: []string{"packer"},
+		ValidAfter:      uint64(after.Unix()),
+		ValidBefore:     uint64(before.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := gossh.NewCertSigner(cert, key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return signer
+}
+
+func TestValidSigners(t *testing.T) {
+	ca := testSigner(t)
+	now := time.Now()
+	key := testSigner(t)
+	valid := testCertSigner(t, ca, testSigner(t), "valid", now.Add(-time.Hour), now.Add(time.Hour))
+	expired := testCertSigner(t, ca, testSigner(t), "expired", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	future := testCertSigner(t, ca, testSigner(t), "future", now.Add(time.Hour), now.Add(2*time.Hour))
+
+	signers := ValidSigners([]gossh.Signer{key, expired, valid, future})
+	if len(signers) != 2 || signers[0] != valid || signers[1] != key {
+		t.Fatalf("expected the valid certificate then the key, got %v", signers)
+	}
+}
+
+func TestSignerWithCert(t *testing.T) {
+	ca := testSigner(t)
+	now := time.Now()
+	key, other := testSigner(t), testSigner(t)
+	dir := t.TempDir()
+
+	writeCert := func(name string, signer gossh.Signer) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, gossh.MarshalAuthorizedKey(signer.PublicKey()), 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return path
+	}
+	valid := writeCert("valid-cert.pub", testCertSigner(t, ca, key, "valid", now.Add(-time.Hour), now.Add(time.Hour)))
+	expired := writeCert("expired-cert.pub", testCertSigner(t, ca, key, "expired", now.Add(-2*time.Hour), now.Add(-time.Hour)))
+
+	signer, err := SignerWithCert([]gossh.Signer{other, key}, valid)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cert, ok := signer.PublicKey().(*gossh.Certificate)
+	if !ok || cert.KeyId != "valid" {
+		t.Fatalf("expected a signer for the certificate, got %#v", signer.PublicKey())
+	}
+
+	if _, err := SignerWithCert([]gossh.Signer{other}, valid); err == nil {
+		t.Fatal("should error when no key matches the certificate")
+	}
+	if _, err := SignerWithCert([]gossh.Signer{key}, expired); err == nil {
+		t.Fatal("should error on an expired certificate")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	encrypted := pem.EncodeToMemory(block)
+	passphrase := func(p string) PassphraseFunc {
+		return func(name string) ([]byte, error) {
+			if name != "key" {
+				t.Fatalf("unexpected key name %q", name)
+			}
+			return []byte(p), nil
+		}
+	}
+
+	if _, err := ParsePrivateKey("key", encrypted, nil); !errors.As(err, new(*gossh.PassphraseMissingError)) {
+		t.Fatalf("expected a missing passphrase error, got %v", err)
+	}
+	if _, err := ParsePrivateKey("key", encrypted, passphrase("wrong")); err == nil {
+		t.Fatal("should error with a wrong passphrase")
+	}
+	signer, err := ParsePrivateKey("key", encrypted, passphrase("secret"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if signer.PublicKey().Type() != gossh.KeyAlgoED25519 {
+		t.Fatalf("unexpected key type %s", signer.PublicKey().Type())
+	}
+}
+
+func TestParsePrivateKey_securityKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	skPub := gossh.Marshal(struct {
+		Name        string
+		KeyBytes    []byte
+		Application string
+	}{gossh.KeyAlgoSKED25519, pub, "ssh:"})
+	key := gossh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, skPub, nil})
+	keyBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), key...),
+	})
+
+	_, err = ParsePrivateKey("id_ed25519_sk", keyBytes, nil)
+	if err == nil || !strings.Contains(err.Error(), "SSH agent") {
+		t.Fatalf("expected an error telling to use the SSH agent, got %v", err)
+	}
+}
@@ -46,3 +46,27 @@ func ParseTunnelArgument(forward string, direction ssh.TunnelDirection) (ssh.Tun
 	// So we parsed all that, and are just going to ignore it now. We would
 	// have used the information to set the type here.
 }
+
+// ParseSOCKSArgument parses the `[bind_address:]port` of an SSH tunnel
+// serving a SOCKS5 proxy, like the openssh client dynamic forwards. The
+// listener binds to localhost without bind_address.
+func ParseSOCKSArgument(forward string, direction ssh.TunnelDirection) (ssh.TunnelSpec, error) {
+	bindAddr, listeningPort := "localhost", forward
+	if strings.Contains(forward, ":") {
+		var err error
+		bindAddr, listeningPort, err = net.SplitHostPort(forward)
+		if err != nil {
+			return ssh.TunnelSpec{}, fmt.Errorf("Error parsing SOCKS proxy '%s': %s", forward, err)
+		}
+	}
+	if _, err := strconv.Atoi(listeningPort); err != nil {
+		return ssh.TunnelSpec{}, fmt.Errorf("Error parsing listening port, must be a valid port: %s", err)
+	}
+
+	return ssh.TunnelSpec{
+		Direction:   direction,
+		ForwardType: ssh.SOCKSForward,
+		ListenAddr:  net.JoinHostPort(bindAddr, listeningPort),
+		ListenType:  "tcp",
+	}, nil
+}
@@ -80,3 +80,33 @@ func TestInvalidTunnels(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSOCKSArgument(t *testing.T) {
+	cases := map[string]string{
+		"1080":           "localhost:1080",
+		"0.0.0.0:1080":   "0.0.0.0:1080",
+		"[::1]:1080":     "[::1]:1080",
+		"localhost:1080": "localhost:1080",
+	}
+	for forward, listenAddr := range cases {
+		tun, err := ParseSOCKSArgument(forward, ssh.RemoteTunnel)
+		if err != nil {
+			t.Fatalf("%s: %s", forward, err)
+		}
+		expectedTun := ssh.TunnelSpec{
+			Direction:   ssh.RemoteTunnel,
+			ForwardType: ssh.SOCKSForward,
+			ListenAddr:  listenAddr,
+			ListenType:  "tcp",
+		}
+		if tun != expectedTun {
+			t.Errorf("Parsed tunnel (%v), want %v", tun, expectedTun)
+		}
+	}
+
+	for _, forward := range []string{"nope", "localhost:nope", "1080:localhost:80", ""} {
+		if tun, err := ParseSOCKSArgument(forward, ssh.UnsetTunnel); err == nil {
+			t.Errorf("Parsed tunnel %v from %q, want error", tun, forward)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package sshkey
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrUnsupportedExportAlgorithm is returned when exporting a key of an
+// algorithm the format doesn't support, like DSA keys.
+var ErrUnsupportedExportAlgorithm = fmt.Errorf("sshkey: the private key algorithm can't be exported to this format")
+
+// privateKey parses the private key of the pair.
+func (p *Pair) privateKey() (crypto.PrivateKey, error) {
+	key, err := ssh.ParseRawPrivateKey(p.Private)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		return *k, nil
+	}
+	return key, nil
+}
+
+// OpenSSH returns the private key of the pair in the OpenSSH format, with the
+// given comment. DSA keys are not supported.
+func (p *Pair) OpenSSH(comment string) ([]byte, error) {
+	key, err := p.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, ErrUnsupportedExportAlgorithm
+	}
+	blk, err := ssh.MarshalPrivateKey(key, comment)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(blk), nil
+}
+
+// PPK returns the pair as an unencrypted PuTTY private key file, in the
+// version 3 of the format read by PuTTY 0.75 and later, with the given
+// comment. DSA keys are not supported.
+func (p *Pair) PPK(comment string) ([]byte, error) {
+	key, err := p.privateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// see https://the.earth.li/~sgtatham/putty/0.76/htmldoc/AppendixC.html
+	var private []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		k.Precompute()
+		private = ssh.Marshal(struct {
+			D, P, Q, Iqmp *big.Int
+		}{k.D, k.Primes[0], k.Primes[1], k.Precomputed.Qinv})
+	case *ecdsa.PrivateKey:
+		private = ssh.Marshal(struct {
+			D *big.Int
+		}{k.D})
+	case ed25519.PrivateKey:
+		private = ssh.Marshal(struct {
+			Seed []byte
+		}{k.Seed()})
+	default:
+		return nil, ErrUnsupportedExportAlgorithm
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	algorithm := signer.PublicKey().Type()
+	public := signer.PublicKey().Marshal()
+
+	// An unencrypted file is authenticated with an empty key.
+	mac := hmac.New(sha256.New, nil)
+	mac.Write(ssh.Marshal(struct {
+		Algorithm, Encryption, Comment string
+		Public, Private                []byte
+	}{algorithm, "none", comment, public, private}))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PuTTY-User-Key-File-3: %s\n", algorithm)
+	fmt.Fprintf(&b, "Encryption: none\n")
+	fmt.Fprintf(&b, "Comment: %s\n", comment)
+	writePPKLines(&b, "Public-Lines", public)
+	writePPKLines(&b, "Private-Lines", private)
+	fmt.Fprintf(&b, "Private-MAC: %s\n", hex.EncodeToString(mac.Sum(nil)))
+	return b.Bytes(), nil
+}
+
+// writePPKLines writes data encoded in base64, in lines of 64 characters,
+// preceded by their count.
+func writePPKLines(b *bytes.Buffer, name string, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	fmt.Fprintf(b, "%s: %d\n", name, (len(encoded)+63)/64)
+	for len(encoded) > 64 {
+		fmt.Fprintln(b, encoded[:64])
+		encoded = encoded[64:]
+	}
+	fmt.Fprintln(b, encoded)
+}
+
+// WritePrivateKeyFile writes the private key to path, readable only by the
+// current user as SSH clients require, like when saving the temporary key in
+// debug mode. An existing file is replaced.
+func WritePrivateKeyFile(path string, private []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// The permissions of an existing file are not changed by OpenFile.
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(private); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
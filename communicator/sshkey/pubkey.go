@@ -4,13 +4,21 @@
 package sshkey
 
 import (
+	"errors"
 	"fmt"
 
 	"golang.org/x/crypto/ssh"
 )
 
+// PublicKeyFromPrivate returns the public key of privateKeyBytes, in the
+// authorized_keys format. The public key of OpenSSH keys protected by a
+// passphrase is returned without decrypting them.
 func PublicKeyFromPrivate(privateKeyBytes []byte) ([]byte, error) {
 	key, err := ssh.ParsePrivateKey(privateKeyBytes)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return ssh.MarshalAuthorizedKey(missing.PublicKey), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Error on parsing SSH private key: %s", err)
 	}
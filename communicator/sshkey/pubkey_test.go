@@ -4,9 +4,13 @@
 package sshkey
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -94,3 +98,26 @@ func TestPublicKeyFromPrivate(t *testing.T) {
 		})
 	}
 }
+
+func TestPublicKeyFromPrivate_passphrase(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PublicKeyFromPrivate(pem.EncodeToMemory(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, ssh.MarshalAuthorizedKey(sshPub)); diff != "" {
+		t.Errorf("wrong PublicKeyFromPrivate(): %s", diff)
+	}
+}
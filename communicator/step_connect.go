@@ -9,6 +9,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/none"
@@ -45,6 +46,17 @@ type StepConnect struct {
 	// existing types.
 	CustomConnect map[string]multistep.Step
 
+	// ReadinessProbes are run through the communicator once connected, in
+	// order. The step halts if one of them doesn't pass in time.
+	ReadinessProbes []ReadinessProbe
+
+	// SecretsSource, if set, fills the credentials the configuration leaves
+	// empty, like the SSH private key or the WinRM password, when
+	// connecting. It defaults to the SecretsSource of Config, or else to the
+	// packersdk.SecretsSource of the "secrets_source" key of the state bag,
+	// if any.
+	SecretsSource packersdk.SecretsSource
+
 	substep multistep.Step
 }
 
@@ -83,7 +95,11 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 
 	step, ok := typeMap[s.Config.Type]
 	if !ok {
-		state.Put("error", fmt.Errorf("unknown communicator type: %s", s.Config.Type))
+		types := didyoumean.NewSet("communicator type")
+		for k := range typeMap {
+			types.Add(k)
+		}
+		state.Put("error", types.Unknown(s.Config.Type))
 		return multistep.ActionHalt
 	}
 
@@ -101,6 +117,22 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 		return multistep.ActionContinue
 	}
 
+	source := s.SecretsSource
+	if source == nil {
+		source = s.Config.SecretsSource
+	}
+	if source == nil {
+		source, _ = state.Get("secrets_source").(packersdk.SecretsSource)
+	}
+	if source != nil {
+		if err := s.Config.fetchSecrets(ctx, source); err != nil {
+			err := fmt.Errorf("Failed to fetch the communicator credentials: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	if host, err := s.Host(state); err == nil {
 		switch s.Config.Type {
 		case "ssh":
@@ -135,6 +167,28 @@ func (s *StepConnect) Run(ctx context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	if comm, ok := state.GetOk("communicator"); ok && len(s.ReadinessProbes) > 0 {
+		policy := s.Config.EffectiveConnectPolicy()
+		for _, probe := range s.ReadinessProbes {
+			ui.Say(fmt.Sprintf("Waiting for %s to be ready...", probe.Name))
+			if err := probe.wait(ctx, comm.(packersdk.Communicator), policy.Backoff); err != nil {
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	if comm, ok := state.GetOk("communicator"); ok {
+		comm, err := s.Config.wrapTransfers(comm.(packersdk.Communicator), ui)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		state.Put("communicator", comm)
+	}
+
 	// Put communicator config into state so we can pass it to provisioners
 	// for specialized interpolation later
 	state.Put("communicator_config", s.Config)
@@ -52,10 +52,11 @@ func (s *StepConnectSSH) Run(ctx context.Context, state multistep.StateBag) mult
 		waitDone <- true
 	}()
 
-	log.Printf("[INFO] Waiting for SSH, up to timeout: %s", s.Config.SSHTimeout)
+	policy := s.Config.EffectiveConnectPolicy()
+	log.Printf("[INFO] Waiting for SSH, up to timeout: %s", policy.MaxWait)
 	timeout := make(<-chan time.Time)
-	if s.Config.SSHTimeout > 0 {
-		timeout = time.After(s.Config.SSHTimeout)
+	if policy.MaxWait > 0 {
+		timeout = time.After(policy.MaxWait)
 	}
 	for {
 		// Wait for either SSH to become available, a timeout to occur,
@@ -92,23 +93,27 @@ func (s *StepConnectSSH) Cleanup(multistep.StateBag) {
 }
 
 func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Context) (packersdk.Communicator, error) {
-	// Determine if we're using a bastion host, and if so, retrieve
-	// that configuration. This configuration doesn't change so we
+	// Determine if we're using bastion hosts, and if so, retrieve
+	// their configuration. This configuration doesn't change so we
 	// do this one before entering the retry loop.
-	var bProto, bAddr string
-	var bConf *gossh.ClientConfig
+	var bProto string
+	var hops []ssh.BastionHop
 	var pAddr string
 	var pAuth *proxy.Auth
-	if s.Config.SSHBastionHost != "" {
+	ui, _ := state.Get("ui").(packersdk.Ui)
+	for _, b := range s.Config.bastionHosts() {
 		// The protocol is hardcoded for now, but may be configurable one day
 		bProto = "tcp"
-		bAddr = net.JoinHostPort(s.Config.SSHBastionHost, fmt.Sprint(s.Config.SSHBastionPort))
 
-		conf, err := sshBastionConfig(s.Config)
+		conf, err := sshBastionConfig(b, s.Config.keyPassphrase(ui))
 		if err != nil {
-			return nil, fmt.Errorf("Error configuring bastion: %s", err)
+			return nil, fmt.Errorf("Error configuring bastion %s: %s", b.Host, err)
 		}
-		bConf = conf
+		hops = append(hops, ssh.BastionHop{
+			Address:           net.JoinHostPort(b.Host, fmt.Sprint(b.Port)),
+			Config:            conf,
+			KeepAliveInterval: b.KeepAliveInterval,
+		})
 	}
 
 	if s.Config.SSHProxyHost != "" {
@@ -121,21 +126,20 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 
 	}
 
+	policy := s.Config.EffectiveConnectPolicy()
 	handshakeAttempts := 0
 
 	var comm packersdk.Communicator
-	first := true
-	for {
+	for retry := 0; ; retry++ {
 		// Don't check for cancel or wait on first iteration
-		if !first {
+		if retry > 0 {
 			select {
 			case <-ctx.Done():
 				log.Println("[DEBUG] SSH wait cancelled. Exiting loop.")
 				return nil, errors.New("SSH wait cancelled")
-			case <-time.After(5 * time.Second):
+			case <-time.After(policy.Backoff(retry)):
 			}
 		}
-		first = false
 
 		// First we request the TCP connection information
 		host, err := s.Host(state)
@@ -166,12 +170,11 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 		// Attempt to connect to SSH port
 		var connFunc func() (net.Conn, error)
 		address := net.JoinHostPort(host, fmt.Sprint(port))
-		if bAddr != "" {
+		if len(hops) > 0 {
 			log.Printf("[INFO] connecting with SSH to host %s through bastion at %s",
-				address, bAddr)
-			// We're using a bastion host, so use the bastion connfunc
-			connFunc = ssh.BastionConnectFunc(
-				bProto, bAddr, bConf, "tcp", address)
+				address, hops[0].Address)
+			// We're using bastion hosts, so use the bastion connfunc
+			connFunc = ssh.BastionChainConnectFunc(bProto, hops, "tcp", address)
 		} else if pAddr != "" {
 			// Connect via SOCKS5 proxy
 			connFunc = ssh.ProxyConnectFunc(pAddr, pAuth, "tcp", address)
@@ -205,16 +208,32 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 			}
 			tunnels = append(tunnels, t)
 		}
+		if s.Config.SSHRemoteSOCKSProxy != "" {
+			t, err := helperssh.ParseSOCKSArgument(s.Config.SSHRemoteSOCKSProxy, ssh.RemoteTunnel)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Error parsing SOCKS proxy: %s", err)
+			}
+			tunnels = append(tunnels, t)
+		}
 
 		// Then we attempt to connect via SSH
 		config := &ssh.Config{
 			Connection:             connFunc,
 			SSHConfig:              sshConfig,
 			Pty:                    s.Config.SSHPty,
+			PowerShellExec:         s.Config.SSHRemoteShell == "powershell",
 			DisableAgentForwarding: s.Config.SSHDisableAgentForwarding,
 			UseSftp:                s.Config.SSHFileTransferMethod == "sftp",
+			AutoFileTransfer:       s.Config.SSHFileTransferMethod == "auto",
+			UploadDirTar:           s.Config.SSHUploadDirTar,
+			UploadDirInclude:       s.Config.SSHUploadDirInclude,
+			UploadDirExclude:       s.Config.SSHUploadDirExclude,
+			UploadDirSymlinks:      ssh.SymlinkPolicy(s.Config.SSHUploadDirSymlinks),
 			KeepAliveInterval:      s.Config.SSHKeepAliveInterval,
+			KeepAliveCountMax:      s.Config.SSHKeepAliveCountMax,
 			Timeout:                s.Config.SSHReadWriteTimeout,
+			HandshakeTimeout:       policy.BannerTimeout,
 			Tunnels:                tunnels,
 		}
 
@@ -238,8 +257,8 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 				handshakeAttempts += 1
 			}
 
-			if s.Config.SSHHandshakeAttempts > 0 &&
-				handshakeAttempts >= s.Config.SSHHandshakeAttempts {
+			if policy.MaxHandshakeAttempts > 0 &&
+				handshakeAttempts >= policy.MaxHandshakeAttempts {
 				return nil, err
 			}
 
@@ -255,10 +274,10 @@ func (s *StepConnectSSH) waitForSSH(state multistep.StateBag, ctx context.Contex
 	return comm, nil
 }
 
-func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
+func sshBastionConfig(config SSHBastion, passphrase helperssh.PassphraseFunc) (*gossh.ClientConfig, error) {
 	auth := make([]gossh.AuthMethod, 0, 2)
 
-	if config.SSHBastionInteractive {
+	if config.Interactive {
 		var c io.ReadWriteCloser
 		if term.IsTerminal(int(os.Stdin.Fd())) {
 			c = os.Stdin
@@ -273,40 +292,22 @@ func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 		auth = append(auth, gossh.KeyboardInteractive(ssh.KeyboardInteractive(c)))
 	}
 
-	if config.SSHBastionPassword != "" {
+	if config.Password != "" {
 		auth = append(auth,
-			gossh.Password(config.SSHBastionPassword),
+			gossh.Password(config.Password),
 			gossh.KeyboardInteractive(
-				ssh.PasswordKeyboardInteractive(config.SSHBastionPassword)))
+				ssh.PasswordKeyboardInteractive(config.Password)))
 	}
 
-	if config.SSHBastionPrivateKeyFile != "" {
-		path, err := pathing.ExpandUser(config.SSHBastionPrivateKeyFile)
+	if config.PrivateKeyFile != "" {
+		signer, err := config.signer(passphrase)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"Error expanding path for SSH bastion private key: %s", err)
-		}
-
-		if config.SSHBastionCertificateFile != "" {
-			identityPath, err := pathing.ExpandUser(config.SSHBastionCertificateFile)
-			if err != nil {
-				return nil, fmt.Errorf("Error expanding path for SSH bastion identity certificate: %s", err)
-			}
-			signer, err := helperssh.FileSignerWithCert(path, identityPath)
-			if err != nil {
-				return nil, err
-			}
-			auth = append(auth, gossh.PublicKeys(signer))
-		} else {
-			signer, err := helperssh.FileSigner(path)
-			if err != nil {
-				return nil, err
-			}
-			auth = append(auth, gossh.PublicKeys(signer))
+			return nil, err
 		}
+		auth = append(auth, gossh.PublicKeys(signer))
 	}
 
-	if config.SSHBastionAgentAuth {
+	if config.AgentAuth {
 		authSock := os.Getenv("SSH_AUTH_SOCK")
 		if authSock == "" {
 			return nil, fmt.Errorf("SSH_AUTH_SOCK is not set")
@@ -317,11 +318,19 @@ func sshBastionConfig(config *Config) (*gossh.ClientConfig, error) {
 			return nil, fmt.Errorf("Cannot connect to SSH Agent socket %q: %s", authSock, err)
 		}
 
-		auth = append(auth, gossh.PublicKeysCallback(agent.NewClient(sshAgent).Signers))
+		certPath := ""
+		if config.PrivateKeyFile == "" && config.CertificateFile != "" {
+			// The key of the certificate is held by the agent.
+			certPath, err = pathing.ExpandUser(config.CertificateFile)
+			if err != nil {
+				return nil, fmt.Errorf("Error expanding path for SSH bastion identity certificate: %s", err)
+			}
+		}
+		auth = append(auth, gossh.PublicKeysCallback(agentSigners(agent.NewClient(sshAgent), certPath)))
 	}
 
 	return &gossh.ClientConfig{
-		User:            config.SSHBastionUsername,
+		User:            config.Username,
 		Auth:            auth,
 		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
 	}, nil
@@ -6,10 +6,14 @@ package communicator
 import (
 	"bytes"
 	"context"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
 )
 
 func TestStepConnect_impl(t *testing.T) {
@@ -32,6 +36,69 @@ func TestStepConnect_none(t *testing.T) {
 	}
 }
 
+// connectLocal is a connect step putting a local communicator into the state.
+type connectLocal struct{}
+
+func (connectLocal) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	state.Put("communicator", local.New(&local.Config{}))
+	return multistep.ActionContinue
+}
+
+func (connectLocal) Cleanup(multistep.StateBag) {}
+
+func TestStepConnect_readinessProbes(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	cases := []struct {
+		name   string
+		probes []ReadinessProbe
+		err    string
+	}{
+		{"passing", []ReadinessProbe{
+			{Name: "true", Command: "true"},
+			// Passes on its second attempt.
+			{Name: "marker", Command: "[ -f " + marker + " ] || { touch " + marker + "; exit 1; }"},
+		}, ""},
+		{"failing", []ReadinessProbe{
+			{Name: "false", Command: "echo not ready; exit 1", Timeout: time.Second},
+		}, "readiness probe false didn't pass within 1s: exited with 1: not ready"},
+		{"hanging", []ReadinessProbe{
+			{Name: "sleep", Command: "sleep 2", Timeout: 200 * time.Millisecond, AttemptTimeout: 50 * time.Millisecond},
+		}, "readiness probe sleep didn't pass within 200ms: no result after 50ms"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := testState(t)
+			step := &StepConnect{
+				Config: &Config{
+					Type:          "local",
+					ConnectPolicy: ConnectPolicy{InitialBackoff: 10 * time.Millisecond},
+				},
+				Host:            func(multistep.StateBag) (string, error) { return "localhost", nil },
+				CustomConnect:   map[string]multistep.Step{"local": connectLocal{}},
+				ReadinessProbes: tc.probes,
+			}
+			action := step.Run(context.Background(), state)
+			if tc.err == "" {
+				if action != multistep.ActionContinue {
+					t.Fatalf("bad action %#v: %v", action, state.Get("error"))
+				}
+				return
+			}
+			err, _ := state.Get("error").(error)
+			if action != multistep.ActionHalt || err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected the step to halt with %q, got %#v, %v", tc.err, action, err)
+			}
+		})
+	}
+}
+
+func TestWindowsServiceProbe(t *testing.T) {
+	p := WindowsServiceProbe("it's")
+	if !strings.Contains(p.Command, "Get-Service -Name 'it''s'") {
+		t.Fatalf("unexpected command %s", p.Command)
+	}
+}
+
 func testState(t *testing.T) multistep.StateBag {
 	state := new(multistep.BasicStateBag)
 	state.Put("hook", &packersdk.MockHook{})
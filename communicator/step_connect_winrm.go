@@ -58,8 +58,9 @@ func (s *StepConnectWinRM) Run(ctx context.Context, state multistep.StateBag) mu
 		waitDone <- true
 	}()
 
-	log.Printf("Waiting for WinRM, up to timeout: %s", s.Config.WinRMTimeout)
-	timeout := time.After(s.Config.WinRMTimeout)
+	policy := s.Config.EffectiveConnectPolicy()
+	log.Printf("Waiting for WinRM, up to timeout: %s", policy.MaxWait)
+	timeout := time.After(policy.MaxWait)
 	for {
 		// Wait for either WinRM to become available, a timeout to occur,
 		// or an interrupt to come through.
@@ -94,19 +95,18 @@ func (s *StepConnectWinRM) Cleanup(multistep.StateBag) {
 }
 
 func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Context) (packersdk.Communicator, error) {
+	policy := s.Config.EffectiveConnectPolicy()
 	var comm packersdk.Communicator
-	first := true
-	for {
+	for retry := 0; ; retry++ {
 		// Don't check for cancel or wait on first iteration
-		if !first {
+		if retry > 0 {
 			select {
 			case <-ctx.Done():
 				log.Println("[INFO] WinRM wait cancelled. Exiting loop.")
 				return nil, errors.New("WinRM wait cancelled")
-			case <-time.After(5 * time.Second):
+			case <-time.After(policy.Backoff(retry)):
 			}
 		}
-		first = false
 
 		host, err := s.Host(state)
 		if err != nil {
@@ -149,9 +149,12 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 			if err := setNoProxy(host, port); err != nil {
 				return nil, fmt.Errorf("Error setting no_proxy: %s", err)
 			}
-			if s.Config.WinRMUseNTLM {
+			switch {
+			case s.Config.WinRMUseKerberos:
+				// The Kerberos transport reloads the proxy settings already.
+			case s.Config.WinRMUseNTLM:
 				s.Config.WinRMTransportDecorator = ProxyTransportDecoratorWithNTLM
-			} else {
+			default:
 				s.Config.WinRMTransportDecorator = ProxyTransportDecorator
 			}
 		}
@@ -166,6 +169,8 @@ func (s *StepConnectWinRM) waitForWinRM(state multistep.StateBag, ctx context.Co
 			Https:              s.Config.WinRMUseSSL,
 			Insecure:           s.Config.WinRMInsecure,
 			TransportDecorator: s.Config.WinRMTransportDecorator,
+			UploadChunkSize:    s.Config.WinRMUploadChunkSize,
+			UploadParallelism:  s.Config.WinRMUploadParallelism,
 		})
 		if err != nil {
 			log.Printf("[ERROR] WinRM connection err: %s", err)
@@ -257,3 +262,19 @@ func ProxyTransportDecorator() winrmcmd.Transporter {
 func ProxyTransportDecoratorWithNTLM() winrmcmd.Transporter {
 	return winrmcmd.NewClientNTLMWithProxyFunc(RefreshProxyFromEnvironment)
 }
+
+// kerberosTransportDecorator is the Transporter authenticating with Kerberos
+// when winrm_use_kerberos is set. It reloads HTTP Proxy settings at client
+// runtime, like ProxyTransportDecorator.
+func (c *Config) kerberosTransportDecorator() winrmcmd.Transporter {
+	return winrm.NewKerberos(&winrm.KerberosConfig{
+		Username: c.WinRMUser,
+		Password: c.WinRMPassword,
+		Realm:    c.WinRMKerberosRealm,
+		Keytab:   c.WinRMKerberosKeytab,
+		CCache:   c.WinRMKerberosCCache,
+		Config:   c.WinRMKerberosConfig,
+		SPN:      c.WinRMKerberosSPN,
+		Proxy:    RefreshProxyFromEnvironment,
+	})
+}
@@ -6,8 +6,8 @@ package communicator
 import (
 	"context"
 	"fmt"
-	"os"
 
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -24,7 +24,7 @@ func (s *StepDumpSSHKey) Run(_ context.Context, state multistep.StateBag) multis
 
 	ui.Message(fmt.Sprintf("Saving key for debug purposes: %s", s.Path))
 
-	err := os.WriteFile(s.Path, s.SSH.SSHPrivateKey, 0700)
+	err := sshkey.WritePrivateKeyFile(s.Path, s.SSH.SSHPrivateKey)
 	if err != nil {
 		state.Put("error", fmt.Errorf("Error saving debug key: %s", err))
 		return multistep.ActionHalt
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/time/rate"
+)
+
+// parseBandwidth parses a transfer_bandwidth_limit, in bytes per second.
+func parseBandwidth(s string) (int, error) {
+	multiplier := 1
+	number := strings.ToUpper(strings.TrimSpace(s))
+	for i, suffix := range []string{"KB", "MB", "GB"} {
+		if strings.HasSuffix(number, suffix) {
+			multiplier = 1 << (10 * (i + 1))
+			number = strings.TrimSuffix(number, suffix)
+			break
+		}
+	}
+	number = strings.TrimSpace(strings.TrimSuffix(number, "B"))
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive number of bytes per second, like 512KB or 5MB", s)
+	}
+	return n * multiplier, nil
+}
+
+// transferCommunicator wraps a communicator to limit the bandwidth of its
+// uploads and downloads, and to report their progress.
+type transferCommunicator struct {
+	packersdk.Communicator
+
+	// limiter limits the bandwidth, when set.
+	limiter *rate.Limiter
+	// ui reports the progress of the transfers, when set.
+	ui packersdk.Ui
+}
+
+// wrapTransfers returns comm wrapped to enforce the transfer_bandwidth_limit
+// and transfer_progress options, or comm itself when they are not set.
+func (c *Config) wrapTransfers(comm packersdk.Communicator, ui packersdk.Ui) (packersdk.Communicator, error) {
+	tc := &transferCommunicator{Communicator: comm}
+	if c.TransferBandwidthLimit != "" {
+		limit, err := parseBandwidth(c.TransferBandwidthLimit)
+		if err != nil {
+			return nil, err
+		}
+		tc.limiter = rate.NewLimiter(rate.Limit(limit), limit)
+	}
+	if c.TransferProgress {
+		tc.ui = ui
+	}
+	if tc.limiter == nil && tc.ui == nil {
+		return comm, nil
+	}
+	return tc, nil
+}
+
+// reader wraps r, read as the transfer of the file at path of the given size,
+// or -1 when unknown.
+func (c *transferCommunicator) reader(path string, size int64, r io.Reader) io.ReadCloser {
+	rc := io.NopCloser(r)
+	if c.limiter != nil {
+		rc = &limitedReader{ReadCloser: rc, limiter: c.limiter}
+	}
+	if c.ui != nil {
+		rc = c.ui.TrackProgress(path, 0, size, rc)
+	}
+	return rc
+}
+
+func (c *transferCommunicator) Upload(path string, input io.Reader, fi *os.FileInfo) error {
+	size := int64(-1)
+	if fi != nil {
+		size = (*fi).Size()
+	}
+	r := c.reader(path, size, input)
+	defer r.Close()
+	return c.Communicator.Upload(path, r, fi)
+}
+
+func (c *transferCommunicator) Download(path string, output io.Writer) error {
+	pr, pw := io.Pipe()
+	r := c.reader(path, -1, pr)
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(output, r)
+		r.Close()
+		// Unblock the download when the output fails.
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	err := c.Communicator.Download(path, pw)
+	pw.CloseWithError(err)
+	if copyErr := <-done; err == nil {
+		err = copyErr
+	}
+	return err
+}
+
+// limitedReader waits for the limiter before returning what it reads.
+type limitedReader struct {
+	io.ReadCloser
+	limiter *rate.Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(context.Background(), n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
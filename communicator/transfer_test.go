@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package communicator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected int
+	}{
+		{"1024", 1024},
+		{"512B", 512},
+		{"512KB", 512 * 1024},
+		{"5mb", 5 * 1024 * 1024},
+		{"1 GB", 1024 * 1024 * 1024},
+	} {
+		got, err := parseBandwidth(tc.in)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.in, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%q: got %d, expected %d", tc.in, got, tc.expected)
+		}
+	}
+
+	for _, in := range []string{"", "fast", "-1MB", "0", "1TB"} {
+		if _, err := parseBandwidth(in); err == nil {
+			t.Fatalf("%q should be invalid", in)
+		}
+	}
+}
+
+func TestTransferCommunicator(t *testing.T) {
+	mock := new(packersdk.MockCommunicator)
+	if comm, err := new(Config).wrapTransfers(mock, nil); err != nil || comm != mock {
+		t.Fatalf("the communicator should not be wrapped, got %#v: %v", comm, err)
+	}
+
+	ui := new(packersdk.MockUi)
+	c := &Config{TransferBandwidthLimit: "8KB", TransferProgress: true}
+	comm, err := c.wrapTransfers(mock, ui)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The first 8KB are allowed at once, the next 8KB take a second.
+	data := strings.Repeat("x", 16*1024)
+	start := time.Now()
+	if err := comm.Upload("/tmp/file", strings.NewReader(data), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("the upload should have been limited, it took %s", elapsed)
+	}
+	if mock.UploadData != data {
+		t.Fatal("unexpected uploaded data")
+	}
+	if !ui.TrackProgressCalled {
+		t.Fatal("the upload progress should have been tracked")
+	}
+
+	mock.DownloadData = "downloaded"
+	var out bytes.Buffer
+	if err := comm.Download("/tmp/file", &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.String() != "downloaded" {
+		t.Fatalf("unexpected downloaded data %q", out.String())
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package didyoumean
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/agext/levenshtein"
+)
+
+// DefaultMaxDistance is the maximum distance of the suggestions of a Set
+// when its MaxDistance isn't set, the one of NameSuggestion.
+const DefaultMaxDistance = 2
+
+// Set is a set of the valid values of a kind, like the communicator types or
+// the builder names, suggesting the closest one for an unknown value.
+type Set struct {
+	// Kind names the values in the errors, like "communicator type".
+	Kind string
+	// MaxDistance is the maximum Levenshtein distance between an unknown
+	// value and its suggestion, DefaultMaxDistance by default. A value
+	// differing from a valid one only by its case is always suggested.
+	MaxDistance int
+
+	mu     sync.RWMutex
+	values []string
+}
+
+// NewSet returns a set of the valid values of kind.
+func NewSet(kind string, values ...string) *Set {
+	s := &Set{Kind: kind}
+	s.Add(values...)
+	return s
+}
+
+// Add adds values to the valid values of s, like the communicator types of
+// a plugin.
+func (s *Set) Add(values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		i := sort.SearchStrings(s.values, v)
+		if i < len(s.values) && s.values[i] == v {
+			continue
+		}
+		s.values = append(s.values, "")
+		copy(s.values[i+1:], s.values[i:])
+		s.values[i] = v
+	}
+}
+
+// Values returns the valid values of s, sorted.
+func (s *Set) Values() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.values...)
+}
+
+// Contains returns whether value is valid.
+func (s *Set) Contains(value string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := sort.SearchStrings(s.values, value)
+	return i < len(s.values) && s.values[i] == value
+}
+
+// Suggest returns the valid value closest to given, the first in order in
+// case of a tie, or an empty string if none is close enough.
+func (s *Set) Suggest(given string) string {
+	max := s.MaxDistance
+	if max == 0 {
+		max = DefaultMaxDistance
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	suggestion, best := "", max+1
+	for _, v := range s.values {
+		if strings.EqualFold(v, given) {
+			return v
+		}
+		if dist := levenshtein.Distance(given, v, nil); dist < best {
+			suggestion, best = v, dist
+		}
+	}
+	return suggestion
+}
+
+// Check returns nil if value is valid, an *UnknownError suggesting a valid
+// value otherwise.
+func (s *Set) Check(value string) error {
+	if s.Contains(value) {
+		return nil
+	}
+	return s.Unknown(value)
+}
+
+// Unknown returns the *UnknownError of the unknown value, suggesting a
+// valid value.
+func (s *Set) Unknown(value string) error {
+	return &UnknownError{
+		Kind:       s.Kind,
+		Value:      value,
+		Suggestion: s.Suggest(value),
+	}
+}
+
+// UnknownError is the error of an unknown value of a Set.
+type UnknownError struct {
+	Kind  string
+	Value string
+	// Suggestion is the closest valid value, empty if none is close
+	// enough.
+	Suggestion string
+}
+
+func (e *UnknownError) Error() string {
+	msg := fmt.Sprintf("unknown %s %q", e.Kind, e.Value)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.Suggestion)
+	}
+	return msg
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Set{}
+)
+
+// Register registers set under its kind, replacing the set of that kind
+// registered before, so that other subsystems can suggest its values, and
+// returns it.
+func Register(set *Set) *Set {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[set.Kind] = set
+	return set
+}
+
+// Lookup returns the set registered for kind, nil if there is none.
+func Lookup(kind string) *Set {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[kind]
+}
+
+// Suggest returns the value closest to given of the set registered for kind,
+// or an empty string if there is no such set or close value.
+func Suggest(kind, given string) string {
+	if s := Lookup(kind); s != nil {
+		return s.Suggest(given)
+	}
+	return ""
+}
+
+// Unknown returns the *UnknownError of the unknown value of kind, suggesting
+// a value of the set registered for kind, if any.
+func Unknown(kind, value string) error {
+	if s := Lookup(kind); s != nil {
+		return s.Unknown(value)
+	}
+	return &UnknownError{Kind: kind, Value: value}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package didyoumean
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet("communicator type", "winrm", "ssh", "none")
+	s.Add("docker", "ssh")
+	if got := s.Values(); !reflect.DeepEqual(got, []string{"docker", "none", "ssh", "winrm"}) {
+		t.Fatalf("bad values: %v", got)
+	}
+
+	for given, want := range map[string]string{
+		"shh":      "ssh",
+		"SSH":      "ssh",
+		"winrn":    "winrm",
+		"kerberos": "",
+	} {
+		if got := s.Suggest(given); got != want {
+			t.Errorf("Suggest(%q) = %q, want %q", given, got, want)
+		}
+	}
+
+	if err := s.Check("ssh"); err != nil {
+		t.Fatalf("ssh should be valid: %s", err)
+	}
+	err := s.Check("shh")
+	if err == nil || err.Error() != `unknown communicator type "shh", did you mean "ssh"?` {
+		t.Fatalf("bad error: %v", err)
+	}
+
+	s.MaxDistance = 4
+	if got := s.Suggest("dockers"); got != "docker" {
+		t.Fatalf("the closest value should be suggested, got %q", got)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	Register(NewSet("test builder", "amazon-ebs", "qemu"))
+	if got := Suggest("test builder", "qemy"); got != "qemu" {
+		t.Fatalf("bad suggestion %q", got)
+	}
+	if got := Suggest("missing kind", "qemy"); got != "" {
+		t.Fatalf("no suggestion expected, got %q", got)
+	}
+	if err := Unknown("missing kind", "qemy"); err.Error() != `unknown missing kind "qemy"` {
+		t.Fatalf("bad error: %s", err)
+	}
+}
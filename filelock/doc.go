@@ -4,5 +4,8 @@
 /*
 Package filelock makes it easy to create and check file locks for concurrent
 processes.
+
+Flock is a bare lock of the system. Lock adds the stamping of its exclusive
+owner, to detect the locks left by crashed processes.
 */
 package filelock
@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package filelock
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Lock is an advisory lock of a file, exclusive or shared, like Flock. The
+// exclusive owner of the lock stamps its PID and start time, see Owner,
+// until it unlocks, so that a lock left by a crashed process is
+// detected, see Recovered, and the lock files shared where the system can't
+// lock them still exclude the processes alive.
+type Lock struct {
+	path      string
+	flock     *Flock
+	exclusive bool
+	recovered *Owner
+}
+
+// NewLock returns an unlocked lock of the file at path, created if needed.
+func NewLock(path string) *Lock {
+	return &Lock{path: path, flock: New(path)}
+}
+
+// Path returns the path of the lock file.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// Recovered returns the owner of the stale lock the last lock recovered,
+// stamped by a process that died without unlocking, or nil. What the lock
+// protects may then be inconsistent, like a partial download.
+func (l *Lock) Recovered() *Owner {
+	return l.recovered
+}
+
+// TryLock tries to lock l exclusively, without waiting, and returns whether
+// it did.
+func (l *Lock) TryLock() (bool, error) {
+	return l.try(true)
+}
+
+// TryRLock tries to lock l shared, without waiting, and returns whether it
+// did.
+func (l *Lock) TryRLock() (bool, error) {
+	return l.try(false)
+}
+
+// TryLockContext tries to lock l exclusively every retryDelay until it does
+// or ctx is done, and returns whether it did.
+func (l *Lock) TryLockContext(ctx context.Context, retryDelay time.Duration) (bool, error) {
+	return l.tryContext(ctx, retryDelay, true)
+}
+
+// TryRLockContext tries to lock l shared every retryDelay until it does or
+// ctx is done, and returns whether it did.
+func (l *Lock) TryRLockContext(ctx context.Context, retryDelay time.Duration) (bool, error) {
+	return l.tryContext(ctx, retryDelay, false)
+}
+
+// Lock locks l exclusively, waiting as long as needed.
+func (l *Lock) Lock() error {
+	_, err := l.tryContext(context.Background(), 100*time.Millisecond, true)
+	return err
+}
+
+// RLock locks l shared, waiting as long as needed.
+func (l *Lock) RLock() error {
+	_, err := l.tryContext(context.Background(), 100*time.Millisecond, false)
+	return err
+}
+
+// Unlock unlocks l, clearing the stamp of its exclusive owner.
+func (l *Lock) Unlock() error {
+	if l.exclusive {
+		if err := clearOwner(l.path); err != nil {
+			log.Printf("[WARN] failed to clear the owner of lock %s: %s", l.path, err)
+		}
+		l.exclusive = false
+	}
+	return l.flock.Unlock()
+}
+
+func (l *Lock) tryContext(ctx context.Context, retryDelay time.Duration, exclusive bool) (bool, error) {
+	for {
+		locked, err := l.try(exclusive)
+		if err != nil || locked {
+			return locked, err
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (l *Lock) try(exclusive bool) (bool, error) {
+	var locked bool
+	var err error
+	if exclusive {
+		locked, err = l.flock.TryLock()
+	} else {
+		locked, err = l.flock.TryRLock()
+	}
+	if err != nil || !locked {
+		return false, err
+	}
+
+	l.recovered = nil
+	owner, err := ReadOwner(l.path)
+	if err != nil {
+		log.Printf("[WARN] %s", err)
+		owner = nil
+	}
+	if owner != nil && !owner.current() {
+		if owner.Alive() {
+			// The system didn't lock the file for the owner, like on a
+			// network filesystem: the stamp is the lock.
+			return false, l.flock.Unlock()
+		}
+		log.Printf("[WARN] Recovering lock %s left by %s", l.path, owner)
+		l.recovered = owner
+	}
+
+	if !exclusive {
+		if l.recovered != nil {
+			if err := clearOwner(l.path); err != nil {
+				l.flock.Unlock()
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if err := currentOwner().stamp(l.path); err != nil {
+		l.flock.Unlock()
+		return false, err
+	}
+	l.exclusive = true
+	return true, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package filelock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	l := NewLock(path)
+	if locked, err := l.TryLock(); !locked || err != nil {
+		t.Fatalf("lock failed: %t, %v", locked, err)
+	}
+	owner, err := ReadOwner(path)
+	if err != nil || owner == nil || owner.PID != os.Getpid() {
+		t.Fatalf("the lock should be stamped with the current process: %v, %v", owner, err)
+	}
+
+	other := NewLock(path)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if locked, err := other.TryRLockContext(ctx, 10*time.Millisecond); locked || err != context.DeadlineExceeded {
+		t.Fatalf("an exclusive lock should exclude shared ones: %t, %v", locked, err)
+	}
+
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := ReadOwner(path); owner != nil || err != nil {
+		t.Fatalf("the stamp should be cleared: %v, %v", owner, err)
+	}
+
+	if locked, err := l.TryRLock(); !locked || err != nil {
+		t.Fatalf("shared lock failed: %t, %v", locked, err)
+	}
+	if locked, err := other.TryRLock(); !locked || err != nil {
+		t.Fatalf("shared locks should not exclude each other: %t, %v", locked, err)
+	}
+	l.Unlock()
+	other.Unlock()
+}
+
+func TestLock_stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	host, _ := os.Hostname()
+
+	// a process that died without unlocking
+	dead := &Owner{PID: 1 << 30, Host: host}
+	if err := dead.stamp(path); err != nil {
+		t.Fatal(err)
+	}
+	l := NewLock(path)
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if r := l.Recovered(); r == nil || *r != *dead {
+		t.Fatalf("the stale lock should be recovered, got %v", r)
+	}
+	l.Unlock()
+
+	// a process alive holding the lock where the system can't lock files
+	alive := currentOwner()
+	alive.PID = os.Getppid()
+	alive.Start, _ = processStart(alive.PID)
+	if err := alive.stamp(path); err != nil {
+		t.Fatal(err)
+	}
+	if locked, err := l.TryLock(); locked || err != nil {
+		t.Fatalf("the stamp of a process alive should lock: %t, %v", locked, err)
+	}
+}
@@ -3,9 +3,19 @@
 
 package filelock
 
+import (
+	"context"
+	"time"
+)
+
 // this lock does nothing
 type Noop struct{}
 
-func (_ *Noop) Lock() (bool, error)    { return true, nil }
-func (_ *Noop) TryLock() (bool, error) { return true, nil }
-func (_ *Noop) Unlock() error          { return nil }
+func (_ *Noop) Lock() error             { return nil }
+func (_ *Noop) RLock() error            { return nil }
+func (_ *Noop) TryLock() (bool, error)  { return true, nil }
+func (_ *Noop) TryRLock() (bool, error) { return true, nil }
+func (_ *Noop) Unlock() error           { return nil }
+
+func (_ *Noop) TryLockContext(context.Context, time.Duration) (bool, error)  { return true, nil }
+func (_ *Noop) TryRLockContext(context.Context, time.Duration) (bool, error) { return true, nil }
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package filelock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Owner is the process holding a Lock exclusively, as stamped in the owner
+// file of its lock file, named after it with an ".owner" suffix. The lock
+// file itself can't be written on the systems where locks are mandatory.
+type Owner struct {
+	// PID is the process ID of the owner.
+	PID int
+	// Start is the start time of the owner, as given by the system, to tell
+	// it from a later process reusing its PID. It is empty where the start
+	// time of a process isn't known.
+	Start string
+	// Host is the host name of the owner, for the lock files shared over
+	// the network.
+	Host string
+}
+
+func (o *Owner) String() string {
+	return fmt.Sprintf("process %d on %s", o.PID, o.Host)
+}
+
+// currentOwner returns the Owner of the current process.
+func currentOwner() *Owner {
+	host, _ := os.Hostname()
+	start, _ := processStart(os.Getpid())
+	return &Owner{PID: os.Getpid(), Start: start, Host: host}
+}
+
+// Alive returns whether the owner is still running. The owners of other
+// hosts can't be checked and are deemed alive.
+func (o *Owner) Alive() bool {
+	if host, _ := os.Hostname(); host != o.Host {
+		return true
+	}
+	start, alive := processStart(o.PID)
+	if !alive {
+		return false
+	}
+	return o.Start == "" || start == "" || o.Start == start
+}
+
+// current returns whether o is the current process.
+func (o *Owner) current() bool {
+	self := currentOwner()
+	return *o == *self
+}
+
+// ownerPath returns the path of the owner file of the lock file at path.
+func ownerPath(path string) string {
+	return path + ".owner"
+}
+
+// ReadOwner returns the owner stamped for the lock file at path, or nil if
+// there is none, like when it is unlocked.
+func ReadOwner(path string) (*Owner, error) {
+	b, err := os.ReadFile(ownerPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid lock file %s: %q", path, b)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %q", path, b)
+	}
+	start := fields[1]
+	if start == "-" {
+		start = ""
+	}
+	return &Owner{PID: pid, Start: start, Host: fields[2]}, nil
+}
+
+// stamp writes o in the owner file of the lock file at path.
+func (o *Owner) stamp(path string) error {
+	start := o.Start
+	if start == "" {
+		start = "-"
+	}
+	return os.WriteFile(ownerPath(path), []byte(fmt.Sprintf("%d %s %s\n", o.PID, start, o.Host)), 0644)
+}
+
+// clearOwner removes the owner file of the lock file at path.
+func clearOwner(path string) error {
+	if err := os.Remove(ownerPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
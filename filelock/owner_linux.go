@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package filelock
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStart returns the start time of the process pid, in clock ticks
+// since the boot, and whether it is running.
+func processStart(pid int) (string, bool) {
+	b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return "", false
+	}
+	// The command, the second field, is between parentheses and can hold
+	// spaces; the start time is the 22nd field.
+	stat := string(b)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return "", true
+	}
+	return fields[19], true
+}
@@ -0,0 +1,23 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build unix && !linux
+
+package filelock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// processStart returns whether the process pid is running. Its start time
+// isn't known.
+func processStart(pid int) (string, bool) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return "", false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return "", err == nil || errors.Is(err, syscall.EPERM)
+}
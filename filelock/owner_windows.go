@@ -0,0 +1,19 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package filelock
+
+import "os"
+
+// processStart returns whether the process pid is running. Its start time
+// isn't known.
+func processStart(pid int) (string, bool) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return "", false
+	}
+	p.Release()
+	return "", true
+}
@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// GuestFactsStateKey is the key of the state bag GuestFactsFromState caches
+// the facts of the guest at.
+const GuestFactsStateKey = "guest_facts"
+
+// GuestFacts are the facts about the guest probed by DetectGuest.
+type GuestFacts struct {
+	// OSType is UnixOSType or WindowsOSType, to create GuestCommands with.
+	OSType string
+	// OSFamily is the kernel of the guest, in lower case: "linux",
+	// "darwin", "freebsd" or "windows".
+	OSFamily string
+	// OSName is the ID of the distribution from /etc/os-release, like
+	// "ubuntu", or the caption of Windows, like "Microsoft Windows Server
+	// 2022 Datacenter".
+	OSName string
+	// OSVersion is the VERSION_ID of the distribution from /etc/os-release,
+	// the release of the kernel when there is none, or the version of
+	// Windows, like "10.0.20348.0".
+	OSVersion string
+	// Shells are the shells available, like "sh", "bash", "powershell",
+	// "pwsh" or "cmd".
+	Shells []string
+	// Elevated is whether commands already run as root, or as an
+	// administrator.
+	Elevated bool
+	// Sudo is whether sudo is available, and SudoNoPassword whether it
+	// runs commands without asking for a password.
+	Sudo           bool
+	SudoNoPassword bool
+	// Runas is whether runas is available on Windows.
+	Runas bool
+	// PowerShellVersion is the version of Windows PowerShell, or of
+	// PowerShell on other systems, empty when it is not available.
+	PowerShellVersion string
+}
+
+// HasShell returns whether the shell of the given name is available.
+func (f *GuestFacts) HasShell(name string) bool {
+	for _, s := range f.Shells {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unixProbe prints the facts of a Unix guest, one key=value per line. The
+// keys are read by parseFacts.
+const unixProbe = `echo "family=$(uname -s)"
+echo "release=$(uname -r)"
+if [ -r /etc/os-release ]; then
+  (. /etc/os-release; echo "name=$ID"; echo "version=$VERSION_ID")
+fi
+for s in sh bash zsh dash ash pwsh; do
+  command -v $s >/dev/null 2>&1 && echo "shell=$s"
+done
+[ "$(id -u)" = 0 ] && echo "elevated=true"
+if command -v sudo >/dev/null 2>&1; then
+  echo "sudo=true"
+  sudo -n true >/dev/null 2>&1 && echo "sudo_nopasswd=true"
+fi
+command -v pwsh >/dev/null 2>&1 && echo "powershell=$(pwsh -NoProfile -Command '$PSVersionTable.PSVersion.ToString()')"
+exit 0`
+
+// windowsProbe prints the facts of a Windows guest like unixProbe.
+const windowsProbe = `"family=windows"
+"name=$((Get-CimInstance Win32_OperatingSystem).Caption)"
+"version=$([Environment]::OSVersion.Version)"
+"powershell=$($PSVersionTable.PSVersion)"
+"shell=powershell"
+"shell=cmd"
+if (Get-Command pwsh -ErrorAction SilentlyContinue) { "shell=pwsh" }
+if (Get-Command runas.exe -ErrorAction SilentlyContinue) { "runas=true" }
+$p = [Security.Principal.WindowsPrincipal][Security.Principal.WindowsIdentity]::GetCurrent()
+if ($p.IsInRole([Security.Principal.WindowsBuiltInRole]::Administrator)) { "elevated=true" }
+exit 0`
+
+// DetectGuest probes the facts of the guest with comm. It first tries the
+// commands of a Unix guest, then of a Windows guest.
+func DetectGuest(ctx context.Context, comm packersdk.Communicator) (*GuestFacts, error) {
+	out, unixErr := probe(ctx, comm, "sh -c "+unixQuote(unixProbe))
+	if unixErr == nil && strings.Contains(out, "family=") {
+		return parseFacts(out, UnixOSType), nil
+	}
+	log.Printf("[DEBUG] The guest doesn't look like a Unix guest: %v", unixErr)
+
+	out, windowsErr := probe(ctx, comm, powershellCommand(windowsProbe))
+	if windowsErr == nil && strings.Contains(out, "family=") {
+		return parseFacts(out, WindowsOSType), nil
+	}
+	return nil, fmt.Errorf("Error detecting the guest OS: as a Unix guest: %v, as a Windows guest: %v",
+		unixErr, windowsErr)
+}
+
+// GuestFactsFromState returns the facts of the guest cached in state, and
+// detects them with comm first when they are not.
+func GuestFactsFromState(ctx context.Context, state multistep.StateBag, comm packersdk.Communicator) (*GuestFacts, error) {
+	if facts, ok := state.GetOk(GuestFactsStateKey); ok {
+		return facts.(*GuestFacts), nil
+	}
+	facts, err := DetectGuest(ctx, comm)
+	if err != nil {
+		return nil, err
+	}
+	state.Put(GuestFactsStateKey, facts)
+	return facts, nil
+}
+
+// probe runs command, and returns its output when it succeeds.
+func probe(ctx context.Context, comm packersdk.Communicator, command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: command,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return "", err
+	}
+	if code := cmd.Wait(); code != 0 {
+		return "", fmt.Errorf("exited with %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return "", errors.New("no output")
+	}
+	return stdout.String(), nil
+}
+
+func parseFacts(out string, osType string) *GuestFacts {
+	f := &GuestFacts{OSType: osType}
+	var release string
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(s.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "family":
+			f.OSFamily = strings.ToLower(value)
+		case "release":
+			release = value
+		case "name":
+			f.OSName = value
+		case "version":
+			f.OSVersion = value
+		case "shell":
+			f.Shells = append(f.Shells, value)
+		case "elevated":
+			f.Elevated = true
+		case "sudo":
+			f.Sudo = true
+		case "sudo_nopasswd":
+			f.SudoNoPassword = true
+		case "runas":
+			f.Runas = true
+		case "powershell":
+			f.PowerShellVersion = value
+		}
+	}
+	if f.OSVersion == "" {
+		f.OSVersion = release
+	}
+	return f
+}
+
+// unixQuote quotes s for a POSIX shell.
+func unixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// powershellCommand returns the command running script with PowerShell. The
+// script is passed encoded, so that the shell running the command, cmd on
+// Windows, doesn't interpret it.
+func powershellCommand(script string) string {
+	// Progress records end up as CLIXML in the output when there is no
+	// console.
+	script = "$ProgressPreference = 'SilentlyContinue'\n" + script
+
+	encoded := utf16.Encode([]rune(script))
+	b := make([]byte, 2*len(encoded))
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+	return "powershell.exe -NoLogo -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand " +
+		base64.StdEncoding.EncodeToString(b)
+}
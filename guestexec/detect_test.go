@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
+)
+
+// windowsGuest is a communicator answering the commands like a Windows
+// guest, where sh is not available.
+type windowsGuest struct {
+	packersdk.MockCommunicator
+	commands []string
+}
+
+func (c *windowsGuest) Start(_ context.Context, cmd *packersdk.RemoteCmd) error {
+	c.commands = append(c.commands, cmd.Command)
+	go func() {
+		if !strings.HasPrefix(cmd.Command, "powershell.exe ") {
+			io.WriteString(cmd.Stderr, "'sh' is not recognized as an internal or external command")
+			cmd.SetExited(1)
+			return
+		}
+		io.WriteString(cmd.Stdout, "family=windows\r\nname=Microsoft Windows Server 2022 Datacenter\r\n"+
+			"version=10.0.20348.0\r\npowershell=5.1.20348.2227\r\nshell=powershell\r\nshell=cmd\r\nrunas=true\r\nelevated=true\r\n")
+		cmd.SetExited(0)
+	}()
+	return nil
+}
+
+func TestDetectGuest_windows(t *testing.T) {
+	comm := new(windowsGuest)
+	state := new(multistep.BasicStateBag)
+	facts, err := GuestFactsFromState(context.Background(), state, comm)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := &GuestFacts{
+		OSType:            WindowsOSType,
+		OSFamily:          "windows",
+		OSName:            "Microsoft Windows Server 2022 Datacenter",
+		OSVersion:         "10.0.20348.0",
+		Shells:            []string{"powershell", "cmd"},
+		Elevated:          true,
+		Runas:             true,
+		PowerShellVersion: "5.1.20348.2227",
+	}
+	if diff := cmp.Diff(expected, facts); diff != "" {
+		t.Fatalf("unexpected facts: %s", diff)
+	}
+
+	// The facts are cached in the state.
+	if _, err := GuestFactsFromState(context.Background(), state, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(comm.commands) != 2 {
+		t.Fatalf("expected the guest to be probed once, got %q", comm.commands)
+	}
+}
+
+func TestDetectGuest_unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the host is not a Unix system")
+	}
+	facts, err := DetectGuest(context.Background(), local.New(&local.Config{}))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if facts.OSType != UnixOSType || facts.OSFamily != runtime.GOOS || !facts.HasShell("sh") || facts.OSVersion == "" {
+		t.Fatalf("unexpected facts %#v", facts)
+	}
+}
+
+func TestDetectGuest_unknown(t *testing.T) {
+	comm := &packersdk.MockCommunicator{StartExitStatus: 127}
+	if _, err := DetectGuest(context.Background(), comm); err == nil {
+		t.Fatal("detecting an unknown guest should fail")
+	}
+}
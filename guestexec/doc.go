@@ -9,6 +9,11 @@ simple and repeatable.
 
 Note that to successfully use this package your provisioner must have knowledge
 of the guest type, which is not information that builders generally collect --
-your provisioner will have to require guest information in its config.
+your provisioner will have to require guest information in its config, or
+probe it with DetectGuest. GuestFactsFromState caches the probed facts in the
+state bag, so that the provisioners of a build only probe the guest once.
+
+RunScript uploads a script to the guest, runs it and removes it, retrying when
+the script fails to start for a transient reason.
 */
 package guestexec
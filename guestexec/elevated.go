@@ -15,6 +15,8 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
 )
 
+// ElevatedProvisioner is a provisioner running commands as an elevated user,
+// see GenerateElevatedCommand.
 type ElevatedProvisioner interface {
 	Communicator() packersdk.Communicator
 	ElevatedUser() string
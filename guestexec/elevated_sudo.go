@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// GenerateElevatedCommand returns the command running command as the
+// elevated user of p on a guest of the given OS type: through a scheduled
+// task on Windows, see GenerateElevatedRunner, and with sudo otherwise, see
+// GenerateSudoRunner and RunSudo.
+func GenerateElevatedCommand(osType string, command string, p ElevatedProvisioner) (string, error) {
+	switch osType {
+	case WindowsOSType:
+		return GenerateElevatedRunner(command, p)
+	case UnixOSType:
+		return GenerateSudoRunner(command, p)
+	default:
+		return "", fmt.Errorf("Invalid osType: \"%s\"", osType)
+	}
+}
+
+// GenerateSudoRunner returns the command running command, a shell command,
+// with sudo as the elevated user of p, or root when it is not set. The output
+// of the command is streamed and its exit code is kept.
+//
+// When p has an elevated password, sudo gets it from the askpass program of
+// SUDO_ASKPASS: run the command with RunSudo, that provides it.
+func GenerateSudoRunner(command string, p ElevatedProvisioner) (string, error) {
+	log.Printf("Building sudo command wrapper for: %s", command)
+
+	sudo := "sudo -n"
+	if p.ElevatedPassword() != "" {
+		// -A makes sudo ask the password to the askpass program.
+		sudo = "sudo -A"
+	}
+	if user := p.ElevatedUser(); user != "" {
+		sudo += " -u " + unixQuote(user)
+	}
+	return fmt.Sprintf("%s -- /bin/sh -c %s", sudo, unixQuote(command)), nil
+}
+
+// RunSudo runs cmd, a shell command, with sudo as the elevated user of p, see
+// GenerateSudoRunner, like cmd.RunWithUi.
+//
+// When p has an elevated password, the askpass program giving it to sudo is
+// uploaded right before the command starts, to a directory only the user of
+// the communicator can read, and removed once the command ends. The
+// directory is created in /tmp, which must not be mounted noexec.
+func RunSudo(ctx context.Context, ui packersdk.Ui, p ElevatedProvisioner, cmd *packersdk.RemoteCmd) error {
+	command, err := GenerateSudoRunner(cmd.Command, p)
+	if err != nil {
+		return err
+	}
+	comm := p.Communicator()
+
+	if password := p.ElevatedPassword(); password != "" {
+		dir := fmt.Sprintf("/tmp/packer-askpass-%s", uuid.TimeOrderedUUID())
+		askpass := dir + "/askpass"
+		// The trap of the command removes the askpass directory as soon as
+		// it exits; this removes it when the command didn't even start.
+		defer func() {
+			rm := &packersdk.RemoteCmd{Command: "rm -rf " + unixQuote(dir)}
+			if err := rm.RunWithUi(context.TODO(), comm, ui); err != nil || rm.ExitStatus() != 0 {
+				ui.Error(fmt.Sprintf("Error removing the askpass directory %s: %v, exited with %d", dir, err, rm.ExitStatus()))
+			}
+		}()
+		if err := uploadAskpass(ctx, comm, dir, askpass, password); err != nil {
+			return err
+		}
+		wrapper := fmt.Sprintf(`trap 'rm -rf %[1]s' EXIT; chmod 700 %[2]s && SUDO_ASKPASS=%[2]s %[3]s`,
+			dir, unixQuote(askpass), command)
+		command = "/bin/sh -c " + unixQuote(wrapper)
+	}
+
+	elevated := &packersdk.RemoteCmd{
+		Command: command,
+		Stdin:   cmd.Stdin,
+		Stdout:  cmd.Stdout,
+		Stderr:  cmd.Stderr,
+	}
+	if err := elevated.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	cmd.SetExited(elevated.ExitStatus())
+	return nil
+}
+
+// uploadAskpass uploads to askpass, in the new directory dir, the askpass
+// program printing password.
+func uploadAskpass(ctx context.Context, comm packersdk.Communicator, dir, askpass, password string) error {
+	var stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("umask 077 && mkdir %s", unixQuote(dir)),
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return fmt.Errorf("Error creating the askpass directory: %s", err)
+	}
+	if code := cmd.Wait(); code != 0 {
+		return fmt.Errorf("Error creating the askpass directory: exited with %d: %s",
+			code, strings.TrimSpace(stderr.String()))
+	}
+
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' %s\n", unixQuote(password))
+	log.Printf("Uploading askpass program to [%s]", askpass)
+	if err := comm.Upload(askpass, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Error uploading the askpass program: %s", err)
+	}
+	return nil
+}
@@ -4,10 +4,16 @@
 package guestexec
 
 import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
 )
 
 func testConfig() map[string]interface{} {
@@ -39,3 +45,85 @@ func TestProvisioner_GenerateElevatedRunner(t *testing.T) {
 		t.Fatalf("Got unexpected file: %s", path)
 	}
 }
+
+// sudoProvisioner is an ElevatedProvisioner running commands on the host.
+type sudoProvisioner struct {
+	comm     packersdk.Communicator
+	user     string
+	password string
+}
+
+func (p *sudoProvisioner) Communicator() packersdk.Communicator { return p.comm }
+func (p *sudoProvisioner) ElevatedUser() string                 { return p.user }
+func (p *sudoProvisioner) ElevatedPassword() string             { return p.password }
+
+// fakeSudo is a sudo checking the password given by the askpass program,
+// and logging its arguments to $0.log.
+const fakeSudo = `#!/bin/sh
+printf "%s\n" "$*" >> "$0.log"
+if [ "$1" = -A ]; then
+  [ "$("$SUDO_ASKPASS")" = "p'ss" ] || { echo "wrong password" >&2; exit 1; }
+fi
+while [ "$1" != -- ]; do shift; done
+shift
+exec "$@"
+`
+
+func TestRunSudo(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte(fakeSudo), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	for _, p := range []*sudoProvisioner{
+		{comm: local.New(&local.Config{}), password: "p'ss"},
+		{comm: local.New(&local.Config{}), user: "admin"},
+	} {
+		var stdout, stderr bytes.Buffer
+		cmd := &packersdk.RemoteCmd{Command: `echo "it's elevated"; exit 3`, Stdout: &stdout, Stderr: &stderr}
+		if err := RunSudo(context.Background(), packersdk.TestUi(t), p, cmd); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if code := cmd.ExitStatus(); code != 3 {
+			t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+		}
+		if stdout.String() != "it's elevated\n" {
+			t.Fatalf("unexpected output %q", stdout.String())
+		}
+	}
+
+	log, _ := os.ReadFile(filepath.Join(bin, "sudo.log"))
+	if lines := strings.Split(strings.TrimSpace(string(log)), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "-A -- /bin/sh -c") || !strings.HasPrefix(lines[1], "-n -u admin -- /bin/sh -c") {
+		t.Fatalf("unexpected sudo arguments %q", log)
+	}
+	if askpass, _ := filepath.Glob("/tmp/packer-askpass-*"); len(askpass) > 0 {
+		t.Fatalf("the askpass directories weren't removed: %q", askpass)
+	}
+}
+
+func TestGenerateSudoRunner(t *testing.T) {
+	comm := new(packersdk.MockCommunicator)
+	p := &sudoProvisioner{comm: comm, user: "admin", password: "p'ss"}
+	command, err := GenerateSudoRunner("id", p)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if command != "sudo -A -u 'admin' -- /bin/sh -c 'id'" {
+		t.Fatalf("unexpected command %q", command)
+	}
+	if comm.StartCalled || comm.UploadCalled {
+		t.Fatal("generating the command shouldn't run anything on the guest")
+	}
+}
+
+func TestGenerateElevatedCommand(t *testing.T) {
+	p := &sudoProvisioner{comm: new(packersdk.MockCommunicator)}
+	if command, err := GenerateElevatedCommand(UnixOSType, "id", p); err != nil || command != "sudo -n -- /bin/sh -c 'id'" {
+		t.Fatalf("unexpected command %q: %v", command, err)
+	}
+	if _, err := GenerateElevatedCommand("Amiga", "id", p); err == nil {
+		t.Fatal("Should have returned an err for unsupported OS type")
+	}
+}
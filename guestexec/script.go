@@ -0,0 +1,211 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// ScriptOptions configures RunScript.
+type ScriptOptions struct {
+	// GuestOSType is UnixOSType, the default, or WindowsOSType.
+	GuestOSType string
+	// Sudo runs the commands managing the script with sudo on Unix guests.
+	// Set ExecuteCommand to run the script itself with sudo.
+	Sudo bool
+	// RemoteFolder is the folder the script is uploaded to. Defaults to
+	// /tmp, or C:/Windows/Temp on Windows.
+	RemoteFolder string
+	// Extension is the extension of the name of the script. Defaults to
+	// ".sh", or ".ps1" on Windows.
+	Extension string
+	// ExecuteCommand is the template of the command running the script,
+	// with {{.Path}}, the path of the script, and {{.Vars}}, the commands
+	// setting Env. Defaults to `{{.Vars}} {{.Path}}` once the script is made
+	// executable, or to running {{.Path}} with PowerShell on Windows.
+	ExecuteCommand string
+	// Env are the environment variables of the script, as "key=value".
+	Env []string
+	// Stdout and Stderr receive the output of the script, which is also
+	// written to the Ui.
+	Stdout io.Writer
+	Stderr io.Writer
+	// Retries is the number of times the script is run again after a
+	// retryable error, see IsRetryableScriptError. By default, the script
+	// runs once.
+	Retries int
+	// RetryDelay is the time to wait between two tries. Defaults to 2
+	// seconds.
+	RetryDelay time.Duration
+}
+
+// retryableOutputs are the outputs of scripts that failed to start
+// because of a transient error.
+var retryableOutputs = []string{
+	// The upload of the script is not done yet for the kernel.
+	"text file busy",
+}
+
+// ErrRetryableScript is wrapped by the errors of scripts that failed to start
+// because of a transient error, and can be run again.
+var ErrRetryableScript = errors.New("the script failed to start")
+
+// IsRetryableScriptError returns whether the error returned by running a
+// script is transient, when the script failed to start for a reason like
+// "text file busy", or the communicator failed to transfer it or to start
+// it, like WinRM occasionally does.
+func IsRetryableScriptError(err error) bool {
+	return errors.Is(err, ErrRetryableScript)
+}
+
+type scriptTemplateData struct {
+	Path string
+	Vars string
+}
+
+// RunScript uploads script to the guest with a unique name, runs it with
+// its environment variables and removes it, whatever the script exits with.
+// It returns the exit code of the script.
+func RunScript(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, script io.Reader, opts ScriptOptions) (int, error) {
+	osType := opts.GuestOSType
+	if osType == "" {
+		osType = DefaultOSType
+	}
+	guestCommands, err := NewGuestCommands(osType, opts.Sudo)
+	if err != nil {
+		return 0, err
+	}
+	content, err := io.ReadAll(script)
+	if err != nil {
+		return 0, fmt.Errorf("Error reading script: %s", err)
+	}
+
+	folder, extension := opts.RemoteFolder, opts.Extension
+	executeCommand := opts.ExecuteCommand
+	if osType == WindowsOSType {
+		if folder == "" {
+			folder = "C:/Windows/Temp"
+		}
+		if extension == "" {
+			extension = ".ps1"
+		}
+		if executeCommand == "" {
+			executeCommand = `powershell -executionpolicy bypass "& { if (Test-Path variable:global:ProgressPreference){$ProgressPreference='SilentlyContinue'};{{.Vars}}&'{{.Path}}'; exit $LastExitCode }"`
+		}
+	} else {
+		if folder == "" {
+			folder = "/tmp"
+		}
+		if extension == "" {
+			extension = ".sh"
+		}
+		if executeCommand == "" {
+			executeCommand = "{{.Vars}} {{.Path}}"
+		}
+	}
+	path := fmt.Sprintf("%s/script_%s%s", strings.TrimSuffix(folder, "/"), uuid.TimeOrderedUUID(), extension)
+
+	command, err := interpolate.Render(executeCommand, &interpolate.Context{
+		Data: &scriptTemplateData{
+			Path: path,
+			Vars: scriptVars(osType, opts.Env),
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Error processing command: %s", err)
+	}
+
+	defer func() {
+		cmd := &packersdk.RemoteCmd{Command: guestCommands.RemoveDir(path)}
+		if err := cmd.RunWithUi(context.TODO(), comm, ui); err != nil || cmd.ExitStatus() != 0 {
+			ui.Error(fmt.Sprintf("Error removing script %s: %v, exited with %d", path, err, cmd.ExitStatus()))
+		}
+	}()
+
+	retryDelay := opts.RetryDelay
+	if retryDelay == 0 {
+		retryDelay = 2 * time.Second
+	}
+	var exitStatus int
+	retries := 0
+	err = retry.Config{
+		ShouldRetry: func(err error) bool {
+			retries++
+			return retries <= opts.Retries && IsRetryableScriptError(err)
+		},
+		RetryDelay: func() time.Duration { return retryDelay },
+	}.Run(ctx, func(ctx context.Context) error {
+		log.Printf("Uploading script to %s", path)
+		if err := comm.Upload(path, bytes.NewReader(content), nil); err != nil {
+			return fmt.Errorf("%w: Error uploading script: %s", ErrRetryableScript, err)
+		}
+		if osType == UnixOSType {
+			cmd := &packersdk.RemoteCmd{Command: guestCommands.Chmod(path, "0755")}
+			if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+				return fmt.Errorf("%w: Error making script executable: %s", ErrRetryableScript, err)
+			}
+			if code := cmd.ExitStatus(); code != 0 {
+				return fmt.Errorf("Error making script executable: chmod exited with %d", code)
+			}
+		}
+
+		var stderr bytes.Buffer
+		cmd := &packersdk.RemoteCmd{
+			Command: command,
+			Stdout:  opts.Stdout,
+			Stderr:  &stderr,
+		}
+		if opts.Stderr != nil {
+			cmd.Stderr = io.MultiWriter(&stderr, opts.Stderr)
+		}
+		log.Printf("Executing script %s: %s", path, command)
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+			return fmt.Errorf("%w: %s", ErrRetryableScript, err)
+		}
+		exitStatus = cmd.ExitStatus()
+		if exitStatus != 0 {
+			output := strings.ToLower(stderr.String())
+			for _, o := range retryableOutputs {
+				if strings.Contains(output, o) {
+					return fmt.Errorf("%w: %s", ErrRetryableScript, o)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return exitStatus, nil
+}
+
+// scriptVars returns the commands setting the environment variables of env,
+// as "key=value", sorted by name.
+func scriptVars(osType string, env []string) string {
+	vars := append([]string(nil), env...)
+	sort.Strings(vars)
+	var b strings.Builder
+	for _, kv := range vars {
+		key, value, _ := strings.Cut(kv, "=")
+		if osType == WindowsOSType {
+			fmt.Fprintf(&b, "$env:%s=\"%s\"; ", key, psEscape.Replace(value))
+		} else {
+			fmt.Fprintf(&b, "%s=%s ", key, unixQuote(value))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package guestexec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/local"
+)
+
+func TestRunScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the host is not a Unix system")
+	}
+	dir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	script := "#!/bin/sh\necho \"$0 $FOO $BAR\"\necho oops >&2\nexit 3\n"
+	code, err := RunScript(context.Background(), packersdk.TestUi(t), local.New(&local.Config{}),
+		strings.NewReader(script), ScriptOptions{
+			RemoteFolder: dir,
+			Env:          []string{"FOO=foo", "BAR=it's bar"},
+			Stdout:       &stdout,
+			Stderr:       &stderr,
+		})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if code != 3 {
+		t.Fatalf("expected the script to exit with 3, got %d", code)
+	}
+	fields := strings.SplitN(strings.TrimSpace(stdout.String()), " ", 2)
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], dir+"/script_") || fields[1] != "foo it's bar" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Fatalf("unexpected error output %q", stderr.String())
+	}
+
+	// The script is removed.
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected the script to be removed, got %v", entries)
+	}
+}
+
+// busyComm is a communicator failing to start the script with "text file
+// busy" the first times.
+type busyComm struct {
+	packersdk.MockCommunicator
+	busy     int
+	commands []string
+	uploads  int
+}
+
+func (c *busyComm) Upload(string, io.Reader, *os.FileInfo) error {
+	c.uploads++
+	return nil
+}
+
+func (c *busyComm) Start(_ context.Context, cmd *packersdk.RemoteCmd) error {
+	c.commands = append(c.commands, cmd.Command)
+	go func() {
+		if strings.Contains(cmd.Command, "script_") && strings.HasPrefix(cmd.Command, "FOO=") {
+			if c.busy > 0 {
+				c.busy--
+				io.WriteString(cmd.Stderr, "/bin/sh: 1: /tmp/script.sh: Text file busy\n")
+				cmd.SetExited(126)
+				return
+			}
+		}
+		cmd.SetExited(0)
+	}()
+	return nil
+}
+
+func TestRunScript_retry(t *testing.T) {
+	opts := ScriptOptions{
+		Env:        []string{"FOO=foo"},
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	}
+	comm := &busyComm{busy: 2}
+	code, err := RunScript(context.Background(), packersdk.TestUi(t), comm, strings.NewReader("true"), opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if code != 0 || comm.uploads != 3 {
+		t.Fatalf("expected the script to succeed after 3 uploads, got %d and %d uploads", code, comm.uploads)
+	}
+	if last := comm.commands[len(comm.commands)-1]; !strings.HasPrefix(last, "rm -rf '/tmp/script_") {
+		t.Fatalf("expected the script to be removed, got %q", comm.commands)
+	}
+
+	comm = &busyComm{busy: 3}
+	_, err = RunScript(context.Background(), packersdk.TestUi(t), comm, strings.NewReader("true"), opts)
+	if !IsRetryableScriptError(err) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+	if comm.uploads != 3 {
+		t.Fatalf("expected 3 uploads, got %d", comm.uploads)
+	}
+}
+
+func TestRunScript_windows(t *testing.T) {
+	comm := new(packersdk.MockCommunicator)
+	_, err := RunScript(context.Background(), packersdk.TestUi(t), comm, strings.NewReader("Write-Host hi"),
+		ScriptOptions{
+			GuestOSType: WindowsOSType,
+			Env:         []string{`B=b"$`, "A=a"},
+		})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.HasPrefix(comm.UploadPath, "C:/Windows/Temp/script_") || !strings.HasSuffix(comm.UploadPath, ".ps1") {
+		t.Fatalf("unexpected path %q", comm.UploadPath)
+	}
+	if comm.UploadData != "Write-Host hi" {
+		t.Fatalf("unexpected script %q", comm.UploadData)
+	}
+	// The last command is the removal of the script.
+	if !strings.HasPrefix(comm.StartCmd.Command, "powershell.exe -Command \"rm C:/Windows/Temp/script_") {
+		t.Fatalf("unexpected command %q", comm.StartCmd.Command)
+	}
+}
+
+func TestScriptVars(t *testing.T) {
+	env := []string{"B=it's", "A=a=b"}
+	if vars := scriptVars(UnixOSType, env); vars != `A='a=b' B='it'\''s'` {
+		t.Fatalf("unexpected vars %q", vars)
+	}
+	if vars := scriptVars(WindowsOSType, env); vars != "$env:A=\"a=b\"; $env:B=\"it`'s\";" {
+		t.Fatalf("unexpected vars %q", vars)
+	}
+}
@@ -35,8 +35,11 @@ type MockTag struct {
 type MockConfig struct {
 	NotSquashed      string `mapstructure:"not_squashed"`
 	NestedMockConfig `mapstructure:",squash"`
-	Nested           NestedMockConfig   `mapstructure:"nested"`
-	NestedSlice      []NestedMockConfig `mapstructure:"nested_slice"`
+	Nested           NestedMockConfig            `mapstructure:"nested"`
+	NestedSlice      []NestedMockConfig          `mapstructure:"nested_slice"`
+	NestedPtrSlice   []*MockTag                  `mapstructure:"nested_ptr_slice"`
+	NestedMap        map[string]NestedMockConfig `mapstructure:"nested_map"`
+	Extra            interface{}                 `mapstructure:"extra"`
 }
 
 type NamedMapStringString map[string]string
@@ -10,22 +10,25 @@ import (
 // FlatMockConfig is an auto-generated flat version of MockConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatMockConfig struct {
-	NotSquashed          *string                `mapstructure:"not_squashed" cty:"not_squashed" hcl:"not_squashed"`
-	String               *string                `mapstructure:"string" cty:"string" hcl:"string"`
-	Int                  *int                   `mapstructure:"int" cty:"int" hcl:"int"`
-	Int64                *int64                 `mapstructure:"int64" cty:"int64" hcl:"int64"`
-	Bool                 *bool                  `mapstructure:"bool" cty:"bool" hcl:"bool"`
-	Trilean              *bool                  `mapstructure:"trilean" cty:"trilean" hcl:"trilean"`
-	Duration             *string                `mapstructure:"duration" cty:"duration" hcl:"duration"`
-	MapStringString      map[string]string      `mapstructure:"map_string_string" cty:"map_string_string" hcl:"map_string_string"`
-	SliceString          []string               `mapstructure:"slice_string" cty:"slice_string" hcl:"slice_string"`
-	SliceSliceString     [][]string             `mapstructure:"slice_slice_string" cty:"slice_slice_string" hcl:"slice_slice_string"`
-	NamedMapStringString NamedMapStringString   `mapstructure:"named_map_string_string" cty:"named_map_string_string" hcl:"named_map_string_string"`
-	NamedString          *NamedString           `mapstructure:"named_string" cty:"named_string" hcl:"named_string"`
-	Tags                 []FlatMockTag          `mapstructure:"tag" cty:"tag" hcl:"tag"`
-	Datasource           *string                `mapstructure:"data_source" cty:"data_source" hcl:"data_source"`
-	Nested               *FlatNestedMockConfig  `mapstructure:"nested" cty:"nested" hcl:"nested"`
-	NestedSlice          []FlatNestedMockConfig `mapstructure:"nested_slice" cty:"nested_slice" hcl:"nested_slice"`
+	NotSquashed          *string                         `mapstructure:"not_squashed" cty:"not_squashed" hcl:"not_squashed"`
+	String               *string                         `mapstructure:"string" cty:"string" hcl:"string"`
+	Int                  *int                            `mapstructure:"int" cty:"int" hcl:"int"`
+	Int64                *int64                          `mapstructure:"int64" cty:"int64" hcl:"int64"`
+	Bool                 *bool                           `mapstructure:"bool" cty:"bool" hcl:"bool"`
+	Trilean              *bool                           `mapstructure:"trilean" cty:"trilean" hcl:"trilean"`
+	Duration             *string                         `mapstructure:"duration" cty:"duration" hcl:"duration"`
+	MapStringString      map[string]string               `mapstructure:"map_string_string" cty:"map_string_string" hcl:"map_string_string"`
+	SliceString          []string                        `mapstructure:"slice_string" cty:"slice_string" hcl:"slice_string"`
+	SliceSliceString     [][]string                      `mapstructure:"slice_slice_string" cty:"slice_slice_string" hcl:"slice_slice_string"`
+	NamedMapStringString NamedMapStringString            `mapstructure:"named_map_string_string" cty:"named_map_string_string" hcl:"named_map_string_string"`
+	NamedString          *NamedString                    `mapstructure:"named_string" cty:"named_string" hcl:"named_string"`
+	Tags                 []FlatMockTag                   `mapstructure:"tag" cty:"tag" hcl:"tag"`
+	Datasource           *string                         `mapstructure:"data_source" cty:"data_source" hcl:"data_source"`
+	Nested               *FlatNestedMockConfig           `mapstructure:"nested" cty:"nested" hcl:"nested"`
+	NestedSlice          []FlatNestedMockConfig          `mapstructure:"nested_slice" cty:"nested_slice" hcl:"nested_slice"`
+	NestedPtrSlice       []*FlatMockTag                  `mapstructure:"nested_ptr_slice" cty:"nested_ptr_slice" hcl:"nested_ptr_slice"`
+	NestedMap            map[string]FlatNestedMockConfig `mapstructure:"nested_map" cty:"nested_map" hcl:"nested_map"`
+	Extra                cty.Value                       `mapstructure:"extra" cty:"extra" hcl:"extra"`
 }
 
 // FlatMapstructure returns a new FlatMockConfig.
@@ -56,6 +59,9 @@ func (*FlatMockConfig) HCL2Spec() map[string]hcldec.Spec {
 		"data_source":             &hcldec.AttrSpec{Name: "data_source", Type: cty.String, Required: false},
 		"nested":                  &hcldec.BlockSpec{TypeName: "nested", Nested: hcldec.ObjectSpec((*FlatNestedMockConfig)(nil).HCL2Spec())},
 		"nested_slice":            &hcldec.BlockListSpec{TypeName: "nested_slice", Nested: hcldec.ObjectSpec((*FlatNestedMockConfig)(nil).HCL2Spec())},
+		"nested_ptr_slice":        &hcldec.BlockListSpec{TypeName: "nested_ptr_slice", Nested: hcldec.ObjectSpec((*FlatMockTag)(nil).HCL2Spec())},
+		"nested_map":              &hcldec.BlockMapSpec{TypeName: "nested_map", LabelNames: []string{"name"}, Nested: hcldec.ObjectSpec((*FlatNestedMockConfig)(nil).HCL2Spec())},
+		"extra":                   &hcldec.AttrSpec{Name: "extra", Type: cty.DynamicPseudoType, Required: false},
 	}
 	return s
 }
@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// OutputDiff is a difference between the value returned by a datasource and
+// the type declared by its OutputSpec.
+type OutputDiff struct {
+	// Path is the path of the value in the output, like "tags.name" or
+	// "disks[0].size".
+	Path string
+	// Want is the type declared by the OutputSpec, cty.NilType if the value
+	// is not declared.
+	Want cty.Type
+	// Got is the type of the value, cty.NilType if the value is missing.
+	Got cty.Type
+}
+
+func (d OutputDiff) String() string {
+	switch {
+	case d.Got == cty.NilType:
+		return fmt.Sprintf("- %s: missing, expected %s", d.Path, d.Want.FriendlyName())
+	case d.Want == cty.NilType:
+		return fmt.Sprintf("+ %s: %s not declared by the OutputSpec", d.Path, d.Got.FriendlyName())
+	}
+	return fmt.Sprintf("~ %s: expected %s, got %s", d.Path, d.Want.FriendlyName(), d.Got.FriendlyName())
+}
+
+// OutputError is returned when the value returned by a datasource doesn't
+// conform to its OutputSpec.
+type OutputError struct {
+	Diffs []OutputDiff
+}
+
+func (e *OutputError) Error() string {
+	var b strings.Builder
+	b.WriteString("the datasource output does not match its OutputSpec:")
+	for _, d := range e.Diffs {
+		b.WriteString("\n  ")
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
+
+// ValidateOutput checks that value, returned by the Execute method of a
+// datasource, conforms to spec, its OutputSpec. Null and unknown values
+// conform to any type. The returned error is an *OutputError listing the
+// differences, with - for the attributes missing from value, + for the ones
+// missing from spec and ~ for the ones of a different type.
+func ValidateOutput(spec hcldec.ObjectSpec, value cty.Value) error {
+	diffs := outputDiffs("", hcldec.ImpliedType(spec), value)
+	if len(diffs) == 0 {
+		return nil
+	}
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return &OutputError{Diffs: diffs}
+}
+
+func outputDiffs(path string, want cty.Type, value cty.Value) []OutputDiff {
+	if value.IsNull() || !value.IsKnown() || want == cty.DynamicPseudoType {
+		return nil
+	}
+	got := value.Type()
+	if got.Equals(want) {
+		return nil
+	}
+	mismatch := []OutputDiff{{Path: rootPath(path), Want: want, Got: got}}
+
+	switch {
+	case want.IsObjectType():
+		if !got.IsObjectType() {
+			return mismatch
+		}
+		var diffs []OutputDiff
+		for name, at := range want.AttributeTypes() {
+			if !got.HasAttribute(name) {
+				diffs = append(diffs, OutputDiff{Path: attrPath(path, name), Want: at})
+				continue
+			}
+			diffs = append(diffs, outputDiffs(attrPath(path, name), at, value.GetAttr(name))...)
+		}
+		for name, at := range got.AttributeTypes() {
+			if !want.HasAttribute(name) {
+				diffs = append(diffs, OutputDiff{Path: attrPath(path, name), Got: at})
+			}
+		}
+		return diffs
+	case want.IsMapType():
+		if !got.IsMapType() && !got.IsObjectType() {
+			return mismatch
+		}
+		var diffs []OutputDiff
+		for it := value.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			diffs = append(diffs, outputDiffs(attrPath(path, k.AsString()), want.ElementType(), v)...)
+		}
+		return diffs
+	case want.IsListType(), want.IsSetType():
+		if !got.IsListType() && !got.IsSetType() && !got.IsTupleType() {
+			return mismatch
+		}
+		var diffs []OutputDiff
+		i := 0
+		for it := value.ElementIterator(); it.Next(); i++ {
+			_, v := it.Element()
+			diffs = append(diffs, outputDiffs(fmt.Sprintf("%s[%d]", path, i), want.ElementType(), v)...)
+		}
+		return diffs
+	}
+	return mismatch
+}
+
+func attrPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func rootPath(path string) string {
+	if path == "" {
+		return "(output)"
+	}
+	return path
+}
+
+// ExecuteDatasource executes d and checks that the value it returns conforms
+// to its OutputSpec with ValidateOutput.
+func ExecuteDatasource(d packersdk.Datasource) (cty.Value, error) {
+	value, err := d.Execute()
+	if err != nil {
+		return value, err
+	}
+	if err := ValidateOutput(d.OutputSpec(), value); err != nil {
+		return value, err
+	}
+	return value, nil
+}
+
+// TestDatasourceOutput executes d, a configured datasource, and fails the test
+// if the value it returns doesn't conform to its OutputSpec. It returns the
+// value for further checks.
+func TestDatasourceOutput(t testing.TB, d packersdk.Datasource) cty.Value {
+	t.Helper()
+	value, err := ExecuteDatasource(d)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return value
+}
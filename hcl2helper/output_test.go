@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl2helper
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestValidateOutput(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name": &hcldec.AttrSpec{Name: "name", Type: cty.String},
+		"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		"tags": &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.String)},
+		"disks": &hcldec.BlockListSpec{TypeName: "disks", Nested: hcldec.ObjectSpec{
+			"size": &hcldec.AttrSpec{Name: "size", Type: cty.Number},
+		}},
+	}
+
+	tests := []struct {
+		Name  string
+		Value cty.Value
+		Err   string
+	}{
+		{
+			Name: "conforming",
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("ubuntu"),
+				"size": cty.NumberIntVal(10),
+				"tags": cty.MapVal(map[string]cty.Value{"os": cty.StringVal("linux")}),
+				"disks": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"size": cty.NumberIntVal(10)}),
+				}),
+			}),
+		},
+		{
+			Name: "nulls and objects for maps",
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.NullVal(cty.String),
+				"size":  cty.UnknownVal(cty.Number),
+				"tags":  cty.ObjectVal(map[string]cty.Value{"os": cty.StringVal("linux")}),
+				"disks": cty.NullVal(cty.DynamicPseudoType),
+			}),
+		},
+		{
+			Name: "drift",
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("ubuntu"),
+				"id":   cty.StringVal("ami-1234"),
+				"tags": cty.ObjectVal(map[string]cty.Value{"count": cty.NumberIntVal(1)}),
+				"disks": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"size": cty.StringVal("10G")}),
+				}),
+			}),
+			Err: `the datasource output does not match its OutputSpec:
+  ~ disks[0].size: expected number, got string
+  + id: string not declared by the OutputSpec
+  - size: missing, expected number
+  ~ tags.count: expected string, got number`,
+		},
+		{
+			Name:  "not an object",
+			Value: cty.StringVal("ubuntu"),
+			Err: `the datasource output does not match its OutputSpec:
+  ~ (output): expected object, got string`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := ValidateOutput(spec, tt.Value)
+			if tt.Err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.Error() != tt.Err {
+				t.Fatalf("bad error:\n%s\nexpected:\n%s", err, tt.Err)
+			}
+		})
+	}
+}
+
+func TestTestDatasourceOutput(t *testing.T) {
+	d := &packersdk.MockDatasource{Foo: "baz"}
+	value := TestDatasourceOutput(t, d)
+	if got := value.GetAttr("foo").AsString(); got != "baz" {
+		t.Fatalf("bad foo: %s", got)
+	}
+	if !d.OutputSpecCalled {
+		t.Fatal("the OutputSpec should be checked")
+	}
+}
@@ -4,8 +4,10 @@
 package hcl2helper
 
 import (
+	"encoding/json"
 	"fmt"
-	"time"
+	"reflect"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
@@ -39,6 +41,13 @@ func HCL2ValueFromConfigValue(v interface{}) cty.Value {
 		return cty.NumberIntVal(int64(tv))
 	case float64:
 		return cty.NumberFloatVal(tv)
+	case json.Number:
+		// From the streaming decoder of the json package, parsed without
+		// loss of precision.
+		if value, err := cty.ParseNumberVal(tv.String()); err == nil {
+			return value
+		}
+		panic(fmt.Errorf("can't convert %#v to cty.Value", v))
 	case []interface{}:
 		vals := make([]cty.Value, len(tv))
 		for i, ev := range tv {
@@ -58,6 +67,9 @@ func HCL2ValueFromConfigValue(v interface{}) cty.Value {
 		}
 		return cty.ObjectVal(vals)
 	default:
+		if value, ok := adaptedValue(v, cty.DynamicPseudoType); ok {
+			return value
+		}
 		// HCL/HIL should never generate anything that isn't caught by
 		// the above, so if we get here something has gone very wrong.
 		panic(fmt.Errorf("can't convert %#v to cty.Value", v))
@@ -71,6 +83,11 @@ func HCL2ValueFromConfig(conf interface{}, configSpec map[string]hcldec.Spec) ct
 	if err := mapstructure.Decode(conf, &c); err != nil {
 		panic(fmt.Errorf("can't convert %#v to cty.Value", conf))
 	}
+	// mapstructure turns the struct fields into maps, get back the ones to
+	// convert with an adapter
+	for k, v := range adaptedStructFields(conf) {
+		c[k] = v
+	}
 
 	// Use the HCL2Spec to know the expected cty.Type for an attribute
 	resp := map[string]cty.Value{}
@@ -117,9 +134,36 @@ If this doesn't fix your problem, this is likely a Packer bug, please consider o
 				// At this point this is an empty list so we want it to go to gocty.ToCtyValue(v, impT)
 				// and make it a NullVal
 			}
+		case *hcldec.BlockMapSpec:
+			// This is a map of objects, labelled by their key
+			if hcldec.ImpliedType(st.Nested).IsObjectType() {
+				res := map[string]cty.Value{}
+				rv := reflect.ValueOf(v)
+				if rv.Kind() != reflect.Map {
+					panic(fmt.Errorf("can't convert %#v to cty.Value", conf))
+				}
+				types := hcldec.ChildBlockTypes(spec)
+				for iter := rv.MapRange(); iter.Next(); {
+					key := fmt.Sprint(iter.Key().Interface())
+					res[key] = HCL2ValueFromConfig(iter.Value().Interface(), types[k].(hcldec.ObjectSpec))
+				}
+				if len(res) != 0 {
+					resp[k] = cty.MapVal(res)
+					continue
+				}
+			}
 		}
 
 		impT := hcldec.ImpliedType(spec)
+		if impT == cty.DynamicPseudoType {
+			// This is an interface field, which can be set to any value
+			resp[k] = HCL2ValueFromConfigValue(v)
+			continue
+		}
+		if value, ok := adaptedValue(v, impT); ok {
+			resp[k] = value
+			continue
+		}
 		if value, err := gocty.ToCtyValue(v, impT); err == nil {
 			resp[k] = value
 			continue
@@ -130,13 +174,6 @@ If this doesn't fix your problem, this is likely a Packer bug, please consider o
 		case config.Trilean:
 			resp[k] = cty.BoolVal(tv.True())
 			continue
-		case time.Duration:
-			if tv.Microseconds() == int64(0) {
-				resp[k] = cty.NumberIntVal(int64(0))
-				continue
-			}
-			resp[k] = cty.NumberIntVal(v.(time.Duration).Milliseconds())
-			continue
 		}
 
 		// This is a nested object and we should recursively go through the same process
@@ -152,3 +189,59 @@ If this doesn't fix your problem, this is likely a Packer bug, please consider o
 	// This is decoding structs so it will always be an cty.ObjectVal at the end
 	return cty.ObjectVal(resp)
 }
+
+// adaptedValue converts v with the adapter registered for its type with
+// config.RegisterTypeAdapter, if any. A nil pointer is a null value of t.
+func adaptedValue(v interface{}, t cty.Type) (cty.Value, bool) {
+	a, ok := config.TypeAdapterFor(reflect.TypeOf(v))
+	if !ok {
+		return cty.NilVal, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type() != a.Type && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return cty.NullVal(t), true
+		}
+		v = rv.Elem().Interface()
+	}
+	value, err := a.Encode(v)
+	if err != nil {
+		panic(fmt.Errorf("can't convert %#v to cty.Value: %s", v, err))
+	}
+	return value, true
+}
+
+// adaptedStructFields returns the fields of conf, a struct, which are structs
+// with an adapter, by their mapstructure name, following the squashed fields.
+func adaptedStructFields(conf interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	rv := reflect.Indirect(reflect.ValueOf(conf))
+	if rv.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if opts == "squash" {
+			for k, v := range adaptedStructFields(fv.Interface()) {
+				fields[k] = v
+			}
+			continue
+		}
+		if _, ok := config.TypeAdapterFor(f.Type); !ok || fv.Kind() != reflect.Struct {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = fv.Interface()
+	}
+	return fields
+}
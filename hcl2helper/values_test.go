@@ -4,11 +4,16 @@
 package hcl2helper
 
 import (
+	"encoding/json"
+	"net"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -39,6 +44,11 @@ func TestHCL2ValueFromConfigValue(t *testing.T) {
 			Input: float64(12.5),
 			Want:  cty.NumberFloatVal(12.5),
 		},
+		{
+			Name:  "json.Number",
+			Input: json.Number("18446744073709551615"),
+			Want:  cty.MustParseNumberVal("18446744073709551615"),
+		},
 		{
 			Name:  "string",
 			Input: "hello world",
@@ -169,7 +179,10 @@ func TestHCL2ValueFromConfig(t *testing.T) {
 					}))),
 					"data_source": cty.StringVal(""),
 				}),
-				"nested_slice": cty.NullVal(hcldec.ImpliedType(new(MockConfig).FlatMapstructure().HCL2Spec()["nested_slice"])),
+				"nested_slice":     cty.NullVal(hcldec.ImpliedType(new(MockConfig).FlatMapstructure().HCL2Spec()["nested_slice"])),
+				"nested_ptr_slice": cty.NullVal(hcldec.ImpliedType(new(MockConfig).FlatMapstructure().HCL2Spec()["nested_ptr_slice"])),
+				"nested_map":       cty.NullVal(hcldec.ImpliedType(new(MockConfig).FlatMapstructure().HCL2Spec()["nested_map"])),
+				"extra":            cty.NullVal(cty.DynamicPseudoType),
 			}),
 		},
 		{
@@ -232,6 +245,14 @@ func TestHCL2ValueFromConfig(t *testing.T) {
 						Datasource: "datasource",
 					},
 				},
+				NestedPtrSlice: []*MockTag{{
+					Key:   "a",
+					Value: "b",
+				}},
+				NestedMap: map[string]NestedMockConfig{
+					"first": {String: "string"},
+				},
+				Extra: map[string]interface{}{"a": "b"},
 			},
 			Spec: new(MockConfig).FlatMapstructure().HCL2Spec(),
 			Want: cty.ObjectVal(map[string]cty.Value{
@@ -308,6 +329,30 @@ func TestHCL2ValueFromConfig(t *testing.T) {
 						"data_source": cty.StringVal("datasource"),
 					}),
 				}),
+				"nested_ptr_slice": cty.ListVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{
+					"key":   cty.StringVal("a"),
+					"value": cty.StringVal("b"),
+				})}),
+				"nested_map": cty.MapVal(map[string]cty.Value{
+					"first": cty.ObjectVal(map[string]cty.Value{
+						"string":                  cty.StringVal("string"),
+						"int":                     cty.NumberIntVal(int64(0)),
+						"int64":                   cty.NumberIntVal(int64(0)),
+						"bool":                    cty.False,
+						"trilean":                 cty.False,
+						"duration":                cty.NumberIntVal(int64(0)),
+						"map_string_string":       cty.NullVal(cty.Map(cty.String)),
+						"slice_string":            cty.NullVal(cty.List(cty.String)),
+						"slice_slice_string":      cty.NullVal(cty.List(cty.List(cty.String))),
+						"named_map_string_string": cty.NullVal(cty.Map(cty.String)),
+						"named_string":            cty.StringVal(""),
+						"tag": cty.NullVal(cty.List(cty.Object(map[string]cty.Type{
+							"key": cty.String, "value": cty.String,
+						}))),
+						"data_source": cty.StringVal(""),
+					}),
+				}),
+				"extra": cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("b")}),
 			}),
 		},
 	}
@@ -321,3 +366,71 @@ func TestHCL2ValueFromConfig(t *testing.T) {
 		})
 	}
 }
+
+type adaptedConfig struct {
+	IP    net.IP   `mapstructure:"ip"`
+	URL   url.URL  `mapstructure:"url"`
+	Proxy *url.URL `mapstructure:"proxy"`
+}
+
+func TestHCL2ValueFromConfig_typeAdapters(t *testing.T) {
+	spec := map[string]hcldec.Spec{
+		"ip":    &hcldec.AttrSpec{Name: "ip", Type: cty.String},
+		"url":   &hcldec.AttrSpec{Name: "url", Type: cty.String},
+		"proxy": &hcldec.AttrSpec{Name: "proxy", Type: cty.String},
+	}
+	got := HCL2ValueFromConfig(adaptedConfig{
+		IP:  net.ParseIP("10.0.0.1"),
+		URL: url.URL{Scheme: "https", Host: "example.com", Path: "/iso"},
+	}, spec)
+	want := cty.ObjectVal(map[string]cty.Value{
+		"ip":    cty.StringVal("10.0.0.1"),
+		"url":   cty.StringVal("https://example.com/iso"),
+		"proxy": cty.NullVal(cty.String),
+	})
+	if !got.RawEquals(want) {
+		t.Fatalf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestDecode_nestedBlocks(t *testing.T) {
+	src := `
+nested_ptr_slice {
+  key   = "a"
+  value = "b"
+}
+nested_map "first" {
+  string = "one"
+}
+nested_map "second" {
+  int = 2
+}
+extra = {
+  tags = ["a", "b"]
+}
+`
+	file, diags := hclsyntax.ParseConfig([]byte(src), "config.pkr.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("parse: %s", diags)
+	}
+	spec := hcldec.ObjectSpec(new(MockConfig).FlatMapstructure().HCL2Spec())
+	val, diags := hcldec.Decode(file.Body, spec, nil)
+	if diags.HasErrors() {
+		t.Fatalf("decode: %s", diags)
+	}
+
+	var c MockConfig
+	if err := config.Decode(&c, &config.DecodeOpts{}, val); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(c.NestedPtrSlice) != 1 || *c.NestedPtrSlice[0] != (MockTag{Key: "a", Value: "b"}) {
+		t.Fatalf("unexpected nested_ptr_slice %#v", c.NestedPtrSlice)
+	}
+	if len(c.NestedMap) != 2 || c.NestedMap["first"].String != "one" || c.NestedMap["second"].Int != 2 {
+		t.Fatalf("unexpected nested_map %#v", c.NestedMap)
+	}
+	extra := map[string]interface{}{"tags": []interface{}{"a", "b"}}
+	if !reflect.DeepEqual(c.Extra, extra) {
+		t.Fatalf("unexpected extra %#v", c.Extra)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package json
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalCanonical(t *testing.T) {
+	type artifact struct {
+		Name  string                 `json:"name"`
+		Files []string               `json:"files"`
+		Size  uint64                 `json:"size"`
+		State map[string]interface{} `json:"state"`
+	}
+
+	b, err := MarshalCanonical(artifact{
+		Name:  "<image>",
+		Files: []string{"b", "a"},
+		Size:  18446744073709551615,
+		State: map[string]interface{}{"z": nil, "a": true, "m": []interface{}{1.5, "x"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"files":["b","a"],"name":"<image>","size":18446744073709551615,"state":{"a":true,"m":[1.5,"x"],"z":null}}`
+	if string(b) != expected {
+		t.Fatalf("bad:\n%s\nexpected:\n%s", b, expected)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	a, err := Canonicalize([]byte("{\n  \"b\": {\"d\": 1, \"c\": 2},\n  \"a\": []\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Canonicalize([]byte(`{"a":[],"b":{"c":2,"d":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Fatalf("encodings differ:\n%s\n%s", a, b)
+	}
+}
+
+func TestCanonicalize_invalid(t *testing.T) {
+	cases := map[string]string{
+		"syntax":   "{\n  \"a\": 1,\n  \"b\" 2\n}",
+		"trailing": `{"a":1}}`,
+		"multiple": `{"a":1} {"b":2}`,
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Canonicalize([]byte(data))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if name == "syntax" && !strings.Contains(err.Error(), "line 3") {
+				t.Fatalf("expected the line of the error: %s", err)
+			}
+		})
+	}
+}
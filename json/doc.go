@@ -0,0 +1,10 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package json complements encoding/json for Packer plugins: Unmarshal reports
+the line of syntax errors, MarshalCanonical encodes values with sorted keys,
+for reproducible manifests and checksums, and DecodeObject and DecodeArray
+stream large payloads, like the state of an artifact, one member at a time.
+*/
+package json
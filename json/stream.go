@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// NewDecoder returns a decoder reading the JSON values of r, with the
+// numbers decoded as json.Number, so that large integers keep their
+// precision.
+func NewDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// DecodeObject streams the members of the JSON object read from r, like the
+// state of an artifact, without loading all of it in memory. fn is called
+// with the key of each member, in order, and dec positioned at its value,
+// that fn decodes with dec.Decode; a value fn doesn't decode is skipped.
+//
+// Iteration stops at the first error of fn, which is returned.
+func DecodeObject(r io.Reader, fn func(key string, dec *json.Decoder) error) error {
+	dec := NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return offsetError(err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("Error at offset %d: expected an object key, got %v", dec.InputOffset(), tok)
+		}
+		if err := decodeValue(dec, func() error { return fn(key, dec) }); err != nil {
+			return err
+		}
+	}
+	return expectEnd(dec, '}')
+}
+
+// DecodeArray streams the elements of the JSON array read from r, like the
+// files of a large manifest, without loading all of it in memory. fn is
+// called with the index of each element and dec positioned at it, that fn
+// decodes with dec.Decode; an element fn doesn't decode is skipped.
+//
+// Iteration stops at the first error of fn, which is returned.
+func DecodeArray(r io.Reader, fn func(i int, dec *json.Decoder) error) error {
+	dec := NewDecoder(r)
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for i := 0; dec.More(); i++ {
+		i := i
+		if err := decodeValue(dec, func() error { return fn(i, dec) }); err != nil {
+			return err
+		}
+	}
+	return expectEnd(dec, ']')
+}
+
+// decodeValue calls fn, that decodes the next value of dec, and skips the
+// value if fn didn't.
+func decodeValue(dec *json.Decoder, fn func() error) error {
+	offset := dec.InputOffset()
+	if err := fn(); err != nil {
+		return err
+	}
+	if dec.InputOffset() != offset {
+		return nil
+	}
+	var skip json.RawMessage
+	if err := dec.Decode(&skip); err != nil {
+		return offsetError(err)
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return offsetError(err)
+	}
+	if tok != delim {
+		return fmt.Errorf("Error at offset %d: expected %q, got %v", dec.InputOffset(), delim, tok)
+	}
+	return nil
+}
+
+// expectEnd reads the delim closing the streamed value, which must be the
+// last one of the input.
+func expectEnd(dec *json.Decoder, delim json.Delim) error {
+	if err := expectDelim(dec, delim); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("Error at offset %d: data after the top-level value", dec.InputOffset())
+	}
+	return nil
+}
+
+// offsetError returns the syntax errors of a stream with their offset, the
+// lines of a stream being unknown, and the other errors as is.
+func offsetError(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		return fmt.Errorf("Error at offset %d: %s", syntaxErr.Offset, syntaxErr)
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package json
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeObject(t *testing.T) {
+	r := strings.NewReader(`{"id": "ami-1", "files": ["a", "b"], "size": 18446744073709551615, "skipped": {"x": [1]}}`)
+
+	var keys []string
+	var id string
+	var size json.Number
+	err := DecodeObject(r, func(key string, dec *json.Decoder) error {
+		keys = append(keys, key)
+		switch key {
+		case "id":
+			return dec.Decode(&id)
+		case "size":
+			return dec.Decode(&size)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"id", "files", "size", "skipped"}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if id != "ami-1" || size.String() != "18446744073709551615" {
+		t.Fatalf("bad values: %q %q", id, size)
+	}
+}
+
+func TestDecodeObject_error(t *testing.T) {
+	stop := errors.New("stop")
+	err := DecodeObject(strings.NewReader(`{"a": 1, "b": 2}`), func(key string, dec *json.Decoder) error {
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	cases := map[string]string{
+		"array":     `[1]`,
+		"syntax":    `{"a" 1}`,
+		"truncated": `{"a": 1`,
+		"trailing":  `{"a": 1} 2`,
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := DecodeObject(strings.NewReader(data), func(string, *json.Decoder) error { return nil })
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeArray(t *testing.T) {
+	r := strings.NewReader(`[{"name": "a"}, {"name": "b"}, 3]`)
+
+	var names []string
+	err := DecodeArray(r, func(i int, dec *json.Decoder) error {
+		if i == 2 {
+			return nil
+		}
+		var file struct{ Name string }
+		if err := dec.Decode(&file); err != nil {
+			return err
+		}
+		names = append(names, file.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad names: %v", names)
+	}
+}
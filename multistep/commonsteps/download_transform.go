@@ -0,0 +1,233 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter/v2"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// DownloadTransform transforms the file downloaded by StepDownload, like
+// decompressing it or converting its format. Its result is cached, under a
+// key derived from the one of its input and its Key.
+type DownloadTransform interface {
+	// Key identifies the transformation and its options, like "xz" or
+	// "convert:qcow2:raw", so it must change with any option changing its
+	// result.
+	Key() string
+	// Extension is the extension of the result, like "raw", or empty to
+	// keep the one of the input.
+	Extension() string
+	// Transform writes the result of the transformation of the file src to
+	// the file dst.
+	Transform(ctx context.Context, src, dst string) error
+}
+
+// DecompressTransform decompresses the file, with the go-getter
+// decompressor of Format, like "xz", "zst", "gz" or "bz2". go-getter already
+// decompresses the files whose URL has the extension of their format, so
+// it's for the ones whose URL doesn't.
+type DecompressTransform struct {
+	Format string
+}
+
+func (t *DecompressTransform) Key() string { return "decompress:" + t.Format }
+
+func (t *DecompressTransform) Extension() string { return "" }
+
+func (t *DecompressTransform) Transform(ctx context.Context, src, dst string) error {
+	d, ok := getter.Decompressors[t.Format]
+	if !ok {
+		return fmt.Errorf("unknown compression format %q", t.Format)
+	}
+	return d.Decompress(dst, src, false, 0)
+}
+
+// ExtractTransform extracts the file matching Pattern, a path.Match pattern
+// like "*.vmdk", from an archive of Format: "zip", "ova", or a tar format of
+// go-getter, like "tar" or "tar.gz".
+type ExtractTransform struct {
+	Format  string
+	Pattern string
+}
+
+func (t *ExtractTransform) Key() string { return "extract:" + t.Format + ":" + t.Pattern }
+
+func (t *ExtractTransform) Extension() string {
+	if ext := path.Ext(t.Pattern); !strings.ContainsAny(ext, "*?[") {
+		return strings.TrimPrefix(ext, ".")
+	}
+	return ""
+}
+
+func (t *ExtractTransform) Transform(ctx context.Context, src, dst string) error {
+	format := t.Format
+	if format == "ova" {
+		// An OVA is a tar archive.
+		format = "tar"
+	}
+	d, ok := getter.Decompressors[format]
+	if !ok {
+		return fmt.Errorf("unknown archive format %q", t.Format)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(dst), "extract")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := d.Decompress(dir, src, true, 0); err != nil {
+		return err
+	}
+
+	var matches []string
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if ok, _ := path.Match(t.Pattern, rel); ok {
+			matches = append(matches, p)
+		} else if ok, _ := path.Match(t.Pattern, path.Base(rel)); ok {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no file of the archive matches %q", t.Pattern)
+	case 1:
+		return os.Rename(matches[0], dst)
+	default:
+		return fmt.Errorf("%d files of the archive match %q, expected one", len(matches), t.Pattern)
+	}
+}
+
+// ImageConverter converts disk images between formats, like qcow2 to raw.
+type ImageConverter interface {
+	Convert(ctx context.Context, src, srcFormat, dst, dstFormat string) error
+}
+
+// QemuImgConverter converts disk images with qemu-img.
+type QemuImgConverter struct {
+	// Path is the path of qemu-img, looked up in the PATH by default.
+	Path string
+}
+
+func (c *QemuImgConverter) Convert(ctx context.Context, src, srcFormat, dst, dstFormat string) error {
+	qemuImg := c.Path
+	if qemuImg == "" {
+		qemuImg = "qemu-img"
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, qemuImg, "convert", "-f", srcFormat, "-O", dstFormat, src, dst)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error converting %s from %s to %s: %s: %s", src, srcFormat, dstFormat, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ConvertTransform converts a disk image from the format From to the
+// format To, like "qcow2" to "raw", with Converter, a QemuImgConverter by
+// default.
+type ConvertTransform struct {
+	From      string
+	To        string
+	Converter ImageConverter
+}
+
+func (t *ConvertTransform) Key() string { return "convert:" + t.From + ":" + t.To }
+
+func (t *ConvertTransform) Extension() string { return t.To }
+
+func (t *ConvertTransform) Transform(ctx context.Context, src, dst string) error {
+	converter := t.Converter
+	if converter == nil {
+		converter = &QemuImgConverter{}
+	}
+	return converter.Convert(ctx, src, t.From, dst, t.To)
+}
+
+// transform applies the Transforms of s to the downloaded file src, and
+// returns the path of the result. The results are cached in the cache
+// directory when the checksum of the download is known.
+func (s *StepDownload) transform(ctx context.Context, ui packersdk.Ui, src string) (string, error) {
+	cached := s.Checksum != "" && s.Checksum != "none"
+	key := s.Checksum
+	if !cached {
+		key = src
+	}
+	ext := strings.TrimPrefix(filepath.Ext(src), ".")
+
+	for _, t := range s.Transforms {
+		key += "\n" + t.Key()
+		if e := t.Extension(); e != "" {
+			ext = e
+		}
+		sum := sha1.Sum([]byte(key))
+		name := hex.EncodeToString(sum[:])
+		if ext != "" {
+			name += "." + ext
+		}
+		dst, err := packersdk.CachePath(name)
+		if err != nil {
+			return "", fmt.Errorf("CachePath: %s", err)
+		}
+		if err := s.transformOnce(ctx, ui, t, src, dst, cached); err != nil {
+			return "", err
+		}
+		src = dst
+	}
+	return src, nil
+}
+
+// transformOnce writes the result of t of src at dst, unless cached and
+// already there.
+func (s *StepDownload) transformOnce(ctx context.Context, ui packersdk.Ui, t DownloadTransform, src, dst string, cached bool) error {
+	lock := filelock.New(dst + ".lock")
+	if err := lock.Lock(); err != nil {
+		return fmt.Errorf("Error locking %s: %s", dst, err)
+	}
+	defer lock.Unlock()
+
+	if _, err := os.Stat(dst); err == nil && cached {
+		ui.Say(fmt.Sprintf("Using the cached %s of %s: %s", t.Key(), s.Description, dst))
+		return nil
+	}
+
+	ui.Say(fmt.Sprintf("Applying %s to %s...", t.Key(), s.Description))
+	tmp := dst + ".tmp"
+	os.Remove(tmp)
+	if err := t.Transform(ctx, src, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error applying %s to %s: %s", t.Key(), src, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	log.Printf("%s of %s => %s", t.Key(), src, dst)
+	return nil
+}
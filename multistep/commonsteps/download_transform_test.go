@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type countingConverter struct {
+	calls int
+}
+
+func (c *countingConverter) Convert(ctx context.Context, src, srcFormat, dst, dstFormat string) error {
+	c.calls++
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, []byte(dstFormat+":"+string(b)), 0644)
+}
+
+func writeGzip(t *testing.T, path, content string) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(content))
+	w.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	w.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStepDownload_Transforms(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+	// Without the extension of its format, go-getter doesn't decompress it.
+	src := filepath.Join(t.TempDir(), "disk")
+	writeGzip(t, src, "image")
+
+	converter := &countingConverter{}
+	run := func() string {
+		step := &StepDownload{
+			Checksum:    "none",
+			Description: "disk image",
+			ResultKey:   "disk_path",
+			Url:         []string{src},
+			Transforms: []DownloadTransform{
+				&DecompressTransform{Format: "gz"},
+				&ConvertTransform{From: "qcow2", To: "raw", Converter: converter},
+			},
+		}
+		state := testState(t)
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v, error: %v", action, state.Get("error"))
+		}
+		return state.Get("disk_path").(string)
+	}
+
+	path := run()
+	if !strings.HasSuffix(path, ".raw") {
+		t.Fatalf("the result %q should have the extension of the conversion", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "raw:image" {
+		t.Fatalf("bad result: %q", b)
+	}
+
+	// Without checksum, the transforms are applied again.
+	run()
+	if converter.calls != 2 {
+		t.Fatalf("the conversion should have run twice without checksum, ran %d times", converter.calls)
+	}
+}
+
+func TestStepDownload_transform_cached(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+	src := filepath.Join(t.TempDir(), "disk.qcow2")
+	if err := os.WriteFile(src, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)}
+
+	converter := &countingConverter{}
+	step := &StepDownload{
+		Checksum:   "sha256:1234",
+		Transforms: []DownloadTransform{&ConvertTransform{From: "qcow2", To: "raw", Converter: converter}},
+	}
+	first, err := step.transform(context.Background(), ui, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := step.transform(context.Background(), ui, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("the cached result %q should be reused, got %q", first, second)
+	}
+	if converter.calls != 1 {
+		t.Fatalf("the conversion should have run once, ran %d times", converter.calls)
+	}
+
+	step.Transforms = []DownloadTransform{&ConvertTransform{From: "qcow2", To: "vmdk", Converter: converter}}
+	third, err := step.transform(context.Background(), ui, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || converter.calls != 2 {
+		t.Fatalf("another conversion should get another result, got %q after %d calls", third, converter.calls)
+	}
+}
+
+func TestExtractTransform(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "appliance.zip")
+	writeZip(t, src, map[string]string{
+		"appliance.ovf":      "ovf",
+		"disks/disk1.vmdk":   "disk",
+		"docs/disk.vmdk.txt": "doc",
+	})
+
+	tr := &ExtractTransform{Format: "zip", Pattern: "*.vmdk"}
+	if ext := tr.Extension(); ext != "vmdk" {
+		t.Fatalf("bad extension: %q", ext)
+	}
+	dst := filepath.Join(dir, "disk.vmdk")
+	if err := tr.Transform(context.Background(), src, dst); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "disk" {
+		t.Fatalf("bad extracted file: %q", b)
+	}
+
+	tr = &ExtractTransform{Format: "zip", Pattern: "*"}
+	if err := tr.Transform(context.Background(), src, filepath.Join(dir, "any")); err == nil {
+		t.Fatal("extracting one of several matching files should fail")
+	}
+	tr = &ExtractTransform{Format: "zip", Pattern: "*.iso"}
+	if err := tr.Transform(context.Background(), src, filepath.Join(dir, "none")); err == nil {
+		t.Fatal("extracting no file should fail")
+	}
+}
+
+func TestDecompressTransform_unknownFormat(t *testing.T) {
+	tr := &DecompressTransform{Format: "rar"}
+	if err := tr.Transform(context.Background(), "src", "dst"); err == nil {
+		t.Fatal("an unknown format should fail")
+	}
+}
+
+func TestStepDownload_transform_lockError(t *testing.T) {
+	// The lock can't be created in a directory that is a file.
+	cache := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(cache, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PACKER_CACHE_DIR", cache)
+	ui := &packersdk.BasicUi{Reader: new(bytes.Buffer), Writer: new(bytes.Buffer)}
+
+	converter := &countingConverter{}
+	step := &StepDownload{
+		Checksum:   "sha256:1234",
+		Transforms: []DownloadTransform{&ConvertTransform{From: "qcow2", To: "raw", Converter: converter}},
+	}
+	_, err := step.transform(context.Background(), ui, "disk.qcow2")
+	if err == nil {
+		t.Fatal("failing to lock the result should fail")
+	} else if !strings.Contains(err.Error(), "Error locking") {
+		t.Fatalf("expected a lock error, got %s", err)
+	}
+	if converter.calls != 0 {
+		t.Fatalf("the conversion shouldn't run without the lock, ran %d times", converter.calls)
+	}
+}
@@ -8,6 +8,7 @@ package commonsteps
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -19,9 +20,12 @@ import (
 // removable media. By default, no floppy will be attached. All files listed in
 // this setting get placed into the root directory of the floppy and the floppy
 // is attached as the first floppy device. The summary size of the listed files
-// must not exceed 1.44 MB. The supported ways to move large files into the OS
-// are using `http_directory` or [the file
-// provisioner](/packer/docs/provisioners/file).
+// must not exceed the size of the floppy, 1.44 MB unless `floppy_size` says
+// otherwise. The supported ways to move large files into the OS are using
+// `http_directory` or [the file provisioner](/packer/docs/provisioners/file).
+//
+// File and directory names longer than the 8.3 format are written with VFAT
+// long filename entries, and lowercase 8.3 names keep their case.
 type FloppyConfig struct {
 	// A list of files to place onto a floppy disk that is attached when the VM
 	// is booted. Currently, no support exists for creating sub-directories on
@@ -54,6 +58,22 @@ type FloppyConfig struct {
 	// ```
 	FloppyContent map[string]string `mapstructure:"floppy_content"`
 	FloppyLabel   string            `mapstructure:"floppy_label"`
+	// The size of the floppy disk image. Either one of the standard
+	// `1.44MB` or `2.88MB` formats, whose drive geometry is set accordingly,
+	// or a custom size in `KB` or `MB`, e.g. `8MB`. Images of up to 16MB are
+	// formatted as FAT12, larger ones, of up to 2048MB, as FAT16. Defaults to
+	// `1.44MB`.
+	FloppySize string `mapstructure:"floppy_size"`
+	// A list of glob patterns. When set, only the files found in directories
+	// listed in `floppy_files` or `floppy_dirs` that match at least one of
+	// these patterns are added to the floppy. Patterns are matched against
+	// both the file name and its path relative to the listed directory, e.g.
+	// `*.inf` or `drivers/*/*.sys`.
+	FloppyInclude []string `mapstructure:"floppy_include"`
+	// A list of glob patterns, using the same syntax as `floppy_include`, for
+	// files and directories to leave out of the floppy. Exclusions take
+	// precedence over `floppy_include`.
+	FloppyExclude []string `mapstructure:"floppy_exclude"`
 }
 
 func (c *FloppyConfig) Prepare(ctx *interpolate.Context) []error {
@@ -90,5 +110,17 @@ func (c *FloppyConfig) Prepare(ctx *interpolate.Context) []error {
 		}
 	}
 
+	if c.FloppySize != "" {
+		if _, err := parseFloppySize(c.FloppySize); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, pattern := range append(c.FloppyInclude, c.FloppyExclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("Bad Floppy disk pattern '%s': %s", pattern, err))
+		}
+	}
+
 	return errs
 }
@@ -71,3 +71,31 @@ func TestMultiErrorFloppyFiles(t *testing.T) {
 		t.Fatalf("array with %v non existing floppy should return %v errors but it is returning %v", expectedErrors, expectedErrors, count)
 	}
 }
+
+func TestFloppySize(t *testing.T) {
+	valid := []string{"", "1.44MB", "2.88mb", "1440KB", "8MB", "64MB", "2048MB"}
+	for _, size := range valid {
+		c := FloppyConfig{FloppySize: size}
+		if errs := c.Prepare(nil); len(errs) != 0 {
+			t.Fatalf("floppy size %q should be valid: %v", size, errs)
+		}
+	}
+
+	invalid := []string{"1.44", "1.5MB", "100KB", "-1MB", "big", "2049MB", "4GB", "4096MB"}
+	for _, size := range invalid {
+		c := FloppyConfig{FloppySize: size}
+		if errs := c.Prepare(nil); len(errs) != 1 {
+			t.Fatalf("floppy size %q should return an error: %v", size, errs)
+		}
+	}
+}
+
+func TestFloppyPatterns(t *testing.T) {
+	c := FloppyConfig{
+		FloppyInclude: []string{"*.inf"},
+		FloppyExclude: []string{"[bad"},
+	}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("malformed pattern should return an error: %v", errs)
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/mitchellh/go-fs/fat"
+)
+
+const (
+	floppySectorSize = 512
+
+	// The biggest image go-fs can format as FAT12 with 4 KiB clusters.
+	floppyMaxFAT12Size = 4084 * 8 * floppySectorSize
+	// The biggest image go-fs can format at all, as FAT16.
+	floppyMaxFAT16Size = 4194304 * floppySectorSize
+)
+
+// floppyFormat describes the size and drive geometry of a floppy image.
+type floppyFormat struct {
+	Size            int64
+	Heads           uint16
+	SectorsPerTrack uint16
+}
+
+// Standard floppy formats. go-fs already uses the right geometry for 1.44MB
+// images, the others are patched in after formatting so that BIOS and
+// installers addressing the drive through CHS see a consistent layout.
+var floppyFormats = map[string]floppyFormat{
+	"1.44MB": {Size: 1440 * 1024, Heads: 2, SectorsPerTrack: 18},
+	"2.88MB": {Size: 2880 * 1024, Heads: 2, SectorsPerTrack: 36},
+}
+
+// parseFloppySize returns the format for one of the standard floppy sizes or
+// a custom size expressed in KB or MB. Custom sizes keep the geometry go-fs
+// computes for them.
+func parseFloppySize(size string) (floppyFormat, error) {
+	if size == "" {
+		return floppyFormats["1.44MB"], nil
+	}
+
+	normalized := strings.ToUpper(strings.TrimSpace(size))
+	if f, ok := floppyFormats[normalized]; ok {
+		return f, nil
+	}
+
+	var unit int64
+	var number string
+	switch {
+	case strings.HasSuffix(normalized, "KB"):
+		unit, number = 1024, strings.TrimSuffix(normalized, "KB")
+	case strings.HasSuffix(normalized, "MB"):
+		unit, number = 1024*1024, strings.TrimSuffix(normalized, "MB")
+	default:
+		return floppyFormat{}, fmt.Errorf("Bad floppy size '%s': expected 1.44MB, 2.88MB or a size in KB or MB", size)
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || n <= 0 {
+		return floppyFormat{}, fmt.Errorf("Bad floppy size '%s': expected 1.44MB, 2.88MB or a size in KB or MB", size)
+	}
+	bytes := n * unit
+	if bytes%floppySectorSize != 0 {
+		return floppyFormat{}, fmt.Errorf("Bad floppy size '%s': must be a multiple of %d bytes", size, floppySectorSize)
+	}
+	if bytes < 160*1024 {
+		return floppyFormat{}, fmt.Errorf("Bad floppy size '%s': must be at least 160KB", size)
+	}
+	if bytes > floppyMaxFAT16Size {
+		return floppyFormat{}, fmt.Errorf("Bad floppy size '%s': must be at most %dMB", size, floppyMaxFAT16Size/(1024*1024))
+	}
+	for _, f := range floppyFormats {
+		if f.Size == bytes {
+			return f, nil
+		}
+	}
+	return floppyFormat{Size: bytes}, nil
+}
+
+// FATType returns the FAT variant to format an image of this size with.
+func (f floppyFormat) FATType() fat.FATType {
+	if f.Size > floppyMaxFAT12Size {
+		return fat.FAT16
+	}
+	return fat.FAT12
+}
+
+// writeGeometry overwrites the drive geometry fields of the boot sector. The
+// fields are informational only, the layout of the filesystem is untouched.
+func (f floppyFormat) writeGeometry(w io.WriterAt) error {
+	if f.Heads == 0 || f.SectorsPerTrack == 0 {
+		return nil
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint16(b[0:2], f.SectorsPerTrack)
+	binary.LittleEndian.PutUint16(b[2:4], f.Heads)
+	_, err := w.WriteAt(b[:], 24)
+	return err
+}
+
+// validateFloppyName returns an error if name cannot be stored as a VFAT long
+// filename by go-fs.
+func validateFloppyName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid floppy file name %q", name)
+	}
+	if len(utf16.Encode([]rune(name))) > 255 {
+		return fmt.Errorf("floppy file name %q is longer than 255 characters", name)
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") || strings.HasPrefix(name, " ") {
+		return fmt.Errorf("floppy file name %q cannot start with a space or end with a space or a dot", name)
+	}
+	ascii := true
+	for _, r := range name {
+		if r > 0x7f {
+			ascii = false
+		} else if r < 0x20 || strings.ContainsRune(`"*/:<>?\|`, r) {
+			return fmt.Errorf("floppy file name %q contains the invalid character %q", name, r)
+		}
+	}
+	if !ascii {
+		// go-fs splits long names on byte boundaries, which garbles
+		// multi-byte characters. They have always been accepted so only warn.
+		log.Printf("[WARN] floppy file name %q contains non-ASCII characters, it may be garbled on the floppy", name)
+	}
+	return nil
+}
+
+// shortNameCase returns the Windows NT lowercase flags for a name that fits
+// in a 8.3 directory entry. go-fs does not write a long filename entry for
+// those, so without the flags "ks.cfg" would show up as "KS.CFG". ok is false
+// when the case of the name cannot be represented with the flags.
+func shortNameCase(name string) (flags byte, ok bool) {
+	base, ext := name, ""
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		base, ext = name[:idx], name[idx+1:]
+	}
+
+	for i, part := range []string{base, ext} {
+		switch {
+		case part == strings.ToUpper(part):
+		case part == strings.ToLower(part):
+			flags |= 0x08 << i
+		default:
+			return 0, false
+		}
+	}
+	return flags, true
+}
+
+// floppyFilter decides which files found while walking a directory end up on
+// the floppy.
+type floppyFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Match reports whether rel, the slash-separated path of a file relative to
+// the walked directory, should be added.
+func (f floppyFilter) Match(rel string, isDir bool) bool {
+	if matchAny(f.Exclude, rel) {
+		return false
+	}
+	// Directories are always descended into so that includes can match the
+	// files inside of them.
+	if isDir || len(f.Include) == 0 {
+		return true
+	}
+	return matchAny(f.Include, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fatImage gives raw access to the directory entries of a FAT12 or FAT16
+// image formatted by go-fs.
+type fatImage struct {
+	rw interface {
+		io.ReaderAt
+		io.WriterAt
+	}
+	fatType fat.FATType
+
+	bytesPerCluster int64
+	fatOffset       int64
+	rootOffset      int64
+	rootSize        int64
+	dataOffset      int64
+}
+
+func newFatImage(rw interface {
+	io.ReaderAt
+	io.WriterAt
+}, fatType fat.FATType) (*fatImage, error) {
+	var bs [32]byte
+	if _, err := rw.ReadAt(bs[:], 0); err != nil {
+		return nil, err
+	}
+
+	bytesPerSector := int64(binary.LittleEndian.Uint16(bs[11:13]))
+	sectorsPerCluster := int64(bs[13])
+	reserved := int64(binary.LittleEndian.Uint16(bs[14:16]))
+	numFATs := int64(bs[16])
+	rootEntries := int64(binary.LittleEndian.Uint16(bs[17:19]))
+	sectorsPerFAT := int64(binary.LittleEndian.Uint16(bs[22:24]))
+
+	img := &fatImage{
+		rw:              rw,
+		fatType:         fatType,
+		bytesPerCluster: bytesPerSector * sectorsPerCluster,
+		fatOffset:       reserved * bytesPerSector,
+		rootOffset:      (reserved + numFATs*sectorsPerFAT) * bytesPerSector,
+		rootSize:        rootEntries * fat.DirectoryEntrySize,
+	}
+	img.dataOffset = img.rootOffset + (img.rootSize+bytesPerSector-1)/bytesPerSector*bytesPerSector
+	return img, nil
+}
+
+// dirRegions returns the offset of the byte ranges holding the entries of the
+// directory starting at cluster. Cluster 0 is the root directory.
+func (img *fatImage) dirRegions(cluster uint32) ([][2]int64, error) {
+	if cluster == 0 {
+		return [][2]int64{{img.rootOffset, img.rootSize}}, nil
+	}
+
+	var regions [][2]int64
+	for i := 0; cluster >= 2; i++ {
+		if i > 0xFFFF {
+			return nil, fmt.Errorf("cluster chain loop at cluster %d", cluster)
+		}
+		regions = append(regions, [2]int64{
+			img.dataOffset + int64(cluster-2)*img.bytesPerCluster,
+			img.bytesPerCluster,
+		})
+
+		var b [2]byte
+		var next uint32
+		switch img.fatType {
+		case fat.FAT12:
+			if _, err := img.rw.ReadAt(b[:], img.fatOffset+int64(cluster+cluster/2)); err != nil {
+				return nil, err
+			}
+			next = uint32(binary.LittleEndian.Uint16(b[:]))
+			if cluster%2 == 1 {
+				next >>= 4
+			}
+			next &= 0xFFF
+			if next >= 0xFF8 {
+				return regions, nil
+			}
+		default:
+			if _, err := img.rw.ReadAt(b[:], img.fatOffset+int64(cluster)*2); err != nil {
+				return nil, err
+			}
+			next = uint32(binary.LittleEndian.Uint16(b[:]))
+			if next >= 0xFFF8 {
+				return regions, nil
+			}
+		}
+		cluster = next
+	}
+	return regions, nil
+}
+
+// fixShortNameCase sets the lowercase flags on the 8.3 entries whose intended
+// name, looked up by its upper-cased path in names, is not all uppercase.
+func (img *fatImage) fixShortNameCase(names map[string]string) error {
+	return img.fixDirCase(0, "", names, 0)
+}
+
+func (img *fatImage) fixDirCase(cluster uint32, prefix string, names map[string]string, depth int) error {
+	if depth > 64 {
+		return fmt.Errorf("directory tree is too deep at %s", prefix)
+	}
+
+	regions, err := img.dirRegions(cluster)
+	if err != nil {
+		return err
+	}
+
+	var longName []uint16
+	for _, region := range regions {
+		for off := region[0]; off < region[0]+region[1]; off += fat.DirectoryEntrySize {
+			var e [fat.DirectoryEntrySize]byte
+			if _, err := img.rw.ReadAt(e[:], off); err != nil {
+				return err
+			}
+
+			switch {
+			case e[0] == 0:
+				return nil
+			case e[0] == 0xE5:
+				longName = nil
+				continue
+			case fat.DirectoryAttr(e[11]) == fat.AttrLongName:
+				part := make([]uint16, 0, 13)
+				for _, r := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+					for i := r[0]; i < r[1]; i += 2 {
+						part = append(part, binary.LittleEndian.Uint16(e[i:i+2]))
+					}
+				}
+				longName = append(part, longName...)
+				continue
+			case fat.DirectoryAttr(e[11])&fat.AttrVolumeId != 0:
+				longName = nil
+				continue
+			}
+
+			name := strings.TrimRight(string(e[0:8]), " ")
+			if ext := strings.TrimRight(string(e[8:11]), " "); ext != "" {
+				name += "." + ext
+			}
+			hasLongName := longName != nil
+			if hasLongName {
+				name = string(utf16.Decode(longName))
+				if idx := strings.IndexRune(name, 0); idx != -1 {
+					name = name[:idx]
+				}
+				longName = nil
+			}
+			if name == "." || name == ".." {
+				continue
+			}
+
+			fullPath := path.Join(prefix, name)
+			if intended, ok := names[strings.ToUpper(fullPath)]; ok && !hasLongName {
+				flags, ok := shortNameCase(intended)
+				if !ok {
+					// Windows would write a long name entry here, go-fs
+					// doesn't so the name stays uppercase.
+					log.Printf("Floppy file %s will be named %s", intended, fullPath)
+				}
+				if flags != 0 {
+					if _, err := img.rw.WriteAt([]byte{e[12] | flags}, off+12); err != nil {
+						return err
+					}
+				}
+			}
+
+			if fat.DirectoryAttr(e[11])&fat.AttrDirectory != 0 {
+				sub := uint32(binary.LittleEndian.Uint16(e[26:28]))
+				if err := img.fixDirCase(sub, fullPath, names, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
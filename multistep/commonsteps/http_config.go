@@ -6,6 +6,7 @@
 package commonsteps
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 
@@ -69,8 +70,32 @@ type HTTPConfig struct {
 	// `http_interface` can be specified.
 	HTTPInterface string `mapstructure:"http_interface" undocumented:"true"`
 	// Defines the HTTP Network protocol. Valid options are `tcp`, `tcp4`, `tcp6`,
-	// `unix`, and `unixpacket`. This value defaults to `tcp`.
+	// `unix`, and `unixpacket`. This value defaults to `tcp`. With `unix` and
+	// `unixpacket`, `http_bind_address` is the path of the socket.
 	HTTPNetworkProtocol string `mapstructure:"http_network_protocol"`
+	// The name of a socket passed to Packer by systemd socket activation, as
+	// set with `FileDescriptorName` in its `.socket` unit, for the HTTP
+	// server to listen to rather than opening a port, or `*` for the first
+	// socket passed. `http_port_min`, `http_port_max`, `http_bind_address`
+	// and `http_network_protocol` are then ignored.
+	HTTPSystemdSocket string `mapstructure:"http_systemd_socket"`
+	// Serve `http_directory` or `http_content` over HTTPS. Unless
+	// `http_tls_cert_file` and `http_tls_key_file` are set, a self-signed
+	// certificate valid for the addresses of the host is generated for the
+	// duration of the build, so the guest will have to skip certificate
+	// verification. Defaults to `false`.
+	HTTPTLS bool `mapstructure:"http_tls"`
+	// Path to a PEM encoded certificate to serve HTTPS with. Setting it, along
+	// with `http_tls_key_file`, implies `http_tls`.
+	HTTPTLSCertFile string `mapstructure:"http_tls_cert_file"`
+	// Path to the PEM encoded private key of `http_tls_cert_file`.
+	HTTPTLSKeyFile string `mapstructure:"http_tls_key_file"`
+	// When set, along with `http_password`, the HTTP server requires clients to
+	// authenticate with these basic auth credentials, e.g. by requesting
+	// `http://user:password@{{ .HTTPIP }}:{{ .HTTPPort }}/ks.cfg`.
+	HTTPUsername string `mapstructure:"http_username"`
+	// The basic auth password expected by the HTTP server.
+	HTTPPassword string `mapstructure:"http_password" packer:"sensitive"`
 }
 
 func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
@@ -90,6 +115,7 @@ func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
 			errors.New("either http_interface or http_bind_address can be specified"))
 	}
 
+	addressSet := c.HTTPAddress != ""
 	if c.HTTPAddress == "" {
 		c.HTTPAddress = "0.0.0.0"
 	}
@@ -129,5 +155,29 @@ func (c *HTTPConfig) Prepare(ctx *interpolate.Context) []error {
 			fmt.Errorf("http_network_protocol is invalid. Must be one of: %v", validProtocols))
 	}
 
+	isUnix := c.HTTPNetworkProtocol == NetworkProtocolUnix || c.HTTPNetworkProtocol == NetworkProcotlUnixPacket
+	if isUnix && !addressSet && c.HTTPSystemdSocket == "" {
+		errs = append(errs,
+			fmt.Errorf("http_bind_address must be the path of the socket with http_network_protocol %q", c.HTTPNetworkProtocol))
+	}
+
+	if (c.HTTPTLSCertFile == "") != (c.HTTPTLSKeyFile == "") {
+		errs = append(errs,
+			errors.New("http_tls_cert_file and http_tls_key_file must be specified together"))
+	}
+
+	if c.HTTPTLSCertFile != "" {
+		c.HTTPTLS = true
+		if _, err := tls.LoadX509KeyPair(c.HTTPTLSCertFile, c.HTTPTLSKeyFile); err != nil {
+			errs = append(errs,
+				fmt.Errorf("http_tls_cert_file and http_tls_key_file could not be loaded: %s", err))
+		}
+	}
+
+	if (c.HTTPUsername == "") != (c.HTTPPassword == "") {
+		errs = append(errs,
+			errors.New("http_username and http_password must be specified together"))
+	}
+
 	return errs
 }
@@ -46,3 +46,37 @@ func TestHTTPConfigPrepare_Bounds(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestHTTPConfigPrepare_TLSAndAuth(t *testing.T) {
+	c := &HTTPConfig{HTTPTLSCertFile: "cert.pem"}
+	if errs := c.Prepare(nil); len(errs) != 2 {
+		t.Fatalf("expected errors for a lone, missing, certificate: %v", errs)
+	}
+
+	c = &HTTPConfig{HTTPUsername: "packer"}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("expected an error for a username without password: %v", errs)
+	}
+
+	c = &HTTPConfig{HTTPTLS: true, HTTPUsername: "packer", HTTPPassword: "packer"}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestHTTPConfigPrepare_UnixSocket(t *testing.T) {
+	c := &HTTPConfig{HTTPNetworkProtocol: "unix"}
+	if errs := c.Prepare(nil); len(errs) != 1 {
+		t.Fatalf("expected an error for a unix socket without path: %v", errs)
+	}
+
+	c = &HTTPConfig{HTTPNetworkProtocol: "unix", HTTPAddress: "/run/packer/http.sock"}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+
+	c = &HTTPConfig{HTTPNetworkProtocol: "unix", HTTPSystemdSocket: "*"}
+	if errs := c.Prepare(nil); len(errs) != 0 {
+		t.Fatalf("should not have error: %v", errs)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	getter "github.com/hashicorp/go-getter/v2"
+	urlhelper "github.com/hashicorp/go-getter/v2/helper/url"
+)
+
+// verifiedChecksumFile downloads the checksum file at checksumURL, verifies
+// it was signed by one of the keys of the keyring at keyPath and writes its
+// signed content to a temporary file.
+//
+// The signature is read from signatureURL when set, otherwise the checksum
+// file must be clearsigned.
+//
+// The returned path is the one of the verified copy, so that the checksums
+// cannot change between the verification and their use. The caller is
+// responsible for removing its directory, which is returned too.
+func verifiedChecksumFile(ctx context.Context, checksumURL, signatureURL, keyPath, pwd string) (path, dir string, err error) {
+	keyring, err := readKeyRing(keyPath)
+	if err != nil {
+		return "", "", fmt.Errorf("Error reading the checksum public key %s: %s", keyPath, err)
+	}
+
+	dir, err = os.MkdirTemp("", "packer-checksum")
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	name := "checksums"
+	if u, err := urlhelper.Parse(checksumURL); err == nil && filepath.Base(u.Path) != "" {
+		name = filepath.Base(u.Path)
+	}
+	checksums, err := getFileContent(ctx, checksumURL, filepath.Join(dir, name), pwd)
+	if err != nil {
+		return "", "", fmt.Errorf("Error downloading checksum file: %s", err)
+	}
+
+	signed := checksums
+	var signer *openpgp.Entity
+	if signatureURL != "" {
+		signature, err := getFileContent(ctx, signatureURL, filepath.Join(dir, name+".sig"), pwd)
+		if err != nil {
+			return "", "", fmt.Errorf("Error downloading checksum signature: %s", err)
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP SIGNATURE-----")) {
+			signer, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(checksums), bytes.NewReader(signature), nil)
+		} else {
+			signer, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(checksums), bytes.NewReader(signature), nil)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("Bad signature for checksum file %s: %s", checksumURL, err)
+		}
+	} else {
+		block, _ := clearsign.Decode(checksums)
+		if block == nil {
+			return "", "", fmt.Errorf("Checksum file %s is not clearsigned, the URL of its detached signature must be set in iso_checksum_signature", checksumURL)
+		}
+		signer, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, nil)
+		if err != nil {
+			return "", "", fmt.Errorf("Bad signature for checksum file %s: %s", checksumURL, err)
+		}
+		signed = block.Plaintext
+	}
+	for identity := range signer.Identities {
+		log.Printf("Checksum file %s signed by %s", checksumURL, identity)
+	}
+
+	path = filepath.Join(dir, "verified", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, signed, 0600); err != nil {
+		return "", "", err
+	}
+	return path, dir, nil
+}
+
+func getFileContent(ctx context.Context, src, dst, pwd string) ([]byte, error) {
+	req := &getter.Request{
+		Src:     src,
+		Dst:     dst,
+		Pwd:     pwd,
+		GetMode: getter.ModeFile,
+	}
+	if _, err := defaultGetterClient.Get(ctx, req); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(dst)
+}
+
+// readKeyRing reads an armored or binary keyring.
+func readKeyRing(path string) (openpgp.EntityList, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(b), "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
@@ -30,6 +30,9 @@ import (
 // * Mercurial
 // * HTTP
 // * Amazon S3
+// * OCI registries, with `oci://` URLs like
+// `oci://ghcr.io/vendor/isos/ubuntu:22.04?file=ubuntu.iso`, where `file`
+// selects the file of the artifacts published with several files
 //
 // Examples:
 // go-getter can guess the checksum type based on `iso_checksum` length, and it is
@@ -94,6 +97,22 @@ import (
 //	iso_url = "ubuntu.org/.../ubuntu-14.04.1-server-amd64.iso"
 //
 // ```
+//
+// Checksum files can be in the GNU style of `sha256sum` and friends or in the
+// BSD style, ex: `SHA256 (ubuntu-20.04-live-server-amd64.iso) = ...`, the
+// algorithm is then read from the file or inferred from the length of the
+// checksum. When `iso_checksum_public_key` is set, the checksum file must be
+// signed by one of its keys, either through a detached signature or by being
+// clearsigned:
+//
+// ```hcl
+//
+//	iso_checksum            = "file:http://releases.ubuntu.com/20.04/SHA256SUMS"
+//	iso_checksum_signature  = "http://releases.ubuntu.com/20.04/SHA256SUMS.gpg"
+//	iso_checksum_public_key = "./ubuntu-cdimage.asc"
+//	iso_url                 = "http://releases.ubuntu.com/20.04/ubuntu-20.04.6-live-server-amd64.iso"
+//
+// ```
 type ISOConfig struct {
 	// The checksum for the ISO file or virtual hard drive file. The type of
 	// the checksum is specified within the checksum field as a prefix, ex:
@@ -116,6 +135,17 @@ type ISOConfig struct {
 	// this is not recommended since these files can be very large and
 	// corruption does happen from time to time.
 	ISOChecksum string `mapstructure:"iso_checksum" required:"true"`
+	// The URL or path of the detached GPG signature, armored or binary, of
+	// the checksum file set in `iso_checksum`, ex:
+	// `http://releases.ubuntu.com/20.04/SHA256SUMS.gpg`. Requires
+	// `iso_checksum_public_key`.
+	ISOChecksumSignature string `mapstructure:"iso_checksum_signature"`
+	// The path of a file containing the GPG public keys, armored or binary,
+	// trusted to sign the checksum file set in `iso_checksum`. When set, the
+	// build fails unless the checksum file is signed by one of these keys,
+	// through the detached signature set in `iso_checksum_signature` or by
+	// being clearsigned.
+	ISOChecksumPublicKey string `mapstructure:"iso_checksum_public_key"`
 	// A URL to the ISO containing the installation image or virtual hard drive
 	// (VHD or VHDX) file to clone.
 	RawSingleISOUrl string `mapstructure:"iso_url" required:"true"`
@@ -156,6 +186,15 @@ func (c *ISOConfig) Prepare(*interpolate.Context) (warnings []string, errs []err
 	}
 	c.TargetExtension = strings.ToLower(c.TargetExtension)
 
+	checksumFile, isChecksumFile := strings.CutPrefix(c.ISOChecksum, "file:")
+	if c.ISOChecksumSignature != "" && c.ISOChecksumPublicKey == "" {
+		errs = append(errs, errors.New("iso_checksum_public_key must be set to verify iso_checksum_signature"))
+	}
+	if c.ISOChecksumPublicKey != "" && !isChecksumFile {
+		errs = append(errs, errors.New("iso_checksum_public_key and iso_checksum_signature can only be used with a file: iso_checksum"))
+		return warnings, errs
+	}
+
 	// Warnings
 	if c.ISOChecksum == "none" {
 		warnings = append(warnings,
@@ -177,12 +216,6 @@ func (c *ISOConfig) Prepare(*interpolate.Context) (warnings []string, errs []err
 			return warnings, append(errs, fmt.Errorf("url parse: %s", err))
 		}
 
-		q := u.Query()
-		if c.ISOChecksum != "" {
-			q.Set("checksum", c.ISOChecksum)
-		}
-		u.RawQuery = q.Encode()
-
 		wd, err := os.Getwd()
 		if err != nil {
 			log.Printf("Getwd: %v", err)
@@ -190,13 +223,29 @@ func (c *ISOConfig) Prepare(*interpolate.Context) (warnings []string, errs []err
 			// working directory is not needed.
 		}
 
+		checksum := c.ISOChecksum
+		if c.ISOChecksumPublicKey != "" {
+			path, dir, err := verifiedChecksumFile(context.TODO(), checksumFile, c.ISOChecksumSignature, c.ISOChecksumPublicKey, wd)
+			if err != nil {
+				return warnings, append(errs, err)
+			}
+			defer os.RemoveAll(dir)
+			checksum = "file:" + path
+		}
+
+		q := u.Query()
+		if checksum != "" {
+			q.Set("checksum", checksum)
+		}
+		u.RawQuery = q.Encode()
+
 		req := &getter.Request{
 			Src: u.String(),
 			Pwd: wd,
 		}
 		cksum, err := defaultGetterClient.GetChecksum(context.TODO(), req)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("%v in %q", err, req.URL().Query().Get("checksum")))
+			errs = append(errs, fmt.Errorf("%v in %q", err, c.ISOChecksum))
 		} else {
 			c.ISOChecksum = cksum.String()
 		}
@@ -12,7 +12,12 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 )
 
 func testISOConfig() ISOConfig {
@@ -237,6 +242,140 @@ func TestISOConfigPrepare_ISOChecksumLocalFile(t *testing.T) {
 	}
 }
 
+func TestISOConfigPrepare_ISOChecksumStyles(t *testing.T) {
+	const sum = "ed363350696a726b7932db864dda019bd2017365c9e299627830f06954643f93"
+	for name, content := range map[string]string{
+		"gnu":        sum + "  mini.iso\n",
+		"gnu binary": sum + " *mini.iso\n",
+		"bsd":        "SHA256 (mini.iso) = " + sum + "\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "SHA256SUMS")
+			if err := os.WriteFile(path, []byte("# comment\n"+content), 0644); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			i := ISOConfig{
+				ISOChecksum: "file:" + path,
+				ISOUrls:     []string{"http://www.packer.io/mini.iso"},
+			}
+			if _, errs := i.Prepare(nil); len(errs) > 0 {
+				t.Fatalf("should not have error: %v", errs)
+			}
+			if i.ISOChecksum != "sha256:"+sum {
+				t.Fatalf("bad checksum: %s", i.ISOChecksum)
+			}
+		})
+	}
+}
+
+func TestISOConfigPrepare_ISOChecksumSignature(t *testing.T) {
+	const sum = "ed363350696a726b7932db864dda019bd2017365c9e299627830f06954643f93"
+	const sums = sum + "  mini.iso\n"
+
+	signer, err := openpgp.NewEntity("Packer", "", "packer@example.com", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	other, err := openpgp.NewEntity("Other", "", "other@example.com", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dir := t.TempDir()
+	writeFile := func(name string, write func(io.Writer) error) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer f.Close()
+		if err := write(f); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return path
+	}
+	writeKey := func(name string, e *openpgp.Entity) string {
+		return writeFile(name, func(w io.Writer) error {
+			aw, err := armor.Encode(w, openpgp.PublicKeyType, nil)
+			if err != nil {
+				return err
+			}
+			if err := e.Serialize(aw); err != nil {
+				return err
+			}
+			return aw.Close()
+		})
+	}
+
+	checksums := writeFile("SHA256SUMS", func(w io.Writer) error {
+		_, err := io.WriteString(w, sums)
+		return err
+	})
+	armoredSig := writeFile("SHA256SUMS.asc", func(w io.Writer) error {
+		return openpgp.ArmoredDetachSign(w, signer, strings.NewReader(sums), nil)
+	})
+	binarySig := writeFile("SHA256SUMS.gpg", func(w io.Writer) error {
+		return openpgp.DetachSign(w, signer, strings.NewReader(sums), nil)
+	})
+	clearsigned := writeFile("CHECKSUM", func(w io.Writer) error {
+		pw, err := clearsign.Encode(w, signer.PrivateKey, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(pw, sums); err != nil {
+			return err
+		}
+		return pw.Close()
+	})
+	tampered := writeFile("TAMPERED", func(w io.Writer) error {
+		_, err := io.WriteString(w, strings.Repeat("0", len(sum))+"  mini.iso\n")
+		return err
+	})
+	key := writeKey("packer.asc", signer)
+	otherKey := writeKey("other.asc", other)
+
+	cases := []struct {
+		name      string
+		checksum  string
+		signature string
+		key       string
+		ok        bool
+	}{
+		{"armored detached signature", "file:" + checksums, armoredSig, key, true},
+		{"binary detached signature", "file:" + checksums, binarySig, key, true},
+		{"clearsigned", "file:" + clearsigned, "", key, true},
+		{"tampered", "file:" + tampered, armoredSig, key, false},
+		{"untrusted key", "file:" + checksums, armoredSig, otherKey, false},
+		{"unsigned", "file:" + checksums, "", key, false},
+		{"signature without key", "file:" + checksums, armoredSig, "", false},
+		{"key without checksum file", sum, "", key, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			i := ISOConfig{
+				ISOChecksum:          tc.checksum,
+				ISOChecksumSignature: tc.signature,
+				ISOChecksumPublicKey: tc.key,
+				ISOUrls:              []string{"http://www.packer.io/mini.iso"},
+			}
+			_, errs := i.Prepare(nil)
+			if !tc.ok {
+				if len(errs) == 0 {
+					t.Fatal("should have error")
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatalf("should not have error: %v", errs)
+			}
+			if i.ISOChecksum != "sha256:"+sum {
+				t.Fatalf("bad checksum: %s", i.ISOChecksum)
+			}
+		})
+	}
+}
+
 const fixtureDir = "./test-fixtures"
 
 func httpTestModule(n string) *httptest.Server {
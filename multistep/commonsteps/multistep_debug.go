@@ -4,16 +4,27 @@
 package commonsteps
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"reflect"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 // MultistepDebugFn will return a proper multistep.DebugPauseFn to
 // use for debugging if you're using multistep in your builder.
+//
+// While paused, typing "dump" writes a redacted snapshot of the state bag and
+// of the generated data to a JSON file in the current directory, see
+// DumpState.
 func MultistepDebugFn(ui packersdk.Ui) multistep.DebugPauseFn {
 	return func(loc multistep.DebugLocation, name string, state multistep.StateBag) {
 		var locationString string
@@ -27,17 +38,30 @@ func MultistepDebugFn(ui packersdk.Ui) multistep.DebugPauseFn {
 		}
 
 		message := fmt.Sprintf(
-			"Pausing %s step '%s'. Press enter to continue.",
+			"Pausing %s step '%s'. Press enter to continue, or type 'dump' to write the state to a file.",
 			locationString, name)
 
 		result := make(chan string, 1)
 		go func() {
-			line, err := ui.Ask(message)
-			if err != nil {
-				log.Printf("Error asking for input: %s", err)
-			}
+			for {
+				line, err := ui.Ask(message)
+				if err != nil {
+					log.Printf("Error asking for input: %s", err)
+				}
+
+				if err == nil && strings.ToLower(strings.TrimSpace(line)) == "dump" {
+					path := debugStateFileName(loc, name)
+					if err := writeStateDump(path, state); err != nil {
+						ui.Error(fmt.Sprintf("Error writing the state to %s: %s", path, err))
+					} else {
+						ui.Say(fmt.Sprintf("State written to %s", path))
+					}
+					continue
+				}
 
-			result <- line
+				result <- line
+				return
+			}
 		}()
 
 		for {
@@ -52,3 +76,141 @@ func MultistepDebugFn(ui packersdk.Ui) multistep.DebugPauseFn {
 		}
 	}
 }
+
+func debugStateFileName(loc multistep.DebugLocation, name string) string {
+	where := "after"
+	if loc == multistep.DebugLocationBeforeCleanup {
+		where = "before-cleanup"
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	return fmt.Sprintf("packer-debug-state-%s-%s-%s.json", where, name, time.Now().Format("20060102-150405"))
+}
+
+func writeStateDump(path string, state multistep.StateBag) error {
+	// The dump can contain secrets that were not redacted, keep it private.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := DumpState(f, state); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// stateKeyer is implemented by state bags that can list their content, like
+// multistep.BasicStateBag.
+type stateKeyer interface {
+	Keys() []string
+}
+
+// sensitiveKeyParts are the parts of a key name hinting that its value is a
+// secret.
+var sensitiveKeyParts = []string{"password", "secret", "token", "private_key", "privatekey", "passphrase", "credential"}
+
+// DumpState writes a JSON snapshot of state to w, for debugging purposes.
+//
+// Values that cannot be represented as JSON, like the ui or the communicator,
+// are replaced by their type. The values stored under a key that looks like
+// it holds a secret, as well as those registered with
+// packersdk.LogSecretFilter and the generated data set with a sensitive
+// packerbuilderdata.Key, are replaced by "<sensitive>".
+//
+// The content of the state bag is only listed when it implements
+// Keys() []string, otherwise only the generated data is written.
+func DumpState(w io.Writer, state multistep.StateBag) error {
+	dump := struct {
+		State         map[string]interface{} `json:"state,omitempty"`
+		GeneratedData interface{}            `json:"generated_data,omitempty"`
+	}{}
+
+	if keyer, ok := state.(stateKeyer); ok {
+		dump.State = map[string]interface{}{}
+		for _, k := range keyer.Keys() {
+			if k == "generated_data" {
+				continue
+			}
+			v, ok := state.GetOk(k)
+			if !ok {
+				continue
+			}
+			dump.State[k] = redactDumpValue(k, jsonSafeValue(v))
+		}
+	}
+	if data, ok := state.GetOk("generated_data"); ok {
+		if redacted := packerbuilderdata.Redacted(state); redacted != nil {
+			data = redacted
+		}
+		dump.GeneratedData = redactDumpValue("generated_data", jsonSafeValue(data))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+// jsonSafeValue returns v as generic JSON data, or a placeholder describing
+// v when it cannot be encoded.
+func jsonSafeValue(v interface{}) (res interface{}) {
+	placeholder := fmt.Sprintf("<%T>", v)
+
+	switch v.(type) {
+	case packersdk.Ui, packersdk.Communicator, packersdk.Hook, error:
+		if err, ok := v.(error); ok {
+			return err.Error()
+		}
+		return placeholder
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return placeholder
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			res = placeholder
+		}
+	}()
+	b, err := json.Marshal(v)
+	if err != nil {
+		return placeholder
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return placeholder
+	}
+	return res
+}
+
+func redactDumpValue(key string, v interface{}) interface{} {
+	lkey := strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(lkey, part) {
+			if v == nil || v == "" {
+				return v
+			}
+			return "<sensitive>"
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			v[k] = redactDumpValue(k, sub)
+		}
+		return v
+	case []interface{}:
+		for i, sub := range v {
+			v[i] = redactDumpValue("", sub)
+		}
+		return v
+	case string:
+		return packersdk.LogSecretFilter.FilterString(v)
+	}
+	return v
+}
@@ -69,6 +69,9 @@ func NewRunnerWithPauseFn(steps []multistep.Step, config common.PackerConfig, ui
 }
 
 func typeName(i interface{}) string {
+	if wrapped, ok := i.(multistep.StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
 	return reflect.Indirect(reflect.ValueOf(i)).Type().Name()
 }
 
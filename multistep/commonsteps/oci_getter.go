@@ -0,0 +1,478 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	getter "github.com/hashicorp/go-getter/v2"
+)
+
+// The media types of the manifests of OCI artifacts, and the annotation of
+// the names of their files.
+const (
+	ociManifestMediaType       = "application/vnd.oci.image.manifest.v1+json"
+	ociArtifactMediaType       = "application/vnd.oci.artifact.manifest.v1+json"
+	dockerManifestMediaType    = "application/vnd.docker.distribution.manifest.v2+json"
+	ociTitleAnnotation         = "org.opencontainers.image.title"
+	defaultOCICredentialsEntry = "https://index.docker.io/v1/"
+)
+
+// OCIGetter is a go-getter Getter downloading the files published as OCI
+// artifacts, like with ORAS, from OCI registries. Its sources are of the
+// form:
+//
+//	oci://ghcr.io/vendor/images/firmware:1.2.0
+//	oci://registry.example.com/isos/ubuntu@sha256:<digest>
+//
+// The file is the layer of the artifact, or the one whose
+// "org.opencontainers.image.title" annotation is the "file" query
+// parameter, like oci://ghcr.io/vendor/images:1.2.0?file=disk.qcow2. The
+// digests of the layer, and of the manifest when referenced by digest, are
+// verified. The "plain_http" query parameter set to true uses HTTP, for the
+// local registries.
+//
+// The registries are authenticated with the credentials of the docker
+// configuration, in $DOCKER_CONFIG/config.json or ~/.docker/config.json,
+// like after a docker login or an oras login; credential helpers are not
+// supported.
+type OCIGetter struct {
+	// Client is the client of the requests to the registries,
+	// http.DefaultClient by default.
+	Client *http.Client
+	// Timeout is the time limit of a download.
+	Timeout time.Duration
+	// Credentials returns the username and password of host, an empty
+	// username for anonymous access. The docker configuration is used by
+	// default.
+	Credentials func(host string) (username, password string, err error)
+}
+
+var _ getter.Getter = new(OCIGetter)
+
+// ociReference is a reference to an artifact of a registry.
+type ociReference struct {
+	scheme     string
+	host       string
+	repository string
+	// reference is the tag or the digest of the artifact.
+	reference string
+	file      string
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+	// Blobs are the files of the artifact manifests.
+	Blobs []ociDescriptor `json:"blobs"`
+}
+
+func (g *OCIGetter) Mode(ctx context.Context, u *url.URL) (getter.Mode, error) {
+	return getter.ModeFile, nil
+}
+
+func (g *OCIGetter) Detect(req *getter.Request) (bool, error) {
+	if req.Forced != "" {
+		return req.Forced == "oci", nil
+	}
+	u, err := url.Parse(req.Src)
+	if err != nil {
+		return false, nil
+	}
+	return u.Scheme == "oci", nil
+}
+
+// Get downloads all the files of the artifact in the directory req.Dst,
+// named after their title annotation.
+func (g *OCIGetter) Get(ctx context.Context, req *getter.Request) error {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	ref, err := parseOCIReference(req.URL())
+	if err != nil {
+		return err
+	}
+	layers, err := g.layers(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(req.Dst, 0755); err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		title := filepath.Base(layer.Annotations[ociTitleAnnotation])
+		if title == "." || title == string(filepath.Separator) || title == "" {
+			return fmt.Errorf("layer %s of %s has no title", layer.Digest, ref)
+		}
+		if err := g.getBlob(ctx, req, ref, layer, filepath.Join(req.Dst, title)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFile downloads the file of the artifact at req.Dst.
+func (g *OCIGetter) GetFile(ctx context.Context, req *getter.Request) error {
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	ref, err := parseOCIReference(req.URL())
+	if err != nil {
+		return err
+	}
+	layers, err := g.layers(ctx, ref)
+	if err != nil {
+		return err
+	}
+	layer, err := ref.selectLayer(layers)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(req.Dst), 0755); err != nil {
+		return err
+	}
+	return g.getBlob(ctx, req, ref, layer, req.Dst)
+}
+
+func (g *OCIGetter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.Timeout > 0 {
+		return context.WithTimeout(ctx, g.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func parseOCIReference(u *url.URL) (*ociReference, error) {
+	ref := &ociReference{
+		scheme: "https",
+		host:   u.Host,
+		file:   u.Query().Get("file"),
+	}
+	if plain := u.Query().Get("plain_http"); plain == "true" || plain == "1" {
+		ref.scheme = "http"
+	}
+	if ref.host == "docker.io" {
+		ref.host = "registry-1.docker.io"
+	}
+
+	path := strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(path, "@"); i >= 0 {
+		ref.repository, ref.reference = path[:i], path[i+1:]
+	} else if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		ref.repository, ref.reference = path[:i], path[i+1:]
+	} else {
+		ref.repository, ref.reference = path, "latest"
+	}
+	if ref.host == "" || ref.repository == "" || ref.reference == "" {
+		return nil, fmt.Errorf("invalid OCI reference %s, expected oci://registry/repository:tag or oci://registry/repository@digest", u.Redacted())
+	}
+	if ref.host == "registry-1.docker.io" && !strings.Contains(ref.repository, "/") {
+		ref.repository = "library/" + ref.repository
+	}
+	return ref, nil
+}
+
+func (ref *ociReference) String() string {
+	sep := ":"
+	if strings.Contains(ref.reference, ":") {
+		sep = "@"
+	}
+	return ref.host + "/" + ref.repository + sep + ref.reference
+}
+
+func (ref *ociReference) url(kind, reference string) string {
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", ref.scheme, ref.host, ref.repository, kind, reference)
+}
+
+// selectLayer returns the layer of the file of ref.
+func (ref *ociReference) selectLayer(layers []ociDescriptor) (ociDescriptor, error) {
+	var titles []string
+	for _, layer := range layers {
+		title := layer.Annotations[ociTitleAnnotation]
+		if ref.file != "" && title == ref.file {
+			return layer, nil
+		}
+		titles = append(titles, title)
+	}
+	if ref.file == "" && len(layers) == 1 {
+		return layers[0], nil
+	}
+	if ref.file == "" {
+		return ociDescriptor{}, fmt.Errorf("%s has %d files %q, select one with the file query parameter", ref, len(layers), titles)
+	}
+	return ociDescriptor{}, fmt.Errorf("%s has no file %q, only %q", ref, ref.file, titles)
+}
+
+// layers returns the layers of the manifest of ref.
+func (g *OCIGetter) layers(ctx context.Context, ref *ociReference) ([]ociDescriptor, error) {
+	resp, err := g.do(ctx, ref, ref.url("manifests", ref.reference), strings.Join([]string{
+		ociManifestMediaType, ociArtifactMediaType, dockerManifestMediaType,
+	}, ", "))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the manifest of %s: %s", ref, err)
+	}
+	if strings.HasPrefix(ref.reference, "sha256:") {
+		if digest := sha256Digest(body); digest != ref.reference {
+			return nil, fmt.Errorf("manifest of %s has digest %s", ref, digest)
+		}
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("error decoding the manifest of %s: %s", ref, err)
+	}
+	layers := append(manifest.Layers, manifest.Blobs...)
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("%s has no files", ref)
+	}
+	return layers, nil
+}
+
+// getBlob downloads the blob of layer at dst, verifying its digest.
+func (g *OCIGetter) getBlob(ctx context.Context, req *getter.Request, ref *ociReference, layer ociDescriptor, dst string) error {
+	algorithm, expected, ok := strings.Cut(layer.Digest, ":")
+	if !ok || algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %q of a file of %s", layer.Digest, ref)
+	}
+
+	resp, err := g.do(ctx, ref, ref.url("blobs", layer.Digest), "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	var body io.ReadCloser = resp.Body
+	if req.ProgressListener != nil {
+		body = req.ProgressListener.TrackProgress(filepath.Base(dst), 0, layer.Size, resp.Body)
+		defer body.Close()
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if digest := hex.EncodeToString(h.Sum(nil)); digest != expected {
+			err = fmt.Errorf("file of %s has digest sha256:%s, expected %s", ref, digest, layer.Digest)
+		}
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// do gets u, authenticating to the registry of ref when asked to.
+func (g *OCIGetter) do(ctx context.Context, ref *ociReference, u, accept string) (*http.Response, error) {
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		authorization, err := g.authorize(ctx, client, ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if req, err = newRequest(); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", authorization)
+		if resp, err = client.Do(req); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error getting %s from %s: %s", u, ref.host, resp.Status)
+	}
+	return resp, nil
+}
+
+// authorize returns the Authorization header answering the challenge of the
+// registry of ref: a bearer token from its token service, or basic
+// credentials.
+func (g *OCIGetter) authorize(ctx context.Context, client *http.Client, ref *ociReference, challenge string) (string, error) {
+	credentials := g.Credentials
+	if credentials == nil {
+		credentials = dockerCredentials
+	}
+	username, password, err := credentials(ref.host)
+	if err != nil {
+		return "", fmt.Errorf("error reading the credentials of %s: %s", ref.host, err)
+	}
+
+	scheme, params := parseAuthChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return "", fmt.Errorf("%s requires credentials", ref.host)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication %q of %s", scheme, ref.host)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm %q of %s", params["realm"], ref.host)
+	}
+	q := realm.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.repository + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting a token for %s: %s", ref, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding the token for %s: %s", ref, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header like `Bearer
+// realm="https://auth.example.com/token",service="registry"`.
+func parseAuthChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, ", "), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key != "" {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+	return scheme, params
+}
+
+// dockerCredentials returns the credentials of host of the docker
+// configuration.
+func dockerCredentials(host string) (string, string, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return "", "", fmt.Errorf("invalid docker configuration: %s", err)
+	}
+
+	keys := []string{host, "https://" + host, "https://" + host + "/v1/", "https://" + host + "/v2/"}
+	if host == "registry-1.docker.io" {
+		keys = append(keys, defaultOCICredentialsEntry, "docker.io")
+	}
+	for _, key := range keys {
+		entry, ok := config.Auths[key]
+		if !ok || entry.Auth == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid docker credentials of %s: %s", key, err)
+		}
+		username, password, _ := strings.Cut(string(decoded), ":")
+		return username, password, nil
+	}
+	return "", "", nil
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
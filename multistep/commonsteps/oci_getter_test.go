@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	getter "github.com/hashicorp/go-getter/v2"
+)
+
+// testOCIRegistry serves the files of an artifact, behind a token
+// authentication.
+func testOCIRegistry(t *testing.T, files map[string]string) *httptest.Server {
+	blobs := map[string]string{}
+	var layers []ociDescriptor
+	for title, content := range files {
+		digest := sha256Digest([]byte(content))
+		blobs[digest] = content
+		layers = append(layers, ociDescriptor{
+			MediaType:   "application/octet-stream",
+			Digest:      digest,
+			Size:        int64(len(content)),
+			Annotations: map[string]string{ociTitleAnnotation: title},
+		})
+	}
+	manifest, err := json.Marshal(ociManifest{MediaType: ociManifestMediaType, Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:vendor/disk:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/vendor/disk/manifests/1.0", r.URL.Path == "/v2/vendor/disk/manifests/"+sha256Digest(manifest):
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			w.Write(manifest)
+		case strings.HasPrefix(r.URL.Path, "/v2/vendor/disk/blobs/"):
+			content, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/vendor/disk/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testOCIGet(t *testing.T, src string) (string, error) {
+	client := getter.Client{Getters: []getter.Getter{&OCIGetter{}}}
+	dst := filepath.Join(t.TempDir(), "disk")
+	_, err := client.Get(context.Background(), &getter.Request{
+		Src:     src,
+		Dst:     dst,
+		GetMode: getter.ModeFile,
+	})
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(dst)
+	return string(b), err
+}
+
+func TestOCIGetter(t *testing.T) {
+	srv := testOCIRegistry(t, map[string]string{"disk.raw": "raw disk"})
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	content, err := testOCIGet(t, fmt.Sprintf("oci://%s/vendor/disk:1.0?plain_http=true", host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "raw disk" {
+		t.Fatalf("bad content: %q", content)
+	}
+
+	_, err = testOCIGet(t, fmt.Sprintf("oci://%s/vendor/disk@sha256:%s?plain_http=true", host, strings.Repeat("0", 64)))
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a not found manifest, got %v", err)
+	}
+}
+
+func TestOCIGetter_file(t *testing.T) {
+	srv := testOCIRegistry(t, map[string]string{"disk.raw": "raw disk", "disk.qcow2": "qcow2 disk"})
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	content, err := testOCIGet(t, fmt.Sprintf("oci://%s/vendor/disk:1.0?plain_http=true&file=disk.qcow2", host))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "qcow2 disk" {
+		t.Fatalf("bad content: %q", content)
+	}
+
+	_, err = testOCIGet(t, fmt.Sprintf("oci://%s/vendor/disk:1.0?plain_http=true", host))
+	if err == nil || !strings.Contains(err.Error(), "select one with the file query parameter") {
+		t.Fatalf("expected an error asking to select a file, got %v", err)
+	}
+}
+
+func TestOCIGetter_digestMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/manifests/") {
+			json.NewEncoder(w).Encode(ociManifest{Layers: []ociDescriptor{{Digest: sha256Digest([]byte("expected"))}}})
+			return
+		}
+		fmt.Fprint(w, "tampered")
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	dir := t.TempDir()
+	client := getter.Client{Getters: []getter.Getter{&OCIGetter{}}}
+	_, err := client.Get(context.Background(), &getter.Request{
+		Src:     fmt.Sprintf("oci://%s/vendor/disk:1.0?plain_http=true", host),
+		Dst:     filepath.Join(dir, "disk"),
+		GetMode: getter.ModeFile,
+	})
+	if err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Fatalf("expected a digest error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "disk")); !os.IsNotExist(err) {
+		t.Fatalf("the tampered file should have been removed: %v", err)
+	}
+}
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"oci://ghcr.io/vendor/images/firmware:1.2.0", "https://ghcr.io/v2/vendor/images/firmware/manifests/1.2.0"},
+		{"oci://localhost:5000/isos/ubuntu", "https://localhost:5000/v2/isos/ubuntu/manifests/latest"},
+		{"oci://registry.example.com/isos/ubuntu@sha256:abcd", "https://registry.example.com/v2/isos/ubuntu/manifests/sha256:abcd"},
+		{"oci://docker.io/ubuntu:22.04", "https://registry-1.docker.io/v2/library/ubuntu/manifests/22.04"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := parseOCIReference(u)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.src, err)
+		}
+		if got := ref.url("manifests", ref.reference); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, got, tt.want)
+		}
+	}
+
+	if _, err := parseOCIReference(&url.URL{Scheme: "oci", Host: "ghcr.io"}); err == nil {
+		t.Fatal("expected an error for a reference without repository")
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull"`)
+	if scheme != "Bearer" || params["realm"] != "https://auth.example.com/token" ||
+		params["service"] != "registry.example.com" || params["scope"] != "repository:a/b:pull" {
+		t.Fatalf("bad challenge: %s %v", scheme, params)
+	}
+}
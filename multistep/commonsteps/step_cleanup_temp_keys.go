@@ -8,32 +8,58 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
+// StepCleanupTempKeys removes the temporary SSH key pair packer created from
+// the authorized_keys files of the guest, when SSHClearAuthorizedKeys is set.
+// The keys are removed through the communicator, so unlike the resources of
+// the TempResources registry they are cleaned up by a step of their own,
+// placed before the machine is shut down.
 type StepCleanupTempKeys struct {
 	Comm *communicator.Config
 }
 
 func (s *StepCleanupTempKeys) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if cleanup := TempKeysCleanup(s.Comm); cleanup != nil {
+		// Errors are only logged, the keys are ephemeral anyways.
+		_ = cleanup(ctx, state)
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepCleanupTempKeys) Cleanup(state multistep.StateBag) {
+}
+
+// TempKeysCleanup returns the callback that removes the temporary SSH key
+// pair from the authorized_keys files of the guest, or nil if there is
+// nothing to remove for comm.
+func TempKeysCleanup(comm *communicator.Config) TempResourceCleanupFunc {
 	// This step is mostly cosmetic; Packer deletes the ephemeral keys anyway
 	// so there's no realistic situation where these keys can cause issues.
 	// However, it's nice to clean up after yourself.
 
-	if !s.Comm.SSHClearAuthorizedKeys {
-		return multistep.ActionContinue
+	if !comm.SSHClearAuthorizedKeys {
+		return nil
 	}
 
-	if s.Comm.Type != "ssh" {
-		return multistep.ActionContinue
+	if comm.Type != "ssh" {
+		return nil
 	}
 
-	if s.Comm.SSHTemporaryKeyPairName == "" {
-		return multistep.ActionContinue
+	if comm.SSHTemporaryKeyPairName == "" {
+		return nil
 	}
 
+	return func(ctx context.Context, state multistep.StateBag) error {
+		return cleanupTempKeys(ctx, state, comm.SSHTemporaryKeyPairName)
+	}
+}
+
+func cleanupTempKeys(ctx context.Context, state multistep.StateBag, keyPairName string) error {
 	comm := state.Get("communicator").(packersdk.Communicator)
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -58,18 +84,18 @@ func (s *StepCleanupTempKeys) Run(ctx context.Context, state multistep.StateBag)
 	// leading space).
 	//
 	// TODO: Why create a backup file if you are going to remove it?
-	cmd.Command = fmt.Sprintf("sed -i.bak '/ %s$/d' ~/.ssh/authorized_keys; rm ~/.ssh/authorized_keys.bak", s.Comm.SSHTemporaryKeyPairName)
+	var errs error
+	cmd.Command = fmt.Sprintf("sed -i.bak '/ %s$/d' ~/.ssh/authorized_keys; rm ~/.ssh/authorized_keys.bak", keyPairName)
 	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		log.Printf("Error cleaning up ~/.ssh/authorized_keys; please clean up keys manually: %s", err)
+		errs = multierror.Append(errs, err)
 	}
 	cmd = new(packersdk.RemoteCmd)
-	cmd.Command = fmt.Sprintf("sudo sed -i.bak '/ %s$/d' /root/.ssh/authorized_keys; sudo rm /root/.ssh/authorized_keys.bak", s.Comm.SSHTemporaryKeyPairName)
+	cmd.Command = fmt.Sprintf("sudo sed -i.bak '/ %s$/d' /root/.ssh/authorized_keys; sudo rm /root/.ssh/authorized_keys.bak", keyPairName)
 	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 		log.Printf("Error cleaning up /root/.ssh/authorized_keys; please clean up keys manually: %s", err)
+		errs = multierror.Append(errs, err)
 	}
 
-	return multistep.ActionContinue
-}
-
-func (s *StepCleanupTempKeys) Cleanup(state multistep.StateBag) {
+	return errs
 }
@@ -26,9 +26,20 @@ type StepCreateFloppy struct {
 	Directories []string
 	Content     map[string]string
 	Label       string
+	// Size is the size of the floppy image, as accepted by
+	// FloppyConfig.FloppySize. Defaults to 1.44MB.
+	Size string
+	// Include and Exclude filter the files added from directories, as
+	// described in FloppyConfig.
+	Include []string
+	Exclude []string
 
 	floppyPath string
 
+	// names maps the upper-cased path of everything written to the floppy
+	// to its intended name, so the case of 8.3 names can be restored.
+	names map[string]string
+
 	FilesAdded map[string]bool
 }
 
@@ -45,8 +56,17 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 
 	s.FilesAdded = make(map[string]bool)
+	s.names = make(map[string]string)
 
 	ui := state.Get("ui").(packersdk.Ui)
+
+	format, err := parseFloppySize(s.Size)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	filter := floppyFilter{Include: s.Include, Exclude: s.Exclude}
+
 	ui.Say("Creating floppy disk...")
 
 	// Create a temporary file to be our floppy drive
@@ -64,7 +84,8 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	log.Printf("Floppy path: %s", s.floppyPath)
 
 	// Set the size of the file to be a floppy sized
-	if err := floppyF.Truncate(1440 * 1024); err != nil {
+	log.Printf("Floppy size: %d bytes", format.Size)
+	if err := floppyF.Truncate(format.Size); err != nil {
 		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
@@ -80,7 +101,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	// Format the block device so it contains a valid FAT filesystem
 	log.Println("Formatting the block device with a FAT filesystem...")
 	formatConfig := &fat.SuperFloppyConfig{
-		FATType: fat.FAT12,
+		FATType: format.FATType(),
 		Label:   s.Label,
 		OEMName: s.Label,
 	}
@@ -88,6 +109,10 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
 		return multistep.ActionHalt
 	}
+	if err := format.writeGeometry(floppyF); err != nil {
+		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		return multistep.ActionHalt
+	}
 
 	// The actual FAT filesystem
 	log.Println("Initializing FAT filesystem on block device")
@@ -126,10 +151,19 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 
 	var crawlDirectoryFiles []string
+	var crawlRoot string
 	crawlDirectory := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if rel, err := filepath.Rel(crawlRoot, path); err == nil && rel != "." {
+			if !filter.Match(filepath.ToSlash(rel), info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
 		if !info.IsDir() {
 			crawlDirectoryFiles = append(crawlDirectoryFiles, path)
 			ui.Message(fmt.Sprintf("Adding file: %s", path))
@@ -159,6 +193,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 		if finfo.IsDir() {
 			ui.Message(fmt.Sprintf("Copying directory: %s", filename))
 
+			crawlRoot = filename
 			err := filepath.Walk(filename, crawlDirectory)
 			if err != nil {
 				state.Put("error", fmt.Errorf("Error adding file from floppy_files : %s : %s", filename, err))
@@ -208,7 +243,7 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	// Go over each path in pathqueue and copy it.
 	for _, src := range pathqueue {
 		ui.Message(fmt.Sprintf("Recursively copying : %s", src))
-		err = s.Add(cache, src)
+		err = s.add(cache, src, filter)
 		if err != nil {
 			state.Put("error", fmt.Errorf("Error adding path %s to floppy: %s", src, err))
 			return multistep.ActionHalt
@@ -228,6 +263,16 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 	}
 	ui.Message("Done copying files from floppy_content")
 
+	// go-fs uppercases names fitting in 8.3 entries, restore their case.
+	img, err := newFatImage(floppyF, formatConfig.FATType)
+	if err == nil {
+		err = img.fixShortNameCase(s.names)
+	}
+	if err != nil {
+		state.Put("error", fmt.Errorf("Error creating floppy: %s", err))
+		return multistep.ActionHalt
+	}
+
 	// Set the path to the floppy so it can be used later
 	state.Put("floppy_path", s.floppyPath)
 
@@ -235,6 +280,26 @@ func (s *StepCreateFloppy) Run(ctx context.Context, state multistep.StateBag) mu
 }
 
 func (s *StepCreateFloppy) Add(dircache directoryCache, src string) error {
+	return s.add(dircache, src, floppyFilter{})
+}
+
+// addName checks that every component of the slash-separated floppyPath is a
+// valid floppy file name and records them for fixShortNameCase.
+func (s *StepCreateFloppy) addName(floppyPath string) error {
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	components := strings.Split(path.Clean(floppyPath), "/")
+	for i, name := range components {
+		if err := validateFloppyName(name); err != nil {
+			return err
+		}
+		s.names[strings.ToUpper(strings.Join(components[:i+1], "/"))] = name
+	}
+	return nil
+}
+
+func (s *StepCreateFloppy) add(dircache directoryCache, src string, filter floppyFilter) error {
 	finfo, err := os.Stat(src)
 	if err != nil {
 		return fmt.Errorf("Error adding path to floppy: %s", err)
@@ -253,7 +318,11 @@ func (s *StepCreateFloppy) Add(dircache directoryCache, src string) error {
 			return err
 		}
 
-		entry, err := d.AddFile(path.Base(filepath.ToSlash(src)))
+		name := path.Base(filepath.ToSlash(src))
+		if err := s.addName(name); err != nil {
+			return err
+		}
+		entry, err := d.AddFile(name)
 		if err != nil {
 			return err
 		}
@@ -274,11 +343,22 @@ func (s *StepCreateFloppy) Add(dircache directoryCache, src string) error {
 		if err != nil {
 			return err
 		}
+		if rel, err := filepath.Rel(src, pathname); err == nil && rel != "." {
+			if !filter.Match(filepath.ToSlash(rel), fi.IsDir()) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
 		if fi.Mode().IsDir() {
 			base, err := removeBase(basedirectory, pathname)
 			if err != nil {
 				return err
 			}
+			if err := s.addName(filepath.ToSlash(base)); err != nil {
+				return err
+			}
 			_, err = dircache(filepath.ToSlash(base))
 			return err
 		}
@@ -289,6 +369,10 @@ func (s *StepCreateFloppy) Add(dircache directoryCache, src string) error {
 			return err
 		}
 
+		if err := s.addName(path.Join(filepath.ToSlash(base), filename)); err != nil {
+			return err
+		}
+
 		inputF, err := os.Open(pathname)
 		if err != nil {
 			return err
@@ -319,15 +403,13 @@ func (s *StepCreateFloppy) Add(dircache directoryCache, src string) error {
 }
 
 func (s *StepCreateFloppy) AddContent(dircache directoryCache, path, content string) error {
-	basedirectory := filepath.Join(path, "..")
 	directory, filename := filepath.Split(filepath.ToSlash(path))
 
-	base, err := removeBase(basedirectory, filepath.FromSlash(directory))
-	if err != nil {
+	if err := s.addName(filepath.ToSlash(path)); err != nil {
 		return err
 	}
 
-	wd, err := dircache(filepath.ToSlash(base))
+	wd, err := dircache(strings.Trim(directory, "/"))
 	if err != nil {
 		return err
 	}
@@ -454,7 +536,7 @@ func fsDirectoryCache(rootDirectory fs.Directory) directoryCache {
 			cache[input] = res
 
 			// ..and yield it
-			Output <- entry
+			Output <- res
 		}
 	}(Error)
 
@@ -6,6 +6,7 @@ package commonsteps
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"log"
 	"os"
@@ -14,8 +15,10 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/mitchellh/go-fs/fat"
 )
 
 const TestFixtures = "test-fixtures"
@@ -318,3 +321,183 @@ func TestStepCreateFloppyContent(t *testing.T) {
 		t.Fatalf("file found: %s for %v", floppy_path, step.Content)
 	}
 }
+
+func TestStepCreateFloppySize(t *testing.T) {
+	tests := []struct {
+		size            string
+		bytes           int64
+		heads           uint16
+		sectorsPerTrack uint16
+	}{
+		{"", 1440 * 1024, 2, 18},
+		{"2.88MB", 2880 * 1024, 2, 36},
+		{"8MB", 8 * 1024 * 1024, 16, 32},
+		{"32MB", 32 * 1024 * 1024, 16, 32},
+	}
+
+	for _, tt := range tests {
+		state := testStepCreateFloppyState(t)
+		step := &StepCreateFloppy{
+			Size:    tt.size,
+			Content: map[string]string{"user-data": "#cloud-config"},
+		}
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v for %q : %v", action, tt.size, state.Get("error"))
+		}
+
+		floppy_path := state.Get("floppy_path").(string)
+		raw, err := os.ReadFile(floppy_path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if int64(len(raw)) != tt.bytes {
+			t.Fatalf("expected a floppy of %d bytes for %q, got %d", tt.bytes, tt.size, len(raw))
+		}
+		if spt := binary.LittleEndian.Uint16(raw[24:26]); spt != tt.sectorsPerTrack {
+			t.Fatalf("expected %d sectors per track for %q, got %d", tt.sectorsPerTrack, tt.size, spt)
+		}
+		if heads := binary.LittleEndian.Uint16(raw[26:28]); heads != tt.heads {
+			t.Fatalf("expected %d heads for %q, got %d", tt.heads, tt.size, heads)
+		}
+
+		step.Cleanup(state)
+	}
+}
+
+func TestStepCreateFloppyNonASCIIFilename(t *testing.T) {
+	state := testStepCreateFloppyState(t)
+	step := &StepCreateFloppy{
+		Content: map[string]string{"ünicode.txt": "content"},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatalf("state should be ok: %s", state.Get("error"))
+	}
+	step.Cleanup(state)
+}
+
+func TestStepCreateFloppyFilter(t *testing.T) {
+	dir := filepath.Join(".", TestFixtures, "floppy-hier", "test-2")
+
+	tests := []struct {
+		include []string
+		exclude []string
+		result  []string
+	}{
+		{
+			exclude: []string{"subdir1"},
+			result:  []string{"dir1/file1"},
+		},
+		{
+			include: []string{"file2"},
+			result:  []string{"dir1/subdir1/file2", "dir2/subdir1/file2"},
+		},
+		{
+			include: []string{"subdir1/*"},
+			exclude: []string{"file1"},
+			result:  []string{"dir1/subdir1/file2", "dir2/subdir1/file2"},
+		},
+	}
+
+	for _, tt := range tests {
+		state := testStepCreateFloppyState(t)
+		step := &StepCreateFloppy{
+			Directories: []string{filepath.Join(dir, "dir1"), filepath.Join(dir, "dir2")},
+			Include:     tt.include,
+			Exclude:     tt.exclude,
+		}
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+			t.Fatalf("bad action: %#v : %v", action, state.Get("error"))
+		}
+
+		expected := map[string]bool{}
+		for _, rpath := range tt.result {
+			expected[filepath.Join(dir, filepath.FromSlash(rpath))] = true
+		}
+		if diff := cmp.Diff(expected, step.FilesAdded); diff != "" {
+			t.Fatalf("unexpected files added for include %v, exclude %v: %s", tt.include, tt.exclude, diff)
+		}
+
+		step.Cleanup(state)
+	}
+}
+
+func TestStepCreateFloppyLongFilenames(t *testing.T) {
+	state := testStepCreateFloppyState(t)
+	step := new(StepCreateFloppy)
+
+	step.Content = map[string]string{
+		"Autounattend.xml":         "long",
+		"ks.cfg":                   "lower",
+		"README.md":                "upper",
+		"scripts/install.ps1":      "nested",
+		"a long name with spc.txt": "spaces",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v : %v", action, state.Get("error"))
+	}
+	defer step.Cleanup(state)
+
+	f, err := os.OpenFile(state.Get("floppy_path").(string), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	img, err := newFatImage(f, fat.FAT12)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	regions, err := img.dirRegions(0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	flags := map[string]byte{}
+	raw := make([]byte, regions[0][1])
+	if _, err := f.ReadAt(raw, regions[0][0]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for off := 0; off < len(raw) && raw[off] != 0; off += fat.DirectoryEntrySize {
+		e := raw[off : off+fat.DirectoryEntrySize]
+		if fat.DirectoryAttr(e[11]) == fat.AttrLongName {
+			continue
+		}
+		flags[string(e[0:11])] = e[12]
+	}
+
+	expected := map[string]byte{
+		"packer     ": 0,
+		"AUTOUN~1XML": 0,
+		"KS      CFG": 0x18,
+		"README  MD ": 0x10,
+		"SCRIPTS    ": 0x08,
+		"ALONGN~1TXT": 0,
+	}
+	if diff := cmp.Diff(expected, flags); diff != "" {
+		t.Fatalf("unexpected root directory entries: %s", diff)
+	}
+}
+
+func TestStepCreateFloppyInvalidFilename(t *testing.T) {
+	for _, name := range []string{"bad:name", "trailing.", " leading"} {
+		state := testStepCreateFloppyState(t)
+		step := &StepCreateFloppy{
+			Content: map[string]string{name: "content"},
+		}
+
+		if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+			t.Fatalf("bad action: %#v for %q", action, name)
+		}
+		if _, ok := state.GetOk("error"); !ok {
+			t.Fatalf("state should not be ok for %q", name)
+		}
+		step.Cleanup(state)
+	}
+}
@@ -58,6 +58,12 @@ type StepDownload struct {
 	// extension on the URL is used. Otherwise, this will be forced
 	// on the downloaded file for every URL.
 	Extension string
+
+	// Transforms are applied in order to the downloaded file, like
+	// DecompressTransform or ConvertTransform, and the path of the result
+	// is put into the state instead. Their results are cached in the cache
+	// directory when Checksum is set, so that the next builds skip them.
+	Transforms []DownloadTransform
 }
 
 // defaultGetterReadTimeout is the read timeout for downloading operations via go-getter.
@@ -115,6 +121,9 @@ func prepareGetterClient() {
 			&s3.Getter{
 				Timeout: getterReadTimeout,
 			},
+			&OCIGetter{
+				Timeout: getterReadTimeout,
+			},
 		},
 	}
 }
@@ -149,6 +158,9 @@ func (s *StepDownload) Run(ctx context.Context, state multistep.StateBag) multis
 		} else {
 			dst, err = s.download(ctx, ui, source)
 		}
+		if err == nil && len(s.Transforms) > 0 {
+			dst, err = s.transform(ctx, ui, dst)
+		}
 		if err == nil {
 			state.Put(s.ResultKey, dst)
 			// Track the URL you actually used for the download.
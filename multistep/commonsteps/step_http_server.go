@@ -5,17 +5,28 @@ package commonsteps
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"log"
+	"math/big"
+	gonet "net"
 	"net/http"
 	"os"
 	"path"
 	"sort"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
@@ -26,6 +37,12 @@ func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
 		HTTPPortMax:         cfg.HTTPPortMax,
 		HTTPAddress:         cfg.HTTPAddress,
 		HTTPNetworkProcotol: cfg.HTTPNetworkProtocol,
+		HTTPSystemdSocket:   cfg.HTTPSystemdSocket,
+		HTTPTLS:             cfg.HTTPTLS,
+		HTTPTLSCertFile:     cfg.HTTPTLSCertFile,
+		HTTPTLSKeyFile:      cfg.HTTPTLSKeyFile,
+		HTTPUsername:        cfg.HTTPUsername,
+		HTTPPassword:        cfg.HTTPPassword,
 	}
 }
 
@@ -37,9 +54,14 @@ func HTTPServerFromHTTPConfig(cfg *HTTPConfig) *StepHTTPServer {
 //
 //	ui     packersdk.Ui
 //
+// Builders can serve additional, dynamic, content by registering Handlers,
+// which take precedence over the directory or content for their pattern. See
+// InterpolatedContent to serve files rendered with per-build values.
+//
 // Produces:
 //
-//	http_port int - The port the HTTP server started on.
+//	http_port int - The port the HTTP server started on, 0 when it listens
+//	to a unix socket
 type StepHTTPServer struct {
 	HTTPDir             string
 	HTTPContent         map[string]string
@@ -47,16 +69,79 @@ type StepHTTPServer struct {
 	HTTPPortMax         int
 	HTTPAddress         string
 	HTTPNetworkProcotol string
+	// HTTPSystemdSocket is the name of a socket passed by systemd socket
+	// activation to listen to, rather than opening a port.
+	HTTPSystemdSocket string
+
+	HTTPTLS         bool
+	HTTPTLSCertFile string
+	HTTPTLSKeyFile  string
+	HTTPUsername    string
+	HTTPPassword    string
+
+	// Handlers are registered on the server, patterns follow the
+	// http.ServeMux syntax.
+	Handlers map[string]http.Handler
 
 	l *net.Listener
 }
 
 func (s *StepHTTPServer) Handler() http.Handler {
+	var handler http.Handler
 	if s.HTTPDir != "" {
-		return http.FileServer(http.Dir(s.HTTPDir))
+		handler = http.FileServer(http.Dir(s.HTTPDir))
+	} else {
+		handler = MapServer(s.HTTPContent)
 	}
 
-	return MapServer(s.HTTPContent)
+	if len(s.Handlers) > 0 {
+		mux := http.NewServeMux()
+		for pattern, h := range s.Handlers {
+			mux.Handle(pattern, h)
+		}
+		if _, ok := s.Handlers["/"]; !ok {
+			mux.Handle("/", handler)
+		}
+		handler = mux
+	}
+
+	if s.HTTPUsername != "" {
+		handler = basicAuth(s.HTTPUsername, s.HTTPPassword, handler)
+	}
+
+	return handler
+}
+
+func basicAuth(username, password string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="packer"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InterpolatedContent returns a handler that serves content after rendering it
+// with ictx on every request, so that a kickstart or cloud-init file can
+// reference values, set in ictx.Data, that are only known once the build
+// started.
+func InterpolatedContent(content string, ictx *interpolate.Context) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rendered, err := interpolate.Render(content, ictx)
+		if err != nil {
+			log.Printf("http content interpolation error for %s: %v", r.URL.Path, err)
+			http.Error(w, fmt.Sprintf("Error rendering %s: %s", r.URL.Path, err), http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write([]byte(rendered)); err != nil {
+			log.Printf("http content serve error: %v", err)
+		}
+	})
 }
 
 type MapServer map[string]string
@@ -88,7 +173,7 @@ func (s MapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 
-	if s.HTTPDir == "" && len(s.HTTPContent) == 0 {
+	if s.HTTPDir == "" && len(s.HTTPContent) == 0 && len(s.Handlers) == 0 {
 		state.Put("http_port", 0)
 		return multistep.ActionContinue
 	}
@@ -105,10 +190,11 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 	// Find an available TCP port for our HTTP server
 	var err error
 	s.l, err = net.ListenRangeConfig{
-		Min:     s.HTTPPortMin,
-		Max:     s.HTTPPortMax,
-		Addr:    s.HTTPAddress,
-		Network: s.HTTPNetworkProcotol,
+		Min:           s.HTTPPortMin,
+		Max:           s.HTTPPortMax,
+		Addr:          s.HTTPAddress,
+		Network:       s.HTTPNetworkProcotol,
+		SystemdSocket: s.HTTPSystemdSocket,
 	}.Listen(ctx)
 
 	if err != nil {
@@ -118,11 +204,24 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Starting HTTP server on port %d", s.l.Port))
+	var listener gonet.Listener = s.l
+	if s.HTTPTLS {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			err := fmt.Errorf("Error setting up HTTPS: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		listener = tls.NewListener(s.l, tlsConfig)
+		ui.Say(fmt.Sprintf("Starting HTTPS server on %s", listenerLocation(s.l)))
+	} else {
+		ui.Say(fmt.Sprintf("Starting HTTP server on %s", listenerLocation(s.l)))
+	}
 
 	// Start the HTTP server and run it in the background
 	server := &http.Server{Addr: "", Handler: s.Handler()}
-	go server.Serve(s.l)
+	go server.Serve(listener)
 
 	// Save the address into the state so it can be accessed in the future
 	state.Put("http_port", s.l.Port)
@@ -130,6 +229,73 @@ func (s *StepHTTPServer) Run(ctx context.Context, state multistep.StateBag) mult
 	return multistep.ActionContinue
 }
 
+// listenerLocation describes where l listens, its port or its socket.
+func listenerLocation(l *net.Listener) string {
+	if l.Port == 0 {
+		return fmt.Sprintf("%s socket %s", l.Network, l.Address)
+	}
+	return fmt.Sprintf("port %d", l.Port)
+}
+
+func (s *StepHTTPServer) tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if s.HTTPTLSCertFile != "" {
+		cert, err = tls.LoadX509KeyPair(s.HTTPTLSCertFile, s.HTTPTLSKeyFile)
+	} else {
+		log.Printf("Generating a self-signed certificate for the HTTPS server")
+		cert, err = selfSignedCertificate(s.HTTPAddress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// selfSignedCertificate generates a short lived certificate for address, or
+// for all of the addresses of the host when address is unspecified.
+func selfSignedCertificate(address string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Packer"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+	}
+	if ip := gonet.ParseIP(address); ip != nil && !ip.IsUnspecified() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if addrs, err := gonet.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*gonet.IPNet); ok {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
+
 func (s *StepHTTPServer) Cleanup(state multistep.StateBag) {
 	if s.l != nil {
 		ui := state.Get("ui").(packersdk.Ui)
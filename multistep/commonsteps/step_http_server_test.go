@@ -5,6 +5,7 @@ package commonsteps
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
 func TestStepHTTPServer_Run(t *testing.T) {
@@ -85,3 +87,72 @@ func TestStepHTTPServer_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestStepHTTPServer_TLSAndAuth(t *testing.T) {
+	ictx := &interpolate.Context{Data: map[string]string{"Hostname": "builder-1"}}
+	s := &StepHTTPServer{
+		HTTPContent:  map[string]string{"/foo.txt": "biz"},
+		HTTPPortMin:  9100,
+		HTTPPortMax:  9200,
+		HTTPTLS:      true,
+		HTTPUsername: "packer",
+		HTTPPassword: "s3cr3t",
+		Handlers: map[string]http.Handler{
+			"/ks.cfg": InterpolatedContent("network --hostname={{ .Hostname }}", ictx),
+		},
+	}
+	state := testState(t)
+	if got := s.Run(context.Background(), state); got != multistep.ActionContinue {
+		t.Fatalf("StepHTTPServer.Run() = %s: %v", got, state.Get("error"))
+	}
+	defer s.Cleanup(state)
+	port := state.Get("http_port")
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	tests := []struct {
+		path       string
+		user, pass string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/foo.txt", "packer", "s3cr3t", http.StatusOK, "biz"},
+		{"/ks.cfg", "packer", "s3cr3t", http.StatusOK, "network --hostname=builder-1"},
+		{"/foo.txt", "packer", "wrong", http.StatusUnauthorized, "Unauthorized\n"},
+		{"/foo.txt", "", "", http.StatusUnauthorized, "Unauthorized\n"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", fmt.Sprintf("https://127.0.0.1:%d%s", port, tt.path), nil)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("readall: %v", err)
+		}
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("GET %s as %q: status %d, want %d", tt.path, tt.user, resp.StatusCode, tt.wantStatus)
+		}
+		if diff := cmp.Diff(tt.wantBody, string(b)); diff != "" {
+			t.Errorf("GET %s as %q: unexpected content: %s", tt.path, tt.user, diff)
+		}
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/foo.txt", port))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("plain HTTP request to the HTTPS server should fail, got status %d", resp.StatusCode)
+		}
+	}
+}
@@ -9,26 +9,126 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
+// StateOutputDir is the state bag key under which StepOutputDir stores the
+// path of the directory the build should write its output to.
+const StateOutputDir = "output_dir"
+
+// OutputDir abstracts the storage the output directory of a build lives on,
+// so that builders writing to a remote datastore can reuse StepOutputDir.
+// Paths are passed as is, they don't need to be local paths.
+type OutputDir interface {
+	// Exists reports whether there is something at path.
+	Exists(path string) (bool, error)
+	// MkdirAll creates the directory at path and any missing parent.
+	MkdirAll(path string) error
+	// RemoveAll removes path and everything it contains. It returns nil if
+	// path doesn't exist.
+	RemoveAll(path string) error
+	// Rename moves the directory at oldpath to newpath, which doesn't exist.
+	Rename(oldpath, newpath string) error
+	// CheckWritable returns an error if files cannot be created in the
+	// directory at path.
+	CheckWritable(path string) error
+}
+
+// LocalOutputDir is the OutputDir of the local filesystem.
+type LocalOutputDir struct{}
+
+var _ OutputDir = LocalOutputDir{}
+
+func (LocalOutputDir) Exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (LocalOutputDir) MkdirAll(path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+func (LocalOutputDir) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (LocalOutputDir) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (LocalOutputDir) CheckWritable(path string) error {
+	f, err := os.Create(filepath.Join(path, "_packer_perm_check"))
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
 // StepOutputDir sets up the output directory by creating it if it does
 // not exist, deleting it if it does exist and we're forcing, and cleaning
 // it up when we're done with it.
+//
+// When Atomic is set the build writes to a staging directory next to Path,
+// which is only renamed to Path once the build succeeds; a previous output
+// directory is kept intact until then. Either way the path to write to is
+// stored in the state under StateOutputDir.
+//
+// Produces:
+//
+//	output_dir string - The directory the build should write to.
 type StepOutputDir struct {
 	Force bool
 	Path  string
 
+	// Atomic makes the step stage the output in a temporary directory,
+	// renamed to Path when the build succeeds.
+	Atomic bool
+	// Dir is the storage the output directory lives on. Defaults to the
+	// local filesystem.
+	Dir OutputDir
+
 	cleanup bool
 }
 
+func (s *StepOutputDir) dir() OutputDir {
+	if s.Dir == nil {
+		return LocalOutputDir{}
+	}
+	return s.Dir
+}
+
+// stagingPath is the directory the build writes to when Atomic is set.
+func (s *StepOutputDir) stagingPath() string {
+	return strings.TrimRight(s.Path, `/\`) + ".packer-tmp"
+}
+
+// workPath is the directory the build writes to.
+func (s *StepOutputDir) workPath() string {
+	if s.Atomic {
+		return s.stagingPath()
+	}
+	return s.Path
+}
+
 func (s *StepOutputDir) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
+	dir := s.dir()
 
-	if _, err := os.Stat(s.Path); err == nil {
+	exists, err := dir.Exists(s.Path)
+	if err != nil {
+		err = fmt.Errorf("Error checking output directory %s: %s", s.Path, err)
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	if exists {
 		if !s.Force {
 			err := fmt.Errorf(
 				"Output directory exists: %s\n\n"+
@@ -38,29 +138,42 @@ func (s *StepOutputDir) Run(ctx context.Context, state multistep.StateBag) multi
 			return multistep.ActionHalt
 		}
 
-		ui.Say("Deleting previous output directory...")
-		os.RemoveAll(s.Path)
+		// In atomic mode the previous output is only replaced on success.
+		if !s.Atomic {
+			ui.Say("Deleting previous output directory...")
+			if err := dir.RemoveAll(s.Path); err != nil {
+				log.Printf("Error removing previous output dir: %s", err)
+			}
+		}
+	}
+
+	path := s.workPath()
+	if s.Atomic {
+		// Leftovers of an interrupted build.
+		if err := dir.RemoveAll(path); err != nil {
+			err = fmt.Errorf("Error removing staging output directory %s: %s", path, err)
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
 	}
 
 	// Enable cleanup
 	s.cleanup = true
 
 	// Create the directory
-	if err := os.MkdirAll(s.Path, 0755); err != nil {
+	if err := dir.MkdirAll(path); err != nil {
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
 
 	// Make sure we can write in the directory
-	f, err := os.Create(filepath.Join(s.Path, "_packer_perm_check"))
-	if err != nil {
+	if err := dir.CheckWritable(path); err != nil {
 		err = fmt.Errorf("Couldn't write to output directory: %s", err)
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
-	f.Close()
-	os.Remove(f.Name())
 
+	state.Put(StateOutputDir, path)
 	return multistep.ActionContinue
 }
 
@@ -72,12 +185,13 @@ func (s *StepOutputDir) Cleanup(state multistep.StateBag) {
 	_, cancelled := state.GetOk(multistep.StateCancelled)
 	_, halted := state.GetOk(multistep.StateHalted)
 
-	if cancelled || halted {
-		ui := state.Get("ui").(packersdk.Ui)
+	ui := state.Get("ui").(packersdk.Ui)
+	dir := s.dir()
 
+	if cancelled || halted {
 		ui.Say("Deleting output directory...")
 		for i := 0; i < 5; i++ {
-			err := os.RemoveAll(s.Path)
+			err := dir.RemoveAll(s.workPath())
 			if err == nil {
 				break
 			}
@@ -85,5 +199,21 @@ func (s *StepOutputDir) Cleanup(state multistep.StateBag) {
 			log.Printf("Error removing output dir: %s", err)
 			time.Sleep(2 * time.Second)
 		}
+		return
+	}
+
+	if !s.Atomic {
+		return
+	}
+
+	if exists, err := dir.Exists(s.Path); err != nil || exists {
+		ui.Say("Replacing previous output directory...")
+		if err := dir.RemoveAll(s.Path); err != nil {
+			ui.Error(fmt.Sprintf("Error removing previous output directory, the output was left in %s: %s", s.stagingPath(), err))
+			return
+		}
+	}
+	if err := dir.Rename(s.stagingPath(), s.Path); err != nil {
+		ui.Error(fmt.Sprintf("Error moving the output to %s, it was left in %s: %s", s.Path, s.stagingPath(), err))
 	}
 }
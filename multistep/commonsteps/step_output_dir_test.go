@@ -7,8 +7,10 @@ import (
 	"bytes"
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -136,3 +138,108 @@ func TestStepOutputDir_halted(t *testing.T) {
 		t.Fatal("should not exist")
 	}
 }
+
+func TestStepOutputDir_atomic(t *testing.T) {
+	state := testState(t)
+	step := testStepOutputDir(t)
+	step.Atomic = true
+	step.Force = true
+	defer os.RemoveAll(step.Path)
+
+	// A previous build
+	if err := os.MkdirAll(step.Path, 0755); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(step.Path, "previous"), nil, 0644); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+	path := state.Get(StateOutputDir).(string)
+	if path == step.Path {
+		t.Fatalf("should write to a staging directory")
+	}
+	if _, err := os.Stat(filepath.Join(step.Path, "previous")); err != nil {
+		t.Fatalf("previous output should be kept until the build succeeds: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "disk.vmdk"), nil, 0644); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+
+	step.Cleanup(state)
+	if _, err := os.Stat(filepath.Join(step.Path, "disk.vmdk")); err != nil {
+		t.Fatalf("output should have been moved: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(step.Path, "previous")); err == nil {
+		t.Fatal("previous output should have been replaced")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("staging directory should not exist")
+	}
+}
+
+func TestStepOutputDir_atomicHalted(t *testing.T) {
+	state := testState(t)
+	step := testStepOutputDir(t)
+	step.Atomic = true
+	step.Force = true
+	defer os.RemoveAll(step.Path)
+
+	if err := os.MkdirAll(step.Path, 0755); err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+	path := state.Get(StateOutputDir).(string)
+
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("staging directory should not exist")
+	}
+	if _, err := os.Stat(step.Path); err != nil {
+		t.Fatalf("previous output should be kept: %s", err)
+	}
+}
+
+type recordingOutputDir struct {
+	LocalOutputDir
+	calls []string
+}
+
+func (d *recordingOutputDir) MkdirAll(path string) error {
+	d.calls = append(d.calls, "mkdir "+filepath.Base(path))
+	return d.LocalOutputDir.MkdirAll(path)
+}
+
+func (d *recordingOutputDir) Rename(oldpath, newpath string) error {
+	d.calls = append(d.calls, "rename "+filepath.Base(oldpath)+" "+filepath.Base(newpath))
+	return d.LocalOutputDir.Rename(oldpath, newpath)
+}
+
+func TestStepOutputDir_customDir(t *testing.T) {
+	state := testState(t)
+	step := testStepOutputDir(t)
+	defer os.RemoveAll(step.Path)
+	dir := new(recordingOutputDir)
+	step.Dir = dir
+	step.Atomic = true
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+	step.Cleanup(state)
+
+	base := filepath.Base(step.Path)
+	expected := []string{
+		"mkdir " + base + ".packer-tmp",
+		"rename " + base + ".packer-tmp " + base,
+	}
+	if diff := cmp.Diff(expected, dir.calls); diff != "" {
+		t.Fatalf("unexpected calls: %s", diff)
+	}
+}
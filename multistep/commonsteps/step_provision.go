@@ -30,7 +30,40 @@ const HttpIPNotImplemented = "ERR_HTTP_IP_NOT_IMPLEMENTED_BY_BUILDER"
 const HttpPortNotImplemented = "ERR_HTTP_PORT_NOT_IMPLEMENTED_BY_BUILDER"
 const HttpAddrNotImplemented = "ERR_HTTP_ADDR_NOT_IMPLEMENTED_BY_BUILDER"
 
+// ProvisionHookDataProvider contributes entries to the generated data passed
+// to provisioners and post-processors. Builders can implement it to expose
+// values of their own without having to rebuild the whole map.
+type ProvisionHookDataProvider interface {
+	ProvisionHookData(state multistep.StateBag) map[string]interface{}
+}
+
+// ProvisionHookDataFunc is an adapter to use ordinary functions as a
+// ProvisionHookDataProvider.
+type ProvisionHookDataFunc func(state multistep.StateBag) map[string]interface{}
+
+func (f ProvisionHookDataFunc) ProvisionHookData(state multistep.StateBag) map[string]interface{} {
+	return f(state)
+}
+
+// defaultProvisionHookData returns the providers of the entries every build
+// exposes: the instance ID, the run UUID, the HTTP server address and the
+// communicator settings.
+func defaultProvisionHookData() []ProvisionHookDataProvider {
+	return []ProvisionHookDataProvider{
+		ProvisionHookDataFunc(instanceHookData),
+		ProvisionHookDataFunc(httpHookData),
+		ProvisionHookDataFunc(communicatorHookData),
+	}
+}
+
 func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{} {
+	return PopulateProvisionHookDataWith(state)
+}
+
+// PopulateProvisionHookDataWith assembles the generated data the same way as
+// PopulateProvisionHookData and then adds the entries of providers, in order.
+// Entries from later providers replace existing ones.
+func PopulateProvisionHookDataWith(state multistep.StateBag, providers ...ProvisionHookDataProvider) map[string]interface{} {
 	hookData := make(map[string]interface{})
 
 	// Load Builder hook data from state, if it has been set.
@@ -39,6 +72,20 @@ func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{}
 		hookData = hd.(map[string]interface{})
 	}
 
+	for _, list := range [][]ProvisionHookDataProvider{defaultProvisionHookData(), providers} {
+		for _, provider := range list {
+			for k, v := range provider.ProvisionHookData(state) {
+				hookData[k] = v
+			}
+		}
+	}
+
+	return hookData
+}
+
+func instanceHookData(state multistep.StateBag) map[string]interface{} {
+	hookData := make(map[string]interface{})
+
 	// Warn user that the id isn't implemented
 	hookData["ID"] = "ERR_ID_NOT_IMPLEMENTED_BY_BUILDER"
 
@@ -54,6 +101,12 @@ func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{}
 
 	hookData["PackerRunUUID"] = os.Getenv("PACKER_RUN_UUID")
 
+	return hookData
+}
+
+func httpHookData(state multistep.StateBag) map[string]interface{} {
+	hookData := make(map[string]interface{})
+
 	// Packer HTTP info
 	hookData["PackerHTTPIP"] = HttpIPNotImplemented
 	hookData["PackerHTTPPort"] = HttpPortNotImplemented
@@ -71,14 +124,20 @@ func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{}
 		hookData["PackerHTTPAddr"] = fmt.Sprintf("%s:%s", hookData["PackerHTTPIP"], hookData["PackerHTTPPort"])
 	}
 
+	return hookData
+}
+
+func communicatorHookData(state multistep.StateBag) map[string]interface{} {
 	// Read communicator data into hook data
 	comm, ok := state.GetOk("communicator_config")
 	if !ok {
 		log.Printf("Unable to load communicator config from state to populate provisionHookData")
-		return hookData
+		return nil
 	}
 	commConf := comm.(*communicator.Config)
 
+	hookData := make(map[string]interface{})
+
 	// Loop over all field values and retrieve them from the ssh config
 	hookData["Host"] = commConf.Host()
 	hookData["Port"] = commConf.Port()
@@ -97,8 +156,21 @@ func PopulateProvisionHookData(state multistep.StateBag) map[string]interface{}
 	return hookData
 }
 
+// StepProvision runs the provision hook, and the cleanup provision hook if it
+// fails. Settings apply to the whole provision hook, so they are enforced
+// whatever the hook is, including the one of Packer over RPC. When the hook is
+// a packersdk.ProvisionHook, each provisioner gets its own settings on top.
 type StepProvision struct {
 	Comm packersdk.Communicator
+
+	// Settings are enforced around the provision hook: it is paused before,
+	// given a timeout and retried as configured. They don't apply to the
+	// cleanup provision hook.
+	Settings packersdk.ProvisionerSettings
+
+	// HookData are additional providers of generated data, applied after
+	// the default ones.
+	HookData []ProvisionHookDataProvider
 }
 
 func (s *StepProvision) runWithHook(ctx context.Context, state multistep.StateBag, hooktype string) multistep.StepAction {
@@ -114,7 +186,7 @@ func (s *StepProvision) runWithHook(ctx context.Context, state multistep.StateBa
 	hook := state.Get("hook").(packersdk.Hook)
 	ui := state.Get("ui").(packersdk.Ui)
 
-	hookData := PopulateProvisionHookData(state)
+	hookData := PopulateProvisionHookDataWith(state, s.HookData...)
 
 	// Update state generated_data with complete hookData
 	// to make them accessible by post-processors
@@ -127,9 +199,18 @@ func (s *StepProvision) runWithHook(ctx context.Context, state multistep.StateBa
 	} else if hooktype == packersdk.HookCleanupProvision {
 		ui.Say("Provisioning step had errors: Running the cleanup provisioner, if present...")
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- hook.Run(ctx, hooktype, ui, comm, hookData)
+		if hooktype != packersdk.HookProvision {
+			errCh <- hook.Run(ctx, hooktype, ui, comm, hookData)
+			return
+		}
+		errCh <- s.Settings.Run(ctx, ui, "provision hook", func(ctx context.Context) error {
+			return hook.Run(ctx, hooktype, ui, comm, hookData)
+		})
 	}()
 
 	for {
@@ -4,13 +4,17 @@
 package commonsteps
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 func testCommConfig() *communicator.Config {
@@ -96,3 +100,156 @@ func TestPopulateProvisionHookData(t *testing.T) {
 		t.Fatalf("Bad: Expecting hookData[\"WinRMPassword\"]  was %s but actual value was %s", commConfig.WinRMPassword, hookData["WinRMPassword"])
 	}
 }
+
+func TestPopulateProvisionHookDataWith(t *testing.T) {
+	state := testState(t)
+	state.Put("instance_id", "i-123")
+
+	hookData := PopulateProvisionHookDataWith(state,
+		ProvisionHookDataFunc(func(state multistep.StateBag) map[string]interface{} {
+			return map[string]interface{}{"Region": "eu-west-1", "ID": "overridden"}
+		}),
+	)
+
+	if hookData["Region"] != "eu-west-1" {
+		t.Fatalf("Bad: Expecting hookData[\"Region\"] to be set by the provider, got %v", hookData["Region"])
+	}
+	if hookData["ID"] != "overridden" {
+		t.Fatalf("Bad: Expecting providers to override default keys, got %v", hookData["ID"])
+	}
+	if hookData["PackerHTTPPort"] != HttpPortNotImplemented {
+		t.Fatalf("Bad: Expecting default keys to be set, got %v", hookData["PackerHTTPPort"])
+	}
+}
+
+// funcProvisioner calls fn on every run, unlike packersdk.MockProvisioner that
+// only calls its ProvFunc once.
+type funcProvisioner struct {
+	packersdk.MockProvisioner
+	fn func(context.Context) error
+}
+
+func (p *funcProvisioner) Provision(ctx context.Context, _ packersdk.Ui, _ packersdk.Communicator, _ map[string]interface{}) error {
+	return p.fn(ctx)
+}
+
+func TestStepProvision_Settings(t *testing.T) {
+	attempts := 0
+	hook := &packersdk.ProvisionHook{
+		Provisioners: []*packersdk.HookedProvisioner{
+			{
+				Provisioner: &funcProvisioner{
+					fn: func(ctx context.Context) error {
+						attempts++
+						if attempts < 2 {
+							return fmt.Errorf("attempt %d failed", attempts)
+						}
+						return nil
+					},
+				},
+				TypeName: "mock",
+				Settings: packersdk.ProvisionerSettings{MaxRetries: 2},
+			},
+		},
+	}
+
+	state := testState(t)
+	state.Put("hook", hook)
+	step := &StepProvision{
+		Comm: new(packersdk.MockCommunicator),
+		HookData: []ProvisionHookDataProvider{
+			ProvisionHookDataFunc(func(multistep.StateBag) map[string]interface{} {
+				return map[string]interface{}{"Custom": "value"}
+			}),
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %v", action, state.Get("error"))
+	}
+	if attempts != 2 {
+		t.Fatalf("expected the provisioner to be retried once, ran %d times", attempts)
+	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if generatedData["Custom"] != "value" {
+		t.Fatalf("Bad: Expecting generated data from HookData, got %v", generatedData["Custom"])
+	}
+}
+
+func TestStepProvision_Timeout(t *testing.T) {
+	ran := false
+	hook := &packersdk.ProvisionHook{
+		Provisioners: []*packersdk.HookedProvisioner{
+			{
+				Provisioner: &packersdk.MockProvisioner{
+					ProvFunc: func(ctx context.Context) error {
+						<-ctx.Done()
+						return ctx.Err()
+					},
+				},
+				TypeName: "slow",
+				Settings: packersdk.ProvisionerSettings{Timeout: 10 * time.Millisecond},
+			},
+			{
+				Provisioner: &packersdk.MockProvisioner{
+					ProvFunc: func(ctx context.Context) error {
+						ran = true
+						return nil
+					},
+				},
+				TypeName: "fast",
+			},
+		},
+	}
+
+	state := testState(t)
+	state.Put("hook", hook)
+	step := &StepProvision{Comm: new(packersdk.MockCommunicator)}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "slow provisioner timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if ran {
+		t.Fatal("the provisioners following a failed one should not run")
+	}
+}
+
+func TestStepProvision_hookSettings(t *testing.T) {
+	attempts := 0
+	// Like the hook of Packer over RPC, it isn't a packersdk.ProvisionHook.
+	hook := &packersdk.MockHook{
+		RunFunc: func(ctx context.Context) error {
+			attempts++
+			if attempts < 2 {
+				return fmt.Errorf("attempt %d failed", attempts)
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	state := testState(t)
+	state.Put("hook", hook)
+	step := &StepProvision{
+		Comm: new(packersdk.MockCommunicator),
+		Settings: packersdk.ProvisionerSettings{
+			MaxRetries: 1,
+			Timeout:    10 * time.Millisecond,
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected the provision hook to be retried once, ran %d times", attempts)
+	}
+	err, ok := state.GetOk("error")
+	if !ok || !strings.Contains(err.(error).Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
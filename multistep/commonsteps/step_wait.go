@@ -0,0 +1,244 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// DefaultWaitTimeout is the time the wait steps wait for when no
+	// timeout is set.
+	DefaultWaitTimeout = 5 * time.Minute
+	// DefaultWaitPollInterval is the time the wait steps wait for between
+	// two checks when no poll interval is set.
+	DefaultWaitPollInterval = 5 * time.Second
+)
+
+// waitFor calls check every interval until it returns true, an error, or
+// until timeout elapses or ctx is cancelled. Each check gets a context
+// cancelled when the overall timeout expires.
+func waitFor(ctx context.Context, what string, timeout, interval time.Duration, check func(context.Context) (bool, error)) error {
+	if timeout == 0 {
+		timeout = DefaultWaitTimeout
+	}
+	if interval == 0 {
+		interval = DefaultWaitPollInterval
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := check(waitCtx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		log.Printf("[DEBUG] %s not ready yet, waiting %s", what, interval)
+
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("Timeout waiting for %s after %s", what, timeout)
+		}
+	}
+}
+
+// StepWaitForPort waits until a TCP port of the guest accepts connections.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+type StepWaitForPort struct {
+	// Host returns the host to connect to.
+	Host func(multistep.StateBag) (string, error)
+	// Port is the TCP port to wait for.
+	Port int
+	// Timeout is the time to wait for the port to open. Defaults to
+	// DefaultWaitTimeout.
+	Timeout time.Duration
+	// PollInterval is the time to wait for between two connection attempts.
+	// Defaults to DefaultWaitPollInterval.
+	PollInterval time.Duration
+}
+
+func (s *StepWaitForPort) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	host, err := s.Host(state)
+	if err != nil {
+		err := fmt.Errorf("Error getting the host to wait for: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(s.Port))
+
+	ui.Say(fmt.Sprintf("Waiting for %s to accept connections...", address))
+	err = waitFor(ctx, address, s.Timeout, s.PollInterval, func(ctx context.Context) (bool, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			log.Printf("[DEBUG] Error connecting to %s: %s", address, err)
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	})
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepWaitForPort) Cleanup(state multistep.StateBag) {}
+
+// StepWaitForFile waits until a file exists on the guest.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator
+//	ui packersdk.Ui
+type StepWaitForFile struct {
+	// Path is the path of the file on the guest.
+	Path string
+	// Command is the command run through the communicator to check that the
+	// file exists, a zero exit status meaning it does. It is formatted with
+	// Path and defaults to `test -e '%s'`, with Path quoted for POSIX shells.
+	// Windows guests need to set it, for example to
+	// `if exist "%s" (exit 0) else (exit 1)`.
+	Command string
+	// Timeout is the time to wait for the file to exist. Defaults to
+	// DefaultWaitTimeout.
+	Timeout time.Duration
+	// PollInterval is the time to wait for between two checks. Defaults to
+	// DefaultWaitPollInterval.
+	PollInterval time.Duration
+}
+
+func (s *StepWaitForFile) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	var command string
+	if s.Command == "" {
+		command = fmt.Sprintf("test -e '%s'", strings.ReplaceAll(s.Path, "'", `'\''`))
+	} else {
+		command = fmt.Sprintf(s.Command, s.Path)
+	}
+
+	ui.Say(fmt.Sprintf("Waiting for %s to exist...", s.Path))
+	err := waitFor(ctx, s.Path, s.Timeout, s.PollInterval, func(ctx context.Context) (bool, error) {
+		cmd := &packersdk.RemoteCmd{Command: command}
+		if err := comm.Start(ctx, cmd); err != nil {
+			log.Printf("[DEBUG] Error checking for %s: %s", s.Path, err)
+			return false, nil
+		}
+		code, err := cmd.WaitContext(ctx)
+		if err != nil {
+			log.Printf("[DEBUG] Error checking for %s: %s", s.Path, err)
+			return false, nil
+		}
+		return code == 0, nil
+	})
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepWaitForFile) Cleanup(state multistep.StateBag) {}
+
+// StepWaitForHTTP waits until an HTTP endpoint answers with a 200 status.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+type StepWaitForHTTP struct {
+	// URL returns the URL to request.
+	URL func(multistep.StateBag) (string, error)
+	// InsecureSkipVerify skips the verification of the TLS certificate of
+	// the server, which is often self signed on a freshly installed guest.
+	InsecureSkipVerify bool
+	// Timeout is the time to wait for the endpoint to be ready. Defaults to
+	// DefaultWaitTimeout.
+	Timeout time.Duration
+	// PollInterval is the time to wait for between two requests. Defaults
+	// to DefaultWaitPollInterval.
+	PollInterval time.Duration
+}
+
+func (s *StepWaitForHTTP) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	url, err := s.URL(state)
+	if err != nil {
+		err := fmt.Errorf("Error getting the URL to wait for: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: s.InsecureSkipVerify},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	ui.Say(fmt.Sprintf("Waiting for %s to be ready...", url))
+	err = waitFor(ctx, url, s.Timeout, s.PollInterval, func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, fmt.Errorf("Error creating the request for %s: %s", url, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("[DEBUG] Error requesting %s: %s", url, err)
+			return false, nil
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("[DEBUG] %s answered with status %s", url, resp.Status)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepWaitForHTTP) Cleanup(state multistep.StateBag) {}
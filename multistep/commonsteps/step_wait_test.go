@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepWait_impl(t *testing.T) {
+	var _ multistep.Step = new(StepWaitForPort)
+	var _ multistep.Step = new(StepWaitForFile)
+	var _ multistep.Step = new(StepWaitForHTTP)
+}
+
+func TestStepWaitForPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+
+	step := &StepWaitForPort{
+		Host:         func(multistep.StateBag) (string, error) { return "127.0.0.1", nil },
+		Port:         l.Addr().(*net.TCPAddr).Port,
+		Timeout:      5 * time.Second,
+		PollInterval: 10 * time.Millisecond,
+	}
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+
+	// Nothing listens on the port anymore.
+	l.Close()
+	step.Timeout = 50 * time.Millisecond
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "Timeout waiting for") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestStepWaitForFile(t *testing.T) {
+	comm := &packersdk.MockCommunicator{}
+	state := testState(t)
+	state.Put("communicator", comm)
+
+	step := &StepWaitForFile{
+		Path:         "/var/lib/cloud/instance/boot-finished",
+		Timeout:      5 * time.Second,
+		PollInterval: 10 * time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+	if expected := "test -e '/var/lib/cloud/instance/boot-finished'"; comm.StartCmd.Command != expected {
+		t.Fatalf("expected command %q, got %q", expected, comm.StartCmd.Command)
+	}
+
+	step.Path = "/tmp/it's ready"
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+	if expected := `test -e '/tmp/it'\''s ready'`; comm.StartCmd.Command != expected {
+		t.Fatalf("expected command %q, got %q", expected, comm.StartCmd.Command)
+	}
+
+	comm.StartExitStatus = 1
+	step.Command = `if exist "%s" (exit 0) else (exit 1)`
+	step.Path = `C:\ready`
+	step.Timeout = 50 * time.Millisecond
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if expected := `if exist "C:\ready" (exit 0) else (exit 1)`; comm.StartCmd.Command != expected {
+		t.Fatalf("expected command %q, got %q", expected, comm.StartCmd.Command)
+	}
+}
+
+func TestStepWaitForHTTP(t *testing.T) {
+	var requests int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	step := &StepWaitForHTTP{
+		URL:                func(multistep.StateBag) (string, error) { return ts.URL + "/health", nil },
+		InsecureSkipVerify: true,
+		Timeout:            5 * time.Second,
+		PollInterval:       10 * time.Millisecond,
+	}
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, err: %s", action, state.Get("error"))
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Fatalf("expected 3 requests, got %d", n)
+	}
+}
+
+func TestStepWaitForHTTP_cancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	step := &StepWaitForHTTP{
+		URL:          func(multistep.StateBag) (string, error) { return ts.URL, nil },
+		PollInterval: 10 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	state := testState(t)
+	if action := step.Run(ctx, state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
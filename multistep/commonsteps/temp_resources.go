@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StateTempResources is the state bag key under which the TempResources of a
+// build are stored.
+const StateTempResources = "temp_resources"
+
+// TempResourceCleanupFunc deletes a temporary resource.
+type TempResourceCleanupFunc func(ctx context.Context, state multistep.StateBag) error
+
+type tempResource struct {
+	name    string
+	cleanup TempResourceCleanupFunc
+}
+
+// TempResources is a registry of the temporary resources created during a
+// build: temporary security groups, files, etc. Steps register a cleanup
+// callback right after creating a resource, and the callbacks are run in the
+// reverse order of registration by StepCleanupTempResources, whether the
+// build succeeds, fails or is cancelled.
+//
+// The callbacks run once every other step was cleaned up, so they can't
+// rely on the communicator being connected.
+//
+// A TempResources is safe for concurrent use.
+type TempResources struct {
+	l         sync.Mutex
+	resources []tempResource
+}
+
+// TempResourcesFromState returns the registry of the build, creating and
+// storing it in state if needed.
+func TempResourcesFromState(state multistep.StateBag) *TempResources {
+	if r, ok := state.GetOk(StateTempResources); ok {
+		return r.(*TempResources)
+	}
+	r := new(TempResources)
+	state.Put(StateTempResources, r)
+	return r
+}
+
+// Register adds a resource to clean up. name is used to report progress and
+// errors to the user.
+func (r *TempResources) Register(name string, cleanup TempResourceCleanupFunc) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.resources = append(r.resources, tempResource{name: name, cleanup: cleanup})
+}
+
+// Len returns the number of resources waiting to be cleaned up.
+func (r *TempResources) Len() int {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return len(r.resources)
+}
+
+// Cleanup runs and forgets the cleanup callbacks registered so far, the most
+// recent first. A failing callback doesn't stop the others from running,
+// all the errors are returned together.
+func (r *TempResources) Cleanup(ctx context.Context, state multistep.StateBag) error {
+	r.l.Lock()
+	resources := r.resources
+	r.resources = nil
+	r.l.Unlock()
+
+	var errs *multierror.Error
+	for i := len(resources) - 1; i >= 0; i-- {
+		res := resources[i]
+		log.Printf("Cleaning up temporary resource: %s", res.name)
+		if err := res.cleanup(ctx, state); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %w", res.name, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// StepCleanupTempResources runs the cleanup callbacks of the TempResources
+// registry of the build.
+//
+// The step must be the first of the build: a runner only cleans up the steps
+// that ran, so being first guarantees that its Cleanup, which runs the
+// callbacks, is called on every exit path. Run only stores the registry in
+// state.
+//
+// Uses:
+//
+//	ui packersdk.Ui
+//
+// Produces:
+//
+//	temp_resources *TempResources - the registry of the build.
+type StepCleanupTempResources struct{}
+
+func (s *StepCleanupTempResources) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	TempResourcesFromState(state)
+	return multistep.ActionContinue
+}
+
+func (s *StepCleanupTempResources) Cleanup(state multistep.StateBag) {
+	r, ok := state.GetOk(StateTempResources)
+	if !ok || r.(*TempResources).Len() == 0 {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	ui.Say("Cleaning up temporary resources...")
+	if err := r.(*TempResources).Cleanup(context.Background(), state); err != nil {
+		ui.Error(fmt.Sprintf("Error cleaning up temporary resources, please clean them up manually: %s", err))
+	}
+}
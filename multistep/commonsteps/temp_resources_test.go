@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package commonsteps
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCleanupTempResources_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCleanupTempResources)
+}
+
+func TestTempResources_Cleanup(t *testing.T) {
+	state := testState(t)
+	resources := TempResourcesFromState(state)
+	if TempResourcesFromState(state) != resources {
+		t.Fatal("registry should be stored in state")
+	}
+
+	var called []string
+	register := func(name string, err error) {
+		resources.Register(name, func(context.Context, multistep.StateBag) error {
+			called = append(called, name)
+			return err
+		})
+	}
+	register("key pair", nil)
+	register("security group", errors.New("still in use"))
+	register("temp file", errors.New("permission denied"))
+
+	err := resources.Cleanup(context.Background(), state)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	for _, expected := range []string{"security group: still in use", "temp file: permission denied"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("error %q should contain %q", err, expected)
+		}
+	}
+	if diff := cmp.Diff([]string{"temp file", "security group", "key pair"}, called); diff != "" {
+		t.Fatalf("unexpected cleanup order: %s", diff)
+	}
+
+	if resources.Len() != 0 {
+		t.Fatalf("registry should be empty, has %d resources", resources.Len())
+	}
+	if err := resources.Cleanup(context.Background(), state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(called) != 3 {
+		t.Fatalf("callbacks should only run once, got %v", called)
+	}
+}
+
+// registeringStep registers a temporary resource then returns action.
+type registeringStep struct {
+	name   string
+	action multistep.StepAction
+	called *[]string
+}
+
+func (s *registeringStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	TempResourcesFromState(state).Register(s.name, func(context.Context, multistep.StateBag) error {
+		*s.called = append(*s.called, s.name)
+		return nil
+	})
+	if s.action == multistep.ActionHalt {
+		state.Put("error", errors.New("halted"))
+	}
+	return s.action
+}
+
+func (s *registeringStep) Cleanup(multistep.StateBag) {
+	*s.called = append(*s.called, "cleanup "+s.name)
+}
+
+func TestStepCleanupTempResources(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		halt     bool
+		expected []string
+	}{
+		{"completed", false, []string{"cleanup third", "cleanup second", "cleanup first", "third", "second", "first"}},
+		{"halted", true, []string{"cleanup second", "cleanup first", "second", "first"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var called []string
+			second := multistep.ActionContinue
+			if tc.halt {
+				second = multistep.ActionHalt
+			}
+			runner := &multistep.BasicRunner{Steps: []multistep.Step{
+				new(StepCleanupTempResources),
+				&registeringStep{name: "first", action: multistep.ActionContinue, called: &called},
+				&registeringStep{name: "second", action: second, called: &called},
+				&registeringStep{name: "third", action: multistep.ActionContinue, called: &called},
+			}}
+			state := testState(t)
+			runner.Run(context.Background(), state)
+
+			if diff := cmp.Diff(tc.expected, called); diff != "" {
+				t.Fatalf("unexpected calls: %s", diff)
+			}
+			if n := TempResourcesFromState(state).Len(); n != 0 {
+				t.Fatalf("registry should be empty, has %d resources", n)
+			}
+		})
+	}
+}
+
+func TestTempKeysCleanup(t *testing.T) {
+	cases := []struct {
+		name string
+		comm communicator.Config
+		nil  bool
+	}{
+		{"disabled", communicator.Config{Type: "ssh", SSH: communicator.SSH{SSHTemporaryKeyPairName: "packer"}}, true},
+		{"winrm", communicator.Config{Type: "winrm", SSH: communicator.SSH{SSHClearAuthorizedKeys: true, SSHTemporaryKeyPairName: "packer"}}, true},
+		{"no temporary key", communicator.Config{Type: "ssh", SSH: communicator.SSH{SSHClearAuthorizedKeys: true}}, true},
+		{"enabled", communicator.Config{Type: "ssh", SSH: communicator.SSH{SSHClearAuthorizedKeys: true, SSHTemporaryKeyPairName: "packer"}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if cleanup := TempKeysCleanup(&tc.comm); (cleanup == nil) != tc.nil {
+				t.Fatalf("expected nil cleanup: %t", tc.nil)
+			}
+		})
+	}
+}
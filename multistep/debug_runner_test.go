@@ -59,6 +59,21 @@ func TestDebugRunner_Run(t *testing.T) {
 	}
 }
 
+func TestDebugRunner_Run_withDependencies(t *testing.T) {
+	var names []string
+	r := &DebugRunner{
+		Steps: []Step{WithDependencies(&TestStepAcc{Data: "a"}, []string{"data"}, nil)},
+		PauseFn: func(loc DebugLocation, name string, state StateBag) {
+			names = append(names, name)
+		},
+	}
+	r.Run(context.Background(), new(BasicStateBag))
+
+	if expected := []string{"TestStepAcc", "TestStepAcc"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("the steps should be named after the declared step, got %#v", names)
+	}
+}
+
 // confirm that can't run twice
 func TestDebugRunner_Run_Run(t *testing.T) {
 	defer func() {
@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// StepProducer is a Step declaring the keys it puts in the state bag, for
+// ValidateSteps and SortSteps.
+type StepProducer interface {
+	Step
+	Produces() []string
+}
+
+// StepConsumer is a Step declaring the keys of the state bag it requires,
+// for ValidateSteps and SortSteps. The keys a step reads with GetOk, that
+// it doesn't require, are not declared.
+type StepConsumer interface {
+	Step
+	Consumes() []string
+}
+
+// WithDependencies returns step declaring the keys it produces and
+// consumes, for the steps that don't implement StepProducer and
+// StepConsumer, like the ones of other packages.
+func WithDependencies(step Step, produces, consumes []string) Step {
+	return &declaredStep{step: step, produces: produces, consumes: consumes}
+}
+
+type declaredStep struct {
+	step     Step
+	produces []string
+	consumes []string
+}
+
+func (s *declaredStep) Run(ctx context.Context, state StateBag) StepAction {
+	return s.step.Run(ctx, state)
+}
+
+func (s *declaredStep) Cleanup(state StateBag) { s.step.Cleanup(state) }
+
+// InnerStepName returns the name of the step declared, for DebugRunner and
+// the other runners naming their steps.
+func (s *declaredStep) InnerStepName() string {
+	if wrapped, ok := s.step.(StepWrapper); ok {
+		return wrapped.InnerStepName()
+	}
+	return reflect.Indirect(reflect.ValueOf(s.step)).Type().Name()
+}
+
+func (s *declaredStep) Produces() []string {
+	return append(append([]string(nil), produces(s.step)...), s.produces...)
+}
+
+func (s *declaredStep) Consumes() []string {
+	return append(append([]string(nil), consumes(s.step)...), s.consumes...)
+}
+
+// DependencyError is the error of a step consuming a key of the state bag
+// that no step before it produces.
+type DependencyError struct {
+	// Step is the step consuming Key, and Index its index.
+	Step  string
+	Index int
+	Key   string
+	// Producer is the first step producing Key, after Step, empty if no
+	// step produces it.
+	Producer string
+}
+
+func (err *DependencyError) Error() string {
+	if err.Producer == "" {
+		return fmt.Sprintf("step %d %s consumes %q, that no step produces", err.Index, err.Step, err.Key)
+	}
+	return fmt.Sprintf("step %d %s consumes %q, produced by %s after it", err.Index, err.Step, err.Key, err.Producer)
+}
+
+// ValidateSteps returns the *DependencyErrors of the steps consuming keys
+// of the state bag that are neither in initial, the keys put in the state
+// bag before the run, like "ui" or "hook", nor produced by a step before
+// them. Builders call it when they make their steps, to fail fast instead of
+// with a nil interface conversion in the middle of a build.
+func ValidateSteps(steps []Step, initial ...string) error {
+	available := map[string]bool{}
+	for _, k := range initial {
+		available[k] = true
+	}
+
+	var errs *multierror.Error
+	for i, step := range steps {
+		for _, k := range consumes(step) {
+			if available[k] {
+				continue
+			}
+			err := &DependencyError{Step: stepName(step), Index: i, Key: k}
+			if producer := firstProducer(steps[i+1:], k); producer != nil {
+				err.Producer = stepName(producer)
+			}
+			errs = multierror.Append(errs, err)
+		}
+		for _, k := range produces(step) {
+			available[k] = true
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// SortSteps returns steps run in an order in which each step runs after
+// the producers of what it consumes. The order of steps is kept, but for
+// the consumers moved after their producers. An error is returned if a key
+// is consumed but not produced, or for circular dependencies.
+func SortSteps(steps []Step, initial ...string) ([]Step, error) {
+	available := map[string]bool{}
+	known := map[string]bool{}
+	for _, k := range initial {
+		available[k] = true
+		known[k] = true
+	}
+	for _, step := range steps {
+		for _, k := range produces(step) {
+			known[k] = true
+		}
+	}
+
+	var errs *multierror.Error
+	for i, step := range steps {
+		for _, k := range consumes(step) {
+			if !known[k] {
+				errs = multierror.Append(errs, &DependencyError{Step: stepName(step), Index: i, Key: k})
+			}
+		}
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	sorted := make([]Step, 0, len(steps))
+	placed := make([]bool, len(steps))
+	for len(sorted) < len(steps) {
+		next := -1
+		for i, step := range steps {
+			if !placed[i] && ready(step, available) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			var names []string
+			for i, step := range steps {
+				if !placed[i] {
+					names = append(names, stepName(step))
+				}
+			}
+			return nil, fmt.Errorf("circular dependencies between the steps %v", names)
+		}
+		placed[next] = true
+		sorted = append(sorted, steps[next])
+		for _, k := range produces(steps[next]) {
+			available[k] = true
+		}
+	}
+	return sorted, nil
+}
+
+func ready(step Step, available map[string]bool) bool {
+	for _, k := range consumes(step) {
+		if !available[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func firstProducer(steps []Step, key string) Step {
+	for _, step := range steps {
+		for _, k := range produces(step) {
+			if k == key {
+				return step
+			}
+		}
+	}
+	return nil
+}
+
+func produces(step Step) []string {
+	if p, ok := step.(StepProducer); ok {
+		return p.Produces()
+	}
+	return nil
+}
+
+func consumes(step Step) []string {
+	if c, ok := step.(StepConsumer); ok {
+		return c.Consumes()
+	}
+	return nil
+}
+
+// stepName returns the type of step, the one of the step wrapped by
+// WithDependencies included.
+func stepName(step Step) string {
+	if s, ok := step.(*declaredStep); ok {
+		return stepName(s.step)
+	}
+	return fmt.Sprintf("%T", step)
+}
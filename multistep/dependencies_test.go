@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package multistep
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+type testStepDeps struct {
+	nullStep
+	name     string
+	produces []string
+	consumes []string
+}
+
+func (s *testStepDeps) Produces() []string { return s.produces }
+func (s *testStepDeps) Consumes() []string { return s.consumes }
+
+func TestValidateSteps(t *testing.T) {
+	create := &testStepDeps{name: "create", produces: []string{"instance_id"}}
+	connect := &testStepDeps{name: "connect", consumes: []string{"instance_id", "ui"}, produces: []string{"communicator"}}
+	provision := WithDependencies(nullStep{}, nil, []string{"communicator"})
+
+	if err := ValidateSteps([]Step{create, connect, provision}, "ui"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := ValidateSteps([]Step{connect, create, provision})
+	merr, ok := err.(*multierror.Error)
+	if !ok || len(merr.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	var derr *DependencyError
+	if !errors.As(merr.Errors[0], &derr) {
+		t.Fatalf("expected a *DependencyError, got %v", merr.Errors[0])
+	}
+	if derr.Index != 0 || derr.Key != "instance_id" || derr.Producer != "*multistep.testStepDeps" {
+		t.Fatalf("bad error: %#v", derr)
+	}
+	if !strings.Contains(merr.Errors[1].Error(), `"ui", that no step produces`) {
+		t.Fatalf("bad error: %s", merr.Errors[1])
+	}
+}
+
+func TestSortSteps(t *testing.T) {
+	a := &testStepDeps{name: "a", consumes: []string{"y"}}
+	b := &testStepDeps{name: "b", produces: []string{"x"}}
+	c := &testStepDeps{name: "c", consumes: []string{"x"}, produces: []string{"y"}}
+	d := &testStepDeps{name: "d"}
+
+	sorted, err := SortSteps([]Step{a, b, c, d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Step{b, c, a, d}; !reflect.DeepEqual(sorted, expected) {
+		var names []string
+		for _, s := range sorted {
+			names = append(names, s.(*testStepDeps).name)
+		}
+		t.Fatalf("bad order: %v", names)
+	}
+	if err := ValidateSteps(sorted); err != nil {
+		t.Fatalf("sorted steps should be valid: %s", err)
+	}
+
+	if _, err := SortSteps([]Step{a}); err == nil {
+		t.Fatal("expected an error for a key not produced")
+	}
+	e := &testStepDeps{name: "e", consumes: []string{"y"}, produces: []string{"x"}}
+	if _, err := SortSteps([]Step{c, e}); err == nil || !strings.Contains(err.Error(), "circular") {
+		t.Fatalf("expected a circular dependency error, got %v", err)
+	}
+}
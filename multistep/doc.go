@@ -62,5 +62,23 @@ Value is 0
 Value is 1
 Value is 2
 ```
+
+## Dependencies
+
+Steps can declare the keys of the state they put and require, by
+implementing StepProducer and StepConsumer, or with WithDependencies.
+ValidateSteps then checks, when the steps are made, that no step requires a
+key before a step puts it, and SortSteps orders the steps so that it can't
+happen.
+
+```go
+
+	func (s *stepAdd) Consumes() []string { return []string{"value"} }
+
+	if err := multistep.ValidateSteps(steps, "value"); err != nil {
+	    return err
+	}
+
+```
 */
 package multistep
@@ -3,7 +3,10 @@
 
 package multistep
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // Add context to state bag to prevent changing step signature
 
@@ -37,6 +40,19 @@ func (b *BasicStateBag) GetOk(k string) (interface{}, bool) {
 	return result, ok
 }
 
+// Keys returns the sorted list of the keys stored in the state bag.
+func (b *BasicStateBag) Keys() []string {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	keys := make([]string, 0, len(b.data))
+	for k := range b.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (b *BasicStateBag) Put(k string, v interface{}) {
 	b.l.Lock()
 	defer b.l.Unlock()
@@ -30,3 +30,18 @@ func TestBasicStateBag(t *testing.T) {
 		t.Fatalf("bad")
 	}
 }
+
+func TestBasicStateBag_Keys(t *testing.T) {
+	b := new(BasicStateBag)
+	if keys := b.Keys(); len(keys) != 0 {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	b.Put("foo", "bar")
+	b.Put("bar", 42)
+
+	keys := b.Keys()
+	if len(keys) != 2 || keys[0] != "bar" || keys[1] != "foo" {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
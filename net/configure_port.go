@@ -9,122 +9,103 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math/rand"
 	"net"
 	"os"
-	"strconv"
-	"time"
-
-	"github.com/hashicorp/packer-plugin-sdk/filelock"
-	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/hashicorp/packer-plugin-sdk/retry"
 )
 
 var _ net.Listener = &Listener{}
 
 // Listener wraps a net.Lister with some Packer-specific capabilies. For
 // example, until you call Listener.Close, any call to ListenRangeConfig.Listen
-// cannot bind to a Port. Packer tries to tell moving parts which port they can
-// use, but often the port has to be released before a 3rd party is started,
-// like a VNC server.
+// cannot bind to a Port, which is held by a PortReservation. Packer tries to
+// tell moving parts which port they can use, but often the port has to be
+// released before a 3rd party is started, like a VNC server.
+//
+// A Listener can also listen to a unix socket, or to a socket inherited from
+// the parent process, like with systemd socket activation; Port is then 0,
+// unless the inherited socket is a TCP one.
 type Listener struct {
 	// Listener can be closed but Port will be file locked by packer until
 	// Close is called.
 	net.Listener
-	Port        int
-	Address     string
-	lock        *filelock.Flock
-	cleanupFunc func() error
+	Port int
+	// Address is the address of the listener, the path of the socket for a
+	// unix socket.
+	Address string
+	// Network is the network of the listener, like "tcp" or "unix".
+	Network     string
+	reservation *PortReservation
 }
 
 func (l *Listener) Close() error {
-	err := l.lock.Unlock()
-	if err != nil {
-		log.Printf("cannot unlock lockfile %#v: %v", l, err)
-	}
-	err = l.Listener.Close()
-	if err != nil {
-		return err
-	}
-
-	if l.cleanupFunc != nil {
-		err := l.cleanupFunc()
-		if err != nil {
-			log.Printf("cannot cleanup: %#v", err)
+	if l.reservation != nil {
+		if err := l.reservation.Release(); err != nil {
+			log.Printf("cannot unlock lockfile %#v: %v", l, err)
 		}
 	}
-	return nil
+	return l.Listener.Close()
 }
 
 // ListenRangeConfig contains options for listening to a free address [Min,Max)
 // range. ListenRangeConfig wraps a net.ListenConfig.
 type ListenRangeConfig struct {
-	// like "tcp" or "udp". defaults to "tcp".
+	// like "tcp" or "udp". defaults to "tcp". With "unix" or "unixpacket",
+	// Addr is the path of the socket, and Min and Max are ignored.
 	Network  string
 	Addr     string
 	Min, Max int
+	// File, if set, is a listening socket inherited from the parent
+	// process, listened to rather than opening a new one.
+	File *os.File
+	// SystemdSocket, if set, is the name of a socket passed by systemd
+	// socket activation, as set with FileDescriptorName in the .socket unit,
+	// listened to rather than opening a new one. "*" is the first socket
+	// passed. See SystemdSockets.
+	SystemdSocket string
 	net.ListenConfig
 }
 
 // Listen tries to Listen to a random open TCP port in the [min, max) range
-// until ctx is cancelled.
+// until ctx is cancelled. It listens to the unix socket, the inherited
+// socket or the systemd socket of lc instead when they are set.
 // Listen uses net.ListenConfig.Listen internally.
 func (lc ListenRangeConfig) Listen(ctx context.Context) (*Listener, error) {
+	switch {
+	case lc.File != nil:
+		return fileListener(lc.File)
+	case lc.SystemdSocket != "":
+		f, err := systemdSocket(lc.SystemdSocket)
+		if err != nil {
+			return nil, err
+		}
+		return fileListener(f)
+	}
 	if lc.Network == "" {
 		lc.Network = "tcp"
 	}
-	portRange := lc.Max - lc.Min
-
+	if lc.Network == "unix" || lc.Network == "unixpacket" {
+		return lc.listenUnix(ctx)
+	}
 	var listener *Listener
-
-	err := retry.Config{
-		RetryDelay: func() time.Duration { return 1 * time.Millisecond },
-	}.Run(ctx, func(context.Context) error {
-		port := lc.Min
-		if portRange > 0 {
-			port += rand.Intn(portRange)
-		}
-
-		lockFilePath, err := packersdk.CachePath("port", strconv.Itoa(port))
+	_, err := lc.reserve(ctx, func(r *PortReservation) error {
+		l, err := lc.ListenConfig.Listen(ctx, lc.Network, net.JoinHostPort(lc.Addr, fmt.Sprint(r.Port)))
 		if err != nil {
 			return err
 		}
-
-		lock := filelock.New(lockFilePath)
-		locked, err := lock.TryLock()
-		if err != nil {
-			return err
-		}
-		if !locked {
-			return ErrPortFileLocked(port)
-		}
-
-		l, err := lc.ListenConfig.Listen(ctx, lc.Network, net.JoinHostPort(lc.Addr, fmt.Sprint(port)))
-		if err != nil {
-			if err := lock.Unlock(); err != nil {
-				log.Fatalf("Could not unlock file lock for port %d: %v", port, err)
-			}
-			return &ErrPortBusy{
-				Port: port,
-				Err:  err,
-			}
-		}
-
-		cleanupFunc := func() error {
-			return os.Remove(lockFilePath)
-		}
-
-		log.Printf("Found available port: %d on IP: %s", port, lc.Addr)
+		log.Printf("Found available port: %d on IP: %s", r.Port, lc.Addr)
 		listener = &Listener{
 			Address:     lc.Addr,
-			Port:        port,
+			Port:        r.Port,
+			Network:     lc.Network,
 			Listener:    l,
-			lock:        lock,
-			cleanupFunc: cleanupFunc,
+			reservation: r,
 		}
 		return nil
 	})
-	return listener, err
+	if err != nil {
+		return nil, err
+	}
+	return listener, nil
 }
 
 type ErrPortFileLocked int
@@ -130,7 +130,7 @@ func TestListenRangeConfig_Listen(t *testing.T) {
 
 	}
 
-	if err := lockedListener.lock.Unlock(); err != nil {
+	if err := lockedListener.reservation.Release(); err != nil {
 		t.Fatalf("error closing port: %v", err)
 	}
 
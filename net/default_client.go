@@ -7,6 +7,9 @@ import (
 	"net/http"
 )
 
+// HttpClientWithEnvironmentProxy returns a client using the proxy of the
+// environment. See NewHTTPClient for a client with the timeouts, retries and
+// User-Agent of the SDK.
 func HttpClientWithEnvironmentProxy() *http.Client {
 	httpClient := &http.Client{
 		Transport: &http.Transport{
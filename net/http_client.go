@@ -0,0 +1,275 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+	"github.com/hashicorp/packer-plugin-sdk/useragent"
+	"github.com/hashicorp/packer-plugin-sdk/version"
+)
+
+// The defaults of HTTPClientConfig.
+const (
+	DefaultHTTPDialTimeout           = 30 * time.Second
+	DefaultHTTPTLSHandshakeTimeout   = 10 * time.Second
+	DefaultHTTPResponseHeaderTimeout = 60 * time.Second
+	DefaultHTTPTries                 = 4
+	DefaultHTTPTLSMinVersion         = tls.VersionTLS12
+)
+
+// HTTPClientConfig configures the clients returned by NewHTTPClient. The zero
+// value is a client with the defaults of the SDK, for the metadata and
+// download calls of the plugins.
+type HTTPClientConfig struct {
+	// CAFile is a PEM bundle of certificate authorities trusted besides the
+	// ones of the system, like the one of a corporate proxy.
+	CAFile string
+	// CAPEM are PEM certificate authorities trusted besides the ones of the
+	// system and of CAFile.
+	CAPEM []byte
+	// TLSMinVersion is the minimum TLS version, like tls.VersionTLS13,
+	// DefaultHTTPTLSMinVersion by default.
+	TLSMinVersion uint16
+	// InsecureSkipVerify disables the verification of the certificates of
+	// the servers.
+	InsecureSkipVerify bool
+
+	// Timeout is the time limit of a request, its retries included, reading
+	// the body of the response included. 0 means no limit, for downloads.
+	Timeout time.Duration
+	// DialTimeout is the time limit of a connection, DefaultHTTPDialTimeout
+	// by default.
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout is the time limit of a TLS handshake,
+	// DefaultHTTPTLSHandshakeTimeout by default.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout is the time waited for the headers of a
+	// response once the request is sent, DefaultHTTPResponseHeaderTimeout by
+	// default.
+	ResponseHeaderTimeout time.Duration
+
+	// Tries is the number of times the requests that can be sent again,
+	// like GET ones, are tried when they fail with a retryable error of
+	// retry.DefaultRegistry, like 503 statuses or connections reset.
+	// DefaultHTTPTries by default, 1 disables the retries.
+	Tries int
+	// RetryStrategy is the time waited between the tries, exponential from
+	// 1s to 30s with jitter by default.
+	RetryStrategy retry.Strategy
+
+	// UserAgent is the User-Agent header of the requests that don't set
+	// one, useragent.String of the version of the SDK by default, with the
+	// product tokens registered by the plugin with useragent.Register.
+	UserAgent string
+}
+
+// NewHTTPClient returns an HTTP client configured by c. It uses the proxy of
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func NewHTTPClient(c HTTPClientConfig) (*http.Client, error) {
+	transport, err := c.Transport()
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = transport
+	tries := c.Tries
+	if tries == 0 {
+		tries = DefaultHTTPTries
+	}
+	if tries > 1 {
+		strategy := c.RetryStrategy
+		if strategy == nil {
+			strategy = retry.ExponentialJitter(time.Second, 30*time.Second)
+		}
+		rt = &retryTransport{base: rt, tries: tries, strategy: strategy}
+	}
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = useragent.String(version.SDKVersion.FormattedVersion())
+	}
+	rt = &userAgentTransport{base: rt, userAgent: userAgent}
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   c.Timeout,
+	}, nil
+}
+
+// Transport returns the transport of the clients configured by c, without
+// retries nor User-Agent, for the SDKs taking a transport.
+func (c HTTPClientConfig) Transport() (*http.Transport, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout := c.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultHTTPDialTimeout
+	}
+	tlsHandshakeTimeout := c.TLSHandshakeTimeout
+	if tlsHandshakeTimeout == 0 {
+		tlsHandshakeTimeout = DefaultHTTPTLSHandshakeTimeout
+	}
+	responseHeaderTimeout := c.ResponseHeaderTimeout
+	if responseHeaderTimeout == 0 {
+		responseHeaderTimeout = DefaultHTTPResponseHeaderTimeout
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}, nil
+}
+
+func (c HTTPClientConfig) tlsConfig() (*tls.Config, error) {
+	minVersion := c.TLSMinVersion
+	if minVersion == 0 {
+		minVersion = DefaultHTTPTLSMinVersion
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         minVersion,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile == "" && len(c.CAPEM) == 0 {
+		return tlsConfig, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %s", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", c.CAFile)
+		}
+	}
+	if len(c.CAPEM) != 0 && !pool.AppendCertsFromPEM(c.CAPEM) {
+		return nil, fmt.Errorf("no certificate found in CA PEM")
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// userAgentTransport sets the User-Agent of the requests that don't set one.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") != "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+// statusError is the error of a response with a failed status, classified
+// by retry.ClassifyHTTPStatus.
+type statusError int
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("status %d %s", int(err), http.StatusText(int(err)))
+}
+
+func (err statusError) StatusCode() int { return int(err) }
+
+// retryTransport tries the idempotent requests again when they fail with a
+// retryable error. The response of the last try is returned, even if its
+// status is a failed one.
+type retryTransport struct {
+	base     http.RoundTripper
+	tries    int
+	strategy retry.Strategy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !retryable(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	var resp *http.Response
+	err := retry.Config{
+		Tries:       t.tries,
+		Strategy:    t.strategy,
+		ShouldRetry: retry.DefaultRegistry.ShouldRetry,
+	}.Run(req.Context(), func(ctx context.Context) error {
+		if resp != nil {
+			drain(resp)
+			resp = nil
+		}
+		try := req
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			try = req.Clone(ctx)
+			try.Body = body
+		}
+		r, err := t.base.RoundTrip(try)
+		if err != nil {
+			return err
+		}
+		resp = r
+		if r.StatusCode >= 400 {
+			return statusError(r.StatusCode)
+		}
+		return nil
+	})
+	if resp != nil {
+		// The last try ended with a response, which is the answer, even
+		// with a failed status.
+		return resp, nil
+	}
+	if aerr, ok := err.(*retry.AttemptError); ok {
+		err = aerr.Err
+	}
+	if rerr, ok := err.(*retry.RetryExhaustedError); ok {
+		err = rerr.Err
+	}
+	return nil, err
+}
+
+// retryable returns whether req can be sent again: it is idempotent and its
+// body, if any, can be read again.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// drain reads the body of resp, up to a limit, so that its connection can be
+// reused, and closes it.
+func drain(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	resp.Body.Close()
+}
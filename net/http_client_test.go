@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func noDelay(int, time.Duration) time.Duration { return time.Millisecond }
+
+func TestNewHTTPClient_retries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer srv.Close()
+
+	c, err := NewHTTPClient(HTTPClientConfig{RetryStrategy: noDelay, UserAgent: "test/1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("expected a success on the third try, got %d after %d tries", resp.StatusCode, calls)
+	}
+	if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "test/1.0" {
+		t.Fatalf("unexpected user agent %q: %v", b, err)
+	}
+
+	// the last failed response is returned once the tries are exhausted,
+	// and the requests that can't be sent again aren't
+	atomic.StoreInt32(&calls, -10)
+	resp, err = c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get failed: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls != -10+DefaultHTTPTries {
+		t.Fatalf("expected %d tries ending with a 503, got %d and %d", DefaultHTTPTries, resp.StatusCode, calls)
+	}
+	atomic.StoreInt32(&calls, 0)
+	resp, err = c.Post(srv.URL, "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("post failed: %s", err)
+	}
+	resp.Body.Close()
+	if calls != 1 {
+		t.Fatalf("a POST should not be retried, got %d tries", calls)
+	}
+}
+
+func TestNewHTTPClient_CA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c, err := NewHTTPClient(HTTPClientConfig{Tries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(srv.URL); err == nil {
+		t.Fatal("the certificate of the server should not be trusted")
+	}
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	c, err = NewHTTPClient(HTTPClientConfig{CAPEM: ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("the certificate of the server should be trusted: %s", err)
+	}
+	resp.Body.Close()
+
+	if _, err := NewHTTPClient(HTTPClientConfig{CAPEM: []byte("nope")}); err == nil {
+		t.Fatal("expected an error for an invalid CA")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation.
+const listenFDsStart = 3
+
+var (
+	systemdSocketsOnce sync.Once
+	systemdSockets     []*os.File
+)
+
+// SystemdSockets returns the sockets passed to the process by systemd socket
+// activation, named after their FileDescriptorName, or nil if there are
+// none. See sd_listen_fds(3).
+func SystemdSockets() []*os.File {
+	systemdSocketsOnce.Do(func() {
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		for i := 0; i < n; i++ {
+			name := "unknown"
+			if i < len(names) && names[i] != "" {
+				name = names[i]
+			}
+			systemdSockets = append(systemdSockets, os.NewFile(uintptr(listenFDsStart+i), name))
+		}
+	})
+	return systemdSockets
+}
+
+// systemdSocket returns the socket passed by systemd named name, or the
+// first one for "*".
+func systemdSocket(name string) (*os.File, error) {
+	sockets := SystemdSockets()
+	for _, f := range sockets {
+		if name == "*" || f.Name() == name {
+			return f, nil
+		}
+	}
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("no socket passed by systemd socket activation")
+	}
+	return nil, fmt.Errorf("no socket named %q passed by systemd socket activation", name)
+}
+
+// fileListener returns a Listener listening to the socket of f, which stays
+// open.
+func fileListener(f *os.File) (*Listener, error) {
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("file %s is not a listening socket: %s", f.Name(), err)
+	}
+	listener := &Listener{
+		Listener: l,
+		Address:  l.Addr().String(),
+		Network:  l.Addr().Network(),
+	}
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		listener.Address = addr.IP.String()
+		listener.Port = addr.Port
+	}
+	log.Printf("Listening to inherited socket %s: %s %s", f.Name(), listener.Network, l.Addr())
+	return listener, nil
+}
+
+// listenUnix listens to the unix socket at lc.Addr. A stale socket, that
+// nothing listens to anymore, is removed first.
+func (lc ListenRangeConfig) listenUnix(ctx context.Context) (*Listener, error) {
+	if lc.Addr == "" {
+		return nil, fmt.Errorf("the path of the %s socket is not set", lc.Network)
+	}
+	if fi, err := os.Lstat(lc.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout(lc.Network, lc.Addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is already in use", lc.Addr)
+		}
+		log.Printf("Removing stale socket %s", lc.Addr)
+		if err := os.Remove(lc.Addr); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := lc.ListenConfig.Listen(ctx, lc.Network, lc.Addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Listening to socket %s", lc.Addr)
+	return &Listener{
+		Listener: l,
+		Address:  lc.Addr,
+		Network:  lc.Network,
+	}, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListenRangeConfig_Listen_unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not tested on windows")
+	}
+	path := filepath.Join(t.TempDir(), "http.sock")
+
+	l, err := ListenRangeConfig{Network: "unix", Addr: path}.Listen(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l.Address != path || l.Network != "unix" || l.Port != 0 {
+		t.Fatalf("bad listener: %#v", l)
+	}
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn.Close()
+
+	if _, err := (ListenRangeConfig{Network: "unix", Addr: path}).Listen(context.Background()); err == nil {
+		t.Fatalf("the socket is in use, this should fail")
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestListenRangeConfig_Listen_staleUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not tested on windows")
+	}
+	path := filepath.Join(t.TempDir(), "http.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// Leave the socket file behind, like a crashed process would.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := ListenRangeConfig{Network: "unix", Addr: path}.Listen(context.Background())
+	if err != nil {
+		t.Fatalf("the stale socket should be replaced: %s", err)
+	}
+	l.Close()
+}
+
+func TestListenRangeConfig_Listen_file(t *testing.T) {
+	inherited, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer inherited.Close()
+	f, err := inherited.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	l, err := ListenRangeConfig{File: f}.Listen(context.Background())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	if l.Port != inherited.Addr().(*net.TCPAddr).Port || l.Network != "tcp" {
+		t.Fatalf("bad listener: %#v", l)
+	}
+
+	if _, err := (ListenRangeConfig{File: os.Stdin}).Listen(context.Background()); err == nil {
+		t.Fatalf("stdin is not a socket, this should fail")
+	}
+}
+
+func TestListenRangeConfig_Listen_noSystemdSocket(t *testing.T) {
+	if _, err := (ListenRangeConfig{SystemdSocket: "http"}).Listen(context.Background()); err == nil {
+		t.Fatalf("no socket was passed, this should fail")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
+
+// The ports reserved by this process. The lock files are enough across
+// processes, this also covers the platforms where filelock does nothing.
+var (
+	reservedPortsMu sync.Mutex
+	reservedPorts   = map[int]*PortReservation{}
+)
+
+// PortReservation is a port reserved, until Release is called, across the
+// builds of all the Packer processes of the host sharing a cache directory,
+// like parallel builds run by Packer and its plugins. A port found free can
+// be taken by a parallel build before it is bound; reserving it first, from
+// a range overlapping theirs, like the VNC or HTTP server ports, prevents
+// it.
+//
+// A reservation is a lock file of the "port" directory of the cache. The
+// lock is released by the system if the process dies.
+type PortReservation struct {
+	Port int
+	lock *filelock.Flock
+}
+
+// ReservePort reserves port, or returns an ErrPortFileLocked if it is
+// already reserved.
+func ReservePort(port int) (*PortReservation, error) {
+	reservedPortsMu.Lock()
+	defer reservedPortsMu.Unlock()
+	if reservedPorts[port] != nil {
+		return nil, ErrPortFileLocked(port)
+	}
+
+	lockFilePath, err := packersdk.CachePath("port", strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+	lock := filelock.New(lockFilePath)
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrPortFileLocked(port)
+	}
+	r := &PortReservation{Port: port, lock: lock}
+	reservedPorts[port] = r
+	return r, nil
+}
+
+// Release releases the reservation of the port. The lock file is kept: a
+// process could have opened it before its removal, and lock it while
+// another one locks a new lock file for the same port.
+func (r *PortReservation) Release() error {
+	reservedPortsMu.Lock()
+	defer reservedPortsMu.Unlock()
+	if reservedPorts[r.Port] != r {
+		return nil
+	}
+	delete(reservedPorts, r.Port)
+	return r.lock.Unlock()
+}
+
+// Reserve reserves a random open TCP port in the [min, max) range until ctx
+// is cancelled. Unlike with Listen, the port isn't left bound, so that a
+// third party, like a VNC server, can bind it; release the reservation once
+// it did, or once done with the port.
+func (lc ListenRangeConfig) Reserve(ctx context.Context) (*PortReservation, error) {
+	if lc.Network == "" {
+		lc.Network = "tcp"
+	}
+	return lc.reserve(ctx, func(r *PortReservation) error {
+		l, err := lc.ListenConfig.Listen(ctx, lc.Network, net.JoinHostPort(lc.Addr, fmt.Sprint(r.Port)))
+		if err != nil {
+			return err
+		}
+		return l.Close()
+	})
+}
+
+// reserve reserves a random port of the range of lc, for which try
+// succeeds, until ctx is cancelled. The port is released if try fails, which
+// then returns an ErrPortBusy.
+func (lc ListenRangeConfig) reserve(ctx context.Context, try func(*PortReservation) error) (*PortReservation, error) {
+	portRange := lc.Max - lc.Min
+
+	var reservation *PortReservation
+
+	err := retry.Config{
+		RetryDelay: func() time.Duration { return 1 * time.Millisecond },
+	}.Run(ctx, func(context.Context) error {
+		port := lc.Min
+		if portRange > 0 {
+			port += rand.Intn(portRange)
+		}
+
+		r, err := ReservePort(port)
+		if err != nil {
+			return err
+		}
+		if err := try(r); err != nil {
+			if err := r.Release(); err != nil {
+				log.Fatalf("Could not unlock file lock for port %d: %v", port, err)
+			}
+			return &ErrPortBusy{
+				Port: port,
+				Err:  err,
+			}
+		}
+		reservation = r
+		return nil
+	})
+	return reservation, err
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package net
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestListenRangeConfig_Reserve(t *testing.T) {
+	t.Setenv("PACKER_CACHE_DIR", t.TempDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := ListenRangeConfig{Min: 8000, Max: 9000, Addr: "localhost"}.Reserve(ctx)
+	if err != nil {
+		t.Fatalf("could not reserve a port: %v", err)
+	}
+	defer r.Release()
+
+	// the port isn't bound, a third party can listen to it
+	l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(r.Port)))
+	if err != nil {
+		t.Fatalf("reserved port should be free: %v", err)
+	}
+	l.Close()
+
+	if _, err := ReservePort(r.Port); err != ErrPortFileLocked(r.Port) {
+		t.Fatalf("reserved port should be locked, got %v", err)
+	}
+	lctx, lcancel := context.WithTimeout(ctx, 250*time.Millisecond)
+	defer lcancel()
+	if l, err := (ListenRangeConfig{Min: r.Port, Addr: "localhost"}).Listen(lctx); err == nil {
+		l.Close()
+		t.Fatal("reserved port should not be listened to")
+	}
+
+	if err := r.Release(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	r2, err := ReservePort(r.Port)
+	if err != nil {
+		t.Fatalf("released port should be reservable: %v", err)
+	}
+	// releasing a stale reservation leaves the new one
+	if err := r.Release(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if _, err := ReservePort(r.Port); err == nil {
+		t.Fatal("port should still be reserved")
+	}
+	if err := r2.Release(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+}
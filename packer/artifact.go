@@ -3,6 +3,13 @@
 
 package packer
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
 // An Artifact is the result of a build, and is the metadata that documents
 // what a builder actually created. The exact meaning of the contents is
 // specific to each builder, but this interface is used to communicate back
@@ -37,3 +44,88 @@ type Artifact interface {
 	// no longer needed.
 	Destroy() error
 }
+
+// ArtifactMetadata is the structured metadata of an artifact, for the
+// post-processors and the HCP Packer registry.
+type ArtifactMetadata struct {
+	// CreatedAt is the time the artifact was created.
+	CreatedAt time.Time
+	// Size is the size of the artifact in bytes, or 0 if unknown.
+	Size int64
+	// Checksums maps hash algorithms, like "sha256", to the hexadecimal
+	// checksum of the artifact.
+	Checksums map[string]string
+	// Plugin and PluginVersion are the name and version of the plugin that
+	// created the artifact.
+	Plugin        string
+	PluginVersion string
+	// Labels are additional details about the artifact.
+	Labels map[string]string
+}
+
+// ArtifactV2 is an Artifact with structured metadata. Use AsArtifactV2 to get
+// the metadata of any artifact.
+type ArtifactV2 interface {
+	Artifact
+
+	// Metadata returns the metadata of the artifact.
+	Metadata() ArtifactMetadata
+}
+
+// AsArtifactV2 returns a as an ArtifactV2. The metadata of artifacts not
+// implementing ArtifactV2 is derived from their files: their size is the sum
+// of the sizes of the files, and their creation time is the latest
+// modification time of the files. Their plugin is their builder ID.
+func AsArtifactV2(a Artifact) ArtifactV2 {
+	if v2, ok := a.(ArtifactV2); ok {
+		return v2
+	}
+	return &legacyArtifact{a}
+}
+
+type legacyArtifact struct {
+	Artifact
+}
+
+func (a *legacyArtifact) Metadata() ArtifactMetadata {
+	md := ArtifactMetadata{
+		Plugin: a.BuilderId(),
+	}
+	for _, f := range a.Files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		md.Size += fi.Size()
+		if fi.ModTime().After(md.CreatedAt) {
+			md.CreatedAt = fi.ModTime()
+		}
+	}
+	return md
+}
+
+// ArtifactFileOpener is an Artifact whose files can be read without access
+// to the filesystem they are on, like the artifacts of a builder run by
+// Packer for a post-processor running in a container or on another host.
+// Use OpenArtifactFile to read the files of any artifact.
+type ArtifactFileOpener interface {
+	Artifact
+
+	// OpenFile opens name, one of the files of the artifact, for reading.
+	OpenFile(name string) (io.ReadCloser, error)
+}
+
+// OpenArtifactFile opens name, one of the files of a, for reading: with the
+// OpenFile method of a if it is an ArtifactFileOpener, or from the
+// filesystem otherwise.
+func OpenArtifactFile(a Artifact, name string) (io.ReadCloser, error) {
+	if opener, ok := a.(ArtifactFileOpener); ok {
+		return opener.OpenFile(name)
+	}
+	for _, f := range a.Files() {
+		if f == name {
+			return os.Open(name)
+		}
+	}
+	return nil, fmt.Errorf("%s is not a file of artifact %s", name, a.Id())
+}
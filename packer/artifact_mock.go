@@ -11,8 +11,11 @@ type MockArtifact struct {
 	StateValues    map[string]interface{}
 	DestroyCalled  bool
 	StringValue    string
+	MetadataValue  ArtifactMetadata
 }
 
+var _ ArtifactV2 = new(MockArtifact)
+
 func (a *MockArtifact) BuilderId() string {
 	if a.BuilderIdValue == "" {
 		return "bid"
@@ -55,3 +58,7 @@ func (a *MockArtifact) Destroy() error {
 	a.DestroyCalled = true
 	return nil
 }
+
+func (a *MockArtifact) Metadata() ArtifactMetadata {
+	return a.MetadataValue
+}
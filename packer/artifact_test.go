@@ -3,6 +3,14 @@
 
 package packer
 
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
 type TestArtifact struct {
 	id            string
 	state         map[string]interface{}
@@ -39,3 +47,63 @@ func (a *TestArtifact) Destroy() error {
 	a.destroyCalled = true
 	return nil
 }
+
+func TestAsArtifactV2(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	if err := os.WriteFile(a, make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, make([]byte, 5), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(b, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	latest := modTime.Add(time.Hour)
+	if err := os.Chtimes(a, latest, latest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hide the Metadata method of the mock.
+	legacy := struct{ Artifact }{&MockArtifact{FilesValue: []string{a, b, filepath.Join(dir, "missing")}}}
+	md := AsArtifactV2(legacy).Metadata()
+	if md.Size != 15 {
+		t.Fatalf("bad size: %d", md.Size)
+	}
+	if md.Plugin != "bid" {
+		t.Fatalf("bad plugin: %s", md.Plugin)
+	}
+	if !md.CreatedAt.Equal(latest) {
+		t.Fatalf("bad creation time: %s", md.CreatedAt)
+	}
+
+	v2 := &MockArtifact{MetadataValue: ArtifactMetadata{Size: 42}}
+	if AsArtifactV2(v2) != ArtifactV2(v2) {
+		t.Fatal("expected the artifact to be returned")
+	}
+}
+
+func TestOpenArtifactFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := &MockArtifact{FilesValue: []string{path}}
+
+	f, err := OpenArtifactFile(a, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "image" {
+		t.Fatalf("bad content %q: %v", b, err)
+	}
+
+	if _, err := OpenArtifactFile(a, filepath.Join(filepath.Dir(path), "other")); err == nil {
+		t.Fatal("expected an error for a file not of the artifact")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+/*
+Package attestation allows post-processors to produce signed provenance
+attestations of artifacts, for supply chain verification tools.
+
+An attestation is an in-toto statement with a SLSA provenance predicate. Its
+subjects are the files of the artifact, with their digests, and its predicate
+describes the build with the digest of the configuration of the builder. It is
+signed into a DSSE envelope, with a key or a KMS:
+
+	st, err := attestation.NewStatement(artifact, attestation.BuildInfo{
+		Config: b.config,
+	})
+	if err != nil {
+		return err
+	}
+	env, err := attestation.Sign(ctx, st, attestation.NewKeySigner(key, "my-key"))
+*/
+package attestation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	packerjson "github.com/hashicorp/packer-plugin-sdk/json"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	// StatementType is the type of in-toto statements.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// ProvenancePredicateType is the type of SLSA provenance predicates.
+	ProvenancePredicateType = "https://slsa.dev/provenance/v1"
+	// BuildType is the type of the builds of Packer.
+	BuildType = "https://developer.hashicorp.com/packer/attestation/build/v1"
+)
+
+// Statement is an in-toto statement of the provenance of an artifact.
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is a file of an artifact, or the artifact itself when it isn't made
+// of files, and its digests.
+type Subject struct {
+	Name string `json:"name"`
+	// Digest maps hash algorithms, like "sha256", to hexadecimal digests.
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is a SLSA provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes the inputs of a build.
+type BuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+}
+
+// ResourceDescriptor is an artifact a build depends on, like a source image.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// RunDetails describes the run of a build.
+type RunDetails struct {
+	Builder  Builder        `json:"builder"`
+	Metadata *BuildMetadata `json:"metadata,omitempty"`
+}
+
+// Builder is the plugin that ran a build.
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+// BuildMetadata holds the details of the run of a build.
+type BuildMetadata struct {
+	InvocationID string     `json:"invocationId,omitempty"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// BuildInfo describes the build of an artifact to NewStatement.
+type BuildInfo struct {
+	// Name is the name of the build.
+	Name string
+	// Config is the configuration of the builder. Only its digest is part of
+	// the statement, so that secrets don't leak.
+	Config interface{}
+	// Dependencies are the artifacts the build depends on, like its source
+	// image.
+	Dependencies []ResourceDescriptor
+	// InvocationID identifies the run of the build, like PackerRunUUID.
+	InvocationID string
+	// StartedOn is the time the build started, if known.
+	StartedOn time.Time
+}
+
+// NewStatement returns the provenance statement of artifact. The subjects are
+// the files of the artifact; an artifact without files, like a cloud image, is
+// the subject itself, named after its ID, with the checksums of its
+// metadata.
+func NewStatement(artifact packersdk.Artifact, build BuildInfo) (*Statement, error) {
+	md := packersdk.AsArtifactV2(artifact).Metadata()
+
+	subjects, err := HashFiles(artifact.Files()...)
+	if err != nil {
+		return nil, err
+	}
+	if len(subjects) == 0 {
+		if len(md.Checksums) == 0 {
+			return nil, fmt.Errorf("artifact %s has neither files nor checksums to attest", artifact.Id())
+		}
+		subjects = append(subjects, Subject{Name: artifact.Id(), Digest: md.Checksums})
+	}
+
+	params := map[string]interface{}{
+		"builderId": artifact.BuilderId(),
+	}
+	if build.Name != "" {
+		params["buildName"] = build.Name
+	}
+	if build.Config != nil {
+		digest, err := ConfigDigest(build.Config)
+		if err != nil {
+			return nil, err
+		}
+		params["configDigest"] = digest
+	}
+
+	builder := Builder{ID: md.Plugin}
+	if builder.ID == "" {
+		builder.ID = artifact.BuilderId()
+	}
+	if md.PluginVersion != "" {
+		builder.Version = map[string]string{builder.ID: md.PluginVersion}
+	}
+
+	finished := time.Now().UTC()
+	metadata := &BuildMetadata{
+		InvocationID: build.InvocationID,
+		FinishedOn:   &finished,
+	}
+	if !build.StartedOn.IsZero() {
+		started := build.StartedOn.UTC()
+		metadata.StartedOn = &started
+	}
+
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: ProvenancePredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType:            BuildType,
+				ExternalParameters:   params,
+				ResolvedDependencies: build.Dependencies,
+			},
+			RunDetails: RunDetails{
+				Builder:  builder,
+				Metadata: metadata,
+			},
+		},
+	}, nil
+}
+
+// HashFiles returns the subjects of files, named after their base name, with
+// their sha256 digest.
+func HashFiles(files ...string) ([]Subject, error) {
+	subjects := make([]Subject, 0, len(files))
+	for _, path := range files {
+		digest, err := hashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, Subject{
+			Name:   filepath.Base(path),
+			Digest: map[string]string{"sha256": digest},
+		})
+	}
+	return subjects, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %s", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %s", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ConfigDigest returns the sha256 digest of the JSON encoding of config, a
+// configuration of a builder. The encoding is the canonical one of
+// json.MarshalCanonical, so that the digest is stable.
+func ConfigDigest(config interface{}) (map[string]string, error) {
+	b, err := packerjson.MarshalCanonical(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the configuration: %s", err)
+	}
+	sum := sha256.Sum256(b)
+	return map[string]string{"sha256": hex.EncodeToString(sum[:])}, nil
+}
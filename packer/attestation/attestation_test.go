@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package attestation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestNewStatement(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &packersdk.MockArtifact{
+		FilesValue: []string{file},
+		MetadataValue: packersdk.ArtifactMetadata{
+			Plugin:        "packer-plugin-happycloud",
+			PluginVersion: "1.2.3",
+		},
+	}
+	st, err := NewStatement(a, BuildInfo{
+		Name:   "happycloud.ubuntu",
+		Config: map[string]string{"region": "eu"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(st.Subject) != 1 || st.Subject[0].Name != "disk.img" {
+		t.Fatalf("bad subjects: %#v", st.Subject)
+	}
+	// sha256 of "hello"
+	expected := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if d := st.Subject[0].Digest["sha256"]; d != expected {
+		t.Fatalf("bad digest: %s", d)
+	}
+	if st.Predicate.RunDetails.Builder.ID != "packer-plugin-happycloud" {
+		t.Fatalf("bad builder: %#v", st.Predicate.RunDetails.Builder)
+	}
+	if st.Predicate.BuildDefinition.ExternalParameters["configDigest"] == nil {
+		t.Fatal("expected the digest of the configuration")
+	}
+}
+
+func TestNewStatement_noFiles(t *testing.T) {
+	a := &packersdk.MockArtifact{
+		FilesValue: []string{},
+		IdValue:    "ami-1234",
+	}
+	if _, err := NewStatement(a, BuildInfo{}); err == nil {
+		t.Fatal("expected an error for an artifact without files nor checksums")
+	}
+
+	a.MetadataValue.Checksums = map[string]string{"sha256": "abcd"}
+	st, err := NewStatement(a, BuildInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Subject) != 1 || st.Subject[0].Name != "ami-1234" || st.Subject[0].Digest["sha256"] != "abcd" {
+		t.Fatalf("bad subjects: %#v", st.Subject)
+	}
+}
+
+func TestConfigDigest_stable(t *testing.T) {
+	a, err := ConfigDigest(map[string]interface{}{"a": 1, "b": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ConfigDigest(map[string]interface{}{"b": 2, "a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a["sha256"] != b["sha256"] {
+		t.Fatalf("digests differ: %s %s", a, b)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	st := &Statement{
+		Type:          StatementType,
+		Subject:       []Subject{{Name: "disk.img", Digest: map[string]string{"sha256": "abcd"}}},
+		PredicateType: ProvenancePredicateType,
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		signer Signer
+		pub    interface{}
+	}{
+		"ecdsa":   {NewKeySigner(ecKey, "ec"), &ecKey.PublicKey},
+		"ed25519": {NewKeySigner(edKey, "ed"), edPub},
+		"func": {NewFuncSigner("kms", func(_ context.Context, payload []byte) ([]byte, error) {
+			return ed25519.Sign(edKey, payload), nil
+		}), edPub},
+	} {
+		t.Run(name, func(t *testing.T) {
+			env, err := Sign(context.Background(), st, tc.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.Signatures[0].KeyID != tc.signer.KeyID() {
+				t.Fatalf("bad key id: %s", env.Signatures[0].KeyID)
+			}
+			verified, err := Verify(env, tc.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verified.Subject[0].Name != "disk.img" {
+				t.Fatalf("bad statement: %#v", verified)
+			}
+
+			env.Payload = append(env.Payload, ' ')
+			if _, err := Verify(env, tc.pub); err == nil {
+				t.Fatal("expected a tampered envelope to fail verification")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package attestation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// PayloadType is the type of the payload of the envelopes of statements.
+const PayloadType = "application/vnd.in-toto+json"
+
+// Envelope is a DSSE envelope of a signed statement. The payload is encoded
+// in base64 when written as JSON.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     []byte      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of an envelope.
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   []byte `json:"sig"`
+}
+
+// Signer signs the payloads of envelopes.
+type Signer interface {
+	// KeyID identifies the key of the signer, if any.
+	KeyID() string
+	// Sign returns the signature of payload.
+	Sign(ctx context.Context, payload []byte) ([]byte, error)
+}
+
+// SignFunc signs payload, like by calling a KMS.
+type SignFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+type funcSigner struct {
+	keyID string
+	sign  SignFunc
+}
+
+func (s *funcSigner) KeyID() string { return s.keyID }
+
+func (s *funcSigner) Sign(ctx context.Context, payload []byte) ([]byte, error) {
+	return s.sign(ctx, payload)
+}
+
+// NewFuncSigner returns a Signer signing with sign, like a callback signing
+// with a key of a KMS identified by keyID.
+func NewFuncSigner(keyID string, sign SignFunc) Signer {
+	return &funcSigner{keyID: keyID, sign: sign}
+}
+
+// NewKeySigner returns a Signer signing with key, an ECDSA, RSA or Ed25519
+// private key. ECDSA and RSA (PKCS #1 v1.5) signatures are of the sha256
+// digest of the payload.
+func NewKeySigner(key crypto.Signer, keyID string) Signer {
+	return NewFuncSigner(keyID, func(_ context.Context, payload []byte) ([]byte, error) {
+		switch key.(type) {
+		case ed25519.PrivateKey, *ed25519.PrivateKey:
+			return key.Sign(rand.Reader, payload, crypto.Hash(0))
+		case *ecdsa.PrivateKey, *rsa.PrivateKey:
+			digest := sha256.Sum256(payload)
+			return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		}
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	})
+}
+
+// Sign signs st with signers into an envelope.
+func Sign(ctx context.Context, st *Statement, signers ...Signer) (*Envelope, error) {
+	if len(signers) == 0 {
+		return nil, errors.New("no signer to sign the statement with")
+	}
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{
+		PayloadType: PayloadType,
+		Payload:     payload,
+	}
+	pae := preAuthEncoding(env.PayloadType, payload)
+	for _, s := range signers {
+		sig, err := s.Sign(ctx, pae)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign the statement: %s", err)
+		}
+		env.Signatures = append(env.Signatures, Signature{KeyID: s.KeyID(), Sig: sig})
+	}
+	return env, nil
+}
+
+// Verify checks that env is signed by the key of pub, an ECDSA, RSA or
+// Ed25519 public key, and returns its statement.
+func Verify(env *Envelope, pub crypto.PublicKey) (*Statement, error) {
+	if env.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", env.PayloadType)
+	}
+	pae := preAuthEncoding(env.PayloadType, env.Payload)
+	digest := sha256.Sum256(pae)
+
+	verified := false
+	for _, sig := range env.Signatures {
+		switch pub := pub.(type) {
+		case ed25519.PublicKey:
+			verified = ed25519.Verify(pub, pae, sig.Sig)
+		case *ecdsa.PublicKey:
+			verified = ecdsa.VerifyASN1(pub, digest[:], sig.Sig)
+		case *rsa.PublicKey:
+			verified = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig.Sig) == nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", pub)
+		}
+		if verified {
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("no valid signature of the envelope")
+	}
+
+	var st Statement
+	if err := json.Unmarshal(env.Payload, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// preAuthEncoding returns the pre-authentication encoding of DSSE, the
+// message that is signed.
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s",
+		len(payloadType), payloadType, len(payload), payload))
+}
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CancelReason is why Packer cancels a component.
+type CancelReason string
+
+const (
+	// CancelReasonUnknown is the reason of the cancellations whose reason
+	// isn't known, like from a Packer core that doesn't send one.
+	CancelReasonUnknown CancelReason = ""
+	// CancelReasonInterrupt is the reason of the cancellations asked by the
+	// user, with Ctrl-C.
+	CancelReasonInterrupt CancelReason = "interrupt"
+	// CancelReasonTimeout is the reason of the cancellations of the
+	// components that ran longer than their timeout.
+	CancelReasonTimeout CancelReason = "timeout"
+	// CancelReasonDependencyFailure is the reason of the cancellations of
+	// the components of a build after the failure of another build they
+	// depend on.
+	CancelReasonDependencyFailure CancelReason = "dependency_failure"
+)
+
+// CancelError is the cause of the cancellation of the context of a
+// component, set by Packer to tell why it cancels it:
+//
+//	ctx, cancel := context.WithCancelCause(ctx)
+//	...
+//	cancel(&packersdk.CancelError{Reason: packersdk.CancelReasonTimeout, GracePeriod: time.Minute})
+//
+// Components get it with CancelCause, to clean up differently on a timeout
+// than on Ctrl-C, like keeping a snapshot to debug. errors.Is(err,
+// context.Canceled) is true for a CancelError.
+type CancelError struct {
+	Reason CancelReason
+	// Message details the reason, like the build that failed, optional.
+	Message string
+	// GracePeriod is the time the component has to clean up before Packer
+	// stops it, 0 if unlimited or unknown.
+	GracePeriod time.Duration
+	// Deadline is the end of the grace period, set by the component
+	// receiving the cancellation, zero without grace period.
+	Deadline time.Time
+}
+
+func (err *CancelError) Error() string {
+	reason := err.Reason
+	if reason == CancelReasonUnknown {
+		reason = "unknown reason"
+	}
+	s := fmt.Sprintf("cancelled: %s", reason)
+	if err.Message != "" {
+		s += ": " + err.Message
+	}
+	return s
+}
+
+func (err *CancelError) Is(target error) bool {
+	return target == context.Canceled
+}
+
+// CancelCause returns the *CancelError ctx was cancelled with, or nil if ctx
+// is not cancelled. A context cancelled without a *CancelError gets one with
+// CancelReasonTimeout if its deadline was exceeded, CancelReasonUnknown
+// otherwise.
+func CancelCause(ctx context.Context) *CancelError {
+	cause := context.Cause(ctx)
+	if cause == nil {
+		return nil
+	}
+	var cerr *CancelError
+	if errors.As(cause, &cerr) {
+		return cerr
+	}
+	if errors.Is(cause, context.DeadlineExceeded) {
+		return &CancelError{Reason: CancelReasonTimeout}
+	}
+	return &CancelError{Reason: CancelReasonUnknown}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelCause(t *testing.T) {
+	if cause := CancelCause(context.Background()); cause != nil {
+		t.Fatalf("expected no cause, got %v", cause)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&CancelError{Reason: CancelReasonInterrupt})
+	cause := CancelCause(ctx)
+	if cause == nil || cause.Reason != CancelReasonInterrupt {
+		t.Fatalf("bad cause: %v", cause)
+	}
+	if !errors.Is(context.Cause(ctx), context.Canceled) {
+		t.Fatal("a CancelError should be a context.Canceled")
+	}
+
+	ctx, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	if cause := CancelCause(ctx); cause == nil || cause.Reason != CancelReasonUnknown {
+		t.Fatalf("bad cause: %v", cause)
+	}
+
+	ctx, cancel2 = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel2()
+	<-ctx.Done()
+	if cause := CancelCause(ctx); cause == nil || cause.Reason != CancelReasonTimeout {
+		t.Fatalf("bad cause: %v", cause)
+	}
+}
@@ -36,6 +36,16 @@ type RemoteCmd struct {
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// Env specifies environment variables to set for the command, each of
+	// the form "key=value". Communicators pass them through their protocol
+	// rather than in the command line, so they can hold secrets, and return
+	// an error from Start when they can't set them.
+	Env []string
+
+	// Pty, when set, tells whether to run the command in a pseudo terminal,
+	// overriding the setting of the communicator.
+	Pty *bool
+
 	// Once Exited is true, this will contain the exit code of the process.
 	exitStatus int
 
@@ -193,6 +203,20 @@ func (r *RemoteCmd) Wait() int {
 	return r.exitStatus
 }
 
+// WaitContext waits for command exit, like Wait, or for ctx to be done, and
+// returns the exit status, or the error of ctx if the command didn't exit.
+func (r *RemoteCmd) WaitContext(ctx context.Context) (int, error) {
+	r.initchan()
+	select {
+	case <-r.exitCh:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.exitStatus, nil
+}
+
 func (r *RemoteCmd) ExitStatus() int {
 	return r.Wait()
 }
@@ -89,3 +89,19 @@ func TestRemoteCmd_Wait(t *testing.T) {
 		t.Fatal("never got exit notification")
 	}
 }
+
+func TestRemoteCmd_WaitContext(t *testing.T) {
+	var cmd RemoteCmd
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cmd.WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	cmd.SetExited(42)
+	status, err := cmd.WaitContext(context.Background())
+	if err != nil || status != 42 {
+		t.Fatalf("expected the exit status 42, got %d, %v", status, err)
+	}
+}
@@ -5,12 +5,38 @@ package packer
 
 import (
 	"context"
+	"fmt"
 )
 
 // This is the hook that should be fired for provisioners to run.
 const HookProvision = "packer_provision"
 const HookCleanupProvision = "packer_cleanup_provision"
 
+// These are the hooks of the lifecycle of builds, fired by RunBuilder. Their
+// data is a map[string]string, described by the HookData keys, and they get
+// no communicator.
+const (
+	// HookPreBuild is fired before the builder runs. The build fails if the
+	// hook fails.
+	HookPreBuild = "packer_pre_build"
+	// HookPreArtifact is fired once the builder created its artifact, before
+	// it is returned. The build fails if the hook fails, like when the
+	// artifact doesn't comply with a policy; the artifact is not destroyed.
+	HookPreArtifact = "packer_pre_artifact"
+	// HookPostBuild is fired once the build succeeded. The errors of the hook
+	// are reported to the Ui but do not fail the build.
+	HookPostBuild = "packer_post_build"
+	// HookError is fired when the build fails.
+	HookError = "packer_error"
+)
+
+// The keys of the data of the lifecycle hooks.
+const (
+	HookDataArtifactID = "artifact_id"
+	HookDataBuilderID  = "builder_id"
+	HookDataError      = "error"
+)
+
 // A Hook is used to hook into an arbitrarily named location in a build,
 // allowing custom behavior to run at certain points along a build.
 //
@@ -54,3 +80,50 @@ func (h *DispatchHook) Run(ctx context.Context, name string, ui Ui, comm Communi
 
 	return nil
 }
+
+// RunBuilder runs b, firing the lifecycle hooks of the build along the way.
+func RunBuilder(ctx context.Context, b Builder, ui Ui, hook Hook) (Artifact, error) {
+	if hook == nil {
+		return b.Run(ctx, ui, hook)
+	}
+	artifact, err := runBuilder(ctx, b, ui, hook)
+	if err != nil {
+		data := map[string]string{HookDataError: err.Error()}
+		if herr := hook.Run(ctx, HookError, ui, nil, data); herr != nil {
+			ui.Error(fmt.Sprintf("Error running %s hook: %s", HookError, herr))
+		}
+		return nil, err
+	}
+
+	data := map[string]string{}
+	if artifact != nil {
+		data = artifactHookData(artifact)
+	}
+	if err := hook.Run(ctx, HookPostBuild, ui, nil, data); err != nil {
+		ui.Error(fmt.Sprintf("Error running %s hook: %s", HookPostBuild, err))
+	}
+	return artifact, nil
+}
+
+func runBuilder(ctx context.Context, b Builder, ui Ui, hook Hook) (Artifact, error) {
+	if err := hook.Run(ctx, HookPreBuild, ui, nil, map[string]string{}); err != nil {
+		return nil, err
+	}
+
+	artifact, err := b.Run(ctx, ui, hook)
+	if err != nil || artifact == nil {
+		return artifact, err
+	}
+
+	if err := hook.Run(ctx, HookPreArtifact, ui, nil, artifactHookData(artifact)); err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+func artifactHookData(a Artifact) map[string]string {
+	return map[string]string{
+		HookDataArtifactID: a.Id(),
+		HookDataBuilderID:  a.BuilderId(),
+	}
+}
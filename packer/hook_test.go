@@ -5,6 +5,8 @@ package packer
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -70,3 +72,95 @@ func TestDispatchHook_cancel(t *testing.T) {
 		t.Fatal("hook should've errored")
 	}
 }
+
+// recordHook records the names of the hooks it runs, failing the ones of
+// fail.
+type recordHook struct {
+	names []string
+	data  []interface{}
+	fail  map[string]bool
+}
+
+func (h *recordHook) Run(_ context.Context, name string, _ Ui, _ Communicator, data interface{}) error {
+	h.names = append(h.names, name)
+	h.data = append(h.data, data)
+	if h.fail[name] {
+		return errors.New("hook failed")
+	}
+	return nil
+}
+
+func TestRunBuilder(t *testing.T) {
+	cases := map[string]struct {
+		Builder  *MockBuilder
+		Fail     []string
+		Expected []string
+		Err      bool
+	}{
+		"success": {
+			Builder:  &MockBuilder{ArtifactId: "ami-1234"},
+			Expected: []string{HookPreBuild, HookProvision, HookPreArtifact, HookPostBuild},
+		},
+		"builder error": {
+			Builder:  &MockBuilder{RunErrResult: true},
+			Expected: []string{HookPreBuild, HookError},
+			Err:      true,
+		},
+		"no artifact": {
+			Builder:  &MockBuilder{RunNilResult: true},
+			Expected: []string{HookPreBuild, HookPostBuild},
+		},
+		"pre-build veto": {
+			Builder:  &MockBuilder{},
+			Fail:     []string{HookPreBuild},
+			Expected: []string{HookPreBuild, HookError},
+			Err:      true,
+		},
+		"pre-artifact veto": {
+			Builder:  &MockBuilder{},
+			Fail:     []string{HookPreArtifact},
+			Expected: []string{HookPreBuild, HookProvision, HookPreArtifact, HookError},
+			Err:      true,
+		},
+		"post-build failure": {
+			Builder:  &MockBuilder{},
+			Fail:     []string{HookPostBuild},
+			Expected: []string{HookPreBuild, HookProvision, HookPreArtifact, HookPostBuild},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hook := &recordHook{fail: map[string]bool{}}
+			for _, name := range tc.Fail {
+				hook.fail[name] = true
+			}
+			ui := &MockUi{}
+			artifact, err := RunBuilder(context.Background(), tc.Builder, ui, hook)
+			if (err != nil) != tc.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil && artifact != nil {
+				t.Fatalf("unexpected artifact: %#v", artifact)
+			}
+			if !reflect.DeepEqual(hook.names, tc.Expected) {
+				t.Fatalf("expected hooks %v, got %v", tc.Expected, hook.names)
+			}
+		})
+	}
+}
+
+func TestRunBuilder_data(t *testing.T) {
+	hook := &recordHook{}
+	_, err := RunBuilder(context.Background(), &MockBuilder{ArtifactId: "ami-1234"}, &MockUi{}, hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		HookDataArtifactID: "ami-1234",
+		HookDataBuilderID:  "bid",
+	}
+	if !reflect.DeepEqual(hook.data[len(hook.data)-1], expected) {
+		t.Fatalf("bad: %#v", hook.data[len(hook.data)-1])
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"io"
 	"strings"
 	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 )
 
 type secretFilter struct {
@@ -31,6 +34,8 @@ func (l *secretFilter) SetOutput(output io.Writer) {
 }
 
 func (l *secretFilter) Write(p []byte) (n int, err error) {
+	l.m.Lock()
+	defer l.m.Unlock()
 	for s := range l.s {
 		if s != "" {
 			p = bytes.Replace(p, []byte(s), []byte("<sensitive>"), -1)
@@ -42,6 +47,8 @@ func (l *secretFilter) Write(p []byte) (n int, err error) {
 // FilterString will overwrite any senstitive variables in a string, returning
 // the filtered string.
 func (l *secretFilter) FilterString(message string) string {
+	l.m.Lock()
+	defer l.m.Unlock()
 	for s := range l.s {
 		if s != "" {
 			message = strings.Replace(message, s, "<sensitive>", -1)
@@ -54,4 +61,8 @@ var LogSecretFilter secretFilter
 
 func init() {
 	LogSecretFilter.s = make(map[string]struct{})
+	// Redact the sensitive options of the decoded configurations.
+	config.OnSensitiveValues(LogSecretFilter.Set)
+	// And the sensitive generated data of the builders.
+	packerbuilderdata.OnSensitiveValues(LogSecretFilter.Set)
 }
@@ -21,9 +21,9 @@ func (e *MultiError) Error() string {
 		points[i] = fmt.Sprintf("* %s", err)
 	}
 
-	return fmt.Sprintf(
+	return LogSecretFilter.FilterString(fmt.Sprintf(
 		"%d error(s) occurred:\n\n%s",
-		len(e.Errors), strings.Join(points, "\n"))
+		len(e.Errors), strings.Join(points, "\n")))
 }
 
 // MultiErrorAppend is a helper function that will append more errors
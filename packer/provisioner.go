@@ -3,7 +3,15 @@
 
 package packer
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/retry"
+)
 
 // A provisioner is responsible for installing and configuring software
 // on a machine prior to building the actual image.
@@ -21,3 +29,105 @@ type Provisioner interface {
 	// machine so that provisioning can be done.
 	Provision(context.Context, Ui, Communicator, map[string]interface{}) error
 }
+
+// ProvisionerSettings are the settings that a template can set on any
+// provisioner, regardless of its type.
+type ProvisionerSettings struct {
+	// PauseBefore is how long to wait before running the provisioner.
+	PauseBefore time.Duration
+	// MaxRetries is how many times the provisioner is retried after failing.
+	MaxRetries int
+	// Timeout bounds how long each run of the provisioner can take.
+	Timeout time.Duration
+}
+
+// Run calls fn as dictated by the settings: after pausing for PauseBefore,
+// fn is called with a context cancelled after Timeout and is retried up to
+// MaxRetries times if it fails. name is used to report progress to ui.
+//
+// Run returns as soon as ctx is cancelled.
+func (s ProvisionerSettings) Run(ctx context.Context, ui Ui, name string, fn func(context.Context) error) error {
+	if s.PauseBefore > 0 {
+		ui.Say(fmt.Sprintf("Pausing %s before the next provisioner...", s.PauseBefore))
+		select {
+		case <-time.After(s.PauseBefore):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	run := fn
+	if s.Timeout > 0 {
+		run = func(ctx context.Context) error {
+			log.Printf("Setting a %s timeout for the %s provisioner", s.Timeout, name)
+			ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+			defer cancel()
+
+			err := fn(ctx)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%s provisioner timed out after %s: %w", name, s.Timeout, err)
+			}
+			return err
+		}
+	}
+
+	if s.MaxRetries <= 0 {
+		return run(ctx)
+	}
+
+	try := 0
+	return retry.Config{
+		Tries: s.MaxRetries + 1,
+		ShouldRetry: func(error) bool {
+			return ctx.Err() == nil
+		},
+		RetryDelay: (&retry.Backoff{InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second, Multiplier: 2}).Linear,
+	}.Run(ctx, func(ctx context.Context) error {
+		if try > 0 {
+			ui.Say(fmt.Sprintf("Retrying the %s provisioner (attempt %d/%d)...", name, try+1, s.MaxRetries+1))
+		}
+		try++
+		return run(ctx)
+	})
+}
+
+// HookedProvisioner is a provisioner along with the settings it was
+// configured with in the template.
+type HookedProvisioner struct {
+	Provisioner Provisioner
+	TypeName    string
+	Settings    ProvisionerSettings
+}
+
+// ProvisionHook is a Hook that runs its provisioners one after the other,
+// enforcing their settings.
+type ProvisionHook struct {
+	Provisioners []*HookedProvisioner
+}
+
+// Run runs the provisioners in order. data is expected to be the generated
+// data map assembled by the builder.
+func (h *ProvisionHook) Run(ctx context.Context, name string, ui Ui, comm Communicator, data interface{}) error {
+	if len(h.Provisioners) == 0 {
+		return nil
+	}
+
+	if comm == nil {
+		return fmt.Errorf(
+			"No communicator found for provisioners! This is usually because the\n" +
+				"`communicator` config was set to \"none\". If you have any provisioners\n" +
+				"then a communicator is required. Please fix this to continue.")
+	}
+
+	generatedData, _ := data.(map[string]interface{})
+	for _, p := range h.Provisioners {
+		err := p.Settings.Run(ctx, ui, p.TypeName, func(ctx context.Context) error {
+			return p.Provisioner.Provision(ctx, ui, comm, generatedData)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProvisionerUi() *BasicUi {
+	return &BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func TestProvisionHook_Impl(t *testing.T) {
+	var _ Hook = new(ProvisionHook)
+}
+
+func TestProvisionHook_Run(t *testing.T) {
+	p1 := &MockProvisioner{}
+	p2 := &MockProvisioner{}
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{Provisioner: p1, TypeName: "p1"},
+			{Provisioner: p2, TypeName: "p2"},
+		},
+	}
+
+	comm := new(MockCommunicator)
+	if err := hook.Run(context.Background(), HookProvision, testProvisionerUi(), comm, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p1.ProvCalled || !p2.ProvCalled {
+		t.Fatal("both provisioners should be called")
+	}
+	if p1.ProvCommunicator != comm {
+		t.Fatal("should be called with the communicator")
+	}
+}
+
+func TestProvisionHook_NoCommunicator(t *testing.T) {
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{{Provisioner: &MockProvisioner{}}},
+	}
+	if err := hook.Run(context.Background(), HookProvision, testProvisionerUi(), nil, nil); err == nil {
+		t.Fatal("should error without a communicator")
+	}
+}
+
+func TestProvisionerSettings_Retry(t *testing.T) {
+	p := &MockProvisioner{
+		ProvFunc: func(context.Context) error { return errors.New("failed") },
+	}
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{Provisioner: p, TypeName: "mock", Settings: ProvisionerSettings{MaxRetries: 1}},
+		},
+	}
+	if err := hook.Run(context.Background(), HookProvision, testProvisionerUi(), new(MockCommunicator), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.ProvRetried {
+		t.Fatal("provisioner should have been retried")
+	}
+}
+
+func TestProvisionerSettings_Timeout(t *testing.T) {
+	settings := ProvisionerSettings{Timeout: 10 * time.Millisecond}
+	err := settings.Run(context.Background(), testProvisionerUi(), "mock", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil || !strings.Contains(err.Error(), "mock provisioner timed out after 10ms") {
+		t.Fatalf("expected a timeout error, got: %v", err)
+	}
+}
+
+func TestProvisionerSettings_PauseCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	settings := ProvisionerSettings{PauseBefore: time.Hour}
+	called := false
+	err := settings.Run(ctx, testProvisionerUi(), "mock", func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the pause to be cancelled, got: %v", err)
+	}
+	if called {
+		t.Fatal("provisioner should not run once cancelled")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"context"
+	"errors"
+)
+
+// The names of the secrets the communicators fetch from a SecretsSource when
+// connecting, named after the options they fill.
+const (
+	SecretSSHUsername        = "ssh_username"
+	SecretSSHPassword        = "ssh_password"
+	SecretSSHPrivateKey      = "ssh_private_key"
+	SecretSSHBastionUsername = "ssh_bastion_username"
+	SecretSSHBastionPassword = "ssh_bastion_password"
+	SecretWinRMUsername      = "winrm_username"
+	SecretWinRMPassword      = "winrm_password"
+)
+
+// ErrSecretNotFound is returned by a SecretsSource that has no secret of the
+// requested name.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// A SecretsSource fetches secrets, like the credentials of communicators,
+// from a secret manager such as Vault, at the time they are needed, so that
+// they don't have to be set in templates.
+type SecretsSource interface {
+	// Secret returns the secret named name, or ErrSecretNotFound.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretsSourceFunc is a function implementing SecretsSource.
+type SecretsSourceFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretsSourceFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// MapSecretsSource is a SecretsSource of fixed secrets, for tests.
+type MapSecretsSource map[string]string
+
+func (m MapSecretsSource) Secret(_ context.Context, name string) (string, error) {
+	s, ok := m[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return s, nil
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,22 +23,63 @@ type TTY interface {
 	Close() error
 }
 
+// SecretTTY is a TTY reading secrets without echoing them, like the TTY of
+// github.com/mattn/go-tty.
+type SecretTTY interface {
+	TTY
+	ReadPassword() (string, error)
+}
+
 // The Ui interface handles all communication for Packer with the outside
 // world. This sort of control allows us to strictly control how output
 // is formatted and various levels of output.
 type Ui interface {
 	Ask(string) (string, error)
 	Askf(string, ...any) (string, error)
+	AskSecret(string) (string, error)
+	AskChoice(query string, choices []string, defaultChoice string) (string, error)
+	Confirm(query string, defaultYes bool) (bool, error)
 	Say(string)
 	Sayf(string, ...any)
 	Message(string)
 	Error(string)
 	Errorf(string, ...any)
 	Machine(string, ...string)
+	Event(Event)
 	// TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser)
 	getter.ProgressTracker
 }
 
+// Event is a machine-readable event of a build, like the creation of a
+// resource or the time a step took, for the tools consuming the output of
+// Packer. It is sent through Ui.Event.
+type Event struct {
+	// Type is the type of the event, like "artifact-id" or "step-timing".
+	Type string
+	// Build is the name of the build the event is about, if any.
+	Build string
+	// Payload holds the values of the event, like {"id": "ami-1234"}.
+	Payload map[string]string
+}
+
+// MachineArgs returns the payload of the event as "key=value" arguments,
+// sorted by key, for Uis only supporting Machine.
+func (e Event) MachineArgs() []string {
+	keys := make([]string, 0, len(e.Payload))
+	for k := range e.Payload {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]string, 0, len(keys)+1)
+	if e.Build != "" {
+		args = append(args, "build="+e.Build)
+	}
+	for _, k := range keys {
+		args = append(args, k+"="+e.Payload[k])
+	}
+	return args
+}
+
 var ErrInterrupted = errors.New("interrupted")
 
 // BasicUI is an implementation of  Ui that reads and writes from a standard Go
@@ -70,6 +112,40 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	if rw.TTY == nil {
 		return "", errors.New("no available tty")
 	}
+	return rw.ask(query, rw.TTY.ReadString)
+}
+
+// AskSecret asks query, reading the answer without echoing it.
+func (rw *BasicUi) AskSecret(query string) (string, error) {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+
+	if rw.interrupted {
+		return "", ErrInterrupted
+	}
+
+	tty, ok := rw.TTY.(SecretTTY)
+	if !ok {
+		return "", errors.New("no available tty to read secrets")
+	}
+	answer, err := rw.ask(query, tty.ReadPassword)
+	if err == nil {
+		// The newline of the answer isn't echoed either.
+		fmt.Fprintln(rw.Writer)
+	}
+	return answer, err
+}
+
+func (rw *BasicUi) AskChoice(query string, choices []string, defaultChoice string) (string, error) {
+	return PromptChoice(rw.Ask, query, choices, defaultChoice)
+}
+
+func (rw *BasicUi) Confirm(query string, defaultYes bool) (bool, error) {
+	return PromptConfirm(rw.Ask, query, defaultYes)
+}
+
+// ask writes query and returns the answer read with read, unless interrupted.
+func (rw *BasicUi) ask(query string, read func() (string, error)) (string, error) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
@@ -81,19 +157,22 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 		}
 	}
 
-	result := make(chan string, 1)
+	type answer struct {
+		line string
+		err  error
+	}
+	result := make(chan answer, 1)
 	go func() {
-		line, err := rw.TTY.ReadString()
+		line, err := read()
 		if err != nil {
 			log.Printf("ui: scan err: %s", err)
-			return
 		}
-		result <- strings.TrimSpace(line)
+		result <- answer{strings.TrimSpace(line), err}
 	}()
 
 	select {
-	case line := <-result:
-		return line, nil
+	case a := <-result:
+		return a.line, a.err
 	case <-sigCh:
 		// Print a newline so that any further output starts properly
 		// on a new line.
@@ -165,6 +244,10 @@ func (rw *BasicUi) Machine(t string, args ...string) {
 	log.Printf("machine readable: %s %#v", t, args)
 }
 
+func (rw *BasicUi) Event(e Event) {
+	log.Printf("machine readable event: %s %#v", e.Type, e.MachineArgs())
+}
+
 func (rw *BasicUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
 	return rw.PB.TrackProgress(src, currentSize, totalSize, stream)
 }
@@ -194,6 +277,30 @@ func (u *SafeUi) Ask(s string) (string, error) {
 	return ret, err
 }
 
+func (u *SafeUi) AskSecret(s string) (string, error) {
+	u.Sem <- 1
+	ret, err := u.Ui.AskSecret(s)
+	<-u.Sem
+
+	return ret, err
+}
+
+func (u *SafeUi) AskChoice(s string, choices []string, defaultChoice string) (string, error) {
+	u.Sem <- 1
+	ret, err := u.Ui.AskChoice(s, choices, defaultChoice)
+	<-u.Sem
+
+	return ret, err
+}
+
+func (u *SafeUi) Confirm(s string, defaultYes bool) (bool, error) {
+	u.Sem <- 1
+	ret, err := u.Ui.Confirm(s, defaultYes)
+	<-u.Sem
+
+	return ret, err
+}
+
 func (u *SafeUi) Sayf(s string, args ...any) {
 	u.Sem <- 1
 	u.Ui.Sayf(s, args...)
@@ -228,6 +335,12 @@ func (u *SafeUi) Machine(t string, args ...string) {
 	<-u.Sem
 }
 
+func (u *SafeUi) Event(e Event) {
+	u.Sem <- 1
+	u.Ui.Event(e)
+	<-u.Sem
+}
+
 func (u *SafeUi) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) (body io.ReadCloser) {
 	u.Sem <- 1
 	ret := u.Ui.TrackProgress(src, currentSize, totalSize, stream)
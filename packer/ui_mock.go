@@ -29,17 +29,22 @@ type SayMessage struct {
 }
 
 type MockUi struct {
-	AskCalled      bool
-	AskQuery       string
-	ErrorCalled    bool
-	ErrorMessage   string
-	MachineCalled  bool
-	MachineType    string
-	MachineArgs    []string
-	MessageCalled  bool
-	MessageMessage string
-	SayCalled      bool
-	SayMessages    []SayMessage
+	AskCalled       bool
+	AskQuery        string
+	AskSecretCalled bool
+	AskChoiceCalled bool
+	ConfirmCalled   bool
+	ErrorCalled     bool
+	ErrorMessage    string
+	MachineCalled   bool
+	MachineType     string
+	MachineArgs     []string
+	EventCalled     bool
+	Events          []Event
+	MessageCalled   bool
+	MessageMessage  string
+	SayCalled       bool
+	SayMessages     []SayMessage
 
 	TrackProgressCalled    bool
 	ProgressBarAddCalled   bool
@@ -55,6 +60,30 @@ func (u *MockUi) Ask(query string) (string, error) {
 	return "foo", nil
 }
 
+func (u *MockUi) AskSecret(query string) (string, error) {
+	u.AskSecretCalled = true
+	u.AskQuery = query
+	return "foo", nil
+}
+
+// AskChoice chooses defaultChoice, or the first choice if there is no
+// default.
+func (u *MockUi) AskChoice(query string, choices []string, defaultChoice string) (string, error) {
+	u.AskChoiceCalled = true
+	u.AskQuery = query
+	if defaultChoice == "" && len(choices) > 0 {
+		return choices[0], nil
+	}
+	return defaultChoice, nil
+}
+
+// Confirm answers the default.
+func (u *MockUi) Confirm(query string, defaultYes bool) (bool, error) {
+	u.ConfirmCalled = true
+	u.AskQuery = query
+	return defaultYes, nil
+}
+
 func (u *MockUi) Errorf(message string, args ...any) {
 	u.Error(fmt.Sprintf(message, args...))
 }
@@ -69,6 +98,11 @@ func (u *MockUi) Machine(t string, args ...string) {
 	u.MachineArgs = args
 }
 
+func (u *MockUi) Event(e Event) {
+	u.EventCalled = true
+	u.Events = append(u.Events, e)
+}
+
 func (u *MockUi) Message(message string) {
 	u.MessageCalled = true
 	u.MessageMessage = message
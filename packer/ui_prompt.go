@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// promptAttempts is the number of times an invalid answer is asked again.
+const promptAttempts = 3
+
+// PromptChoice asks query with ask until the answer is one of choices, or
+// its number starting from 1, and returns the choice. An empty answer
+// chooses defaultChoice, if not empty. It implements Ui.AskChoice on top of
+// Ui.Ask.
+func PromptChoice(ask func(string) (string, error), query string, choices []string, defaultChoice string) (string, error) {
+	if len(choices) == 0 {
+		return "", fmt.Errorf("no choices to ask %q", query)
+	}
+
+	var b strings.Builder
+	b.WriteString(query)
+	for i, c := range choices {
+		fmt.Fprintf(&b, "\n  %d) %s", i+1, c)
+		if c == defaultChoice {
+			b.WriteString(" (default)")
+		}
+	}
+	b.WriteString("\nChoice:")
+	prompt := b.String()
+
+	var answer string
+	for attempt := 0; attempt < promptAttempts; attempt++ {
+		var err error
+		answer, err = ask(prompt)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" && defaultChoice != "" {
+			return defaultChoice, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1], nil
+		}
+		for _, c := range choices {
+			if strings.EqualFold(answer, c) {
+				return c, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid choice %q, expected one of: %s",
+		answer, strings.Join(choices, ", "))
+}
+
+// PromptConfirm asks the yes or no question query with ask until the answer
+// is valid. An empty answer is yes if defaultYes is true. It implements
+// Ui.Confirm on top of Ui.Ask.
+func PromptConfirm(ask func(string) (string, error), query string, defaultYes bool) (bool, error) {
+	prompt := query + " [y/N]"
+	if defaultYes {
+		prompt = query + " [Y/n]"
+	}
+
+	var answer string
+	for attempt := 0; attempt < promptAttempts; attempt++ {
+		var err error
+		answer, err = ask(prompt)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return defaultYes, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("invalid answer %q, expected yes or no", answer)
+}
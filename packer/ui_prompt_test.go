@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packer
+
+import (
+	"strings"
+	"testing"
+)
+
+// answers returns an ask function answering answers in order.
+func answers(answers ...string) func(string) (string, error) {
+	return func(string) (string, error) {
+		a := answers[0]
+		answers = answers[1:]
+		return a, nil
+	}
+}
+
+func TestPromptChoice(t *testing.T) {
+	choices := []string{"eu-west-1", "us-east-1"}
+	cases := []struct {
+		Answers  []string
+		Default  string
+		Expected string
+		Err      bool
+	}{
+		{Answers: []string{"us-east-1"}, Expected: "us-east-1"},
+		{Answers: []string{"EU-WEST-1"}, Expected: "eu-west-1"},
+		{Answers: []string{"2"}, Expected: "us-east-1"},
+		{Answers: []string{""}, Default: "us-east-1", Expected: "us-east-1"},
+		{Answers: []string{"3", "", "eu-west-1"}, Expected: "eu-west-1"},
+		{Answers: []string{"a", "b", "c"}, Err: true},
+	}
+
+	for _, tc := range cases {
+		result, err := PromptChoice(answers(tc.Answers...), "Region?", choices, tc.Default)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%v: unexpected error %v", tc.Answers, err)
+		}
+		if result != tc.Expected {
+			t.Fatalf("%v: expected %q, got %q", tc.Answers, tc.Expected, result)
+		}
+	}
+}
+
+func TestPromptChoice_prompt(t *testing.T) {
+	var prompt string
+	_, err := PromptChoice(func(p string) (string, error) {
+		prompt = p
+		return "1", nil
+	}, "Region?", []string{"eu", "us"}, "us")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Region?\n  1) eu\n  2) us (default)\nChoice:"
+	if prompt != expected {
+		t.Fatalf("expected %q, got %q", expected, prompt)
+	}
+}
+
+func TestPromptConfirm(t *testing.T) {
+	cases := []struct {
+		Answers    []string
+		DefaultYes bool
+		Expected   bool
+		Err        bool
+	}{
+		{Answers: []string{"y"}, Expected: true},
+		{Answers: []string{"Yes"}, Expected: true},
+		{Answers: []string{"n"}, DefaultYes: true, Expected: false},
+		{Answers: []string{""}, DefaultYes: true, Expected: true},
+		{Answers: []string{""}, Expected: false},
+		{Answers: []string{"maybe", "y"}, Expected: true},
+		{Answers: []string{"a", "b", "c"}, Err: true},
+	}
+
+	for _, tc := range cases {
+		result, err := PromptConfirm(answers(tc.Answers...), "Continue?", tc.DefaultYes)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%v: unexpected error %v", tc.Answers, err)
+		}
+		if result != tc.Expected {
+			t.Fatalf("%v: expected %t, got %t", tc.Answers, tc.Expected, result)
+		}
+	}
+}
+
+type secretTTY struct {
+	secret string
+}
+
+func (t *secretTTY) ReadString() (string, error)   { return "echoed", nil }
+func (t *secretTTY) ReadPassword() (string, error) { return t.secret, nil }
+func (t *secretTTY) Close() error                  { return nil }
+
+func TestBasicUi_AskSecret(t *testing.T) {
+	var out strings.Builder
+	ui := &BasicUi{
+		Writer: &out,
+		TTY:    &secretTTY{secret: "hunter2"},
+	}
+	secret, err := ui.AskSecret("Password:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "hunter2" {
+		t.Fatalf("bad: %q", secret)
+	}
+	if out.String() != "Password: \n" {
+		t.Fatalf("bad output: %q", out.String())
+	}
+
+	ui.TTY = nil
+	if _, err := ui.AskSecret("Password:"); err == nil {
+		t.Fatal("expected an error without a tty")
+	}
+}
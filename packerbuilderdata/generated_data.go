@@ -7,7 +7,11 @@
 // relevant details that provisioning scripts may need access to.
 package packerbuilderdata
 
-import "github.com/hashicorp/packer-plugin-sdk/multistep"
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
 
 // This is used in the BasicPlaceholderData() func in the packer/provisioner.go
 // To force users to access generated data via the "generated" func.
@@ -19,11 +23,20 @@ const PlaceholderMsg = "To set this dynamically in the Packer template, " +
 // build data generated at runtime -- for example, instance ID or instance IP
 // address. Internally, it uses the builder's multistep.StateBag. The user
 // must make sure that the State field is not is not nil before calling Put().
+//
+// Set publishes typed entries declared with a Key, and records them in the
+// manifest of the state, so that two components can't publish different
+// entries under the same name.
 type GeneratedData struct {
 	// The builder's StateBag
 	State multistep.StateBag
+	// Manifest, if set, is the manifest the builder returned the names of in
+	// Prepare; Set then refuses the entries it doesn't declare.
+	Manifest *Manifest
 }
 
+// Put sets the entry key to data, without checking its type or conflicts
+// with other entries.
 func (gd *GeneratedData) Put(key string, data interface{}) {
 	genData := make(map[string]interface{})
 	if _, ok := gd.State.GetOk("generated_data"); ok {
@@ -32,3 +45,51 @@ func (gd *GeneratedData) Put(key string, data interface{}) {
 	genData[key] = data
 	gd.State.Put("generated_data", genData)
 }
+
+// Set sets the entry declared by key to value. It returns an error if value
+// is not of the type of key, if key is not declared by gd.Manifest, when
+// set, or if the entry was set before with a different declaration. The
+// values of the entries declared as sensitive, by key or by gd.Manifest, are
+// passed to the functions registered with OnSensitiveValues.
+func (gd *GeneratedData) Set(key Key, value interface{}) error {
+	if key.Type == "" {
+		key.Type = TypeAny
+	}
+	if key.Name == "" {
+		return fmt.Errorf("generated data set without a name")
+	}
+	if err := key.Type.check(value); err != nil {
+		return fmt.Errorf("generated data %s: %s", key, err)
+	}
+	if gd.Manifest != nil {
+		declared, ok := gd.Manifest.Lookup(key.String())
+		if !ok {
+			return fmt.Errorf("generated data %s is not declared in the manifest of the builder", key)
+		}
+		if err := key.conflicts(declared); err != nil {
+			return err
+		}
+		key.Sensitive = key.Sensitive || declared.Sensitive
+	}
+
+	manifest := StateManifest(gd.State)
+	if manifest == nil {
+		manifest = &Manifest{}
+	}
+	if err := manifest.Add(key); err != nil {
+		return err
+	}
+	gd.State.Put("generated_data_manifest", manifest)
+	if key, _ := manifest.Lookup(key.String()); key.Sensitive {
+		setSensitiveValues(value)
+	}
+	gd.Put(key.String(), value)
+	return nil
+}
+
+// StateManifest returns the manifest of the entries set with Set in state,
+// or nil if none were.
+func StateManifest(state multistep.StateBag) *Manifest {
+	m, _ := state.Get("generated_data_manifest").(*Manifest)
+	return m
+}
@@ -4,6 +4,9 @@
 package packerbuilderdata
 
 import (
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -32,3 +35,152 @@ func TestGeneratedData_Put(t *testing.T) {
 		t.Fatalf("Unexpected state for another_data_key: expected %#v got %#v\n", secondExpectedValue, generatedDataState["another_data_key"])
 	}
 }
+
+func TestGeneratedData_Set(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	gd := GeneratedData{State: state}
+
+	host := Key{Namespace: "SSH", Name: "Host", Type: TypeString}
+	if err := gd.Set(host, "10.0.0.1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := gd.Set(Key{Name: "Disks", Type: TypeList}, []string{"sda"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := gd.Set(host, "10.0.0.2"); err != nil {
+		t.Fatalf("setting an entry again should be allowed: %s", err)
+	}
+
+	genData := state.Get("generated_data").(map[string]interface{})
+	if genData["SSH_Host"] != "10.0.0.2" {
+		t.Fatalf("bad SSH_Host: %#v", genData["SSH_Host"])
+	}
+
+	errs := map[string]error{
+		"wrong type":        gd.Set(Key{Name: "Port", Type: TypeNumber}, "22"),
+		"conflicting type":  gd.Set(Key{Namespace: "SSH", Name: "Host", Type: TypeList}, []string{"a"}),
+		"conflicting owner": gd.Set(Key{Name: "SSH_Host", Type: TypeString}, "10.0.0.3"),
+		"unknown type":      gd.Set(Key{Name: "Foo", Type: "object"}, "bar"),
+		"nil for a non-any": gd.Set(Key{Name: "Foo", Type: TypeString}, nil),
+		"missing name":      gd.Set(Key{Type: TypeString}, "bar"),
+	}
+	for name, err := range errs {
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	want := []string{"Disks", "SSH_Host"}
+	if got := StateManifest(state).Names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad manifest: %#v", got)
+	}
+}
+
+func TestGeneratedData_Set_manifest(t *testing.T) {
+	manifest, err := NewManifest(
+		Key{Name: "ID", Type: TypeString, Description: "The ID of the instance."},
+		Key{Name: "Port", Type: TypeNumber},
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	gd := GeneratedData{State: new(multistep.BasicStateBag), Manifest: manifest}
+
+	if err := gd.Set(Key{Name: "Port", Type: TypeNumber}, 22); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := gd.Set(Key{Name: "Host", Type: TypeString}, "localhost"); err == nil {
+		t.Fatal("an entry not declared in the manifest should be refused")
+	}
+	if err := gd.Set(Key{Name: "ID", Type: TypeNumber}, 1); err == nil {
+		t.Fatal("an entry declared with another type should be refused")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	if _, err := NewManifest(
+		Key{Name: "ID", Type: TypeString},
+		Key{Name: "ID", Type: TypeNumber},
+	); err == nil {
+		t.Fatal("conflicting declarations should be refused")
+	}
+
+	m, err := NewManifest(
+		Key{Name: "ID", Type: TypeString, Description: "The ID of the instance."},
+		Key{Namespace: "SSH", Name: "Host"},
+		Key{Name: "ID", Type: TypeString},
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := `[{"name":"ID","type":"string","description":"The ID of the instance."},{"namespace":"SSH","name":"Host","type":"any"}]`
+	if string(b) != want {
+		t.Fatalf("bad JSON:\n%s\nexpected:\n%s", b, want)
+	}
+
+	var decoded Manifest
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(decoded.Keys(), m.Keys()) {
+		t.Fatalf("bad decoded manifest: %#v", decoded.Keys())
+	}
+}
+
+func TestGeneratedData_Set_sensitive(t *testing.T) {
+	var sensitive []string
+	OnSensitiveValues(func(values ...string) {
+		sensitive = append(sensitive, values...)
+	})
+
+	manifest, err := NewManifest(
+		Key{Name: "Password", Type: TypeString, Sensitive: true},
+		Key{Name: "Host", Type: TypeString},
+		Key{Name: "Keys", Type: TypeMap},
+	)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state := new(multistep.BasicStateBag)
+	gd := GeneratedData{State: state, Manifest: manifest}
+
+	if err := gd.Set(Key{Name: "Password", Type: TypeString}, "hunter2"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := gd.Set(Key{Name: "Host", Type: TypeString}, "10.0.0.1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keys := Key{Name: "Keys", Type: TypeMap, Sensitive: true}
+	if err := gd.Set(keys, map[string]string{"rsa": "ssh-rsa AAAA"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if want := []string{"hunter2", "ssh-rsa AAAA"}; !reflect.DeepEqual(sensitive, want) {
+		t.Fatalf("bad sensitive values: %#v", sensitive)
+	}
+
+	want := map[string]interface{}{
+		"Password": SensitivePlaceholder,
+		"Host":     "10.0.0.1",
+		"Keys":     SensitivePlaceholder,
+	}
+	if got := Redacted(state); !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad redacted data: %#v", got)
+	}
+	if got, _ := state.Get("generated_data").(map[string]interface{}); got["Password"] != "hunter2" {
+		t.Fatalf("the generated data should not be redacted: %#v", got)
+	}
+
+	b, err := json.Marshal(StateManifest(state))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(b), `{"name":"Password","type":"string","sensitive":true}`) {
+		t.Fatalf("the manifest should mark the sensitive entries: %s", b)
+	}
+}
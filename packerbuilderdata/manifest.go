@@ -0,0 +1,212 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packerbuilderdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Type is the type of the value of a generated data entry.
+type Type string
+
+const (
+	TypeString Type = "string"
+	TypeNumber Type = "number"
+	TypeBool   Type = "bool"
+	// TypeList is a list of strings.
+	TypeList Type = "list(string)"
+	// TypeMap is a map of strings.
+	TypeMap Type = "map(string)"
+	// TypeAny is a value of any type, like the entries set with Put.
+	TypeAny Type = "any"
+)
+
+func (t Type) valid() bool {
+	switch t {
+	case TypeString, TypeNumber, TypeBool, TypeList, TypeMap, TypeAny:
+		return true
+	}
+	return false
+}
+
+// check returns an error if v is not of type t.
+func (t Type) check(v interface{}) error {
+	if t == TypeAny {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	ok := false
+	switch t {
+	case TypeString:
+		ok = rv.Kind() == reflect.String
+	case TypeNumber:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			ok = true
+		}
+	case TypeBool:
+		ok = rv.Kind() == reflect.Bool
+	case TypeList:
+		ok = rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.String
+	case TypeMap:
+		ok = rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String &&
+			rv.Type().Elem().Kind() == reflect.String
+	default:
+		return fmt.Errorf("unknown type %q", t)
+	}
+	if !ok {
+		return fmt.Errorf("expected a value of type %s, got %T", t, v)
+	}
+	return nil
+}
+
+// Key declares an entry of generated data published by a builder.
+type Key struct {
+	// Namespace groups the entries published by a same component, like
+	// "SSH" or the name of a builder. It prefixes the name of the entry,
+	// like SSH_Host, so that different components can't overwrite each
+	// other's entries. It is optional.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the entry within its namespace.
+	Name string `json:"name"`
+	// Type is the type of the value of the entry.
+	Type Type `json:"type"`
+	// Description describes the entry, for documentation purposes.
+	Description string `json:"description,omitempty"`
+	// Sensitive marks the entries holding secrets, like passwords or private
+	// keys, so that their values are redacted from the output of the Ui, the
+	// logs, the debug dumps of the state and the listings of Redacted.
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// String returns the name the entry is published as, and accessed with the
+// build function or variable of the templates.
+func (k Key) String() string {
+	if k.Namespace == "" {
+		return k.Name
+	}
+	return k.Namespace + "_" + k.Name
+}
+
+// conflicts returns an error if k and other are different declarations of
+// the same entry.
+func (k Key) conflicts(other Key) error {
+	if k.Namespace != other.Namespace || k.Type != other.Type {
+		return fmt.Errorf("generated data %s is already declared as %s of type %s, cannot declare it as %s of type %s",
+			k, other.describe(), other.Type, k.describe(), k.Type)
+	}
+	return nil
+}
+
+func (k Key) describe() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("%q", k.Name)
+	}
+	return fmt.Sprintf("%q in namespace %q", k.Name, k.Namespace)
+}
+
+// Manifest lists the entries of generated data a builder publishes. Builders
+// declare their entries in Prepare, and return Names() as its generated
+// variables, so that Packer can validate their use in templates before the
+// build; the JSON encoding of a manifest documents the entries.
+type Manifest struct {
+	keys map[string]Key
+}
+
+// NewManifest returns a manifest of keys, or an error if two of them aren't
+// compatible declarations of the same entry.
+func NewManifest(keys ...Key) (*Manifest, error) {
+	m := &Manifest{}
+	if err := m.Add(keys...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add declares keys in m. Declaring an entry again with the same namespace
+// and type is a no-op, except that it can mark the entry as sensitive; any
+// other declaration of an entry is a conflict.
+func (m *Manifest) Add(keys ...Key) error {
+	if m.keys == nil {
+		m.keys = map[string]Key{}
+	}
+	for _, k := range keys {
+		if k.Name == "" {
+			return fmt.Errorf("generated data declared without a name")
+		}
+		if k.Type == "" {
+			k.Type = TypeAny
+		}
+		if !k.Type.valid() {
+			return fmt.Errorf("generated data %s: unknown type %q", k, k.Type)
+		}
+		if other, ok := m.keys[k.String()]; ok {
+			if err := k.conflicts(other); err != nil {
+				return err
+			}
+			if k.Sensitive && !other.Sensitive {
+				other.Sensitive = true
+				m.keys[k.String()] = other
+			}
+			continue
+		}
+		m.keys[k.String()] = k
+	}
+	return nil
+}
+
+// Lookup returns the declaration of the entry named name.
+func (m *Manifest) Lookup(name string) (Key, bool) {
+	if m == nil {
+		return Key{}, false
+	}
+	k, ok := m.keys[name]
+	return k, ok
+}
+
+// Keys returns the declared entries, sorted by name.
+func (m *Manifest) Keys() []Key {
+	if m == nil {
+		return nil
+	}
+	keys := make([]Key, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	return keys
+}
+
+// Names returns the names of the declared entries, sorted, as returned by
+// the Prepare method of builders.
+func (m *Manifest) Names() []string {
+	keys := m.Keys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.String()
+	}
+	return names
+}
+
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	keys := m.Keys()
+	if keys == nil {
+		keys = []Key{}
+	}
+	return json.Marshal(keys)
+}
+
+func (m *Manifest) UnmarshalJSON(b []byte) error {
+	var keys []Key
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return err
+	}
+	m.keys = nil
+	return m.Add(keys...)
+}
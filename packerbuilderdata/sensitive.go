@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package packerbuilderdata
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// SensitivePlaceholder replaces the values of the sensitive entries of
+// generated data in the listings returned by Redacted.
+const SensitivePlaceholder = "<sensitive>"
+
+var (
+	sensitiveHandlersLock sync.RWMutex
+	sensitiveHandlers     []func(values ...string)
+)
+
+// OnSensitiveValues registers fn to be called with the values of the entries
+// set with a sensitive Key. The packer package registers its
+// LogSecretFilter, so that the values are replaced by "<sensitive>" in the
+// output of the Ui, including its machine-readable output, and in the logs.
+func OnSensitiveValues(fn func(values ...string)) {
+	sensitiveHandlersLock.Lock()
+	defer sensitiveHandlersLock.Unlock()
+	sensitiveHandlers = append(sensitiveHandlers, fn)
+}
+
+// setSensitiveValues passes the non-empty values of v to the functions
+// registered with OnSensitiveValues.
+func setSensitiveValues(v interface{}) {
+	values := sensitiveStrings(reflect.ValueOf(v))
+	if len(values) == 0 {
+		return
+	}
+	sensitiveHandlersLock.RLock()
+	defer sensitiveHandlersLock.RUnlock()
+	for _, fn := range sensitiveHandlers {
+		fn(values...)
+	}
+}
+
+// sensitiveStrings returns the non-empty strings of v, or the elements of v
+// when it is a slice or a map.
+func sensitiveStrings(v reflect.Value) []string {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return sensitiveStrings(v.Elem())
+	case reflect.Slice, reflect.Array:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, sensitiveStrings(v.Index(i))...)
+		}
+		return values
+	case reflect.Map:
+		var values []string
+		for iter := v.MapRange(); iter.Next(); {
+			values = append(values, sensitiveStrings(iter.Value())...)
+		}
+		return values
+	case reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+		return []string{v.String()}
+	}
+	return []string{fmt.Sprint(v.Interface())}
+}
+
+// Redacted returns a copy of the generated data of state, with the values of
+// the entries set with a sensitive Key replaced by SensitivePlaceholder. It
+// is the data to list in the outputs of tooling, like manifests.
+func Redacted(state multistep.StateBag) map[string]interface{} {
+	data, _ := state.Get("generated_data").(map[string]interface{})
+	if data == nil {
+		return nil
+	}
+	manifest := StateManifest(state)
+	redacted := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if key, ok := manifest.Lookup(k); ok && key.Sensitive {
+			v = SensitivePlaceholder
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package pathing
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// dirKind is a kind of directory of Packer, besides its config directory,
+// and where it goes on each platform.
+type dirKind struct {
+	// env is the environment variable overriding the directory.
+	env string
+	// xdgEnv is the XDG base directory environment variable of the kind,
+	// and xdgDefault its default, relative to the home directory.
+	xdgEnv, xdgDefault string
+	// darwin is the directory on macOS, relative to ~/Library.
+	darwin string
+	// windowsEnv is the environment variable of the known folder of the
+	// kind on Windows, and windows the directory relative to it.
+	windowsEnv, windows string
+}
+
+var (
+	cacheDirKind = dirKind{
+		env:    "PACKER_CACHE_DIR",
+		xdgEnv: "XDG_CACHE_HOME", xdgDefault: ".cache",
+		darwin:     filepath.Join("Caches", "packer"),
+		windowsEnv: "LOCALAPPDATA", windows: filepath.Join("packer", "cache"),
+	}
+	dataDirKind = dirKind{
+		env:    "PACKER_DATA_DIR",
+		xdgEnv: "XDG_DATA_HOME", xdgDefault: filepath.Join(".local", "share"),
+		darwin:     filepath.Join("Application Support", "packer", "data"),
+		windowsEnv: "APPDATA", windows: filepath.Join("packer", "data"),
+	}
+	stateDirKind = dirKind{
+		env:    "PACKER_STATE_DIR",
+		xdgEnv: "XDG_STATE_HOME", xdgDefault: filepath.Join(".local", "state"),
+		darwin:     filepath.Join("Application Support", "packer", "state"),
+		windowsEnv: "LOCALAPPDATA", windows: filepath.Join("packer", "state"),
+	}
+)
+
+// CacheDir returns the cache directory of Packer, for the files that can be
+// downloaded or computed again, like ISOs.
+//
+//	PACKER_CACHE_DIR="bar"       CacheDir() => "bar", made absolute
+//	Unix:    XDG_CACHE_HOME=""      CacheDir() => "$HOME/.cache/packer"
+//	Unix:    XDG_CACHE_HOME="bar"   CacheDir() => "bar/packer"
+//	macOS:   XDG_CACHE_HOME=""      CacheDir() => "$HOME/Library/Caches/packer"
+//	Windows:                        CacheDir() => "%LOCALAPPDATA%\packer\cache"
+//
+// On macOS, XDG_CACHE_HOME is honored when set, and $HOME/.cache/packer is
+// kept when it exists, for the caches made by previous versions of Packer.
+func CacheDir() (string, error) {
+	return userDir(cacheDirKind)
+}
+
+// DataDir returns the data directory of Packer, for the files installed for
+// the user, like plugins.
+//
+//	PACKER_DATA_DIR="bar"        DataDir() => "bar", made absolute
+//	Unix:    XDG_DATA_HOME=""       DataDir() => "$HOME/.local/share/packer"
+//	Unix:    XDG_DATA_HOME="bar"    DataDir() => "bar/packer"
+//	macOS:   XDG_DATA_HOME=""       DataDir() => "$HOME/Library/Application Support/packer/data"
+//	Windows:                        DataDir() => "%APPDATA%\packer\data"
+//
+// See MigrateLegacyDir to move the files of the legacy ~/.packer.d
+// directory.
+func DataDir() (string, error) {
+	return userDir(dataDirKind)
+}
+
+// StateDir returns the state directory of Packer, for the files that persist
+// between runs but aren't worth backing up, like logs or history.
+//
+//	PACKER_STATE_DIR="bar"       StateDir() => "bar", made absolute
+//	Unix:    XDG_STATE_HOME=""      StateDir() => "$HOME/.local/state/packer"
+//	Unix:    XDG_STATE_HOME="bar"   StateDir() => "bar/packer"
+//	macOS:   XDG_STATE_HOME=""      StateDir() => "$HOME/Library/Application Support/packer/state"
+//	Windows:                        StateDir() => "%LOCALAPPDATA%\packer\state"
+func StateDir() (string, error) {
+	return userDir(stateDirKind)
+}
+
+func userDir(kind dirKind) (string, error) {
+	if dir := os.Getenv(kind.env); dir != "" {
+		log.Printf("Detected directory from env var %s: %s", kind.env, dir)
+		return filepath.Abs(dir)
+	}
+	return defaultUserDir(kind)
+}
+
+// xdgDir returns the XDG base directory of kind, relative to homedir when its
+// environment variable isn't set.
+func xdgDir(kind dirKind, homedir string) string {
+	if dir := os.Getenv(kind.xdgEnv); dir != "" {
+		return filepath.Join(dir, "packer")
+	}
+	return filepath.Join(homedir, kind.xdgDefault, "packer")
+}
+
+// LegacyDir returns the directory Packer used for everything before it
+// followed the conventions of the platform: ~/.packer.d, or packer.d in the
+// application data directory on Windows. It may not exist.
+func LegacyDir() (string, error) {
+	return legacyDir()
+}
+
+// MigrateLegacyDir moves legacy, like the plugins directory of LegacyDir, to
+// dir, like the plugins directory of DataDir, unless dir already exists or
+// legacy doesn't. It returns whether legacy was moved.
+func MigrateLegacyDir(legacy, dir string) (bool, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return false, nil
+	}
+	fi, err := os.Stat(legacy)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !fi.IsDir() {
+		return false, fmt.Errorf("%s is not a directory", legacy)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return false, err
+	}
+	if err := os.Rename(legacy, dir); err != nil {
+		return false, fmt.Errorf("failed to move %s to %s: %s", legacy, dir, err)
+	}
+	log.Printf("Moved legacy directory %s to %s", legacy, dir)
+	return true, nil
+}
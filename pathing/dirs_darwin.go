@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build darwin
+
+package pathing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+func defaultUserDir(kind dirKind) (string, error) {
+	homedir := os.Getenv("HOME")
+	if os.Getenv(kind.xdgEnv) != "" {
+		return xdgDir(kind, homedir), nil
+	}
+	if homedir == "" {
+		return "", errors.New("No $HOME environment variable found, required to set the directory")
+	}
+	// Previous versions of Packer used the XDG directories on macOS too.
+	if dir := xdgDir(kind, homedir); exists(dir) {
+		return dir, nil
+	}
+	return filepath.Join(homedir, "Library", kind.darwin), nil
+}
+
+func legacyDir() (string, error) {
+	homedir := os.Getenv("HOME")
+	if homedir == "" {
+		return "", errors.New("No $HOME environment variable found, required to find the legacy directory")
+	}
+	return filepath.Join(homedir, defaultConfigDir), nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
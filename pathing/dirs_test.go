@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package pathing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateLegacyDir(t *testing.T) {
+	tmp := t.TempDir()
+	legacy := filepath.Join(tmp, ".packer.d", "plugins")
+	dir := filepath.Join(tmp, "share", "packer", "plugins")
+
+	if moved, err := MigrateLegacyDir(legacy, dir); moved || err != nil {
+		t.Fatalf("a missing legacy directory should not be moved: %t, %v", moved, err)
+	}
+
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "packer-plugin-foo"), []byte("foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := MigrateLegacyDir(legacy, dir)
+	if !moved || err != nil {
+		t.Fatalf("the legacy directory should be moved: %t, %v", moved, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "packer-plugin-foo")); err != nil {
+		t.Fatalf("the files should have been moved: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Fatalf("the legacy directory should be gone: %v", err)
+	}
+
+	// an existing directory is left alone
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if moved, err := MigrateLegacyDir(legacy, dir); moved || err != nil {
+		t.Fatalf("an existing directory should not be replaced: %t, %v", moved, err)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build aix || freebsd || linux || netbsd || openbsd || solaris
+
+package pathing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+func defaultUserDir(kind dirKind) (string, error) {
+	homedir := os.Getenv("HOME")
+	if homedir == "" && os.Getenv(kind.xdgEnv) == "" {
+		return "", errors.New("No $HOME environment variable found, required to set the directory")
+	}
+	return xdgDir(kind, homedir), nil
+}
+
+func legacyDir() (string, error) {
+	homedir := os.Getenv("HOME")
+	if homedir == "" {
+		return "", errors.New("No $HOME environment variable found, required to find the legacy directory")
+	}
+	return filepath.Join(homedir, defaultConfigDir), nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build aix || freebsd || linux || netbsd || openbsd || solaris
+
+package pathing
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUserDirs(t *testing.T) {
+	home := t.TempDir()
+	xdg := t.TempDir()
+	override := t.TempDir()
+
+	tests := []struct {
+		name    string
+		dir     func() (string, error)
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"cache no HOME", CacheDir, nil, "", true},
+		{"cache base", CacheDir, map[string]string{"HOME": home}, filepath.Join(home, ".cache", "packer"), false},
+		{"cache xdg", CacheDir, map[string]string{"HOME": home, "XDG_CACHE_HOME": xdg}, filepath.Join(xdg, "packer"), false},
+		{"cache env", CacheDir, map[string]string{"HOME": home, "XDG_CACHE_HOME": xdg, "PACKER_CACHE_DIR": override}, override, false},
+		{"data base", DataDir, map[string]string{"HOME": home}, filepath.Join(home, ".local", "share", "packer"), false},
+		{"data xdg", DataDir, map[string]string{"XDG_DATA_HOME": xdg}, filepath.Join(xdg, "packer"), false},
+		{"data env", DataDir, map[string]string{"PACKER_DATA_DIR": override}, override, false},
+		{"state base", StateDir, map[string]string{"HOME": home}, filepath.Join(home, ".local", "state", "packer"), false},
+		{"state xdg", StateDir, map[string]string{"HOME": home, "XDG_STATE_HOME": xdg}, filepath.Join(xdg, "packer"), false},
+		{"state env", StateDir, map[string]string{"PACKER_STATE_DIR": override}, override, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"HOME", "PACKER_CACHE_DIR", "PACKER_DATA_DIR", "PACKER_STATE_DIR",
+				"XDG_CACHE_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME"} {
+				t.Setenv(k, tt.env[k])
+			}
+			got, err := tt.dir()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package pathing
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func defaultUserDir(kind dirKind) (string, error) {
+	if dir := os.Getenv(kind.windowsEnv); dir != "" {
+		return filepath.Join(dir, kind.windows), nil
+	}
+	homedir, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homedir, kind.windows), nil
+}
+
+func legacyDir() (string, error) {
+	homedir, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homedir, defaultConfigDir), nil
+}
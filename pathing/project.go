@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package pathing
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ProjectDirName is the name of the project-local Packer directory, at the
+// root of a project.
+const ProjectDirName = ".packer"
+
+// Project is the project-local configuration of the directory tree Packer
+// runs in, so that a team can keep the plugins and the configuration of a
+// repository along with it.
+type Project struct {
+	// Root is the root directory of the project.
+	Root string
+	// Dir is the ProjectDirName directory of Root, empty if it has none.
+	Dir string
+	// File is the config file of the project, the config file name of
+	// ConfigFile in Root, or else in Dir, empty if there is none.
+	File string
+}
+
+// PluginDir returns the plugins directory of the project, "plugins" in Dir,
+// empty if the project has no Dir.
+func (p *Project) PluginDir() string {
+	if p.Dir == "" {
+		return ""
+	}
+	return filepath.Join(p.Dir, "plugins")
+}
+
+// FindProject returns the project of start, or nil if it isn't in one. The
+// root of the project is found in this order:
+//
+//  1. The PACKER_PROJECT_DIR environment variable, if set. Setting it to
+//     "none" disables the discovery.
+//  2. The nearest directory, from start up, holding a ProjectDirName
+//     directory or a config file with the name of the one of ConfigFile.
+//     The home directory and its parents are not searched, their config
+//     is the one of the user.
+//
+// The configuration of the project takes precedence over the one of the
+// user, see ConfigFiles.
+func FindProject(start string) (*Project, error) {
+	if root := os.Getenv("PACKER_PROJECT_DIR"); root != "" {
+		if root == "none" {
+			return nil, nil
+		}
+		log.Printf("Detected project directory from env var: %s", root)
+		root, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		return project(root), nil
+	}
+
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return nil, err
+	}
+	home, _ := homeDir()
+	if home != "" {
+		home, _ = filepath.Abs(home)
+	}
+	for {
+		if dir == home {
+			return nil, nil
+		}
+		if p := project(dir); p.Dir != "" || p.File != "" {
+			log.Printf("Found project directory: %s", dir)
+			return p, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func project(root string) *Project {
+	p := &Project{Root: root}
+	if fi, err := os.Stat(filepath.Join(root, ProjectDirName)); err == nil && fi.IsDir() {
+		p.Dir = filepath.Join(root, ProjectDirName)
+	}
+	for _, dir := range []string{p.Root, p.Dir} {
+		if dir == "" {
+			continue
+		}
+		file := filepath.Join(dir, defaultConfigFile)
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			p.File = file
+			break
+		}
+	}
+	return p
+}
+
+// ConfigFiles returns the config files that apply from start, that exist,
+// the one taking precedence first: the one of the project of start, see
+// FindProject, then the one of the user, see ConfigFile.
+func ConfigFiles(start string) ([]string, error) {
+	var files []string
+	p, err := FindProject(start)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil && p.File != "" {
+		files = append(files, p.File)
+	}
+	user, err := ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(user); err == nil {
+		files = append(files, user)
+	}
+	return files, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package pathing
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindProject(t *testing.T) {
+	tmp := t.TempDir()
+	home := filepath.Join(tmp, "home")
+	root := filepath.Join(home, "src", "project")
+	nested := filepath.Join(root, "images", "ubuntu")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ProjectDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APPDATA", "")
+	t.Setenv("HOME", home)
+	t.Setenv("PACKER_CONFIG_DIR", "")
+	t.Setenv("PACKER_PROJECT_DIR", "")
+
+	p, err := FindProject(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Project{Root: root, Dir: filepath.Join(root, ProjectDirName)}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("got %#v, want %#v", p, want)
+	}
+	if p.PluginDir() != filepath.Join(root, ProjectDirName, "plugins") {
+		t.Fatalf("unexpected plugin dir %s", p.PluginDir())
+	}
+
+	// a config file at the root takes precedence over the user one, the
+	// one of the home directory isn't the one of a project
+	projectFile := filepath.Join(root, defaultConfigFile)
+	userFile := filepath.Join(home, defaultConfigFile)
+	for _, f := range []string{projectFile, userFile} {
+		if err := os.WriteFile(f, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ConfigFiles(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, []string{projectFile, userFile}) {
+		t.Fatalf("unexpected config files %v", files)
+	}
+	if p, err := FindProject(filepath.Join(home, "src")); p != nil || err != nil {
+		t.Fatalf("no project expected above the root, got %#v, %v", p, err)
+	}
+
+	t.Setenv("PACKER_PROJECT_DIR", "none")
+	if p, err := FindProject(nested); p != nil || err != nil {
+		t.Fatalf("the discovery should be disabled, got %#v, %v", p, err)
+	}
+	t.Setenv("PACKER_PROJECT_DIR", nested)
+	if p, err := FindProject(tmp); err != nil || p.Root != nested {
+		t.Fatalf("the project should be the one of the env var, got %#v, %v", p, err)
+	}
+}
@@ -13,6 +13,7 @@ package plugin
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"net"
@@ -24,6 +25,7 @@ import (
 	"syscall"
 	"time"
 
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	packrpc "github.com/hashicorp/packer-plugin-sdk/rpc"
 	"github.com/hashicorp/packer-plugin-sdk/tmp"
 )
@@ -60,9 +62,29 @@ const (
 var ErrManuallyStartedPlugin = errors.New(
 	"Please do not execute plugins directly. Packer will execute these for you.")
 
+// ServeConfig configures how a plugin is served, with ServerWithConfig or
+// Set.SetServeConfig.
+type ServeConfig struct {
+	// RedirectStdout, once Packer connected to the plugin, replaces
+	// os.Stdout with a pipe forwarding what is written to it to Stdout, so
+	// that an accidental fmt.Println of the plugin or of its dependencies
+	// can't corrupt the protocol. What is written directly to the file
+	// descriptor of the standard output, like by C libraries, is not
+	// redirected.
+	RedirectStdout bool
+	// Stdout is where the output of the plugin is forwarded to with
+	// RedirectStdout, os.Stderr, logged by Packer, by default.
+	Stdout io.Writer
+}
+
 // Server waits for a connection to this plugin and returns a Packer
 // RPC server that you can use to register components and serve them.
 func Server() (*packrpc.PluginServer, error) {
+	return ServerWithConfig(ServeConfig{})
+}
+
+// ServerWithConfig is Server configured by c.
+func ServerWithConfig(c ServeConfig) (*packrpc.PluginServer, error) {
 	if os.Getenv(MagicCookieKey) != MagicCookieValue {
 		return nil, ErrManuallyStartedPlugin
 	}
@@ -72,6 +94,12 @@ func Server() (*packrpc.PluginServer, error) {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
 
+	// Redact the sensitive values from the logs of the plugin
+	if w := log.Writer(); w != &packersdk.LogSecretFilter {
+		packersdk.LogSecretFilter.SetOutput(w)
+		log.SetOutput(&packersdk.LogSecretFilter)
+	}
+
 	listener, err := serverListener()
 	if err != nil {
 		return nil, err
@@ -96,6 +124,20 @@ func Server() (*packrpc.PluginServer, error) {
 		return nil, err
 	}
 
+	// The standard output is redirected for as long as the plugin serves,
+	// so only once there is a connection to serve.
+	restore := func() {}
+	if c.RedirectStdout {
+		dst := c.Stdout
+		if dst == nil {
+			dst = os.Stderr
+		}
+		if restore, err = redirectStdout(dst); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
 	// Eat the interrupts
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
@@ -110,7 +152,39 @@ func Server() (*packrpc.PluginServer, error) {
 
 	// Serve a single connection
 	log.Println("Serving a plugin connection...")
-	return packrpc.NewServer(conn)
+	server, err := packrpc.NewServer(conn)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+	return server, nil
+}
+
+// redirectStdout replaces os.Stdout with a pipe forwarding what is written
+// to it to dst. restore puts the original os.Stdout back, once what was
+// written is forwarded.
+func redirectStdout(dst io.Writer) (restore func(), err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to redirect the standard output: %s", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	log.Printf("[DEBUG] Redirecting the standard output of the plugin")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := io.Copy(dst, r); err != nil {
+			log.Printf("[ERR] forwarding the standard output of the plugin: %s", err)
+		}
+		r.Close()
+	}()
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		<-done
+	}, nil
 }
 
 func serverListener() (net.Listener, error) {
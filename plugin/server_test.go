@@ -4,7 +4,10 @@
 package plugin
 
 import (
+	"bytes"
+	"fmt"
 	"math/rand"
+	"os"
 	"testing"
 )
 
@@ -13,3 +16,21 @@ func TestPluginServerRandom(t *testing.T) {
 		t.Fatal("math.rand is not seeded properly")
 	}
 }
+
+func TestRedirectStdout(t *testing.T) {
+	var buf bytes.Buffer
+	stdout := os.Stdout
+	restore, err := redirectStdout(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("accidental output")
+	restore()
+
+	if os.Stdout != stdout {
+		t.Fatal("os.Stdout should have been restored")
+	}
+	if buf.String() != "accidental output\n" {
+		t.Fatalf("bad forwarded output: %q", buf.String())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"sort"
 
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	pluginVersion "github.com/hashicorp/packer-plugin-sdk/version"
 )
@@ -34,6 +35,7 @@ type Set struct {
 	sdkVersion     string
 	apiVersion     string
 	useProto       bool
+	serveConfig    ServeConfig
 	Builders       map[string]packersdk.Builder
 	PostProcessors map[string]packersdk.PostProcessor
 	Provisioners   map[string]packersdk.Provisioner
@@ -48,6 +50,15 @@ type Set struct {
 // Packer and the plugins should use that for communication.
 const ProtocolVersion2 = "v2"
 
+// ProtocolVersion1 is the protocol of the plugins only supporting gob.
+const ProtocolVersion1 = "v1"
+
+// ProtocolVersionEnvVar, when set to ProtocolVersion1, makes the plugins
+// describe themselves as only supporting gob, so that Packer doesn't use
+// protobuf to talk to them. The acceptance tests use it to test both
+// protocols.
+const ProtocolVersionEnvVar = "PACKER_PLUGIN_PROTOCOL_VERSION"
+
 // SetDescription describes a Set.
 type SetDescription struct {
 	Version         string   `json:"version"`
@@ -79,6 +90,11 @@ func (i *Set) SetVersion(version *pluginVersion.PluginVersion) {
 	i.version = version.String()
 }
 
+// SetServeConfig configures how the components of the set are served.
+func (i *Set) SetServeConfig(c ServeConfig) {
+	i.serveConfig = c
+}
+
 func (i *Set) RegisterBuilder(name string, builder packersdk.Builder) {
 	if _, found := i.Builders[name]; found {
 		panic(fmt.Errorf("registering duplicate %s builder", name))
@@ -153,7 +169,10 @@ func (i *Set) RunCommand(args ...string) error {
 }
 
 func (i *Set) start(kind, name string) error {
-	server, err := Server()
+	if err := i.checkName(kind, name); err != nil {
+		return err
+	}
+	server, err := ServerWithConfig(i.serveConfig)
 	if err != nil {
 		return err
 	}
@@ -180,11 +199,34 @@ func (i *Set) start(kind, name string) error {
 	return nil
 }
 
+// checkName returns an error suggesting a component of the set when it has
+// no component of kind named name.
+func (i *Set) checkName(kind, name string) error {
+	var names []string
+	switch kind {
+	case "builder":
+		names = i.buildersDescription()
+	case "post-processor":
+		names = i.postProcessorsDescription()
+	case "provisioner":
+		names = i.provisionersDescription()
+	case "datasource":
+		names = i.datasourceDescription()
+	default:
+		return nil
+	}
+	return didyoumean.NewSet(kind, names...).Check(name)
+}
+
 ////
 // Describe
 ////
 
 func (i *Set) description() SetDescription {
+	protocolVersion := ProtocolVersion2
+	if os.Getenv(ProtocolVersionEnvVar) == ProtocolVersion1 {
+		protocolVersion = ""
+	}
 	return SetDescription{
 		Version:         i.version,
 		SDKVersion:      i.sdkVersion,
@@ -193,7 +235,7 @@ func (i *Set) description() SetDescription {
 		PostProcessors:  i.postProcessorsDescription(),
 		Provisioners:    i.provisionersDescription(),
 		Datasources:     i.datasourceDescription(),
-		ProtocolVersion: ProtocolVersion2,
+		ProtocolVersion: protocolVersion,
 	}
 }
 
@@ -68,6 +68,16 @@ func TestSet(t *testing.T) {
 	if diff := cmp.Diff(err.Error(), ErrManuallyStartedPlugin.Error()); diff != "" {
 		t.Fatalf("Unexpected error: %s", diff)
 	}
+
+	err = set.RunCommand("start", "builder", "exampel")
+	if want := `unknown builder "exampel", did you mean "example"?`; err == nil || err.Error() != want {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Setenv(ProtocolVersionEnvVar, ProtocolVersion1)
+	if got := set.description().ProtocolVersion; got != "" {
+		t.Fatalf("the plugin should not describe protocol v2 when forced to v1, got %q", got)
+	}
 }
 
 func TestSetProtobufArgParsing(t *testing.T) {
@@ -0,0 +1,213 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package random
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+var (
+	// PasswordSpecialCharacters are the special characters of the default
+	// password policy, picked to seldom need escaping in XML, JSON and
+	// quoted shell or PowerShell strings, like the ones of WinRM
+	// passwords.
+	PasswordSpecialCharacters = "%*+-.:=?@^_~"
+
+	// AmbiguousCharacters are the characters that look alike in many fonts,
+	// excluded by PasswordPolicy.ExcludeAmbiguous.
+	AmbiguousCharacters = "0O1lI|`'\""
+)
+
+// CharClass is a class of characters of a password, like the uppercase
+// letters, and the minimum number of them in the password.
+type CharClass struct {
+	Chars string
+	Min   int
+}
+
+// DefaultPasswordClasses are the classes of the passwords of the default
+// policy: at least one lowercase letter, uppercase letter, number and
+// special character, as required by the password policies of most systems.
+var DefaultPasswordClasses = []CharClass{
+	{Chars: PossibleLowerCase, Min: 1},
+	{Chars: PossibleUpperCase, Min: 1},
+	{Chars: PossibleNumbers, Min: 1},
+	{Chars: PasswordSpecialCharacters, Min: 1},
+}
+
+// PasswordPolicy generates random passwords, with crypto/rand, for the
+// passwords and temporary credentials of builds. The zero value generates
+// passwords of 24 characters of DefaultPasswordClasses.
+type PasswordPolicy struct {
+	// Length is the length of the passwords, 24 by default.
+	Length int
+	// Classes are the classes of characters of the passwords,
+	// DefaultPasswordClasses by default. The characters of all the classes
+	// are equally likely beyond the minimum of each class.
+	Classes []CharClass
+	// ExcludeAmbiguous excludes AmbiguousCharacters, for the passwords
+	// read by humans.
+	ExcludeAmbiguous bool
+	// Exclude are characters excluded from the passwords, like the ones a
+	// system refuses.
+	Exclude string
+}
+
+// Generate returns a random password following p, or an error if p can't be
+// satisfied.
+func (p PasswordPolicy) Generate() (string, error) {
+	length := p.Length
+	if length == 0 {
+		length = 24
+	}
+	classes := p.Classes
+	if classes == nil {
+		classes = DefaultPasswordClasses
+	}
+	exclude := p.Exclude
+	if p.ExcludeAmbiguous {
+		exclude += AmbiguousCharacters
+	}
+
+	var all []byte
+	var password []byte
+	seen := map[byte]bool{}
+	for _, class := range classes {
+		chars := filterChars(class.Chars, exclude)
+		if class.Min > 0 && len(chars) == 0 {
+			return "", fmt.Errorf("no character left in class %q once excluded %q", class.Chars, exclude)
+		}
+		for i := 0; i < class.Min; i++ {
+			c, err := pick(chars)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+		for _, c := range []byte(chars) {
+			if !seen[c] {
+				seen[c] = true
+				all = append(all, c)
+			}
+		}
+	}
+	if len(password) > length {
+		return "", fmt.Errorf("the minimums of the classes, %d characters, exceed the length %d", len(password), length)
+	}
+	if len(all) == 0 {
+		return "", fmt.Errorf("no character to generate the password from")
+	}
+	for len(password) < length {
+		c, err := pick(string(all))
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// shuffle the characters of the classes in
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// filterChars returns chars without the characters of exclude.
+func filterChars(chars, exclude string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(exclude, r) {
+			return -1
+		}
+		return r
+	}, chars)
+}
+
+// PassphrasePolicy generates random passphrases of words, with crypto/rand,
+// easier to type than passwords of the same strength. The zero value
+// generates passphrases of 8 words of Words separated by dashes, about 65
+// bits of entropy.
+type PassphrasePolicy struct {
+	// Length is the number of words of the passphrases, 8 by default.
+	Length int
+	// Separator separates the words, "-" by default.
+	Separator string
+	// Wordlist is the list the words are picked from, Words by default.
+	Wordlist []string
+	// Capitalize capitalizes the words.
+	Capitalize bool
+	// Number appends a random number to a random word, for the systems
+	// requiring one.
+	Number bool
+}
+
+// Generate returns a random passphrase following p.
+func (p PassphrasePolicy) Generate() (string, error) {
+	length := p.Length
+	if length == 0 {
+		length = 8
+	}
+	separator := p.Separator
+	if separator == "" {
+		separator = "-"
+	}
+	wordlist := p.Wordlist
+	if wordlist == nil {
+		wordlist = Words
+	}
+	if len(wordlist) == 0 {
+		return "", fmt.Errorf("no word to generate the passphrase from")
+	}
+
+	words := make([]string, length)
+	for i := range words {
+		n, err := randomInt(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		words[i] = wordlist[n]
+		if p.Capitalize && words[i] != "" {
+			r := []rune(words[i])
+			r[0] = unicode.ToUpper(r[0])
+			words[i] = string(r)
+		}
+	}
+	if p.Number {
+		i, err := randomInt(length)
+		if err != nil {
+			return "", err
+		}
+		n, err := randomInt(10)
+		if err != nil {
+			return "", err
+		}
+		words[i] += fmt.Sprint(n)
+	}
+	return strings.Join(words, separator), nil
+}
+
+// pick returns a random character of chars.
+func pick(chars string) (byte, error) {
+	n, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n], nil
+}
+
+// randomInt returns a uniform random number in [0, n) from crypto/rand.
+func randomInt(n int) (int, error) {
+	i, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read random numbers: %s", err)
+	}
+	return int(i.Int64()), nil
+}
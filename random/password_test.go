@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy_Generate(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		password, err := PasswordPolicy{Length: 8}.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 8 {
+			t.Fatalf("bad length: %q", password)
+		}
+		for _, class := range DefaultPasswordClasses {
+			if !strings.ContainsAny(password, class.Chars) {
+				t.Fatalf("%q has no character of %q", password, class.Chars)
+			}
+		}
+	}
+
+	password, err := PasswordPolicy{Length: 500, ExcludeAmbiguous: true, Exclude: "xyz"}.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(password, AmbiguousCharacters+"xyz") {
+		t.Fatalf("%q has excluded characters", password)
+	}
+
+	for _, p := range []PasswordPolicy{
+		{Length: 3},
+		{Classes: []CharClass{{Chars: "01", Min: 1}}, ExcludeAmbiguous: true},
+		{Classes: []CharClass{}},
+	} {
+		if _, err := p.Generate(); err == nil {
+			t.Fatalf("%#v should not be satisfiable", p)
+		}
+	}
+}
+
+func TestPasswordPolicy_Generate_distribution(t *testing.T) {
+	p := PasswordPolicy{
+		Length:  1000,
+		Classes: []CharClass{{Chars: "abcd"}, {Chars: "0123"}},
+	}
+	counts := map[rune]int{}
+	for i := 0; i < 40; i++ {
+		password, err := p.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range password {
+			counts[c]++
+		}
+	}
+	// 40000 characters out of 8, 5000 of each expected
+	if len(counts) != 8 {
+		t.Fatalf("unexpected characters: %v", counts)
+	}
+	for c, n := range counts {
+		if n < 4500 || n > 5500 {
+			t.Fatalf("%q is not uniformly distributed: %v", c, counts)
+		}
+	}
+}
+
+func TestPassphrasePolicy_Generate(t *testing.T) {
+	passphrase, err := PassphrasePolicy{}.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := strings.Split(passphrase, "-"); len(words) != 8 {
+		t.Fatalf("bad passphrase: %q", passphrase)
+	}
+
+	passphrase, err = PassphrasePolicy{
+		Length:     3,
+		Separator:  " ",
+		Wordlist:   []string{"packer"},
+		Capitalize: true,
+		Number:     true,
+	}.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(passphrase, "Packer") || strings.Count(passphrase, "Packer") != 3 || !strings.ContainsAny(passphrase, PossibleNumbers) {
+		t.Fatalf("bad passphrase: %q", passphrase)
+	}
+}
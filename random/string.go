@@ -1,7 +1,8 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
-// Package random is a helper for generating random alphanumeric strings.
+// Package random is a helper for generating random alphanumeric strings, and
+// passwords and passphrases following a policy.
 package random
 
 import (
@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package random
+
+// Words is the default word list of the passphrases, of short, common and
+// unambiguous words, giving about 8.2 bits of entropy per word.
+var Words = []string{
+	"acid", "acorn", "actor", "adobe", "agent", "album", "alert", "alley",
+	"alpha", "amber", "angle", "ankle", "apple", "apron", "arena", "argue",
+	"armor", "arrow", "aspen", "atlas", "attic", "audio", "author", "autumn",
+	"avocado", "badge", "bagel", "baker", "balmy", "bamboo", "banjo", "barn",
+	"basil", "batch", "beach", "beacon", "beard", "berry", "bison", "blade",
+	"blank", "blaze", "blend", "bloom", "blues", "board", "bonus", "boost",
+	"boots", "brave", "bread", "brick", "bridge", "brisk", "brook", "brush",
+	"bucket", "buddy", "bugle", "cabin", "cable", "cactus", "camel", "candy",
+	"canoe", "canyon", "cargo", "carpet", "carrot", "castle", "cedar",
+	"chalk", "charm", "cherry", "chess", "chief", "chime", "cider", "cinema",
+	"circus", "citrus", "clamp", "cliff", "cloud", "clover", "coach", "cobra",
+	"cocoa", "comet", "coral", "cotton", "cougar", "crane", "crater",
+	"crayon", "creek", "crisp", "crown", "cubic", "curry", "daisy", "dance",
+	"delta", "denim", "depot", "desert", "diary", "dingo", "disco", "dolphin",
+	"donut", "dragon", "drift", "drum", "eagle", "easel", "ebony", "echo",
+	"elbow", "elder", "ember", "emerald", "engine", "epoch", "falcon",
+	"fable", "fancy", "feast", "fern", "ferry", "fiddle", "field", "flame",
+	"flint", "flute", "focus", "forest", "fossil", "fox", "frost", "fudge",
+	"galaxy", "garden", "garlic", "gecko", "gentle", "geyser", "ginger",
+	"glacier", "glide", "globe", "gnome", "goose", "grape", "gravel",
+	"guitar", "habit", "hammer", "harbor", "hazel", "heron", "hiker", "honey",
+	"hornet", "husky", "igloo", "index", "iris", "island", "ivory", "jacket",
+	"jaguar", "jelly", "jewel", "jigsaw", "jolly", "juice", "jungle", "kayak",
+	"kettle", "kiwi", "koala", "ladder", "lagoon", "lantern", "laser",
+	"lemon", "lilac", "lime", "lizard", "llama", "lobster", "locket", "lotus",
+	"lunar", "magnet", "mango", "maple", "marble", "meadow", "melon", "mint",
+	"mocha", "monkey", "mosaic", "motor", "muffin", "nectar", "needle",
+	"noble", "nova", "oasis", "ocean", "olive", "onion", "opal", "orbit",
+	"orchid", "otter", "oyster", "paddle", "panda", "papaya", "parrot",
+	"pasta", "peach", "pebble", "pepper", "piano", "pickle", "pilot", "pixel",
+	"plaza", "plum", "polar", "pony", "poppy", "prism", "pumpkin", "puzzle",
+	"quartz", "quill", "rabbit", "radar", "radish", "raven", "ribbon",
+	"river", "robin", "rocket", "rover", "ruby", "saddle", "salmon", "sandal",
+	"scarf", "shadow", "sierra", "silver", "sketch", "slope", "sonic",
+	"spark", "spruce", "squid", "stable", "starch", "storm", "sugar",
+	"summit", "sunny", "swift", "tango", "thistle", "thunder", "tiger",
+	"timber", "toast", "topaz", "torch", "tulip", "tundra", "turtle",
+	"umbrella", "unicorn", "valley", "velvet", "violet", "visor", "waffle",
+	"walnut", "willow", "window", "wizard", "yacht", "yogurt", "zebra",
+	"zephyr", "zinc",
+}
@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Config.Run when the circuit breaker of its
+// Budget was open until the end of the run, before the function could run.
+var ErrCircuitOpen = errors.New("retry: circuit breaker open")
+
+// Budget is shared by the retry configs of all the calls to a same API, so
+// that a builder throttled by the API backs off as a whole, rather than each
+// of its steps retrying into the rate limit. A Budget must not be copied
+// once used.
+//
+// It limits the number of retries done in a time window by all its users,
+// and has a circuit breaker: once enough consecutive retryable failures
+// happened, all the users wait before trying again, including the first try
+// of a call.
+type Budget struct {
+	// MaxRetries is the number of retries allowed in Window. Users wait for
+	// the oldest retry of the window to leave it before retrying again. 0
+	// means infinite.
+	MaxRetries int
+	// Window is the time window of MaxRetries. Defaults to 1 minute.
+	Window time.Duration
+
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit. 0 disables the circuit breaker.
+	FailureThreshold int
+	// Cooldown is the time the circuit stays open. Once elapsed, the
+	// circuit is half open: a success closes it, a failure opens it again.
+	// Defaults to 30 seconds.
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	retries   []time.Time
+	failures  int
+	halfOpen  bool
+	openUntil time.Time
+}
+
+// Open returns whether the circuit is open.
+func (b *Budget) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// reserve returns the time to wait before trying, and when it is 0, counts a
+// retry when retry is set.
+func (b *Budget) reserve(retry bool) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		return b.openUntil.Sub(now)
+	}
+	if !retry || b.MaxRetries == 0 {
+		return 0
+	}
+
+	window := b.Window
+	if window == 0 {
+		window = time.Minute
+	}
+	for len(b.retries) > 0 && now.Sub(b.retries[0]) >= window {
+		b.retries = b.retries[1:]
+	}
+	if len(b.retries) >= b.MaxRetries {
+		return b.retries[0].Add(window).Sub(now)
+	}
+	b.retries = append(b.retries, now)
+	return 0
+}
+
+// record records the result of a try, nil for a success, and opens the
+// circuit when needed.
+func (b *Budget) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.halfOpen = false
+		return
+	}
+	if b.FailureThreshold == 0 {
+		return
+	}
+	b.failures++
+	if b.halfOpen || b.failures >= b.FailureThreshold {
+		cooldown := b.Cooldown
+		if cooldown == 0 {
+			cooldown = 30 * time.Second
+		}
+		b.openUntil = time.Now().Add(cooldown)
+		b.failures = 0
+		b.halfOpen = true
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudget_maxRetries(t *testing.T) {
+	b := &Budget{MaxRetries: 1, Window: 100 * time.Millisecond}
+	noDelay := func() time.Duration { return 0 }
+
+	start := time.Now()
+	err := Config{Tries: 2, RetryDelay: noDelay, Budget: b}.Run(context.Background(), fail)
+	if !errors.Is(err, failErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatal("the first retry shouldn't wait")
+	}
+
+	// The retry of another config waits for the window.
+	err = Config{Tries: 2, RetryDelay: noDelay, Budget: b}.Run(context.Background(), fail)
+	if !errors.Is(err, failErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected the retry to wait for the budget, waited %s", elapsed)
+	}
+}
+
+func TestBudget_circuitBreaker(t *testing.T) {
+	b := &Budget{FailureThreshold: 2, Cooldown: 100 * time.Millisecond}
+	noDelay := func() time.Duration { return 0 }
+
+	err := Config{Tries: 2, RetryDelay: noDelay, Budget: b}.Run(context.Background(), fail)
+	if !errors.Is(err, failErr) || !b.Open() {
+		t.Fatalf("expected the circuit to be open, got %v", err)
+	}
+
+	// Calls don't start while the circuit is open.
+	ran := false
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = Config{Budget: b}.Run(ctx, func(context.Context) error {
+		ran = true
+		return nil
+	})
+	if err != ErrCircuitOpen || ran {
+		t.Fatalf("expected the call not to run, got %v", err)
+	}
+
+	// Non retryable errors don't count.
+	notRetryable := errors.New("not found")
+	err = Config{Budget: b, ShouldRetry: func(err error) bool { return err != notRetryable }}.Run(context.Background(),
+		func(context.Context) error { return notRetryable })
+	if err != notRetryable || b.Open() {
+		t.Fatalf("expected the call to run once the circuit is half open, got %v", err)
+	}
+
+	// A failure of a half open circuit opens it again.
+	Config{Tries: 1, Budget: b}.Run(context.Background(), fail)
+	if !b.Open() {
+		t.Fatal("expected the circuit to be open again")
+	}
+
+	// A success closes it.
+	time.Sleep(100 * time.Millisecond)
+	if err := (Config{Budget: b}).Run(context.Background(), success); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	Config{Tries: 1, Budget: b}.Run(context.Background(), fail)
+	if b.Open() {
+		t.Fatal("expected the circuit to stay closed after a single failure")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Retryability is whether an error is worth retrying.
+type Retryability int
+
+const (
+	// Unknown means the classifier doesn't know the error.
+	Unknown Retryability = iota
+	// Retryable means the error is transient, like a throttling error.
+	Retryable
+	// NotRetryable means trying again would fail the same way.
+	NotRetryable
+)
+
+// Classifier tells whether an error is worth retrying. It returns Unknown
+// for the errors it doesn't know, so that the next classifiers of a
+// Registry are asked.
+type Classifier func(error) Retryability
+
+// Registry is an ordered list of classifiers. Its ShouldRetry method can be
+// set as Config.ShouldRetry, rather than matching the messages of errors
+// with regular expressions. Plugins register the classifiers of the errors
+// of their cloud SDKs first, before the built-in ones.
+type Registry struct {
+	mu          sync.RWMutex
+	classifiers []Classifier
+}
+
+// NewRegistry returns a registry asking classifiers in order.
+func NewRegistry(classifiers ...Classifier) *Registry {
+	return &Registry{classifiers: classifiers}
+}
+
+// DefaultRegistry classifies the common transport errors: the cancellation
+// of the context, timeouts, connections reset or refused, and HTTP statuses
+// like 429 or 503.
+var DefaultRegistry = NewRegistry(ClassifyContext, ClassifyTimeout, ClassifyConnection, ClassifyHTTPStatus)
+
+// Register adds classifiers before the ones of the registry, so that they
+// take precedence.
+func (r *Registry) Register(classifiers ...Classifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.classifiers = append(append([]Classifier(nil), classifiers...), r.classifiers...)
+}
+
+// Classify returns the answer of the first classifier knowing err, Unknown
+// when none does.
+func (r *Registry) Classify(err error) Retryability {
+	if err == nil {
+		return NotRetryable
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.classifiers {
+		if retryability := c(err); retryability != Unknown {
+			return retryability
+		}
+	}
+	return Unknown
+}
+
+// ShouldRetry returns whether err is Retryable.
+func (r *Registry) ShouldRetry(err error) bool {
+	return r.Classify(err) == Retryable
+}
+
+// ClassifyContext tells that a cancelled context is not retryable.
+func ClassifyContext(err error) Retryability {
+	if errors.Is(err, context.Canceled) {
+		return NotRetryable
+	}
+	return Unknown
+}
+
+// ClassifyTimeout tells that timeouts, like the ones of net.Error, are
+// retryable.
+func ClassifyTimeout(err error) Retryability {
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return Retryable
+	}
+	return Unknown
+}
+
+// ClassifyConnection tells that connections reset, refused, aborted or
+// closed by the peer are retryable.
+func ClassifyConnection(err error) Retryability {
+	for _, target := range []error{
+		syscall.ECONNRESET,
+		syscall.ECONNREFUSED,
+		syscall.ECONNABORTED,
+		syscall.EPIPE,
+		io.ErrUnexpectedEOF,
+	} {
+		if errors.Is(err, target) {
+			return Retryable
+		}
+	}
+	return Unknown
+}
+
+// ClassifyHTTPStatus classifies the errors having an HTTP status code, with
+// a StatusCode() int or HTTPStatusCode() int method: 408, 429 and the 5xx
+// server errors but 501 and 505 are retryable, the other 4xx client errors
+// are not.
+func ClassifyHTTPStatus(err error) Retryability {
+	code, ok := httpStatusCode(err)
+	if !ok {
+		return Unknown
+	}
+	switch {
+	case code == 408 || code == 429:
+		return Retryable
+	case code == 501 || code == 505:
+		return NotRetryable
+	case code >= 500 && code < 600:
+		return Retryable
+	case code >= 400 && code < 500:
+		return NotRetryable
+	}
+	return Unknown
+}
+
+func httpStatusCode(err error) (int, bool) {
+	var s interface{ StatusCode() int }
+	if errors.As(err, &s) {
+		return s.StatusCode(), true
+	}
+	var h interface{ HTTPStatusCode() int }
+	if errors.As(err, &h) {
+		return h.HTTPStatusCode(), true
+	}
+	return 0, false
+}
+
+// ClassifyMessages returns a classifier answering retryability for the
+// errors whose message contains one of substrings, for the SDKs whose errors
+// can't be told apart otherwise.
+func ClassifyMessages(retryability Retryability, substrings ...string) Classifier {
+	return func(err error) Retryability {
+		msg := err.Error()
+		for _, s := range substrings {
+			if strings.Contains(msg, s) {
+				return retryability
+			}
+		}
+		return Unknown
+	}
+}
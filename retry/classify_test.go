@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type statusError int
+
+func (err statusError) Error() string   { return fmt.Sprintf("status %d", int(err)) }
+func (err statusError) StatusCode() int { return int(err) }
+
+type sdkError struct{ code int }
+
+func (err *sdkError) Error() string       { return "api error" }
+func (err *sdkError) HTTPStatusCode() int { return err.code }
+
+func TestDefaultRegistry(t *testing.T) {
+	cases := []struct {
+		err  error
+		want Retryability
+	}{
+		{context.Canceled, NotRetryable},
+		{fmt.Errorf("describing images: %w", context.Canceled), NotRetryable},
+		{&url.Error{Op: "Get", URL: "https://api", Err: os.ErrDeadlineExceeded}, Retryable},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, Retryable},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), Retryable},
+		{io.ErrUnexpectedEOF, Retryable},
+		{statusError(429), Retryable},
+		{statusError(503), Retryable},
+		{statusError(501), NotRetryable},
+		{statusError(404), NotRetryable},
+		{fmt.Errorf("CreateImage: %w", &sdkError{code: 500}), Retryable},
+		{&sdkError{code: 403}, NotRetryable},
+		{statusError(302), Unknown},
+		{errors.New("woops"), Unknown},
+	}
+	for _, tc := range cases {
+		if got := DefaultRegistry.Classify(tc.err); got != tc.want {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.want, got)
+		}
+	}
+	if DefaultRegistry.ShouldRetry(errors.New("woops")) || !DefaultRegistry.ShouldRetry(statusError(429)) {
+		t.Fatal("only retryable errors should be retried")
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry(ClassifyHTTPStatus)
+	r.Register(ClassifyMessages(NotRetryable, "QuotaExceeded"), ClassifyMessages(Retryable, "Throttling"))
+
+	if got := r.Classify(errors.New("Throttling: rate exceeded")); got != Retryable {
+		t.Fatalf("expected the registered classifier to be used, got %d", got)
+	}
+	// The registered classifiers take precedence.
+	err := fmt.Errorf("QuotaExceeded: %w", statusError(503))
+	if got := r.Classify(err); got != NotRetryable {
+		t.Fatalf("expected the registered classifier to take precedence, got %d", got)
+	}
+
+	tries := 0
+	cfg := Config{Tries: 5, RetryDelay: func() time.Duration { return 0 }, ShouldRetry: r.ShouldRetry}
+	cfg.Run(context.Background(), func(context.Context) error {
+		tries++
+		if tries < 3 {
+			return statusError(503)
+		}
+		return statusError(404)
+	})
+	if tries != 3 {
+		t.Fatalf("expected to stop at the first not retryable error, tried %d times", tries)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfig_Run_hooks(t *testing.T) {
+	var messages []string
+	cfg := Config{
+		Tries:      3,
+		RetryDelay: func() time.Duration { return time.Millisecond },
+		OnRetry: func(a Attempt) {
+			messages = append(messages, a.Message("CreateImage"))
+		},
+		OnGiveUp: func(a Attempt) {
+			if a.Err != failErr {
+				t.Fatalf("expected the last error of the function, got %v", a.Err)
+			}
+			messages = append(messages, a.Message("CreateImage"))
+		},
+	}
+	if _, ok := cfg.Run(context.Background(), fail).(*RetryExhaustedError); !ok {
+		t.Fatal("expected the retries to be exhausted")
+	}
+	expected := []string{
+		"Retrying CreateImage in 1ms (attempt 1/3): woops !",
+		"Retrying CreateImage in 1ms (attempt 2/3): woops !",
+		"Giving up on CreateImage after 3 attempts: woops !",
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Fatalf("expected %q, got %q", expected, messages)
+		}
+	}
+
+	// OnGiveUp is not called on success.
+	messages = nil
+	if err := cfg.Run(context.Background(), new(failOnce).Run); err != nil || len(messages) != 1 {
+		t.Fatalf("unexpected result %v, %q", err, messages)
+	}
+	if m := (Attempt{Number: 4, Delay: time.Second, Err: failErr}).Message("Start"); m != "Retrying Start in 1s (attempt 4): woops !" {
+		t.Fatalf("unexpected message %q", m)
+	}
+}
@@ -19,15 +19,64 @@ type Config struct {
 	StartTimeout time.Duration
 
 	// RetryDelay gives the time elapsed after a failure and before we try
-	// again. Returns 2s by default.
+	// again. Returns 2s by default, unless Strategy is set.
 	RetryDelay func() time.Duration
 
+	// Strategy gives the time elapsed after a failure and before we try
+	// again when RetryDelay is nil, see ExponentialJitter, DecorrelatedJitter
+	// and CappedLinear. When it is set, Run wraps the last error of the
+	// function in an AttemptError.
+	Strategy Strategy
+
 	// Max number of retries, 0 means infinite
 	Tries int
 
 	// ShouldRetry tells whether error should be retried. Nil defaults to always
 	// true.
 	ShouldRetry func(error) bool
+
+	// Budget is shared with the other configs retrying calls to the same
+	// API. Nil means the tries are not limited by the other calls.
+	Budget *Budget
+
+	// OnRetry is called after each retryable failure, before waiting to try
+	// again, so that long waits don't look like hangs. See
+	// Attempt.Message.
+	OnRetry func(Attempt)
+
+	// OnGiveUp is called with the last failure when Run returns an error.
+	OnGiveUp func(Attempt)
+}
+
+// Attempt describes a failed try of the function run by Config.Run.
+type Attempt struct {
+	// Number is the number of the try, counted from 1. It is 0 when the
+	// function didn't run, because the circuit of the Budget was open.
+	Number int
+	// MaxTries is Config.Tries, 0 meaning infinite.
+	MaxTries int
+	// Delay is the time waited before the next try, 0 when giving up.
+	Delay time.Duration
+	// Elapsed is the time elapsed since the first try.
+	Elapsed time.Duration
+	// Err is the error of the function.
+	Err error
+	// Final is set when Run gives up.
+	Final bool
+}
+
+// Message returns a message describing the attempt of operation, for a Ui,
+// like "Retrying CreateImage in 4s (attempt 3/10): Throttled", or "Giving up
+// on CreateImage after 3 attempts: Throttled" when it is final.
+func (a Attempt) Message(operation string) string {
+	if a.Final {
+		return fmt.Sprintf("Giving up on %s after %d attempts: %s", operation, a.Number, a.Err)
+	}
+	attempt := fmt.Sprint(a.Number)
+	if a.MaxTries != 0 {
+		attempt = fmt.Sprintf("%d/%d", a.Number, a.MaxTries)
+	}
+	return fmt.Sprintf("Retrying %s in %s (attempt %s): %s", operation, a.Delay.Round(time.Millisecond), attempt, a.Err)
 }
 
 type RetryExhaustedError struct {
@@ -41,9 +90,14 @@ func (err *RetryExhaustedError) Error() string {
 	return fmt.Sprintf("retry count exhausted. Last err: %s", err.Err)
 }
 
+func (err *RetryExhaustedError) Unwrap() error {
+	return err.Err
+}
+
 // Run will repeatedly retry the proivided fn within the constraints set in the
 // retry Config. It will retry until one of the following conditions is met:
-//   - The provided context is cancelled.
+//   - The provided context is cancelled, or its deadline would pass before
+//     the next try.
 //   - The Config.StartTimeout time has passed.
 //   - The function returns without an error.
 //   - The maximum number of tries, Config.Tries is exceeded.
@@ -53,9 +107,11 @@ func (err *RetryExhaustedError) Error() string {
 // If the given function (fn) does not return an error, then Run will return
 // nil. Otherwise, Run will return a relevant error.
 func (cfg Config) Run(ctx context.Context, fn func(context.Context) error) error {
-	retryDelay := func() time.Duration { return 2 * time.Second }
+	retryDelay := func(int, time.Duration) time.Duration { return 2 * time.Second }
 	if cfg.RetryDelay != nil {
-		retryDelay = cfg.RetryDelay
+		retryDelay = func(int, time.Duration) time.Duration { return cfg.RetryDelay() }
+	} else if cfg.Strategy != nil {
+		retryDelay = cfg.Strategy
 	}
 	shouldRetry := func(error) bool { return true }
 	if cfg.ShouldRetry != nil {
@@ -66,27 +122,100 @@ func (cfg Config) Run(ctx context.Context, fn func(context.Context) error) error
 		startTimeout = time.After(cfg.StartTimeout)
 	}
 
+	start := time.Now()
+	giveUp := func(err error, attempts int, exhausted bool) error {
+		if cfg.OnGiveUp != nil {
+			cfg.OnGiveUp(Attempt{
+				Number:   attempts,
+				MaxTries: cfg.Tries,
+				Elapsed:  time.Since(start),
+				Err:      err,
+				Final:    true,
+			})
+		}
+		if exhausted {
+			err = &RetryExhaustedError{err}
+		}
+		if cfg.Strategy == nil {
+			return err
+		}
+		return &AttemptError{Err: err, Attempts: attempts, Elapsed: time.Since(start)}
+	}
+
 	var err error
+	var delay time.Duration
 	for try := 0; ; try++ {
-		if cfg.Tries != 0 && try == cfg.Tries {
-			return &RetryExhaustedError{err}
+		if cfg.Budget != nil {
+			if werr := cfg.waitBudget(ctx, startTimeout, try > 0); werr != nil {
+				if err == nil {
+					err = werr
+				}
+				return giveUp(err, try, false)
+			}
+		}
+		err = fn(ctx)
+		if cfg.Budget != nil && (err == nil || shouldRetry(err)) {
+			cfg.Budget.record(err)
 		}
-		if err = fn(ctx); err == nil {
+		if err == nil {
 			return nil
 		}
 		if !shouldRetry(err) {
-			return err
+			return giveUp(err, try+1, false)
+		}
+		if cfg.Tries != 0 && try+1 == cfg.Tries {
+			return giveUp(err, try+1, true)
 		}
 
 		log.Print(fmt.Errorf("Retryable error: %s", err))
 
+		delay = retryDelay(try+1, delay)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return giveUp(err, try+1, false)
+		}
+		if cfg.OnRetry != nil {
+			cfg.OnRetry(Attempt{
+				Number:   try + 1,
+				MaxTries: cfg.Tries,
+				Delay:    delay,
+				Elapsed:  time.Since(start),
+				Err:      err,
+			})
+		}
+		timer := time.NewTimer(delay)
 		select {
 		case <-ctx.Done():
-			return err
+			timer.Stop()
+			return giveUp(err, try+1, false)
 		case <-startTimeout:
-			return err
-		default:
-			time.Sleep(retryDelay())
+			timer.Stop()
+			return giveUp(err, try+1, false)
+		case <-timer.C:
+		}
+	}
+}
+
+// waitBudget waits for the budget to allow a try, and returns an error when
+// the run ends before.
+func (cfg Config) waitBudget(ctx context.Context, startTimeout <-chan time.Time, retry bool) error {
+	for {
+		wait := cfg.Budget.reserve(retry)
+		if wait == 0 {
+			return nil
+		}
+		log.Printf("Waiting %s for the retry budget", wait)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return ErrCircuitOpen
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ErrCircuitOpen
+		case <-startTimeout:
+			timer.Stop()
+			return ErrCircuitOpen
+		case <-timer.C:
 		}
 	}
 }
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Strategy returns the time to wait after the given failed attempt, counted
+// from 1, and before the next one. previous is the time waited before the
+// failed attempt, 0 after the first one.
+type Strategy func(attempt int, previous time.Duration) time.Duration
+
+// ExponentialJitter returns a strategy waiting a random time between 0 and
+// base * 2^(attempt-1), capped to max, the "full jitter" of
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+// Randomizing the delays spreads the tries of concurrent callers throttled
+// at the same time.
+func ExponentialJitter(base, max time.Duration) Strategy {
+	return func(attempt int, _ time.Duration) time.Duration {
+		return randomDuration(0, exponential(base, max, attempt))
+	}
+}
+
+// DecorrelatedJitter returns a strategy waiting a random time between base
+// and three times the previous delay, capped to max. It backs off about as
+// fast as ExponentialJitter, with delays closer to max.
+func DecorrelatedJitter(base, max time.Duration) Strategy {
+	return func(_ int, previous time.Duration) time.Duration {
+		if previous < base {
+			previous = base
+		}
+		upper := previous * 3
+		if upper < previous { // overflow
+			upper = max
+		}
+		return capDuration(randomDuration(base, upper), max)
+	}
+}
+
+// CappedLinear returns a strategy waiting initial, then step longer after
+// each attempt, up to max.
+func CappedLinear(initial, step, max time.Duration) Strategy {
+	return func(attempt int, _ time.Duration) time.Duration {
+		return capDuration(initial+time.Duration(attempt-1)*step, max)
+	}
+}
+
+func exponential(base, max time.Duration, attempt int) time.Duration {
+	d := float64(base) * math.Pow(2, float64(attempt-1))
+	if d > float64(math.MaxInt64) {
+		d = float64(math.MaxInt64)
+	}
+	return capDuration(time.Duration(d), max)
+}
+
+// randomDuration returns a random duration in [min, max).
+func randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)))
+}
+
+// capDuration caps d to max, unless max is 0.
+func capDuration(d, max time.Duration) time.Duration {
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// AttemptError is the last error of the function run by Config.Run when it
+// gave up with a Strategy set.
+type AttemptError struct {
+	// Err is the last error of the function, or a RetryExhaustedError
+	// wrapping it when the tries were exhausted.
+	Err error
+	// Attempts is the number of times the function ran.
+	Attempts int
+	// Elapsed is the time elapsed since the first attempt.
+	Elapsed time.Duration
+}
+
+func (err *AttemptError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts in %s: %s", err.Attempts, err.Elapsed.Round(time.Millisecond), err.Err)
+}
+
+func (err *AttemptError) Unwrap() error {
+	return err.Err
+}
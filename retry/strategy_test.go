@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExponentialJitter(t *testing.T) {
+	s := ExponentialJitter(time.Second, 10*time.Second)
+	for attempt := 1; attempt < 100; attempt++ {
+		upper := 10 * time.Second
+		if attempt <= 4 {
+			upper = time.Second << (attempt - 1)
+		}
+		if d := s(attempt, 0); d < 0 || d >= upper {
+			t.Fatalf("attempt %d: delay %s out of [0, %s)", attempt, d, upper)
+		}
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	s := DecorrelatedJitter(time.Second, 10*time.Second)
+	var previous time.Duration
+	for attempt := 1; attempt < 100; attempt++ {
+		d := s(attempt, previous)
+		lower, upper := time.Second, 3*previous
+		if upper < 3*time.Second {
+			upper = 3 * time.Second
+		}
+		if upper > 10*time.Second {
+			upper = 10 * time.Second
+		}
+		if d < lower || d > upper {
+			t.Fatalf("attempt %d: delay %s after %s out of [%s, %s]", attempt, d, previous, lower, upper)
+		}
+		previous = d
+	}
+}
+
+func TestCappedLinear(t *testing.T) {
+	s := CappedLinear(time.Second, 2*time.Second, 4*time.Second)
+	for attempt, want := range []time.Duration{time.Second, 3 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if d := s(attempt+1, 0); d != want {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt+1, want, d)
+		}
+	}
+}
+
+func TestConfig_Run_strategy(t *testing.T) {
+	var delays []time.Duration
+	cfg := Config{
+		Tries: 3,
+		Strategy: func(attempt int, previous time.Duration) time.Duration {
+			delays = append(delays, previous)
+			return time.Duration(attempt) * time.Millisecond
+		},
+	}
+	err := cfg.Run(context.Background(), fail)
+	var attemptErr *AttemptError
+	if !errors.As(err, &attemptErr) || attemptErr.Attempts != 3 {
+		t.Fatalf("expected an AttemptError after 3 attempts, got %#v", err)
+	}
+	var exhausted *RetryExhaustedError
+	if !errors.As(err, &exhausted) || !errors.Is(err, failErr) {
+		t.Fatalf("expected the exhausted last error to be wrapped, got %v", err)
+	}
+	if len(delays) != 2 || delays[0] != 0 || delays[1] != time.Millisecond {
+		t.Fatalf("expected the previous delays to be passed, got %v", delays)
+	}
+
+	// Run gives up when the deadline of the context would pass before the
+	// next try.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cfg = Config{Strategy: CappedLinear(time.Minute, 0, 0)}
+	start := time.Now()
+	err = cfg.Run(ctx, fail)
+	if !errors.As(err, &attemptErr) || attemptErr.Attempts != 1 || !errors.Is(err, failErr) {
+		t.Fatalf("expected an AttemptError after 1 attempt, got %#v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("expected Run to give up without waiting")
+	}
+
+	// The context is honored while waiting.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err = Config{RetryDelay: func() time.Duration { return time.Minute }}.Run(ctx, fail)
+	if err != failErr {
+		t.Fatalf("expected the last error, got %v", err)
+	}
+}
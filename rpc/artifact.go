@@ -4,19 +4,34 @@
 package rpc
 
 import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
-// An implementation of packersdk.Artifact where the artifact is actually
+// An implementation of packersdk.ArtifactV2 where the artifact is actually
 // available over an RPC connection.
 type artifact struct {
 	commonClient
 }
 
+var _ packersdk.ArtifactV2 = new(artifact)
+var _ packersdk.ArtifactFileOpener = new(artifact)
+
 // ArtifactServer wraps a packersdk.Artifact implementation and makes it
 // exportable as part of a Golang RPC server.
 type ArtifactServer struct {
 	artifact packersdk.Artifact
+	mux      *muxBroker
+}
+
+type ArtifactOpenFileArgs struct {
+	Name     string
+	StreamId uint32
 }
 
 func (a *artifact) BuilderId() (result string) {
@@ -44,6 +59,99 @@ func (a *artifact) State(name string) (result interface{}) {
 	return
 }
 
+// Metadata returns the metadata of the artifact. The metadata of the
+// artifacts of plugins built with an SDK without artifact metadata is
+// derived from the artifact, like for the artifacts not implementing
+// packersdk.ArtifactV2.
+func (a *artifact) Metadata() (result packersdk.ArtifactMetadata) {
+	err := a.client.Call(a.endpoint+".Metadata", new(interface{}), &result)
+	if err != nil && isMissingMethod(err) {
+		return packersdk.AsArtifactV2(legacyArtifact{a}).Metadata()
+	}
+	return
+}
+
+// OpenFile streams the file name of the artifact from the side of the
+// plugin that created it, so that the post-processors don't need to share
+// its filesystem. The file is read from the local filesystem if the plugin
+// was built with an SDK without artifact file streaming.
+func (a *artifact) OpenFile(name string) (io.ReadCloser, error) {
+	streamId := a.mux.NextId()
+
+	type accepted struct {
+		conn net.Conn
+		err  error
+	}
+	acceptC := make(chan accepted, 1)
+	go func() {
+		conn, err := a.mux.Accept(streamId)
+		acceptC <- accepted{conn, err}
+	}()
+	callC := make(chan error, 1)
+	go func() {
+		args := &ArtifactOpenFileArgs{
+			Name:     name,
+			StreamId: streamId,
+		}
+		callC <- a.client.Call(a.endpoint+".OpenFile", args, new(interface{}))
+	}()
+
+	select {
+	case acc := <-acceptC:
+		if acc.err != nil {
+			return nil, acc.err
+		}
+		return &artifactFile{Conn: acc.conn, callC: callC}, nil
+	case err := <-callC:
+		if err != nil {
+			if isMissingMethod(err) {
+				log.Printf("[DEBUG] artifact file streaming not supported by the plugin, opening %s", name)
+				return os.Open(name)
+			}
+			return nil, err
+		}
+		// The whole file was sent before it was accepted.
+		acc := <-acceptC
+		if acc.err != nil {
+			return nil, acc.err
+		}
+		callC <- nil
+		return &artifactFile{Conn: acc.conn, callC: callC}, nil
+	}
+}
+
+// artifactFile is a file of an artifact streamed by ArtifactServer.OpenFile.
+// The end of the stream is only reported once the call succeeded, so that
+// a truncated file is an error.
+type artifactFile struct {
+	net.Conn
+	callC chan error
+	err   error
+	done  bool
+}
+
+func (f *artifactFile) Read(p []byte) (int, error) {
+	n, err := f.Conn.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+	if !f.done {
+		f.done = true
+		if callErr := <-f.callC; callErr != nil {
+			f.err = fmt.Errorf("error streaming the artifact file: %s", callErr)
+		}
+	}
+	if f.err != nil {
+		return n, f.err
+	}
+	return n, io.EOF
+}
+
+// legacyArtifact hides the Metadata method of an artifact.
+type legacyArtifact struct {
+	packersdk.Artifact
+}
+
 func (a *artifact) Destroy() error {
 	var result error
 	if err := a.client.Call(a.endpoint+".Destroy", new(interface{}), &result); err != nil {
@@ -78,6 +186,29 @@ func (s *ArtifactServer) State(name string, reply *interface{}) error {
 	return nil
 }
 
+func (s *ArtifactServer) Metadata(args *interface{}, reply *packersdk.ArtifactMetadata) error {
+	*reply = packersdk.AsArtifactV2(s.artifact).Metadata()
+	return nil
+}
+
+func (s *ArtifactServer) OpenFile(args *ArtifactOpenFileArgs, reply *interface{}) error {
+	f, err := packersdk.OpenArtifactFile(s.artifact, args.Name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	conn, err := s.mux.Dial(args.StreamId)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	written, err := io.Copy(conn, f)
+	log.Printf("[INFO] %d bytes written for artifact file %s", written, args.Name)
+	return err
+}
+
 func (s *ArtifactServer) Destroy(args *interface{}, reply *error) error {
 	err := s.artifact.Destroy()
 	if err != nil {
@@ -4,8 +4,14 @@
 package rpc
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -40,6 +46,67 @@ func TestArtifactRPC(t *testing.T) {
 	}
 }
 
+func TestArtifactRPC_Metadata(t *testing.T) {
+	a := &packersdk.MockArtifact{
+		MetadataValue: packersdk.ArtifactMetadata{
+			CreatedAt:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Size:          1024,
+			Checksums:     map[string]string{"sha256": "abcd"},
+			Plugin:        "packer-plugin-happycloud",
+			PluginVersion: "1.2.3",
+			Labels:        map[string]string{"os": "ubuntu"},
+		},
+	}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	aClient := packersdk.AsArtifactV2(client.Artifact())
+	md := aClient.Metadata()
+	if !md.CreatedAt.Equal(a.MetadataValue.CreatedAt) {
+		t.Fatalf("bad creation time: %s", md.CreatedAt)
+	}
+	md.CreatedAt = a.MetadataValue.CreatedAt
+	if !reflect.DeepEqual(md, a.MetadataValue) {
+		t.Fatalf("bad: %#v", md)
+	}
+}
+
+func TestArtifactRPC_OpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	content := bytes.Repeat([]byte("packer"), 100000)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := &packersdk.MockArtifact{FilesValue: []string{path}}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterArtifact(a)
+
+	aClient := client.Artifact()
+	f, err := packersdk.OpenArtifactFile(aClient, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatalf("bad content: %d bytes", len(b))
+	}
+
+	_, err = packersdk.OpenArtifactFile(aClient, "/etc/passwd")
+	if err == nil || !strings.Contains(err.Error(), "not a file of artifact") {
+		t.Fatalf("expected an error for a file not of the artifact, got %v", err)
+	}
+}
+
 func TestArtifact_Implements(t *testing.T) {
 	var _ packersdk.Artifact = new(artifact)
 }
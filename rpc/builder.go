@@ -20,7 +20,7 @@ type builder struct {
 // as part of a Golang RPC server.
 type BuilderServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel context.CancelCauseFunc
 
 	commonServer
 	builder packersdk.Builder
@@ -67,7 +67,7 @@ func (b *builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		select {
 		case <-ctx.Done():
 			log.Printf("Cancelling builder after context cancellation %v", ctx.Err())
-			if err := b.client.Call(b.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
+			if err := b.cancel(ctx); err != nil {
 				log.Printf("Error cancelling builder: %s", err)
 			}
 		case <-done:
@@ -114,10 +114,10 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 	defer client.Close()
 
 	if b.context == nil {
-		b.context, b.contextCancel = context.WithCancel(context.Background())
+		b.context, b.contextCancel = context.WithCancelCause(context.Background())
 	}
 
-	artifact, err := b.builder.Run(b.context, client.Ui(), client.Hook())
+	artifact, err := packersdk.RunBuilder(b.context, b.builder, client.Ui(), client.Hook())
 	if err != nil {
 		return NewBasicError(err)
 	}
@@ -138,6 +138,11 @@ func (b *BuilderServer) Run(streamId uint32, reply *uint32) error {
 }
 
 func (b *BuilderServer) Cancel(args *interface{}, reply *interface{}) error {
-	b.contextCancel()
+	b.contextCancel(nil)
+	return nil
+}
+
+func (b *BuilderServer) CancelWithCause(args *CancelArgs, reply *interface{}) error {
+	b.contextCancel(args.cause())
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -156,6 +157,41 @@ func TestBuilderCancel(t *testing.T) {
 	}
 }
 
+func TestBuilderCancel_cause(t *testing.T) {
+	topCtx, topCtxCancel := context.WithCancelCause(context.Background())
+
+	b := new(packersdk.MockBuilder)
+	var cause *packersdk.CancelError
+	b.RunFn = func(ctx context.Context) {
+		topCtxCancel(&packersdk.CancelError{
+			Reason:      packersdk.CancelReasonTimeout,
+			Message:     "build timeout of 1h exceeded",
+			GracePeriod: time.Minute,
+		})
+		<-ctx.Done()
+		cause = packersdk.CancelCause(ctx)
+	}
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if _, err := bClient.Run(topCtx, new(testUi), new(packersdk.MockHook)); err != nil {
+		t.Fatalf("mock shouldnt retun run error for cancellation")
+	}
+
+	if cause == nil {
+		t.Fatal("context should have been cancelled with a cause")
+	}
+	if cause.Reason != packersdk.CancelReasonTimeout || cause.Message != "build timeout of 1h exceeded" || cause.GracePeriod != time.Minute {
+		t.Fatalf("bad cause: %#v", cause)
+	}
+	if remaining := time.Until(cause.Deadline); remaining <= 0 || remaining > time.Minute {
+		t.Fatalf("bad deadline: %s", cause.Deadline)
+	}
+}
+
 func TestBuilder_ImplementsBuilder(t *testing.T) {
 	var _ packersdk.Builder = new(builder)
 }
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// CancelArgs are the arguments of the CancelWithCause calls: the cause of
+// the cancellation of a component, a *packersdk.CancelError on the side of
+// the plugin.
+type CancelArgs struct {
+	Reason      packersdk.CancelReason
+	Message     string
+	GracePeriod time.Duration
+}
+
+// cancel cancels the component of the endpoint of c with the cause of the
+// cancellation of ctx. Plugins built with an SDK without cancellation
+// causes are cancelled without.
+func (c *commonClient) cancel(ctx context.Context) error {
+	args := &CancelArgs{}
+	if cerr := packersdk.CancelCause(ctx); cerr != nil {
+		args.Reason = cerr.Reason
+		args.Message = cerr.Message
+		args.GracePeriod = cerr.GracePeriod
+	}
+	err := c.client.Call(c.endpoint+".CancelWithCause", args, new(interface{}))
+	if err != nil && isMissingMethod(err) {
+		err = c.client.Call(c.endpoint+".Cancel", new(interface{}), new(interface{}))
+	}
+	return err
+}
+
+// cause returns the cause of the cancellation of args, its deadline starting
+// now.
+func (args *CancelArgs) cause() error {
+	cerr := &packersdk.CancelError{
+		Reason:      args.Reason,
+		Message:     args.Message,
+		GracePeriod: args.GracePeriod,
+	}
+	if args.GracePeriod > 0 {
+		cerr.Deadline = time.Now().Add(args.GracePeriod)
+	}
+	return cerr
+}
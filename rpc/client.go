@@ -79,6 +79,7 @@ func (c *Client) Artifact() packer.Artifact {
 		commonClient: commonClient{
 			endpoint: DefaultArtifactEndpoint,
 			client:   c.client,
+			mux:      c.mux,
 			// Setting useProto to false is essentially a noop for
 			// this type of client since they don't exchange cty
 			// values, and there's no HCLSpec object tied to this.
@@ -185,6 +186,15 @@ func (c *Client) Datasource() packer.Datasource {
 	}
 }
 
+func (c *Client) SecretsSource() packer.SecretsSource {
+	return &secretsSource{
+		commonClient: commonClient{
+			endpoint: DefaultSecretsSourceEndpoint,
+			client:   c.client,
+		},
+	}
+}
+
 func (c *Client) Ui() packer.Ui {
 	return &Ui{
 		commonClient: commonClient{
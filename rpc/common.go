@@ -155,6 +155,7 @@ func init() {
 	gob.Register(new(hcldec.BlockSpec))
 	gob.Register(new(hcldec.BlockAttrsSpec))
 	gob.Register(new(hcldec.BlockListSpec))
+	gob.Register(new(hcldec.BlockMapSpec))
 	gob.Register(new(hcldec.BlockObjectSpec))
 	gob.Register(new(cty.Value))
 }
@@ -34,6 +34,8 @@ type CommandFinished struct {
 
 type CommunicatorStartArgs struct {
 	Command          string
+	Env              []string
+	Pty              *bool
 	StdinStreamId    uint32
 	StdoutStreamId   uint32
 	StderrStreamId   uint32
@@ -75,6 +77,8 @@ func Communicator(client *rpc.Client) *communicator {
 func (c *communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error) {
 	var args CommunicatorStartArgs
 	args.Command = cmd.Command
+	args.Env = cmd.Env
+	args.Pty = cmd.Pty
 
 	var wg sync.WaitGroup
 
@@ -215,6 +219,8 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	// to the remote side.
 	var cmd packersdk.RemoteCmd
 	cmd.Command = args.Command
+	cmd.Env = args.Env
+	cmd.Pty = args.Pty
 
 	// Create a channel to signal we're done so that we can close
 	// our stdin/stdout/stderr streams
@@ -34,6 +34,9 @@ func TestCommunicatorRPC(t *testing.T) {
 	cmd.Stdin = stdin_r
 	cmd.Stdout = stdout_w
 	cmd.Stderr = stderr_w
+	cmd.Env = []string{"SECRET=bar"}
+	pty := true
+	cmd.Pty = &pty
 
 	// Send some data on stdout and stderr from the mock
 	c.StartStdout = "outfoo\n"
@@ -48,6 +51,13 @@ func TestCommunicatorRPC(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 
+	if !reflect.DeepEqual(c.StartCmd.Env, cmd.Env) {
+		t.Fatalf("bad env: %#v", c.StartCmd.Env)
+	}
+	if c.StartCmd.Pty == nil || !*c.StartCmd.Pty {
+		t.Fatalf("bad pty: %#v", c.StartCmd.Pty)
+	}
+
 	// Test that we can read from stdout
 	bufOut := bufio.NewReader(stdout_r)
 	data, err := bufOut.ReadString('\n')
@@ -116,11 +116,14 @@ func attrSpecToProto(attrSpec *hcldec.AttrSpec) (*Attr, error) {
 }
 
 func ctyTypeToProto(cType cty.Type) (*CtyType, error) {
-	if cType.IsPrimitiveType() {
+	// The attributes of any type, like the interface fields, are sent as
+	// a primitive.
+	if cType.IsPrimitiveType() || cType == cty.DynamicPseudoType {
 		switch cType {
 		case cty.Bool,
 			cty.String,
-			cty.Number:
+			cty.Number,
+			cty.DynamicPseudoType:
 			return &CtyType{
 				TypeDef: &CtyType_Primitive{
 					Primitive: &CtyPrimitive{
@@ -247,6 +250,8 @@ func protoTypeToCtyType(protoType *CtyType) (cty.Type, error) {
 			return cty.Bool, nil
 		case "cty.Number":
 			return cty.Number, nil
+		case "cty.DynamicPseudoType":
+			return cty.DynamicPseudoType, nil
 		}
 	case *CtyType_List:
 		elType, err := protoTypeToCtyType(concrete.List.ElementType)
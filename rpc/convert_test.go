@@ -0,0 +1,31 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHCL2SpecProtobuf_dynamic(t *testing.T) {
+	spec := hcldec.ObjectSpec{
+		"name":  &hcldec.AttrSpec{Name: "name", Type: cty.String},
+		"value": &hcldec.AttrSpec{Name: "value", Type: cty.DynamicPseudoType},
+		"tags":  &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.DynamicPseudoType)},
+	}
+	b, err := hcl2SpecToProtobuf(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := protobufToHCL2Spec(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, spec) {
+		t.Fatalf("bad spec after a round trip: %#v", got)
+	}
+}
@@ -3,10 +3,15 @@
 
 package rpc
 
+import (
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
 // This is a type that wraps error types so that they can be messaged
 // across RPC channels. Since "error" is an interface, we can't always
 // gob-encode the underlying structure. This is a valid error interface
-// implementer that we will push across.
+// implementer that we will push across. The sensitive values known to
+// packersdk.LogSecretFilter are redacted from its message.
 type BasicError struct {
 	Message string
 }
@@ -16,7 +21,7 @@ func NewBasicError(err error) *BasicError {
 		return nil
 	}
 
-	return &BasicError{err.Error()}
+	return &BasicError{packersdk.LogSecretFilter.FilterString(err.Error())}
 }
 
 func (e *BasicError) Error() string {
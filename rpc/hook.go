@@ -21,7 +21,7 @@ type hook struct {
 // as part of a Golang RPC server.
 type HookServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel context.CancelCauseFunc
 
 	hook packersdk.Hook
 	lock sync.Mutex
@@ -47,7 +47,7 @@ func (h *hook) Run(ctx context.Context, name string, ui packersdk.Ui, comm packe
 		select {
 		case <-ctx.Done():
 			log.Printf("Cancelling hook after context cancellation %v", ctx.Err())
-			if err := h.client.Call(h.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
+			if err := h.cancel(ctx); err != nil {
 				log.Printf("Error cancelling builder: %s", err)
 			}
 		case <-done:
@@ -72,7 +72,7 @@ func (h *HookServer) Run(args *HookRunArgs, reply *interface{}) error {
 
 	h.lock.Lock()
 	if h.context == nil {
-		h.context, h.contextCancel = context.WithCancel(context.Background())
+		h.context, h.contextCancel = context.WithCancelCause(context.Background())
 	}
 	h.lock.Unlock()
 	if err := h.hook.Run(h.context, args.Name, client.Ui(), client.Communicator(), args.Data); err != nil {
@@ -86,7 +86,16 @@ func (h *HookServer) Run(args *HookRunArgs, reply *interface{}) error {
 func (h *HookServer) Cancel(args *interface{}, reply *interface{}) error {
 	h.lock.Lock()
 	if h.contextCancel != nil {
-		h.contextCancel()
+		h.contextCancel(nil)
+	}
+	h.lock.Unlock()
+	return nil
+}
+
+func (h *HookServer) CancelWithCause(args *CancelArgs, reply *interface{}) error {
+	h.lock.Lock()
+	if h.contextCancel != nil {
+		h.contextCancel(args.cause())
 	}
 	h.lock.Unlock()
 	return nil
@@ -20,7 +20,7 @@ type postProcessor struct {
 // exportable as part of a Golang RPC server.
 type PostProcessorServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel context.CancelCauseFunc
 
 	commonServer
 	p packersdk.PostProcessor
@@ -60,7 +60,7 @@ func (p *postProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, a pack
 		select {
 		case <-ctx.Done():
 			log.Printf("Cancelling post-processor after context cancellation %v", ctx.Err())
-			if err := p.client.Call(p.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
+			if err := p.cancel(ctx); err != nil {
 				log.Printf("Error cancelling post-processor: %s", err)
 			}
 		case <-done:
@@ -104,7 +104,7 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 	}
 
 	if p.context == nil {
-		p.context, p.contextCancel = context.WithCancel(context.Background())
+		p.context, p.contextCancel = context.WithCancelCause(context.Background())
 	}
 
 	artifact := client.Artifact()
@@ -143,7 +143,14 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 
 func (b *PostProcessorServer) Cancel(args *interface{}, reply *interface{}) error {
 	if b.contextCancel != nil {
-		b.contextCancel()
+		b.contextCancel(nil)
+	}
+	return nil
+}
+
+func (b *PostProcessorServer) CancelWithCause(args *CancelArgs, reply *interface{}) error {
+	if b.contextCancel != nil {
+		b.contextCancel(args.cause())
 	}
 	return nil
 }
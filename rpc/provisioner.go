@@ -20,7 +20,7 @@ type provisioner struct {
 // exportable as part of a Golang RPC server.
 type ProvisionerServer struct {
 	context       context.Context
-	contextCancel func()
+	contextCancel context.CancelCauseFunc
 
 	commonServer
 	p packersdk.Provisioner
@@ -58,7 +58,7 @@ func (p *provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packe
 		select {
 		case <-ctx.Done():
 			log.Printf("Cancelling provisioner after context cancellation %v", ctx.Err())
-			if err := p.client.Call(p.endpoint+".Cancel", new(interface{}), new(interface{})); err != nil {
+			if err := p.cancel(ctx); err != nil {
 				log.Printf("Error cancelling provisioner: %s", err)
 			}
 		case <-done:
@@ -86,7 +86,7 @@ func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *int
 	defer client.Close()
 
 	if p.context == nil {
-		p.context, p.contextCancel = context.WithCancel(context.Background())
+		p.context, p.contextCancel = context.WithCancelCause(context.Background())
 	}
 	if err := p.p.Provision(p.context, client.Ui(), client.Communicator(), args.GeneratedData); err != nil {
 		return NewBasicError(err)
@@ -96,6 +96,11 @@ func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *int
 }
 
 func (p *ProvisionerServer) Cancel(args *interface{}, reply *interface{}) error {
-	p.contextCancel()
+	p.contextCancel(nil)
+	return nil
+}
+
+func (p *ProvisionerServer) CancelWithCause(args *CancelArgs, reply *interface{}) error {
+	p.contextCancel(args.cause())
 	return nil
 }
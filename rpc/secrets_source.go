@@ -0,0 +1,62 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// An implementation of packersdk.SecretsSource where the source is actually
+// executed over an RPC connection.
+type secretsSource struct {
+	commonClient
+}
+
+// SecretsSourceServer wraps a packersdk.SecretsSource implementation and
+// makes it exportable as part of a Golang RPC server.
+type SecretsSourceServer struct {
+	source packersdk.SecretsSource
+}
+
+type SecretsSourceSecretResponse struct {
+	Value    string
+	NotFound bool
+	Error    *BasicError
+}
+
+func (s *secretsSource) Secret(ctx context.Context, name string) (string, error) {
+	var resp SecretsSourceSecretResponse
+	call := s.client.Go(s.endpoint+".Secret", name, &resp, nil)
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-call.Done:
+	}
+	if call.Error != nil {
+		return "", call.Error
+	}
+	if resp.NotFound {
+		return "", packersdk.ErrSecretNotFound
+	}
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	return resp.Value, nil
+}
+
+func (s *SecretsSourceServer) Secret(name string, reply *SecretsSourceSecretResponse) error {
+	value, err := s.source.Secret(context.Background(), name)
+	switch {
+	case errors.Is(err, packersdk.ErrSecretNotFound):
+		*reply = SecretsSourceSecretResponse{NotFound: true}
+	case err != nil:
+		*reply = SecretsSourceSecretResponse{Error: NewBasicError(err)}
+	default:
+		*reply = SecretsSourceSecretResponse{Value: value}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestSecretsSourceRPC(t *testing.T) {
+	source := packersdk.MapSecretsSource{
+		packersdk.SecretSSHPassword: "hunter2",
+	}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterSecretsSource(source)
+
+	sClient := client.SecretsSource()
+
+	value, err := sClient.Secret(context.Background(), packersdk.SecretSSHPassword)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if value != "hunter2" {
+		t.Fatalf("bad: %s", value)
+	}
+
+	_, err = sClient.Secret(context.Background(), packersdk.SecretSSHUsername)
+	if !errors.Is(err, packersdk.ErrSecretNotFound) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestSecretsSourceRPC_error(t *testing.T) {
+	source := packersdk.SecretsSourceFunc(func(context.Context, string) (string, error) {
+		return "", errors.New("permission denied")
+	})
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterSecretsSource(source)
+
+	_, err := client.SecretsSource().Secret(context.Background(), packersdk.SecretSSHPassword)
+	if err == nil || err.Error() != "permission denied" {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -23,6 +23,7 @@ const (
 	DefaultPostProcessorEndpoint string = "PostProcessor"
 	DefaultProvisionerEndpoint   string = "Provisioner"
 	DefaultDatasourceEndpoint    string = "Datasource"
+	DefaultSecretsSourceEndpoint string = "SecretsSource"
 	DefaultUiEndpoint            string = "Ui"
 )
 
@@ -77,6 +78,7 @@ func (s *PluginServer) Close() error {
 func (s *PluginServer) RegisterArtifact(a packer.Artifact) error {
 	return s.server.RegisterName(DefaultArtifactEndpoint, &ArtifactServer{
 		artifact: a,
+		mux:      s.mux,
 	})
 }
 
@@ -114,6 +116,12 @@ func (s *PluginServer) RegisterCommunicator(c packer.Communicator) error {
 	})
 }
 
+func (s *PluginServer) RegisterSecretsSource(source packer.SecretsSource) error {
+	return s.server.RegisterName(DefaultSecretsSourceEndpoint, &SecretsSourceServer{
+		source: source,
+	})
+}
+
 func (s *PluginServer) RegisterHook(h packer.Hook) error {
 	return s.server.RegisterName(DefaultHookEndpoint, &HookServer{
 		hook: h,
@@ -6,12 +6,14 @@ package rpc
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 // An implementation of packersdk.Ui where the Ui is actually executed
-// over an RPC connection.
+// over an RPC connection. The sensitive values known to
+// packersdk.LogSecretFilter are redacted before being sent.
 type Ui struct {
 	commonClient
 	endpoint string
@@ -26,6 +28,19 @@ type UiServer struct {
 	register func(name string, rcvr interface{}) error
 }
 
+// The arguments sent to Ui.AskChoice
+type UiAskChoiceArgs struct {
+	Query   string
+	Choices []string
+	Default string
+}
+
+// The arguments sent to Ui.Confirm
+type UiConfirmArgs struct {
+	Query      string
+	DefaultYes bool
+}
+
 // The arguments sent to Ui.Machine
 type UiMachineArgs struct {
 	Category string
@@ -40,19 +55,61 @@ func (u *Ui) Ask(query string) (result string, err error) {
 	return
 }
 
+// AskSecret asks query to Packer, which reads the answer without echoing it.
+// Versions of Packer without secret prompts return an error, rather than
+// echoing the secret.
+func (u *Ui) AskSecret(query string) (result string, err error) {
+	err = u.client.Call("Ui.AskSecret", query, &result)
+	return
+}
+
+// AskChoice asks query to Packer. Versions of Packer without choice prompts
+// are asked through Ask.
+func (u *Ui) AskChoice(query string, choices []string, defaultChoice string) (result string, err error) {
+	args := &UiAskChoiceArgs{
+		Query:   query,
+		Choices: choices,
+		Default: defaultChoice,
+	}
+	err = u.client.Call("Ui.AskChoice", args, &result)
+	if err != nil && isMissingMethod(err) {
+		return packersdk.PromptChoice(u.Ask, query, choices, defaultChoice)
+	}
+	return
+}
+
+// Confirm asks query to Packer. Versions of Packer without confirmations are
+// asked through Ask.
+func (u *Ui) Confirm(query string, defaultYes bool) (result bool, err error) {
+	args := &UiConfirmArgs{
+		Query:      query,
+		DefaultYes: defaultYes,
+	}
+	err = u.client.Call("Ui.Confirm", args, &result)
+	if err != nil && isMissingMethod(err) {
+		return packersdk.PromptConfirm(u.Ask, query, defaultYes)
+	}
+	return
+}
+
 func (u *Ui) Errorf(message string, args ...any) {
 	u.Error(fmt.Sprintf(message, args...))
 }
 func (u *Ui) Error(message string) {
+	message = packersdk.LogSecretFilter.FilterString(message)
 	if err := u.client.Call("Ui.Error", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Error RPC call: %s", err)
 	}
 }
 
 func (u *Ui) Machine(t string, args ...string) {
+	filtered := make([]string, len(args))
+	for i, arg := range args {
+		filtered[i] = packersdk.LogSecretFilter.FilterString(arg)
+	}
 	rpcArgs := &UiMachineArgs{
 		Category: t,
-		Args:     args,
+		Args:     filtered,
 	}
 
 	if err := u.client.Call("Ui.Machine", rpcArgs, new(interface{})); err != nil {
@@ -60,7 +117,27 @@ func (u *Ui) Machine(t string, args ...string) {
 	}
 }
 
+// Event sends e to the Ui of Packer. Versions of Packer without events get
+// it through Machine, with its type as category.
+func (u *Ui) Event(e packersdk.Event) {
+	payload := make(map[string]string, len(e.Payload))
+	for k, v := range e.Payload {
+		payload[k] = packersdk.LogSecretFilter.FilterString(v)
+	}
+	e.Payload = payload
+
+	err := u.client.Call("Ui.Event", &e, new(interface{}))
+	if err != nil && isMissingMethod(err) {
+		u.Machine(e.Type, e.MachineArgs()...)
+		return
+	}
+	if err != nil {
+		log.Printf("Error in Ui.Event RPC call: %s", err)
+	}
+}
+
 func (u *Ui) Message(message string) {
+	message = packersdk.LogSecretFilter.FilterString(message)
 	if err := u.client.Call("Ui.Message", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Message RPC call: %s", err)
 	}
@@ -70,6 +147,7 @@ func (u *Ui) Sayf(message string, args ...any) {
 	u.Say(fmt.Sprintf(message, args...))
 }
 func (u *Ui) Say(message string) {
+	message = packersdk.LogSecretFilter.FilterString(message)
 	if err := u.client.Call("Ui.Say", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui.Say RPC call: %s", err)
 	}
@@ -80,6 +158,21 @@ func (u *UiServer) Ask(query string, reply *string) (err error) {
 	return
 }
 
+func (u *UiServer) AskSecret(query string, reply *string) (err error) {
+	*reply, err = u.ui.AskSecret(query)
+	return
+}
+
+func (u *UiServer) AskChoice(args *UiAskChoiceArgs, reply *string) (err error) {
+	*reply, err = u.ui.AskChoice(args.Query, args.Choices, args.Default)
+	return
+}
+
+func (u *UiServer) Confirm(args *UiConfirmArgs, reply *bool) (err error) {
+	*reply, err = u.ui.Confirm(args.Query, args.DefaultYes)
+	return
+}
+
 func (u *UiServer) Error(message *string, reply *interface{}) error {
 	u.ui.Error(*message)
 
@@ -94,6 +187,13 @@ func (u *UiServer) Machine(args *UiMachineArgs, reply *interface{}) error {
 	return nil
 }
 
+func (u *UiServer) Event(e *packersdk.Event, reply *interface{}) error {
+	u.ui.Event(*e)
+
+	*reply = nil
+	return nil
+}
+
 func (u *UiServer) Message(message *string, reply *interface{}) error {
 	u.ui.Message(*message)
 	*reply = nil
@@ -106,3 +206,10 @@ func (u *UiServer) Say(message *string, reply *interface{}) error {
 	*reply = nil
 	return nil
 }
+
+// isMissingMethod returns whether err is the error of a call to a method the
+// server doesn't have, like a method added to the Ui after the version of
+// Packer serving it.
+func isMissingMethod(err error) bool {
+	return strings.Contains(err.Error(), "can't find method")
+}
@@ -9,20 +9,26 @@ import (
 	"io"
 	"reflect"
 	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
 
 type testUi struct {
-	askCalled      bool
-	askQuery       string
-	errorCalled    bool
-	errorMessage   string
-	machineCalled  bool
-	machineType    string
-	machineArgs    []string
-	messageCalled  bool
-	messageMessage string
-	sayCalled      bool
-	sayMessage     string
+	askCalled       bool
+	askQuery        string
+	askSecretCalled bool
+	askChoices      []string
+	errorCalled     bool
+	errorMessage    string
+	machineCalled   bool
+	machineType     string
+	machineArgs     []string
+	eventCalled     bool
+	event           packersdk.Event
+	messageCalled   bool
+	messageMessage  string
+	sayCalled       bool
+	sayMessage      string
 
 	trackProgressCalled    bool
 	progressBarAddCalled   bool
@@ -38,6 +44,23 @@ func (u *testUi) Ask(query string) (string, error) {
 	return "foo", nil
 }
 
+func (u *testUi) AskSecret(query string) (string, error) {
+	u.askSecretCalled = true
+	u.askQuery = query
+	return "secret", nil
+}
+
+func (u *testUi) AskChoice(query string, choices []string, defaultChoice string) (string, error) {
+	u.askQuery = query
+	u.askChoices = choices
+	return choices[len(choices)-1], nil
+}
+
+func (u *testUi) Confirm(query string, defaultYes bool) (bool, error) {
+	u.askQuery = query
+	return !defaultYes, nil
+}
+
 func (u *testUi) Errorf(message string, args ...any) {
 	u.Error(fmt.Sprintf(message, args...))
 }
@@ -52,6 +75,11 @@ func (u *testUi) Machine(t string, args ...string) {
 	u.machineArgs = args
 }
 
+func (u *testUi) Event(e packersdk.Event) {
+	u.eventCalled = true
+	u.event = e
+}
+
 func (u *testUi) Message(message string) {
 	u.messageCalled = true
 	u.messageMessage = message
@@ -115,6 +143,30 @@ func TestUiRPC(t *testing.T) {
 		t.Fatalf("bad: %#v", result)
 	}
 
+	result, err = uiClient.AskSecret("password")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ui.askSecretCalled || ui.askQuery != "password" || result != "secret" {
+		t.Fatalf("bad: %#v %#v", ui.askQuery, result)
+	}
+
+	result, err = uiClient.AskChoice("region", []string{"eu", "us"}, "eu")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(ui.askChoices, []string{"eu", "us"}) || result != "us" {
+		t.Fatalf("bad: %#v %#v", ui.askChoices, result)
+	}
+
+	confirmed, err := uiClient.Confirm("delete?", false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ui.askQuery != "delete?" || !confirmed {
+		t.Fatalf("bad: %#v %#v", ui.askQuery, confirmed)
+	}
+
 	uiClient.Error("message")
 	if ui.errorMessage != "message" {
 		t.Fatalf("bad: %#v", ui.errorMessage)
@@ -162,4 +214,45 @@ func TestUiRPC(t *testing.T) {
 	if !reflect.DeepEqual(ui.machineArgs, expected) {
 		t.Fatalf("bad: %#v", ui.machineArgs)
 	}
+
+	event := packersdk.Event{
+		Type:    "artifact-id",
+		Build:   "ubuntu",
+		Payload: map[string]string{"id": "ami-1234"},
+	}
+	uiClient.Event(event)
+	if !ui.eventCalled {
+		t.Fatal("event should be called")
+	}
+	if !reflect.DeepEqual(ui.event, event) {
+		t.Fatalf("bad: %#v", ui.event)
+	}
+}
+
+func TestUiRPC_sensitive(t *testing.T) {
+	ui := new(testUi)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	uiClient := client.Ui()
+
+	packersdk.LogSecretFilter.Set("ui-rpc-secret")
+
+	uiClient.Say("password is ui-rpc-secret")
+	if ui.sayMessage != "password is <sensitive>" {
+		t.Fatalf("bad: %#v", ui.sayMessage)
+	}
+
+	uiClient.Error("ui-rpc-secret was rejected")
+	if ui.errorMessage != "<sensitive> was rejected" {
+		t.Fatalf("bad: %#v", ui.errorMessage)
+	}
+
+	uiClient.Machine("secret", "ui-rpc-secret")
+	if !reflect.DeepEqual(ui.machineArgs, []string{"<sensitive>"}) {
+		t.Fatalf("bad: %#v", ui.machineArgs)
+	}
 }
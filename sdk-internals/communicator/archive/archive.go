@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package archive archives directories uploaded, and extracts directories
+// downloaded, by communicators copying files as tar streams.
+package archive
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	packerssh "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+)
+
+// WriteDir writes a tar archive of the contents of the src directory, under
+// prefix. Links are archived as links. Entries matching one of the exclude
+// patterns are skipped, excluded directories with their contents, see
+// ssh.MatchUploadPattern.
+func WriteDir(w io.Writer, src, prefix string, exclude []string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && packerssh.MatchUploadPattern(exclude, rel) {
+			log.Printf("[DEBUG] Excluding %s from the upload", rel)
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := path.Join(prefix, rel)
+		if name == "." {
+			return nil
+		}
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		// Extracted entries are owned by the user extracting them.
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		if fi.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ExtractDir extracts the contents of the directory archived in r, as
+// written by WriteDir under the name of the directory, to dst. Entries
+// matching one of the exclude patterns are skipped, and entries outside of
+// dst are an error.
+func ExtractDir(r io.Reader, dst string, exclude []string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Entries are archived under the name of the directory.
+		_, rel, _ := strings.Cut(path.Clean(header.Name), "/")
+		if rel == "" {
+			continue
+		}
+		if packerssh.MatchUploadPattern(exclude, rel) {
+			log.Printf("[DEBUG] Excluding %s from the download", rel)
+			continue
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if !strings.HasPrefix(target, filepath.Clean(dst)+string(filepath.Separator)) {
+			return fmt.Errorf("the archive entry %s is outside of the directory", header.Name)
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, mode|0700)
+		case tar.TypeReg:
+			err = extractFile(tr, target, mode)
+		case tar.TypeSymlink:
+			os.Remove(target)
+			err = os.Symlink(header.Linkname, target)
+		default:
+			log.Printf("[WARN] Not downloading %s, of unsupported type %c", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
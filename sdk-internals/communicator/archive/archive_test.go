@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDir_ExtractDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	os.MkdirAll(filepath.Join(src, "node_modules"), 0755)
+	os.WriteFile(filepath.Join(src, "node_modules", "dep"), []byte("dep"), 0644)
+	os.WriteFile(filepath.Join(src, "script"), []byte("script"), 0755)
+	os.Symlink("script", filepath.Join(src, "link"))
+
+	var buf bytes.Buffer
+	if err := WriteDir(&buf, src, "src", []string{"node_modules"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := t.TempDir()
+	if err := ExtractDir(&buf, dst, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if fi, err := os.Stat(filepath.Join(dst, "script")); err != nil || fi.Mode().Perm() != 0755 {
+		t.Fatalf("unexpected extracted script %v: %v", fi, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "script" {
+		t.Fatalf("unexpected extracted link %q: %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "node_modules")); err == nil {
+		t.Fatal("node_modules should have been excluded")
+	}
+}
+
+func TestExtractDir_outside(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "src/../../../escaped", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := ExtractDir(&buf, dst, nil); err == nil {
+		t.Fatal("entries outside of the directory should be an error")
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package container implements a communicator running commands and copying
+// files into a container, through the Docker Engine API or containerd.
+// Container builders and test harnesses can use it to run provisioners in a
+// container, the same way they run on a machine over SSH.
+package container
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/archive"
+	"github.com/hashicorp/packer-plugin-sdk/tmp"
+)
+
+// Config configures the container communicator.
+type Config struct {
+	// ContainerID is the ID, or name, of the running container.
+	ContainerID string
+	// Engine is the container engine running the container, "docker",
+	// the default, or "containerd".
+	Engine string
+	// Host is the address of the Docker Engine API, as
+	// unix:///var/run/docker.sock or tcp://127.0.0.1:2375. It defaults to
+	// $DOCKER_HOST, or else to the local socket.
+	Host string
+	// Namespace is the containerd namespace of the container, defaults to
+	// "default".
+	Namespace string
+	// CLI is the path of nerdctl, which drives containerd, defaults to
+	// nerdctl in $PATH.
+	CLI string
+	// User is the user commands run as, defaults to the user of the
+	// container.
+	User string
+	// WorkDir is the directory commands run in, defaults to the working
+	// directory of the container.
+	WorkDir string
+	// Pty runs commands in a pseudo terminal.
+	Pty bool
+}
+
+// engine runs commands and copies files into a container.
+type engine interface {
+	// exec starts cmd in the container, and returns a function waiting for
+	// its exit code.
+	exec(ctx context.Context, cmd []string, opts execOptions) (wait func() (int, error), err error)
+	// copyTo extracts a tar archive into the dir directory of the
+	// container.
+	copyTo(ctx context.Context, dir string, archive io.Reader) error
+	// copyFrom returns a tar archive of the file, or directory, at path in
+	// the container, holding it under its base name.
+	copyFrom(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+type execOptions struct {
+	env    []string
+	tty    bool
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Communicator is a packersdk.Communicator for a container.
+type Communicator struct {
+	config *Config
+	engine engine
+}
+
+var _ packersdk.Communicator = new(Communicator)
+
+// New creates a communicator for the container of config.
+func New(config *Config) (*Communicator, error) {
+	if config.ContainerID == "" {
+		return nil, errors.New("the container to communicate with must be set")
+	}
+
+	c := &Communicator{config: config}
+	switch config.Engine {
+	case "", "docker":
+		e, err := newDocker(config)
+		if err != nil {
+			return nil, err
+		}
+		c.engine = e
+	case "containerd":
+		c.engine = newContainerd(config)
+	default:
+		return nil, fmt.Errorf("unknown container engine %q, expected docker or containerd", config.Engine)
+	}
+	return c, nil
+}
+
+func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	opts := execOptions{
+		env:    cmd.Env,
+		tty:    c.config.Pty,
+		stdin:  cmd.Stdin,
+		stdout: cmd.Stdout,
+		stderr: cmd.Stderr,
+	}
+	if cmd.Pty != nil {
+		opts.tty = *cmd.Pty
+	}
+	if opts.stdout == nil {
+		opts.stdout = io.Discard
+	}
+	if opts.stderr == nil {
+		opts.stderr = io.Discard
+	}
+
+	log.Printf("[DEBUG] Executing in container %s: %s", c.config.ContainerID, cmd.Command)
+	wait, err := c.engine.exec(ctx, []string{"/bin/sh", "-c", cmd.Command}, opts)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		exitStatus, err := wait()
+		if err != nil {
+			log.Printf("[ERROR] Error waiting for the command in container %s: %s", c.config.ContainerID, err)
+			exitStatus = packersdk.CmdDisconnect
+		}
+		log.Printf("[DEBUG] Container command exited with '%d': %s", exitStatus, cmd.Command)
+		cmd.SetExited(exitStatus)
+	}()
+	return nil
+}
+
+func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	log.Printf("[DEBUG] Uploading to container %s: %s", c.config.ContainerID, dst)
+
+	header := &tar.Header{
+		Name:     path.Base(dst),
+		Mode:     0644,
+		Typeflag: tar.TypeReg,
+	}
+	if fi != nil {
+		header.Mode = int64((*fi).Mode().Perm())
+		header.ModTime = (*fi).ModTime()
+	}
+
+	// The size of a file must be known before it is archived.
+	var size int64 = -1
+	if fi != nil && (*fi).Mode().IsRegular() {
+		size = (*fi).Size()
+	}
+	if size < 0 {
+		tf, err := tmp.File("packer-container-upload")
+		if err != nil {
+			return fmt.Errorf("Error preparing the upload: %s", err)
+		}
+		defer os.Remove(tf.Name())
+		defer tf.Close()
+
+		if size, err = io.Copy(tf, r); err != nil {
+			return err
+		}
+		if _, err := tf.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = tf
+	}
+	header.Size = size
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(header)
+		if err == nil {
+			_, err = io.CopyN(tw, r, size)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	err := c.engine.copyTo(context.TODO(), path.Dir(dst), pr)
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("Error uploading %s: %s", dst, err)
+	}
+	return nil
+}
+
+// UploadDir uploads the src directory to dst, or only its contents when src
+// ends with a slash. Entries matching one of the exclude patterns are not
+// uploaded, see archive.WriteDir.
+func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	log.Printf("[DEBUG] Uploading directory '%s' to container %s: %s", src, c.config.ContainerID, dst)
+
+	prefix := ""
+	if !strings.HasSuffix(src, "/") {
+		prefix = filepath.Base(src)
+	}
+
+	// The Docker Engine API only extracts archives in existing directories.
+	if err := c.run(context.TODO(), "mkdir", "-p", dst); err != nil {
+		return fmt.Errorf("Error creating %s: %s", dst, err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.WriteDir(pw, src, prefix, exclude))
+	}()
+	err := c.engine.copyTo(context.TODO(), dst, pr)
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("Error uploading %s: %s", src, err)
+	}
+	return nil
+}
+
+func (c *Communicator) Download(src string, w io.Writer) error {
+	log.Printf("[DEBUG] Downloading from container %s: %s", c.config.ContainerID, src)
+	r, err := c.engine.copyFrom(context.TODO(), src)
+	if err != nil {
+		return fmt.Errorf("Error downloading %s: %s", src, err)
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("Error downloading %s: %s", src, err)
+	}
+	if header.Typeflag != tar.TypeReg {
+		return fmt.Errorf("Error downloading %s: not a regular file", src)
+	}
+	if _, err := io.Copy(w, tr); err != nil {
+		return err
+	}
+	// Reading the archive to its end reports the errors of the copy.
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// DownloadDir downloads the contents of the src directory to dst. Entries
+// matching one of the exclude patterns are not downloaded.
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("[DEBUG] Downloading directory '%s' from container %s: %s", src, c.config.ContainerID, dst)
+	r, err := c.engine.copyFrom(context.TODO(), strings.TrimSuffix(src, "/"))
+	if err != nil {
+		return fmt.Errorf("Error downloading %s: %s", src, err)
+	}
+	defer r.Close()
+
+	err = archive.ExtractDir(r, dst, exclude)
+	if err == nil {
+		// Reading the archive to its end reports the errors of the copy.
+		_, err = io.Copy(io.Discard, r)
+	}
+	if err != nil {
+		return fmt.Errorf("Error downloading %s: %s", src, err)
+	}
+	return nil
+}
+
+// run runs a command in the container and waits for it to succeed.
+func (c *Communicator) run(ctx context.Context, cmd ...string) error {
+	var stderr strings.Builder
+	wait, err := c.engine.exec(ctx, cmd, execOptions{stdout: io.Discard, stderr: &stderr})
+	if err != nil {
+		return err
+	}
+	code, err := wait()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("%s exited with %d: %s", cmd[0], code, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// fakeNerdctl is a nerdctl running the commands of exec on the host, and
+// logging its arguments to the file at $0.log.
+const fakeNerdctl = `#!/bin/sh
+echo "$@" >> "$0.log"
+[ "$1" = --namespace ] && shift 2
+[ "$1" = exec ] || exit 125
+shift
+while :; do
+	case "$1" in
+	-i|-t) shift ;;
+	-u|-w|-e) shift 2 ;;
+	*) break ;;
+	esac
+done
+shift
+exec "$@"
+`
+
+// testCommunicators returns communicators of both engines, for a container
+// sharing the file system of the host.
+func testCommunicators(t *testing.T) map[string]*Communicator {
+	cli := filepath.Join(t.TempDir(), "nerdctl")
+	if err := os.WriteFile(cli, []byte(fakeNerdctl), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comms := map[string]*Communicator{}
+	for name, config := range map[string]*Config{
+		"docker":     {ContainerID: "c1", Host: newFakeDocker(t)},
+		"containerd": {ContainerID: "c1", Engine: "containerd", CLI: cli},
+	} {
+		c, err := New(config)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		comms[name] = c
+	}
+	return comms
+}
+
+func TestCommunicator_Start(t *testing.T) {
+	comms := testCommunicators(t)
+	for name, c := range comms {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			cmd := &packersdk.RemoteCmd{
+				Command: `read line; echo "$line $SECRET"; echo err >&2; exit 3`,
+				Stdin:   strings.NewReader("hello\n"),
+				Stdout:  &stdout,
+				Stderr:  &stderr,
+				Env:     []string{"SECRET=s3cr3t"},
+			}
+			if err := c.Start(context.Background(), cmd); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if code := cmd.Wait(); code != 3 {
+				t.Fatalf("unexpected exit code %d", code)
+			}
+			if stdout.String() != "hello s3cr3t\n" || stderr.String() != "err\n" {
+				t.Fatalf("unexpected output %q, %q", stdout.String(), stderr.String())
+			}
+		})
+	}
+
+	// nerdctl gets the values of variables from its environment.
+	log, _ := os.ReadFile(comms["containerd"].config.CLI + ".log")
+	if !strings.Contains(string(log), "-e SECRET c1") || strings.Contains(string(log), "s3cr3t") {
+		t.Fatalf("unexpected nerdctl arguments %q", log)
+	}
+}
+
+func TestCommunicator_files(t *testing.T) {
+	for name, c := range testCommunicators(t) {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			dst := filepath.Join(dir, "file")
+			if err := c.Upload(dst, strings.NewReader("content"), nil); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			var out bytes.Buffer
+			if err := c.Download(dst, &out); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if out.String() != "content" {
+				t.Fatalf("unexpected downloaded content %q", out.String())
+			}
+
+			src := filepath.Join(dir, "src")
+			for name, content := range map[string]string{"a": "a", "sub/b": "b", "skip.tmp": "x"} {
+				os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755)
+				if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+					t.Fatalf("err: %s", err)
+				}
+			}
+			if err := c.UploadDir(filepath.Join(dir, "dst"), src, []string{"*.tmp"}); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if err := c.UploadDir(filepath.Join(dir, "contents"), src+"/", nil); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			for name, content := range map[string]string{"dst/src/a": "a", "dst/src/sub/b": "b", "contents/sub/b": "b"} {
+				if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != content {
+					t.Fatalf("%s: unexpected content %q: %v", name, b, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "dst/src/skip.tmp")); err == nil {
+				t.Fatal("skip.tmp should have been excluded")
+			}
+
+			down := filepath.Join(dir, "down")
+			if err := c.DownloadDir(src, down, []string{"a"}); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if b, err := os.ReadFile(filepath.Join(down, "sub/b")); err != nil || string(b) != "b" {
+				t.Fatalf("unexpected downloaded content %q: %v", b, err)
+			}
+			if _, err := os.Stat(filepath.Join(down, "a")); err == nil {
+				t.Fatal("a should have been excluded")
+			}
+
+			if err := c.Download(filepath.Join(dir, "missing"), &out); err == nil {
+				t.Fatal("downloading a missing file should fail")
+			}
+		})
+	}
+}
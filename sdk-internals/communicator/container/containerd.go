@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// containerd is the engine of containers run by containerd, driven through
+// nerdctl. Files are copied with tar, which must be in the container.
+type containerd struct {
+	config *Config
+}
+
+func newContainerd(config *Config) *containerd {
+	return &containerd{config: config}
+}
+
+func (c *containerd) exec(ctx context.Context, cmd []string, opts execOptions) (func() (int, error), error) {
+	namespace := c.config.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	args := []string{"--namespace", namespace, "exec"}
+	if opts.stdin != nil {
+		args = append(args, "-i")
+	}
+	if opts.tty {
+		args = append(args, "-t")
+	}
+	if c.config.User != "" {
+		args = append(args, "-u", c.config.User)
+	}
+	if c.config.WorkDir != "" {
+		args = append(args, "-w", c.config.WorkDir)
+	}
+	// Variables are passed by name, their values are taken from the
+	// environment of nerdctl rather than shown in its command line.
+	for _, kv := range opts.env {
+		name, _, _ := strings.Cut(kv, "=")
+		args = append(args, "-e", name)
+	}
+	args = append(append(args, c.config.ContainerID), cmd...)
+
+	cli := c.config.CLI
+	if cli == "" {
+		cli = "nerdctl"
+	}
+	localCmd := exec.Command(cli, args...)
+	localCmd.Stdin = opts.stdin
+	localCmd.Stdout = opts.stdout
+	localCmd.Stderr = opts.stderr
+	if len(opts.env) > 0 {
+		localCmd.Env = append(os.Environ(), opts.env...)
+	}
+	if err := localCmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return func() (int, error) {
+		err := localCmd.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, err
+	}, nil
+}
+
+func (c *containerd) copyTo(ctx context.Context, dir string, archive io.Reader) error {
+	var stderr bytes.Buffer
+	wait, err := c.exec(ctx, []string{"tar", "-xf", "-", "-C", dir}, execOptions{
+		stdin:  archive,
+		stdout: io.Discard,
+		stderr: &stderr,
+	})
+	if err != nil {
+		return err
+	}
+	return tarResult(wait, &stderr)
+}
+
+func (c *containerd) copyFrom(ctx context.Context, p string) (io.ReadCloser, error) {
+	var stderr bytes.Buffer
+	pr, pw := io.Pipe()
+	wait, err := c.exec(ctx, []string{"tar", "-cf", "-", "-C", path.Dir(p), path.Base(p)}, execOptions{
+		stdout: pw,
+		stderr: &stderr,
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		pw.CloseWithError(tarResult(wait, &stderr))
+	}()
+	return pr, nil
+}
+
+func tarResult(wait func() (int, error), stderr *bytes.Buffer) error {
+	code, err := wait()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("tar exited with %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
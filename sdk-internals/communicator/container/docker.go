@@ -0,0 +1,259 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// docker is the engine of containers run by Docker, through the Docker
+// Engine API.
+type docker struct {
+	config *Config
+	dial   func(ctx context.Context) (net.Conn, error)
+	client *http.Client
+	// base is the URL requests are sent to.
+	base string
+}
+
+func newDocker(config *Config) (*docker, error) {
+	host := config.Host
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker host %q: %s", host, err)
+	}
+	var network, address string
+	switch u.Scheme {
+	case "unix":
+		network, address = "unix", u.Path
+	case "tcp":
+		network, address = "tcp", u.Host
+	default:
+		return nil, fmt.Errorf("invalid Docker host %q, expected a unix:// or a tcp:// address", host)
+	}
+
+	d := &docker{
+		config: config,
+		base:   "http://docker",
+	}
+	d.dial = func(ctx context.Context) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	}
+	d.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.dial(ctx)
+			},
+		},
+	}
+	return d, nil
+}
+
+func (d *docker) exec(ctx context.Context, cmd []string, opts execOptions) (func() (int, error), error) {
+	create := map[string]interface{}{
+		"AttachStdin":  opts.stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          opts.tty,
+		"Env":          opts.env,
+		"Cmd":          cmd,
+		"User":         d.config.User,
+		"WorkingDir":   d.config.WorkDir,
+	}
+	var created struct{ Id string }
+	if err := d.do(ctx, "POST", "/containers/"+url.PathEscape(d.config.ContainerID)+"/exec", create, &created); err != nil {
+		return nil, err
+	}
+
+	// Starting the exec upgrades the connection to the raw streams of the
+	// command.
+	body, _ := json.Marshal(map[string]interface{}{"Detach": false, "Tty": opts.tty})
+	req, err := http.NewRequest("POST", d.base+"/exec/"+created.Id+"/start", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, apiError(resp)
+	}
+
+	if opts.stdin != nil {
+		go func() {
+			io.Copy(conn, opts.stdin)
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+			}
+		}()
+	}
+
+	return func() (int, error) {
+		defer conn.Close()
+		var err error
+		if opts.tty {
+			_, err = io.Copy(opts.stdout, br)
+		} else {
+			err = demux(br, opts.stdout, opts.stderr)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return d.exitCode(created.Id)
+	}, nil
+}
+
+// exitCode returns the exit code of an exec, once it ended.
+func (d *docker) exitCode(id string) (int, error) {
+	for {
+		var inspect struct {
+			Running  bool
+			ExitCode int
+		}
+		if err := d.do(context.TODO(), "GET", "/exec/"+id+"/json", nil, &inspect); err != nil {
+			return 0, err
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (d *docker) copyTo(ctx context.Context, dir string, archive io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, "PUT", d.archiveURL(dir), archive)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	return nil
+}
+
+func (d *docker) copyFrom(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.archiveURL(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return resp.Body, nil
+}
+
+func (d *docker) archiveURL(path string) string {
+	return d.base + "/containers/" + url.PathEscape(d.config.ContainerID) + "/archive?" +
+		url.Values{"path": {path}}.Encode()
+}
+
+// do sends a request of the Docker Engine API, with in as its JSON body, and
+// decodes the JSON response in out.
+func (d *docker) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, d.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return apiError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// apiError returns the error of a failed response of the Docker Engine API.
+func apiError(resp *http.Response) error {
+	var e struct{ Message string }
+	b, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(b, &e) != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(b))
+	}
+	return fmt.Errorf("Docker Engine API error %d: %s", resp.StatusCode, e.Message)
+}
+
+// demux copies the output of a command without a pseudo terminal, where
+// frames of stdout and stderr are multiplexed, each after a header of its
+// stream and size.
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if _, err := io.CopyN(w, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
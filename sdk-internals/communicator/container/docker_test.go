@@ -0,0 +1,165 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDocker is a Docker Engine API running the commands of execs on the
+// host, and copying files with tar.
+type fakeDocker struct {
+	l     sync.Mutex
+	execs map[string]*fakeExec
+}
+
+type fakeExec struct {
+	AttachStdin bool
+	Tty         bool
+	Env         []string
+	Cmd         []string
+	exitCode    int
+}
+
+// newFakeDocker starts a fake Docker Engine API for the container c1, and
+// returns its address.
+func newFakeDocker(t *testing.T) string {
+	// The path of unix sockets is limited to about a hundred bytes.
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	server := &http.Server{Handler: &fakeDocker{execs: map[string]*fakeExec{}}}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return "unix://" + socket
+}
+
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path, "/")
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/containers/c1/exec":
+		e := new(fakeExec)
+		json.NewDecoder(r.Body).Decode(e)
+		d.l.Lock()
+		id := fmt.Sprintf("e%d", len(d.execs)+1)
+		d.execs[id] = e
+		d.l.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id": %q}`, id)
+
+	case r.Method == "POST" && len(parts) == 4 && parts[1] == "exec" && parts[3] == "start":
+		d.l.Lock()
+		e := d.execs[parts[2]]
+		d.l.Unlock()
+		if e == nil {
+			http.Error(w, `{"message": "no such exec"}`, http.StatusNotFound)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		fmt.Fprint(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+
+		cmd := exec.Command(e.Cmd[0], e.Cmd[1:]...)
+		cmd.Env = append(os.Environ(), e.Env...)
+		if e.AttachStdin {
+			cmd.Stdin = brw
+		}
+		var l sync.Mutex
+		cmd.Stdout = &frameWriter{w: conn, stream: 1, l: &l}
+		cmd.Stderr = &frameWriter{w: conn, stream: 2, l: &l}
+		if e.Tty {
+			cmd.Stdout, cmd.Stderr = conn, conn
+		}
+		cmd.Run()
+		d.l.Lock()
+		e.exitCode = cmd.ProcessState.ExitCode()
+		d.l.Unlock()
+
+	case r.Method == "GET" && len(parts) == 4 && parts[1] == "exec" && parts[3] == "json":
+		d.l.Lock()
+		defer d.l.Unlock()
+		fmt.Fprintf(w, `{"Running": false, "ExitCode": %d}`, d.execs[parts[2]].exitCode)
+
+	case r.URL.Path == "/containers/c1/archive":
+		p := r.URL.Query().Get("path")
+		var cmd *exec.Cmd
+		if r.Method == "PUT" {
+			cmd = exec.Command("tar", "-xf", "-", "-C", p)
+			cmd.Stdin = r.Body
+		} else {
+			cmd = exec.Command("tar", "-cf", "-", "-C", path.Dir(p), path.Base(p))
+		}
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"message": %q}`, stderr.String()), http.StatusNotFound)
+			return
+		}
+		io.Copy(w, &stdout)
+
+	default:
+		http.Error(w, `{"message": "page not found"}`, http.StatusNotFound)
+	}
+}
+
+// frameWriter writes the frames of a stream of the output of a command.
+type frameWriter struct {
+	w      io.Writer
+	stream byte
+	l      *sync.Mutex
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	header := [8]byte{f.stream}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+	if _, err := f.w.Write(header[:]); err != nil {
+		return 0, err
+	}
+	return f.w.Write(p)
+}
+
+func TestDocker_unknownContainer(t *testing.T) {
+	c, err := New(&Config{ContainerID: "c2", Host: newFakeDocker(t)})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := c.Upload("/tmp/file", strings.NewReader("content"), nil); err == nil {
+		t.Fatal("the upload to an unknown container should fail")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, config := range []*Config{
+		{},
+		{ContainerID: "c1", Engine: "podman"},
+		{ContainerID: "c1", Host: "ssh://docker.example.com"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Fatalf("%#v should be invalid", config)
+		}
+	}
+}
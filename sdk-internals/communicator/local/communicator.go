@@ -0,0 +1,226 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package local implements a communicator executing commands and copying
+// files on the host running Packer, optionally within a chroot, with sudo, or
+// through a command wrapper. Chroot builders and local provisioners can use it
+// rather than implementing their own.
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/archive"
+)
+
+// Config configures the local communicator.
+type Config struct {
+	// Chroot is the directory commands run in a chroot of, and paths are
+	// relative to, when set.
+	Chroot string
+	// Sudo runs the commands, and the transfers of files, as root with sudo.
+	// Sudo must not prompt for a password.
+	Sudo bool
+	// CmdWrapper wraps the commands run on the host, after the chroot and
+	// sudo are applied, as `sudo {{.Command}}` would.
+	CmdWrapper common.CommandWrapper
+}
+
+// Communicator is a packersdk.Communicator for the host. Files are copied
+// with cat, tee and tar, run the same way as commands.
+type Communicator struct {
+	config *Config
+}
+
+var _ packersdk.Communicator = new(Communicator)
+
+// New creates a local communicator.
+func New(config *Config) *Communicator {
+	return &Communicator{config: config}
+}
+
+// ShellQuote quotes s for a POSIX shell.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ChrootCommand returns the command running command, a shell command, in a
+// chroot of dir.
+func ChrootCommand(dir, command string) string {
+	return fmt.Sprintf("chroot %s /bin/sh -c %s", ShellQuote(dir), ShellQuote(command))
+}
+
+// SudoCommand returns the command running command, a shell command, as root
+// with sudo. The environment variables of env, of the form "key=value", are
+// preserved by name, which sudo only allows when they are set in its
+// env_keep, or with SETENV.
+func SudoCommand(command string, env []string) string {
+	preserve := ""
+	if len(env) > 0 {
+		names := make([]string, len(env))
+		for i, kv := range env {
+			names[i], _, _ = strings.Cut(kv, "=")
+		}
+		preserve = " --preserve-env=" + strings.Join(names, ",")
+	}
+	return fmt.Sprintf("sudo -n%s /bin/sh -c %s", preserve, ShellQuote(command))
+}
+
+// command returns the command running command on the host.
+func (c *Communicator) command(command string, env []string, chroot bool) (*exec.Cmd, error) {
+	if chroot && c.config.Chroot != "" {
+		command = ChrootCommand(c.config.Chroot, command)
+	}
+	if c.config.Sudo {
+		command = SudoCommand(command, env)
+	}
+	if c.config.CmdWrapper != nil {
+		var err error
+		if command, err = c.config.CmdWrapper(command); err != nil {
+			return nil, err
+		}
+	}
+
+	localCmd := common.ShellCommand(command)
+	if len(env) > 0 {
+		localCmd.Env = append(os.Environ(), env...)
+	}
+	return localCmd, nil
+}
+
+// path returns the path on the host of p.
+func (c *Communicator) path(p string) string {
+	if c.config.Chroot == "" {
+		return p
+	}
+	return filepath.Join(c.config.Chroot, p)
+}
+
+func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	localCmd, err := c.command(cmd.Command, cmd.Env, true)
+	if err != nil {
+		return err
+	}
+	localCmd.Stdin = cmd.Stdin
+	localCmd.Stdout = cmd.Stdout
+	localCmd.Stderr = cmd.Stderr
+
+	log.Printf("Executing: %s %#v", localCmd.Path, localCmd.Args)
+	if err := localCmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		exitStatus := 0
+		if err := localCmd.Wait(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitStatus = exitErr.ExitCode()
+			}
+		}
+		log.Printf("Local execution exited with '%d': '%s'", exitStatus, cmd.Command)
+		cmd.SetExited(exitStatus)
+	}()
+	return nil
+}
+
+// run runs command on the host, outside of the chroot, and waits for it to
+// succeed.
+func (c *Communicator) run(command string, stdin io.Reader, stdout io.Writer) error {
+	localCmd, err := c.command(command, nil, false)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	localCmd.Stdin = stdin
+	localCmd.Stdout = stdout
+	localCmd.Stderr = &stderr
+	if err := localCmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	dst = c.path(dst)
+	log.Printf("Uploading to %s", dst)
+	if err := c.run("tee "+ShellQuote(dst)+" > /dev/null", r, nil); err != nil {
+		return fmt.Errorf("Error uploading %s: %s", dst, err)
+	}
+	if fi != nil {
+		if err := c.run(fmt.Sprintf("chmod %o %s", (*fi).Mode().Perm(), ShellQuote(dst)), nil, nil); err != nil {
+			return fmt.Errorf("Error uploading %s: %s", dst, err)
+		}
+	}
+	return nil
+}
+
+// UploadDir uploads the src directory to dst, or only its contents when src
+// ends with a slash. Entries matching one of the exclude patterns are not
+// uploaded, see archive.WriteDir.
+func (c *Communicator) UploadDir(dst string, src string, exclude []string) error {
+	dst = c.path(dst)
+	log.Printf("Uploading directory '%s' to '%s'", src, dst)
+
+	prefix := ""
+	if !strings.HasSuffix(src, "/") {
+		prefix = filepath.Base(src)
+	}
+	if err := c.run("mkdir -p "+ShellQuote(dst), nil, nil); err != nil {
+		return fmt.Errorf("Error creating %s: %s", dst, err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.WriteDir(pw, src, prefix, exclude))
+	}()
+	err := c.run("tar -xf - -C "+ShellQuote(dst), pr, nil)
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("Error uploading %s: %s", src, err)
+	}
+	return nil
+}
+
+func (c *Communicator) Download(src string, w io.Writer) error {
+	src = c.path(src)
+	log.Printf("Downloading from %s", src)
+	if err := c.run("cat "+ShellQuote(src), nil, w); err != nil {
+		return fmt.Errorf("Error downloading %s: %s", src, err)
+	}
+	return nil
+}
+
+// DownloadDir downloads the contents of the src directory to dst. Entries
+// matching one of the exclude patterns are not downloaded.
+func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
+	src = filepath.Clean(c.path(src))
+	log.Printf("Downloading directory '%s' to '%s'", src, dst)
+
+	pr, pw := io.Pipe()
+	command := fmt.Sprintf("tar -cf - -C %s %s", ShellQuote(filepath.Dir(src)), ShellQuote(filepath.Base(src)))
+	go func() {
+		pw.CloseWithError(c.run(command, nil, pw))
+	}()
+	err := archive.ExtractDir(pr, dst, exclude)
+	if err == nil {
+		// Reading the archive to its end reports the errors of tar.
+		_, err = io.Copy(io.Discard, pr)
+	}
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("Error downloading %s: %s", src, err)
+	}
+	return nil
+}
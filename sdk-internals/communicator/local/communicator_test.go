@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"", "simple", "it's $HOME", `"; rm -rf / #`} {
+		out, err := exec.Command("/bin/sh", "-c", "printf %s "+ShellQuote(s)).Output()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(out) != s {
+			t.Fatalf("%q was quoted as %q", s, ShellQuote(s))
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	if got := ChrootCommand("/mnt/root", "echo 'hi'"); got != `chroot '/mnt/root' /bin/sh -c 'echo '\''hi'\'''` {
+		t.Fatalf("unexpected chroot command %s", got)
+	}
+	if got := SudoCommand("make install", []string{"PREFIX=/usr", "DESTDIR=/"}); got != `sudo -n --preserve-env=PREFIX,DESTDIR /bin/sh -c 'make install'` {
+		t.Fatalf("unexpected sudo command %s", got)
+	}
+}
+
+func TestCommunicator_Start(t *testing.T) {
+	c := New(&Config{})
+	var stdout bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: `read line; echo "$line $SECRET"; exit 3`,
+		Stdin:   strings.NewReader("hello\n"),
+		Stdout:  &stdout,
+		Env:     []string{"SECRET=s3cr3t"},
+	}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if code := cmd.Wait(); code != 3 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if stdout.String() != "hello s3cr3t\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+
+	// The wrapper gets the command with the chroot and sudo applied.
+	c = New(&Config{
+		Chroot: "/mnt/root",
+		Sudo:   true,
+		CmdWrapper: func(command string) (string, error) {
+			return "echo " + ShellQuote(command), nil
+		},
+	})
+	stdout.Reset()
+	cmd = &packersdk.RemoteCmd{Command: "id", Stdout: &stdout}
+	if err := c.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+	if expected := SudoCommand(ChrootCommand("/mnt/root", "id"), nil) + "\n"; stdout.String() != expected {
+		t.Fatalf("unexpected command %q, expected %q", stdout.String(), expected)
+	}
+}
+
+func TestCommunicator_files(t *testing.T) {
+	chroot := t.TempDir()
+	c := New(&Config{Chroot: chroot})
+
+	if err := c.Upload("/file", strings.NewReader("content"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(chroot, "file")); err != nil || string(b) != "content" {
+		t.Fatalf("unexpected uploaded content %q: %v", b, err)
+	}
+	var out bytes.Buffer
+	if err := c.Download("/file", &out); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out.String() != "content" {
+		t.Fatalf("unexpected downloaded content %q", out.String())
+	}
+	if err := c.Download("/missing", &out); err == nil {
+		t.Fatal("downloading a missing file should fail")
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	for name, content := range map[string]string{"a": "a", "sub/b": "b", "skip.tmp": "x"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755)
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := c.UploadDir("/dst", src, []string{"*.tmp"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(chroot, "dst/src/sub/b")); err != nil || string(b) != "b" {
+		t.Fatalf("unexpected uploaded content %q: %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(chroot, "dst/src/skip.tmp")); err == nil {
+		t.Fatal("skip.tmp should have been excluded")
+	}
+
+	down := t.TempDir()
+	if err := c.DownloadDir("/dst/src", down, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(down, "a")); err != nil || string(b) != "a" {
+		t.Fatalf("unexpected downloaded content %q: %v", b, err)
+	}
+	if err := c.DownloadDir("/missing", down, nil); err == nil {
+		t.Fatal("downloading a missing directory should fail")
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -38,6 +40,14 @@ type comm struct {
 	config  *Config
 	conn    net.Conn
 	address string
+
+	// transfer is the detected file transfer method, when
+	// Config.AutoFileTransfer is set.
+	transferLock sync.Mutex
+	transfer     *transferMethod
+	// remoteTar tells whether tar is installed on the remote end, when
+	// Config.UploadDirTar is set.
+	remoteTar *bool
 }
 
 // TunnelDirection is the supported tunnel directions
@@ -49,6 +59,12 @@ const (
 	LocalTunnel
 )
 
+// SOCKSForward is the ForwardType of the tunnels serving a SOCKS5 proxy on
+// their listener, forwarding the connections to the addresses the clients
+// ask for, reachable from the other side, like the dynamic forwards of
+// OpenSSH. Their ForwardAddr is empty.
+const SOCKSForward = "socks"
+
 // TunnelSpec represents a request to map a port on one side of the SSH connection to the other
 type TunnelSpec struct {
 	Direction   TunnelDirection
@@ -71,6 +87,13 @@ type Config struct {
 	// Pty, if true, will request a pty from the remote end.
 	Pty bool
 
+	// PowerShellExec, if true, runs the commands as PowerShell scripts with
+	// powershell.exe on the exec channel, for Windows hosts reached through
+	// OpenSSH, when WinRM is disabled. It doesn't speak PowerShell Remoting
+	// (PSRP) on the powershell subsystem. Files are then transferred with
+	// sftp or scp only.
+	PowerShellExec bool
+
 	// DisableAgentForwarding, if true, will not forward the SSH agent.
 	DisableAgentForwarding bool
 
@@ -81,10 +104,37 @@ type Config struct {
 	// UseSftp, if true, sftp will be used instead of scp for file transfers
 	UseSftp bool
 
+	// AutoFileTransfer, if true, the file transfer method is detected on
+	// the first transfer and UseSftp is ignored: sftp is used when the
+	// server supports it, else scp when it is installed, else shell
+	// commands (cat) for minimal hosts.
+	AutoFileTransfer bool
+
+	// UploadDirTar, if true, UploadDir streams directories in a tar archive
+	// extracted on the remote end, when tar is installed there, instead of
+	// uploading files one by one.
+	UploadDirTar bool
+
+	// UploadDirInclude and UploadDirExclude are the patterns of the files
+	// uploaded, or not, by UploadDir, see MatchUploadPattern. The excluded
+	// files of each call are added to UploadDirExclude.
+	UploadDirInclude []string
+	UploadDirExclude []string
+
+	// UploadDirSymlinks is how UploadDir handles links, defaults to
+	// SymlinksFollow.
+	UploadDirSymlinks SymlinkPolicy
+
 	// KeepAliveInterval sets how often we send a channel request to the
 	// server. A value < 0 disables.
 	KeepAliveInterval time.Duration
 
+	// KeepAliveCountMax is the number of keep alive requests in a row the
+	// server can leave without reply before the connection is considered
+	// dead, and closed so that the running commands fail instead of
+	// hanging. A value <= 0 never closes the connection.
+	KeepAliveCountMax int
+
 	// Timeout is how long to wait for a read or write to succeed.
 	Timeout time.Duration
 
@@ -119,7 +169,35 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 	session.Stdout = cmd.Stdout
 	session.Stderr = cmd.Stderr
 
-	if c.config.Pty {
+	command := cmd.Command
+	if len(cmd.Env) > 0 {
+		refused, err := setEnv(session, cmd.Env)
+		if err != nil {
+			session.Close()
+			return err
+		}
+		if len(refused) > 0 && c.config.PowerShellExec {
+			session.Close()
+			return errPowershellEnv(refused)
+		}
+		if len(refused) > 0 {
+			command, err = c.envFileCommand(command, refused)
+			if err != nil {
+				session.Close()
+				return err
+			}
+		}
+	}
+
+	if c.config.PowerShellExec {
+		command = powershellCommand(command)
+	}
+
+	pty := c.config.Pty
+	if cmd.Pty != nil {
+		pty = *cmd.Pty
+	}
+	if pty {
 		// Request a PTY
 		termModes := ssh.TerminalModes{
 			ssh.ECHO:          0,     // do not echo
@@ -128,29 +206,20 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 		}
 
 		if err = session.RequestPty("xterm", 40, 80, termModes); err != nil {
+			session.Close()
 			return
 		}
 	}
 
 	log.Printf("[DEBUG] starting remote command: %s", cmd.Command)
-	err = session.Start(cmd.Command + "\n")
+	err = session.Start(command + "\n")
 	if err != nil {
+		session.Close()
 		return
 	}
 
-	go func() {
-		if c.config.KeepAliveInterval <= 0 {
-			return
-		}
-		c := time.NewTicker(c.config.KeepAliveInterval)
-		defer c.Stop()
-		for range c.C {
-			_, err := session.SendRequest("keepalive@packer.io", true, nil)
-			if err != nil {
-				return
-			}
-		}
-	}()
+	dead := new(atomic.Bool)
+	go c.keepAlive(session, c.conn, dead)
 
 	// Start a goroutine to wait for the session to end and set the
 	// exit boolean and status.
@@ -171,30 +240,58 @@ func (c *comm) Start(ctx context.Context, cmd *packersdk.RemoteCmd) (err error)
 				log.Printf("[ERROR] Error occurred waiting for ssh session: %s", err.Error())
 			}
 		}
+		if dead.Load() {
+			log.Printf("[ERROR] Remote command failed, the SSH connection was lost: %s", cmd.Command)
+			exitStatus = packersdk.CmdDisconnect
+		}
 		cmd.SetExited(exitStatus)
 	}()
 	return
 }
 
 func (c *comm) Upload(path string, input io.Reader, fi *os.FileInfo) error {
-	if c.config.UseSftp {
+	switch c.transferMethod() {
+	case transferSFTP:
 		return c.sftpUploadSession(path, input, fi)
-	} else {
+	case transferShell:
+		return c.shellUploadSession(path, input, fi)
+	default:
 		return c.scpUploadSession(path, input, fi)
 	}
 }
 
 func (c *comm) UploadDir(dst string, src string, excl []string) error {
 	log.Printf("[DEBUG] Upload dir '%s' to '%s'", src, dst)
-	if c.config.UseSftp {
-		return c.sftpUploadDirSession(dst, src, excl)
-	} else {
-		return c.scpUploadDirSession(dst, src, excl)
+	method := c.transferMethod()
+	tarUpload := c.config.UploadDirTar && c.tarUploadAvailable()
+
+	symlinks := c.config.UploadDirSymlinks
+	if symlinks == SymlinksPreserve && method == transferSCP && !tarUpload {
+		log.Printf("[WARN] scp can't upload links, following them instead")
+		symlinks = SymlinksFollow
+	}
+	entries, err := c.uploadDirEntries(src, excl, symlinks)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case tarUpload:
+		return c.tarUploadDirSession(dst, entries)
+	case method == transferSFTP:
+		return c.sftpUploadDirSession(dst, entries)
+	case method == transferShell:
+		return c.shellUploadDirSession(dst, entries)
+	default:
+		return c.scpUploadDirSession(dst, entries)
 	}
 }
 
 func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 	log.Printf("[DEBUG] Download dir '%s' to '%s'", src, dst)
+	if c.transferMethod() == transferShell {
+		return errShellDownloadDir
+	}
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		dirStack := []string{dst}
 		for {
@@ -266,10 +363,14 @@ func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 }
 
 func (c *comm) Download(path string, output io.Writer) error {
-	if c.config.UseSftp {
+	switch c.transferMethod() {
+	case transferSFTP:
 		return c.sftpDownloadSession(path, output)
+	case transferShell:
+		return c.shellDownloadSession(path, output)
+	default:
+		return c.scpDownloadSession(path, output)
 	}
-	return c.scpDownloadSession(path, output)
 }
 
 func (c *comm) newSession() (session *ssh.Session, err error) {
@@ -400,9 +501,14 @@ func (c *comm) connectTunnels(sshConn ssh.Conn) (err error) {
 				err = fmt.Errorf("Tunnel: Failed to bind remote ('%v'): %s", v, err)
 				return
 			}
-			log.Printf("[INFO] Tunnel: Remote bound on %s forwarding to %s", v.ListenAddr, v.ForwardAddr)
-			connectFunc := ConnectFunc(v.ForwardType, v.ForwardAddr)
-			go ProxyServe(listener, done, connectFunc)
+			if v.ForwardType == SOCKSForward {
+				log.Printf("[INFO] Tunnel: Remote bound on %s serving SOCKS", v.ListenAddr)
+				go SOCKSServe(listener, done, net.Dial)
+			} else {
+				log.Printf("[INFO] Tunnel: Remote bound on %s forwarding to %s", v.ListenAddr, v.ForwardAddr)
+				connectFunc := ConnectFunc(v.ForwardType, v.ForwardAddr)
+				go ProxyServe(listener, done, connectFunc)
+			}
 			// Wait for our sshConn to be shutdown
 			// FIXME: Is there a better "on-shutdown" we can wait on?
 			go shutdownProxyTunnel(sshConn, done, listener)
@@ -413,12 +519,18 @@ func (c *comm) connectTunnels(sshConn ssh.Conn) (err error) {
 				err = fmt.Errorf("Tunnel: Failed to bind local ('%v'): %s", v, err)
 				return
 			}
-			log.Printf("[INFO] Tunnel: Local bound on %s forwarding to %s", v.ListenAddr, v.ForwardAddr)
-			connectFunc := func() (net.Conn, error) {
+			if v.ForwardType == SOCKSForward {
+				log.Printf("[INFO] Tunnel: Local bound on %s serving SOCKS", v.ListenAddr)
 				// This Dial occurs on the SSH server's side
-				return c.client.Dial(v.ForwardType, v.ForwardAddr)
+				go SOCKSServe(listener, done, c.client.Dial)
+			} else {
+				log.Printf("[INFO] Tunnel: Local bound on %s forwarding to %s", v.ListenAddr, v.ForwardAddr)
+				connectFunc := func() (net.Conn, error) {
+					// This Dial occurs on the SSH server's side
+					return c.client.Dial(v.ForwardType, v.ForwardAddr)
+				}
+				go ProxyServe(listener, done, connectFunc)
 			}
-			go ProxyServe(listener, done, connectFunc)
 			// FIXME: Is there a better "on-shutdown" we can wait on?
 			go shutdownProxyTunnel(sshConn, done, listener)
 		default:
@@ -522,48 +634,6 @@ func (c *comm) sftpUploadFile(path string, input io.Reader, client *sftp.Client,
 	return nil
 }
 
-func (c *comm) sftpUploadDirSession(dst string, src string, excl []string) error {
-	sftpFunc := func(client *sftp.Client) error {
-		rootDst := dst
-		if src[len(src)-1] != '/' {
-			srcBase := filepath.Base(src)
-			log.Printf("[DEBUG] sftp: No trailing slash, creating directory %s/%s", dst, srcBase)
-			rootDst = filepath.Join(dst, srcBase)
-		}
-		walkFunc := func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			// Calculate the final destination using the
-			// base source and root destination
-			relSrc, err := filepath.Rel(src, path)
-			if err != nil {
-				return err
-			}
-			finalDst := filepath.Join(rootDst, relSrc)
-
-			// In Windows, Join uses backslashes which we don't want to get
-			// to the sftp server
-			finalDst = filepath.ToSlash(finalDst)
-
-			log.Printf("[DEBUG] sftp: uploading %q to %q", relSrc, finalDst)
-
-			// Skip the creation of the target destination directory since
-			// it should exist and we might not even own it
-			if finalDst == dst {
-				log.Printf("[DEBUG] sftp: skipping creation of %q", dst)
-				return nil
-			}
-
-			return c.sftpVisitFile(finalDst, path, info, client)
-		}
-
-		return filepath.Walk(src, walkFunc)
-	}
-
-	return c.sftpSession(sftpFunc)
-}
-
 func (c *comm) sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error {
 	log.Printf("[DEBUG] sftp: creating dir %s", path)
 
@@ -582,20 +652,6 @@ func (c *comm) sftpMkdir(path string, client *sftp.Client, fi os.FileInfo) error
 	return nil
 }
 
-func (c *comm) sftpVisitFile(dst string, src string, fi os.FileInfo, client *sftp.Client) error {
-	if !fi.IsDir() {
-		f, err := os.Open(src)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		return c.sftpUploadFile(dst, f, client, &fi)
-	} else {
-		err := c.sftpMkdir(dst, client, fi)
-		return err
-	}
-}
-
 func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
 	sftpFunc := func(client *sftp.Client) error {
 		f, err := client.Open(path)
@@ -675,40 +731,6 @@ func (c *comm) scpUploadSession(path string, input io.Reader, fi *os.FileInfo) e
 	return c.scpSession("scp -vt "+target_dir, scpFunc)
 }
 
-func (c *comm) scpUploadDirSession(dst string, src string, excl []string) error {
-	scpFunc := func(w io.Writer, r *bufio.Reader) error {
-		uploadEntries := func() error {
-			f, err := os.Open(src)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			entries, err := f.Readdir(-1)
-			if err != nil {
-				return err
-			}
-
-			return scpUploadDir(src, entries, w, r)
-		}
-
-		if src[len(src)-1] != '/' {
-			srcBase := filepath.Base(src)
-			log.Printf("[DEBUG] scp: No trailing slash, creating directory %s/%s", dst, srcBase)
-			fi, err := os.Stat(src)
-			if err != nil {
-				return err
-			}
-			return scpUploadDirProtocol(srcBase, w, r, uploadEntries, fi)
-		} else {
-			// Trailing slash, so only upload the contents
-			return uploadEntries()
-		}
-	}
-
-	return c.scpSession("scp -rvt "+dst, scpFunc)
-}
-
 func (c *comm) scpDownloadSession(path string, output io.Writer) error {
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
 		fmt.Fprint(w, "\x00")
@@ -943,91 +965,3 @@ func scpUploadFile(dst string, src io.Reader, w io.Writer, r *bufio.Reader, fi *
 	fmt.Fprint(w, "\x00")
 	return checkSCPStatus(r)
 }
-
-func scpUploadDirProtocol(name string, w io.Writer, r *bufio.Reader, f func() error, fi os.FileInfo) error {
-	log.Printf("[DEBUG] SCP: starting directory upload: %s", name)
-
-	mode := fi.Mode().Perm()
-
-	perms := fmt.Sprintf("D%04o 0", mode)
-
-	fmt.Fprintln(w, perms, name)
-	err := checkSCPStatus(r)
-	if err != nil {
-		return err
-	}
-
-	if err := f(); err != nil {
-		return err
-	}
-
-	fmt.Fprintln(w, "E")
-	return err
-}
-
-func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) error {
-	log.Printf("[DEBUG] scp: uploading directory %s", root)
-
-	for _, fi := range fs {
-		realPath := filepath.Join(root, fi.Name())
-
-		// Track if this is actually a symlink to a directory. If it is
-		// a symlink to a file we don't do any special behavior because uploading
-		// a file just works. If it is a directory, we need to know so we
-		// treat it as such.
-		isSymlinkToDir := false
-		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
-			symPath, err := filepath.EvalSymlinks(realPath)
-			if err != nil {
-				return err
-			}
-
-			symFi, err := os.Lstat(symPath)
-			if err != nil {
-				return err
-			}
-
-			isSymlinkToDir = symFi.IsDir()
-		}
-
-		if !fi.IsDir() && !isSymlinkToDir {
-			// It is a regular file (or symlink to a file), just upload it
-			f, err := os.Open(realPath)
-			if err != nil {
-				return err
-			}
-
-			err = func() error {
-				defer f.Close()
-				return scpUploadFile(fi.Name(), f, w, r, &fi)
-			}()
-
-			if err != nil {
-				return err
-			}
-
-			continue
-		}
-
-		// It is a directory, recursively upload
-		err := scpUploadDirProtocol(fi.Name(), w, r, func() error {
-			f, err := os.Open(realPath)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			entries, err := f.Readdir(-1)
-			if err != nil {
-				return err
-			}
-
-			return scpUploadDir(realPath, entries, w, r)
-		}, fi)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
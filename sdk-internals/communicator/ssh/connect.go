@@ -84,6 +84,102 @@ func BastionConnectFunc(
 	}
 }
 
+// BastionHop is one of the bastion hosts a connection goes through.
+type BastionHop struct {
+	// Address is the host:port of the bastion, dialed from the previous hop.
+	Address string
+	Config  *ssh.ClientConfig
+	// KeepAliveInterval is how often to send keep alive requests to the
+	// bastion, 0 disabling them.
+	KeepAliveInterval time.Duration
+}
+
+// BastionChainConnectFunc is a convenience method for returning a function
+// that connects to a host through a chain of bastion connections: the first
+// hop is dialed with bProto, each of the following ones through the previous
+// one.
+func BastionChainConnectFunc(
+	bProto string,
+	hops []BastionHop,
+	proto string,
+	addr string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		var clients []*ssh.Client
+		closeAll := func() {
+			for i := len(clients) - 1; i >= 0; i-- {
+				clients[i].Close()
+			}
+		}
+
+		for i, hop := range hops {
+			var client *ssh.Client
+			if i == 0 {
+				c, err := ssh.Dial(bProto, hop.Address, hop.Config)
+				if err != nil {
+					return nil, fmt.Errorf("Error connecting to bastion %s: %s", hop.Address, err)
+				}
+				client = c
+			} else {
+				conn, err := clients[i-1].Dial("tcp", hop.Address)
+				if err != nil {
+					closeAll()
+					return nil, fmt.Errorf("Error connecting to bastion %s: %s", hop.Address, err)
+				}
+				c, chans, reqs, err := ssh.NewClientConn(conn, hop.Address, hop.Config)
+				if err != nil {
+					conn.Close()
+					closeAll()
+					return nil, fmt.Errorf("Error connecting to bastion %s: %s", hop.Address, err)
+				}
+				client = ssh.NewClient(c, chans, reqs)
+			}
+			log.Printf("[DEBUG] connected to bastion host %s", hop.Address)
+			clients = append(clients, client)
+			go bastionKeepAlive(client, hop.KeepAliveInterval)
+		}
+
+		log.Println("[DEBUG] attempting connection to destination host")
+		conn, err := clients[len(clients)-1].Dial(proto, addr)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		return &bastionChainConn{
+			Conn:     conn,
+			Bastions: clients,
+		}, nil
+	}
+}
+
+// bastionKeepAlive sends keep alive requests to the bastion until its
+// connection is closed.
+func bastionKeepAlive(client *ssh.Client, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		if _, _, err := client.SendRequest("keepalive@packer.io", true, nil); err != nil {
+			return
+		}
+	}
+}
+
+type bastionChainConn struct {
+	net.Conn
+	Bastions []*ssh.Client
+}
+
+func (c *bastionChainConn) Close() error {
+	err := c.Conn.Close()
+	for i := len(c.Bastions) - 1; i >= 0; i-- {
+		err = c.Bastions[i].Close()
+	}
+	return err
+}
+
 type bastionConn struct {
 	net.Conn
 	Bastion *ssh.Client
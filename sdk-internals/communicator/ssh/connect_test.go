@@ -5,14 +5,19 @@ package ssh_test
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/user"
 	"testing"
 	"time"
 
 	helperssh "github.com/hashicorp/packer-plugin-sdk/communicator/ssh"
+	packerssh "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -74,3 +79,133 @@ func TestConnectFunc(t *testing.T) {
 		fmt.Println(stdoutBuf.String())
 	}
 }
+
+// newBastionServer starts an SSH server accepting the password "pass" for
+// user and forwarding the direct-tcpip channels it is asked for.
+func newBastionServer(t *testing.T, user string) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == "pass" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", c.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(c, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					var target struct {
+						Host       string
+						Port       uint32
+						OriginHost string
+						OriginPort uint32
+					}
+					if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+						newChannel.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+					if err != nil {
+						newChannel.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						conn.Close()
+						continue
+					}
+					go ssh.DiscardRequests(requests)
+					go func() {
+						defer channel.Close()
+						defer conn.Close()
+						go io.Copy(conn, channel)
+						io.Copy(channel, conn)
+					}()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestBastionChainConnectFunc(t *testing.T) {
+	// The destination echoes what it reads.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(c, c)
+	}()
+
+	hop := func(address, user string) packerssh.BastionHop {
+		return packerssh.BastionHop{
+			Address: address,
+			Config: &ssh.ClientConfig{
+				User:            user,
+				Auth:            []ssh.AuthMethod{ssh.Password("pass")},
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			},
+			KeepAliveInterval: 10 * time.Millisecond,
+		}
+	}
+	hops := []packerssh.BastionHop{
+		hop(newBastionServer(t, "first"), "first"),
+		hop(newBastionServer(t, "second"), "second"),
+	}
+
+	conn, err := packerssh.BastionChainConnectFunc("tcp", hops, "tcp", l.Addr().String())()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("bad: %q", buf)
+	}
+
+	// The second hop rejects the credentials of the first one.
+	hops[1].Config.User = "first"
+	if _, err := packerssh.BastionChainConnectFunc("tcp", hops, "tcp", l.Addr().String())(); err == nil {
+		t.Fatal("should error")
+	}
+}
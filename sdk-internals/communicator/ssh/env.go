@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/random"
+	"golang.org/x/crypto/ssh"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// setEnv sets the env variables of a command, of the form "key=value", in
+// session. Servers only accept the variables they are configured to, like
+// with the AcceptEnv option of OpenSSH: the variables they refuse are
+// returned, to be set with an env file instead.
+func setEnv(session *ssh.Session, env []string) (refused []string, err error) {
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !envNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable %q, expected key=value", name)
+		}
+		if err := session.Setenv(name, value); err != nil {
+			log.Printf("[DEBUG] The SSH server refused to set %s, setting it with an env file", name)
+			refused = append(refused, kv)
+		}
+	}
+	return refused, nil
+}
+
+// envFileCommand uploads the env variables, of the form "key=value", to a
+// file only readable by the remote user, and returns command wrapped to
+// source and remove the file before running. Values never show up in the
+// command line this way.
+func (c *comm) envFileCommand(command string, env []string) (string, error) {
+	var script strings.Builder
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&script, "export %s=%s\n", name, shellQuote(value))
+	}
+
+	path := shellQuote("/tmp/packer-env-" + random.AlphaNum(16))
+	err := c.shellSession("umask 077 && cat > "+path, strings.NewReader(script.String()), io.Discard)
+	if err != nil {
+		return "", fmt.Errorf("Error uploading the environment of the command: %s", err)
+	}
+	return fmt.Sprintf(". %s && rm -f %s && %s", path, path, command), nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keepAlive sends keep alive requests on session every KeepAliveInterval
+// until it ends. When KeepAliveCountMax of them in a row get no reply within
+// the interval, the connection is dead, probably dropped by a firewall: conn
+// is closed so that the command fails fast, and dead is set.
+func (c *comm) keepAlive(session *ssh.Session, conn net.Conn, dead *atomic.Bool) {
+	interval := c.config.KeepAliveInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for range ticker.C {
+		reply := make(chan error, 1)
+		go func() {
+			_, err := session.SendRequest("keepalive@packer.io", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case err := <-reply:
+			if err != nil {
+				return
+			}
+			missed = 0
+		case <-time.After(interval):
+			missed++
+			log.Printf("[WARN] No reply from the SSH server to %d keep alive request(s)", missed)
+			if max := c.config.KeepAliveCountMax; max > 0 && missed >= max {
+				log.Printf("[ERROR] The SSH connection to %s is dead, closing it", c.address)
+				dead.Store(true)
+				if conn != nil {
+					conn.Close()
+				}
+				return
+			}
+		}
+	}
+}
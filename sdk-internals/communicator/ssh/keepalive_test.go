@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	. "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// newStalledServer starts an SSH server that starts commands and then stops
+// answering, like a connection silently dropped by a firewall.
+func newStalledServer(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(c, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					_, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range requests {
+							if req.Type == "exec" {
+								req.Reply(true, nil)
+							}
+							// Other requests, like keep alives, are left
+							// without reply.
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestKeepAliveDeadConnection(t *testing.T) {
+	address := newStalledServer(t)
+	comm, err := New(address, &Config{
+		Connection: func() (net.Conn, error) {
+			return net.Dial("tcp", address)
+		},
+		SSHConfig: &ssh.ClientConfig{
+			User:            "user",
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		DisableAgentForwarding: true,
+		KeepAliveInterval:      10 * time.Millisecond,
+		KeepAliveCountMax:      3,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cmd := &packersdk.RemoteCmd{Command: "sleep 3600"}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	exited := make(chan int, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case status := <-exited:
+		if status != packersdk.CmdDisconnect {
+			t.Fatalf("expected the disconnect exit status, got %d", status)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the command should fail once the connection is found dead")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// powershellCommand returns the command running script with PowerShell.
+// The script is passed encoded, so that cmd.exe, the default shell of
+// OpenSSH on Windows, doesn't interpret it.
+func powershellCommand(script string) string {
+	// Progress records end up as CLIXML in the output when there is no
+	// console.
+	script = "$ProgressPreference = 'SilentlyContinue'\n" + script
+
+	encoded := utf16.Encode([]rune(script))
+	b := make([]byte, 2*len(encoded))
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+	return "powershell.exe -NoLogo -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand " +
+		base64.StdEncoding.EncodeToString(b)
+}
+
+// errPowershellEnv is returned when the server refuses environment
+// variables of a command run with PowerShell: they can't be passed otherwise
+// without showing up in the command line.
+func errPowershellEnv(refused []string) error {
+	names := make([]string, len(refused))
+	for i, kv := range refused {
+		names[i], _, _ = strings.Cut(kv, "=")
+	}
+	return fmt.Errorf("the SSH server refused to set %s, allow it with AcceptEnv in its sshd_config",
+		strings.Join(names, ", "))
+}
@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestPowershellCommand(t *testing.T) {
+	command := powershellCommand(`Write-Output "héllo" | Out-File C:\Windows\Temp\a.txt`)
+
+	prefix := "powershell.exe -NoLogo -NoProfile -NonInteractive -ExecutionPolicy Bypass -EncodedCommand "
+	if !strings.HasPrefix(command, prefix) {
+		t.Fatalf("unexpected command %q", command)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(command, prefix))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	encoded := make([]uint16, len(b)/2)
+	for i := range encoded {
+		encoded[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	script := string(utf16.Decode(encoded))
+	if !strings.HasSuffix(script, "\n"+`Write-Output "héllo" | Out-File C:\Windows\Temp\a.txt`) {
+		t.Fatalf("unexpected script %q", script)
+	}
+}
+
+func TestErrPowershellEnv(t *testing.T) {
+	err := errPowershellEnv([]string{"TOKEN=secret", "PASSWORD=secret"})
+	if strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), "TOKEN, PASSWORD") {
+		t.Fatalf("unexpected error %q", err)
+	}
+}
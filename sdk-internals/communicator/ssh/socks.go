@@ -0,0 +1,168 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 constants, from RFC 1928.
+const (
+	socksVersion = 5
+
+	socksMethodNoAuth       = 0
+	socksMethodNoAcceptable = 0xff
+
+	socksCmdConnect = 1
+
+	socksAddrIPv4   = 1
+	socksAddrDomain = 3
+	socksAddrIPv6   = 4
+
+	socksReplySucceeded           = 0
+	socksReplyHostUnreachable     = 4
+	socksReplyCmdNotSupported     = 7
+	socksReplyAddrTypeUnsupported = 8
+)
+
+// socksHandshakeTimeout limits the time a client has to send its request.
+const socksHandshakeTimeout = 30 * time.Second
+
+// SOCKSServe starts Accepting connections, like ProxyServe, but serves
+// SOCKS5 on them: each client asks for the address to connect to, dialed
+// with dial. Only the CONNECT command is supported, without authentication.
+func SOCKSServe(l net.Listener, done <-chan struct{}, dial func(network, addr string) (net.Conn, error)) {
+	serve(l, done, func(client net.Conn) {
+		handleSOCKSClient(client, dial)
+	})
+}
+
+// handleSOCKSClient reads the request of the SOCKS5 client clientConn,
+// dials the address it asks for, and proxies the connection.
+func handleSOCKSClient(clientConn net.Conn, dial func(network, addr string) (net.Conn, error)) {
+	clientConn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	addr, err := readSOCKSRequest(clientConn)
+	if err != nil {
+		log.Printf("[ERROR] Tunnel: bad SOCKS request: %v", err)
+		clientConn.Close()
+		return
+	}
+
+	upstreamConn, err := dial("tcp", addr)
+	if err != nil {
+		log.Printf("[ERROR] Tunnel: failed to open connection to %s: %v", addr, err)
+		writeSOCKSReply(clientConn, socksReplyHostUnreachable, nil)
+		clientConn.Close()
+		return
+	}
+	if err := writeSOCKSReply(clientConn, socksReplySucceeded, upstreamConn.LocalAddr()); err != nil {
+		log.Printf("[ERROR] Tunnel: failed to answer the SOCKS request: %v", err)
+		upstreamConn.Close()
+		clientConn.Close()
+		return
+	}
+	clientConn.SetDeadline(time.Time{})
+	log.Printf("[DEBUG] Tunnel: client '%s' connected to %s", clientConn.RemoteAddr(), addr)
+	proxyConns(clientConn, upstreamConn)
+}
+
+// readSOCKSRequest negotiates the authentication method with the client and
+// returns the address of its CONNECT request, answering the requests it
+// can't serve.
+func readSOCKSRequest(conn net.Conn) (string, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	method := byte(socksMethodNoAcceptable)
+	for _, m := range methods {
+		if m == socksMethodNoAuth {
+			method = socksMethodNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksMethodNoAcceptable {
+		return "", errors.New("the client requires authentication")
+	}
+
+	var request [4]byte
+	if _, err := io.ReadFull(conn, request[:]); err != nil {
+		return "", err
+	}
+	if request[0] != socksVersion {
+		return "", fmt.Errorf("unsupported SOCKS version %d", request[0])
+	}
+	if request[1] != socksCmdConnect {
+		writeSOCKSReply(conn, socksReplyCmdNotSupported, nil)
+		return "", fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socksAddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksAddrDomain:
+		var length [1]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		writeSOCKSReply(conn, socksReplyAddrTypeUnsupported, nil)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// writeSOCKSReply answers a request with reply, and the address bound to
+// connect to its destination, if any.
+func writeSOCKSReply(conn net.Conn, reply byte, bound net.Addr) error {
+	ip := net.IPv4zero.To4()
+	port := 0
+	if addr, ok := bound.(*net.TCPAddr); ok && addr.IP != nil {
+		ip = addr.IP
+		port = addr.Port
+	}
+	msg := []byte{socksVersion, reply, 0}
+	if ip4 := ip.To4(); ip4 != nil {
+		msg = append(append(msg, socksAddrIPv4), ip4...)
+	} else {
+		msg = append(append(msg, socksAddrIPv6), ip.To16()...)
+	}
+	msg = binary.BigEndian.AppendUint16(msg, uint16(port))
+	_, err := conn.Write(msg)
+	return err
+}
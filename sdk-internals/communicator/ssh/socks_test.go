@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/net/proxy"
+)
+
+func TestSOCKSServe(t *testing.T) {
+	// The destination answers with what it reads.
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			c, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		l.Close()
+	}()
+	go SOCKSServe(l, done, net.Dial)
+
+	dialer, err := proxy.SOCKS5("tcp", l.Addr().String(), nil, proxy.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{upstream.Addr().String(), "localhost:" + portOf(t, upstream.Addr())} {
+		c, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dialing %s through the proxy: %s", addr, err)
+		}
+		if _, err := c.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 4)
+		if _, err := io.ReadFull(c, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "ping" {
+			t.Fatalf("bad answer %q", b)
+		}
+		c.Close()
+	}
+
+	// A closed port is reported to the client.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	if _, err := dialer.Dial("tcp", closed.Addr().String()); err == nil {
+		t.Fatal("dialing a closed port through the proxy should fail")
+	}
+}
+
+func portOf(t *testing.T, addr net.Addr) string {
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// transferMethod is how files are transferred with the remote end.
+type transferMethod int
+
+const (
+	transferSCP transferMethod = iota
+	transferSFTP
+	// transferShell streams files through shell commands, for the minimal
+	// hosts that support neither SFTP nor SCP.
+	transferShell
+)
+
+func (m transferMethod) String() string {
+	switch m {
+	case transferSFTP:
+		return "sftp"
+	case transferShell:
+		return "shell"
+	}
+	return "scp"
+}
+
+// transferMethod returns the method to transfer files with. When
+// AutoFileTransfer is set, it is detected on the first transfer: SFTP when
+// the server supports it, else SCP when it is installed, else shell commands.
+func (c *comm) transferMethod() transferMethod {
+	if !c.config.AutoFileTransfer {
+		if c.config.UseSftp {
+			return transferSFTP
+		}
+		return transferSCP
+	}
+
+	c.transferLock.Lock()
+	defer c.transferLock.Unlock()
+	if c.transfer != nil {
+		return *c.transfer
+	}
+
+	method, err := c.detectTransferMethod()
+	if err != nil {
+		// Try again on the next transfer, the connection may be back.
+		log.Printf("[WARN] Failed to detect the SSH file transfer method, using %s: %s", method, err)
+		return method
+	}
+	log.Printf("[INFO] Using %s to transfer files over SSH", method)
+	c.transfer = &method
+	return method
+}
+
+func (c *comm) detectTransferMethod() (transferMethod, error) {
+	client, err := c.newSftpClient()
+	if err == nil {
+		client.Close()
+		return transferSFTP, nil
+	}
+	log.Printf("[DEBUG] SFTP is not available: %s", err)
+	if c.config.PowerShellExec {
+		// OpenSSH on Windows comes with scp, and the shell transfers need
+		// a POSIX shell.
+		return transferSCP, nil
+	}
+
+	session, err := c.newSession()
+	if err != nil {
+		return transferSCP, err
+	}
+	defer session.Close()
+	if err := session.Run("command -v scp"); err != nil {
+		log.Printf("[DEBUG] SCP is not available: %s", err)
+		return transferShell, nil
+	}
+	return transferSCP, nil
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellSession runs command in a new session, with stdin and stdout
+// connected to the given reader and writer.
+func (c *comm) shellSession(command string, stdin io.Reader, stdout io.Writer) error {
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stderr := new(bytes.Buffer)
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	log.Printf("[DEBUG] shell transfer: %s", command)
+	if err := session.Run(command); err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%q failed: %s: %s", command, err, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("%q failed: %s", command, err)
+	}
+	return nil
+}
+
+func (c *comm) shellUploadSession(path string, input io.Reader, fi *os.FileInfo) error {
+	path = filepath.ToSlash(path)
+	command := "cat > " + shellQuote(path)
+	if fi != nil && (*fi).Mode().IsRegular() {
+		command += fmt.Sprintf(" && chmod %04o %s", (*fi).Mode().Perm(), shellQuote(path))
+	}
+	return c.shellSession(command, input, io.Discard)
+}
+
+func (c *comm) shellDownloadSession(path string, output io.Writer) error {
+	return c.shellSession("cat "+shellQuote(filepath.ToSlash(path)), nil, output)
+}
+
+var errShellDownloadDir = errors.New(
+	"downloading directories needs SCP, which is not installed on the remote system")
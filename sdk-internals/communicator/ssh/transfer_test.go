@@ -0,0 +1,382 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	. "github.com/hashicorp/packer-plugin-sdk/sdk-internals/communicator/ssh"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTransferServer starts an SSH server running the commands with the local
+// shell, supporting the sftp subsystem and scp as asked. Like the default
+// OpenSSH configuration, it only accepts the LC_* environment variables, and
+// sets PTY_TERM when a PTY is requested.
+func newTransferServer(t *testing.T, withSftp, withScp bool) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	serve := func(channel ssh.Channel, requests <-chan *ssh.Request) {
+		defer channel.Close()
+		var env []string
+		for req := range requests {
+			switch req.Type {
+			case "env":
+				var payload struct{ Name, Value string }
+				ssh.Unmarshal(req.Payload, &payload)
+				accept := strings.HasPrefix(payload.Name, "LC_")
+				if accept {
+					env = append(env, payload.Name+"="+payload.Value)
+				}
+				req.Reply(accept, nil)
+			case "pty-req":
+				var payload struct{ Term string }
+				ssh.Unmarshal(req.Payload, &payload)
+				env = append(env, "PTY_TERM="+payload.Term)
+				req.Reply(true, nil)
+			case "subsystem":
+				req.Reply(withSftp, nil)
+				if !withSftp {
+					continue
+				}
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					return
+				}
+				server.Serve()
+				return
+			case "exec":
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+
+				status := 0
+				if payload.Command == "command -v scp" {
+					if !withScp {
+						status = 1
+					}
+				} else {
+					cmd := exec.Command("sh", "-c", payload.Command)
+					cmd.Env = append(os.Environ(), env...)
+					cmd.Stdin = channel
+					cmd.Stdout = channel
+					cmd.Stderr = channel.Stderr()
+					if err := cmd.Run(); err != nil {
+						status = 1
+					}
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+				return
+			default:
+				req.Reply(false, nil)
+			}
+		}
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(c, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go serve(channel, requests)
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestAutoFileTransfer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test server runs commands with sh")
+	}
+
+	for _, tc := range []struct {
+		name              string
+		withSftp, withScp bool
+	}{
+		{"sftp", true, true},
+		{"shell", false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			address := newTransferServer(t, tc.withSftp, tc.withScp)
+			comm, err := New(address, &Config{
+				Connection: func() (net.Conn, error) {
+					return net.Dial("tcp", address)
+				},
+				SSHConfig: &ssh.ClientConfig{
+					User:            "user",
+					HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+				},
+				DisableAgentForwarding: true,
+				AutoFileTransfer:       true,
+			})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "it's a file")
+			if err := comm.Upload(path, bytes.NewBufferString("content"), nil); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			var out bytes.Buffer
+			if err := comm.Download(path, &out); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if out.String() != "content" {
+				t.Fatalf("unexpected content %q", out.String())
+			}
+
+			src := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if err := os.WriteFile(filepath.Join(src, "sub", "file"), []byte("nested"), 0600); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if err := comm.UploadDir(dir, src+"/", nil); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			b, err := os.ReadFile(filepath.Join(dir, "sub", "file"))
+			if err != nil || string(b) != "nested" {
+				t.Fatalf("unexpected uploaded file %q: %v", b, err)
+			}
+			fi, err := os.Stat(filepath.Join(dir, "sub", "file"))
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if fi.Mode().Perm() != 0600 {
+				t.Fatalf("unexpected uploaded file mode %s", fi.Mode())
+			}
+		})
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test server runs commands with sh")
+	}
+
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"app/main.js":                  "main",
+		"app/README.md":                "readme",
+		"app/node_modules/dep/dep.js":  "dep",
+		"app/lib/util.js":              "util",
+		"app/lib/node_modules/x/x.js":  "x",
+		"app/lib/notes.txt":            "notes",
+		"app/build/output/bundle.js":   "bundle",
+		"app/build/output/bundle.map":  "map",
+		"app/build/output/nested/a.js": "a",
+	} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := os.Symlink("main.js", filepath.Join(src, "app", "link.js")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"app/",
+		"app/build/",
+		"app/build/output/",
+		"app/build/output/bundle.js",
+		"app/build/output/nested/",
+		"app/build/output/nested/a.js",
+		"app/lib/",
+		"app/lib/util.js",
+		"app/link.js",
+		"app/main.js",
+	}
+
+	for _, tc := range []struct {
+		name              string
+		withSftp, withScp bool
+		tar               bool
+	}{
+		{"tar", false, false, true},
+		{"sftp", true, false, false},
+		{"scp", false, true, false},
+		{"shell", false, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := exec.LookPath("scp"); tc.withScp && err != nil {
+				t.Skip("scp is not installed")
+			}
+			address := newTransferServer(t, tc.withSftp, tc.withScp)
+			comm, err := New(address, &Config{
+				Connection: func() (net.Conn, error) {
+					return net.Dial("tcp", address)
+				},
+				SSHConfig: &ssh.ClientConfig{
+					User:            "user",
+					HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+				},
+				DisableAgentForwarding: true,
+				AutoFileTransfer:       true,
+				UploadDirTar:           tc.tar,
+				UploadDirInclude:       []string{"*.js"},
+				UploadDirExclude:       []string{"node_modules"},
+				UploadDirSymlinks:      SymlinksPreserve,
+			})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			dst := t.TempDir()
+			if err := comm.UploadDir(dst, filepath.Join(src, "app"), []string{"build/output/*.map"}); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			var uploaded []string
+			err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+				if err != nil || path == dst {
+					return err
+				}
+				rel, _ := filepath.Rel(dst, path)
+				rel = filepath.ToSlash(rel)
+				if info.IsDir() {
+					rel += "/"
+				}
+				uploaded = append(uploaded, rel)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if diff := cmp.Diff(expected, uploaded); diff != "" {
+				t.Fatalf("unexpected uploaded files: %s", diff)
+			}
+			if tc.withScp {
+				// scp follows the links it can't upload.
+				if b, err := os.ReadFile(filepath.Join(dst, "app", "link.js")); err != nil || string(b) != "main" {
+					t.Fatalf("the link should have been followed, got %q: %v", b, err)
+				}
+			} else if link, err := os.Readlink(filepath.Join(dst, "app", "link.js")); err != nil || link != "main.js" {
+				t.Fatalf("the link should have been preserved, got %q: %v", link, err)
+			}
+		})
+	}
+}
+
+func TestMatchUploadPattern(t *testing.T) {
+	for _, tc := range []struct {
+		patterns []string
+		name     string
+		expected bool
+	}{
+		{[]string{"node_modules"}, "lib/node_modules", true},
+		{[]string{"*.js"}, "lib/util.js", true},
+		{[]string{"lib/*.js"}, "lib/util.js", true},
+		{[]string{"lib/*.js"}, "app/lib/util.js", false},
+		{[]string{"*.map", "*.txt"}, "notes.txt", true},
+		{[]string{"*.js"}, "main.go", false},
+		{nil, "main.go", false},
+	} {
+		if got := MatchUploadPattern(tc.patterns, tc.name); got != tc.expected {
+			t.Errorf("MatchUploadPattern(%q, %q) = %t, expected %t", tc.patterns, tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestStart_envAndPty(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test server runs commands with sh")
+	}
+
+	address := newTransferServer(t, false, false)
+	comm, err := New(address, &Config{
+		Connection: func() (net.Conn, error) {
+			return net.Dial("tcp", address)
+		},
+		SSHConfig: &ssh.ClientConfig{
+			User:            "user",
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		DisableAgentForwarding: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	run := func(cmd *packersdk.RemoteCmd) string {
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		if err := comm.Start(context.Background(), cmd); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if status := cmd.Wait(); status != 0 {
+			t.Fatalf("unexpected exit status %d", status)
+		}
+		return strings.TrimSpace(out.String())
+	}
+
+	// LC_SET is accepted by the server, SECRET is set with an env file.
+	out := run(&packersdk.RemoteCmd{
+		Command: `echo "$LC_SET $SECRET"`,
+		Env:     []string{"LC_SET=accepted", "SECRET=it's a secret"},
+	})
+	if out != "accepted it's a secret" {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	pty := true
+	if out := run(&packersdk.RemoteCmd{Command: `echo "$PTY_TERM"`, Pty: &pty}); out != "xterm" {
+		t.Fatalf("a PTY should have been requested, got %q", out)
+	}
+	if out := run(&packersdk.RemoteCmd{Command: `echo "$PTY_TERM"`}); out != "" {
+		t.Fatal("a PTY should not have been requested")
+	}
+
+	if err := comm.Start(context.Background(), &packersdk.RemoteCmd{Command: "true", Env: []string{"NOT A VARIABLE"}}); err == nil {
+		t.Fatal("should error with an invalid environment variable")
+	}
+}
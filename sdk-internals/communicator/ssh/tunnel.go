@@ -11,6 +11,14 @@ import (
 
 // ProxyServe starts Accepting connections
 func ProxyServe(l net.Listener, done <-chan struct{}, dialer func() (net.Conn, error)) {
+	serve(l, done, func(client net.Conn) {
+		handleProxyClient(client, dialer)
+	})
+}
+
+// serve accepts the connections of l until done, and handles each in its
+// goroutine.
+func serve(l net.Listener, done <-chan struct{}, handle func(net.Conn)) {
 	for {
 		// Accept will return if either the underlying connection is closed or if a connection is made.
 		// after returning, check to see if c.done can be received. If so, then Accept() returned because
@@ -27,7 +35,7 @@ func ProxyServe(l net.Listener, done <-chan struct{}, dialer func() (net.Conn, e
 			}
 			log.Printf("[DEBUG] Tunnel: client '%s' accepted", client.RemoteAddr())
 			// Proxy bytes from one side to the other
-			go handleProxyClient(client, dialer)
+			go handle(client)
 		}
 	}
 }
@@ -41,7 +49,12 @@ func handleProxyClient(clientConn net.Conn, dialer func() (net.Conn, error)) {
 		clientConn.Close()
 		return
 	}
+	proxyConns(clientConn, upstreamConn)
+}
 
+// proxyConns copies the data between clientConn and upstreamConn, until one
+// of them is closed.
+func proxyConns(clientConn, upstreamConn net.Conn) {
 	// channels to wait on the close event for each connection
 	serverClosed := make(chan struct{}, 1)
 	upstreamClosed := make(chan struct{}, 1)
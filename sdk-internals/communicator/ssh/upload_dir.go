@@ -0,0 +1,349 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ssh
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// SymlinkPolicy is how UploadDir handles symbolic links.
+type SymlinkPolicy string
+
+const (
+	// SymlinksFollow uploads the files and directories links point to.
+	SymlinksFollow SymlinkPolicy = "follow"
+	// SymlinksPreserve recreates the links on the remote end. Links are
+	// followed with scp, which can't transfer them.
+	SymlinksPreserve SymlinkPolicy = "preserve"
+	// SymlinksSkip doesn't upload links.
+	SymlinksSkip SymlinkPolicy = "skip"
+)
+
+// uploadEntry is a file, directory or link to upload.
+type uploadEntry struct {
+	// name is the slash separated path of the entry relative to the upload
+	// destination.
+	name string
+	// path is the local path of the entry.
+	path string
+	// info describes the entry, or the file a followed link points to.
+	info os.FileInfo
+	// link is the target of a preserved link.
+	link string
+}
+
+// MatchUploadPattern reports whether the slash separated path name, relative
+// to an uploaded directory, matches one of patterns. Patterns are globs
+// matched against the whole path, or against the base name of the path when
+// they contain no slash: `node_modules` matches any directory with that name.
+func MatchUploadPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadDirEntries returns the entries to upload for src, in the order they
+// must be created, following the UploadDir convention: without a trailing
+// slash the src directory itself is uploaded, otherwise only its contents.
+// Entries matching an exclude pattern are skipped, excluded directories with
+// their contents, and when include patterns are set only the files matching
+// one of them are uploaded.
+func (c *comm) uploadDirEntries(src string, excl []string, symlinks SymlinkPolicy) ([]uploadEntry, error) {
+	exclude := append(append([]string{}, c.config.UploadDirExclude...), excl...)
+	include := c.config.UploadDirInclude
+	if symlinks == "" {
+		symlinks = SymlinksFollow
+	}
+
+	var entries []uploadEntry
+	visited := map[string]bool{}
+
+	// walk appends the entries of the dir directory, uploaded as name. rel is
+	// its path relative to src, that patterns are matched against.
+	var walk func(dir, name, rel string) error
+	walk = func(dir, name, rel string) error {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if visited[real] {
+			log.Printf("[WARN] Not uploading %s again, its links form a loop", dir)
+			return nil
+		}
+		visited[real] = true
+		defer delete(visited, real)
+
+		f, err := os.Open(dir)
+		if err != nil {
+			return err
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			localPath := filepath.Join(dir, n)
+			entry := uploadEntry{name: path.Join(name, n), path: localPath}
+			rel := path.Join(rel, n)
+			if MatchUploadPattern(exclude, rel) {
+				log.Printf("[DEBUG] Excluding %s from the upload", rel)
+				continue
+			}
+
+			entry.info, err = os.Lstat(localPath)
+			if err != nil {
+				return err
+			}
+			if entry.info.Mode()&os.ModeSymlink != 0 {
+				switch symlinks {
+				case SymlinksSkip:
+					log.Printf("[DEBUG] Skipping link %s", rel)
+					continue
+				case SymlinksPreserve:
+					entry.link, err = os.Readlink(localPath)
+					if err != nil {
+						return err
+					}
+					entries = append(entries, entry)
+					continue
+				}
+				entry.info, err = os.Stat(localPath)
+				if err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case entry.info.IsDir():
+				entries = append(entries, entry)
+				if err := walk(localPath, entry.name, rel); err != nil {
+					return err
+				}
+			case entry.info.Mode().IsRegular():
+				if len(include) > 0 && !MatchUploadPattern(include, rel) {
+					continue
+				}
+				entries = append(entries, entry)
+			default:
+				log.Printf("[WARN] Not uploading %s, it is not a regular file", rel)
+			}
+		}
+		return nil
+	}
+
+	prefix := ""
+	if src[len(src)-1] != '/' {
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, err
+		}
+		prefix = filepath.Base(src)
+		log.Printf("[DEBUG] No trailing slash, creating directory %s", prefix)
+		entries = append(entries, uploadEntry{name: prefix, path: src, info: info})
+	}
+	return entries, walk(src, prefix, "")
+}
+
+// tarUploadAvailable tells whether tar is installed on the remote end, to
+// extract the archives streamed by UploadDir.
+func (c *comm) tarUploadAvailable() bool {
+	c.transferLock.Lock()
+	defer c.transferLock.Unlock()
+	if c.remoteTar != nil {
+		return *c.remoteTar
+	}
+	if c.config.PowerShellExec {
+		log.Printf("[INFO] Streaming directories in tar archives needs a POSIX shell, uploading them file by file")
+		return false
+	}
+
+	session, err := c.newSession()
+	if err != nil {
+		return false
+	}
+	defer session.Close()
+	available := session.Run("command -v tar") == nil
+	if !available {
+		log.Printf("[INFO] tar is not installed on the remote end, uploading directories file by file")
+	}
+	c.remoteTar = &available
+	return available
+}
+
+// tarUploadDirSession uploads the entries in a tar archive extracted by tar
+// on the remote end.
+func (c *comm) tarUploadDirSession(dst string, entries []uploadEntry) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeUploadTar(pw, entries))
+	}()
+	defer pr.Close()
+
+	dst = filepath.ToSlash(dst)
+	log.Printf("[DEBUG] tar: uploading %d entries to %s", len(entries), dst)
+	return c.shellSession("tar -xf - -C "+shellQuote(dst), pr, io.Discard)
+}
+
+func writeUploadTar(w io.Writer, entries []uploadEntry) error {
+	bw := bufio.NewWriter(w)
+	tw := tar.NewWriter(bw)
+	for _, entry := range entries {
+		hdr, err := tar.FileInfoHeader(entry.info, entry.link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = entry.name
+		if entry.info.IsDir() {
+			hdr.Name += "/"
+		}
+		// Files are owned by the remote user extracting them.
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		f, err := os.Open(entry.path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (c *comm) sftpUploadDirSession(dst string, entries []uploadEntry) error {
+	return c.sftpSession(func(client *sftp.Client) error {
+		for _, entry := range entries {
+			// In Windows, Join uses backslashes which we don't want to get
+			// to the sftp server
+			finalDst := filepath.ToSlash(filepath.Join(dst, entry.name))
+			log.Printf("[DEBUG] sftp: uploading %q to %q", entry.path, finalDst)
+
+			var err error
+			switch {
+			case entry.link != "":
+				client.Remove(finalDst)
+				err = client.Symlink(entry.link, finalDst)
+			case entry.info.IsDir():
+				err = c.sftpMkdir(finalDst, client, entry.info)
+			default:
+				err = c.sftpUploadEntry(finalDst, entry, client)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *comm) sftpUploadEntry(dst string, entry uploadEntry, client *sftp.Client) error {
+	f, err := os.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.sftpUploadFile(dst, f, client, &entry.info)
+}
+
+func (c *comm) shellUploadDirSession(dst string, entries []uploadEntry) error {
+	for _, entry := range entries {
+		finalDst := filepath.ToSlash(filepath.Join(dst, entry.name))
+
+		var err error
+		switch {
+		case entry.link != "":
+			err = c.shellSession(fmt.Sprintf("ln -sfn %s %s",
+				shellQuote(entry.link), shellQuote(finalDst)), nil, io.Discard)
+		case entry.info.IsDir():
+			err = c.shellSession(fmt.Sprintf("mkdir -p %s && chmod %04o %s",
+				shellQuote(finalDst), entry.info.Mode().Perm(), shellQuote(finalDst)), nil, io.Discard)
+		default:
+			err = c.shellUploadEntry(finalDst, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *comm) shellUploadEntry(dst string, entry uploadEntry) error {
+	f, err := os.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.shellUploadSession(dst, f, &entry.info)
+}
+
+func (c *comm) scpUploadDirSession(dst string, entries []uploadEntry) error {
+	scpFunc := func(w io.Writer, r *bufio.Reader) error {
+		// dirs are the directories being uploaded, SCP needs to be told
+		// when we are done with one.
+		var dirs []string
+		for _, entry := range entries {
+			for len(dirs) > 0 && !strings.HasPrefix(entry.name, dirs[len(dirs)-1]+"/") {
+				fmt.Fprintln(w, "E")
+				dirs = dirs[:len(dirs)-1]
+			}
+
+			if entry.info.IsDir() {
+				log.Printf("[DEBUG] SCP: starting directory upload: %s", entry.name)
+				fmt.Fprintln(w, fmt.Sprintf("D%04o 0", entry.info.Mode().Perm()), path.Base(entry.name))
+				if err := checkSCPStatus(r); err != nil {
+					return err
+				}
+				dirs = append(dirs, entry.name)
+				continue
+			}
+
+			f, err := os.Open(entry.path)
+			if err != nil {
+				return err
+			}
+			err = scpUploadFile(path.Base(entry.name), f, w, r, &entry.info)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		for range dirs {
+			fmt.Fprintln(w, "E")
+		}
+		return nil
+	}
+
+	return c.scpSession("scp -rvt "+dst, scpFunc)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/masterzen/winrm"
+)
+
+// receiveTimeout is the WSMan operation timeout of the requests polling the
+// output of commands, within the default MaxTimeoutms of WinRM. A command
+// without output for that long is polled again.
+const receiveTimeout = 60 * time.Second
+
+var (
+	// receiveRetryTimeout is how long transient errors polling the output
+	// of a command are retried for, before the connection is considered
+	// lost.
+	receiveRetryTimeout = time.Minute
+	receiveRetryDelay   = 5 * time.Second
+)
+
+// WSMan fault codes.
+const (
+	faultOperationTimeout = "2150858793"
+	faultShellNotFound    = "2150858843"
+)
+
+func (c *Communicator) createShell() (string, error) {
+	request := winrm.NewOpenShellRequest(c.url, &c.client.Parameters)
+	defer request.Free()
+
+	response, err := c.transport.Post(c.client, request)
+	if err != nil {
+		return "", err
+	}
+	return winrm.ParseOpenShellResponse(response)
+}
+
+func (c *Communicator) deleteShell(shellID string) {
+	request := winrm.NewDeleteShellRequest(c.url, shellID, &c.client.Parameters)
+	defer request.Free()
+
+	if _, err := c.transport.Post(c.client, request); err != nil {
+		log.Printf("[WARN] Error closing the WinRM shell: %s", err)
+	}
+}
+
+func (c *Communicator) execute(shellID, command string) (string, error) {
+	request := winrm.NewExecuteCommandRequest(c.url, shellID, command, nil, &c.client.Parameters)
+	defer request.Free()
+
+	response, err := c.transport.Post(c.client, request)
+	if err != nil {
+		return "", err
+	}
+	return winrm.ParseExecuteCommandResponse(response)
+}
+
+func (c *Communicator) signal(shellID, commandID string) {
+	request := winrm.NewSignalRequest(c.url, shellID, commandID, &c.client.Parameters)
+	defer request.Free()
+
+	if _, err := c.transport.Post(c.client, request); err != nil {
+		log.Printf("[WARN] Error terminating the WinRM command: %s", err)
+	}
+}
+
+// receive polls the output of a command until it exits, and returns its exit
+// code. The output is written as it comes. Operation timeouts, returned when
+// the command has no output, are polled again, and other errors are retried
+// for up to receiveRetryTimeout. The error of the last attempt is returned
+// when the connection is lost, or when the shell is gone, as when the
+// machine restarted.
+func (c *Communicator) receive(shellID, commandID string, stdout, stderr io.Writer) (int, error) {
+	params := c.client.Parameters
+	params.Timeout = formatDuration(receiveTimeout)
+
+	var failingSince time.Time
+	for {
+		request := winrm.NewGetOutputRequest(c.url, shellID, commandID, "stdout stderr", &params)
+		response, err := c.transport.Post(c.client, request)
+		request.Free()
+		if err == nil && strings.Contains(response, "WSManFault") {
+			// The default transport doesn't return the faults of http
+			// errors as errors.
+			err = errors.New(response)
+		}
+
+		switch {
+		case err == nil:
+			failingSince = time.Time{}
+			finished, code, err := winrm.ParseSlurpOutputErrResponse(response, stdout, stderr)
+			if err != nil {
+				return 0, err
+			}
+			if finished {
+				return code, nil
+			}
+		case isFault(err, faultOperationTimeout) || strings.Contains(err.Error(), "OperationTimeout"):
+			failingSince = time.Time{}
+		case isFault(err, faultShellNotFound):
+			return 0, err
+		default:
+			if failingSince.IsZero() {
+				failingSince = time.Now()
+			}
+			if time.Since(failingSince) > receiveRetryTimeout {
+				return 0, err
+			}
+			log.Printf("[WARN] Error polling the output of the WinRM command, retrying: %s", err)
+			time.Sleep(receiveRetryDelay)
+		}
+	}
+}
+
+// isFault returns whether err holds the WSMan fault of the given code.
+func isFault(err error, code string) bool {
+	return strings.Contains(err.Error(), `Code="`+code+`"`)
+}
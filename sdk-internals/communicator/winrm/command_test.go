@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStart_receive(t *testing.T) {
+	defer func(timeout, delay time.Duration) {
+		receiveRetryTimeout, receiveRetryDelay = timeout, delay
+	}(receiveRetryTimeout, receiveRetryDelay)
+	receiveRetryTimeout, receiveRetryDelay = 200*time.Millisecond, 10*time.Millisecond
+
+	// The connection is dropped for longer than receiveRetryTimeout.
+	lost := make([]string, 100)
+	for i := range lost {
+		lost[i] = "drop"
+	}
+
+	for _, tc := range []struct {
+		name     string
+		command  string
+		faults   []string
+		stdout   string
+		exitCode int
+	}{
+		{"output", "echo foo", nil, "foo", 0},
+		{"exit code", "exit 3", nil, "", 3},
+		{"operation timeouts", "echo foo", []string{faultOperationTimeout, faultOperationTimeout}, "foo", 0},
+		{"connection dropped", "echo foo", []string{"drop", "drop"}, "foo", 0},
+		{"restarted", "echo foo", []string{faultShellNotFound}, "", packersdk.CmdDisconnect},
+		{"connection lost", "echo foo", lost, "", packersdk.CmdDisconnect},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, config := newFakeWinRM(t)
+			c, err := New(config)
+			if err != nil {
+				t.Fatalf("error creating communicator: %s", err)
+			}
+			fake.receiveFaults = tc.faults
+
+			stdout := new(bytes.Buffer)
+			cmd := &packersdk.RemoteCmd{Command: tc.command, Stdout: stdout}
+			if err := c.Start(context.Background(), cmd); err != nil {
+				t.Fatalf("error executing remote command: %s", err)
+			}
+			cmd.Wait()
+
+			if stdout.String() != tc.stdout {
+				t.Fatalf("bad command output: expected %q, got %q", tc.stdout, stdout.String())
+			}
+			if cmd.ExitStatus() != tc.exitCode {
+				t.Fatalf("bad exit code: expected %d, got %d", tc.exitCode, cmd.ExitStatus())
+			}
+		})
+	}
+}
@@ -9,14 +9,15 @@ package winrm
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/masterzen/winrm"
@@ -25,13 +26,19 @@ import (
 
 // Communicator represents the WinRM communicator
 type Communicator struct {
-	config   *Config
-	client   *winrm.Client
-	endpoint *winrm.Endpoint
+	config    *Config
+	client    *winrm.Client
+	endpoint  *winrm.Endpoint
+	transport winrm.Transporter
+	url       string
 }
 
 // New creates a new communicator implementation over WinRM.
 func New(config *Config) (*Communicator, error) {
+	scheme := "http"
+	if config.Https {
+		scheme = "https"
+	}
 	endpoint := &winrm.Endpoint{
 		Host:     config.Host,
 		Port:     config.Port,
@@ -49,8 +56,21 @@ func New(config *Config) (*Communicator, error) {
 	// Create the client
 	params := *winrm.DefaultParameters
 
-	if config.TransportDecorator != nil {
-		params.TransportDecorator = config.TransportDecorator
+	c := &Communicator{
+		config:   config,
+		endpoint: endpoint,
+		url:      fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(config.Host, strconv.Itoa(config.Port))),
+	}
+
+	// The transport is kept to poll the output of commands with requests of
+	// our own.
+	decorator := config.TransportDecorator
+	if decorator == nil {
+		decorator = func() winrm.Transporter { return winrm.NewClientWithDial(nil) }
+	}
+	params.TransportDecorator = func() winrm.Transporter {
+		c.transport = decorator()
+		return c.transport
 	}
 
 	params.Timeout = formatDuration(config.Timeout)
@@ -59,6 +79,7 @@ func New(config *Config) (*Communicator, error) {
 	if err != nil {
 		return nil, err
 	}
+	c.client = client
 
 	// Create the shell to verify the connection
 	log.Printf("[DEBUG] connecting to remote shell using WinRM")
@@ -73,67 +94,57 @@ func New(config *Config) (*Communicator, error) {
 		return nil, err
 	}
 
-	return &Communicator{
-		config:   config,
-		client:   client,
-		endpoint: endpoint,
-	}, nil
+	return c, nil
 }
 
 // Start implementation of communicator.Communicator interface
 func (c *Communicator) Start(ctx context.Context, rc *packersdk.RemoteCmd) error {
-	shell, err := c.client.CreateShell()
+	if len(rc.Env) > 0 {
+		return errors.New("WinRM doesn't support setting environment variables for commands")
+	}
+
+	shellID, err := c.createShell()
 	if err != nil {
 		return err
 	}
 
 	log.Printf("[INFO] starting remote command: %s", rc.Command)
-	cmd, err := shell.Execute(rc.Command)
+	commandID, err := c.execute(shellID, rc.Command)
 	if err != nil {
+		c.deleteShell(shellID)
 		return err
 	}
 
-	go runCommand(shell, cmd, rc)
+	go c.runCommand(shellID, commandID, rc)
 	return nil
 }
 
-func runCommand(shell *winrm.Shell, cmd *winrm.Command, rc *packersdk.RemoteCmd) {
-	defer shell.Close()
-	var wg sync.WaitGroup
+func (c *Communicator) runCommand(shellID, commandID string, rc *packersdk.RemoteCmd) {
+	defer c.deleteShell(shellID)
 
-	copyFunc := func(w io.Writer, r io.Reader) {
-		defer wg.Done()
-		io.Copy(w, r)
+	stdout, stderr := rc.Stdout, rc.Stderr
+	if stdout == nil {
+		stdout = io.Discard
 	}
-
-	if rc.Stdout != nil && cmd.Stdout != nil {
-		wg.Add(1)
-		go copyFunc(rc.Stdout, cmd.Stdout)
-	} else {
-		log.Printf("[WARN] Failed to read stdout for command '%s'", rc.Command)
+	if stderr == nil {
+		stderr = io.Discard
 	}
 
-	if rc.Stderr != nil && cmd.Stderr != nil {
-		wg.Add(1)
-		go copyFunc(rc.Stderr, cmd.Stderr)
+	code, err := c.receive(shellID, commandID, stdout, stderr)
+	if err != nil {
+		// Commands restarting the machine end this way.
+		log.Printf("[ERROR] Remote command failed, the WinRM connection was lost: %s: %s", rc.Command, err)
+		code = packersdk.CmdDisconnect
 	} else {
-		log.Printf("[WARN] Failed to read stderr for command '%s'", rc.Command)
+		c.signal(shellID, commandID)
 	}
 
-	cmd.Wait()
-	wg.Wait()
-
-	code := cmd.ExitCode()
 	log.Printf("[INFO] command '%s' exited with code: %d", rc.Command, code)
 	rc.SetExited(code)
 }
 
 // Upload implementation of communicator.Communicator interface
 func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) error {
-	wcp, err := c.newCopyClient()
-	if err != nil {
-		return fmt.Errorf("Was unable to create winrm client: %s", err)
-	}
 	if strings.HasSuffix(path, `\`) {
 		// path is a directory
 		if fi != nil {
@@ -143,7 +154,7 @@ func (c *Communicator) Upload(path string, input io.Reader, fi *os.FileInfo) err
 		}
 	}
 	log.Printf("Uploading file to '%s'", path)
-	return wcp.Write(path, input)
+	return c.upload(path, input)
 }
 
 // UploadDir implementation of communicator.Communicator interface
@@ -152,11 +163,26 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 		dst = fmt.Sprintf("%s\\%s", dst, filepath.Base(src))
 	}
 	log.Printf("Uploading dir '%s' to '%s'", src, dst)
-	wcp, err := c.newCopyClient()
-	if err != nil {
-		return err
-	}
-	return wcp.Copy(src, dst)
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Directories are created with the files they hold. OS X special
+		// hidden files are skipped.
+		if fi.IsDir() || fi.Name() == ".DS_Store" {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Couldn't read file %s: %s", path, err)
+		}
+		defer f.Close()
+		return c.upload(filepath.Join(dst, rel), f)
+	})
 }
 
 func (c *Communicator) Download(src string, dst io.Writer) error {
@@ -165,13 +191,27 @@ func (c *Communicator) Download(src string, dst io.Writer) error {
 		return err
 	}
 
-	encodeScript := `$file=[System.IO.File]::ReadAllBytes("%s"); Write-Output $([System.Convert]::ToBase64String($file))`
-
-	base64DecodePipe := &Base64Pipe{w: dst}
+	// The file is read and sent in chunks, which are decoded as they come
+	// rather than once the whole file is received.
+	encodeScript := `$s=[IO.File]::OpenRead("%s")
+try{$b=New-Object byte[] 49152
+while(($n=$s.Read($b,0,$b.Length)) -gt 0){[Console]::Out.WriteLine([Convert]::ToBase64String($b,0,$n))}}
+finally{$s.Close()}`
+
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(dst, base64.NewDecoder(base64.StdEncoding, r))
+		r.CloseWithError(err)
+		done <- err
+	}()
 
 	cmd := winrm.Powershell(fmt.Sprintf(encodeScript, src))
-	_, err = client.Run(cmd, base64DecodePipe, io.Discard)
-
+	_, err = client.Run(cmd, w, io.Discard)
+	w.CloseWithError(err)
+	if decodeErr := <-done; err == nil {
+		err = decodeErr
+	}
 	return err
 }
 
@@ -193,12 +233,6 @@ func (c *Communicator) getClientConfig() *winrmcp.Config {
 	}
 }
 
-func (c *Communicator) newCopyClient() (*winrmcp.Winrmcp, error) {
-	addr := net.JoinHostPort(c.endpoint.Host, fmt.Sprint(c.endpoint.Port))
-	clientConfig := c.getClientConfig()
-	return winrmcp.New(addr, clientConfig)
-}
-
 func (c *Communicator) newWinRMClient() (*winrm.Client, error) {
 	conf := c.getClientConfig()
 
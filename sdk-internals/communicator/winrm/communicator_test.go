@@ -28,18 +28,6 @@ func newMockWinRMServer(t *testing.T) *winrmtest.Remote {
 			return 0
 		})
 
-	wrm.CommandFunc(
-		winrmtest.MatchPattern(`^echo c29tZXRoaW5n >> ".*"$`),
-		func(out, err io.Writer) int {
-			return 0
-		})
-
-	wrm.CommandFunc(
-		winrmtest.MatchPattern(`^echo `+BASE64_ENCODED_PAYLOAD+` >> ".*"$`),
-		func(out, err io.Writer) int {
-			return 0
-		})
-
 	wrm.CommandFunc(
 		winrmtest.MatchPattern(`^powershell.exe -EncodedCommand .*$`),
 		func(out, err io.Writer) int {
@@ -94,16 +82,9 @@ func TestStart(t *testing.T) {
 }
 
 func TestUpload(t *testing.T) {
-	wrm := newMockWinRMServer(t)
-	defer wrm.Close()
+	_, config := newFakeWinRM(t)
 
-	c, err := New(&Config{
-		Host:     wrm.Host,
-		Port:     wrm.Port,
-		Username: "user",
-		Password: "pass",
-		Timeout:  30 * time.Second,
-	})
+	c, err := New(config)
 	if err != nil {
 		t.Fatalf("error creating communicator: %s", err)
 	}
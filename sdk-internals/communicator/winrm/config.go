@@ -19,4 +19,10 @@ type Config struct {
 	Https              bool
 	Insecure           bool
 	TransportDecorator func() winrm.Transporter
+	// UploadChunkSize is the size of the chunks files are uploaded in,
+	// defaults to DefaultUploadChunkSize.
+	UploadChunkSize int
+	// UploadParallelism is the number of streams a file is uploaded with,
+	// when it can be read at offsets, like an *os.File.
+	UploadParallelism int
 }
@@ -0,0 +1,342 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/chksumtype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+// KerberosConfig configures the Kerberos authentication to WinRM.
+type KerberosConfig struct {
+	// Username and Password are the credentials of the user, the realm can
+	// be part of the username, as in user@REALM. Password is ignored when a
+	// keytab or a credentials cache is set.
+	Username string
+	Password string
+	// Realm defaults to the default_realm of the Kerberos configuration.
+	Realm string
+	// Keytab is the path of a keytab holding the keys of the user.
+	Keytab string
+	// CCache is the path of a credentials cache, as filled by kinit. It
+	// defaults to $KRB5CCNAME, or /tmp/krb5cc_<uid>, when neither a keytab
+	// nor a password are set.
+	CCache string
+	// Config is the path of the Kerberos configuration, defaults to
+	// $KRB5_CONFIG or /etc/krb5.conf.
+	Config string
+	// SPN is the service principal name of WinRM, defaults to HTTP/<host>.
+	SPN string
+	// Proxy returns the proxy to use for a request, defaults to
+	// http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// NewKerberos returns a winrm.Transporter authenticating with Kerberos,
+// through SPNEGO. Over HTTPS, the authentication is bound to the TLS channel
+// with the tls-server-end-point channel bindings, so that it can't be relayed
+// to another server. Over HTTP, WinRM must allow unencrypted messages as
+// messages are not sealed.
+func NewKerberos(config *KerberosConfig) winrm.Transporter {
+	return &kerberosTransporter{config: *config}
+}
+
+type kerberosTransporter struct {
+	config KerberosConfig
+
+	httpClient *http.Client
+	url        string
+	https      bool
+	spn        string
+
+	l      sync.Mutex
+	client *krbclient.Client
+	// bindings is the hash of the channel bindings of the HTTPS
+	// connection, once known.
+	bindings []byte
+}
+
+func (t *kerberosTransporter) Transport(endpoint *winrm.Endpoint) error {
+	proxy := http.ProxyFromEnvironment
+	if t.config.Proxy != nil {
+		proxy = t.config.Proxy
+	}
+	transport := &http.Transport{
+		Proxy: proxy,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: endpoint.Insecure,
+			ServerName:         endpoint.TLSServerName,
+		},
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: endpoint.Timeout,
+	}
+	if len(endpoint.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(endpoint.CACert) {
+			return errors.New("unable to read the CA certificates")
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	t.httpClient = &http.Client{Transport: transport}
+
+	scheme := "http"
+	if endpoint.HTTPS {
+		scheme = "https"
+	}
+	t.https = endpoint.HTTPS
+	t.url = fmt.Sprintf("%s://%s/wsman", scheme, net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.Port)))
+	t.spn = t.config.SPN
+	if t.spn == "" {
+		t.spn = "HTTP/" + endpoint.Host
+	}
+	return nil
+}
+
+func (t *kerberosTransporter) Post(_ *winrm.Client, request *soap.SoapMessage) (string, error) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if err := t.login(); err != nil {
+		return "", fmt.Errorf("Kerberos login failed: %w", err)
+	}
+
+	if t.https && t.bindings == nil {
+		// The channel bindings hash the certificate of the server, which is
+		// only known once connected.
+		resp, err := t.do("", "")
+		if err != nil {
+			return "", err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+			return "", errors.New("the WinRM server didn't present a certificate")
+		}
+		t.bindings = channelBindings(resp.TLS.PeerCertificates[0])
+	}
+
+	header, err := t.negotiateHeader()
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.do(request.String(), header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error while reading the response body: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", fmt.Errorf("http error 401: the Kerberos authentication to %s was rejected", t.spn)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("http error %d: %s", resp.StatusCode, b)
+	case !strings.Contains(resp.Header.Get("Content-Type"), "application/soap+xml"):
+		return "", errors.New("invalid content type")
+	}
+	return string(b), nil
+}
+
+func (t *kerberosTransporter) do(body, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", t.url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("impossible to create http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	if authorization != "" {
+		req.Header.Set(spnego.HTTPHeaderAuthRequest, authorization)
+	}
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unknown error: %w", err)
+	}
+	return resp, nil
+}
+
+// login gets the credentials of the user, from the credentials cache or by
+// logging in to the KDC.
+func (t *kerberosTransporter) login() error {
+	if t.client != nil {
+		return nil
+	}
+
+	path := t.config.Config
+	if path == "" {
+		path = os.Getenv("KRB5_CONFIG")
+	}
+	if path == "" {
+		path = "/etc/krb5.conf"
+	}
+	cfg, err := krbconfig.Load(path)
+	if err != nil {
+		return fmt.Errorf("error reading the Kerberos configuration %s: %w", path, err)
+	}
+
+	username, realm := t.config.Username, t.config.Realm
+	if i := strings.LastIndex(username, "@"); i >= 0 {
+		username, realm = username[:i], username[i+1:]
+	}
+	if realm == "" {
+		realm = cfg.LibDefaults.DefaultRealm
+	}
+
+	settings := krbclient.DisablePAFXFAST(true)
+	ccache := t.config.CCache
+	switch {
+	case t.config.Keytab != "":
+		kt, err := keytab.Load(t.config.Keytab)
+		if err != nil {
+			return fmt.Errorf("error reading the keytab %s: %w", t.config.Keytab, err)
+		}
+		t.client = krbclient.NewWithKeytab(username, realm, kt, cfg, settings)
+	case ccache == "" && t.config.Password != "":
+		t.client = krbclient.NewWithPassword(username, realm, t.config.Password, cfg, settings)
+	default:
+		if ccache == "" {
+			ccache = defaultCCache()
+		}
+		cc, err := credentials.LoadCCache(ccache)
+		if err != nil {
+			return fmt.Errorf("error reading the credentials cache %s: %w", ccache, err)
+		}
+		client, err := krbclient.NewFromCCache(cc, cfg, settings)
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] Using the Kerberos credentials of %s from %s", client.Credentials.CName().PrincipalNameString(), ccache)
+		t.client = client
+		return nil
+	}
+
+	if err := t.client.Login(); err != nil {
+		t.client = nil
+		return err
+	}
+	return nil
+}
+
+func defaultCCache() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return strings.TrimPrefix(name, "FILE:")
+	}
+	return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+}
+
+// negotiateHeader returns the Authorization header holding a SPNEGO token
+// for the WinRM service. The token is built here rather than with
+// spnego.SetSPNEGOHeader, which has no channel bindings.
+func (t *kerberosTransporter) negotiateHeader() (string, error) {
+	tkt, key, err := t.client.GetServiceTicket(t.spn)
+	if err != nil {
+		return "", fmt.Errorf("error getting a service ticket for %s: %w", t.spn, err)
+	}
+
+	auth, err := types.NewAuthenticator(t.client.Credentials.Domain(), t.client.Credentials.CName())
+	if err != nil {
+		return "", err
+	}
+	auth.Cksum = types.Checksum{
+		CksumType: chksumtype.GSSAPI,
+		Checksum:  authenticatorChecksum(t.bindings),
+	}
+	apReq, err := messages.NewAPReq(tkt, key, auth)
+	if err != nil {
+		return "", err
+	}
+	apReqBytes, err := apReq.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	// The KRB5 mech token of RFC 4121 section 4.1.
+	mech, _ := asn1.Marshal(gssapi.OIDKRB5.OID())
+	mech = append(mech, 0x01, 0x00) // AP-REQ
+	mech = asn1tools.AddASNAppTag(append(mech, apReqBytes...), 0)
+
+	token := spnego.SPNEGOToken{
+		Init: true,
+		NegTokenInit: spnego.NegTokenInit{
+			MechTypes:      []asn1.ObjectIdentifier{gssapi.OIDKRB5.OID()},
+			MechTokenBytes: mech,
+		},
+	}
+	b, err := token.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return "Negotiate " + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// authenticatorChecksum returns the checksum of the authenticator of RFC
+// 4121 section 4.1.1, with the hash of the channel bindings, if any.
+func authenticatorChecksum(bindings []byte) []byte {
+	b := make([]byte, 24)
+	binary.LittleEndian.PutUint32(b[:4], 16)
+	copy(b[4:20], bindings)
+	binary.LittleEndian.PutUint32(b[20:], gssapi.ContextFlagInteg|gssapi.ContextFlagConf)
+	return b
+}
+
+// channelBindings returns the MD5 hash of the tls-server-end-point channel
+// bindings (RFC 5929) of a connection to a server with cert.
+func channelBindings(cert *x509.Certificate) []byte {
+	// The certificate is hashed with the hash of its signature, SHA-256
+	// when it is MD5 or SHA-1.
+	var h hash.Hash
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.SHA384WithRSAPSS, x509.ECDSAWithSHA384:
+		h = sha512.New384()
+	case x509.SHA512WithRSA, x509.SHA512WithRSAPSS, x509.ECDSAWithSHA512:
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+	h.Write(cert.Raw)
+	data := append([]byte("tls-server-end-point:"), h.Sum(nil)...)
+
+	// gss_channel_bindings_struct without addresses.
+	b := make([]byte, 20+len(data))
+	binary.LittleEndian.PutUint32(b[16:], uint32(len(data)))
+	copy(b[20:], data)
+	sum := md5.Sum(b)
+	return sum[:]
+}
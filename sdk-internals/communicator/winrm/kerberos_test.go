@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/masterzen/winrm"
+	"github.com/masterzen/winrm/soap"
+)
+
+func TestChannelBindings(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	cert := server.Certificate()
+
+	// RFC 5929 with the gss_channel_bindings_struct of RFC 2744, without
+	// addresses.
+	hash := sha256.Sum256(cert.Raw)
+	data := append([]byte("tls-server-end-point:"), hash[:]...)
+	var b bytes.Buffer
+	b.Write(make([]byte, 16))
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	expected := md5.Sum(b.Bytes())
+
+	bindings := channelBindings(cert)
+	if !bytes.Equal(bindings, expected[:]) {
+		t.Fatalf("unexpected channel bindings %x, expected %x", bindings, expected)
+	}
+
+	checksum := authenticatorChecksum(bindings)
+	if len(checksum) != 24 || binary.LittleEndian.Uint32(checksum) != 16 {
+		t.Fatalf("unexpected checksum %x", checksum)
+	}
+	if !bytes.Equal(checksum[4:20], bindings) {
+		t.Fatalf("the checksum doesn't hold the channel bindings: %x", checksum)
+	}
+	if !bytes.Equal(authenticatorChecksum(nil)[4:20], make([]byte, 16)) {
+		t.Fatal("the checksum should not have channel bindings")
+	}
+}
+
+func TestKerberos_loginErrors(t *testing.T) {
+	dir := t.TempDir()
+	krb5conf := filepath.Join(dir, "krb5.conf")
+	if err := os.WriteFile(krb5conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for name, config := range map[string]*KerberosConfig{
+		"configuration": {Config: filepath.Join(dir, "missing.conf")},
+		"keytab":        {Config: krb5conf, Username: "admin", Keytab: filepath.Join(dir, "missing.keytab")},
+		"ccache":        {Config: krb5conf, CCache: filepath.Join(dir, "missing.ccache")},
+	} {
+		transporter := NewKerberos(config)
+		if err := transporter.Transport(&winrm.Endpoint{Host: "127.0.0.1", Port: 5985}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		_, err := transporter.Post(nil, soap.NewMessage())
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected an error reading the %s, got %v", name, err)
+		}
+	}
+}
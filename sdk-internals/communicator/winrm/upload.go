@@ -0,0 +1,184 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"github.com/masterzen/winrm"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultUploadChunkSize is the default size of the chunks uploaded files are
+// sent in, small enough for a chunk to fit in a WinRM message.
+const DefaultUploadChunkSize = 64 * 1024
+
+// uploadStreamScript writes the base64 encoded lines of its standard input to
+// the temporary file, from an offset.
+const uploadStreamScript = `$p=Join-Path $env:TEMP '%s'
+$f=[IO.File]::Open($p,'OpenOrCreate','Write','ReadWrite')
+try{$f.Position=%d
+while(($l=[Console]::In.ReadLine()) -ne $null){if($l){$b=[Convert]::FromBase64String($l);$f.Write($b,0,$b.Length)}}}
+finally{$f.Close()}`
+
+// uploadFinishScript moves the temporary file to its destination, once its
+// SHA256 hash is verified.
+const uploadFinishScript = `$p=Join-Path $env:TEMP '%s'
+$s=[IO.File]::OpenRead($p)
+try{$h=[BitConverter]::ToString([Security.Cryptography.SHA256]::Create().ComputeHash($s)) -replace '-'}finally{$s.Close()}
+if($h -ne '%s'){Remove-Item $p;[Console]::Error.WriteLine("the SHA256 hash of the uploaded file is $h");exit 2}
+$d=[IO.Path]::GetFullPath("%s")
+if(Test-Path -PathType Container $d){Remove-Item $p;[Console]::Error.WriteLine("$d is a directory");exit 1}
+New-Item -ItemType Directory -Force -Path ([IO.Path]::GetDirectoryName($d)) | Out-Null
+Move-Item -Force $p $d`
+
+// upload uploads input to path. Chunks are sent to the standard input of
+// PowerShell commands writing them to a temporary file, in parallel streams
+// when input can be read at offsets, then the file is moved to path once
+// its hash matches the one of input.
+func (c *Communicator) upload(path string, input io.Reader) error {
+	client, err := c.newWinRMClient()
+	if err != nil {
+		return fmt.Errorf("Was unable to create winrm client: %s", err)
+	}
+	chunkSize := c.config.UploadChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultUploadChunkSize
+	}
+	tmp := fmt.Sprintf("packer-upload-%s.tmp", uuid.TimeOrderedUUID())
+	hash := sha256.New()
+
+	ra, size, ok := readerAt(input)
+	if streams := c.config.UploadParallelism; ok && streams > 1 && size > int64(chunkSize) {
+		if _, err := io.Copy(hash, io.NewSectionReader(ra, 0, size)); err != nil {
+			return err
+		}
+		// Streams upload whole chunks, but the last one.
+		chunks := (size + int64(chunkSize) - 1) / int64(chunkSize)
+		perStream := (chunks + int64(streams) - 1) / int64(streams) * int64(chunkSize)
+		log.Printf("[DEBUG] Uploading %d bytes to %s in %d streams", size, path, (size+perStream-1)/perStream)
+
+		var g errgroup.Group
+		for offset := int64(0); offset < size; offset += perStream {
+			offset := offset
+			n := perStream
+			if offset+n > size {
+				n = size - offset
+			}
+			g.Go(func() error {
+				return uploadStream(client, tmp, offset, io.NewSectionReader(ra, offset, n), chunkSize)
+			})
+		}
+		err = g.Wait()
+	} else {
+		err = uploadStream(client, tmp, 0, io.TeeReader(input, hash), chunkSize)
+	}
+	if err != nil {
+		return fmt.Errorf("Error uploading file to %s: %s", path, err)
+	}
+
+	sum := strings.ToUpper(hex.EncodeToString(hash.Sum(nil)))
+	script := fmt.Sprintf(uploadFinishScript, tmp, sum, winPath(path))
+	if err := runUploadCommand(client, script, nil, chunkSize); err != nil {
+		return fmt.Errorf("Error restoring file to %s: %s", path, err)
+	}
+	return nil
+}
+
+// readerAt returns input as an io.ReaderAt reading from its current offset,
+// and the size left to read, when it is seekable.
+func readerAt(input io.Reader) (io.ReaderAt, int64, bool) {
+	ra, ok := input.(io.ReaderAt)
+	seeker, ok2 := input.(io.Seeker)
+	if !ok || !ok2 {
+		return nil, 0, false
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, false
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, false
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, false
+	}
+	return io.NewSectionReader(ra, start, end-start), end - start, true
+}
+
+func uploadStream(client *winrm.Client, tmp string, offset int64, r io.Reader, chunkSize int) error {
+	return runUploadCommand(client, fmt.Sprintf(uploadStreamScript, tmp, offset), r, chunkSize)
+}
+
+// runUploadCommand runs a PowerShell script, with the content of input sent
+// base64 encoded to its standard input, one line per chunk.
+func runUploadCommand(client *winrm.Client, script string, input io.Reader, chunkSize int) error {
+	shell, err := client.CreateShell()
+	if err != nil {
+		return fmt.Errorf("Couldn't create shell: %s", err)
+	}
+	defer shell.Close()
+
+	cmd, err := shell.Execute(winrm.Powershell(script))
+	if err != nil {
+		return err
+	}
+	defer cmd.Close()
+
+	var wg sync.WaitGroup
+	var stderr bytes.Buffer
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(io.Discard, cmd.Stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(&stderr, cmd.Stderr)
+	}()
+
+	if input != nil {
+		chunk := make([]byte, chunkSize)
+		for {
+			n, err := io.ReadFull(input, chunk)
+			if n > 0 {
+				line := base64.StdEncoding.EncodeToString(chunk[:n]) + "\n"
+				if _, err := cmd.Stdin.Write([]byte(line)); err != nil {
+					return fmt.Errorf("Error sending the file: %s", err)
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := cmd.Stdin.Close(); err != nil {
+		return fmt.Errorf("Error sending the file: %s", err)
+	}
+
+	cmd.Wait()
+	wg.Wait()
+	if code := cmd.ExitCode(); code != 0 {
+		return fmt.Errorf("the upload command exited with %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// winPath returns path with Windows separators.
+func winPath(path string) string {
+	return strings.ReplaceAll(strings.Trim(path, `'"`), "/", `\`)
+}
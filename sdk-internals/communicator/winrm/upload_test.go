@@ -0,0 +1,303 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package winrm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+var (
+	fakeActionRe     = regexp.MustCompile(`Action[^>]*>([^<]+)<`)
+	fakeStreamRe     = regexp.MustCompile(`:Stream ([^>]*)>([^<]*)<`)
+	fakeCommandIdRe  = regexp.MustCompile(`CommandId="([^"]+)"`)
+	fakeTempRe       = regexp.MustCompile(`Join-Path \$env:TEMP '([^']+)'`)
+	fakeOffsetRe     = regexp.MustCompile(`Position=(\d+)`)
+	fakeHashRe       = regexp.MustCompile(`-ne '([0-9A-F]+)'`)
+	fakeDstRe        = regexp.MustCompile(`GetFullPath\("([^"]+)"\)`)
+	fakeDownloadRe   = regexp.MustCompile(`OpenRead\("([^"]+)"\)`)
+	fakeEncodedCmdRe = regexp.MustCompile(`-EncodedCommand ([A-Za-z0-9+/=]+)`)
+	fakeRawCmdRe     = regexp.MustCompile(`<!\[CDATA\[(.*?)\]\]>`)
+)
+
+// fakeWinRM is a WinRM server running the upload and download scripts of the
+// communicator against in memory files, and simple echo and exit commands.
+type fakeWinRM struct {
+	l        sync.Mutex
+	files    map[string][]byte
+	commands map[string]*fakeCommand
+	// streams is the number of upload streams run.
+	streams int
+	// corrupt corrupts the uploaded files when set.
+	corrupt bool
+	// receiveFaults are the codes of the WSMan faults returned to the next
+	// Receive requests, "drop" drops the connection instead.
+	receiveFaults []string
+}
+
+type fakeCommand struct {
+	script string
+	stdin  bytes.Buffer
+	ended  chan struct{}
+}
+
+func newFakeWinRM(t *testing.T) (*fakeWinRM, *Config) {
+	f := &fakeWinRM{
+		files:    map[string][]byte{},
+		commands: map[string]*fakeCommand{},
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	return f, &Config{
+		Host:     host,
+		Port:     p,
+		Username: "user",
+		Password: "pass",
+		Timeout:  30 * time.Second,
+	}
+}
+
+func (f *fakeWinRM) file(path string) []byte {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.files[path]
+}
+
+func (f *fakeWinRM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/soap+xml")
+	var body bytes.Buffer
+	body.ReadFrom(r.Body)
+	req := body.String()
+
+	action := fakeActionRe.FindStringSubmatch(req)[1]
+	switch {
+	case strings.HasSuffix(action, "transfer/Create"):
+		fmt.Fprint(w, `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+			<rsp:ShellId>123</rsp:ShellId>
+		</env:Envelope>`)
+
+	case strings.HasSuffix(action, "shell/Command"):
+		script := fakeRawCmdRe.FindStringSubmatch(req)[1]
+		if m := fakeEncodedCmdRe.FindStringSubmatch(req); m != nil {
+			b, _ := base64.StdEncoding.DecodeString(m[1])
+			encoded := make([]uint16, len(b)/2)
+			for i := range encoded {
+				encoded[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+			}
+			script = string(utf16.Decode(encoded))
+		}
+		f.l.Lock()
+		id := strconv.Itoa(len(f.commands) + 1)
+		f.commands[id] = &fakeCommand{script: script, ended: make(chan struct{})}
+		f.l.Unlock()
+		fmt.Fprintf(w, `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+			<rsp:CommandId>%s</rsp:CommandId>
+		</env:Envelope>`, id)
+
+	case strings.HasSuffix(action, "shell/Send"):
+		m := fakeStreamRe.FindStringSubmatch(req)
+		id := fakeCommandIdRe.FindStringSubmatch(m[1])[1]
+		data, _ := base64.StdEncoding.DecodeString(m[2])
+		f.l.Lock()
+		cmd := f.commands[id]
+		cmd.stdin.Write(data)
+		if strings.Contains(m[1], `End="true"`) {
+			close(cmd.ended)
+		}
+		f.l.Unlock()
+		fmt.Fprint(w, `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"></env:Envelope>`)
+
+	case strings.HasSuffix(action, "shell/Receive"):
+		id := fakeCommandIdRe.FindStringSubmatch(req)[1]
+		f.l.Lock()
+		cmd := f.commands[id]
+		var fault string
+		if len(f.receiveFaults) > 0 {
+			fault, f.receiveFaults = f.receiveFaults[0], f.receiveFaults[1:]
+		}
+		f.l.Unlock()
+		switch fault {
+		case "":
+		case "drop":
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault>
+				<s:Detail><f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="%s"/></s:Detail>
+			</s:Fault></s:Body></s:Envelope>`, fault)
+			return
+		}
+		if strings.Contains(cmd.script, "ReadLine") || strings.Contains(cmd.script, "ComputeHash") {
+			<-cmd.ended
+		}
+		stdout, stderr, code := f.run(cmd)
+		fmt.Fprintf(w, `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+			<rsp:ReceiveResponse>
+				<rsp:Stream Name="stdout" CommandId="%s">%s</rsp:Stream>
+				<rsp:Stream Name="stderr" CommandId="%s">%s</rsp:Stream>
+				<rsp:Stream Name="stdout" CommandId="%s" End="true"></rsp:Stream>
+				<rsp:Stream Name="stderr" CommandId="%s" End="true"></rsp:Stream>
+				<rsp:CommandState State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">
+					<rsp:ExitCode>%d</rsp:ExitCode>
+				</rsp:CommandState>
+			</rsp:ReceiveResponse>
+		</env:Envelope>`, id, base64.StdEncoding.EncodeToString([]byte(stdout)),
+			id, base64.StdEncoding.EncodeToString([]byte(stderr)), id, id, code)
+
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// run emulates the scripts of the communicator.
+func (f *fakeWinRM) run(cmd *fakeCommand) (stdout, stderr string, code int) {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	switch {
+	case strings.Contains(cmd.script, "ReadLine"):
+		f.streams++
+		tmp := fakeTempRe.FindStringSubmatch(cmd.script)[1]
+		offset, _ := strconv.Atoi(fakeOffsetRe.FindStringSubmatch(cmd.script)[1])
+		content := f.files[tmp]
+		for _, line := range strings.Split(cmd.stdin.String(), "\n") {
+			b, err := base64.StdEncoding.DecodeString(line)
+			if err != nil {
+				return "", err.Error(), 1
+			}
+			if f.corrupt && len(b) > 0 {
+				b[0]++
+			}
+			for len(content) < offset+len(b) {
+				content = append(content, 0)
+			}
+			copy(content[offset:], b)
+			offset += len(b)
+		}
+		f.files[tmp] = content
+	case strings.Contains(cmd.script, "ComputeHash"):
+		tmp := fakeTempRe.FindStringSubmatch(cmd.script)[1]
+		sum := sha256.Sum256(f.files[tmp])
+		if strings.ToUpper(hex.EncodeToString(sum[:])) != fakeHashRe.FindStringSubmatch(cmd.script)[1] {
+			delete(f.files, tmp)
+			return "", "the SHA256 hash of the uploaded file is wrong", 2
+		}
+		f.files[fakeDstRe.FindStringSubmatch(cmd.script)[1]] = f.files[tmp]
+		delete(f.files, tmp)
+	case strings.Contains(cmd.script, "Out.WriteLine"):
+		content := f.files[winPath(fakeDownloadRe.FindStringSubmatch(cmd.script)[1])]
+		return base64.StdEncoding.EncodeToString(content), "", 0
+	case strings.HasPrefix(cmd.script, "echo "):
+		return strings.TrimPrefix(cmd.script, "echo "), "", 0
+	case strings.HasPrefix(cmd.script, "exit "):
+		code, _ := strconv.Atoi(strings.TrimPrefix(cmd.script, "exit "))
+		return "", "", code
+	}
+	return "", "", 0
+}
+
+func TestUpload_parallel(t *testing.T) {
+	fake, config := newFakeWinRM(t)
+	config.UploadChunkSize = 1024
+	config.UploadParallelism = 4
+	c, err := New(config)
+	if err != nil {
+		t.Fatalf("error creating communicator: %s", err)
+	}
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	if err := c.Upload("C:/Temp/file", f, nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	if !bytes.Equal(fake.file(`C:\Temp\file`), content) {
+		t.Fatal("the uploaded file doesn't match")
+	}
+	if fake.streams != 4 {
+		t.Fatalf("the file should have been uploaded in 4 streams, got %d", fake.streams)
+	}
+
+	// Readers that can't be read at offsets are uploaded in one stream.
+	fake.streams = 0
+	if err := c.Upload("C:/Temp/other", bytes.NewBufferString("other"), nil); err != nil {
+		t.Fatalf("error uploading file: %s", err)
+	}
+	if string(fake.file(`C:\Temp\other`)) != "other" || fake.streams != 1 {
+		t.Fatalf("unexpected upload of %q in %d streams", fake.file(`C:\Temp\other`), fake.streams)
+	}
+}
+
+func TestUpload_integrity(t *testing.T) {
+	fake, config := newFakeWinRM(t)
+	c, err := New(config)
+	if err != nil {
+		t.Fatalf("error creating communicator: %s", err)
+	}
+
+	fake.corrupt = true
+	err = c.Upload("C:/Temp/file", strings.NewReader(PAYLOAD), nil)
+	if err == nil || !strings.Contains(err.Error(), "SHA256") {
+		t.Fatalf("the upload should fail the hash verification, got %v", err)
+	}
+	if fake.file(`C:\Temp\file`) != nil {
+		t.Fatal("the corrupted file should not have been restored")
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	fake, config := newFakeWinRM(t)
+	c, err := New(config)
+	if err != nil {
+		t.Fatalf("error creating communicator: %s", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for name, content := range map[string]string{"a.txt": "a", "sub/b.txt": "b", ".DS_Store": "x"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if err := c.UploadDir(`C:\dst`, src, nil); err != nil {
+		t.Fatalf("error uploading dir: %s", err)
+	}
+	if string(fake.file(`C:\dst\src\a.txt`)) != "a" || string(fake.file(`C:\dst\src\sub\b.txt`)) != "b" {
+		t.Fatalf("unexpected uploaded files %q", fake.files)
+	}
+	if fake.file(`C:\dst\src\.DS_Store`) != nil {
+		t.Fatal(".DS_Store should not have been uploaded")
+	}
+}
@@ -17,6 +17,9 @@ import (
 
 type Communicator struct {
 	ExecuteCommand []string
+	// Dir is the working directory of the command. Defaults to the current
+	// directory.
+	Dir string
 }
 
 func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
@@ -27,9 +30,13 @@ func (c *Communicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) erro
 	// Build the local command to execute
 	log.Printf("[INFO] (shell-local communicator): Executing local shell command %s", c.ExecuteCommand)
 	localCmd := exec.CommandContext(ctx, c.ExecuteCommand[0], c.ExecuteCommand[1:]...)
+	localCmd.Dir = c.Dir
 	localCmd.Stdin = cmd.Stdin
 	localCmd.Stdout = cmd.Stdout
 	localCmd.Stderr = cmd.Stderr
+	if len(cmd.Env) > 0 {
+		localCmd.Env = append(os.Environ(), cmd.Env...)
+	}
 
 	// Start it. If it doesn't work, then error right away.
 	if err := localCmd.Start(); err != nil {
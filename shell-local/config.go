@@ -8,6 +8,7 @@ package shell_local
 import (
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -15,6 +16,7 @@ import (
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/shell"
+	"github.com/hashicorp/packer-plugin-sdk/shell-local/localexec"
 	configHelper "github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
@@ -32,6 +34,14 @@ type Config struct {
 	// can be used to inject the environment_vars into the environment.
 	ExecuteCommand []string `mapstructure:"execute_command"`
 
+	// The interpreters the scripts are run with when `execute_command` is
+	// not set, tried in order until one is found in the PATH: `sh`, `bash`,
+	// `zsh`, `cmd`, `powershell` or `pwsh`. Inline commands are written in
+	// the language of the interpreter found. Defaults to `["cmd",
+	// "powershell", "pwsh"]` on Windows, so that no bash compatible shell is
+	// required, and to running the scripts with `/bin/sh` elsewhere.
+	Interpreters []string `mapstructure:"interpreters"`
+
 	// The shebang value used when running inline scripts.
 	InlineShebang string `mapstructure:"inline_shebang"`
 
@@ -44,11 +54,48 @@ type Config struct {
 	// End dedupe with postprocessor
 	UseLinuxPathing bool `mapstructure:"use_linux_pathing"`
 
+	// Write the environment variables to a temporary file, removed once the
+	// scripts ran, rather than to the command line, where they are visible
+	// to the other processes of the host. `{{.Vars}}` loads the file in the
+	// execute command, and `{{.EnvVarFile}}` is its path.
+	UseEnvVarFile bool `mapstructure:"use_env_var_file"`
+
+	// Pass the environment variables in the environment of the execute
+	// command rather than on its command line. `{{.Vars}}` is empty.
+	UseProcessEnvironment bool `mapstructure:"use_process_environment"`
+
+	// The names of the environment variables, set with `environment_vars` or
+	// `env`, whose values are replaced by `<sensitive>` in the output and
+	// the logs.
+	SensitiveEnvVars []string `mapstructure:"sensitive_env_vars"`
+
+	// The format the scripts write values computed for the next steps of the
+	// build in on their standard output: `json`, a JSON object, or
+	// `key_value`, lines of `key=value` where the other lines are ignored.
+	// The values are published as generated data, see PublishOutputs. By
+	// default, the output is not parsed.
+	OutputFormat string `mapstructure:"output_format"`
+
+	// Run the scripts in a new temporary directory, removed once they ran,
+	// rather than in the current directory, so that the shell-local steps of
+	// parallel builds don't overwrite the files of each other. The inline
+	// scripts are written to it, and `{{.WorkingDir}}` is its path.
+	IsolatedWorkingDir bool `mapstructure:"isolated_working_dir"`
+
+	// The paths of lock files held while the scripts run, so that the
+	// shell-local steps of parallel builds using the same resources, like a
+	// shared cache, run one at a time. The files are created when they don't
+	// exist.
+	LockFiles []string `mapstructure:"lock_files"`
+
 	// used to track the data sent to shell-local from the builder
 	// GeneratedData
 
 	ctx           interpolate.Context
 	generatedData map[string]interface{}
+	interpreter   *localexec.Interpreter
+	outputs       map[string]string
+	workingDir    string
 }
 
 func Decode(config *Config, raws ...interface{}) error {
@@ -72,6 +119,21 @@ func Decode(config *Config, raws ...interface{}) error {
 func Validate(config *Config) error {
 	var errs *packersdk.MultiError
 
+	if len(config.ExecuteCommand) == 0 {
+		interpreters := config.Interpreters
+		if len(interpreters) == 0 && runtime.GOOS == "windows" {
+			interpreters = localexec.DefaultInterpreters()
+		}
+		if len(interpreters) > 0 {
+			interpreter, err := localexec.FindInterpreter(interpreters...)
+			if err != nil {
+				errs = packersdk.MultiErrorAppend(errs, err)
+			} else {
+				config.useInterpreter(interpreter)
+			}
+		}
+	}
+
 	if runtime.GOOS == "windows" {
 		if len(config.ExecuteCommand) == 0 {
 			config.ExecuteCommand = []string{
@@ -87,7 +149,7 @@ func Validate(config *Config) error {
 			config.TempfileExtension = ".cmd"
 		}
 	} else {
-		if config.InlineShebang == "" {
+		if config.InlineShebang == "" && config.interpreter == nil {
 			config.InlineShebang = "/bin/sh -e"
 		}
 		if len(config.ExecuteCommand) == 0 {
@@ -187,6 +249,33 @@ func Validate(config *Config) error {
 		}
 	}
 
+	if config.UseEnvVarFile && config.UseProcessEnvironment {
+		errs = packersdk.MultiErrorAppend(errs,
+			errors.New("Only one of use_env_var_file and use_process_environment can be set."))
+	}
+
+	switch config.OutputFormat {
+	case "", OutputFormatJSON, OutputFormatKeyValue:
+	default:
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("Invalid output_format %q, expected %q or %q.",
+				config.OutputFormat, OutputFormatJSON, OutputFormatKeyValue))
+	}
+
+	for _, path := range config.LockFiles {
+		if path == "" {
+			errs = packersdk.MultiErrorAppend(errs,
+				errors.New("lock_files cannot contain empty paths."))
+		}
+	}
+
+	for _, name := range config.SensitiveEnvVars {
+		if name == "" {
+			errs = packersdk.MultiErrorAppend(errs,
+				errors.New("sensitive_env_vars cannot contain empty names."))
+		}
+	}
+
 	// drop unnecessary "." in extension; we add this later.
 	config.TempfileExtension = strings.TrimPrefix(config.TempfileExtension, ".")
 
@@ -206,6 +295,47 @@ func Validate(config *Config) error {
 	return nil
 }
 
+// useInterpreter sets the execute command, the extension of the inline
+// scripts and the format of the environment variables which are not set to
+// the ones of interpreter.
+func (config *Config) useInterpreter(interpreter *localexec.Interpreter) {
+	log.Printf("[INFO] (shell-local): using the %s interpreter at %s", interpreter.Name, interpreter.Path)
+	config.interpreter = interpreter
+
+	switch {
+	case interpreter.IsCmd():
+		config.ExecuteCommand = []string{interpreter.Path, "/V", "/C", "{{.Vars}}", "call", "{{.Script}}"}
+		if config.EnvVarFormat == "" {
+			config.EnvVarFormat = "set %s=%s && "
+		}
+	case interpreter.IsPowerShell():
+		config.ExecuteCommand = interpreter.CommandArgs("{{.Vars}}& '{{.Script}}'; exit $LASTEXITCODE")
+		if config.EnvVarFormat == "" {
+			config.EnvVarFormat = "$env:%s='%s'; "
+		}
+	default:
+		config.ExecuteCommand = interpreter.CommandArgs("{{.Vars}} {{.Script}}")
+		if config.InlineShebang == "" {
+			config.InlineShebang = interpreter.Path + " -e"
+		}
+		if config.EnvVarFormat == "" {
+			config.EnvVarFormat = "%s='%s' "
+		}
+	}
+	if config.TempfileExtension == "" {
+		config.TempfileExtension = interpreter.ScriptExtension()
+	}
+}
+
+// escapeEnvVar escapes the single quotes of value, so that it parses
+// correctly with the environment variable format.
+func (config *Config) escapeEnvVar(value string) string {
+	if config.interpreter != nil && config.interpreter.IsPowerShell() {
+		return strings.Replace(value, "'", "''", -1)
+	}
+	return strings.Replace(value, "'", `'"'"'`, -1)
+}
+
 // C:/path/to/your/file becomes /mnt/c/path/to/your/file
 func ConvertToLinuxPath(winAbsPath string) (string, error) {
 	// get absolute path of script, and morph it into the bash path
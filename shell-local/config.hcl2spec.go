@@ -10,27 +10,34 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName     *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType   *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion   *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug         *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce         *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError       *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars      map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	Inline              []string          `cty:"inline" hcl:"inline"`
-	Script              *string           `cty:"script" hcl:"script"`
-	Scripts             []string          `cty:"scripts" hcl:"scripts"`
-	ValidExitCodes      []int             `mapstructure:"valid_exit_codes" cty:"valid_exit_codes" hcl:"valid_exit_codes"`
-	Vars                []string          `mapstructure:"environment_vars" cty:"environment_vars" hcl:"environment_vars"`
-	Env                 map[string]string `mapstructure:"env" cty:"env" hcl:"env"`
-	EnvVarFormat        *string           `mapstructure:"env_var_format" cty:"env_var_format" hcl:"env_var_format"`
-	Command             *string           `cty:"command" hcl:"command"`
-	ExecuteCommand      []string          `mapstructure:"execute_command" cty:"execute_command" hcl:"execute_command"`
-	InlineShebang       *string           `mapstructure:"inline_shebang" cty:"inline_shebang" hcl:"inline_shebang"`
-	OnlyOn              []string          `mapstructure:"only_on" cty:"only_on" hcl:"only_on"`
-	TempfileExtension   *string           `mapstructure:"tempfile_extension" cty:"tempfile_extension" hcl:"tempfile_extension"`
-	UseLinuxPathing     *bool             `mapstructure:"use_linux_pathing" cty:"use_linux_pathing" hcl:"use_linux_pathing"`
+	PackerBuildName       *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType     *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion     *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug           *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce           *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError         *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars        map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars   []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Inline                []string          `cty:"inline" hcl:"inline"`
+	Script                *string           `cty:"script" hcl:"script"`
+	Scripts               []string          `cty:"scripts" hcl:"scripts"`
+	ValidExitCodes        []int             `mapstructure:"valid_exit_codes" cty:"valid_exit_codes" hcl:"valid_exit_codes"`
+	Vars                  []string          `mapstructure:"environment_vars" cty:"environment_vars" hcl:"environment_vars"`
+	Env                   map[string]string `mapstructure:"env" cty:"env" hcl:"env"`
+	EnvVarFormat          *string           `mapstructure:"env_var_format" cty:"env_var_format" hcl:"env_var_format"`
+	Command               *string           `cty:"command" hcl:"command"`
+	ExecuteCommand        []string          `mapstructure:"execute_command" cty:"execute_command" hcl:"execute_command"`
+	Interpreters          []string          `mapstructure:"interpreters" cty:"interpreters" hcl:"interpreters"`
+	InlineShebang         *string           `mapstructure:"inline_shebang" cty:"inline_shebang" hcl:"inline_shebang"`
+	OnlyOn                []string          `mapstructure:"only_on" cty:"only_on" hcl:"only_on"`
+	TempfileExtension     *string           `mapstructure:"tempfile_extension" cty:"tempfile_extension" hcl:"tempfile_extension"`
+	UseLinuxPathing       *bool             `mapstructure:"use_linux_pathing" cty:"use_linux_pathing" hcl:"use_linux_pathing"`
+	UseEnvVarFile         *bool             `mapstructure:"use_env_var_file" cty:"use_env_var_file" hcl:"use_env_var_file"`
+	UseProcessEnvironment *bool             `mapstructure:"use_process_environment" cty:"use_process_environment" hcl:"use_process_environment"`
+	SensitiveEnvVars      []string          `mapstructure:"sensitive_env_vars" cty:"sensitive_env_vars" hcl:"sensitive_env_vars"`
+	OutputFormat          *string           `mapstructure:"output_format" cty:"output_format" hcl:"output_format"`
+	IsolatedWorkingDir    *bool             `mapstructure:"isolated_working_dir" cty:"isolated_working_dir" hcl:"isolated_working_dir"`
+	LockFiles             []string          `mapstructure:"lock_files" cty:"lock_files" hcl:"lock_files"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -62,10 +69,17 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"env_var_format":             &hcldec.AttrSpec{Name: "env_var_format", Type: cty.String, Required: false},
 		"command":                    &hcldec.AttrSpec{Name: "command", Type: cty.String, Required: false},
 		"execute_command":            &hcldec.AttrSpec{Name: "execute_command", Type: cty.List(cty.String), Required: false},
+		"interpreters":               &hcldec.AttrSpec{Name: "interpreters", Type: cty.List(cty.String), Required: false},
 		"inline_shebang":             &hcldec.AttrSpec{Name: "inline_shebang", Type: cty.String, Required: false},
 		"only_on":                    &hcldec.AttrSpec{Name: "only_on", Type: cty.List(cty.String), Required: false},
 		"tempfile_extension":         &hcldec.AttrSpec{Name: "tempfile_extension", Type: cty.String, Required: false},
 		"use_linux_pathing":          &hcldec.AttrSpec{Name: "use_linux_pathing", Type: cty.Bool, Required: false},
+		"use_env_var_file":           &hcldec.AttrSpec{Name: "use_env_var_file", Type: cty.Bool, Required: false},
+		"use_process_environment":    &hcldec.AttrSpec{Name: "use_process_environment", Type: cty.Bool, Required: false},
+		"sensitive_env_vars":         &hcldec.AttrSpec{Name: "sensitive_env_vars", Type: cty.List(cty.String), Required: false},
+		"output_format":              &hcldec.AttrSpec{Name: "output_format", Type: cty.String, Required: false},
+		"isolated_working_dir":       &hcldec.AttrSpec{Name: "isolated_working_dir", Type: cty.Bool, Required: false},
+		"lock_files":                 &hcldec.AttrSpec{Name: "lock_files", Type: cty.List(cty.String), Required: false},
 	}
 	return s
 }
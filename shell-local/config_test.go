@@ -4,6 +4,8 @@
 package shell_local
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +19,64 @@ func TestConvertToLinuxPath(t *testing.T) {
 		"Should have converted %s to %s -- not %s", winPath, winBashPath, converted)
 
 }
+
+func TestValidate_interpreters(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pwsh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Setenv("PATH", dir)
+
+	config := &Config{Interpreters: []string{"powershell", "pwsh", "cmd"}}
+	config.Inline = []string{"Write-Host hi"}
+	config.Vars = []string{"FOO=it's"}
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pwsh := filepath.Join(dir, "pwsh")
+	assert.Equal(t, []string{pwsh, "-NoLogo", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass",
+		"-Command", "{{.Vars}}& '{{.Script}}'; exit $LASTEXITCODE"}, config.ExecuteCommand)
+	assert.Equal(t, "ps1", config.TempfileExtension)
+	assert.Equal(t, "", config.InlineShebang)
+
+	config.generatedData = map[string]interface{}{}
+	vars, err := createFlattenedEnvVars(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, "$env:FOO='it''s'; $env:PACKER_BUILDER_TYPE=''; $env:PACKER_BUILD_NAME=''; ", vars)
+
+	// The execute command takes precedence.
+	config = &Config{Interpreters: []string{"pwsh"}, ExecuteCommand: []string{"/bin/bash", "-c", "{{.Script}}"}}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, []string{"/bin/bash", "-c", "{{.Script}}"}, config.ExecuteCommand)
+
+	config = &Config{Interpreters: []string{"cmd", "bash"}}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err == nil {
+		t.Fatal("validating interpreters not in the PATH should fail")
+	}
+
+	config = &Config{Interpreters: []string{"fish"}}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err == nil {
+		t.Fatal("validating an unknown interpreter should fail")
+	}
+}
+
+func TestValidate_envVars(t *testing.T) {
+	config := &Config{UseEnvVarFile: true, UseProcessEnvironment: true}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err == nil {
+		t.Fatal("validating both use_env_var_file and use_process_environment should fail")
+	}
+
+	config = &Config{SensitiveEnvVars: []string{""}}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err == nil {
+		t.Fatal("validating an empty sensitive variable name should fail")
+	}
+}
@@ -11,7 +11,9 @@ post-processor.
 However, the localexec sub-package can be used in any plugins that need local
 shell access, whether that is in a driver for a hypervisor, or a command to a
 third party cli tool. Please make sure that any third party tool dependencies
-are noted in your plugin's documentation.
+are noted in your plugin's documentation. ShellCommand runs inline commands
+with the shell found on the host, like PowerShell or cmd on Windows, so that
+plugins don't have to assume a bash compatible shell is installed.
 */
 
 package shell_local
@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package localexec
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KnownInterpreters are the names of the interpreters FindInterpreter knows
+// how to run commands with.
+var KnownInterpreters = []string{"sh", "bash", "zsh", "cmd", "powershell", "pwsh"}
+
+// DefaultInterpreters returns the interpreters tried when none are
+// configured: cmd, then Windows PowerShell and PowerShell on Windows, which
+// don't require a bash compatible shell to be installed, and sh elsewhere.
+func DefaultInterpreters() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "powershell", "pwsh"}
+	}
+	return []string{"sh"}
+}
+
+// Interpreter is a shell of the local host.
+type Interpreter struct {
+	// Name is one of KnownInterpreters.
+	Name string
+	// Path is the path of the executable of the shell.
+	Path string
+}
+
+// FindInterpreter returns the first of the named interpreters found in the
+// PATH.
+func FindInterpreter(names ...string) (*Interpreter, error) {
+	for _, name := range names {
+		if !IsKnownInterpreter(name) {
+			return nil, fmt.Errorf("Unknown interpreter %q, expected one of: %s",
+				name, strings.Join(KnownInterpreters, ", "))
+		}
+	}
+	for _, name := range names {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		return &Interpreter{Name: name, Path: path}, nil
+	}
+	return nil, fmt.Errorf("None of the interpreters %s were found in the PATH",
+		strings.Join(names, ", "))
+}
+
+// IsKnownInterpreter returns whether name is one of KnownInterpreters.
+func IsKnownInterpreter(name string) bool {
+	for _, n := range KnownInterpreters {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPowerShell returns whether the interpreter is Windows PowerShell or
+// PowerShell.
+func (i *Interpreter) IsPowerShell() bool {
+	return i.Name == "powershell" || i.Name == "pwsh"
+}
+
+// IsCmd returns whether the interpreter is the Windows command interpreter.
+func (i *Interpreter) IsCmd() bool {
+	return i.Name == "cmd"
+}
+
+// CommandArgs returns the arguments running command, written in the language
+// of the interpreter, starting with the executable of the interpreter.
+func (i *Interpreter) CommandArgs(command string) []string {
+	switch {
+	case i.IsCmd():
+		return []string{i.Path, "/V", "/C", command}
+	case i.IsPowerShell():
+		return []string{i.Path, "-NoLogo", "-NoProfile", "-NonInteractive",
+			"-ExecutionPolicy", "Bypass", "-Command", command}
+	default:
+		return []string{i.Path, "-c", command}
+	}
+}
+
+// ShellCommand returns the command running command, written in the language
+// of the first of the named interpreters found, or of DefaultInterpreters
+// when none are named. The command can be run with RunAndStream.
+func ShellCommand(command string, interpreters ...string) (*exec.Cmd, error) {
+	if len(interpreters) == 0 {
+		interpreters = DefaultInterpreters()
+	}
+	i, err := FindInterpreter(interpreters...)
+	if err != nil {
+		return nil, err
+	}
+	args := i.CommandArgs(command)
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// ScriptExtension returns the extension, without its dot, the interpreter
+// requires the scripts it runs to have, if any.
+func (i *Interpreter) ScriptExtension() string {
+	switch {
+	case i.IsCmd():
+		return "cmd"
+	case i.IsPowerShell():
+		return "ps1"
+	default:
+		return ""
+	}
+}
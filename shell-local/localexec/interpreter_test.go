@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package localexec
+
+import (
+	"runtime"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestFindInterpreter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+	i, err := FindInterpreter("pwsh", "sh")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if i.Name != "sh" && i.Name != "pwsh" {
+		t.Fatalf("unexpected interpreter %#v", i)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := FindInterpreter("sh"); err == nil {
+		t.Fatal("finding an interpreter not in the PATH should fail")
+	}
+	if _, err := FindInterpreter("fish"); err == nil {
+		t.Fatal("finding an unknown interpreter should fail")
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+	cmd, err := ShellCommand("test \"$(echo foo)\" = foo || exit 3")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := RunAndStream(cmd, packersdk.TestUi(t), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
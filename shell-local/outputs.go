@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shell_local
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+const (
+	// OutputFormatJSON parses the output of the scripts as a JSON object.
+	OutputFormatJSON = "json"
+	// OutputFormatKeyValue parses the lines of the output of the scripts
+	// looking like key=value.
+	OutputFormatKeyValue = "key_value"
+)
+
+var keyValueRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// Outputs returns the values the scripts ran by Run wrote on their standard
+// output, when OutputFormat is set. When several scripts write the same key,
+// the value of the last one is kept.
+func (config *Config) Outputs() map[string]string {
+	return config.outputs
+}
+
+// PublishOutputs puts outputs, as returned by Config.Outputs, in the
+// generated data of state, so that the provisioners and post-processors
+// following the step running shell-local can use them.
+func PublishOutputs(state multistep.StateBag, outputs map[string]string) {
+	gd := &packerbuilderdata.GeneratedData{State: state}
+	for k, v := range outputs {
+		gd.Put(k, v)
+	}
+}
+
+// parseOutput parses the standard output of a script with format. The values
+// of a JSON object which are not strings are kept JSON encoded.
+func parseOutput(format string, output string) (map[string]string, error) {
+	outputs := make(map[string]string)
+	switch format {
+	case OutputFormatJSON:
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(output), &object); err != nil {
+			return nil, fmt.Errorf("Error parsing the output of the script as a JSON object: %s", err)
+		}
+		for k, v := range object {
+			if s, ok := v.(string); ok {
+				outputs[k] = s
+				continue
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			outputs[k] = string(b)
+		}
+	case OutputFormatKeyValue:
+		s := bufio.NewScanner(strings.NewReader(output))
+		for s.Scan() {
+			m := keyValueRe.FindStringSubmatch(strings.TrimRight(s.Text(), "\r"))
+			if m != nil {
+				outputs[m[1]] = m[2]
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("Error reading the output of the script: %s", err)
+		}
+	}
+	return outputs, nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shell_local
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutput(t *testing.T) {
+	outputs, err := parseOutput(OutputFormatJSON, `{"id": "i-123", "count": 2, "tags": ["a"]}`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, map[string]string{"id": "i-123", "count": "2", "tags": `["a"]`}, outputs)
+
+	if _, err := parseOutput(OutputFormatJSON, "building...\n{}"); err == nil {
+		t.Fatal("parsing an output which is not a JSON object should fail")
+	}
+
+	outputs, err = parseOutput(OutputFormatKeyValue, "building...\r\nID=i-123\r\nURL=http://host/?a=b\n= ignored\n")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assert.Equal(t, map[string]string{"ID": "i-123", "URL": "http://host/?a=b"}, outputs)
+}
+
+func TestRun_outputs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+	config := &Config{OutputFormat: OutputFormatKeyValue}
+	config.Inline = []string{"echo building", "echo ImageID=img-1", "echo Region=eu"}
+	testRun(t, config)
+	assert.Equal(t, map[string]string{"ImageID": "img-1", "Region": "eu"}, config.Outputs())
+	assert.Equal(t, "img-1", config.generatedData["ImageID"])
+
+	state := new(multistep.BasicStateBag)
+	state.Put("generated_data", map[string]interface{}{"ImageID": "old", "Zone": "a"})
+	PublishOutputs(state, config.Outputs())
+	assert.Equal(t, map[string]interface{}{"ImageID": "img-1", "Region": "eu", "Zone": "a"}, state.Get("generated_data"))
+}
+
+func TestValidate_outputFormat(t *testing.T) {
+	config := &Config{OutputFormat: "yaml"}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err == nil {
+		t.Fatal("validating an unknown output format should fail")
+	}
+}
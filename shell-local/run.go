@@ -5,6 +5,7 @@ package shell_local
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"log"
@@ -13,7 +14,9 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
@@ -29,6 +32,7 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 		config.generatedData = make(map[string]interface{})
 	}
 	config.ctx.Data = generatedData
+	config.outputs = make(map[string]string)
 	// Check if shell-local can even execute against this runtime OS
 	if len(config.OnlyOn) > 0 {
 		runCommand := false
@@ -45,6 +49,24 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 		}
 	}
 
+	unlock, err := lockFiles(ctx, ui, config.LockFiles)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	config.workingDir = ""
+	if config.IsolatedWorkingDir {
+		dir, err := tmp.Dir("packer-shell-local")
+		if err != nil {
+			return false, fmt.Errorf("Error creating the working directory: %s", err)
+		}
+		defer os.RemoveAll(dir)
+		log.Printf("[INFO] (shell-local): running in working directory %s", dir)
+		config.workingDir = dir
+		config.generatedData["WorkingDir"] = dir
+	}
+
 	scripts := make([]string, len(config.Scripts))
 	if len(config.Scripts) > 0 {
 		copy(scripts, config.Scripts)
@@ -68,10 +90,34 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 	}
 
 	// Create environment variables to set before executing the command
-	flattenedEnvVars, err := createFlattenedEnvVars(config)
+	envVars, err := createEnvVars(config)
 	if err != nil {
 		return false, err
 	}
+	for _, name := range config.SensitiveEnvVars {
+		if value, ok := envVars[name]; ok {
+			packersdk.LogSecretFilter.Set(value)
+		}
+	}
+
+	var flattenedEnvVars string
+	var env []string
+	switch {
+	case config.UseProcessEnvironment:
+		for _, key := range sortedKeys(envVars) {
+			env = append(env, key+"="+envVars[key])
+		}
+	case config.UseEnvVarFile:
+		envVarFile, err := createEnvVarFile(config, envVars)
+		if err != nil {
+			return false, err
+		}
+		defer os.Remove(envVarFile)
+		config.generatedData["EnvVarFile"] = envVarFile
+		flattenedEnvVars = loadEnvVarFileCommand(config, envVarFile)
+	default:
+		flattenedEnvVars = flattenEnvVars(config, envVars)
+	}
 
 	for _, script := range scripts {
 		// use absolute path in case the script is linked with forward slashes
@@ -92,6 +138,7 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 
 		comm := &Communicator{
 			ExecuteCommand: interpolatedCmds,
+			Dir:            config.workingDir,
 		}
 
 		// The remoteCmd generated here isn't actually run, but it allows us to
@@ -99,7 +146,11 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 		// the other communicators; ultimately, this command is just used for
 		// buffers and for reading the final exit status.
 		flattenedCmd := strings.Join(interpolatedCmds, " ")
-		cmd := &packersdk.RemoteCmd{Command: flattenedCmd}
+		var stdout bytes.Buffer
+		cmd := &packersdk.RemoteCmd{Command: flattenedCmd, Env: env}
+		if config.OutputFormat != "" {
+			cmd.Stdout = &stdout
+		}
 		log.Printf("[INFO] (shell-local): starting local command: %s", flattenedCmd)
 		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
 			return false, fmt.Errorf(
@@ -111,13 +162,24 @@ func Run(ctx context.Context, ui packersdk.Ui, config *Config, generatedData map
 		if err := config.ValidExitCode(cmd.ExitStatus()); err != nil {
 			return false, err
 		}
+
+		if config.OutputFormat != "" {
+			outputs, err := parseOutput(config.OutputFormat, stdout.String())
+			if err != nil {
+				return false, fmt.Errorf("Error capturing the output of script %s: %s", absScript, err)
+			}
+			for k, v := range outputs {
+				config.outputs[k] = v
+				config.generatedData[k] = v
+			}
+		}
 	}
 
 	return true, nil
 }
 
 func createInlineScriptFile(config *Config) (string, error) {
-	tf, err := tmp.File("packer-shell")
+	tf, err := config.tempFile("packer-shell")
 	if err != nil {
 		return "", fmt.Errorf("Error preparing shell script: %s", err)
 	}
@@ -175,7 +237,15 @@ func createInterpolatedCommands(config *Config, script string, flattenedEnvVars
 }
 
 func createFlattenedEnvVars(config *Config) (string, error) {
-	flattened := ""
+	envVars, err := createEnvVars(config)
+	if err != nil {
+		return "", err
+	}
+	return flattenEnvVars(config, envVars), nil
+}
+
+// createEnvVars returns the environment variables of the scripts.
+func createEnvVars(config *Config) (map[string]string, error) {
 	envVars := make(map[string]string)
 
 	// Always available Packer provided env vars
@@ -200,30 +270,159 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 	for _, envVar := range config.Vars {
 		envVar, err := interpolate.Render(envVar, &config.ctx)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		// Split vars into key/value components
 		keyValue := strings.SplitN(envVar, "=", 2)
-		// Store pair, replacing any single quotes in value so they parse
-		// correctly with required environment variable format
-		envVars[keyValue[0]] = strings.Replace(keyValue[1], "'", `'"'"'`, -1)
+		envVars[keyValue[0]] = keyValue[1]
 	}
 
 	for k, v := range config.Env {
-		// Store pair, replacing any single quotes in value so they parse
-		// correctly with required environment variable format
-		envVars[k] = strings.Replace(v, "'", `'"'"'`, -1)
+		envVars[k] = v
+	}
+	return envVars, nil
+}
+
+// flattenEnvVars formats envVars with the environment variable format.
+func flattenEnvVars(config *Config, envVars map[string]string) string {
+	flattened := ""
+	for _, key := range sortedKeys(envVars) {
+		// Replace any single quotes in value so they parse correctly with
+		// required environment variable format
+		flattened += fmt.Sprintf(config.EnvVarFormat, key, config.escapeEnvVar(envVars[key]))
+	}
+	return flattened
+}
+
+// createEnvVarFile writes envVars to a temporary file in the language of the
+// interpreter, and returns its path.
+func createEnvVarFile(config *Config, envVars map[string]string) (string, error) {
+	tf, err := config.tempFile("packer-shell-env")
+	if err != nil {
+		return "", fmt.Errorf("Error preparing environment variable file: %s", err)
+	}
+	defer tf.Close()
+	if err := tf.Chmod(0600); err != nil {
+		log.Printf("[ERROR] (shell-local): error modifying permissions of environment variable file: %s", err.Error())
+	}
+
+	writer := bufio.NewWriter(tf)
+	for _, key := range sortedKeys(envVars) {
+		value := envVars[key]
+		switch {
+		case config.interpreter != nil && config.interpreter.IsCmd():
+			fmt.Fprintf(writer, "@set \"%s=%s\"\r\n", key, value)
+		case config.interpreter != nil && config.interpreter.IsPowerShell():
+			fmt.Fprintf(writer, "$env:%s='%s'\n", key, strings.Replace(value, "'", "''", -1))
+		default:
+			fmt.Fprintf(writer, "export %s='%s'\n", key, strings.Replace(value, "'", `'"'"'`, -1))
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return "", fmt.Errorf("Error preparing environment variable file: %s", err)
+	}
+	if err := tf.Close(); err != nil {
+		return "", fmt.Errorf("Error preparing environment variable file: %s", err)
 	}
 
-	// Create a list of env var keys in sorted order
+	// cmd and PowerShell only run files with the right extension.
+	name := tf.Name()
+	if config.interpreter != nil && config.interpreter.ScriptExtension() != "" {
+		name = fmt.Sprintf("%s.%s", tf.Name(), config.interpreter.ScriptExtension())
+		if err := os.Rename(tf.Name(), name); err != nil {
+			os.Remove(tf.Name())
+			return "", fmt.Errorf("Error preparing environment variable file: %s", err)
+		}
+	}
+	return name, nil
+}
+
+// loadEnvVarFileCommand returns the command loading the environment variable
+// file at path, for {{.Vars}}.
+func loadEnvVarFileCommand(config *Config, path string) string {
+	switch {
+	case config.interpreter != nil && config.interpreter.IsCmd():
+		return fmt.Sprintf("call \"%s\" && ", path)
+	case config.interpreter != nil && config.interpreter.IsPowerShell():
+		return fmt.Sprintf(". '%s'; ", path)
+	default:
+		return fmt.Sprintf(". '%s' &&", path)
+	}
+}
+
+// sortedKeys returns the keys of envVars in sorted order.
+func sortedKeys(envVars map[string]string) []string {
 	var keys []string
 	for k := range envVars {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
+	return keys
+}
 
-	for _, key := range keys {
-		flattened += fmt.Sprintf(config.EnvVarFormat, key, envVars[key])
+// tempFile creates a temporary file in the working directory when it is
+// isolated, and in the system temporary directory otherwise.
+func (config *Config) tempFile(pattern string) (*os.File, error) {
+	if config.workingDir != "" {
+		return os.CreateTemp(config.workingDir, pattern)
+	}
+	return tmp.File(pattern)
+}
+
+// lockRetryDelay is the time to wait between two tries to lock a file.
+var lockRetryDelay = 500 * time.Millisecond
+
+// lockFiles locks the files at paths, waiting for the processes holding them
+// to unlock them, and returns the function unlocking them. The files are
+// locked in sorted order, so that two runs locking the same files don't
+// deadlock.
+func lockFiles(ctx context.Context, ui packersdk.Ui, paths []string) (func(), error) {
+	set := make(map[string]struct{})
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error converting %s to absolute path: %s", path, err)
+		}
+		set[abs] = struct{}{}
+	}
+	var sorted []string
+	for path := range set {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var locks []*filelock.Flock
+	unlock := func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			if err := locks[i].Unlock(); err != nil {
+				log.Printf("[ERROR] (shell-local): error unlocking %s: %s", sorted[i], err)
+			}
+		}
+	}
+	for _, path := range sorted {
+		lock := filelock.New(path)
+		waiting := false
+		for {
+			locked, err := lock.TryLock()
+			if err != nil {
+				unlock()
+				return nil, fmt.Errorf("Error locking %s: %s", path, err)
+			}
+			if locked {
+				break
+			}
+			if !waiting {
+				ui.Say(fmt.Sprintf("Waiting for the lock of %s...", path))
+				waiting = true
+			}
+			select {
+			case <-ctx.Done():
+				unlock()
+				return nil, ctx.Err()
+			case <-time.After(lockRetryDelay):
+			}
+		}
+		locks = append(locks, lock)
 	}
-	return flattened, nil
+	return unlock, nil
 }
@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shell_local
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testRun(t *testing.T, config *Config) string {
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var out bytes.Buffer
+	ui := &packersdk.BasicUi{
+		Reader:      new(bytes.Buffer),
+		Writer:      &out,
+		ErrorWriter: &out,
+	}
+	if _, err := Run(context.Background(), ui, config, map[string]interface{}{}); err != nil {
+		t.Fatalf("err: %s\n%s", err, out.String())
+	}
+	return out.String()
+}
+
+func TestRun_envVars(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+
+	cases := []struct {
+		name   string
+		config func(*Config)
+	}{
+		{"command line", func(*Config) {}},
+		{"file", func(c *Config) { c.UseEnvVarFile = true }},
+		{"environment", func(c *Config) { c.UseProcessEnvironment = true }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{}
+			config.Inline = []string{`echo "FOO is $FOO, BAR is $BAR"`}
+			config.Vars = []string{"FOO=it's foo"}
+			config.Env = map[string]string{"BAR": "bar"}
+			tc.config(config)
+
+			out := testRun(t, config)
+			if !strings.Contains(out, "FOO is it's foo, BAR is bar") {
+				t.Fatalf("unexpected output %q", out)
+			}
+			if config.UseEnvVarFile {
+				path := config.generatedData["EnvVarFile"].(string)
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Fatalf("expected the environment variable file to be removed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_sensitiveEnvVars(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+	config := &Config{SensitiveEnvVars: []string{"TOKEN"}}
+	config.Inline = []string{`echo "the token is $TOKEN"`}
+	config.Env = map[string]string{"TOKEN": "s3cr3t-t0k3n"}
+	config.UseProcessEnvironment = true
+
+	out := testRun(t, config)
+	if strings.Contains(out, "s3cr3t-t0k3n") || !strings.Contains(out, "the token is <sensitive>") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestRun_isolatedWorkingDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+	config := &Config{IsolatedWorkingDir: true, OutputFormat: OutputFormatKeyValue}
+	config.Inline = []string{`touch artifact && echo "DIR=$(pwd)" && echo "{{.WorkingDir}}" >&2`}
+	out := testRun(t, config)
+
+	dir := config.Outputs()["DIR"]
+	cwd, _ := os.Getwd()
+	if dir == "" || dir == cwd {
+		t.Fatalf("expected the script to run in a new directory, got %q", dir)
+	}
+	if !strings.Contains(out, config.generatedData["WorkingDir"].(string)) {
+		t.Fatalf("expected the working directory in the output %q", out)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the working directory to be removed: %v", err)
+	}
+	if _, err := os.Stat("artifact"); !os.IsNotExist(err) {
+		t.Fatalf("expected no artifact in the current directory: %v", err)
+	}
+}
+
+func TestRun_lockFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows not supported for this test")
+	}
+	lockRetryDelay = 10 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	lock := filelock.New(path)
+	if locked, err := lock.TryLock(); !locked || err != nil {
+		t.Fatalf("failed to lock %s: %v", path, err)
+	}
+
+	config := &Config{LockFiles: []string{path}}
+	config.Inline = []string{"echo hi"}
+	if err := Validate(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Run(ctx, packersdk.TestUi(t), config, nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected the run to wait for the lock, got %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testRun(t, config)
+
+	// The lock is released once the run ends.
+	if locked, err := lock.TryLock(); !locked || err != nil {
+		t.Fatalf("expected %s to be unlocked: %v", path, err)
+	}
+	lock.Unlock()
+}
@@ -3,7 +3,8 @@
 
 //go:generate packer-sdc struct-markdown
 
-// Package shutdowncommand is a helper module for builder plugin configuration.
+// Package shutdowncommand is a helper module for builder plugin configuration,
+// and the step shutting the machine down as configured.
 package shutdowncommand
 
 import (
@@ -35,6 +36,9 @@ type ShutdownConfig struct {
 	// in this time it is considered an error. By default, the time out is "5m"
 	// (five minutes).
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" required:"false"`
+	// Forcefully power off the machine when it didn't shut down within
+	// shutdown_timeout, rather than failing the build. Defaults to false.
+	ShutdownForceOnTimeout bool `mapstructure:"shutdown_force_on_timeout" required:"false"`
 }
 
 func (c *ShutdownConfig) Prepare(ctx *interpolate.Context) []error {
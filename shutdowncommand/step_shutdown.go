@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shutdowncommand
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// DefaultPollInterval is the time StepShutdown waits for between two checks
+// of the state of the machine when no poll interval is set.
+const DefaultPollInterval = time.Second
+
+// StepShutdown shuts the machine down as configured by a ShutdownConfig. It
+// runs the shutdown command, waits for the communicator to disconnect, then
+// for the builder to report the machine is powered off. Without a shutdown
+// command, or once the timeout elapsed with ShutdownForceOnTimeout, the
+// machine is forcefully powered off.
+//
+// Uses:
+//
+//	communicator packersdk.Communicator, with a shutdown command
+//	ui packersdk.Ui
+type StepShutdown struct {
+	Config *ShutdownConfig
+	// IsRunning returns whether the machine is still running.
+	IsRunning func(multistep.StateBag) (bool, error)
+	// PowerOff forcefully powers the machine off, like by pulling the plug.
+	// The machine can't be forcefully powered off if it is nil.
+	PowerOff func(multistep.StateBag) error
+	// PollInterval is the time waited for between two checks of the state
+	// of the machine. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// PowerOffTimeout is the time waited for the machine to be powered off
+	// once forcefully powered off. Defaults to one minute.
+	PowerOffTimeout time.Duration
+}
+
+// errShutdownTimeout is the error of a shutdown that didn't complete within
+// the timeout.
+var errShutdownTimeout = errors.New("timeout")
+
+func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	halt := func(err error) multistep.StepAction {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if s.Config.ShutdownCommand == "" {
+		ui.Say("Forcefully shutting down the machine...")
+		if err := s.powerOff(ctx, state); err != nil {
+			return halt(err)
+		}
+		return multistep.ActionContinue
+	}
+
+	timeout := s.Config.ShutdownTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	shutdownCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := s.shutdown(shutdownCtx, state, ui)
+	if err == nil {
+		ui.Say("The machine is powered off.")
+		return multistep.ActionContinue
+	}
+	if ctx.Err() != nil {
+		return halt(ctx.Err())
+	}
+	if !errors.Is(err, errShutdownTimeout) {
+		return halt(err)
+	}
+	if !s.Config.ShutdownForceOnTimeout || s.PowerOff == nil {
+		return halt(fmt.Errorf("Timeout waiting for the machine to shut down after %s", timeout))
+	}
+	ui.Error(fmt.Sprintf("The machine didn't shut down within %s, forcefully powering it off...", timeout))
+	if err := s.powerOff(ctx, state); err != nil {
+		return halt(err)
+	}
+	return multistep.ActionContinue
+}
+
+// shutdown runs the shutdown command and waits for the machine to be powered
+// off, until ctx is done.
+func (s *StepShutdown) shutdown(ctx context.Context, state multistep.StateBag, ui packersdk.Ui) error {
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	ui.Say("Gracefully shutting down the machine...")
+	log.Printf("Executing shutdown command: %s", s.Config.ShutdownCommand)
+	var stdout, stderr bytes.Buffer
+	cmd := &packersdk.RemoteCmd{
+		Command: s.Config.ShutdownCommand,
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return fmt.Errorf("Failed to send the shutdown command: %s", err)
+	}
+
+	ui.Say("Waiting for the communicator to disconnect...")
+	status, err := cmd.WaitContext(ctx)
+	if err != nil {
+		return errShutdownTimeout
+	}
+	log.Printf("Shutdown command exited with status %d, stdout: %s, stderr: %s", status, stdout.String(), stderr.String())
+	if status != 0 && status != packersdk.CmdDisconnect {
+		// Some shutdown commands return before disconnecting, others fail
+		// once the shutdown started: the state of the machine is what tells.
+		ui.Message(fmt.Sprintf("The shutdown command exited with status %d", status))
+	}
+
+	ui.Say("Waiting for the machine to power off...")
+	return s.waitForPowerOff(ctx, state)
+}
+
+// powerOff forcefully powers the machine off and waits for it to be powered
+// off.
+func (s *StepShutdown) powerOff(ctx context.Context, state multistep.StateBag) error {
+	if s.PowerOff == nil {
+		return fmt.Errorf("No shutdown command is set, and the machine can't be forcefully powered off")
+	}
+	if err := s.PowerOff(state); err != nil {
+		return fmt.Errorf("Error powering off the machine: %s", err)
+	}
+	timeout := s.PowerOffTimeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	powerOffCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := s.waitForPowerOff(powerOffCtx, state); err != nil {
+		if errors.Is(err, errShutdownTimeout) {
+			return fmt.Errorf("The machine is still running %s after being powered off", timeout)
+		}
+		return err
+	}
+	return nil
+}
+
+// waitForPowerOff checks IsRunning every PollInterval until the machine is
+// powered off or ctx is done.
+func (s *StepShutdown) waitForPowerOff(ctx context.Context, state multistep.StateBag) error {
+	if s.IsRunning == nil {
+		return nil
+	}
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		running, err := s.IsRunning(state)
+		if err != nil {
+			return fmt.Errorf("Error checking whether the machine is running: %s", err)
+		}
+		if !running {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errShutdownTimeout
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *StepShutdown) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package shutdowncommand
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testState() (multistep.StateBag, *packersdk.MockCommunicator) {
+	comm := &packersdk.MockCommunicator{StartExitStatus: packersdk.CmdDisconnect}
+	state := new(multistep.BasicStateBag)
+	state.Put("communicator", comm)
+	state.Put("ui", &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state, comm
+}
+
+// testMachine is a machine powering off after a number of checks, or once
+// powered off.
+type testMachine struct {
+	checksLeft int
+	poweredOff bool
+}
+
+func (m *testMachine) IsRunning(multistep.StateBag) (bool, error) {
+	if m.poweredOff || m.checksLeft == 0 {
+		return false, nil
+	}
+	m.checksLeft--
+	return true, nil
+}
+
+func (m *testMachine) PowerOff(multistep.StateBag) error {
+	m.poweredOff = true
+	return nil
+}
+
+func TestStepShutdown_command(t *testing.T) {
+	state, comm := testState()
+	m := &testMachine{checksLeft: 2}
+	step := &StepShutdown{
+		Config:       &ShutdownConfig{ShutdownCommand: "shutdown -h now", ShutdownTimeout: time.Second},
+		IsRunning:    m.IsRunning,
+		PowerOff:     m.PowerOff,
+		PollInterval: time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action %v: %v", action, state.Get("error"))
+	}
+	if comm.StartCmd.Command != "shutdown -h now" {
+		t.Fatalf("bad command %q", comm.StartCmd.Command)
+	}
+	if m.poweredOff {
+		t.Fatal("the machine should not be forcefully powered off")
+	}
+}
+
+func TestStepShutdown_timeout(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		state, _ := testState()
+		m := &testMachine{checksLeft: -1}
+		step := &StepShutdown{
+			Config: &ShutdownConfig{
+				ShutdownCommand:        "shutdown -h now",
+				ShutdownTimeout:        20 * time.Millisecond,
+				ShutdownForceOnTimeout: force,
+			},
+			IsRunning:    m.IsRunning,
+			PowerOff:     m.PowerOff,
+			PollInterval: time.Millisecond,
+		}
+		action := step.Run(context.Background(), state)
+		if force && (action != multistep.ActionContinue || !m.poweredOff) {
+			t.Fatalf("the machine should be forcefully powered off: %v", state.Get("error"))
+		}
+		if !force && (action != multistep.ActionHalt || m.poweredOff) {
+			t.Fatal("the step should fail without powering the machine off")
+		}
+	}
+}
+
+func TestStepShutdown_noCommand(t *testing.T) {
+	state, comm := testState()
+	m := &testMachine{checksLeft: -1}
+	step := &StepShutdown{Config: &ShutdownConfig{}, IsRunning: m.IsRunning, PowerOff: m.PowerOff}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action %v: %v", action, state.Get("error"))
+	}
+	if comm.StartCalled || !m.poweredOff {
+		t.Fatal("the machine should be forcefully powered off")
+	}
+
+	state, _ = testState()
+	step = &StepShutdown{Config: &ShutdownConfig{}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatal("the step should fail without a way to power off")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TypeAdapter bridges a Go type of plugin configurations, like net.IP, with
+// the values of templates. It is used when decoding configurations with
+// Decode, and when converting them back to HCL2 values with
+// hcl2helper.HCL2ValueFromConfig, so that plugins can use the type without
+// converting fields by hand.
+//
+// In the HCL2 spec of the configuration, generated with packer-sdc, the
+// field is of the type of the values of the template, like a string.
+type TypeAdapter struct {
+	// Type is the Go type, like reflect.TypeOf(net.IP{}).
+	Type reflect.Type
+	// Decode converts a value of a template, like a string, to a value of
+	// Type. Values it doesn't know how to convert are returned unchanged.
+	Decode func(v interface{}) (interface{}, error)
+	// Encode converts a value of Type, never a pointer to it, to a HCL2
+	// value.
+	Encode func(v interface{}) (cty.Value, error)
+}
+
+var (
+	typeAdaptersLock sync.RWMutex
+	typeAdapters     = map[reflect.Type]TypeAdapter{}
+)
+
+func init() {
+	RegisterTypeAdapter(TypeAdapter{
+		Type:   reflect.TypeOf(time.Duration(0)),
+		Decode: decodeDuration,
+		Encode: encodeDuration,
+	})
+	RegisterTypeAdapter(TypeAdapter{
+		Type:   reflect.TypeOf(net.IP{}),
+		Decode: decodeIP,
+		Encode: encodeIP,
+	})
+	RegisterTypeAdapter(TypeAdapter{
+		Type:   reflect.TypeOf(url.URL{}),
+		Decode: decodeURL,
+		Encode: encodeURL,
+	})
+}
+
+// RegisterTypeAdapter registers a, replacing the adapter of the same type if
+// any. It is meant to be called from the init function of plugins.
+func RegisterTypeAdapter(a TypeAdapter) {
+	if a.Type == nil || a.Decode == nil || a.Encode == nil {
+		panic("config: incomplete type adapter")
+	}
+	typeAdaptersLock.Lock()
+	defer typeAdaptersLock.Unlock()
+	typeAdapters[a.Type] = a
+}
+
+// TypeAdapterFor returns the adapter registered for t, or for the type t
+// points to.
+func TypeAdapterFor(t reflect.Type) (TypeAdapter, bool) {
+	if t == nil {
+		return TypeAdapter{}, false
+	}
+	typeAdaptersLock.RLock()
+	defer typeAdaptersLock.RUnlock()
+	if a, ok := typeAdapters[t]; ok {
+		return a, true
+	}
+	if t.Kind() == reflect.Ptr {
+		a, ok := typeAdapters[t.Elem()]
+		return a, ok
+	}
+	return TypeAdapter{}, false
+}
+
+// EnumAdapter returns the adapter of t, a string type, only accepting values.
+func EnumAdapter(t reflect.Type, values ...string) TypeAdapter {
+	if t.Kind() != reflect.String {
+		panic(fmt.Sprintf("config: %s is not a string type", t))
+	}
+	return TypeAdapter{
+		Type: t,
+		Decode: func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return v, nil
+			}
+			for _, value := range values {
+				if s == value {
+					return reflect.ValueOf(s).Convert(t).Interface(), nil
+				}
+			}
+			return nil, fmt.Errorf("invalid value %q, expected one of: %s",
+				s, strings.Join(values, ", "))
+		},
+		Encode: func(v interface{}) (cty.Value, error) {
+			return cty.StringVal(reflect.ValueOf(v).String()), nil
+		},
+	}
+}
+
+// typeAdapterHook decodes the values of the types with an adapter.
+func typeAdapterHook(f reflect.Type, t reflect.Type, v interface{}) (interface{}, error) {
+	if f == t {
+		return v, nil
+	}
+	a, ok := TypeAdapterFor(t)
+	if !ok || f == a.Type {
+		return v, nil
+	}
+	decoded, err := a.Decode(v)
+	if err != nil {
+		return nil, err
+	}
+	if t.Kind() == reflect.Ptr && reflect.TypeOf(decoded) == a.Type {
+		ptr := reflect.New(a.Type)
+		ptr.Elem().Set(reflect.ValueOf(decoded))
+		return ptr.Interface(), nil
+	}
+	return decoded, nil
+}
+
+func decodeDuration(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// encodeDuration encodes durations as a number of milliseconds.
+func encodeDuration(v interface{}) (cty.Value, error) {
+	return cty.NumberIntVal(v.(time.Duration).Milliseconds()), nil
+}
+
+func decodeIP(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	if s == "" {
+		return net.IP(nil), nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", s)
+	}
+	return ip, nil
+}
+
+func decodeURL(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return *u, nil
+}
+
+func encodeURL(v interface{}) (cty.Value, error) {
+	u := v.(url.URL)
+	return cty.StringVal(u.String()), nil
+}
+
+func encodeIP(v interface{}) (cty.Value, error) {
+	ip := v.(net.IP)
+	if len(ip) == 0 {
+		return cty.StringVal(""), nil
+	}
+	return cty.StringVal(ip.String()), nil
+}
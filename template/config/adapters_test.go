@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+type testArch string
+
+func TestDecode_typeAdapters(t *testing.T) {
+	RegisterTypeAdapter(EnumAdapter(reflect.TypeOf(testArch("")), "amd64", "arm64"))
+
+	type Target struct {
+		IP       net.IP        `mapstructure:"ip"`
+		URL      url.URL       `mapstructure:"url"`
+		Proxy    *url.URL      `mapstructure:"proxy"`
+		Arch     testArch      `mapstructure:"arch"`
+		Duration time.Duration `mapstructure:"duration"`
+	}
+
+	var result Target
+	err := Decode(&result, nil, map[string]interface{}{
+		"ip":       "10.0.0.1",
+		"url":      "https://example.com/iso",
+		"proxy":    "http://proxy:3128",
+		"arch":     "arm64",
+		"duration": "1m",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !result.IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("unexpected IP %s", result.IP)
+	}
+	if result.URL.Host != "example.com" || result.URL.Path != "/iso" {
+		t.Fatalf("unexpected URL %s", result.URL.String())
+	}
+	if result.Proxy == nil || result.Proxy.Host != "proxy:3128" {
+		t.Fatalf("unexpected proxy %v", result.Proxy)
+	}
+	if result.Arch != "arm64" || result.Duration != time.Minute {
+		t.Fatalf("unexpected arch %q or duration %s", result.Arch, result.Duration)
+	}
+
+	cases := map[string]string{
+		"ip":   "invalid IP address",
+		"arch": "expected one of: amd64, arm64",
+	}
+	for key, expected := range cases {
+		var result Target
+		err := Decode(&result, nil, map[string]interface{}{key: "nope"})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: expected an error containing %q, got %v", key, expected, err)
+		}
+	}
+}
+
+func TestTypeAdapterFor(t *testing.T) {
+	a, ok := TypeAdapterFor(reflect.TypeOf(&url.URL{}))
+	if !ok || a.Type != reflect.TypeOf(url.URL{}) {
+		t.Fatalf("expected the URL adapter for pointers to URLs, got %v", a.Type)
+	}
+	v, err := a.Encode(url.URL{Scheme: "https", Host: "example.com"})
+	if err != nil || !v.RawEquals(cty.StringVal("https://example.com")) {
+		t.Fatalf("unexpected value %#v, %v", v, err)
+	}
+
+	if _, ok := TypeAdapterFor(reflect.TypeOf("")); ok {
+		t.Fatal("expected no adapter for strings")
+	}
+}
@@ -5,6 +5,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -40,11 +41,20 @@ type DecodeOpts struct {
 	PluginType string
 
 	DecodeHooks []mapstructure.DecodeHookFunc
+
+	// Layers, if non-nil, are the defaults, files and environment
+	// variables the configuration is decoded on top of.
+	Layers *Layers
+
+	// Provenance, if non-nil, will be set to the origin of the options
+	// post-decode
+	Provenance *Provenance
 }
 
 var DefaultDecodeHookFuncs = []mapstructure.DecodeHookFunc{
 	uint8ToStringHook,
 	stringToTrilean,
+	typeAdapterHook,
 	mapstructure.StringToSliceHookFunc(","),
 	mapstructure.StringToTimeDurationHookFunc(),
 }
@@ -69,7 +79,11 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		if err != nil {
 			switch err := err.(type) {
 			case cty.PathError:
-				return fmt.Errorf("%v: %v", err, err.Path)
+				path := ctyPathString(err.Path)
+				return multierror.Append(nil, &DecodeError{
+					Path: path,
+					Err:  fmt.Errorf("%s: %v", path, err),
+				})
 			}
 			return err
 		}
@@ -105,6 +119,24 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 	// Detect user variables from the raws and merge them into our context
 	ctxData, raws := DetectContextData(raws...)
 
+	// Put the layers of the configuration below the template
+	var layers []layer
+	if config.Layers != nil {
+		var err error
+		layers, err = config.Layers.layerRaws(reflect.TypeOf(target))
+		if err != nil {
+			return err
+		}
+	}
+	templateRaws := raws
+	if len(layers) > 0 {
+		raws = make([]interface{}, 0, len(layers)+len(templateRaws))
+		for _, l := range layers {
+			raws = append(raws, l.raw)
+		}
+		raws = append(raws, templateRaws...)
+	}
+
 	// Interpolate first
 	if config.Interpolate {
 		ctx, err := DetectContext(raws...)
@@ -119,6 +151,9 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 			config.InterpolateContext.CorePackerVersionString = ctx.CorePackerVersionString
 			config.InterpolateContext.TemplatePath = ctx.TemplatePath
 			config.InterpolateContext.UserVariables = ctx.UserVariables
+			if len(ctx.SensitiveVariables) > 0 {
+				config.InterpolateContext.SensitiveVariables = ctx.SensitiveVariables
+			}
 			if config.InterpolateContext.Data == nil {
 				config.InterpolateContext.Data = ctxData
 			}
@@ -129,6 +164,10 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 		for i, raw := range raws {
 			m, err := interpolate.RenderMap(raw, ctx, config.InterpolateFilter)
 			if err != nil {
+				var tplErr *interpolate.TemplateError
+				if errors.As(err, &tplErr) {
+					return multierror.Append(nil, &DecodeError{Path: tplErr.Option, Err: err})
+				}
 				return err
 			}
 
@@ -155,16 +194,16 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 
 	// In practice, raws is two interfaces: one containing all the packer config
 	// vars, and one containing the raw json configuration for a single
-	// plugin.
+	// plugin. All of them are decoded to report all the errors at once.
+	var errs *multierror.Error
 	for _, raw := range raws {
 		if err := decoder.Decode(raw); err != nil {
-			return err
+			errs = multierror.Append(errs, mapstructureErrors(err)...)
 		}
 	}
 
 	// If we have unused keys, it is an error
 	if len(md.Unused) > 0 {
-		var err error
 		sort.Strings(md.Unused)
 		for _, unused := range md.Unused {
 			if unused == "type" || strings.HasPrefix(unused, "packer_") {
@@ -207,21 +246,64 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 					unused)
 			}
 
-			err = multierror.Append(err, unusedErr)
-		}
-		if err != nil {
-			return err
+			errs = multierror.Append(errs, &DecodeError{Path: optionPath(unused), Err: unusedErr})
 		}
 	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	// Enforce the validate tags and Validate methods of the configuration
+	if err := validateConfig(target, notInterpolated(config)); err != nil {
+		return err
+	}
+
+	// Redact the secrets of the configuration from the output
+	setSensitiveValues(SensitiveValues(target))
+	if config.InterpolateContext != nil {
+		setSensitiveValues(config.InterpolateContext.SensitiveValues())
+	}
 
 	// Set the metadata if it is set
 	if config.Metadata != nil {
 		*config.Metadata = md
 	}
 
+	if config.Provenance != nil {
+		p := Provenance{}
+		setProvenance(p, layers, templateRaws)
+		*config.Provenance = p
+	}
+
 	return nil
 }
 
+// notInterpolated returns whether an option is excluded from the
+// interpolation of Decode, and can still hold a template.
+func notInterpolated(config *DecodeOpts) func(path string) bool {
+	f := config.InterpolateFilter
+	if !config.Interpolate || f == nil {
+		return func(string) bool { return false }
+	}
+	return func(path string) bool {
+		key, _, _ := strings.Cut(strings.ToLower(path), ".")
+		if len(f.Include) > 0 {
+			for _, k := range f.Include {
+				if strings.ToLower(k) == key {
+					return false
+				}
+			}
+			return true
+		}
+		for _, k := range f.Exclude {
+			if strings.ToLower(k) == key {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func DetectContextData(raws ...interface{}) (map[interface{}]interface{}, []interface{}) {
 	// In provisioners, the last value pulled from raws is the placeholder data
 	// for build-specific variables. Pull these out to add to interpolation
@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/mapstructure"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DecodeError is an error decoding or validating the configuration option at
+// Path. Decode returns a *multierror.Error of them, so that all the errors of
+// a configuration are reported at once. The unknown options of a raw
+// configuration are only reported when it has no other errors.
+type DecodeError struct {
+	// Path is the path of the option, its keys and indexes joined with dots,
+	// like "source_ami_filter.filters.virtualization-type". It is empty for
+	// errors about the whole configuration.
+	Path string
+	// Err describes the error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors returns the DecodeErrors of err, an error returned by Decode.
+func DecodeErrors(err error) []*DecodeError {
+	var errs []*DecodeError
+	var merr *multierror.Error
+	if errors.As(err, &merr) {
+		for _, err := range merr.Errors {
+			errs = append(errs, DecodeErrors(err)...)
+		}
+		return errs
+	}
+	var derr *DecodeError
+	if errors.As(err, &derr) {
+		return []*DecodeError{derr}
+	}
+	if err != nil {
+		errs = append(errs, &DecodeError{Err: err})
+	}
+	return errs
+}
+
+var (
+	// mapstructurePathRes match the names of the options in the errors of
+	// mapstructure, like "'tags[0].key' expected type 'string', ..." or
+	// "error decoding 'boot_wait': time: invalid duration".
+	mapstructurePathRes = []*regexp.Regexp{
+		regexp.MustCompile(`^error decoding '([^']*)'`),
+		regexp.MustCompile(`^cannot parse '([^']*)'`),
+		regexp.MustCompile(`^'([^']*)'`),
+		regexp.MustCompile(`^([^ :']+): unsupported type`),
+	}
+
+	indexRe = regexp.MustCompile(`\[([^\]]*)\]`)
+)
+
+// mapstructureErrors splits err, an error of mapstructure, in an error per
+// option.
+func mapstructureErrors(err error) []error {
+	var merr *mapstructure.Error
+	if !errors.As(err, &merr) {
+		return []error{newMapstructureError(err.Error())}
+	}
+	errs := make([]error, 0, len(merr.Errors))
+	for _, msg := range merr.Errors {
+		errs = append(errs, newMapstructureError(msg))
+	}
+	return errs
+}
+
+func newMapstructureError(msg string) *DecodeError {
+	for _, re := range mapstructurePathRes {
+		loc := re.FindStringSubmatchIndex(msg)
+		if loc == nil {
+			continue
+		}
+		name := msg[loc[2]:loc[3]]
+		path := optionPath(name)
+		// Write the path of the option the way it is written in the error
+		// of Decode for the other options.
+		msg = msg[:loc[2]] + path + msg[loc[3]:]
+		return &DecodeError{Path: path, Err: errors.New(msg)}
+	}
+	return &DecodeError{Err: errors.New(msg)}
+}
+
+// optionPath converts a name of mapstructure, like
+// "source_ami_filter.filters[virtualization-type]", to a path.
+func optionPath(name string) string {
+	return strings.TrimPrefix(indexRe.ReplaceAllString(name, ".$1"), ".")
+}
+
+// ctyPathString converts a path in a HCL2 value to a path.
+func ctyPathString(path cty.Path) string {
+	steps := make([]string, 0, len(path))
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			steps = append(steps, step.Name)
+		case cty.IndexStep:
+			switch step.Key.Type() {
+			case cty.String:
+				steps = append(steps, step.Key.AsString())
+			case cty.Number:
+				steps = append(steps, step.Key.AsBigFloat().Text('f', -1))
+			default:
+				steps = append(steps, fmt.Sprintf("%#v", step.Key))
+			}
+		}
+	}
+	return strings.Join(steps, ".")
+}
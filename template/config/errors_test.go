@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecode_errors(t *testing.T) {
+	type Filter struct {
+		Filters map[string]int `mapstructure:"filters"`
+		Owners  []string       `mapstructure:"owners"`
+	}
+	type Tag struct {
+		Key string `mapstructure:"key"`
+	}
+	type Target struct {
+		Name     string        `mapstructure:"name"`
+		Filter   Filter        `mapstructure:"source_ami_filter"`
+		Tags     []Tag         `mapstructure:"tags"`
+		BootWait time.Duration `mapstructure:"boot_wait"`
+	}
+
+	var result Target
+	err := Decode(&result, &DecodeOpts{}, map[string]interface{}{
+		"name": []string{"a", "b"},
+		"source_ami_filter": map[string]interface{}{
+			"filters": map[string]interface{}{
+				"virtualization-type": "hvm",
+			},
+		},
+		"tags": []interface{}{
+			map[string]interface{}{"key": map[string]interface{}{}},
+		},
+	}, map[string]interface{}{
+		"boot_wait": "soon",
+	}, map[string]interface{}{
+		"unknown": true,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var paths []string
+	for _, err := range DecodeErrors(err) {
+		paths = append(paths, err.Path)
+		if !strings.Contains(err.Error(), err.Path) {
+			t.Errorf("expected %q to contain the path %q", err.Error(), err.Path)
+		}
+	}
+	sort.Strings(paths)
+	expected := []string{
+		"boot_wait",
+		"name",
+		"source_ami_filter.filters.virtualization-type",
+		"tags.0.key",
+		"unknown",
+	}
+	if diff := cmp.Diff(expected, paths); diff != "" {
+		t.Fatalf("unexpected paths: %s\n\n%s", diff, err)
+	}
+}
+
+func TestDecode_templateError(t *testing.T) {
+	type Target struct {
+		AMIName string `mapstructure:"ami_name"`
+	}
+
+	var result Target
+	err := Decode(&result, &DecodeOpts{Interpolate: true}, map[string]interface{}{
+		"ami_name": "packer {{timestam}}",
+	})
+	errs := DecodeErrors(err)
+	if len(errs) != 1 || errs[0].Path != "ami_name" {
+		t.Fatalf("expected an error about ami_name, got %#v", errs)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Layers are the sources of options a configuration is decoded on top of.
+// From the lowest precedence to the highest, an option is set by:
+//
+//  1. the default tag of its field, like `default:"22"`, if Defaults is true,
+//  2. the Files, in order,
+//  3. the environment variable named after the option, prefixed with
+//     EnvPrefix, like PKR_SSH_PORT for the ssh_port option with the "PKR_"
+//     prefix,
+//  4. the template, that is the raws given to Decode.
+//
+// The defaults, files and environment variables are interpolated like the
+// template. Only the options of the top level of the configuration,
+// including the ones of its squashed structs, are read from the environment;
+// options that are maps or blocks are not.
+type Layers struct {
+	// Defaults, if true, sets the options to the value of the default tag of
+	// their field.
+	Defaults bool
+	// Files are JSON files holding an object of options, like a
+	// configuration shared by the templates of a project.
+	Files []string
+	// EnvPrefix, if not empty, reads the options from the environment
+	// variables with this prefix.
+	EnvPrefix string
+}
+
+// Source is a source of the value of an option.
+type Source string
+
+const (
+	SourceDefault  Source = "default"
+	SourceFile     Source = "file"
+	SourceEnv      Source = "env"
+	SourceTemplate Source = "template"
+)
+
+// Origin is where the value of an option comes from.
+type Origin struct {
+	Source Source
+	// Name is the path of the file, or the name of the environment variable,
+	// the value was read from.
+	Name string
+}
+
+func (o Origin) String() string {
+	if o.Name == "" {
+		return string(o.Source)
+	}
+	return fmt.Sprintf("%s %s", o.Source, o.Name)
+}
+
+// Provenance maps the top level options of a decoded configuration to the
+// origin of their value. Options that are not set by any layer are absent.
+type Provenance map[string]Origin
+
+// layer is a raw configuration and its origins.
+type layer struct {
+	raw     map[string]interface{}
+	origins map[string]Origin
+}
+
+// layerRaws returns the raws of the layers of a configuration of type t,
+// sorted by precedence.
+func (l *Layers) layerRaws(t reflect.Type) ([]layer, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot layer a configuration of type %s", t)
+	}
+
+	var layers []layer
+	if l.Defaults {
+		raw := defaultsRaw(t)
+		layers = append(layers, newLayer(raw, Origin{Source: SourceDefault}))
+	}
+	for _, path := range l.Files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s: %s", path, err)
+		}
+		layers = append(layers, newLayer(raw, Origin{Source: SourceFile, Name: path}))
+	}
+	if l.EnvPrefix != "" {
+		raw := map[string]interface{}{}
+		origins := map[string]Origin{}
+		for _, name := range envOptions(t) {
+			env := l.EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+			if v, ok := os.LookupEnv(env); ok {
+				raw[name] = v
+				origins[name] = Origin{Source: SourceEnv, Name: env}
+			}
+		}
+		layers = append(layers, layer{raw: raw, origins: origins})
+	}
+	return layers, nil
+}
+
+func newLayer(raw map[string]interface{}, origin Origin) layer {
+	origins := make(map[string]Origin, len(raw))
+	for k, v := range raw {
+		if v != nil {
+			origins[k] = origin
+		}
+	}
+	return layer{raw: raw, origins: origins}
+}
+
+// defaultsRaw returns the default tags of the fields of t, a struct type, as
+// a raw configuration.
+func defaultsRaw(t reflect.Type) map[string]interface{} {
+	raw := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := optionName(sf)
+		if !ok {
+			continue
+		}
+		if squash {
+			for k, v := range defaultsRaw(sf.Type) {
+				raw[k] = v
+			}
+			continue
+		}
+		if v, ok := sf.Tag.Lookup("default"); ok {
+			raw[name] = v
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			if _, ok := TypeAdapterFor(sf.Type); ok {
+				continue
+			}
+			if nested := defaultsRaw(sf.Type); len(nested) > 0 {
+				raw[name] = nested
+			}
+		}
+	}
+	return raw
+}
+
+// envOptions returns the names of the options of t, a struct type, that can
+// be read from environment variables.
+func envOptions(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := optionName(sf)
+		if !ok {
+			continue
+		}
+		if squash {
+			names = append(names, envOptions(sf.Type)...)
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if _, ok := TypeAdapterFor(ft); ok {
+			names = append(names, name)
+			continue
+		}
+		switch ft.Kind() {
+		case reflect.Map, reflect.Struct, reflect.Interface, reflect.Func, reflect.Chan:
+			continue
+		case reflect.Slice, reflect.Array:
+			if nestedStructType(ft) != nil {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// optionName returns the name of the option of a field, and whether the field
+// is squashed. ok is false for the fields that are not options.
+func optionName(sf reflect.StructField) (name string, squash bool, ok bool) {
+	if sf.PkgPath != "" {
+		return "", false, false
+	}
+	tag := strings.Split(sf.Tag.Get("mapstructure"), ",")
+	if tag[0] == "-" {
+		return "", false, false
+	}
+	for _, opt := range tag[1:] {
+		if opt == "squash" && sf.Type.Kind() == reflect.Struct {
+			return "", true, true
+		}
+	}
+	if tag[0] == "" {
+		return sf.Name, false, true
+	}
+	return tag[0], false, true
+}
+
+// setProvenance records the origins of the options of raws, sorted by
+// precedence, in p.
+func setProvenance(p Provenance, layers []layer, raws []interface{}) {
+	for _, l := range layers {
+		for k, o := range l.origins {
+			p[k] = o
+		}
+	}
+	for _, raw := range raws {
+		v := reflect.ValueOf(raw)
+		if v.Kind() != reflect.Map {
+			continue
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			k, ok := iter.Key().Interface().(string)
+			if !ok || k == "type" || strings.HasPrefix(k, "packer_") {
+				continue
+			}
+			if elem := iter.Value(); elem.Kind() == reflect.Interface && elem.IsNil() {
+				continue
+			}
+			p[k] = Origin{Source: SourceTemplate}
+		}
+	}
+}
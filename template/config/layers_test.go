@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecode_layers(t *testing.T) {
+	type Communicator struct {
+		Port    int           `mapstructure:"port" default:"22"`
+		Timeout time.Duration `mapstructure:"timeout" default:"5m"`
+	}
+	type Target struct {
+		Communicator `mapstructure:",squash"`
+		Region       string            `mapstructure:"region" default:"us-east-1"`
+		Size         int               `mapstructure:"size" default:"10"`
+		Zone         string            `mapstructure:"zone"`
+		Name         string            `mapstructure:"name"`
+		Tags         map[string]string `mapstructure:"tags"`
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "defaults.json")
+	if err := os.WriteFile(file, []byte(`{"size": 20, "zone": "a", "name": "file"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PKR_TEST_ZONE", "b")
+	t.Setenv("PKR_TEST_NAME", "env")
+	t.Setenv("PKR_TEST_TAGS", "ignored")
+
+	var result Target
+	var provenance Provenance
+	err := Decode(&result, &DecodeOpts{
+		Layers: &Layers{
+			Defaults:  true,
+			Files:     []string{file},
+			EnvPrefix: "PKR_TEST_",
+		},
+		Provenance: &provenance,
+	}, map[string]interface{}{
+		"name": "template",
+		"port": 2222,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := Target{
+		Communicator: Communicator{Port: 2222, Timeout: 5 * time.Minute},
+		Region:       "us-east-1",
+		Size:         20,
+		Zone:         "b",
+		Name:         "template",
+	}
+	if diff := cmp.Diff(expected, result); diff != "" {
+		t.Fatal(diff)
+	}
+
+	expectedProvenance := Provenance{
+		"port":    {Source: SourceTemplate},
+		"timeout": {Source: SourceDefault},
+		"region":  {Source: SourceDefault},
+		"size":    {Source: SourceFile, Name: file},
+		"zone":    {Source: SourceEnv, Name: "PKR_TEST_ZONE"},
+		"name":    {Source: SourceTemplate},
+	}
+	if diff := cmp.Diff(expectedProvenance, provenance); diff != "" {
+		t.Fatal(diff)
+	}
+	if s := provenance["zone"].String(); s != "env PKR_TEST_ZONE" {
+		t.Fatalf("bad: %s", s)
+	}
+}
+
+func TestDecode_layersInvalidFile(t *testing.T) {
+	type Target struct {
+		Name string `mapstructure:"name"`
+	}
+
+	file := filepath.Join(t.TempDir(), "defaults.json")
+	if err := os.WriteFile(file, []byte(`{"name": "a", "unknown": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var result Target
+	err := Decode(&result, &DecodeOpts{
+		Layers: &Layers{Files: []string{file}},
+	}, map[string]interface{}{})
+	errs := DecodeErrors(err)
+	if len(errs) != 1 || errs[0].Path != "unknown" {
+		t.Fatalf("bad: %v", err)
+	}
+}
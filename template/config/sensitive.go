@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The packer tag marks the options holding secrets, like passwords or
+// tokens, with "sensitive":
+//
+//	type Config struct {
+//		Password string `mapstructure:"password" packer:"sensitive"`
+//	}
+//
+// Once a configuration is decoded, Decode passes the values of its sensitive
+// options, and of the sensitive user variables of its interpolation context,
+// to the functions registered with OnSensitiveValues. The packer package
+// registers its LogSecretFilter, so that the values are replaced by
+// "<sensitive>" in the output of the Ui, the logs and the RPC errors of the
+// plugin.
+//
+// The tag applies to options of type string, and to the slices and maps of
+// strings, whose every element is sensitive. The sensitive options of blocks
+// are tagged in the struct of the block.
+const sensitiveTag = "packer"
+
+var (
+	sensitiveHandlersLock sync.RWMutex
+	sensitiveHandlers     []func(values ...string)
+)
+
+// OnSensitiveValues registers fn to be called with the values of the sensitive
+// options of the configurations decoded by Decode.
+func OnSensitiveValues(fn func(values ...string)) {
+	sensitiveHandlersLock.Lock()
+	defer sensitiveHandlersLock.Unlock()
+	sensitiveHandlers = append(sensitiveHandlers, fn)
+}
+
+// setSensitiveValues passes values to the functions registered with
+// OnSensitiveValues.
+func setSensitiveValues(values []string) {
+	if len(values) == 0 {
+		return
+	}
+	sensitiveHandlersLock.RLock()
+	defer sensitiveHandlersLock.RUnlock()
+	for _, fn := range sensitiveHandlers {
+		fn(values...)
+	}
+}
+
+// SensitiveValues returns the non-empty values of the options of target
+// tagged `packer:"sensitive"`.
+func SensitiveValues(target interface{}) []string {
+	var values []string
+	sensitiveValues(reflect.ValueOf(target), false, &values)
+	return values
+}
+
+func sensitiveValues(v reflect.Value, sensitive bool, values *[]string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if sensitive && v.String() != "" {
+			*values = append(*values, v.String())
+		}
+	case reflect.Struct:
+		if _, ok := TypeAdapterFor(v.Type()); ok {
+			return
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			sensitiveValues(v.Field(i), isSensitive(sf), values)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			sensitiveValues(v.Index(i), sensitive, values)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			sensitiveValues(iter.Value(), sensitive, values)
+		}
+	}
+}
+
+func isSensitive(sf reflect.StructField) bool {
+	for _, opt := range strings.Split(sf.Tag.Get(sensitiveTag), ",") {
+		if opt == "sensitive" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+func TestDecode_sensitive(t *testing.T) {
+	type Credentials struct {
+		User  string `mapstructure:"user"`
+		Token string `mapstructure:"token" packer:"sensitive"`
+	}
+	type Target struct {
+		Name        string            `mapstructure:"name"`
+		Password    string            `mapstructure:"password" packer:"sensitive"`
+		Keys        []string          `mapstructure:"keys" packer:"sensitive"`
+		Headers     map[string]string `mapstructure:"headers" packer:"sensitive"`
+		Empty       string            `mapstructure:"empty" packer:"sensitive"`
+		Credentials []Credentials     `mapstructure:"credentials"`
+	}
+
+	var values []string
+	OnSensitiveValues(func(v ...string) {
+		values = append(values, v...)
+	})
+	defer func() {
+		sensitiveHandlersLock.Lock()
+		sensitiveHandlers = sensitiveHandlers[:len(sensitiveHandlers)-1]
+		sensitiveHandlersLock.Unlock()
+	}()
+
+	var result Target
+	err := Decode(&result, &DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &interpolate.Context{},
+	}, map[string]interface{}{
+		"name":     "{{ user `secret` }}-vm",
+		"password": "hunter2",
+		"keys":     []string{"k1", "k2"},
+		"headers":  map[string]string{"Authorization": "Bearer abc"},
+		"credentials": []map[string]interface{}{
+			{"user": "root", "token": "t0k3n"},
+		},
+		"packer_user_variables": map[string]string{
+			"secret": "s3cr3t",
+		},
+		"packer_sensitive_variables": []string{"secret"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sort.Strings(values)
+	expected := []string{"Bearer abc", "hunter2", "k1", "k2", "s3cr3t", "t0k3n"}
+	if diff := cmp.Diff(expected, values); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,458 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Validator is implemented by configurations, and the structs they are made
+// of, that validate themselves. Decode calls Validate once the configuration
+// is decoded and its validate tags enforced, so that the errors are reported
+// as DecodeErrors with the others.
+//
+// The paths of the DecodeErrors returned by Validate are relative to the
+// struct; errors of other types are reported for the whole struct.
+type Validator interface {
+	Validate() error
+}
+
+// The validate tag holds comma separated rules that Decode enforces on the
+// options of a configuration:
+//
+//	type Config struct {
+//		ISOURL      string   `mapstructure:"iso_url" validate:"required_one_of=iso,exclusive=iso"`
+//		ISOURLs     []string `mapstructure:"iso_urls" validate:"required_one_of=iso,exclusive=iso"`
+//		Port        int      `mapstructure:"port" validate:"range=1:65535"`
+//		MachineName string   `mapstructure:"machine_name" validate:"regexp=^[a-z][a-z0-9-]*$"`
+//	}
+//
+// The rules are:
+//
+//   - required: the option must be set.
+//   - required_one_of=group: at least one of the options of the group must be
+//     set.
+//   - exclusive=group: at most one of the options of the group can be set.
+//   - range=min:max: the number, or duration, must be between min and max,
+//     both included. Either of them can be omitted, like in "range=1:".
+//   - regexp=expression: the string must match the regular expression. As the
+//     expression can contain commas, it must be the last rule of the tag.
+//
+// An option is set when it is not the zero value of its type. The range and
+// regexp rules are not checked on options that are not set. The groups are
+// those of the struct the options are in, including its squashed structs.
+const validateTag = "validate"
+
+const (
+	ruleRequired      = "required"
+	ruleRequiredOneOf = "required_one_of"
+	ruleExclusive     = "exclusive"
+	ruleRange         = "range"
+	ruleRegexp        = "regexp"
+)
+
+// ValidationRule is a rule of the validate tag of an option.
+type ValidationRule struct {
+	// Option is the path of the option, like "ssh_port" or "tag.key" for the
+	// options of blocks.
+	Option string
+	// Name is the name of the rule, like "range".
+	Name string
+	// Arg is the argument of the rule, like "1:65535", if any.
+	Arg string
+}
+
+// ValidationRules returns the rules of the validate tags of target, a
+// configuration struct or a pointer to one. They describe the constraints of
+// the options of the HCL2 spec of the configuration, for the tools reading it,
+// like documentation generators.
+func ValidationRules(target interface{}) ([]ValidationRule, error) {
+	t := reflect.TypeOf(target)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a struct", target)
+	}
+	var rules []ValidationRule
+	err := typeValidationRules(t, "", &rules, map[reflect.Type]bool{})
+	return rules, err
+}
+
+func typeValidationRules(t reflect.Type, path string, rules *[]ValidationRule, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	fields, err := structFieldRules(t)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		name := joinPath(path, f.name)
+		if f.squash {
+			if err := typeValidationRules(f.typ, path, rules, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, r := range f.rules {
+			*rules = append(*rules, ValidationRule{Option: name, Name: r.name, Arg: r.arg})
+		}
+		if nested := nestedStructType(f.typ); nested != nil {
+			if err := typeValidationRules(nested, name, rules, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nestedStructType returns the type of the struct of the blocks of an option
+// of type t, if any.
+func nestedStructType(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		case reflect.Struct:
+			if _, ok := TypeAdapterFor(t); ok {
+				return nil
+			}
+			return t
+		}
+		return nil
+	}
+}
+
+type validationRule struct {
+	name string
+	arg  string
+
+	min, max *float64
+	re       *regexp.Regexp
+}
+
+type fieldRules struct {
+	index  int
+	name   string
+	typ    reflect.Type
+	squash bool
+	rules  []validationRule
+}
+
+var fieldRulesCache sync.Map // map[reflect.Type][]fieldRules
+
+// structFieldRules returns the validation rules of the fields of t, a struct
+// type.
+func structFieldRules(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := fieldRulesCache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := optionName(sf)
+		if !ok {
+			continue
+		}
+		f := fieldRules{index: i, name: name, typ: sf.Type, squash: squash}
+		rules, err := parseValidateTag(sf.Type, sf.Tag.Get(validateTag))
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag of %s.%s: %s", t, sf.Name, err)
+		}
+		f.rules = rules
+		fields = append(fields, f)
+	}
+	fieldRulesCache.Store(t, fields)
+	return fields, nil
+}
+
+func parseValidateTag(t reflect.Type, tag string) ([]validationRule, error) {
+	var rules []validationRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, ruleRegexp+"=") {
+			part, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		r := validationRule{name: name, arg: arg}
+		switch name {
+		case "":
+			continue
+		case ruleRequired:
+		case ruleRequiredOneOf, ruleExclusive:
+			if arg == "" {
+				return nil, fmt.Errorf("%s needs a group", name)
+			}
+		case ruleRange:
+			lo, hi, ok := strings.Cut(arg, ":")
+			if !ok {
+				return nil, fmt.Errorf("range %q is not of the form min:max", arg)
+			}
+			var err error
+			if r.min, err = parseBound(t, lo); err != nil {
+				return nil, err
+			}
+			if r.max, err = parseBound(t, hi); err != nil {
+				return nil, err
+			}
+		case ruleRegexp:
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, err
+			}
+			r.re = re
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parseBound parses a bound of a range rule on an option of type t.
+func parseBound(t reflect.Type, s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		f := float64(d)
+		return &f, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bound %q", s)
+	}
+	return &f, nil
+}
+
+// validateConfig enforces the validate tags of target and calls the Validate
+// methods of its structs. The options for which skip returns true are only
+// checked for being set, like the ones that are not interpolated.
+func validateConfig(target interface{}, skip func(path string) bool) error {
+	var errs *multierror.Error
+	validateValue(reflect.ValueOf(target), "", skip, &errs)
+	return errs.ErrorOrNil()
+}
+
+func validateValue(v reflect.Value, path string, skip func(string) bool, errs **multierror.Error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if _, ok := TypeAdapterFor(v.Type()); ok {
+			return
+		}
+		validateStruct(v, path, skip, errs)
+	case reflect.Slice, reflect.Array:
+		if nestedStructType(v.Type()) == nil {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), joinPath(path, strconv.Itoa(i)), skip, errs)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || nestedStructType(v.Type()) == nil {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), joinPath(path, iter.Key().String()), skip, errs)
+		}
+	}
+}
+
+// validationGroup is a required_one_of or exclusive group of options.
+type validationGroup struct {
+	rule    string
+	name    string
+	options []string
+	set     []string
+}
+
+func validateStruct(v reflect.Value, path string, skip func(string) bool, errs **multierror.Error) {
+	var groups []*validationGroup
+	validateFields(v, path, skip, &groups, errs)
+
+	for _, g := range groups {
+		switch {
+		case g.rule == ruleRequiredOneOf && len(g.set) == 0:
+			*errs = multierror.Append(*errs, &DecodeError{
+				Path: path,
+				Err:  fmt.Errorf("one of %s must be set", strings.Join(g.options, ", ")),
+			})
+		case g.rule == ruleExclusive && len(g.set) > 1:
+			*errs = multierror.Append(*errs, &DecodeError{
+				Path: path,
+				Err:  fmt.Errorf("only one of %s can be set", strings.Join(g.options, ", ")),
+			})
+		}
+	}
+
+	validator, ok := v.Interface().(Validator)
+	if !ok && v.CanAddr() {
+		validator, ok = v.Addr().Interface().(Validator)
+	}
+	if !ok {
+		return
+	}
+	for _, err := range DecodeErrors(validator.Validate()) {
+		*errs = multierror.Append(*errs, &DecodeError{
+			Path: joinPath(path, err.Path),
+			Err:  err.Err,
+		})
+	}
+}
+
+// validateFields enforces the rules of the fields of v, a struct, and of its
+// squashed structs, adding the options to their groups.
+func validateFields(v reflect.Value, path string, skip func(string) bool, groups *[]*validationGroup, errs **multierror.Error) {
+	fields, err := structFieldRules(v.Type())
+	if err != nil {
+		*errs = multierror.Append(*errs, &DecodeError{Path: path, Err: err})
+		return
+	}
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.squash {
+			validateFields(fv, path, skip, groups, errs)
+			continue
+		}
+		name := joinPath(path, f.name)
+		set := !fv.IsZero()
+		for _, r := range f.rules {
+			switch r.name {
+			case ruleRequired:
+				if !set {
+					*errs = multierror.Append(*errs, &DecodeError{
+						Path: name,
+						Err:  fmt.Errorf("%s must be set", name),
+					})
+				}
+			case ruleRequiredOneOf, ruleExclusive:
+				g := addToGroup(groups, r.name, r.arg, name)
+				if set {
+					g.set = append(g.set, name)
+				}
+			case ruleRange:
+				if set && !skip(name) {
+					if err := checkRange(fv, name, r); err != nil {
+						*errs = multierror.Append(*errs, &DecodeError{Path: name, Err: err})
+					}
+				}
+			case ruleRegexp:
+				if set && !skip(name) {
+					if err := checkRegexp(fv, name, r); err != nil {
+						*errs = multierror.Append(*errs, &DecodeError{Path: name, Err: err})
+					}
+				}
+			}
+		}
+		validateValue(fv, name, skip, errs)
+	}
+}
+
+func addToGroup(groups *[]*validationGroup, rule, name, option string) *validationGroup {
+	for _, g := range *groups {
+		if g.rule == rule && g.name == name {
+			g.options = append(g.options, option)
+			return g
+		}
+	}
+	g := &validationGroup{rule: rule, name: name, options: []string{option}}
+	*groups = append(*groups, g)
+	return g
+}
+
+func checkRange(v reflect.Value, name string, r validationRule) error {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	var n float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return fmt.Errorf("%s: range cannot be checked on a %s", name, v.Type())
+	}
+	if (r.min != nil && n < *r.min) || (r.max != nil && n > *r.max) {
+		lo, hi, _ := strings.Cut(r.arg, ":")
+		switch {
+		case r.min == nil:
+			return fmt.Errorf("%s must be at most %s", name, hi)
+		case r.max == nil:
+			return fmt.Errorf("%s must be at least %s", name, lo)
+		}
+		return fmt.Errorf("%s must be between %s and %s", name, lo, hi)
+	}
+	return nil
+}
+
+func checkRegexp(v reflect.Value, name string, r validationRule) error {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if !r.re.MatchString(v.String()) {
+			return fmt.Errorf("%s must match the regular expression %q", name, r.arg)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			if s := v.Index(i).String(); !r.re.MatchString(s) {
+				return fmt.Errorf("%s: %q must match the regular expression %q", name, s, r.arg)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: regexp cannot be checked on a %s", name, v.Type())
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	if name == "" {
+		return path
+	}
+	return path + "." + name
+}
@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type validatedDisk struct {
+	Size int    `mapstructure:"size" validate:"required,range=1:"`
+	Type string `mapstructure:"type" validate:"regexp=^(ssd|hdd)$"`
+}
+
+type validatedSource struct {
+	ISOURL  string   `mapstructure:"iso_url" validate:"required_one_of=iso,exclusive=iso"`
+	ISOURLs []string `mapstructure:"iso_urls" validate:"required_one_of=iso,exclusive=iso"`
+}
+
+type validatedConfig struct {
+	Source  validatedSource `mapstructure:",squash"`
+	Port    int             `mapstructure:"port" validate:"range=1:65535"`
+	Timeout time.Duration   `mapstructure:"timeout" validate:"range=1s:1h"`
+	Name    string          `mapstructure:"name" validate:"regexp=^[a-z]{1,3}$"`
+	Disks   []validatedDisk `mapstructure:"disk"`
+	Command string          `mapstructure:"command" validate:"regexp=^run"`
+}
+
+func (c *validatedConfig) Validate() error {
+	if c.Port == 22 && c.Name == "" {
+		return &DecodeError{Path: "name", Err: errors.New("name must be set for port 22")}
+	}
+	return nil
+}
+
+func TestDecode_validate(t *testing.T) {
+	cases := map[string]struct {
+		Raw      map[string]interface{}
+		Expected []string
+	}{
+		"valid": {
+			Raw: map[string]interface{}{
+				"iso_url": "http://example.com/a.iso",
+				"port":    8080,
+				"timeout": "5m",
+				"name":    "abc",
+				"disk": []map[string]interface{}{
+					{"size": 10, "type": "ssd"},
+				},
+			},
+		},
+		"required one of": {
+			Raw:      map[string]interface{}{},
+			Expected: []string{""},
+		},
+		"exclusive": {
+			Raw: map[string]interface{}{
+				"iso_url":  "http://example.com/a.iso",
+				"iso_urls": []string{"http://example.com/b.iso"},
+			},
+			Expected: []string{""},
+		},
+		"invalid options": {
+			Raw: map[string]interface{}{
+				"iso_url": "http://example.com/a.iso",
+				"port":    70000,
+				"timeout": "2h",
+				"name":    "abcd",
+				"disk": []map[string]interface{}{
+					{"size": 10, "type": "ssd"},
+					{"type": "nvme"},
+				},
+			},
+			Expected: []string{"disk.1.size", "disk.1.type", "name", "port", "timeout"},
+		},
+		"validator": {
+			Raw: map[string]interface{}{
+				"iso_url": "http://example.com/a.iso",
+				"port":    22,
+			},
+			Expected: []string{"name"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var c validatedConfig
+			err := Decode(&c, nil, tc.Raw)
+			var paths []string
+			for _, err := range DecodeErrors(err) {
+				paths = append(paths, err.Path)
+			}
+			sort.Strings(paths)
+			if diff := cmp.Diff(tc.Expected, paths); diff != "" {
+				t.Fatalf("unexpected errors %v: %s", err, diff)
+			}
+		})
+	}
+}
+
+func TestDecode_validateMessages(t *testing.T) {
+	var c validatedConfig
+	err := Decode(&c, nil, map[string]interface{}{
+		"iso_url": "http://example.com/a.iso",
+		"port":    0,
+		"timeout": "10ms",
+		"disk": []map[string]interface{}{
+			{"type": "ssd"},
+		},
+	})
+	var messages []string
+	for _, err := range DecodeErrors(err) {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+	expected := []string{
+		"disk.0.size must be set",
+		"timeout must be between 1s and 1h",
+	}
+	if diff := cmp.Diff(expected, messages); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestDecode_validateNotInterpolated(t *testing.T) {
+	var c validatedConfig
+	err := Decode(&c, &DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &interpolate.Context{},
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"command"},
+		},
+	}, map[string]interface{}{
+		"iso_url": "http://example.com/a.iso",
+		"command": "{{ .Vars }} run",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestDecode_validateInvalidTag(t *testing.T) {
+	type Target struct {
+		Port int `mapstructure:"port" validate:"range=1"`
+	}
+	var c Target
+	err := Decode(&c, nil, map[string]interface{}{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestValidationRules(t *testing.T) {
+	rules, err := ValidationRules(&validatedConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ValidationRule{
+		{Option: "iso_url", Name: "required_one_of", Arg: "iso"},
+		{Option: "iso_url", Name: "exclusive", Arg: "iso"},
+		{Option: "iso_urls", Name: "required_one_of", Arg: "iso"},
+		{Option: "iso_urls", Name: "exclusive", Arg: "iso"},
+		{Option: "port", Name: "range", Arg: "1:65535"},
+		{Option: "timeout", Name: "range", Arg: "1s:1h"},
+		{Option: "name", Name: "regexp", Arg: "^[a-z]{1,3}$"},
+		{Option: "disk.size", Name: "required"},
+		{Option: "disk.size", Name: "range", Arg: "1:"},
+		{Option: "disk.type", Name: "regexp", Arg: "^(ssd|hdd)$"},
+		{Option: "command", Name: "regexp", Arg: "^run"},
+	}
+	if diff := cmp.Diff(expected, rules); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
+)
+
+// TemplateError is the error of the interpolation of a template. It locates
+// the failure in the template, like "in `ami_name` at column 17: unknown
+// function `timestam`".
+type TemplateError struct {
+	// Option is the name of the configuration option set to the template,
+	// when known.
+	Option string
+	// Template is the template.
+	Template string
+	// Line and Column locate the failure in Template, counted from 1. They
+	// are 0 when unknown.
+	Line   int
+	Column int
+	// Message describes the failure.
+	Message string
+	// Err is the error of text/template.
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	var b strings.Builder
+	if e.Option != "" {
+		fmt.Fprintf(&b, "in `%s` ", e.Option)
+	}
+	switch {
+	case e.Line > 1 && e.Column > 0:
+		fmt.Fprintf(&b, "at line %d, column %d", e.Line, e.Column)
+	case e.Line > 1:
+		fmt.Fprintf(&b, "at line %d", e.Line)
+	case e.Column > 0:
+		fmt.Fprintf(&b, "at column %d", e.Column)
+	}
+	if b.Len() == 0 {
+		return e.Message
+	}
+	return strings.TrimSpace(b.String()) + ": " + e.Message
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Excerpt returns the line of the template the failure is at, followed by a
+// line pointing at its column, when known.
+func (e *TemplateError) Excerpt() string {
+	lines := strings.Split(e.Template, "\n")
+	line := e.Line
+	if line == 0 {
+		line = 1
+	}
+	if line > len(lines) {
+		return ""
+	}
+	excerpt := lines[line-1]
+	if e.Column > 0 && e.Column <= len(excerpt)+1 {
+		excerpt += "\n" + strings.Repeat(" ", e.Column-1) + "^"
+	}
+	return excerpt
+}
+
+var (
+	// templateErrorRe matches the errors of text/template, like
+	// `template: root:1: function "timestam" not defined` or
+	// `template: root:1:17: executing "root" at <user "x">: error calling user: ...`.
+	templateErrorRe  = regexp.MustCompile(`(?s)^template: [^:]*:(\d+)(?::(\d+))?: (?:executing "[^"]*" at <[^>]*>: )?(.*)$`)
+	undefinedFuncRe  = regexp.MustCompile(`^function "([^"]+)" not defined$`)
+	unexpectedItemRe = regexp.MustCompile(`^unexpected "([^"]+)"`)
+	missingKeyRe     = regexp.MustCompile(`^map has no entry for key "([^"]+)"$`)
+)
+
+// newTemplateError returns the TemplateError of err, an error of text/template
+// interpolating tpl with funcs.
+func newTemplateError(tpl string, err error, funcs template.FuncMap) *TemplateError {
+	e := &TemplateError{Template: tpl, Message: err.Error(), Err: err}
+	m := templateErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return e
+	}
+	e.Line, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		// text/template counts the bytes from 0.
+		column, _ := strconv.Atoi(m[2])
+		e.Column = column + 1
+	}
+	e.Message = m[3]
+
+	if m := undefinedFuncRe.FindStringSubmatch(e.Message); m != nil {
+		e.Message = fmt.Sprintf("unknown function `%s`", m[1])
+		names := didyoumean.NewSet("function")
+		for name := range funcs {
+			names.Add(name)
+		}
+		if suggestion := names.Suggest(m[1]); suggestion != "" {
+			e.Message += fmt.Sprintf(", did you mean `%s`?", suggestion)
+		}
+		e.Column = e.columnOf(m[1])
+	} else if m := unexpectedItemRe.FindStringSubmatch(e.Message); m != nil && e.Column == 0 {
+		e.Column = e.columnOf(m[1])
+	}
+	return e
+}
+
+// columnOf returns the column of the first occurrence of s in an action of
+// the line of the error, 0 when not found.
+func (e *TemplateError) columnOf(s string) int {
+	lines := strings.Split(e.Template, "\n")
+	if e.Line < 1 || e.Line > len(lines) {
+		return 0
+	}
+	line := lines[e.Line-1]
+	offset := strings.Index(line, "{{")
+	if offset < 0 {
+		offset = 0
+	}
+	i := strings.Index(line[offset:], s)
+	if i < 0 {
+		return 0
+	}
+	return offset + i + 1
+}
+
+// suggestKey describes the failure of a strict interpolation referencing a key
+// missing from data, suggesting a close key.
+func (e *TemplateError) suggestKey(data interface{}) {
+	m := missingKeyRe.FindStringSubmatch(e.Message)
+	if m == nil {
+		return
+	}
+	e.Message = fmt.Sprintf("undefined variable `%s`", m[1])
+	if suggestion := didyoumean.NameSuggestion(m[1], dataKeys(data)); suggestion != "" {
+		e.Message += fmt.Sprintf(", did you mean `%s`?", suggestion)
+	}
+	e.Column = e.columnOf("." + m[1])
+}
+
+// dataKeys returns the sorted keys of data, when it is a map.
+func dataKeys(data interface{}) []string {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
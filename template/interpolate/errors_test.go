@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTemplateError_unknownFunction(t *testing.T) {
+	_, err := Render("ami-{{user `x`}}-{{timestam}}", &Context{})
+	var tplErr *TemplateError
+	if !errors.As(err, &tplErr) {
+		t.Fatalf("expected a TemplateError, got %#v", err)
+	}
+	if tplErr.Line != 1 || tplErr.Column != 20 {
+		t.Fatalf("unexpected position %d:%d", tplErr.Line, tplErr.Column)
+	}
+	expected := "at column 20: unknown function `timestam`, did you mean `timestamp`?"
+	if err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err.Error())
+	}
+	expected = "ami-{{user `x`}}-{{timestam}}\n                   ^"
+	if tplErr.Excerpt() != expected {
+		t.Fatalf("expected the excerpt %q, got %q", expected, tplErr.Excerpt())
+	}
+
+	if err := Validate("{{timestam}}", &Context{}); !errors.As(err, &tplErr) {
+		t.Fatalf("expected a TemplateError, got %#v", err)
+	}
+}
+
+func TestTemplateError_execute(t *testing.T) {
+	ctx := &Context{
+		EnableEnv: false,
+	}
+	_, err := Render("first\n  {{env `HOME`}}", ctx)
+	var tplErr *TemplateError
+	if !errors.As(err, &tplErr) {
+		t.Fatalf("expected a TemplateError, got %#v", err)
+	}
+	if tplErr.Line != 2 || tplErr.Column != 5 {
+		t.Fatalf("unexpected position %d:%d", tplErr.Line, tplErr.Column)
+	}
+	if !strings.HasPrefix(err.Error(), "at line 2, column 5: error calling env: ") {
+		t.Fatalf("unexpected error %q", err.Error())
+	}
+	if tplErr.Err == nil {
+		t.Fatal("expected the text/template error")
+	}
+}
+
+func TestRenderMap_templateError(t *testing.T) {
+	m := map[string]interface{}{
+		"ami_name": "packer-ami {{timestam}}",
+	}
+	_, err := RenderMap(m, &Context{}, nil)
+	expected := "in `ami_name` at column 14: unknown function `timestam`, did you mean `timestamp`?"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestRender_strict(t *testing.T) {
+	ctx := &Context{
+		UserVariables: map[string]string{"region": "us-east-1"},
+		Data:          map[string]string{"Name": "packer"},
+	}
+	result, err := Render("{{user `regoin`}}-{{.Nmae}}", ctx)
+	if err != nil || result != "-<no value>" {
+		t.Fatalf("expected the template to render without strict mode, got %q, %v", result, err)
+	}
+
+	ctx.Strict = true
+	_, err = Render("ami-{{user `regoin`}}", ctx)
+	if err == nil || !strings.Contains(err.Error(), "undefined user variable `regoin`, did you mean `region`?") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = Render("ami-{{.Nmae}}", ctx)
+	expected := "at column 7: undefined variable `Nmae`, did you mean `Name`?"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+
+	result, err = Render("{{user `region`}}-{{.Name}}", ctx)
+	if err != nil || result != "us-east-1-packer" {
+		t.Fatalf("unexpected result %q, %v", result, err)
+	}
+}
@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/didyoumean"
 	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	commontpl "github.com/hashicorp/packer-plugin-sdk/template"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
@@ -54,6 +56,27 @@ var FuncGens = map[string]interface{}{
 
 	"upper": strings.ToUpper,
 	"lower": strings.ToLower,
+
+	"regex_replace": regex_replace,
+	"b64encode":     b64encode,
+	"b64decode":     b64decode,
+	"cidrhost":      cidrhost,
+	"cidrsubnet":    cidrsubnet,
+	"jsonencode":    jsonencode,
+	"jsondecode":    jsondecode,
+	"yamlencode":    yamlencode,
+}
+
+// FunctionNames are the names of the built-in functions and of the
+// functions registered with RegisterFunction, registered with didyoumean.
+var FunctionNames = didyoumean.Register(didyoumean.NewSet("interpolation function", funcGenNames()...))
+
+func funcGenNames() []string {
+	names := make([]string, 0, len(FuncGens))
+	for name := range FuncGens {
+		names = append(names, name)
+	}
+	return names
 }
 
 var ErrVariableNotSetString = "Error: variable not set:"
@@ -63,7 +86,8 @@ var ErrVariableNotSetString = "Error: variable not set:"
 type FuncGenerator func(*Context) interface{}
 
 // Funcs returns the functions that can be used for interpolation given
-// a context.
+// a context: the built-in functions, the functions registered with
+// RegisterFunction and the functions of the context.
 func Funcs(ctx *Context) template.FuncMap {
 	result := make(map[string]interface{})
 	for k, v := range FuncGens {
@@ -74,6 +98,7 @@ func Funcs(ctx *Context) template.FuncMap {
 			result[k] = v
 		}
 	}
+	registeredFuncs(result)
 	if ctx != nil {
 		for k, v := range ctx.Funcs {
 			result[k] = v
@@ -169,6 +194,12 @@ func funcGenTemplateDir(ctx *Context) interface{} {
 
 func passthroughOrInterpolate(data map[interface{}]interface{}, s string) (string, error) {
 	if heldPlace, ok := data[s]; ok {
+		switch d := heldPlace.(type) {
+		case Deferred:
+			return d.String(), nil
+		case *Deferred:
+			return d.String(), nil
+		}
 		if hp, ok := heldPlace.(string); ok {
 			// If we're in the first interpolation pass, the goal is to
 			// make sure that we pass the value through.
@@ -234,10 +265,27 @@ func funcGenUser(ctx *Context) interface{} {
 				return "", fmt.Errorf("%s %s", ErrVariableNotSetString, k)
 			}
 		}
+		if !ok && ctx.Strict {
+			return "", undefinedUserVariable(k, ctx.UserVariables)
+		}
 		return val, nil
 	}
 }
 
+// undefinedUserVariable returns the error of a strict interpolation
+// referencing the undefined user variable k, suggesting a close variable.
+func undefinedUserVariable(k string, vars map[string]string) error {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if suggestion := didyoumean.NameSuggestion(k, names); suggestion != "" {
+		return fmt.Errorf("undefined user variable `%s`, did you mean `%s`?", k, suggestion)
+	}
+	return fmt.Errorf("undefined user variable `%s`", k)
+}
+
 func funcGenUuid(ctx *Context) interface{} {
 	return func() string {
 		return uuid.TimeOrderedUUID()
@@ -31,6 +31,11 @@ type Context struct {
 	// EnableEnv enables the env function
 	EnableEnv bool
 
+	// Strict fails the interpolation of templates referencing undefined
+	// user variables or keys of Data, instead of rendering them as empty
+	// strings or "<no value>".
+	Strict bool
+
 	// All the fields below are used for built-in functions.
 	//
 	// BuildName and BuildType are the name and type, respectively,
@@ -49,6 +54,18 @@ func NewContext() *Context {
 	return &Context{}
 }
 
+// SensitiveValues returns the non-empty values of the sensitive user
+// variables.
+func (ctx *Context) SensitiveValues() []string {
+	var values []string
+	for _, k := range ctx.SensitiveVariables {
+		if v := ctx.UserVariables[k]; v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 // RenderOnce is shorthand for constructing an I and calling Render one time.
 func RenderOnce(v string, ctx *Context) (string, error) {
 	return (&I{Value: v}).Render(ctx)
@@ -108,9 +125,13 @@ type I struct {
 
 // Render renders the interpolation with the given context.
 func (i *I) Render(ictx *Context) (string, error) {
-	tpl, err := i.template(ictx)
+	funcs := Funcs(ictx)
+	tpl, err := i.template(funcs)
 	if err != nil {
-		return "", err
+		return "", newTemplateError(i.Value, err, funcs)
+	}
+	if ictx != nil && ictx.Strict {
+		tpl.Option("missingkey=error")
 	}
 
 	var result bytes.Buffer
@@ -119,7 +140,9 @@ func (i *I) Render(ictx *Context) (string, error) {
 		data = ictx.Data
 	}
 	if err := tpl.Execute(&result, data); err != nil {
-		return "", err
+		tplErr := newTemplateError(i.Value, err, funcs)
+		tplErr.suggestKey(data)
+		return "", tplErr
 	}
 
 	return result.String(), nil
@@ -127,10 +150,13 @@ func (i *I) Render(ictx *Context) (string, error) {
 
 // Validate validates that the template is syntactically valid.
 func (i *I) Validate(ctx *Context) error {
-	_, err := i.template(ctx)
-	return err
+	funcs := Funcs(ctx)
+	if _, err := i.template(funcs); err != nil {
+		return newTemplateError(i.Value, err, funcs)
+	}
+	return nil
 }
 
-func (i *I) template(ctx *Context) (*template.Template, error) {
-	return template.New("root").Funcs(Funcs(ctx)).Parse(i.Value)
+func (i *I) template(funcs template.FuncMap) (*template.Template, error) {
+	return template.New("root").Funcs(funcs).Parse(i.Value)
 }
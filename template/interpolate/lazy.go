@@ -0,0 +1,161 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// Deferred is a value of the data of a Context which is not known yet, like
+// the WinRMPassword or the IP of an instance when the configuration of a
+// provisioner is prepared. Templates referencing it are rendered with
+// RenderLazy, and evaluated again with Lazy.Resolve once it is known.
+//
+// Rendered with Render, it is printed as the template referencing it, like
+// "{{.WinRMPassword}}", as the generated data placeholders are.
+type Deferred struct {
+	// Name is the key of the value in the data of the Context.
+	Name string
+}
+
+func (d Deferred) String() string {
+	return fmt.Sprintf("{{.%s}}", d.Name)
+}
+
+// Lazy is a template whose evaluation waits for the deferred values it
+// references to be known.
+type Lazy struct {
+	// Template is the template to evaluate.
+	Template string
+	// Missing are the names of the deferred values the template references,
+	// sorted. The template is evaluated once there are none.
+	Missing []string
+
+	value string
+}
+
+// RenderLazy renders v like Render. When v references deferred values of the
+// data of ctx, Deferred values or generated data placeholders, the returned
+// Lazy lists them in Missing and its value is not known.
+func RenderLazy(v string, ctx *Context) (*Lazy, error) {
+	l := &Lazy{Template: v}
+	return l, l.Resolve(ctx)
+}
+
+// Known returns whether the template could be evaluated.
+func (l *Lazy) Known() bool {
+	return len(l.Missing) == 0
+}
+
+// Value returns the evaluated template, or an error listing the deferred
+// values it still misses.
+func (l *Lazy) Value() (string, error) {
+	if !l.Known() {
+		return "", fmt.Errorf("%q references values which are not known yet: %s",
+			l.Template, strings.Join(l.Missing, ", "))
+	}
+	return l.value, nil
+}
+
+// Resolve evaluates the template again with ctx, once the deferred values
+// are known.
+func (l *Lazy) Resolve(ctx *Context) error {
+	var sentinels map[string]string
+	if ctx != nil {
+		var data interface{}
+		data, sentinels = markDeferred(ctx.Data)
+		if len(sentinels) > 0 {
+			copied := *ctx
+			copied.Data = data
+			ctx = &copied
+		}
+	}
+
+	rendered, err := Render(l.Template, ctx)
+	if err != nil {
+		return err
+	}
+
+	missing := map[string]struct{}{}
+	for _, m := range deferredRe.FindAllStringSubmatch(rendered, -1) {
+		// Functions like upper change the case of the sentinels.
+		for name := range sentinels {
+			if strings.EqualFold(name, m[1]) {
+				missing[name] = struct{}{}
+			}
+		}
+	}
+	l.Missing = l.Missing[:0]
+	for name := range missing {
+		l.Missing = append(l.Missing, name)
+	}
+	sort.Strings(l.Missing)
+	l.value = ""
+	if l.Known() {
+		l.value = rendered
+	}
+	return nil
+}
+
+var deferredRe = regexp.MustCompile(`(?i)__packer_deferred_([a-z0-9_]+?)__`)
+
+func deferredSentinel(name string) string {
+	return "__packer_deferred_" + name + "__"
+}
+
+// isDeferred returns whether v is a value which is not known yet: a
+// Deferred value, or a generated data placeholder.
+func isDeferred(v interface{}) bool {
+	switch v := v.(type) {
+	case Deferred, *Deferred:
+		return true
+	case string:
+		return strings.Contains(v, packerbuilderdata.PlaceholderMsg)
+	}
+	return false
+}
+
+// markDeferred returns a copy of data where the deferred values are replaced
+// by sentinels, which show in the rendered templates referencing them.
+func markDeferred(data interface{}) (interface{}, map[string]string) {
+	sentinels := map[string]string{}
+	switch data := data.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			if isDeferred(v) {
+				sentinels[k] = deferredSentinel(k)
+				v = sentinels[k]
+			}
+			copied[k] = v
+		}
+		return copied, sentinels
+	case map[interface{}]interface{}:
+		copied := make(map[interface{}]interface{}, len(data))
+		for k, v := range data {
+			if name, ok := k.(string); ok && isDeferred(v) {
+				sentinels[name] = deferredSentinel(name)
+				v = sentinels[name]
+			}
+			copied[k] = v
+		}
+		return copied, sentinels
+	case map[string]string:
+		copied := make(map[string]string, len(data))
+		for k, v := range data {
+			if isDeferred(v) {
+				sentinels[k] = deferredSentinel(k)
+				v = sentinels[k]
+			}
+			copied[k] = v
+		}
+		return copied, sentinels
+	}
+	return data, sentinels
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+func TestRenderLazy(t *testing.T) {
+	ctx := &Context{
+		Data: map[string]interface{}{
+			"User":          "admin",
+			"WinRMPassword": Deferred{Name: "WinRMPassword"},
+			"Host":          packerbuilderdata.PlaceholderMsg,
+		},
+	}
+	l, err := RenderLazy(`{{.User}}:{{upper .WinRMPassword}}@{{ build "Host" }}`, ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l.Known() || !reflect.DeepEqual(l.Missing, []string{"Host", "WinRMPassword"}) {
+		t.Fatalf("expected Host and WinRMPassword to be missing, got %q", l.Missing)
+	}
+	if _, err := l.Value(); err == nil {
+		t.Fatal("the value of a lazy template referencing deferred values should not be known")
+	}
+
+	ctx.Data = map[string]interface{}{
+		"User":          "admin",
+		"WinRMPassword": "s3cr3t",
+		"Host":          "10.0.0.1",
+	}
+	if err := l.Resolve(ctx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	v, err := l.Value()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "admin:S3CR3T@10.0.0.1" {
+		t.Fatalf("unexpected value %q", v)
+	}
+
+	// Templates not referencing deferred values are known right away.
+	l, err = RenderLazy(`{{.User}}`, &Context{Data: map[string]string{"User": "admin", "Host": packerbuilderdata.PlaceholderMsg}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v, err := l.Value(); err != nil || v != "admin" {
+		t.Fatalf("unexpected value %q, %v", v, err)
+	}
+}
+
+func TestRender_deferred(t *testing.T) {
+	// Deferred values are passed through by Render, like the placeholders.
+	ctx := &Context{Data: map[string]interface{}{"WinRMPassword": &Deferred{Name: "WinRMPassword"}}}
+	for _, tpl := range []string{`{{.WinRMPassword}}`, `{{ build "WinRMPassword" }}`} {
+		v, err := RenderOnce(tpl, ctx)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if v != "{{.WinRMPassword}}" {
+			t.Fatalf("%s: unexpected value %q", tpl, v)
+		}
+	}
+}
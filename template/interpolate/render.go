@@ -4,6 +4,7 @@
 package interpolate
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -39,7 +40,7 @@ func RenderMap(v interface{}, ctx *Context, f *RenderFilter) (map[string]interfa
 	for k, raw := range m {
 		// Always validate every field
 		if err := ValidateInterface(raw, ctx); err != nil {
-			return nil, fmt.Errorf("invalid '%s': %s", k, err)
+			return nil, optionError(k, err, "invalid")
 		}
 
 		if !f.include(k) {
@@ -48,7 +49,7 @@ func RenderMap(v interface{}, ctx *Context, f *RenderFilter) (map[string]interfa
 
 		raw, err := RenderInterface(raw, ctx)
 		if err != nil {
-			return nil, fmt.Errorf("render '%s': %s", k, err)
+			return nil, optionError(k, err, "render")
 		}
 
 		m[k] = raw
@@ -57,6 +58,17 @@ func RenderMap(v interface{}, ctx *Context, f *RenderFilter) (map[string]interfa
 	return m, nil
 }
 
+// optionError sets the option of the TemplateError of err to k, or prefixes
+// err with the action and k.
+func optionError(k string, err error, action string) error {
+	var tplErr *TemplateError
+	if errors.As(err, &tplErr) {
+		tplErr.Option = k
+		return tplErr
+	}
+	return fmt.Errorf("%s '%s': %s", action, k, err)
+}
+
 // RenderInterface renders any value and returns the resulting value.
 func RenderInterface(v interface{}, ctx *Context) (interface{}, error) {
 	f := func(v string) (string, error) {
@@ -246,6 +258,10 @@ func (w *renderWalker) Primitive(v reflect.Value) error {
 
 	replaceVal, err := w.F(strV)
 	if err != nil {
+		var tplErr *TemplateError
+		if errors.As(err, &tplErr) {
+			return tplErr
+		}
 		return fmt.Errorf(
 			"%s in:\n\n%s",
 			err, v.String())
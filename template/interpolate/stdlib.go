@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// regex_replace replaces the matches of the regular expression pattern in src
+// with repl, which can reference the submatches like $1. Like replace, src is
+// last so that it can be piped.
+func regex_replace(pattern, repl, src string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(src, repl), nil
+}
+
+func b64encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func b64decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("b64decode: %s", err)
+	}
+	return string(b), nil
+}
+
+// cidrhost returns the address of the host numbered hostnum in the network
+// prefix. A negative hostnum counts from the end of the network.
+func cidrhost(prefix string, hostnum int) (string, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", fmt.Errorf("cidrhost: %s", err)
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	num := big.NewInt(int64(hostnum))
+	if hostnum < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("cidrhost: prefix %s has no host %d", prefix, hostnum)
+	}
+	return addIP(network.IP, num).String(), nil
+}
+
+// cidrsubnet returns the subnet numbered netnum of the network prefix,
+// extending its mask by newbits.
+func cidrsubnet(prefix string, newbits int, netnum int) (string, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", fmt.Errorf("cidrsubnet: %s", err)
+	}
+	ones, bits := network.Mask.Size()
+	if newbits < 0 || ones+newbits > bits {
+		return "", fmt.Errorf("cidrsubnet: cannot extend prefix %s by %d bits", prefix, newbits)
+	}
+	num := big.NewInt(int64(netnum))
+	if num.Sign() < 0 || num.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(newbits))) >= 0 {
+		return "", fmt.Errorf("cidrsubnet: prefix %s extended by %d bits has no subnet %d", prefix, newbits, netnum)
+	}
+	num.Lsh(num, uint(bits-ones-newbits))
+	subnet := &net.IPNet{
+		IP:   addIP(network.IP, num),
+		Mask: net.CIDRMask(ones+newbits, bits),
+	}
+	return subnet.String(), nil
+}
+
+// addIP returns the address n after ip.
+func addIP(ip net.IP, n *big.Int) net.IP {
+	sum := new(big.Int).Add(new(big.Int).SetBytes(ip), n)
+	b := sum.Bytes()
+	result := make(net.IP, len(ip))
+	copy(result[len(result)-len(b):], b)
+	return result
+}
+
+func jsonencode(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("jsonencode: %s", err)
+	}
+	return string(b), nil
+}
+
+func jsondecode(s string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("jsondecode: %s", err)
+	}
+	return v, nil
+}
+
+func yamlencode(v interface{}) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("yamlencode: %s", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStdlibFuncs(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{
+			`{{ "packer-1.2.3" | regex_replace "[^a-z]+" "_" }}`,
+			`packer_`,
+		},
+		{
+			`{{ regex_replace "v(\\d+)" "version $1" "v12" }}`,
+			`version 12`,
+		},
+		{
+			`{{ "hello" | b64encode }}`,
+			`aGVsbG8=`,
+		},
+		{
+			`{{ "aGVsbG8=" | b64decode }}`,
+			`hello`,
+		},
+		{
+			`{{ cidrhost "10.12.0.0/16" 5 }}`,
+			`10.12.0.5`,
+		},
+		{
+			`{{ cidrhost "10.12.0.0/16" -2 }}`,
+			`10.12.255.254`,
+		},
+		{
+			`{{ cidrhost "fd00::/64" 1 }}`,
+			`fd00::1`,
+		},
+		{
+			`{{ cidrsubnet "10.12.0.0/16" 8 3 }}`,
+			`10.12.3.0/24`,
+		},
+		{
+			`{{ cidrsubnet "fd00::/56" 8 255 }}`,
+			`fd00:0:0:ff::/64`,
+		},
+		{
+			`{{ (jsondecode "{\"name\": \"packer\", \"tags\": [1, 2]}").name }}`,
+			`packer`,
+		},
+		{
+			`{{ jsondecode "{\"b\": [1, \"2\"], \"a\": true}" | jsonencode }}`,
+			`{"a":true,"b":[1,"2"]}`,
+		},
+		{
+			`{{ jsondecode "{\"b\": [1, \"2\"], \"a\": true}" | yamlencode }}`,
+			"a: true\nb:\n    - 1\n    - \"2\"\n",
+		},
+	}
+
+	for _, tc := range cases {
+		result, err := Render(tc.Input, &Context{})
+		if err != nil {
+			t.Fatalf("Input: %s\n\nerr: %s", tc.Input, err)
+		}
+
+		if diff := cmp.Diff(tc.Output, result); diff != "" {
+			t.Fatalf("Input: %s\n\nUnexpected output: %s", tc.Input, diff)
+		}
+	}
+}
+
+func TestStdlibFuncs_invalid(t *testing.T) {
+	cases := []string{
+		`{{ regex_replace "(" "" "x" }}`,
+		`{{ b64decode "%%%" }}`,
+		`{{ cidrhost "10.0.0.0/30" 4 }}`,
+		`{{ cidrhost "10.0.0.0" 1 }}`,
+		`{{ cidrsubnet "10.0.0.0/30" 3 0 }}`,
+		`{{ cidrsubnet "10.0.0.0/16" 2 4 }}`,
+		`{{ jsondecode "{" }}`,
+	}
+
+	for _, tc := range cases {
+		if _, err := Render(tc, &Context{}); err == nil {
+			t.Fatalf("Input: %s\n\nexpected an error", tc)
+		}
+	}
+}
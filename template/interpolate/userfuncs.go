@@ -0,0 +1,230 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type is the type of an argument or of the result of a Function.
+type Type int
+
+const (
+	TypeString Type = iota
+	TypeInt
+	TypeBool
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeBool:
+		return "bool"
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Function is an interpolation function added by a plugin with
+// RegisterFunction, like a `clean_resource_name` helper of a builder.
+//
+// Functions are sandboxed: they only get their arguments, checked against
+// Params, and not the Context of the interpolation. They must be
+// deterministic, which is enforced by caching their results: a function
+// called twice with the same arguments returns the first result again. A
+// function panicking or running longer than Timeout fails the
+// interpolation.
+type Function struct {
+	// Name is the name of the function in templates, like
+	// "clean_resource_name".
+	Name string
+	// Params are the types of the arguments of the function.
+	Params []Type
+	// Variadic is the type of the arguments following Params, if the
+	// function takes a variable number of arguments.
+	Variadic *Type
+	// Result is the type of the result of the function.
+	Result Type
+	// Impl computes the result of the function. The arguments are a string,
+	// int or bool, as set by Params and Variadic, and the result must be of
+	// the Result type.
+	Impl func(args ...interface{}) (interface{}, error)
+	// Timeout is the time the function can run for. Defaults to 1 second.
+	Timeout time.Duration
+}
+
+var (
+	functionsLock sync.RWMutex
+	functions     = map[string]*userFunc{}
+
+	functionNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// RegisterFunction makes f available in the interpolations done after, see
+// Funcs. Its name must not be the name of a built-in function, or of an
+// already registered one.
+func RegisterFunction(f Function) error {
+	if !functionNameRe.MatchString(f.Name) {
+		return fmt.Errorf("invalid function name %q", f.Name)
+	}
+	if f.Impl == nil {
+		return fmt.Errorf("function %s has no implementation", f.Name)
+	}
+	for _, t := range append(append([]Type{f.Result}, f.Params...), variadicTypes(f)...) {
+		if t < TypeString || t > TypeBool {
+			return fmt.Errorf("function %s has an invalid type %s", f.Name, t)
+		}
+	}
+
+	functionsLock.Lock()
+	defer functionsLock.Unlock()
+	if _, ok := FuncGens[f.Name]; ok {
+		return fmt.Errorf("function %s is a built-in function", f.Name)
+	}
+	if _, ok := functions[f.Name]; ok {
+		return fmt.Errorf("function %s is already registered", f.Name)
+	}
+	if f.Timeout == 0 {
+		f.Timeout = time.Second
+	}
+	functions[f.Name] = &userFunc{Function: f, results: map[string]userFuncResult{}}
+	FunctionNames.Add(f.Name)
+	return nil
+}
+
+// MustRegisterFunction is like RegisterFunction, but panics on error. It is
+// meant to be called from the init function of plugins.
+func MustRegisterFunction(f Function) {
+	if err := RegisterFunction(f); err != nil {
+		panic(err)
+	}
+}
+
+func variadicTypes(f Function) []Type {
+	if f.Variadic == nil {
+		return nil
+	}
+	return []Type{*f.Variadic}
+}
+
+// registeredFuncs adds the registered functions to funcs.
+func registeredFuncs(funcs map[string]interface{}) {
+	functionsLock.RLock()
+	defer functionsLock.RUnlock()
+	for name, f := range functions {
+		funcs[name] = f.call
+	}
+}
+
+type userFunc struct {
+	Function
+
+	l       sync.Mutex
+	results map[string]userFuncResult
+}
+
+type userFuncResult struct {
+	value interface{}
+	err   error
+}
+
+// call checks the arguments, and calls the implementation when the result
+// for these arguments isn't known yet.
+func (f *userFunc) call(args ...interface{}) (interface{}, error) {
+	if len(args) < len(f.Params) || (f.Variadic == nil && len(args) > len(f.Params)) {
+		return nil, fmt.Errorf("%s: expected %s arguments, got %d", f.Name, f.arity(), len(args))
+	}
+	var key strings.Builder
+	for i, arg := range args {
+		t := f.Variadic
+		if i < len(f.Params) {
+			t = &f.Params[i]
+		}
+		converted, err := convertArg(arg, *t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: argument %d: %s", f.Name, i+1, err)
+		}
+		args[i] = converted
+		fmt.Fprintf(&key, "%T:%q,", converted, fmt.Sprint(converted))
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+	if r, ok := f.results[key.String()]; ok {
+		return r.value, r.err
+	}
+	value, err := f.run(args)
+	f.results[key.String()] = userFuncResult{value, err}
+	return value, err
+}
+
+// run runs the implementation, recovering from its panics and enforcing its
+// timeout.
+func (f *userFunc) run(args []interface{}) (interface{}, error) {
+	done := make(chan userFuncResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- userFuncResult{err: fmt.Errorf("%s: panic: %v", f.Name, r)}
+			}
+		}()
+		value, err := f.Impl(args...)
+		if err != nil {
+			done <- userFuncResult{err: fmt.Errorf("%s: %s", f.Name, err)}
+			return
+		}
+		converted, err := convertArg(value, f.Result)
+		if err != nil {
+			done <- userFuncResult{err: fmt.Errorf("%s: invalid result: %s", f.Name, err)}
+			return
+		}
+		done <- userFuncResult{value: converted}
+	}()
+
+	timer := time.NewTimer(f.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: timed out after %s", f.Name, f.Timeout)
+	}
+}
+
+func (f *userFunc) arity() string {
+	if f.Variadic != nil {
+		return fmt.Sprintf("at least %d", len(f.Params))
+	}
+	return fmt.Sprint(len(f.Params))
+}
+
+// convertArg converts v to t, accepting any kind of integer for TypeInt.
+func convertArg(v interface{}, t Type) (interface{}, error) {
+	switch t {
+	case TypeString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case TypeBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case TypeInt:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return int(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(rv.Uint()), nil
+		}
+	}
+	return nil, fmt.Errorf("expected a %s, got %T", t, v)
+}
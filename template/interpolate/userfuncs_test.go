@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package interpolate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterFunction(t *testing.T) {
+	calls := 0
+	invalid := regexp.MustCompile(`[^a-z0-9-]+`)
+	MustRegisterFunction(Function{
+		Name:   "test_clean_resource_name",
+		Params: []Type{TypeString},
+		Result: TypeString,
+		Impl: func(args ...interface{}) (interface{}, error) {
+			calls++
+			return invalid.ReplaceAllString(strings.ToLower(args[0].(string)), "-"), nil
+		},
+	})
+	str := TypeString
+	MustRegisterFunction(Function{
+		Name:     "test_truncate",
+		Params:   []Type{TypeInt},
+		Variadic: &str,
+		Result:   TypeString,
+		Impl: func(args ...interface{}) (interface{}, error) {
+			s := ""
+			for _, a := range args[1:] {
+				s += a.(string)
+			}
+			if n := args[0].(int); len(s) > n {
+				s = s[:n]
+			}
+			return s, nil
+		},
+	})
+
+	cases := map[string]string{
+		`{{ test_clean_resource_name "My Image_1.0" }}`:           "my-image-1-0",
+		`{{ "My Image_1.0" | test_clean_resource_name }}`:         "my-image-1-0",
+		`{{ test_truncate 5 "packer" "-" "image" }}`:              "packe",
+		`{{ test_truncate 12 "packer" | upper }}`:                 "PACKER",
+		`{{ test_clean_resource_name (test_truncate 3 "ABCD") }}`: "abc",
+	}
+	for tpl, expected := range cases {
+		result, err := Render(tpl, &Context{})
+		if err != nil {
+			t.Fatalf("%s: err: %s", tpl, err)
+		}
+		if result != expected {
+			t.Fatalf("%s: expected %q, got %q", tpl, expected, result)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected the results to be cached, got %d calls", calls)
+	}
+
+	for tpl, msg := range map[string]string{
+		`{{ test_clean_resource_name 12 }}`:      "argument 1: expected a string, got int",
+		`{{ test_clean_resource_name "a" "b" }}`: "expected 1 arguments, got 2",
+		`{{ test_truncate }}`:                    "expected at least 1 arguments, got 0",
+	} {
+		if _, err := Render(tpl, &Context{}); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected an error containing %q, got %v", tpl, msg, err)
+		}
+	}
+}
+
+func TestRegisterFunction_sandbox(t *testing.T) {
+	MustRegisterFunction(Function{
+		Name:   "test_panic",
+		Result: TypeString,
+		Impl:   func(...interface{}) (interface{}, error) { panic("boom") },
+	})
+	MustRegisterFunction(Function{
+		Name:    "test_slow",
+		Result:  TypeString,
+		Timeout: 10 * time.Millisecond,
+		Impl: func(...interface{}) (interface{}, error) {
+			time.Sleep(time.Second)
+			return "", nil
+		},
+	})
+	MustRegisterFunction(Function{
+		Name:   "test_bad_result",
+		Result: TypeBool,
+		Impl:   func(...interface{}) (interface{}, error) { return "true", nil },
+	})
+	MustRegisterFunction(Function{
+		Name:   "test_error",
+		Result: TypeString,
+		Impl:   func(...interface{}) (interface{}, error) { return nil, errors.New("no way") },
+	})
+	for tpl, msg := range map[string]string{
+		`{{ test_panic }}`:      "test_panic: panic: boom",
+		`{{ test_slow }}`:       "test_slow: timed out after 10ms",
+		`{{ test_bad_result }}`: "test_bad_result: invalid result: expected a bool, got string",
+		`{{ test_error }}`:      "test_error: no way",
+	} {
+		if _, err := Render(tpl, &Context{}); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected an error containing %q, got %v", tpl, msg, err)
+		}
+	}
+
+	for _, f := range []Function{
+		{Name: "upper", Impl: func(...interface{}) (interface{}, error) { return "", nil }},
+		{Name: "test_panic", Impl: func(...interface{}) (interface{}, error) { return "", nil }},
+		{Name: "not-valid", Impl: func(...interface{}) (interface{}, error) { return "", nil }},
+		{Name: "test_no_impl"},
+		{Name: "test_bad_type", Result: Type(12), Impl: func(...interface{}) (interface{}, error) { return "", nil }},
+	} {
+		if err := RegisterFunction(f); err == nil {
+			t.Fatalf("registering %s should fail", f.Name)
+		}
+	}
+}
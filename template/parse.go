@@ -43,7 +43,7 @@ type rawTemplate struct {
 func (r *rawTemplate) MarshalJSON() ([]byte, error) {
 	// Avoid recursion
 	type rawTemplate_ rawTemplate
-	out, _ := json.Marshal(rawTemplate_(*r))
+	out, _ := marshalJSON(rawTemplate_(*r))
 
 	var m map[string]json.RawMessage
 	_ = json.Unmarshal(out, &m)
@@ -52,12 +52,12 @@ func (r *rawTemplate) MarshalJSON() ([]byte, error) {
 	delete(m, "comments")
 	for _, comment := range r.Comments {
 		for k, v := range comment {
-			out, _ = json.Marshal(v)
+			out, _ = marshalJSON(v)
 			m[k] = out
 		}
 	}
 
-	return json.Marshal(m)
+	return marshalJSON(m)
 }
 
 func (r *rawTemplate) decodeProvisioner(raw interface{}) (Provisioner, error) {
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	multierror "github.com/hashicorp/go-multierror"
@@ -47,14 +48,25 @@ func (t *Template) Raw() (*rawTemplate, error) {
 		out.Comments = append(out.Comments, map[string]string{k: v})
 	}
 
-	for _, b := range t.Builders {
-		out.Builders = append(out.Builders, b)
+	// Sort the builders by name so that the template is written the same
+	// way every time
+	names := make([]string, 0, len(t.Builders))
+	for name := range t.Builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.Builders = append(out.Builders, t.Builders[name])
 	}
 
 	for _, p := range t.Provisioners {
 		out.Provisioners = append(out.Provisioners, p)
 	}
 
+	if t.CleanupProvisioner != nil {
+		out.CleanupProvisioner = t.CleanupProvisioner
+	}
+
 	for _, pp := range t.PostProcessors {
 		out.PostProcessors = append(out.PostProcessors, pp)
 	}
@@ -86,19 +98,24 @@ type Builder struct {
 func (b *Builder) MarshalJSON() ([]byte, error) {
 	// Avoid recursion
 	type Builder_ Builder
-	out, _ := json.Marshal(Builder_(*b))
+	out, _ := marshalJSON(Builder_(*b))
 
 	var m map[string]json.RawMessage
 	_ = json.Unmarshal(out, &m)
 
+	// The name defaults to the type
+	if b.Name == b.Type {
+		delete(m, "name")
+	}
+
 	// Flatten Config
 	delete(m, "config")
 	for k, v := range b.Config {
-		out, _ = json.Marshal(v)
+		out, _ = marshalJSON(v)
 		m[k] = out
 	}
 
-	return json.Marshal(m)
+	return marshalJSON(m)
 }
 
 // PostProcessor represents a post-processor within the template.
@@ -116,24 +133,29 @@ type PostProcessor struct {
 func (p *PostProcessor) MarshalJSON() ([]byte, error) {
 	// Early exit for simple definitions
 	if len(p.Config) == 0 && len(p.OnlyExcept.Only) == 0 && len(p.OnlyExcept.Except) == 0 && p.KeepInputArtifact == nil {
-		return json.Marshal(p.Type)
+		return marshalJSON(p.Type)
 	}
 
 	// Avoid recursion
 	type PostProcessor_ PostProcessor
-	out, _ := json.Marshal(PostProcessor_(*p))
+	out, _ := marshalJSON(PostProcessor_(*p))
 
 	var m map[string]json.RawMessage
 	_ = json.Unmarshal(out, &m)
 
+	// The name defaults to the type
+	if p.Name == p.Type {
+		delete(m, "name")
+	}
+
 	// Flatten Config
 	delete(m, "config")
 	for k, v := range p.Config {
-		out, _ = json.Marshal(v)
+		out, _ = marshalJSON(v)
 		m[k] = out
 	}
 
-	return json.Marshal(m)
+	return marshalJSON(m)
 }
 
 // Provisioner represents a provisioner within the template.
@@ -153,19 +175,27 @@ type Provisioner struct {
 func (p *Provisioner) MarshalJSON() ([]byte, error) {
 	// Avoid recursion
 	type Provisioner_ Provisioner
-	out, _ := json.Marshal(Provisioner_(*p))
+	out, _ := marshalJSON(Provisioner_(*p))
 
 	var m map[string]json.RawMessage
 	_ = json.Unmarshal(out, &m)
 
+	// Write the durations the way they are written in templates
+	if p.PauseBefore != 0 {
+		m["pause_before"], _ = marshalJSON(p.PauseBefore.String())
+	}
+	if p.Timeout != 0 {
+		m["timeout"], _ = marshalJSON(p.Timeout.String())
+	}
+
 	// Flatten Config
 	delete(m, "config")
 	for k, v := range p.Config {
-		out, _ = json.Marshal(v)
+		out, _ = marshalJSON(v)
 		m[k] = out
 	}
 
-	return json.Marshal(m)
+	return marshalJSON(m)
 }
 
 // Push represents the configuration for pushing the template to Atlas.
@@ -190,10 +220,10 @@ func (v *Variable) MarshalJSON() ([]byte, error) {
 	if v.Required {
 		// We use a nil pointer to coax Go into marshalling it as a JSON null
 		var ret *string
-		return json.Marshal(ret)
+		return marshalJSON(ret)
 	}
 
-	return json.Marshal(v.Default)
+	return marshalJSON(v.Default)
 }
 
 // OnlyExcept is a struct that is meant to be embedded that contains the
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes the template to w as a JSON template, which Parse reads
+// back into the same template. It can be used to modify templates
+// programmatically, like when migrating them.
+//
+// The output is stable: the keys of the objects are sorted, as are the
+// builders by name, and it is indented with two spaces. The root level
+// comments, the keys starting with an underscore, are written back, and so
+// are the comments of the builders, provisioners and post-processors, which
+// are part of their configuration.
+func (t *Template) WriteJSON(w io.Writer) error {
+	raw, err := t.Raw()
+	if err != nil {
+		return err
+	}
+
+	out, err := marshalJSON(raw)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, out, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// marshalJSON is like json.Marshal, but doesn't escape the HTML characters
+// of strings, like the "&&" and ">" of shell commands.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
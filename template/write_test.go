@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package template
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTemplateWriteJSON(t *testing.T) {
+	tpl, err := ParseFile(fixtureDir("write-comments.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.WriteJSON(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `{
+  "_comment": "Builds the web image",
+  "builders": [
+    {
+      "name": "aws",
+      "region": "{{user ` + "`region`" + `}}",
+      "type": "amazon-ebs"
+    },
+    {
+      "_comment": "for local testing",
+      "image": "ubuntu",
+      "type": "docker"
+    }
+  ],
+  "error-cleanup-provisioner": {
+    "inline": [
+      "echo cleanup"
+    ],
+    "type": "shell-local"
+  },
+  "post-processors": [
+    [
+      "compress"
+    ]
+  ],
+  "provisioners": [
+    {
+      "inline": [
+        "apt-get update && apt-get install -y nginx > /dev/null"
+      ],
+      "pause_before": "10s",
+      "type": "shell"
+    }
+  ],
+  "sensitive-variables": [
+    "password"
+  ],
+  "variables": {
+    "password": null,
+    "region": "us-east-1"
+  }
+}
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Fatalf("unexpected template: %s", diff)
+	}
+
+	// The written template is parsed back into the same template, and
+	// written the same way.
+	rewritten, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	rewritten.Path = tpl.Path
+	rewritten.RawContents = tpl.RawContents
+	if !reflect.DeepEqual(tpl, rewritten) {
+		t.Fatalf("the template changed when written:\n\n%#v\n\n%#v", tpl, rewritten)
+	}
+	var again bytes.Buffer
+	if err := rewritten.WriteJSON(&again); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if again.String() != buf.String() {
+		t.Fatalf("the template is not written the same way:\n\n%s", again.String())
+	}
+}
+
+func TestTemplateWriteJSON_modified(t *testing.T) {
+	tpl, err := ParseFile(fixtureDir("parse-comment.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tpl.Builders["something"].Config = map[string]interface{}{"foo": "bar"}
+	tpl.Provisioners = append(tpl.Provisioners, &Provisioner{
+		Type:   "shell",
+		Config: map[string]interface{}{"inline": []string{"echo hi"}},
+	})
+
+	var buf bytes.Buffer
+	if err := tpl.WriteJSON(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := `{
+  "_info": "foo",
+  "builders": [
+    {
+      "foo": "bar",
+      "type": "something"
+    }
+  ],
+  "provisioners": [
+    {
+      "inline": [
+        "echo hi"
+      ],
+      "type": "shell"
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Fatalf("unexpected template: %s", diff)
+	}
+}
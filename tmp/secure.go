@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tmp
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Prefix is prepended to the patterns of the files created by NewFile not
+// starting with it, so that the temporary files of Packer can be told apart,
+// and swept if left behind.
+var Prefix = "packer-"
+
+// FileOptions configures the temporary files created by NewFile.
+type FileOptions struct {
+	// Perm is the permission of the file, 0600 by default.
+	Perm os.FileMode
+	// OwnerOnly requires the file to be accessible by its owner only, for
+	// files holding secrets: Perm must not give access to the group or
+	// others, and the permission of the file is checked once created.
+	OwnerOnly bool
+	// RemoveOnClose removes the file when it is closed.
+	RemoveOnClose bool
+	// Shred overwrites the content of the file with zeros before it is
+	// removed, for files holding private keys or passwords. It implies
+	// RemoveOnClose. The data may remain with copy-on-write or journaling
+	// filesystems, and on SSDs.
+	Shred bool
+	// Register registers the file with the cleanup registry, so that
+	// Cleanup removes it, shredded if set, if it isn't closed, like when
+	// the build is interrupted.
+	Register bool
+}
+
+// SecureFile is a temporary file created by NewFile.
+type SecureFile struct {
+	*os.File
+	opts   FileOptions
+	closed bool
+}
+
+// NewFile creates a new temporary file like File, configured by opts. Its
+// pattern is prefixed with Prefix.
+func NewFile(pattern string, opts FileOptions) (*SecureFile, error) {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0600
+	}
+	if opts.OwnerOnly && perm&0077 != 0 {
+		return nil, fmt.Errorf("permission %v gives access to others than the owner", perm)
+	}
+	if !strings.HasPrefix(pattern, Prefix) {
+		pattern = Prefix + pattern
+	}
+
+	f, err := os.CreateTemp(tmpDir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	tf := &SecureFile{File: f, opts: opts}
+	if err := tf.setup(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if opts.Register {
+		Register(f.Name(), opts.Shred)
+	}
+	return tf, nil
+}
+
+func (f *SecureFile) setup(perm os.FileMode) error {
+	if perm != 0600 {
+		if err := f.Chmod(perm); err != nil {
+			return err
+		}
+	}
+	// The permission bits of the group and others don't apply on Windows.
+	if !f.opts.OwnerOnly || runtime.GOOS == "windows" {
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("temporary file %s is accessible by others than the owner: %v", f.Name(), fi.Mode().Perm())
+	}
+	return nil
+}
+
+// Close closes the file, and shreds and removes it as configured. It is
+// unregistered from the cleanup registry.
+func (f *SecureFile) Close() error {
+	if f.closed {
+		return f.File.Close()
+	}
+	f.closed = true
+	var shredErr error
+	if f.opts.Shred {
+		shredErr = shred(f.File)
+	}
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if f.opts.Register {
+		Unregister(f.Name())
+	}
+	if !f.opts.RemoveOnClose && !f.opts.Shred {
+		return shredErr
+	}
+	if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return shredErr
+}
+
+// shred overwrites the content of f with zeros.
+func shred(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zeros := make([]byte, 32*1024)
+	for off := int64(0); off < fi.Size(); off += int64(len(zeros)) {
+		n := fi.Size() - off
+		if n > int64(len(zeros)) {
+			n = int64(len(zeros))
+		}
+		if _, err := f.WriteAt(zeros[:n], off); err != nil {
+			return fmt.Errorf("failed to shred %s: %s", f.Name(), err)
+		}
+	}
+	return f.Sync()
+}
+
+// shredPath shreds the file at path.
+func shredPath(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return shred(f)
+}
+
+// The cleanup registry: the temporary paths to remove, and whether to shred
+// them.
+var (
+	registryMu sync.Mutex
+	registry   = map[string]bool{}
+)
+
+// Register registers path, a temporary file or directory, with the cleanup
+// registry, so that Cleanup removes it. A file is shredded first if shred is
+// set. The files created by NewFile with Register are unregistered once
+// closed; Unregister the others once removed.
+func Register(path string, shred bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[path] = shred
+}
+
+// Unregister removes path from the cleanup registry.
+func Unregister(path string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, path)
+}
+
+// Cleanup removes the paths of the cleanup registry, like when the build is
+// interrupted, and empties it. It returns the first error, once all the
+// paths were tried.
+func Cleanup() error {
+	registryMu.Lock()
+	paths := registry
+	registry = map[string]bool{}
+	registryMu.Unlock()
+
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	// the deepest paths first, the files of the directories before them
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+
+	var first error
+	for _, path := range sorted {
+		var err error
+		if paths[path] {
+			if fi, serr := os.Stat(path); serr == nil && fi.Mode().IsRegular() {
+				err = shredPath(path)
+			}
+		}
+		if rerr := os.RemoveAll(path); err == nil {
+			err = rerr
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
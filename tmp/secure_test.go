@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tmp
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNewFile(t *testing.T) {
+	tmpDir = t.TempDir()
+	defer func() { tmpDir = os.TempDir() }()
+
+	if _, err := NewFile("key", FileOptions{Perm: 0644, OwnerOnly: true}); err == nil {
+		t.Fatal("a permission open to others should be refused")
+	}
+
+	f, err := NewFile("key", FileOptions{OwnerOnly: true, Shred: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(f.Name()), Prefix) {
+		t.Fatalf("%s should start with %s", f.Name(), Prefix)
+	}
+	if fi, err := f.Stat(); err != nil || (runtime.GOOS != "windows" && fi.Mode().Perm() != 0600) {
+		t.Fatalf("unexpected permission: %v, %v", fi.Mode(), err)
+	}
+	if _, err := f.WriteString("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("a shredded file should be removed: %v", err)
+	}
+
+	f, err = NewFile("kept", FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := os.Stat(f.Name()); err != nil {
+		t.Fatalf("the file should be kept: %v", err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	tmpDir = t.TempDir()
+	defer func() { tmpDir = os.TempDir() }()
+
+	f, err := NewFile("registered", FileOptions{Register: true, Shred: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("secret")
+	closed, err := NewFile("closed", FileOptions{Register: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	d, err := Dir("packer-dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register(d, false)
+
+	if err := Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for _, path := range []string{f.Name(), d} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(closed.Name()); err != nil {
+		t.Fatalf("a closed file should be unregistered: %v", err)
+	}
+}
@@ -14,6 +14,9 @@
 //
 // The directory is neither guaranteed to exist nor have accessible
 // permissions.
+//
+// NewFile creates the temporary files holding secrets, with checked
+// permissions, shredded once closed, and registered for Cleanup.
 package tmp
 
 import (
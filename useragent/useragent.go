@@ -3,11 +3,24 @@
 
 // Package useragent creates a user agent for builders to use when calling out
 // to cloud APIs or other addresses.
+//
+// Plugins register their own product tokens, appended to the Packer one, so
+// that cloud providers can attribute the API calls to a plugin version:
+//
+//	useragent.Register(useragent.Product{
+//		Name:    "packer-plugin-amazon",
+//		Version: version.PluginVersion.String(),
+//	})
+//
+// gives user agents like "Packer/1.10.0 (+https://www.packer.io/; go1.21.5;
+// linux/amd64) packer-plugin-amazon/1.3.0".
 package useragent
 
 import (
 	"fmt"
 	"runtime"
+	"strings"
+	"sync"
 )
 
 var (
@@ -24,8 +37,92 @@ var (
 	goarch = runtime.GOARCH
 )
 
-// String returns the consistent user-agent string for Packer.
+var (
+	productsMu sync.Mutex
+	products   []Product
+)
+
+// Product is a product token of a user agent, like
+// "packer-plugin-amazon/1.3.0" or "ubuntu/22.04 (jammy)".
+type Product struct {
+	// Name is the name of the product. The characters that are not allowed
+	// in the tokens of HTTP headers, like spaces, are replaced with "-".
+	Name string
+	// Version is the version of the product, optional.
+	Version string
+	// Comment is a comment on the product, optional, like the distribution
+	// of the plugin. Parentheses are removed.
+	Comment string
+}
+
+// String returns the product token of the user agent.
+func (p Product) String() string {
+	s := token(p.Name)
+	if p.Version != "" {
+		s += "/" + token(p.Version)
+	}
+	if comment := strings.NewReplacer("(", "", ")", "").Replace(p.Comment); comment != "" {
+		s += " (" + comment + ")"
+	}
+	return s
+}
+
+// Register appends p to the product tokens of the user agents returned by
+// String. Products of the same name are replaced, keeping their place.
+func Register(p Product) {
+	productsMu.Lock()
+	defer productsMu.Unlock()
+	for i := range products {
+		if products[i].Name == p.Name {
+			products[i] = p
+			return
+		}
+	}
+	products = append(products, p)
+}
+
+// Unregister removes the product token named name.
+func Unregister(name string) {
+	productsMu.Lock()
+	defer productsMu.Unlock()
+	for i := range products {
+		if products[i].Name == name {
+			products = append(products[:i], products[i+1:]...)
+			return
+		}
+	}
+}
+
+// Products returns the registered products, in order.
+func Products() []Product {
+	productsMu.Lock()
+	defer productsMu.Unlock()
+	return append([]Product(nil), products...)
+}
+
+// String returns the consistent user-agent string for Packer, followed by
+// the registered product tokens.
 func String(packerVersion string) string {
-	return fmt.Sprintf("Packer/%s (+%s; %s; %s/%s)",
+	s := fmt.Sprintf("Packer/%s (+%s; %s; %s/%s)",
 		packerVersion, projectURL, rt, goos, goarch)
+	for _, p := range Products() {
+		if p.Name != "" {
+			s += " " + p.String()
+		}
+	}
+	return s
+}
+
+// token replaces the characters not allowed in the tokens of RFC 7230 with
+// "-".
+func token(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+			return r
+		}
+		return '-'
+	}, s)
 }
@@ -20,3 +20,26 @@ func TestUserAgent(t *testing.T) {
 		t.Errorf("expected %q to be %q", act, exp)
 	}
 }
+
+func TestUserAgent_products(t *testing.T) {
+	projectURL = "https://packer-test.com"
+	rt = "go5.0"
+	goos = "linux"
+	goarch = "amd64"
+	defer func() { products = nil }()
+
+	Register(Product{Name: "packer-plugin-test", Version: "0.1.0"})
+	Register(Product{Name: "my distro", Version: "22.04", Comment: "jammy (LTS)"})
+	Register(Product{Name: "packer-plugin-test", Version: "0.2.0"})
+
+	act := String("1.2.3")
+	exp := "Packer/1.2.3 (+https://packer-test.com; go5.0; linux/amd64) packer-plugin-test/0.2.0 my-distro/22.04 (jammy LTS)"
+	if exp != act {
+		t.Errorf("expected %q to be %q", act, exp)
+	}
+
+	Unregister("packer-plugin-test")
+	if p := Products(); len(p) != 1 || p[0].Name != "my distro" {
+		t.Errorf("bad products: %v", p)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package uuid
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// crockford is the base32 alphabet of Douglas Crockford, without the
+// letters I, L, O and U, used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a ULID, see https://github.com/ulid/spec: a 48 bits timestamp
+// in milliseconds followed by 80 random bits, in 26 characters of
+// crockford's base32. ULIDs sort by creation time, to the millisecond.
+func ULID() string {
+	return ulidAt(time.Now())
+}
+
+func ulidAt(t time.Time) string {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	randomBytes(b[6:])
+
+	// 128 bits in 26 characters of 5 bits, the first one holding 3 bits.
+	var s [26]byte
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[8+i])
+	}
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// ULIDTime returns the creation time of ulid, to the millisecond.
+func ULIDTime(ulid string) (time.Time, error) {
+	if len(ulid) != 26 {
+		return time.Time{}, fmt.Errorf("invalid ULID %q: not 26 characters long", ulid)
+	}
+	var ms uint64
+	for _, c := range strings.ToUpper(ulid[:10]) {
+		n := strings.IndexRune(crockford, c)
+		if n < 0 {
+			return time.Time{}, fmt.Errorf("invalid ULID %q: invalid character %q", ulid, c)
+		}
+		ms = ms<<5 | uint64(n)
+	}
+	if ms >= 1<<48 {
+		return time.Time{}, fmt.Errorf("invalid ULID %q: timestamp overflow", ulid)
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// shortIDEpoch is the origin of the timestamps of the short IDs.
+var shortIDEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// The bounds of the lengths of the short IDs.
+const (
+	shortIDTimeLength = 6
+	MinShortIDLength  = 10
+	MaxShortIDLength  = 63
+)
+
+// ShortID returns a short ID of length characters, for the names of cloud
+// resources: 6 characters of timestamp in seconds, so that the IDs sort by
+// creation time to the second, followed by random ones, in lowercase
+// crockford's base32. Short IDs are valid DNS labels, but can start with a
+// number; prepend a prefix, like "packer-", for the providers requiring a
+// letter. length is raised to MinShortIDLength, of 20 random bits, and
+// lowered to MaxShortIDLength, the DNS label limit.
+func ShortID(length int) string {
+	return shortIDAt(time.Now(), length)
+}
+
+func shortIDAt(t time.Time, length int) string {
+	if length < MinShortIDLength {
+		length = MinShortIDLength
+	}
+	if length > MaxShortIDLength {
+		length = MaxShortIDLength
+	}
+	s := make([]byte, length)
+	sec := uint64(t.Sub(shortIDEpoch) / time.Second)
+	for i := shortIDTimeLength - 1; i >= 0; i-- {
+		s[i] = crockford[sec&31]
+		sec >>= 5
+	}
+	random := make([]byte, length-shortIDTimeLength)
+	randomBytes(random)
+	for i, b := range random {
+		s[shortIDTimeLength+i] = crockford[b&31]
+	}
+	return strings.ToLower(string(s))
+}
+
+// randomBytes fills b with random bytes, panicking like TimeOrderedUUID if
+// there isn't enough entropy.
+func randomBytes(b []byte) {
+	n, err := rand.Read(b)
+	if n != len(b) {
+		err = fmt.Errorf("Not enough entropy available")
+	}
+	if err != nil {
+		panic(err)
+	}
+}
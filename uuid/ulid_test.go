@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package uuid
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestULID(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	ulid := ulidAt(now)
+	if !regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`).MatchString(ulid) {
+		t.Fatalf("bad ULID: %s", ulid)
+	}
+	got, err := ULIDTime(ulid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(now) {
+		t.Fatalf("ULIDTime: got %v, want %v", got, now)
+	}
+
+	// spec example: the timestamp of 01ARZ3NDEKTSV4RRFFQ69G5FAV
+	got, err = ULIDTime("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UnixMilli() != 1469922850259 {
+		t.Fatalf("bad timestamp %d", got.UnixMilli())
+	}
+
+	ids := []string{ulidAt(now.Add(2 * time.Millisecond)), ulidAt(now), ulidAt(now.Add(time.Millisecond))}
+	if sort.StringsAreSorted(ids) {
+		t.Fatal("test ids should not be sorted")
+	}
+	sort.Strings(ids)
+	for i, want := range []time.Time{now, now.Add(time.Millisecond), now.Add(2 * time.Millisecond)} {
+		if got, _ := ULIDTime(ids[i]); !got.Equal(want) {
+			t.Fatalf("ULIDs should sort by time: %v", ids)
+		}
+	}
+}
+
+func TestShortID(t *testing.T) {
+	label := regexp.MustCompile(`^[0-9a-z]+$`)
+	for _, tc := range []struct{ length, want int }{
+		{0, MinShortIDLength},
+		{16, 16},
+		{100, MaxShortIDLength},
+	} {
+		id := ShortID(tc.length)
+		if len(id) != tc.want || !label.MatchString(id) {
+			t.Fatalf("ShortID(%d): bad id %q", tc.length, id)
+		}
+	}
+
+	now := time.Now()
+	earlier, later := shortIDAt(now, 12), shortIDAt(now.Add(time.Second), 12)
+	if earlier[:shortIDTimeLength] >= later[:shortIDTimeLength] {
+		t.Fatalf("short IDs should sort by time: %s, %s", earlier, later)
+	}
+}
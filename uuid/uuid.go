@@ -1,7 +1,8 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
-// Package uuid provides helper functions for creating time-ordered UUIDs.
+// Package uuid provides helper functions for creating time-ordered UUIDs,
+// ULIDs and short IDs.
 package uuid
 
 import (
@@ -0,0 +1,229 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Constraints are version constraints separated by commas, all of which a
+// version must satisfy, like ">= 1.0, < 2.0" or "~> 1.2". The operators are
+// "=", "!=", ">", ">=", "<", "<=" and "~>", the pessimistic operator: "~>
+// 1.2" allows the 1.x versions from 1.2, "~> 1.2.3" the 1.2.x versions from
+// 1.2.3. A constraint without operator is an "=" one.
+//
+// Versions, prereleases included, are ordered as semantic versions: a
+// prerelease is lower than its release, 1.10.0-dev < 1.10.0, and
+// prereleases of a same version are ordered by their identifiers, alpha <
+// beta < rc1. Unlike the constraints of go-version, a prerelease satisfies
+// the constraints its order satisfies: a development build of Packer
+// 1.11.0-dev satisfies ">= 1.10.0".
+type Constraints struct {
+	raw         string
+	constraints []constraint
+}
+
+type constraint struct {
+	op  string
+	ver *version.Version
+	// upper is the excluded upper bound of a "~>" constraint.
+	upper *version.Version
+}
+
+// The operators of constraints, the longest first for parsing.
+var constraintOperators = []string{"~>", ">=", "<=", "!=", ">", "<", "="}
+
+// NewConstraints parses the constraints of s.
+func NewConstraints(s string) (*Constraints, error) {
+	c := &Constraints{raw: s}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("invalid version constraints %q: empty constraint", s)
+		}
+		op := "="
+		for _, o := range constraintOperators {
+			if strings.HasPrefix(part, o) {
+				op = o
+				part = strings.TrimSpace(strings.TrimPrefix(part, o))
+				break
+			}
+		}
+		ver, err := version.NewVersion(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraints %q: %s", s, err)
+		}
+		cons := constraint{op: op, ver: ver}
+		if op == "~>" {
+			cons.upper = pessimisticUpper(ver, segmentCount(part))
+		}
+		c.constraints = append(c.constraints, cons)
+	}
+	return c, nil
+}
+
+// MustConstraints is like NewConstraints but panics if the constraints are
+// invalid, for the constraints set by plugins in their code.
+func MustConstraints(s string) *Constraints {
+	c, err := NewConstraints(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Check returns whether v satisfies all the constraints.
+func (c *Constraints) Check(v *version.Version) bool {
+	for _, cons := range c.constraints {
+		if !cons.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckString returns whether the version of s satisfies all the
+// constraints, or an error if it isn't a valid version.
+func (c *Constraints) CheckString(s string) (bool, error) {
+	v, err := version.NewVersion(s)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+// String returns the constraints as parsed.
+func (c *Constraints) String() string {
+	return c.raw
+}
+
+func (cons constraint) check(v *version.Version) bool {
+	cmp := v.Compare(cons.ver)
+	switch cons.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "~>":
+		return cmp >= 0 && v.Compare(cons.upper) < 0
+	}
+	return false
+}
+
+// Compare compares the versions a and b, ordered as by Constraints, their
+// metadata ignored, and returns -1, 0 or 1 whether a is lower than, equal to
+// or greater than b.
+func Compare(a, b string) (int, error) {
+	va, err := version.NewVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := version.NewVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// segmentCount returns the number of segments of the core version of s,
+// like 2 for "1.2" or "1.2-beta".
+func segmentCount(s string) int {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Count(s, ".") + 1
+}
+
+// pessimisticUpper returns the excluded upper bound of "~> v", where v has
+// n segments: the version incrementing the segment before the last one, the
+// lowest prerelease of it excluded too.
+func pessimisticUpper(v *version.Version, n int) *version.Version {
+	segments := v.Segments()
+	if n < 2 {
+		n = 2
+	}
+	if n > len(segments) {
+		n = len(segments)
+	}
+	upper := make([]string, len(segments))
+	for i := range segments {
+		switch {
+		case i < n-2:
+			upper[i] = fmt.Sprint(segments[i])
+		case i == n-2:
+			upper[i] = fmt.Sprint(segments[i] + 1)
+		default:
+			upper[i] = "0"
+		}
+	}
+	// 0 is the lowest prerelease identifier, so that the prereleases of the
+	// upper bound are excluded too.
+	return version.Must(version.NewVersion(strings.Join(upper, ".") + "-0"))
+}
+
+// ConstraintError is the error of a version of a component, like Packer,
+// that doesn't satisfy the constraints a plugin requires.
+type ConstraintError struct {
+	// Component is the name of the component, like "Packer".
+	Component string
+	// Version is the version of the component.
+	Version string
+	// Constraints are the constraints the version doesn't satisfy.
+	Constraints string
+}
+
+func (err *ConstraintError) Error() string {
+	return fmt.Sprintf("%s version %s does not satisfy the version constraints %q required by this plugin",
+		err.Component, err.Version, err.Constraints)
+}
+
+// Require returns a *ConstraintError if the version v of component doesn't
+// satisfy constraints, or an error if either is invalid. Plugins call it,
+// like through common.PackerConfig.RequireCoreVersion, at Configure time,
+// to fail early with a friendly error.
+func Require(component, v, constraints string) error {
+	c, err := NewConstraints(constraints)
+	if err != nil {
+		return err
+	}
+	ok, err := c.CheckString(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s version %q: %s", component, v, err)
+	}
+	if !ok {
+		return &ConstraintError{
+			Component:   component,
+			Version:     v,
+			Constraints: constraints,
+		}
+	}
+	return nil
+}
+
+// RequireMinimum is Require with a ">= min" constraint.
+func RequireMinimum(component, v, min string) error {
+	return Require(component, v, ">= "+min)
+}
+
+// Satisfies returns whether the version satisfies the constraints, or an
+// error if they are invalid.
+func (p *PluginVersion) Satisfies(constraints string) (bool, error) {
+	c, err := NewConstraints(constraints)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(p.semVer), nil
+}
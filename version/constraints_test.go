@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package version
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConstraints_Check(t *testing.T) {
+	tests := []struct {
+		constraints string
+		version     string
+		want        bool
+	}{
+		{">= 1.0, < 2.0", "1.5.3", true},
+		{">= 1.0, < 2.0", "2.0.0", false},
+		{">= 1.0, < 2.0", "0.9.9", false},
+		{"~> 1.2", "1.2.0", true},
+		{"~> 1.2", "1.9.1", true},
+		{"~> 1.2", "2.0.0", false},
+		{"~> 1.2", "2.0.0-beta", false},
+		{"~> 1.2", "1.1.9", false},
+		{"~> 1.2.3", "1.2.9", true},
+		{"~> 1.2.3", "1.3.0", false},
+		{"1.2.3", "1.2.3", true},
+		{"= 1.2.3", "1.2.4", false},
+		{"!= 1.2.3", "1.2.4", true},
+		{"> 1.2.3", "1.2.3", false},
+		{"<= 1.2.3", "1.2.3", true},
+		{">= 1.2.3", "1.2.3+metadata", true},
+
+		// Prereleases are ordered as semantic versions.
+		{">= 1.10.0", "1.11.0-dev", true},
+		{">= 1.10.0", "1.10.0-dev", false},
+		{"< 1.10.0", "1.10.0-rc1", true},
+		{">= 1.10.0-beta", "1.10.0-alpha", false},
+		{">= 1.10.0-beta", "1.10.0-rc1", true},
+		{">= 1.10.0-alpha.2", "1.10.0-alpha.10", true},
+	}
+	for _, tt := range tests {
+		c, err := NewConstraints(tt.constraints)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.constraints, err)
+		}
+		got, err := c.CheckString(tt.version)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.version, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q satisfies %q: got %t, want %t", tt.version, tt.constraints, got, tt.want)
+		}
+	}
+}
+
+func TestNewConstraints_invalid(t *testing.T) {
+	for _, s := range []string{"", ">= 1.0,", ">= one", "=> 1.0"} {
+		if _, err := NewConstraints(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{"1.0.0-0", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta", "1.0.0-dev", "1.0.0-rc1", "1.0.0", "1.0.1"}
+	for i := 1; i < len(ordered); i++ {
+		cmp, err := Compare(ordered[i-1], ordered[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmp != -1 {
+			t.Errorf("expected %s < %s", ordered[i-1], ordered[i])
+		}
+	}
+	if cmp, _ := Compare("1.0.0+a", "1.0.0+b"); cmp != 0 {
+		t.Errorf("expected the metadata to be ignored")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	if err := RequireMinimum("Packer", "1.10.2", "1.10.0"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := RequireMinimum("Packer", "1.9.4", "1.10.0")
+	var cerr *ConstraintError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected a *ConstraintError, got %v", err)
+	}
+	if cerr.Component != "Packer" || cerr.Version != "1.9.4" || cerr.Constraints != ">= 1.10.0" {
+		t.Fatalf("bad error: %#v", cerr)
+	}
+
+	if err := Require("Packer", "not-a-version", ">= 1.0"); err == nil || errors.As(err, &cerr) {
+		t.Fatalf("expected an invalid version error, got %v", err)
+	}
+}
+
+func TestPluginVersion_Satisfies(t *testing.T) {
+	v := NewPluginVersion("1.2.3", "dev", "")
+	if ok, err := v.Satisfies("~> 1.2"); err != nil || !ok {
+		t.Fatalf("1.2.3-dev should satisfy ~> 1.2: %t %v", ok, err)
+	}
+	if ok, err := v.Satisfies(">= 1.2.3"); err != nil || ok {
+		t.Fatalf("1.2.3-dev should not satisfy >= 1.2.3: %t %v", ok, err)
+	}
+	if _, err := v.Satisfies(">="); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -2,7 +2,8 @@
 // SPDX-License-Identifier: MPL-2.0
 
 // Package version helps plugin creators set and track the plugin version using
-// the same convenience functions used by the Packer core.
+// the same convenience functions used by the Packer core, and check versions,
+// like the one of the Packer core, against constraints.
 package version
 
 import (